@@ -2,9 +2,12 @@ package bench_test
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"testing"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jws"
 )
 
@@ -65,6 +68,60 @@ func BenchmarkJWS(b *testing.B) {
 				tc.Run(b)
 			}
 		})
+		b.Run("HMAC", func(b *testing.B) {
+			key := []byte(`01234567890123456789012345678901`)
+			payload := []byte(`{"iss":"joe","exp":1300819380,"http://example.com/is_root":true}`)
+			dst := make([]byte, 0, 512)
+			testcases := []Case{
+				{
+					Name: "jws.Sign",
+					Test: func(b *testing.B) error {
+						_, err := jws.Sign(payload, jwa.HS256, key)
+						return err
+					},
+				},
+				{
+					Name: "jws.SignCompactHS",
+					Test: func(b *testing.B) error {
+						_, err := jws.SignCompactHS(dst[:0], jwa.HS256, key, payload)
+						return err
+					},
+				},
+			}
+			for _, tc := range testcases {
+				tc.Run(b)
+			}
+		})
+		b.Run("EdDSA", func(b *testing.B) {
+			pubkey, privkey, _ := ed25519.GenerateKey(rand.Reader)
+			messages := make([][]byte, 64)
+			for i := range messages {
+				messages[i], _ = jws.Sign([]byte(`{"iss":"joe","exp":1300819380,"n":`+string(rune('0'+i%10))+`}`), jwa.EdDSA, privkey)
+			}
+			testcases := []Case{
+				{
+					Name: "jws.Verify (64 messages)",
+					Test: func(b *testing.B) error {
+						for _, m := range messages {
+							if _, err := jws.Verify(m, jwa.EdDSA, pubkey); err != nil {
+								return err
+							}
+						}
+						return nil
+					},
+				},
+				{
+					Name: "jws.VerifyBatch (64 messages)",
+					Test: func(b *testing.B) error {
+						_, err := jws.VerifyBatch(messages, pubkey)
+						return err
+					},
+				},
+			}
+			for _, tc := range testcases {
+				tc.Run(b)
+			}
+		})
 		b.Run("JSON", func(b *testing.B) {
 			m, _ := jws.Parse([]byte(jsonStr))
 			testcases := []Case{
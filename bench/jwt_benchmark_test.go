@@ -8,6 +8,7 @@ import (
 
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
 )
 
@@ -19,6 +20,11 @@ func BenchmarkJWT(b *testing.B) {
 		b.Fatal(err)
 	}
 
+	pubkey, err := jwk.PublicKeyOf(key)
+	if err != nil {
+		b.Fatal(err)
+	}
+
 	t1 := jwt.New()
 	t1.Set(jwt.IssuedAtKey, time.Now().Unix())
 	t1.Set(jwt.ExpirationKey, time.Now().Add(time.Hour).Unix())
@@ -32,8 +38,6 @@ func BenchmarkJWT(b *testing.B) {
 		signedString := string(signedBuf)
 		signedReader := bytes.NewReader(signedBuf)
 		jsonBuf, _ := json.Marshal(t1)
-		jsonString := string(jsonBuf)
-		jsonReader := bytes.NewReader(jsonBuf)
 
 		b.Run("Sign", func(b *testing.B) {
 			testcases := []Case{
@@ -41,14 +45,14 @@ func BenchmarkJWT(b *testing.B) {
 					Name:      "jwt.ParseString",
 					SkipShort: true,
 					Test: func(b *testing.B) error {
-						_, err := jwt.ParseString(signedString)
+						_, err := jwt.ParseString(signedString, jwt.WithVerify(alg, pubkey))
 						return err
 					},
 				},
 				{
 					Name: "jwt.Parse",
 					Test: func(b *testing.B) error {
-						_, err := jwt.Parse(signedBuf)
+						_, err := jwt.Parse(signedBuf, jwt.WithVerify(alg, pubkey))
 						return err
 					},
 				},
@@ -60,7 +64,7 @@ func BenchmarkJWT(b *testing.B) {
 						return err
 					},
 					Test: func(b *testing.B) error {
-						_, err := jwt.ParseReader(signedReader)
+						_, err := jwt.ParseReader(signedReader, jwt.WithVerify(alg, pubkey))
 						return err
 					},
 				},
@@ -76,33 +80,36 @@ func BenchmarkJWT(b *testing.B) {
 				tc.Run(b)
 			}
 		})
-		b.Run("JSON", func(b *testing.B) {
-			var v interface{}
+		b.Run("HMAC", func(b *testing.B) {
+			hmacKey := []byte(`01234567890123456789012345678901`)
+			dst := make([]byte, 0, 512)
 			testcases := []Case{
 				{
-					Name:      "jwt.ParseString",
-					SkipShort: true,
+					Name: "jwt.Sign",
 					Test: func(b *testing.B) error {
-						_, err := jwt.ParseString(jsonString)
+						_, err := jwt.Sign(t1, jwa.HS256, hmacKey)
 						return err
 					},
 				},
 				{
-					Name: "jwt.Parse",
+					Name: "jwt.SignFast",
 					Test: func(b *testing.B) error {
-						_, err := jwt.Parse(jsonBuf)
+						_, err := jwt.SignFast(dst[:0], t1, jwa.HS256, hmacKey)
 						return err
 					},
 				},
+			}
+			for _, tc := range testcases {
+				tc.Run(b)
+			}
+		})
+		b.Run("JSON", func(b *testing.B) {
+			var v interface{}
+			testcases := []Case{
 				{
-					Name:      "jwt.ParseReader",
-					SkipShort: true,
-					Pretest: func(b *testing.B) error {
-						_, err := jsonReader.Seek(0, 0)
-						return err
-					},
+					Name: "jwt.ParseInsecure",
 					Test: func(b *testing.B) error {
-						_, err := jwt.ParseReader(jsonReader)
+						_, err := jwt.ParseInsecure(jsonBuf)
 						return err
 					},
 				},
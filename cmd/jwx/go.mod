@@ -8,3 +8,5 @@ require (
 	github.com/urfave/cli/v2 v2.3.0
 	golang.org/x/crypto v0.0.0-20201217014255-9d1352758620
 )
+
+replace github.com/lestrrat-go/jwx => ../../
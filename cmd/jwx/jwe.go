@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 
@@ -30,12 +31,30 @@ func makeJweCmd() *cli.Command {
 func keyEncryptionFlag(required bool) cli.Flag {
 	return &cli.StringFlag{
 		Name:     "key-encryption",
-		Aliases:  []string{"K"},
+		Aliases:  []string{"K", "alg"},
 		Usage:    "Key encryption algorithm name `NAME` (e.g. RSA-OAEP, ECDH-ES, A128GCMKW, etc)",
 		Required: required,
 	}
 }
 
+// keyEncryptionAlgorithm returns the key encryption algorithm to use with
+// the given key: the explicitly specified algorithm if any, otherwise
+// the "alg" field of the key
+func keyEncryptionAlgorithm(explicit string, key jwk.Key) (jwa.KeyEncryptionAlgorithm, error) {
+	var keyenc jwa.KeyEncryptionAlgorithm
+	name := explicit
+	if name == "" {
+		name = key.Algorithm()
+	}
+	if name == "" {
+		return keyenc, errors.New(`key encryption algorithm must be specified either via --key-encryption or the "alg" field of the key`)
+	}
+	if err := keyenc.Accept(name); err != nil {
+		return keyenc, errors.Wrap(err, `invalid key encryption algorithm`)
+	}
+	return keyenc, nil
+}
+
 func makeJweEncryptCmd() *cli.Command {
 	var cmd cli.Command
 	cmd.Name = "encrypt"
@@ -45,15 +64,19 @@ func makeJweEncryptCmd() *cli.Command {
    Encrypt contents of FILE and generate a JWE message using
    the specified algorithms and key.
    Use "-" as FILE to read from STDIN.
+
+   If the key file contains a JWK set, the payload is encrypted for
+   each key in the set. Multiple recipients require JSON serialization.
+   If "--key-encryption" is omitted, the "alg" field of each key is used.
 `
 	cmd.Aliases = []string{"enc"}
 	cmd.Flags = []cli.Flag{
 		keyFlag("encrypt"),
 		keyFormatFlag(),
-		keyEncryptionFlag(true),
+		keyEncryptionFlag(false),
 		&cli.StringFlag{
 			Name:     "content-encryption",
-			Aliases:  []string{"C"},
+			Aliases:  []string{"C", "enc"},
 			Usage:    "Content encryption algorithm name `NAME` (e.g. A128CBC-HS256, A192GCM, A256GCM, etc)",
 			Required: true,
 		},
@@ -62,6 +85,7 @@ func makeJweEncryptCmd() *cli.Command {
 			Aliases: []string{"z"},
 			Usage:   "Enable compression",
 		},
+		serializationFlag(),
 		outputFlag(),
 	}
 	cmd.Action = func(c *cli.Context) error {
@@ -76,11 +100,6 @@ func makeJweEncryptCmd() *cli.Command {
 			return errors.Wrap(err, `failed to read data from source`)
 		}
 
-		var keyenc jwa.KeyEncryptionAlgorithm
-		if err := keyenc.Accept(c.String("key-encryption")); err != nil {
-			return errors.Wrap(err, `invalid key encryption algorithm`)
-		}
-
 		var cntenc jwa.ContentEncryptionAlgorithm
 		if err := cntenc.Accept(c.String("content-encryption")); err != nil {
 			return errors.Wrap(err, `invalid content encryption algorithm`)
@@ -95,17 +114,43 @@ func makeJweEncryptCmd() *cli.Command {
 		if err != nil {
 			return err
 		}
-		if keyset.Len() != 1 {
-			return errors.New(`jwk file must contain exactly one key`)
+		if keyset.Len() == 0 {
+			return errors.New(`jwk file must contain at least one key`)
 		}
-		key, _ := keyset.Get(0)
 
-		pubkey, err := jwk.PublicKeyOf(key)
-		if err != nil {
-			return errors.Wrapf(err, `failed to retrieve public key of %T`, key)
+		keyencs := make([]jwa.KeyEncryptionAlgorithm, keyset.Len())
+		pubkeys := make([]jwk.Key, keyset.Len())
+		for i := 0; i < keyset.Len(); i++ {
+			key, _ := keyset.Get(i)
+			keyenc, err := keyEncryptionAlgorithm(c.String("key-encryption"), key)
+			if err != nil {
+				return errors.Wrapf(err, `failed to determine key encryption algorithm for key #%d`, i)
+			}
+			keyencs[i] = keyenc
+
+			pubkey, err := jwk.PublicKeyOf(key)
+			if err != nil {
+				return errors.Wrapf(err, `failed to retrieve public key of %T`, key)
+			}
+			pubkeys[i] = pubkey
 		}
 
-		encrypted, err := jwe.Encrypt(buf, keyenc, pubkey, cntenc, compress)
+		var encrypted []byte
+		switch serialization := c.String("serialization"); serialization {
+		case "compact":
+			if keyset.Len() != 1 {
+				return errors.New(`compact serialization requires exactly one key`)
+			}
+			encrypted, err = jwe.Encrypt(buf, keyencs[0], pubkeys[0], cntenc, compress)
+		case "json":
+			options := make([]jwe.EncryptOption, len(pubkeys))
+			for i, pubkey := range pubkeys {
+				options[i] = jwe.WithRecipient(keyencs[i], pubkey)
+			}
+			encrypted, err = jwe.EncryptMulti(buf, cntenc, compress, options...)
+		default:
+			return errors.Errorf(`invalid serialization format %q`, serialization)
+		}
 		if err != nil {
 			return errors.Wrap(err, `failed to encrypt message`)
 		}
@@ -126,6 +171,16 @@ func makeJweDecryptCmd() *cli.Command {
 	var cmd cli.Command
 	cmd.Name = "decrypt"
 	cmd.Aliases = []string{"dec"}
+	cmd.Usage = "Decrypt JWE message"
+	cmd.UsageText = `jwx jwe decrypt [command options] FILE
+
+   Decrypt a JWE message stored in FILE, in either compact or JSON
+   serialization format. Use "-" as FILE to read from STDIN.
+
+   If the key file contains a JWK set, each key is tried in turn.
+   If "--key-encryption" is omitted, the algorithm of each recipient
+   in the message is used.
+`
 	cmd.Flags = []cli.Flag{
 		keyFlag("decrypt"),
 		keyFormatFlag(),
@@ -143,31 +198,22 @@ func makeJweDecryptCmd() *cli.Command {
 		if err != nil {
 			return errors.Wrap(err, `failed to read data from source`)
 		}
+		buf = bytes.TrimSpace(buf)
 
 		keyset, err := getKeyFile(c.String("key"), c.String("key-format"))
 		if err != nil {
 			return err
 		}
-		if keyset.Len() != 1 {
-			return errors.New(`jwk file must contain exactly one key`)
-		}
-		key, _ := keyset.Get(0)
-
-		var decrypted []byte
 
+		var algs []jwa.KeyEncryptionAlgorithm
 		if keyencalg := c.String("key-encryption"); keyencalg != "" {
-			var keyenc jwa.KeyEncryptionAlgorithm
-			if err := keyenc.Accept(c.String("key-encryption")); err != nil {
-				return errors.Wrap(err, `invalid key encryption algorithm`)
-			}
-
 			// if we have an explicit key encryption algorithm, we don't have to
 			// guess it.
-			v, err := jwe.Decrypt(buf, keyenc, key)
-			if err != nil {
-				return errors.Wrap(err, `failed to decrypt message`)
+			var keyenc jwa.KeyEncryptionAlgorithm
+			if err := keyenc.Accept(keyencalg); err != nil {
+				return errors.Wrap(err, `invalid key encryption algorithm`)
 			}
-			decrypted = v
+			algs = append(algs, keyenc)
 		} else {
 			// This is silly, but we go through each recipient, and try the key
 			// with each algorithm
@@ -179,25 +225,33 @@ func makeJweDecryptCmd() *cli.Command {
 			// if we have no recipients, pretend like we only have one
 			recipients := msg.Recipients()
 			if len(recipients) == 0 {
-				r := jwe.NewRecipient()
-				if err := r.SetHeaders(msg.ProtectedHeaders()); err != nil {
-					return errors.Wrap(err, `failed to set headers to recipient`)
+				algs = append(algs, msg.ProtectedHeaders().Algorithm())
+			}
+			for _, recipient := range recipients {
+				alg := recipient.Headers().Algorithm()
+				if alg == "" {
+					alg = msg.ProtectedHeaders().Algorithm()
 				}
-				recipients = append(recipients, r)
+				algs = append(algs, alg)
 			}
+		}
 
-			for _, recipient := range recipients {
-				v, err := msg.Decrypt(recipient.Headers().Algorithm(), key)
+		var decrypted []byte
+	LOOP:
+		for i := 0; i < keyset.Len(); i++ {
+			key, _ := keyset.Get(i)
+			for _, alg := range algs {
+				v, err := jwe.Decrypt(buf, alg, key)
 				if err != nil {
 					continue
 				}
 				decrypted = v
-				break
+				break LOOP
 			}
+		}
 
-			if decrypted == nil {
-				return errors.Errorf(`could not decrypt message`)
-			}
+		if decrypted == nil {
+			return errors.Errorf(`could not decrypt message with any of the keys`)
 		}
 
 		output, err := getOutput(c.String("output"))
@@ -6,7 +6,9 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"io"
 	"io/ioutil"
 
@@ -35,10 +37,51 @@ func jwkOutputFormatFlag() cli.Flag {
 		Name:    "output-format",
 		Aliases: []string{"O"},
 		Value:   "json",
-		Usage:   "Output format `OUTPUT` (json/pem)",
+		Usage:   "Output format `OUTPUT` (json/pem/der)",
 	}
 }
 
+func thumbprintKidFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "thumbprint-kid",
+		Usage: "Assign the JWK thumbprint (RFC 7638) as the key ID if the key does not have one",
+	}
+}
+
+// assignKeyIDs assigns thumbprint based key IDs to each key in the set
+// that does not already have a "kid"
+func assignKeyIDs(keyset jwk.Set) error {
+	for i := 0; i < keyset.Len(); i++ {
+		key, _ := keyset.Get(i)
+		if err := jwk.AssignKeyID(key); err != nil {
+			return errors.Wrapf(err, `failed to assign key ID for key #%d`, i)
+		}
+	}
+	return nil
+}
+
+// parseDER parses a single ASN.1 DER encoded key. Like PEM, it tries
+// its best to determine the key type
+func parseDER(src []byte) (jwk.Key, error) {
+	var rawkey interface{}
+	if v, err := x509.ParsePKCS8PrivateKey(src); err == nil {
+		rawkey = v
+	} else if v, err := x509.ParsePKIXPublicKey(src); err == nil {
+		rawkey = v
+	} else if v, err := x509.ParsePKCS1PrivateKey(src); err == nil {
+		rawkey = v
+	} else if v, err := x509.ParseECPrivateKey(src); err == nil {
+		rawkey = v
+	} else if v, err := x509.ParsePKCS1PublicKey(src); err == nil {
+		rawkey = v
+	} else if v, err := x509.ParseCertificate(src); err == nil {
+		rawkey = v.PublicKey
+	} else {
+		return nil, errors.New(`failed to parse DER encoded key: unknown format`)
+	}
+	return jwk.New(rawkey)
+}
+
 func publicKeyFlag() cli.Flag {
 	return &cli.BoolFlag{
 		Name:    "public-key",
@@ -71,6 +114,24 @@ func dumpJWKSet(dst io.Writer, keyset jwk.Set, format string, preserve bool) err
 		return nil
 	}
 
+	if format == "der" {
+		if keyset.Len() != 1 {
+			return errors.Errorf(`DER format can only hold a single key (got %d)`, keyset.Len())
+		}
+		buf, err := jwk.Pem(keyset)
+		if err != nil {
+			return errors.Wrap(err, `failed to format key in DER format`)
+		}
+		block, _ := pem.Decode(buf)
+		if block == nil {
+			return errors.New(`failed to decode PEM block`)
+		}
+		if _, err := dst.Write(block.Bytes); err != nil {
+			return errors.Wrap(err, `failed to write to destination`)
+		}
+		return nil
+	}
+
 	if format == "json" {
 		if preserve || keyset.Len() != 1 {
 			if err := dumpJSON(dst, keyset); err != nil {
@@ -117,6 +178,12 @@ func makeJwkGenerateCmd() *cli.Command {
 			Name:  "template",
 			Usage: `Extra values in the JWK as JSON object`,
 		},
+		&cli.StringFlag{
+			Name:    "use",
+			Aliases: []string{"u"},
+			Usage:   "Intended use `USE` of the key (sig/enc)",
+		},
+		thumbprintKidFlag(),
 		&cli.IntFlag{
 			Name:    "keysize",
 			Aliases: []string{"s"},
@@ -203,9 +270,21 @@ func makeJwkGenerateCmd() *cli.Command {
 			}
 		}
 
+		if use := c.String("use"); use != "" {
+			if err := key.Set(jwk.KeyUsageKey, use); err != nil {
+				return errors.Wrap(err, `failed to set key usage`)
+			}
+		}
+
 		keyset := jwk.NewSet()
 		keyset.Add(key)
 
+		if c.Bool("thumbprint-kid") {
+			if err := assignKeyIDs(keyset); err != nil {
+				return err
+			}
+		}
+
 		if c.Bool("public-key") {
 			pubks, err := jwk.PublicSetOf(keyset)
 			if err != nil {
@@ -228,17 +307,18 @@ func makeJwkGenerateCmd() *cli.Command {
 func makeJwkFormatCmd() *cli.Command {
 	var cmd cli.Command
 	cmd.Name = "format"
-	cmd.Aliases = []string{"fmt"}
-	cmd.Usage = "Format JWK"
+	cmd.Aliases = []string{"fmt", "convert"}
+	cmd.Usage = "Format JWK, or convert keys between JWK, PEM and DER formats"
 	cmd.Flags = []cli.Flag{
 		publicKeyFlag(),
 		&cli.StringFlag{
 			Name:    "input-format",
 			Aliases: []string{"I"},
 			Value:   "json",
-			Usage:   "Input format `INPUT` (json/pem)",
+			Usage:   "Input format `INPUT` (json/pem/der)",
 		},
 		jwkOutputFormatFlag(),
+		thumbprintKidFlag(),
 		jwkSetFlag(),
 		outputFlag(),
 	}
@@ -260,18 +340,28 @@ func makeJwkFormatCmd() *cli.Command {
 			return errors.Wrap(err, `failed to read data from source`)
 		}
 
-		var options []jwk.ParseOption
+		var keyset jwk.Set
 		switch format := c.String("input-format"); format {
-		case "json":
-		case "pem":
-			options = append(options, jwk.WithPEM(true))
+		case "json", "pem":
+			keyset, err = jwk.Parse(buf, jwk.WithPEM(format == "pem"))
+			if err != nil {
+				return errors.Wrap(err, `failed to parse keyset`)
+			}
+		case "der":
+			key, err := parseDER(buf)
+			if err != nil {
+				return err
+			}
+			keyset = jwk.NewSet()
+			keyset.Add(key)
 		default:
 			return errors.Errorf(`invalid input format %s`, format)
 		}
 
-		keyset, err := jwk.Parse(buf, options...)
-		if err != nil {
-			return errors.Wrap(err, `failed to parse keyset`)
+		if c.Bool("thumbprint-kid") {
+			if err := assignKeyIDs(keyset); err != nil {
+				return err
+			}
 		}
 
 		output, err := getOutput(c.String("output"))
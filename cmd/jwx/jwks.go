@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	topLevelCommands = append(topLevelCommands, makeJwksCmd())
+}
+
+func minRefreshIntervalFlag() cli.Flag {
+	return &cli.DurationFlag{
+		Name:  "min-refresh-interval",
+		Usage: "minimum `DURATION` between refreshes, used when Cache-Control/Expires headers are absent or shorter",
+		Value: time.Hour,
+	}
+}
+
+func makeJwksCmd() *cli.Command {
+	var cmd cli.Command
+	cmd.Name = "jwks"
+	cmd.Usage = "Work with remote JWK sets"
+
+	cmd.Subcommands = []*cli.Command{
+		makeJwksFetchCmd(),
+		makeJwksWatchCmd(),
+	}
+	return &cmd
+}
+
+func makeJwksFetchCmd() *cli.Command {
+	var cmd cli.Command
+	cmd.Name = "fetch"
+	cmd.Usage = "Fetch a remote JWK set"
+	cmd.UsageText = `jwx jwks fetch [command options] URL
+
+   Fetch the JWK set at URL and display it. The time at which the JWK set
+   would next be refreshed by jwk.AutoRefresh, as derived from the
+   Cache-Control and Expires response headers, is reported to STDERR.
+`
+	cmd.Flags = []cli.Flag{
+		minRefreshIntervalFlag(),
+		outputFlag(),
+	}
+
+	// jwx jwks fetch <url>
+	cmd.Action = func(c *cli.Context) error {
+		u := c.Args().Get(0)
+		if u == "" {
+			cli.ShowCommandHelpAndExit(c, "fetch", 1)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(u, jwk.WithMinRefreshInterval(c.Duration("min-refresh-interval")))
+
+		keyset, err := ar.Fetch(ctx, u)
+		if err != nil {
+			return errors.Wrapf(err, `failed to fetch JWK set from %s`, u)
+		}
+
+		output, err := getOutput(c.String("output"))
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+
+		if err := dumpJSON(output, keyset); err != nil {
+			return errors.Wrap(err, `failed to marshal JWK set`)
+		}
+		fmt.Fprintf(output, "\n")
+
+		for snapshot := range ar.Snapshot() {
+			fmt.Fprintf(os.Stderr, "Next refresh: %s\n", describeRefresh(snapshot.NextRefresh, time.Now()))
+		}
+		return nil
+	}
+	return &cmd
+}
+
+func makeJwksWatchCmd() *cli.Command {
+	var cmd cli.Command
+	cmd.Name = "watch"
+	cmd.Usage = "Watch a remote JWK set for changes"
+	cmd.UsageText = `jwx jwks watch [command options] URL
+
+   Fetch the JWK set at URL using jwk.AutoRefresh, and keep watching it
+   until interrupted. Each time the JWK set is refreshed, the keys that
+   were added, removed, or changed since the previous version are
+   reported, along with the time of the next refresh.
+
+   Keys are identified by their key ID ("kid"), or by their thumbprint
+   if they do not have one.
+`
+	cmd.Flags = []cli.Flag{
+		minRefreshIntervalFlag(),
+		&cli.DurationFlag{
+			Name:  "refresh-interval",
+			Usage: "refresh every `DURATION`, ignoring Cache-Control/Expires headers",
+		},
+		outputFlag(),
+	}
+
+	// jwx jwks watch <url>
+	cmd.Action = func(c *cli.Context) error {
+		u := c.Args().Get(0)
+		if u == "" {
+			cli.ShowCommandHelpAndExit(c, "watch", 1)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt)
+		defer signal.Stop(sigCh)
+		go func() {
+			select {
+			case <-sigCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		options := []jwk.AutoRefreshOption{jwk.WithMinRefreshInterval(c.Duration("min-refresh-interval"))}
+		if v := c.Duration("refresh-interval"); v > 0 {
+			options = append(options, jwk.WithRefreshInterval(v))
+		}
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(u, options...)
+
+		keyset, err := ar.Fetch(ctx, u)
+		if err != nil {
+			return errors.Wrapf(err, `failed to fetch JWK set from %s`, u)
+		}
+
+		output, err := getOutput(c.String("output"))
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+
+		prev, err := indexKeySet(keyset)
+		if err != nil {
+			return err
+		}
+
+		var last jwk.TargetSnapshot
+		for snapshot := range ar.Snapshot() {
+			last = snapshot
+		}
+
+		now := time.Now()
+		fmt.Fprintf(output, "[%s] fetched %s (%d keys), next refresh: %s\n", now.Format(time.RFC3339), u, keyset.Len(), describeRefresh(last.NextRefresh, now))
+		if err := dumpJSON(output, keyset); err != nil {
+			return errors.Wrap(err, `failed to marshal JWK set`)
+		}
+		fmt.Fprintf(output, "\n")
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			var snapshot jwk.TargetSnapshot
+			for v := range ar.Snapshot() {
+				snapshot = v
+			}
+
+			now := time.Now()
+			if snapshot.LastError != nil && snapshot.LastError != last.LastError {
+				fmt.Fprintf(output, "[%s] refresh failed: %s\n", now.Format(time.RFC3339), snapshot.LastError)
+			}
+
+			if !snapshot.LastRefresh.Equal(last.LastRefresh) {
+				keyset, err := ar.Fetch(ctx, u)
+				if err != nil {
+					return errors.Wrapf(err, `failed to fetch JWK set from %s`, u)
+				}
+
+				cur, err := indexKeySet(keyset)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(output, "[%s] refreshed %s (%d keys), next refresh: %s\n", now.Format(time.RFC3339), u, keyset.Len(), describeRefresh(snapshot.NextRefresh, now))
+				if !dumpKeySetDiff(output, prev, cur) {
+					fmt.Fprintf(output, "  no changes\n")
+				}
+				prev = cur
+			}
+			last = snapshot
+		}
+	}
+	return &cmd
+}
+
+func describeRefresh(t, now time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s (in %s)", t.Format(time.RFC3339), t.Sub(now).Round(time.Second))
+}
+
+// indexKeySet maps each key in the set to its JSON representation,
+// keyed by the key ID, or the thumbprint of the key if it has no key ID
+func indexKeySet(keyset jwk.Set) (map[string][]byte, error) {
+	index := make(map[string][]byte)
+	for i := 0; i < keyset.Len(); i++ {
+		key, _ := keyset.Get(i)
+
+		id := key.KeyID()
+		if id == "" {
+			thumbprint, err := key.Thumbprint(crypto.SHA256)
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to compute thumbprint for key #%d`, i)
+			}
+			id = "thumbprint:" + base64.EncodeToString(thumbprint)
+		}
+
+		buf, err := json.Marshal(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to marshal key #%d`, i)
+		}
+		index[id] = buf
+	}
+	return index, nil
+}
+
+// dumpKeySetDiff writes the keys that were added ("+"), removed ("-"),
+// or changed ("~") between two versions of a JWK set. Returns false
+// if there were no differences
+func dumpKeySetDiff(dst io.Writer, prev, cur map[string][]byte) bool {
+	ids := make([]string, 0, len(prev)+len(cur))
+	for id := range prev {
+		ids = append(ids, id)
+	}
+	for id := range cur {
+		if _, ok := prev[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var changed bool
+	for _, id := range ids {
+		before, inPrev := prev[id]
+		after, inCur := cur[id]
+		switch {
+		case !inPrev:
+			fmt.Fprintf(dst, "  + %s %s\n", id, after)
+		case !inCur:
+			fmt.Fprintf(dst, "  - %s %s\n", id, before)
+		case !bytes.Equal(before, after):
+			fmt.Fprintf(dst, "  ~ %s %s\n", id, after)
+		default:
+			continue
+		}
+		changed = true
+	}
+	return changed
+}
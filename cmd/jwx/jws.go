@@ -148,6 +148,8 @@ func makeJwsVerifyCmd() *cli.Command {
    use to verify the signature. This is because we can not safely rely
    on the "alg" field of the JWS message to deduce which key to use.
    See https://auth0.com/blog/critical-vulnerabilities-in-json-web-token-libraries/
+   If "--alg" is omitted, the "alg" field of each key is used instead,
+   and keys without an "alg" field are skipped.
 
    The alternative is to match a key based on explicitly specified
    key ID ("kid"). In this case the following conditions must be met
@@ -177,6 +179,10 @@ func makeJwsVerifyCmd() *cli.Command {
 			Value: false,
 			Usage: "instead of using alg, attempt to verify only if the key ID (kid) matches",
 		},
+		&cli.StringFlag{
+			Name:  "payload",
+			Usage: "`FILE` containing the detached payload, if the message was created with --detached",
+		},
 		outputFlag(),
 	}
 
@@ -201,8 +207,17 @@ func makeJwsVerifyCmd() *cli.Command {
 		buf, err := ioutil.ReadAll(src)
 		if err != nil {
 			return errors.Wrap(err, `failed to read data from source`)
+		}
+		buf = bytes.TrimSpace(buf)
+
+		if payloadFile := c.String("payload"); payloadFile != "" {
+			payload, err := ioutil.ReadFile(payloadFile)
+			if err != nil {
+				return errors.Wrapf(err, `failed to read payload from %s`, payloadFile)
+			}
+			buf, err = attachPayload(buf, payload)
 			if err != nil {
-				return errors.Wrap(err, `failed to verify message`)
+				return err
 			}
 		}
 
@@ -220,20 +235,26 @@ func makeJwsVerifyCmd() *cli.Command {
 			}
 		} else {
 			var alg jwa.SignatureAlgorithm
-			givenalg := c.String("alg")
-			if givenalg == "" {
-				return errors.New(`option --alg must be given`)
-			}
-
-			if err := alg.Accept(givenalg); err != nil {
-				return errors.Errorf(`invalid alg %s`, givenalg)
+			if givenalg := c.String("alg"); givenalg != "" {
+				if err := alg.Accept(givenalg); err != nil {
+					return errors.Errorf(`invalid alg %s`, givenalg)
+				}
 			}
 
 			ctx := context.Background()
 			for iter := keyset.Iterate(ctx); iter.Next(ctx); {
 				pair := iter.Pair()
 				key := pair.Value.(jwk.Key)
-				payload, err := jws.Verify(buf, alg, key)
+
+				keyalg := alg
+				if keyalg == "" {
+					if key.Algorithm() == "" {
+						continue
+					}
+					keyalg = jwa.SignatureAlgorithm(key.Algorithm())
+				}
+
+				payload, err := jws.Verify(buf, keyalg, key)
 				if err == nil {
 					fmt.Fprintf(output, "%s", payload)
 					return nil
@@ -250,12 +271,19 @@ func makeJwsSignCmd() *cli.Command {
 	var cmd cli.Command
 	cmd.Name = "sign"
 	cmd.Aliases = []string{"sig"}
-	cmd.Usage = "Verify JWS mesage"
+	cmd.Usage = "Sign JWS mesage"
 	cmd.UsageText = `jwx jws sign [command options] FILE
 
-   Signs the payload in FILE and generates a JWS message in compact format.
+   Signs the payload in FILE and generates a JWS message in compact
+   (default) or JSON serialization format.
    Use "-" as FILE to read from STDIN.
 
+   If "--alg" is omitted, the "alg" field of the key is used.
+
+   With "--detached", the payload is omitted from the generated message
+   (RFC7515 Appendix F). Pass the payload to "jwx jws verify --payload"
+   to verify such messages.
+
    Currently only single key signature mode is supported.
 `
 	cmd.Flags = []cli.Flag{
@@ -266,10 +294,15 @@ func makeJwsSignCmd() *cli.Command {
 			Name:  "header",
 			Usage: "header object to inject into JWS message protected header",
 		},
+		serializationFlag(),
+		&cli.BoolFlag{
+			Name:  "detached",
+			Usage: "omit the payload from the generated message",
+		},
 		outputFlag(),
 	}
 
-	// jwx jws verify <file>
+	// jwx jws sign <file>
 	cmd.Action = func(c *cli.Context) error {
 		keyset, err := getKeyFile(c.String("key"), c.String("key-format"))
 		if err != nil {
@@ -290,35 +323,49 @@ func makeJwsSignCmd() *cli.Command {
 		buf, err := ioutil.ReadAll(src)
 		if err != nil {
 			return errors.Wrap(err, `failed to read data from source`)
-			if err != nil {
-				return errors.Wrap(err, `failed to sign message`)
-			}
 		}
 
 		var alg jwa.SignatureAlgorithm
 		givenalg := c.String("alg")
 		if givenalg == "" {
-			return errors.New(`option --alg must be given`)
+			givenalg = key.Algorithm()
+		}
+		if givenalg == "" {
+			return errors.New(`option --alg must be given if the key does not have an "alg" field`)
 		}
 
 		if err := alg.Accept(givenalg); err != nil {
 			return errors.Errorf(`invalid alg %s`, givenalg)
 		}
 
-		var options []jws.SignOption
+		h := jws.NewHeaders()
 		if hdrbuf := c.String("header"); hdrbuf != "" {
-			h := jws.NewHeaders()
 			if err := json.Unmarshal([]byte(hdrbuf), h); err != nil {
 				return errors.Wrap(err, `failed to parse header`)
 			}
-			options = append(options, jws.WithHeaders(h))
 		}
 
-		signed, err := jws.Sign(buf, alg, key, options...)
+		serialization := c.String("serialization")
+		var signed []byte
+		switch serialization {
+		case "compact":
+			signed, err = jws.Sign(buf, alg, key, jws.WithHeaders(h))
+		case "json":
+			signed, err = signJSON(buf, alg, key, h)
+		default:
+			return errors.Errorf(`invalid serialization format %q`, serialization)
+		}
 		if err != nil {
 			return errors.Wrap(err, `failed to sign payload`)
 		}
 
+		if c.Bool("detached") {
+			signed, err = detachPayload(signed)
+			if err != nil {
+				return err
+			}
+		}
+
 		output, err := getOutput(c.String("output"))
 		if err != nil {
 			return err
@@ -330,3 +377,73 @@ func makeJwsSignCmd() *cli.Command {
 	}
 	return &cmd
 }
+
+// signJSON signs the payload and generates a JWS message in JSON
+// serialization format. As with jws.Sign, the key ID of the key is
+// added to the protected header.
+func signJSON(payload []byte, alg jwa.SignatureAlgorithm, key jwk.Key, protected jws.Headers) ([]byte, error) {
+	signer, err := jws.NewSigner(alg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create signer`)
+	}
+
+	if kid := key.KeyID(); kid != "" {
+		if _, ok := protected.Get(jws.KeyIDKey); !ok {
+			if err := protected.Set(jws.KeyIDKey, kid); err != nil {
+				return nil, errors.Wrap(err, `failed to set "kid" header`)
+			}
+		}
+	}
+
+	return jws.SignMulti(payload, jws.WithSigner(signer, key, nil, protected))
+}
+
+// detachPayload removes the payload from a JWS message, as described
+// in RFC7515 Appendix F
+func detachPayload(signed []byte) ([]byte, error) {
+	if len(signed) > 0 && signed[0] == '{' {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(signed, &m); err != nil {
+			return nil, errors.Wrap(err, `failed to unmarshal message`)
+		}
+		delete(m, "payload")
+		return json.Marshal(m)
+	}
+
+	parts := bytes.Split(signed, []byte{'.'})
+	if len(parts) != 3 {
+		return nil, errors.New(`invalid compact serialization`)
+	}
+	parts[1] = nil
+	return bytes.Join(parts, []byte{'.'}), nil
+}
+
+// attachPayload puts back a detached payload into a JWS message
+func attachPayload(buf, payload []byte) ([]byte, error) {
+	encoded := base64.Encode(payload)
+	if len(buf) > 0 && buf[0] == '{' {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(buf, &m); err != nil {
+			return nil, errors.Wrap(err, `failed to unmarshal message`)
+		}
+		if _, ok := m["payload"]; ok {
+			return nil, errors.New(`message already contains a payload`)
+		}
+		v, err := json.Marshal(string(encoded))
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to encode payload`)
+		}
+		m["payload"] = v
+		return json.Marshal(m)
+	}
+
+	parts := bytes.Split(buf, []byte{'.'})
+	if len(parts) != 3 {
+		return nil, errors.New(`invalid compact serialization`)
+	}
+	if len(parts[1]) > 0 {
+		return nil, errors.New(`message already contains a payload`)
+	}
+	parts[1] = encoded
+	return bytes.Join(parts, []byte{'.'}), nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	topLevelCommands = append(topLevelCommands, makeJwtCmd())
+}
+
+func makeJwtCmd() *cli.Command {
+	var cmd cli.Command
+	cmd.Name = "jwt"
+	cmd.Usage = "Work with JWT tokens"
+
+	cmd.Subcommands = []*cli.Command{
+		makeJwtParseCmd(),
+		makeJwtValidateCmd(),
+	}
+	return &cmd
+}
+
+func readToken(filename string) ([]byte, error) {
+	src, err := getSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	buf, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to read data from source`)
+	}
+
+	buf = bytes.TrimSpace(buf)
+	if len(buf) == 0 {
+		return nil, errors.New(`empty buffer`)
+	}
+	return buf, nil
+}
+
+func makeJwtParseCmd() *cli.Command {
+	var cmd cli.Command
+	cmd.Name = "parse"
+	cmd.Aliases = []string{"inspect"}
+	cmd.Usage = "Parse JWT token"
+	cmd.UsageText = `jwx jwt parse [command options] FILE
+
+   Parse FILE and display the header and claims of a signed JWT,
+   along with the "iat", "nbf" and "exp" claims in human readable form.
+   Use "-" as FILE to read from STDIN.
+
+   The signature is NOT verified. Use "jwx jwt validate" for that.
+`
+	cmd.Flags = []cli.Flag{
+		outputFlag(),
+	}
+
+	// jwx jwt parse <file>
+	cmd.Action = func(c *cli.Context) error {
+		buf, err := readToken(c.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		msg, err := jws.Parse(buf)
+		if err != nil {
+			return errors.Wrap(err, `failed to parse JWT as a JWS message`)
+		}
+
+		signatures := msg.Signatures()
+		if len(signatures) == 0 {
+			return errors.New(`token does not contain any signatures`)
+		}
+
+		var claims map[string]interface{}
+		if err := json.Unmarshal(msg.Payload(), &claims); err != nil {
+			return errors.Wrap(err, `failed to decode claims`)
+		}
+
+		output, err := getOutput(c.String("output"))
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+
+		fmt.Fprintf(output, "Header:\n")
+		if err := dumpJSON(output, signatures[0].ProtectedHeaders()); err != nil {
+			return errors.Wrap(err, `failed to marshal header`)
+		}
+		fmt.Fprintf(output, "\n\nClaims:\n")
+		if err := dumpJSON(output, claims); err != nil {
+			return errors.Wrap(err, `failed to marshal claims`)
+		}
+		fmt.Fprintf(output, "\n")
+
+		var wroteTimes bool
+		now := time.Now()
+		for _, field := range []struct {
+			Name  string
+			Label string
+		}{
+			{Name: jwt.IssuedAtKey, Label: "Issued At: "},
+			{Name: jwt.NotBeforeKey, Label: "Not Before:"},
+			{Name: jwt.ExpirationKey, Label: "Expires At:"},
+		} {
+			v, ok := claims[field.Name].(float64)
+			if !ok {
+				continue
+			}
+			if !wroteTimes {
+				fmt.Fprintf(output, "\n")
+				wroteTimes = true
+			}
+			dumpNumericDate(output, field.Label, field.Name == jwt.ExpirationKey, v, now)
+		}
+		return nil
+	}
+	return &cmd
+}
+
+// dumpNumericDate writes a NumericDate claim in RFC3339 format, along with
+// its distance from now
+func dumpNumericDate(dst io.Writer, label string, expiration bool, v float64, now time.Time) {
+	sec, frac := math.Modf(v)
+	t := time.Unix(int64(sec), int64(frac*1e9)).UTC()
+
+	var relative string
+	if d := t.Sub(now).Round(time.Second); d > 0 {
+		relative = "in " + d.String()
+	} else {
+		relative = (-d).String() + " ago"
+		if expiration {
+			relative = "expired " + relative
+		}
+	}
+	fmt.Fprintf(dst, "%s %s (%s)\n", label, t.Format(time.RFC3339), relative)
+}
+
+func makeJwtValidateCmd() *cli.Command {
+	var cmd cli.Command
+	cmd.Name = "validate"
+	cmd.Aliases = []string{"verify"}
+	cmd.Usage = "Verify and validate JWT tokens"
+	cmd.UsageText = `jwx jwt validate [command options] FILE
+
+   Verifies the signature of the JWT in FILE, and validates its claims.
+   Use "-" as FILE to read from STDIN. On success, the claims are displayed.
+
+   Keys may be specified using "--key", "--jwks-url", or both.
+
+   If "--alg" is omitted, the key is chosen by matching the key ID ("kid")
+   of the token against the keys, and the key must contain the "alg" field.
+   If there is only one key, it is used when the token does not specify
+   a key ID.
+
+   The "exp", "nbf" and "iat" claims are always validated. Use "--iss",
+   "--aud" and "--sub" to validate the respective claims as well.
+`
+	cmd.Flags = []cli.Flag{
+		jwsAlgorithmFlag("verify"),
+		&cli.StringFlag{
+			Name:    "key",
+			Aliases: []string{"k"},
+			Usage:   "`FILE` containing the key to verify with",
+		},
+		keyFormatFlag(),
+		&cli.StringFlag{
+			Name:  "jwks-url",
+			Usage: "`URL` of the JWK set to verify with",
+		},
+		&cli.StringFlag{
+			Name:  "iss",
+			Usage: "expected issuer `ISSUER`",
+		},
+		&cli.StringFlag{
+			Name:  "aud",
+			Usage: "expected audience `AUDIENCE`",
+		},
+		&cli.StringFlag{
+			Name:  "sub",
+			Usage: "expected subject `SUBJECT`",
+		},
+		&cli.DurationFlag{
+			Name:  "skew",
+			Usage: "acceptable clock skew `DURATION` when validating time based claims",
+		},
+		outputFlag(),
+	}
+
+	// jwx jwt validate <file>
+	cmd.Action = func(c *cli.Context) error {
+		keyset := jwk.NewSet()
+		if keyfile := c.String("key"); keyfile != "" {
+			v, err := getKeyFile(keyfile, c.String("key-format"))
+			if err != nil {
+				return err
+			}
+			v, err = jwk.PublicSetOf(v)
+			if err != nil {
+				return errors.Wrap(err, `failed to retrieve public key`)
+			}
+			addKeys(keyset, v)
+		}
+		if u := c.String("jwks-url"); u != "" {
+			v, err := jwk.Fetch(context.Background(), u)
+			if err != nil {
+				return errors.Wrapf(err, `failed to fetch JWK set from %s`, u)
+			}
+			addKeys(keyset, v)
+		}
+		if keyset.Len() == 0 {
+			return errors.New(`either --key or --jwks-url must be specified`)
+		}
+
+		buf, err := readToken(c.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		var token jwt.Token
+		if givenalg := c.String("alg"); givenalg != "" {
+			var alg jwa.SignatureAlgorithm
+			if err := alg.Accept(givenalg); err != nil {
+				return errors.Errorf(`invalid alg %s`, givenalg)
+			}
+
+			for i := 0; i < keyset.Len(); i++ {
+				key, _ := keyset.Get(i)
+				v, err := jwt.Parse(buf, jwt.WithVerify(alg, key))
+				if err == nil {
+					token = v
+					break
+				}
+			}
+			if token == nil {
+				return errors.New(`could not verify with any of the keys`)
+			}
+		} else {
+			token, err = jwt.Parse(buf, jwt.WithKeySet(keyset), jwt.UseDefaultKey(true))
+			if err != nil {
+				return errors.Wrap(err, `failed to verify token`)
+			}
+		}
+
+		var options []jwt.ValidateOption
+		if v := c.String("iss"); v != "" {
+			options = append(options, jwt.WithIssuer(v))
+		}
+		if v := c.String("aud"); v != "" {
+			options = append(options, jwt.WithAudience(v))
+		}
+		if v := c.String("sub"); v != "" {
+			options = append(options, jwt.WithSubject(v))
+		}
+		if v := c.Duration("skew"); v > 0 {
+			options = append(options, jwt.WithAcceptableSkew(v))
+		}
+
+		if err := jwt.Validate(token, options...); err != nil {
+			return errors.Wrap(err, `token validation failed`)
+		}
+
+		output, err := getOutput(c.String("output"))
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+
+		if err := dumpJSON(output, token); err != nil {
+			return errors.Wrap(err, `failed to marshal claims`)
+		}
+		fmt.Fprintf(output, "\n")
+		return nil
+	}
+	return &cmd
+}
+
+func addKeys(dst, src jwk.Set) {
+	for i := 0; i < src.Len(); i++ {
+		key, _ := src.Get(i)
+		dst.Add(key)
+	}
+}
@@ -50,6 +50,15 @@ func keyFormatFlag() cli.Flag {
 	}
 }
 
+func serializationFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "serialization",
+		Aliases: []string{"s"},
+		Usage:   "serialization `FORMAT` of the generated message: compact or json",
+		Value:   "compact",
+	}
+}
+
 func main() {
 	var app cli.App
 	app.Commands = topLevelCommands
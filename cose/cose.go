@@ -0,0 +1,98 @@
+// Package cose bridges JOSE and COSE (RFC8152/RFC9052): it converts
+// jwk.Key objects to and from COSE_Key structures, jwt.Token claims to
+// and from CWT claims (RFC8392), and creates and verifies COSE_Sign1
+// messages using the same algorithm implementations as package jws.
+//
+// This allows a single key set to be used for both JOSE and COSE, e.g.
+// when the same service issues JWTs to web clients and CWTs to
+// constrained devices.
+package cose
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// Common COSE header parameter labels (RFC9052, section 3.1)
+const (
+	HeaderAlgorithm   = 1
+	HeaderCritical    = 2
+	HeaderContentType = 3
+	HeaderKeyID       = 4
+)
+
+// CBOR tags used by this package
+const (
+	TagSign1 = 18
+	TagCWT   = 61
+)
+
+// COSE algorithm identifiers, keyed by their JOSE name. Only algorithms
+// whose semantics are identical in JOSE and COSE are listed. Notably
+// the ECDH-ES variants are absent, as JOSE uses the Concat KDF while
+// COSE uses HKDF.
+var algorithms = map[string]int64{
+	jwa.ES256.String():        -7,
+	jwa.EdDSA.String():        -8,
+	jwa.ES384.String():        -35,
+	jwa.ES512.String():        -36,
+	jwa.PS256.String():        -37,
+	jwa.PS384.String():        -38,
+	jwa.PS512.String():        -39,
+	jwa.ES256K.String():       -47,
+	jwa.RS256.String():        -257,
+	jwa.RS384.String():        -258,
+	jwa.RS512.String():        -259,
+	jwa.HS256.String():        5,
+	jwa.HS384.String():        6,
+	jwa.HS512.String():        7,
+	jwa.A128KW.String():       -3,
+	jwa.A192KW.String():       -4,
+	jwa.A256KW.String():       -5,
+	jwa.DIRECT.String():       -6,
+	jwa.RSA_OAEP.String():     -40,
+	jwa.RSA_OAEP_256.String(): -41,
+	jwa.A128GCM.String():      1,
+	jwa.A192GCM.String():      2,
+	jwa.A256GCM.String():      3,
+}
+
+var algorithmNames = make(map[int64]string)
+
+func init() {
+	for name, id := range algorithms {
+		algorithmNames[id] = name
+	}
+}
+
+// Algorithm returns the COSE algorithm identifier corresponding to
+// the given JOSE algorithm name (e.g. "ES256" -> -7)
+func Algorithm(name string) (int64, error) {
+	id, ok := algorithms[name]
+	if !ok {
+		return 0, errors.Errorf(`algorithm %q has no COSE equivalent`, name)
+	}
+	return id, nil
+}
+
+// AlgorithmName returns the JOSE algorithm name corresponding to the
+// given COSE algorithm identifier (e.g. -7 -> "ES256")
+func AlgorithmName(id int64) (string, error) {
+	name, ok := algorithmNames[id]
+	if !ok {
+		return "", errors.Errorf(`COSE algorithm %d has no JOSE equivalent`, id)
+	}
+	return name, nil
+}
+
+// toInt64 converts integers decoded from CBOR
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,366 @@
+package cose_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/cose"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC8392 Appendix A.2.3
+const rfc8392Key = `a72358206c1382765aec5358f117733d281c1c7bdc39884d04a45a1e6c67c858bc206c1922582060f7f1a780d8a783bfb7a2dd6b2796e8128dbbcef9d3d168db9529971a36e7b9215820143329cce7868e416927599cf65a34f3ce2ffda55a7eca69ed8919a394d42f0f2001010202524173796d6d657472696345434453413235360326`
+
+// RFC8392 Appendix A.3
+const rfc8392SignedCWT = `d28443a10126a104524173796d6d657472696345434453413235365850a70175636f61703a2f2f61732e6578616d706c652e636f6d02656572696b77037818636f61703a2f2f6c696768742e6578616d706c652e636f6d041a5612aeb0051a5610d9f0061a5610d9f007420b7158405427c1ff28d23fbad1f29c4c7c6a555e601d6fa29f9179bc3d7438bacaca5acd08c8d4d4f96131680c429a01f85951ecee743a52b9b63632c57209120e1c9e30`
+
+func mustHex(s string) []byte {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RFC8392", func(t *testing.T) {
+		t.Parallel()
+		key, err := cose.ParseKey(mustHex(rfc8392Key))
+		if !assert.NoError(t, err, `cose.ParseKey should succeed`) {
+			return
+		}
+		if !assert.Equal(t, jwa.EC, key.KeyType(), `kty should be EC`) {
+			return
+		}
+		if !assert.Equal(t, `AsymmetricECDSA256`, key.KeyID(), `kid should match`) {
+			return
+		}
+		if !assert.Equal(t, jwa.ES256.String(), key.Algorithm(), `alg should match`) {
+			return
+		}
+		if !assert.Equal(t, jwa.P256, key.(jwk.ECDSAPrivateKey).Crv(), `crv should match`) { //nolint:forcetypeassert
+			return
+		}
+
+		encoded, err := cose.MarshalKey(key)
+		if !assert.NoError(t, err, `cose.MarshalKey should succeed`) {
+			return
+		}
+		// the example in the RFC does not use the deterministic encoding,
+		// so compare the decoded keys
+		decoded, err := cose.ParseKey(encoded)
+		if !assert.NoError(t, err, `cose.ParseKey should succeed`) {
+			return
+		}
+		expected, _ := json.Marshal(key)
+		actual, _ := json.Marshal(decoded)
+		if !assert.JSONEq(t, string(expected), string(actual), `keys should match`) {
+			return
+		}
+	})
+
+	rsakey, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	okpkey, err := jwxtest.GenerateEd25519Jwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Jwk should succeed`) {
+		return
+	}
+	x25519key, err := jwxtest.GenerateX25519Jwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateX25519Jwk should succeed`) {
+		return
+	}
+	symkey, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+		return
+	}
+	_ = symkey.Set(jwk.KeyIDKey, `hmac`)
+	_ = symkey.Set(jwk.AlgorithmKey, jwa.HS256)
+	_ = symkey.Set(jwk.KeyOpsKey, jwk.KeyOperationList{jwk.KeyOpSign, jwk.KeyOpVerify})
+
+	rsapub, _ := jwk.PublicKeyOf(rsakey)
+	ecpub, _ := jwk.PublicKeyOf(eckey)
+	keys := map[string]jwk.Key{
+		"RSA private": rsakey,
+		"RSA public":  rsapub,
+		"EC private":  eckey,
+		"EC public":   ecpub,
+		"Ed25519":     okpkey,
+		"X25519":      x25519key,
+		"Symmetric":   symkey,
+	}
+	for name, key := range keys {
+		key := key
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			encoded, err := cose.MarshalKey(key)
+			if !assert.NoError(t, err, `cose.MarshalKey should succeed`) {
+				return
+			}
+			decoded, err := cose.ParseKey(encoded)
+			if !assert.NoError(t, err, `cose.ParseKey should succeed`) {
+				return
+			}
+
+			expected, _ := json.Marshal(key)
+			actual, _ := json.Marshal(decoded)
+			if !assert.JSONEq(t, string(expected), string(actual), `keys should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("MAC key operations", func(t *testing.T) {
+		t.Parallel()
+		encoded, err := cose.MarshalKey(symkey)
+		if !assert.NoError(t, err, `cose.MarshalKey should succeed`) {
+			return
+		}
+		// {1: 4, 2: h'686d6163', 3: 5, 4: [9, 10], -1: h'...'}
+		if !assert.Contains(t, hex.EncodeToString(encoded), `0482090a`, `key_ops should use MAC operations`) {
+			return
+		}
+	})
+
+	t.Run("KeySet", func(t *testing.T) {
+		t.Parallel()
+		set := jwk.NewSet()
+		set.Add(rsapub)
+		set.Add(ecpub)
+		set.Add(symkey)
+
+		encoded, err := cose.MarshalKeySet(set)
+		if !assert.NoError(t, err, `cose.MarshalKeySet should succeed`) {
+			return
+		}
+		decoded, err := cose.ParseKeySet(encoded)
+		if !assert.NoError(t, err, `cose.ParseKeySet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, set.Len(), decoded.Len(), `number of keys should match`) {
+			return
+		}
+		if _, ok := decoded.LookupKeyID(`hmac`); !assert.True(t, ok, `symmetric key should be found`) {
+			return
+		}
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		t.Parallel()
+		for _, input := range []string{
+			`01`,           // not a map
+			`a10163666f6f`, // kty is not an integer
+			`a10109`,       // unknown kty
+			`a1010221`,     // invalid structure
+		} {
+			_, err := cose.ParseKey(mustHex(input))
+			if !assert.Error(t, err, `cose.ParseKey(%s) should fail`, input) {
+				return
+			}
+		}
+	})
+}
+
+func TestSign1(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Lorem ipsum")
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	edkey, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Alg jwa.SignatureAlgorithm
+		Key interface{}
+	}{
+		{Alg: jwa.RS256, Key: rsakey},
+		{Alg: jwa.PS256, Key: rsakey},
+		{Alg: jwa.ES256, Key: eckey},
+		{Alg: jwa.EdDSA, Key: edkey},
+		{Alg: jwa.HS256, Key: []byte("0123456789abcdef0123456789abcdef")},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Alg.String(), func(t *testing.T) {
+			t.Parallel()
+
+			key, err := jwk.New(tc.Key)
+			if !assert.NoError(t, err, `jwk.New should succeed`) {
+				return
+			}
+			_ = key.Set(jwk.KeyIDKey, `my-key`)
+
+			signed, err := cose.Sign1(payload, tc.Alg, key, cose.WithExternalAAD([]byte("aad")))
+			if !assert.NoError(t, err, `cose.Sign1 should succeed`) {
+				return
+			}
+
+			var msg cose.Sign1Message
+			verified, err := cose.Verify1(signed, tc.Alg, key, cose.WithExternalAAD([]byte("aad")), cose.WithMessage(&msg))
+			if !assert.NoError(t, err, `cose.Verify1 should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payload should match`) {
+				return
+			}
+			if !assert.Equal(t, `my-key`, msg.KeyID(), `kid should match`) {
+				return
+			}
+
+			_, err = cose.Verify1(signed, tc.Alg, key)
+			if !assert.Error(t, err, `cose.Verify1 without external AAD should fail`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Algorithm mismatch", func(t *testing.T) {
+		t.Parallel()
+		signed, err := cose.Sign1(payload, jwa.ES256, eckey)
+		if !assert.NoError(t, err, `cose.Sign1 should succeed`) {
+			return
+		}
+		_, err = cose.Verify1(signed, jwa.ES384, eckey)
+		if !assert.Error(t, err, `cose.Verify1 should fail`) {
+			return
+		}
+	})
+	t.Run("Tampered payload", func(t *testing.T) {
+		t.Parallel()
+		signed, err := cose.Sign1(payload, jwa.ES256, eckey)
+		if !assert.NoError(t, err, `cose.Sign1 should succeed`) {
+			return
+		}
+		msg, err := cose.ParseSign1(signed)
+		if !assert.NoError(t, err, `cose.ParseSign1 should succeed`) {
+			return
+		}
+		alg, err := msg.Algorithm()
+		if !assert.NoError(t, err, `msg.Algorithm should succeed`) {
+			return
+		}
+		if !assert.Equal(t, jwa.ES256, alg, `algorithm should match`) {
+			return
+		}
+
+		signed[bytes.Index(signed, payload)] ^= 0x01
+		_, err = cose.Verify1(signed, jwa.ES256, &eckey.PublicKey)
+		if !assert.Error(t, err, `cose.Verify1 should fail`) {
+			return
+		}
+	})
+}
+
+func TestCWT(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RFC8392", func(t *testing.T) {
+		t.Parallel()
+		key, err := cose.ParseKey(mustHex(rfc8392Key))
+		if !assert.NoError(t, err, `cose.ParseKey should succeed`) {
+			return
+		}
+
+		token, err := cose.VerifyCWT(mustHex(rfc8392SignedCWT), jwa.ES256, key)
+		if !assert.NoError(t, err, `cose.VerifyCWT should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `coap://as.example.com`, token.Issuer(), `iss should match`) {
+			return
+		}
+		if !assert.Equal(t, `erikw`, token.Subject(), `sub should match`) {
+			return
+		}
+		if !assert.Equal(t, []string{`coap://light.example.com`}, token.Audience(), `aud should match`) {
+			return
+		}
+		if !assert.Equal(t, int64(1444064944), token.Expiration().Unix(), `exp should match`) {
+			return
+		}
+		if !assert.Equal(t, int64(1443944944), token.NotBefore().Unix(), `nbf should match`) {
+			return
+		}
+		if !assert.Equal(t, "\x0bq", token.JwtID(), `cti should match`) {
+			return
+		}
+	})
+	t.Run("Roundtrip", func(t *testing.T) {
+		t.Parallel()
+
+		key, err := jwxtest.GenerateEcdsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+			return
+		}
+
+		now := time.Unix(time.Now().Unix(), 0)
+		token := jwt.New()
+		_ = token.Set(jwt.IssuerKey, `https://issuer.example.com`)
+		_ = token.Set(jwt.AudienceKey, []string{`device-a`, `device-b`})
+		_ = token.Set(jwt.IssuedAtKey, now)
+		_ = token.Set(jwt.ExpirationKey, now.Add(time.Hour))
+		_ = token.Set(jwt.JwtIDKey, `token-1`)
+		_ = token.Set(`scope`, `read write`)
+		_ = token.Set(`-65537`, int64(42))
+		_ = token.Set(`nested`, map[string]interface{}{`a`: []interface{}{1.0, `b`}})
+
+		signed, err := cose.SignCWT(token, jwa.ES512, key)
+		if !assert.NoError(t, err, `cose.SignCWT should succeed`) {
+			return
+		}
+
+		parsed, err := cose.VerifyCWT(signed, jwa.ES512, key)
+		if !assert.NoError(t, err, `cose.VerifyCWT should succeed`) {
+			return
+		}
+		if !assert.NoError(t, jwt.Validate(parsed, jwt.WithAudience(`device-a`)), `jwt.Validate should succeed`) {
+			return
+		}
+
+		expected, _ := json.Marshal(token)
+		actual, _ := json.Marshal(parsed)
+		if !assert.JSONEq(t, string(expected), string(actual), `tokens should match`) {
+			return
+		}
+
+		// private claims with integer names use integer keys
+		claims, err := cose.MarshalClaims(token)
+		if !assert.NoError(t, err, `cose.MarshalClaims should succeed`) {
+			return
+		}
+		if !assert.Contains(t, hex.EncodeToString(claims), `3a00010000182a`, `claim -65537 should use an integer key`) {
+			return
+		}
+	})
+	t.Run("Conflicting private claim", func(t *testing.T) {
+		t.Parallel()
+		token := jwt.New()
+		_ = token.Set(`1`, `foo`)
+		_, err := cose.MarshalClaims(token)
+		if !assert.Error(t, err, `cose.MarshalClaims should fail`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package cose
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/cbor"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+)
+
+// CWT claim keys (RFC8392, section 4)
+const (
+	ClaimIssuer     = 1
+	ClaimSubject    = 2
+	ClaimAudience   = 3
+	ClaimExpiration = 4
+	ClaimNotBefore  = 5
+	ClaimIssuedAt   = 6
+	ClaimCWTID      = 7
+)
+
+// MarshalClaims converts the claims in a jwt.Token to a CWT claims set.
+//
+// The registered claims are encoded using their integer keys. The
+// "jti" claim is encoded as the byte string "cti" claim. Private
+// claims are encoded using their names as text keys, except for
+// names that are decimal integers (e.g. "-65537"), which are encoded
+// as integer keys. This allows private claims with integer keys to
+// survive a round trip through ParseClaims.
+func MarshalClaims(t jwt.Token) ([]byte, error) {
+	m, err := claimsToMap(t)
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(m)
+}
+
+func claimsToMap(t jwt.Token) (map[interface{}]interface{}, error) {
+	m := map[interface{}]interface{}{}
+	if v := t.Issuer(); v != "" {
+		m[ClaimIssuer] = v
+	}
+	if v := t.Subject(); v != "" {
+		m[ClaimSubject] = v
+	}
+	switch aud := t.Audience(); len(aud) {
+	case 0:
+	case 1:
+		m[ClaimAudience] = aud[0]
+	default:
+		m[ClaimAudience] = aud
+	}
+	for key, v := range map[int]time.Time{
+		ClaimExpiration: t.Expiration(),
+		ClaimNotBefore:  t.NotBefore(),
+		ClaimIssuedAt:   t.IssuedAt(),
+	} {
+		if !v.IsZero() {
+			m[key] = v.Unix()
+		}
+	}
+	if v := t.JwtID(); v != "" {
+		m[ClaimCWTID] = []byte(v)
+	}
+
+	for name, v := range t.PrivateClaims() {
+		value, err := toCBORValue(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to convert claim %q`, name)
+		}
+		if n, err := strconv.ParseInt(name, 10, 64); err == nil && strconv.FormatInt(n, 10) == name {
+			if n >= ClaimIssuer && n <= ClaimCWTID {
+				return nil, errors.Errorf(`private claim %q conflicts with a registered CWT claim`, name)
+			}
+			m[n] = value
+			continue
+		}
+		m[name] = value
+	}
+	return m, nil
+}
+
+// toCBORValue converts a claim value to a value that can be encoded
+// by the CBOR encoder. Values of types that are not natively supported
+// are converted through their JSON representation.
+func toCBORValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, bool, string, []byte, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, []string:
+		return v, nil
+	case float32:
+		return toCBORValue(float64(v))
+	case float64:
+		// numbers decoded from JSON are always float64
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), nil
+		}
+		return v, nil
+	case time.Time:
+		return v.Unix(), nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, elem := range v {
+			converted, err := toCBORValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = converted
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for key, elem := range v {
+			converted, err := toCBORValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, `unsupported value of type %T`, v)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(buf, &decoded); err != nil {
+			return nil, errors.Wrapf(err, `unsupported value of type %T`, v)
+		}
+		return toCBORValue(decoded)
+	}
+}
+
+// ParseClaims parses a CWT claims set, and converts it to a jwt.Token.
+// See MarshalClaims for how the claims are mapped. The claims are NOT
+// validated; use jwt.Validate for that.
+func ParseClaims(data []byte) (jwt.Token, error) {
+	v, err := cbor.Unmarshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode CWT claims`)
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.Errorf(`expected CWT claims to be a map, got %T`, v)
+	}
+	return mapToClaims(m)
+}
+
+func mapToClaims(m map[interface{}]interface{}) (jwt.Token, error) {
+	t := jwt.New()
+	for key, v := range m {
+		var name string
+		var value interface{}
+		switch key := key.(type) {
+		case string:
+			name = key
+			value = v
+		case int64:
+			var err error
+			name, value, err = registeredClaim(key, v)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.Errorf(`unsupported claim key type %T`, key)
+		}
+
+		if err := t.Set(name, fromCBORValue(value)); err != nil {
+			return nil, errors.Wrapf(err, `failed to set claim %q`, name)
+		}
+	}
+	return t, nil
+}
+
+func registeredClaim(key int64, v interface{}) (string, interface{}, error) {
+	switch key {
+	case ClaimIssuer, ClaimSubject:
+		s, ok := v.(string)
+		if !ok {
+			return "", nil, errors.Errorf(`expected claim %d to be a text string, got %T`, key, v)
+		}
+		if key == ClaimIssuer {
+			return jwt.IssuerKey, s, nil
+		}
+		return jwt.SubjectKey, s, nil
+	case ClaimAudience:
+		switch v := v.(type) {
+		case string:
+			return jwt.AudienceKey, []string{v}, nil
+		case []interface{}:
+			aud := make([]string, len(v))
+			for i, elem := range v {
+				s, ok := elem.(string)
+				if !ok {
+					return "", nil, errors.Errorf(`expected audience to be a text string, got %T`, elem)
+				}
+				aud[i] = s
+			}
+			return jwt.AudienceKey, aud, nil
+		default:
+			return "", nil, errors.Errorf(`expected claim %d to be a text string or an array, got %T`, key, v)
+		}
+	case ClaimExpiration, ClaimNotBefore, ClaimIssuedAt:
+		var tm time.Time
+		switch v := v.(type) {
+		case int64:
+			tm = time.Unix(v, 0)
+		case float64:
+			sec, frac := math.Modf(v)
+			tm = time.Unix(int64(sec), int64(frac*1e9))
+		default:
+			return "", nil, errors.Errorf(`expected claim %d to be a number, got %T`, key, v)
+		}
+		switch key {
+		case ClaimExpiration:
+			return jwt.ExpirationKey, tm, nil
+		case ClaimNotBefore:
+			return jwt.NotBeforeKey, tm, nil
+		default:
+			return jwt.IssuedAtKey, tm, nil
+		}
+	case ClaimCWTID:
+		b, ok := v.([]byte)
+		if !ok {
+			return "", nil, errors.Errorf(`expected claim %d to be a byte string, got %T`, key, v)
+		}
+		return jwt.JwtIDKey, string(b), nil
+	default:
+		return strconv.FormatInt(key, 10), v, nil
+	}
+}
+
+// fromCBORValue converts values decoded from CBOR so that they can be
+// marshaled to JSON. Map keys are converted to strings, and tags are
+// replaced with their content.
+func fromCBORValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case cbor.Tag:
+		return fromCBORValue(v.Content)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, elem := range v {
+			list[i] = fromCBORValue(elem)
+		}
+		return list
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			m[fmt.Sprint(key)] = fromCBORValue(elem)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+// SignCWT converts the claims in the token to a CWT claims set, and
+// signs it as a COSE_Sign1 message
+func SignCWT(t jwt.Token, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	payload, err := MarshalClaims(t)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal CWT claims`)
+	}
+	return Sign1(payload, alg, key, options...)
+}
+
+// VerifyCWT verifies a CWT signed as a COSE_Sign1 message (optionally
+// wrapped in the CWT tag), and converts its claims to a jwt.Token.
+// The claims are NOT validated; use jwt.Validate for that.
+func VerifyCWT(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...VerifyOption) (jwt.Token, error) {
+	payload, err := Verify1(buf, alg, key, options...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseClaims(payload)
+}
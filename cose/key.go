@@ -0,0 +1,323 @@
+package cose
+
+import (
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/cbor"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// COSE_Key common parameter labels (RFC9052, section 7.1)
+const (
+	KeyLabelKeyType = 1
+	KeyLabelKeyID   = 2
+	KeyLabelAlg     = 3
+	KeyLabelKeyOps  = 4
+)
+
+// COSE key types (RFC9053, section 7)
+const (
+	KeyTypeOKP       = 1
+	KeyTypeEC2       = 2
+	KeyTypeRSA       = 3
+	KeyTypeSymmetric = 4
+)
+
+var keyTypes = map[string]int64{
+	"OKP": KeyTypeOKP,
+	"EC":  KeyTypeEC2,
+	"RSA": KeyTypeRSA,
+	"oct": KeyTypeSymmetric,
+}
+
+var curves = map[string]int64{
+	"P-256":     1,
+	"P-384":     2,
+	"P-521":     3,
+	"X25519":    4,
+	"X448":      5,
+	"Ed25519":   6,
+	"Ed448":     7,
+	"secp256k1": 8,
+}
+
+var keyOps = map[string]int64{
+	"sign":       1,
+	"verify":     2,
+	"encrypt":    3,
+	"decrypt":    4,
+	"wrapKey":    5,
+	"unwrapKey":  6,
+	"deriveKey":  7,
+	"deriveBits": 8,
+}
+
+// COSE uses dedicated key operations for MAC keys, while JOSE uses
+// "sign" and "verify" for both
+const (
+	keyOpMACCreate = 9
+	keyOpMACVerify = 10
+)
+
+// keyParams lists the key type specific parameters, which are all
+// byte strings, as (JWK name, COSE label) pairs
+var keyParams = map[int64][]struct {
+	name  string
+	label int64
+}{
+	KeyTypeOKP:       {{"x", -2}, {"d", -4}},
+	KeyTypeEC2:       {{"x", -2}, {"y", -3}, {"d", -4}},
+	KeyTypeRSA:       {{"n", -1}, {"e", -2}, {"d", -3}, {"p", -4}, {"q", -5}, {"dp", -6}, {"dq", -7}, {"qi", -8}},
+	KeyTypeSymmetric: {{"k", -1}},
+}
+
+const keyLabelCurve = -1
+
+func lookupName(m map[string]int64, id int64) (string, bool) {
+	for name, v := range m {
+		if v == id {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// MarshalKey converts a jwk.Key to its COSE_Key representation.
+// Fields that have no COSE equivalent (e.g. "use", "x5c") are dropped.
+// Algorithms with no COSE equivalent are encoded as text strings.
+func MarshalKey(key jwk.Key) ([]byte, error) {
+	m, err := keyToMap(key)
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(m)
+}
+
+func keyToMap(key jwk.Key) (map[interface{}]interface{}, error) {
+	if key == nil {
+		return nil, errors.New(`key must not be nil`)
+	}
+
+	// Go through the JSON representation, which gives us uniform
+	// access to all fields regardless of the key type
+	buf, err := json.Marshal(key)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal key`)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		return nil, errors.Wrap(err, `failed to unmarshal key`)
+	}
+
+	kty, _ := fields["kty"].(string)
+	ktyID, ok := keyTypes[kty]
+	if !ok {
+		return nil, errors.Errorf(`key type %q has no COSE equivalent`, kty)
+	}
+
+	m := map[interface{}]interface{}{
+		KeyLabelKeyType: ktyID,
+	}
+	if kid, ok := fields["kid"].(string); ok && kid != "" {
+		m[KeyLabelKeyID] = []byte(kid)
+	}
+	if alg, ok := fields["alg"].(string); ok && alg != "" {
+		if id, err := Algorithm(alg); err == nil {
+			m[KeyLabelAlg] = id
+		} else {
+			m[KeyLabelAlg] = alg
+		}
+	}
+	if ops, ok := fields["key_ops"].([]interface{}); ok && len(ops) > 0 {
+		list := make([]interface{}, 0, len(ops))
+		for _, op := range ops {
+			name, _ := op.(string)
+			id, ok := keyOps[name]
+			if !ok {
+				return nil, errors.Errorf(`key operation %q has no COSE equivalent`, name)
+			}
+			if ktyID == KeyTypeSymmetric {
+				switch id {
+				case keyOps["sign"]:
+					id = keyOpMACCreate
+				case keyOps["verify"]:
+					id = keyOpMACVerify
+				}
+			}
+			list = append(list, id)
+		}
+		m[KeyLabelKeyOps] = list
+	}
+
+	if ktyID == KeyTypeOKP || ktyID == KeyTypeEC2 {
+		crv, _ := fields["crv"].(string)
+		id, ok := curves[crv]
+		if !ok {
+			return nil, errors.Errorf(`curve %q has no COSE equivalent`, crv)
+		}
+		m[keyLabelCurve] = id
+	}
+
+	for _, param := range keyParams[ktyID] {
+		v, ok := fields[param.name].(string)
+		if !ok {
+			continue
+		}
+		decoded, err := base64.DecodeString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to decode %q`, param.name)
+		}
+		m[param.label] = decoded
+	}
+	return m, nil
+}
+
+// ParseKey parses a COSE_Key and converts it to a jwk.Key.
+// Parameters that have no JWK equivalent are ignored.
+func ParseKey(data []byte) (jwk.Key, error) {
+	v, err := cbor.Unmarshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode COSE_Key`)
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.Errorf(`expected COSE_Key to be a map, got %T`, v)
+	}
+	return mapToKey(m)
+}
+
+func mapToKey(m map[interface{}]interface{}) (jwk.Key, error) {
+	ktyID, ok := toInt64(m[int64(KeyLabelKeyType)])
+	if !ok {
+		return nil, errors.New(`missing or invalid key type`)
+	}
+	kty, ok := lookupName(keyTypes, ktyID)
+	if !ok {
+		return nil, errors.Errorf(`unsupported COSE key type %d`, ktyID)
+	}
+
+	fields := map[string]interface{}{
+		"kty": kty,
+	}
+	if v, ok := m[int64(KeyLabelKeyID)]; ok {
+		kid, ok := v.([]byte)
+		if !ok {
+			return nil, errors.Errorf(`expected kid to be a byte string, got %T`, v)
+		}
+		fields["kid"] = string(kid)
+	}
+	if v, ok := m[int64(KeyLabelAlg)]; ok {
+		switch v := v.(type) {
+		case string:
+			fields["alg"] = v
+		case int64:
+			name, err := AlgorithmName(v)
+			if err != nil {
+				return nil, err
+			}
+			fields["alg"] = name
+		default:
+			return nil, errors.Errorf(`invalid alg type %T`, v)
+		}
+	}
+	if v, ok := m[int64(KeyLabelKeyOps)]; ok {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, errors.Errorf(`expected key_ops to be an array, got %T`, v)
+		}
+		ops := make([]string, 0, len(list))
+		for _, op := range list {
+			id, ok := toInt64(op)
+			if !ok {
+				return nil, errors.Errorf(`invalid key operation %v`, op)
+			}
+			switch id {
+			case keyOpMACCreate:
+				id = keyOps["sign"]
+			case keyOpMACVerify:
+				id = keyOps["verify"]
+			}
+			name, ok := lookupName(keyOps, id)
+			if !ok {
+				return nil, errors.Errorf(`unsupported key operation %d`, id)
+			}
+			ops = append(ops, name)
+		}
+		fields["key_ops"] = ops
+	}
+
+	if ktyID == KeyTypeOKP || ktyID == KeyTypeEC2 {
+		id, ok := toInt64(m[int64(keyLabelCurve)])
+		if !ok {
+			return nil, errors.New(`missing or invalid curve`)
+		}
+		crv, ok := lookupName(curves, id)
+		if !ok {
+			return nil, errors.Errorf(`unsupported COSE curve %d`, id)
+		}
+		fields["crv"] = crv
+	}
+
+	for _, param := range keyParams[ktyID] {
+		v, ok := m[param.label]
+		if !ok {
+			continue
+		}
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, errors.Errorf(`expected %q to be a byte string, got %T`, param.name, v)
+		}
+		fields[param.name] = base64.EncodeToString(b)
+	}
+
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal key`)
+	}
+	key, err := jwk.ParseKey(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse key`)
+	}
+	return key, nil
+}
+
+// MarshalKeySet converts a jwk.Set to a COSE_KeySet
+func MarshalKeySet(set jwk.Set) ([]byte, error) {
+	list := make([]interface{}, 0, set.Len())
+	for i := 0; i < set.Len(); i++ {
+		key, _ := set.Get(i)
+		m, err := keyToMap(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to convert key #%d`, i)
+		}
+		list = append(list, m)
+	}
+	return cbor.Marshal(list)
+}
+
+// ParseKeySet parses a COSE_KeySet and converts it to a jwk.Set
+func ParseKeySet(data []byte) (jwk.Set, error) {
+	v, err := cbor.Unmarshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode COSE_KeySet`)
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf(`expected COSE_KeySet to be an array, got %T`, v)
+	}
+
+	set := jwk.NewSet()
+	for i, elem := range list {
+		m, ok := elem.(map[interface{}]interface{})
+		if !ok {
+			return nil, errors.Errorf(`expected COSE_Key #%d to be a map, got %T`, i, elem)
+		}
+		key, err := mapToKey(m)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to convert key #%d`, i)
+		}
+		set.Add(key)
+	}
+	return set, nil
+}
@@ -0,0 +1,70 @@
+package cose
+
+import (
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+type identExternalAAD struct{}
+type identKeyID struct{}
+type identMessage struct{}
+
+// SignOption describes an option that can be passed to Sign1 and SignCWT
+type SignOption interface {
+	Option
+	signOption()
+}
+
+type signOption struct {
+	Option
+}
+
+func (*signOption) signOption() {}
+
+// VerifyOption describes an option that can be passed to Verify1 and VerifyCWT
+type VerifyOption interface {
+	Option
+	verifyOption()
+}
+
+type verifyOption struct {
+	Option
+}
+
+func (*verifyOption) verifyOption() {}
+
+// SignVerifyOption describes an option that can be passed to both
+// the signing and the verification functions
+type SignVerifyOption interface {
+	Option
+	signOption()
+	verifyOption()
+}
+
+type signVerifyOption struct {
+	Option
+}
+
+func (*signVerifyOption) signOption()   {}
+func (*signVerifyOption) verifyOption() {}
+
+// WithKeyID specifies the key ID to be included in the unprotected
+// header. If not specified and the key is a jwk.Key with a "kid"
+// field, that value is used.
+func WithKeyID(kid string) SignOption {
+	return &signOption{option.New(identKeyID{}, kid)}
+}
+
+// WithExternalAAD specifies externally supplied data that is
+// authenticated along with the payload, but not included in the
+// message. The same value must be given when signing and verifying.
+func WithExternalAAD(aad []byte) SignVerifyOption {
+	return &signVerifyOption{option.New(identExternalAAD{}, aad)}
+}
+
+// WithMessage can be passed to Verify1 and VerifyCWT to obtain the
+// parsed message upon a successful verification
+func WithMessage(m *Sign1Message) VerifyOption {
+	return &verifyOption{option.New(identMessage{}, m)}
+}
@@ -0,0 +1,259 @@
+package cose
+
+import (
+	"github.com/lestrrat-go/jwx/internal/cbor"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
+)
+
+const sign1Context = "Signature1"
+
+// Sign1Message is a parsed COSE_Sign1 message
+type Sign1Message struct {
+	protected   []byte
+	Protected   map[interface{}]interface{}
+	Unprotected map[interface{}]interface{}
+	Payload     []byte
+	Signature   []byte
+}
+
+// Algorithm returns the JOSE name of the algorithm in the protected header
+func (m *Sign1Message) Algorithm() (jwa.SignatureAlgorithm, error) {
+	id, ok := toInt64(m.Protected[int64(HeaderAlgorithm)])
+	if !ok {
+		return "", errors.New(`missing or invalid algorithm in protected header`)
+	}
+	name, err := AlgorithmName(id)
+	if err != nil {
+		return "", err
+	}
+	return jwa.SignatureAlgorithm(name), nil
+}
+
+// KeyID returns the key ID in the protected or unprotected header,
+// or an empty string if there is none
+func (m *Sign1Message) KeyID() string {
+	for _, hdr := range []map[interface{}]interface{}{m.Protected, m.Unprotected} {
+		if kid, ok := hdr[int64(HeaderKeyID)].([]byte); ok {
+			return string(kid)
+		}
+	}
+	return ""
+}
+
+// toBeSigned builds the Sig_structure (RFC9052, section 4.4)
+func toBeSigned(protected, externalAAD, payload []byte) ([]byte, error) {
+	if externalAAD == nil {
+		externalAAD = []byte{}
+	}
+	return cbor.Marshal([]interface{}{sign1Context, protected, externalAAD, payload})
+}
+
+// Sign1 creates a tagged COSE_Sign1 message for the given payload.
+// The key may be a raw key or a jwk.Key, as with jws.Sign. The
+// algorithm is stored in the protected header, and the key ID (if any)
+// in the unprotected header.
+func Sign1(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	var kid string
+	var externalAAD []byte
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identKeyID{}:
+			kid = option.Value().(string)
+		case identExternalAAD{}:
+			externalAAD = option.Value().([]byte)
+		}
+	}
+
+	algID, err := Algorithm(alg.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		if kid == "" {
+			kid = jwkKey.KeyID()
+		}
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return nil, errors.Wrap(err, `failed to retrieve raw key from jwk.Key`)
+		}
+		key = raw
+	}
+
+	signer, err := jws.NewSigner(alg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create signer`)
+	}
+
+	protected, err := cbor.Marshal(map[interface{}]interface{}{HeaderAlgorithm: algID})
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode protected header`)
+	}
+
+	tbs, err := toBeSigned(protected, externalAAD, payload)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode Sig_structure`)
+	}
+
+	signature, err := signer.Sign(tbs, key)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign payload`)
+	}
+
+	unprotected := map[interface{}]interface{}{}
+	if kid != "" {
+		unprotected[HeaderKeyID] = []byte(kid)
+	}
+
+	return cbor.Marshal(cbor.Tag{
+		Number:  TagSign1,
+		Content: []interface{}{protected, unprotected, payload, signature},
+	})
+}
+
+// ParseSign1 parses a COSE_Sign1 message, which may or may not be
+// tagged. The signature is NOT verified.
+func ParseSign1(buf []byte) (*Sign1Message, error) {
+	v, err := cbor.Unmarshal(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode COSE_Sign1`)
+	}
+	return parseSign1(v)
+}
+
+func parseSign1(v interface{}) (*Sign1Message, error) {
+	if tag, ok := v.(cbor.Tag); ok {
+		if tag.Number != TagSign1 {
+			return nil, errors.Errorf(`unexpected CBOR tag %d`, tag.Number)
+		}
+		v = tag.Content
+	}
+
+	list, ok := v.([]interface{})
+	if !ok || len(list) != 4 {
+		return nil, errors.New(`COSE_Sign1 must be an array of 4 elements`)
+	}
+
+	protected, ok := list[0].([]byte)
+	if !ok {
+		return nil, errors.New(`protected header must be a byte string`)
+	}
+	unprotected, ok := list[1].(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New(`unprotected header must be a map`)
+	}
+	// a detached payload (nil) is not supported
+	payload, ok := list[2].([]byte)
+	if !ok {
+		return nil, errors.New(`payload must be a byte string`)
+	}
+	signature, ok := list[3].([]byte)
+	if !ok {
+		return nil, errors.New(`signature must be a byte string`)
+	}
+
+	m := &Sign1Message{
+		protected:   protected,
+		Protected:   map[interface{}]interface{}{},
+		Unprotected: unprotected,
+		Payload:     payload,
+		Signature:   signature,
+	}
+	if len(protected) > 0 {
+		hdr, err := cbor.Unmarshal(protected)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to decode protected header`)
+		}
+		hdrmap, ok := hdr.(map[interface{}]interface{})
+		if !ok {
+			return nil, errors.New(`protected header must be a map`)
+		}
+		m.Protected = hdrmap
+	}
+	if _, ok := m.Protected[int64(HeaderCritical)]; ok {
+		return nil, errors.New(`critical header parameters are not supported`)
+	}
+	return m, nil
+}
+
+// Verify1 verifies a COSE_Sign1 message using the given algorithm and
+// key, and returns the payload. The key may be a raw key or a jwk.Key,
+// and if it is a private key its public key is used. The algorithm in the protected header must match alg.
+func Verify1(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...VerifyOption) ([]byte, error) {
+	m, err := verify1(buf, alg, key, options...)
+	if err != nil {
+		return nil, err
+	}
+	return m.Payload, nil
+}
+
+func verify1(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...VerifyOption) (*Sign1Message, error) {
+	var dst *Sign1Message
+	var externalAAD []byte
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identExternalAAD{}:
+			externalAAD = option.Value().([]byte)
+		case identMessage{}:
+			dst = option.Value().(*Sign1Message)
+		}
+	}
+
+	v, err := cbor.Unmarshal(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode COSE_Sign1`)
+	}
+	// CWTs may be wrapped in an additional tag
+	if tag, ok := v.(cbor.Tag); ok && tag.Number == TagCWT {
+		v = tag.Content
+	}
+
+	m, err := parseSign1(v)
+	if err != nil {
+		return nil, err
+	}
+
+	msgalg, err := m.Algorithm()
+	if err != nil {
+		return nil, err
+	}
+	if msgalg != alg {
+		return nil, errors.Errorf(`algorithm in protected header (%s) does not match %s`, msgalg, alg)
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return nil, errors.Wrap(err, `failed to retrieve raw key from jwk.Key`)
+		}
+		key = raw
+	}
+	// allow private keys to be used, as COSE key sets often contain them
+	if pubkey, err := jwk.PublicRawKeyOf(key); err == nil {
+		key = pubkey
+	}
+
+	verifier, err := jws.NewVerifier(alg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create verifier`)
+	}
+
+	tbs, err := toBeSigned(m.protected, externalAAD, m.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode Sig_structure`)
+	}
+
+	if err := verifier.Verify(tbs, m.Signature, key); err != nil {
+		return nil, errors.Wrap(err, `failed to verify signature`)
+	}
+
+	if dst != nil {
+		*dst = *m
+	}
+	return m, nil
+}
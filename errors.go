@@ -0,0 +1,45 @@
+package jwx
+
+import (
+	"github.com/lestrrat-go/jwx/internal/fips"
+	"github.com/lestrrat-go/jwx/internal/keysep"
+	"github.com/pkg/errors"
+)
+
+// The following errors describe the kind of failure that occurred
+// while processing JWx objects. They are never returned as is, but
+// errors returned from the `jws`, `jwe`, `jwk`, and `jwt` packages
+// match them via `errors.Is()` when applicable, regardless of how
+// deeply they have been wrapped:
+//
+//   if errors.Is(err, jwx.ErrVerification) {
+//     // respond with 401
+//   }
+//
+// The package specific error types (e.g. `jws.VerificationError`,
+// `jwt.ValidationError`) can be extracted using `errors.As()` for
+// more details.
+var (
+	// ErrParse indicates that the input could not be parsed
+	ErrParse = errors.New(`parse error`)
+	// ErrVerification indicates that a signature could not be verified
+	ErrVerification = errors.New(`verification error`)
+	// ErrDecryption indicates that a message could not be decrypted
+	ErrDecryption = errors.New(`decryption error`)
+	// ErrKeyResolution indicates that a suitable key could not be found
+	ErrKeyResolution = errors.New(`key resolution error`)
+	// ErrValidation indicates that the claims in a JWT failed validation
+	ErrValidation = errors.New(`validation error`)
+	// ErrKeySeparation indicates that a key was used both for signatures
+	// and for encryption. See `jwx.WithKeySeparation()`
+	ErrKeySeparation = keysep.ErrKeySeparation
+	// ErrNotFIPSApproved indicates that an algorithm, curve, or key size
+	// that is not approved was requested while FIPS mode is enabled.
+	// See `jwx.WithFIPSMode()`
+	ErrNotFIPSApproved = fips.ErrNotApproved
+)
+
+// FIPSError is the error returned when FIPS mode is enabled and an
+// algorithm that is not approved is requested. It matches
+// `jwx.ErrNotFIPSApproved` via `errors.Is()`
+type FIPSError = fips.Error
@@ -307,7 +307,7 @@ func ExampleJWT_OpenIDToken() {
 	}
 	fmt.Printf("%s\n", buf)
 
-	t2, err := jwt.Parse(buf, jwt.WithToken(openid.New()))
+	t2, err := jwt.ParseInsecure(buf, jwt.WithToken(openid.New()))
 	if err != nil {
 		fmt.Printf("failed to parse JSON: %s\n", err)
 		return
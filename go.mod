@@ -3,6 +3,7 @@ module github.com/lestrrat-go/jwx
 go 1.15
 
 require (
+	filippo.io/edwards25519 v1.0.0
 	github.com/decred/dcrd/dcrec/secp256k1/v3 v3.0.0
 	github.com/goccy/go-json v0.7.4
 	github.com/lestrrat-go/backoff/v2 v2.0.7
@@ -0,0 +1,31 @@
+package jwx
+
+import "github.com/lestrrat-go/jwx/internal/hook"
+
+// Hook is notified of the operations performed by the jwx packages,
+// such as fetching JWK sets (`jwk.Fetch()`, `jwk.AutoRefresh`),
+// signing and verifying JWS messages, and encrypting and decrypting
+// JWE messages. Use it to emit logs, metrics, or tracing spans.
+//
+// Handle is called synchronously after each operation completes,
+// so implementations should return quickly.
+type Hook = hook.Hook
+
+// HookFunc is a function that implements the Hook interface
+type HookFunc = hook.HookFunc
+
+// Event describes an operation reported to a Hook. It contains the
+// kind of the operation, the algorithm, key ID and URL if applicable,
+// the time it took, and the resulting error, if any.
+type Event = hook.Event
+
+// EventKind describes the kind of an Event
+type EventKind = hook.Kind
+
+const (
+	FetchEvent   EventKind = hook.FetchKind
+	SignEvent    EventKind = hook.SignKind
+	VerifyEvent  EventKind = hook.VerifyKind
+	EncryptEvent EventKind = hook.EncryptKind
+	DecryptEvent EventKind = hook.DecryptKind
+)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 )
@@ -37,7 +38,56 @@ func EncodeUint64ToString(v uint64) string {
 	return EncodeToString(data[i:])
 }
 
+var lenient uint32
+
+// SetLenient controls whether Decode and DecodeStd accept any of the
+// standard and URL alphabets, with or without padding
+func SetLenient(b bool) {
+	var v uint32
+	if b {
+		v = 1
+	}
+	atomic.StoreUint32(&lenient, v)
+}
+
+// Lenient returns true if lenient decoding is enabled
+func Lenient() bool {
+	return atomic.LoadUint32(&lenient) == 1
+}
+
+// Decode decodes base64url without padding, as required by RFC7515.
+// Other forms are only accepted if lenient decoding is enabled.
 func Decode(src []byte) ([]byte, error) {
+	if Lenient() {
+		return DecodeLenient(src)
+	}
+	return decode(base64.RawURLEncoding, src)
+}
+
+func DecodeString(src string) ([]byte, error) {
+	return Decode([]byte(src))
+}
+
+// DecodeStd decodes standard base64, which is used for values such
+// as "x5c". Padding is optional. The URL alphabet is only accepted if
+// lenient decoding is enabled.
+func DecodeStd(src []byte) ([]byte, error) {
+	if Lenient() {
+		return DecodeLenient(src)
+	}
+	if bytes.HasSuffix(src, []byte{'='}) {
+		return decode(base64.StdEncoding, src)
+	}
+	return decode(base64.RawStdEncoding, src)
+}
+
+func DecodeStdString(src string) ([]byte, error) {
+	return DecodeStd([]byte(src))
+}
+
+// DecodeLenient decodes src, which may use either the standard or the
+// URL alphabet, with or without padding
+func DecodeLenient(src []byte) ([]byte, error) {
 	var enc *base64.Encoding
 
 	var isRaw = !bytes.HasSuffix(src, []byte{'='})
@@ -52,7 +102,10 @@ func Decode(src []byte) ([]byte, error) {
 	default:
 		enc = base64.StdEncoding
 	}
+	return decode(enc, src)
+}
 
+func decode(enc *base64.Encoding, src []byte) ([]byte, error) {
 	dst := make([]byte, enc.DecodedLen(len(src)))
 	n, err := enc.Decode(dst, src)
 	if err != nil {
@@ -61,6 +114,16 @@ func Decode(src []byte) ([]byte, error) {
 	return dst[:n], nil
 }
 
-func DecodeString(src string) ([]byte, error) {
-	return Decode([]byte(src))
+// EncodedLen returns the length of the base64url encoded (without
+// padding) representation of a buffer of length n
+func EncodedLen(n int) int {
+	return base64.RawURLEncoding.EncodedLen(n)
+}
+
+// EncodeTo encodes src into dst, which must be at least
+// EncodedLen(len(src)) bytes long, and returns the number of bytes written
+func EncodeTo(dst, src []byte) int {
+	enc := base64.RawURLEncoding
+	enc.Encode(dst, src)
+	return enc.EncodedLen(len(src))
 }
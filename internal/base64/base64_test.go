@@ -11,10 +11,12 @@ func TestDecode(t *testing.T) {
 	testcases := []struct {
 		Name     string
 		Encoding *base64.Encoding
+		Strict   bool
 	}{
 		{
 			Name:     "base64.RawURLEncoding",
 			Encoding: base64.RawURLEncoding,
+			Strict:   true,
 		},
 		{
 			Name:     "base64.URLEncoding",
@@ -30,7 +32,8 @@ func TestDecode(t *testing.T) {
 		},
 	}
 
-	var payload = []byte("Hello, World")
+	// encodes to "+/+/APs=" in the standard alphabet
+	var payload = []byte{0xfb, 0xff, 0xbf, 0x00, 0xfb}
 	for _, tc := range testcases {
 		tc := tc
 		t.Run(tc.Name, func(t *testing.T) {
@@ -38,7 +41,19 @@ func TestDecode(t *testing.T) {
 			tc.Encoding.Encode(dst, payload)
 
 			decoded, err := Decode(dst)
-			if !assert.NoError(t, err, `Decode should succeed`) {
+			if tc.Strict {
+				if !assert.NoError(t, err, `Decode should succeed`) {
+					return
+				}
+				if !assert.Equal(t, payload, decoded, `decoded content should match`) {
+					return
+				}
+			} else if !assert.Error(t, err, `Decode should fail`) {
+				return
+			}
+
+			decoded, err = DecodeLenient(dst)
+			if !assert.NoError(t, err, `DecodeLenient should succeed`) {
 				return
 			}
 			if !assert.Equal(t, payload, decoded, `decoded content should match`) {
@@ -47,6 +62,23 @@ func TestDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestLenient(t *testing.T) {
+	src := []byte(base64.StdEncoding.EncodeToString([]byte{0xfb, 0xff, 0xbf, 0x00, 0xfb}))
+	if _, err := Decode(src); !assert.Error(t, err, `Decode should fail`) {
+		return
+	}
+
+	SetLenient(true)
+	defer SetLenient(false)
+	if _, err := Decode(src); !assert.NoError(t, err, `Decode should succeed`) {
+		return
+	}
+	if _, err := DecodeStd([]byte(base64.RawURLEncoding.EncodeToString([]byte{0xfb, 0xff}))); !assert.NoError(t, err, `DecodeStd should succeed`) {
+		return
+	}
+}
+
 func TestEncodeToStringStd(t *testing.T) {
 	t.Parallel()
 	t.Run("Encodes to StdEncoding with padding", func(t *testing.T) {
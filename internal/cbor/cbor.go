@@ -0,0 +1,409 @@
+// Package cbor implements the subset of CBOR (RFC8949) required to
+// handle COSE and CWT structures.
+//
+// Values are decoded into generic Go types: int64 (or uint64 for
+// positive integers that do not fit in an int64), []byte, string,
+// []interface{}, map[interface{}]interface{}, Tag, bool, nil, and
+// float64. Indefinite length items are not supported.
+//
+// Encoding always produces the deterministic encoding described in
+// RFC8949 section 4.2.1, i.e. map keys are sorted by their encoded form.
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	majorUint   = 0
+	majorNegint = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+const (
+	simpleFalse   = 20
+	simpleTrue    = 21
+	simpleNull    = 22
+	simpleFloat16 = 25
+	simpleFloat32 = 26
+	simpleFloat64 = 27
+)
+
+// maxDepth is the maximum nesting level of arrays, maps and tags
+// accepted by Unmarshal
+const maxDepth = 32
+
+// Tag represents a tagged data item
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+// RawMessage is a pre-encoded CBOR data item. It is written as-is
+// by Marshal.
+type RawMessage []byte
+
+// Marshal returns the deterministic CBOR encoding of v
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], uint16(n))
+		buf.Write(b[:])
+	case n <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		buf.Write(b[:])
+	default:
+		buf.WriteByte(major<<5 | 27)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], n)
+		buf.Write(b[:])
+	}
+}
+
+func writeInt(buf *bytes.Buffer, v int64) {
+	if v < 0 {
+		writeHead(buf, majorNegint, uint64(-(v + 1)))
+		return
+	}
+	writeHead(buf, majorUint, uint64(v))
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(majorSimple<<5 | simpleNull)
+	case bool:
+		if v {
+			buf.WriteByte(majorSimple<<5 | simpleTrue)
+		} else {
+			buf.WriteByte(majorSimple<<5 | simpleFalse)
+		}
+	case int:
+		writeInt(buf, int64(v))
+	case int8:
+		writeInt(buf, int64(v))
+	case int16:
+		writeInt(buf, int64(v))
+	case int32:
+		writeInt(buf, int64(v))
+	case int64:
+		writeInt(buf, v)
+	case uint:
+		writeHead(buf, majorUint, uint64(v))
+	case uint8:
+		writeHead(buf, majorUint, uint64(v))
+	case uint16:
+		writeHead(buf, majorUint, uint64(v))
+	case uint32:
+		writeHead(buf, majorUint, uint64(v))
+	case uint64:
+		writeHead(buf, majorUint, v)
+	case float32:
+		encodeFloat(buf, float64(v))
+	case float64:
+		encodeFloat(buf, v)
+	case []byte:
+		writeHead(buf, majorBytes, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		writeHead(buf, majorText, uint64(len(v)))
+		buf.WriteString(v)
+	case RawMessage:
+		buf.Write(v)
+	case Tag:
+		writeHead(buf, majorTag, v.Number)
+		return encode(buf, v.Content)
+	case []interface{}:
+		writeHead(buf, majorArray, uint64(len(v)))
+		for i, elem := range v {
+			if err := encode(buf, elem); err != nil {
+				return errors.Wrapf(err, `failed to encode array element %d`, i)
+			}
+		}
+	case []string:
+		writeHead(buf, majorArray, uint64(len(v)))
+		for _, elem := range v {
+			writeHead(buf, majorText, uint64(len(elem)))
+			buf.WriteString(elem)
+		}
+	case map[interface{}]interface{}:
+		keys := make([]interface{}, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		return encodeMap(buf, keys, func(k interface{}) interface{} { return v[k] })
+	case map[string]interface{}:
+		keys := make([]interface{}, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		return encodeMap(buf, keys, func(k interface{}) interface{} { return v[k.(string)] }) //nolint:forcetypeassert
+	default:
+		return errors.Errorf(`unsupported type %T`, v)
+	}
+	return nil
+}
+
+func encodeFloat(buf *bytes.Buffer, v float64) {
+	if f32 := float32(v); float64(f32) == v || math.IsNaN(v) {
+		buf.WriteByte(majorSimple<<5 | simpleFloat32)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], math.Float32bits(f32))
+		buf.Write(b[:])
+		return
+	}
+	buf.WriteByte(majorSimple<<5 | simpleFloat64)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+	buf.Write(b[:])
+}
+
+type encodedPair struct {
+	key   []byte
+	value []byte
+}
+
+func encodeMap(buf *bytes.Buffer, keys []interface{}, get func(interface{}) interface{}) error {
+	pairs := make([]encodedPair, 0, len(keys))
+	for _, k := range keys {
+		var kbuf, vbuf bytes.Buffer
+		if err := encode(&kbuf, k); err != nil {
+			return errors.Wrapf(err, `failed to encode map key %v`, k)
+		}
+		if err := encode(&vbuf, get(k)); err != nil {
+			return errors.Wrapf(err, `failed to encode map value for key %v`, k)
+		}
+		pairs = append(pairs, encodedPair{key: kbuf.Bytes(), value: vbuf.Bytes()})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i].key, pairs[j].key) < 0
+	})
+
+	writeHead(buf, majorMap, uint64(len(pairs)))
+	for _, pair := range pairs {
+		buf.Write(pair.key)
+		buf.Write(pair.value)
+	}
+	return nil
+}
+
+// Unmarshal decodes a single CBOR data item. It is an error if data
+// contains trailing bytes.
+func Unmarshal(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errors.Errorf(`unexpected %d trailing bytes`, len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errors.New(`unexpected end of data`)
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *decoder) readHead() (byte, byte, uint64, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major := b[0] >> 5
+	info := b[0] & 0x1f
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info == 24:
+		b, err := d.read(1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		n = uint64(b[0])
+	case info == 25:
+		b, err := d.read(2)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b))
+	case info == 26:
+		b, err := d.read(4)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		n = uint64(binary.BigEndian.Uint32(b))
+	case info == 27:
+		b, err := d.read(8)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		n = binary.BigEndian.Uint64(b)
+	case info == 31:
+		return 0, 0, 0, errors.New(`indefinite length items are not supported`)
+	default:
+		return 0, 0, 0, errors.Errorf(`invalid additional information %d`, info)
+	}
+	return major, info, n, nil
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New(`maximum nesting depth exceeded`)
+	}
+
+	major, info, n, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case majorNegint:
+		if n > math.MaxInt64 {
+			return nil, errors.New(`negative integer overflows int64`)
+		}
+		return -1 - int64(n), nil
+	case majorBytes:
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		ret := make([]byte, len(b))
+		copy(ret, b)
+		return ret, nil
+	case majorText:
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case majorArray:
+		// each element is at least one byte
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errors.New(`unexpected end of data`)
+		}
+		ret := make([]interface{}, 0, int(n))
+		for i := uint64(0); i < n; i++ {
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	case majorMap:
+		if n > uint64(len(d.data)-d.pos)/2 {
+			return nil, errors.New(`unexpected end of data`)
+		}
+		ret := make(map[interface{}]interface{}, int(n))
+		for i := uint64(0); i < n; i++ {
+			k, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, uint64, string, bool:
+			default:
+				return nil, errors.Errorf(`unsupported map key type %T`, k)
+			}
+			if _, ok := ret[k]; ok {
+				return nil, errors.Errorf(`duplicate map key %v`, k)
+			}
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			ret[k] = v
+		}
+		return ret, nil
+	case majorTag:
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Tag{Number: n, Content: v}, nil
+	default: // majorSimple
+		switch info {
+		case simpleFalse:
+			return false, nil
+		case simpleTrue:
+			return true, nil
+		case simpleNull:
+			return nil, nil
+		case simpleFloat16:
+			return float16ToFloat64(uint16(n)), nil
+		case simpleFloat32:
+			return float64(math.Float32frombits(uint32(n))), nil
+		case simpleFloat64:
+			return math.Float64frombits(n), nil
+		default:
+			return nil, errors.Errorf(`unsupported simple value %d`, n)
+		}
+	}
+}
+
+func float16ToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,129 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundtrip(t *testing.T) {
+	// RFC8949 Appendix A
+	testcases := []struct {
+		Value   interface{}
+		Encoded string
+	}{
+		{Value: int64(0), Encoded: "00"},
+		{Value: int64(23), Encoded: "17"},
+		{Value: int64(24), Encoded: "1818"},
+		{Value: int64(1000), Encoded: "1903e8"},
+		{Value: int64(1000000), Encoded: "1a000f4240"},
+		{Value: int64(1000000000000), Encoded: "1b000000e8d4a51000"},
+		{Value: uint64(18446744073709551615), Encoded: "1bffffffffffffffff"},
+		{Value: int64(-1), Encoded: "20"},
+		{Value: int64(-1000), Encoded: "3903e7"},
+		{Value: 1.5, Encoded: "fa3fc00000"},
+		{Value: 1.1, Encoded: "fb3ff199999999999a"},
+		{Value: false, Encoded: "f4"},
+		{Value: true, Encoded: "f5"},
+		{Value: nil, Encoded: "f6"},
+		{Value: []byte{0x01, 0x02, 0x03, 0x04}, Encoded: "4401020304"},
+		{Value: "IETF", Encoded: "6449455446"},
+		{Value: "ü", Encoded: "62c3bc"},
+		{Value: []interface{}{int64(1), []interface{}{int64(2), int64(3)}}, Encoded: "8201820203"},
+		{Value: map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}, Encoded: "a201020304"},
+		{Value: map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}, Encoded: "a26161016162820203"},
+		{Value: Tag{Number: 1, Content: int64(1363896240)}, Encoded: "c11a514b67b0"},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Encoded, func(t *testing.T) {
+			encoded, err := Marshal(tc.Value)
+			if !assert.NoError(t, err, `Marshal should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Encoded, hex.EncodeToString(encoded), `encoded value should match`) {
+				return
+			}
+
+			decoded, err := Unmarshal(encoded)
+			if !assert.NoError(t, err, `Unmarshal should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Value, decoded, `decoded value should match`) {
+				return
+			}
+		})
+	}
+}
+
+func TestDeterministicMap(t *testing.T) {
+	// keys are sorted by their encoded form: 10, -1, "z", "aa"
+	v := map[interface{}]interface{}{
+		"aa": int64(0),
+		"z":  int64(0),
+		-1:   int64(0),
+		10:   int64(0),
+	}
+	encoded, err := Marshal(v)
+	if !assert.NoError(t, err, `Marshal should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "a40a002000617a00626161"+"00", hex.EncodeToString(encoded), `keys should be sorted`) {
+		return
+	}
+}
+
+func TestUnmarshalFloat16(t *testing.T) {
+	testcases := map[string]float64{
+		"f90000": 0,
+		"f93c00": 1,
+		"f97bff": 65504,
+		"f9c400": -4,
+		"f90001": 5.960464477539063e-8,
+		"f97c00": math.Inf(1),
+	}
+	for encoded, expected := range testcases {
+		buf, _ := hex.DecodeString(encoded)
+		v, err := Unmarshal(buf)
+		if !assert.NoError(t, err, `Unmarshal should succeed`) {
+			return
+		}
+		if !assert.Equal(t, expected, v, `decoded value should match`) {
+			return
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	testcases := []string{
+		"",                   // empty
+		"1a0000",             // truncated integer
+		"44010203",           // truncated byte string
+		"5f42010243030405ff", // indefinite length byte string
+		"9bffffffffffffffff", // huge array
+		"a2010201",           // truncated map
+		"a201020103",         // duplicate key
+		"a1400102",           // byte string key
+		"0000",               // trailing bytes
+		"3bffffffffffffffff", // negative integer overflow
+	}
+	for _, encoded := range testcases {
+		buf, _ := hex.DecodeString(encoded)
+		_, err := Unmarshal(buf)
+		if !assert.Error(t, err, `Unmarshal(%s) should fail`, encoded) {
+			return
+		}
+	}
+
+	nested := make([]byte, maxDepth+2)
+	for i := range nested {
+		nested[i] = 0x81
+	}
+	_, err := Unmarshal(append(nested, 0x00))
+	if !assert.Error(t, err, `Unmarshal should fail for deeply nested input`) {
+		return
+	}
+}
@@ -0,0 +1,189 @@
+// Package fips implements the machinery behind jwx.WithFIPSMode(),
+// which restricts the jwx packages to FIPS approved algorithms
+package fips
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
+)
+
+// ErrNotApproved is the error that all errors returned for disallowed
+// algorithms match via `errors.Is()`
+var ErrNotApproved = errors.New(`not approved in FIPS mode`)
+
+// MinHMACKeySize is the minimum size of HMAC keys in bytes (112 bits),
+// as required by NIST SP 800-131A
+const MinHMACKeySize = 14
+
+// Error is returned when an algorithm or curve that is not approved
+// is requested while FIPS mode is enabled
+type Error struct {
+	kind   string
+	name   string
+	reason string
+}
+
+func newError(kind, name, reason string) error {
+	return &Error{kind: kind, name: name, reason: reason}
+}
+
+// Algorithm returns the name of the algorithm or curve that was rejected
+func (e *Error) Algorithm() string {
+	return e.name
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf(`%s %q is not approved in FIPS mode`, e.kind, e.name)
+	if e.reason != "" {
+		msg += `: ` + e.reason
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrNotApproved
+}
+
+var enabled int32
+
+func init() {
+	if buildTag || moduleEnabled() {
+		enabled = 1
+	}
+}
+
+// SetEnabled enables or disables FIPS mode
+func SetEnabled(b bool) {
+	var v int32
+	if b {
+		v = 1
+	}
+	atomic.StoreInt32(&enabled, v)
+}
+
+// Enabled returns true if FIPS mode is enabled
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+var approvedSignatureAlgorithms = map[jwa.SignatureAlgorithm]struct{}{
+	jwa.ES256: {}, jwa.ES384: {}, jwa.ES512: {},
+	jwa.EdDSA: {},
+	jwa.HS256: {}, jwa.HS384: {}, jwa.HS512: {},
+	jwa.PS256: {}, jwa.PS384: {}, jwa.PS512: {},
+	jwa.RS256: {}, jwa.RS384: {}, jwa.RS512: {},
+	// ML-DSA (FIPS 204). The constants are only available with jwx_mldsa
+	"ML-DSA-44": {}, "ML-DSA-65": {}, "ML-DSA-87": {},
+}
+
+var approvedKeyEncryptionAlgorithms = map[jwa.KeyEncryptionAlgorithm]struct{}{
+	jwa.A128GCMKW: {}, jwa.A192GCMKW: {}, jwa.A256GCMKW: {},
+	jwa.A128KW: {}, jwa.A192KW: {}, jwa.A256KW: {},
+	jwa.DIRECT:   {},
+	jwa.ECDH_1PU: {}, jwa.ECDH_1PU_A128KW: {}, jwa.ECDH_1PU_A192KW: {}, jwa.ECDH_1PU_A256KW: {},
+	jwa.ECDH_ES: {}, jwa.ECDH_ES_A128KW: {}, jwa.ECDH_ES_A192KW: {}, jwa.ECDH_ES_A256KW: {},
+	jwa.PBES2_HS256_A128KW: {}, jwa.PBES2_HS384_A192KW: {}, jwa.PBES2_HS512_A256KW: {},
+	jwa.RSA_OAEP: {}, jwa.RSA_OAEP_256: {},
+}
+
+var approvedContentEncryptionAlgorithms = map[jwa.ContentEncryptionAlgorithm]struct{}{
+	jwa.A128CBC_HS256: {}, jwa.A192CBC_HS384: {}, jwa.A256CBC_HS512: {},
+	jwa.A128GCM: {}, jwa.A192GCM: {}, jwa.A256GCM: {},
+}
+
+var approvedCurves = map[jwa.EllipticCurveAlgorithm]struct{}{
+	jwa.P256: {}, jwa.P384: {}, jwa.P521: {},
+	jwa.Ed25519: {},
+}
+
+// CheckSignatureAlgorithm returns an error if FIPS mode is enabled,
+// and alg is not approved. Algorithms registered by the user are
+// never approved
+func CheckSignatureAlgorithm(alg jwa.SignatureAlgorithm) error {
+	if !Enabled() {
+		return nil
+	}
+	if _, ok := approvedSignatureAlgorithms[alg]; !ok {
+		return newError(`signature algorithm`, alg.String(), ``)
+	}
+	return nil
+}
+
+// CheckKeyEncryptionAlgorithm is the same as CheckSignatureAlgorithm,
+// for key encryption algorithms
+func CheckKeyEncryptionAlgorithm(alg jwa.KeyEncryptionAlgorithm) error {
+	if !Enabled() {
+		return nil
+	}
+	if _, ok := approvedKeyEncryptionAlgorithms[alg]; !ok {
+		return newError(`key encryption algorithm`, alg.String(), ``)
+	}
+	return nil
+}
+
+// CheckContentEncryptionAlgorithm is the same as CheckSignatureAlgorithm,
+// for content encryption algorithms
+func CheckContentEncryptionAlgorithm(alg jwa.ContentEncryptionAlgorithm) error {
+	if !Enabled() {
+		return nil
+	}
+	if _, ok := approvedContentEncryptionAlgorithms[alg]; !ok {
+		return newError(`content encryption algorithm`, alg.String(), ``)
+	}
+	return nil
+}
+
+// CheckCurve returns an error if FIPS mode is enabled, and crv is
+// neither one of the NIST curves nor Ed25519
+func CheckCurve(crv jwa.EllipticCurveAlgorithm) error {
+	if !Enabled() {
+		return nil
+	}
+	if _, ok := approvedCurves[crv]; !ok {
+		return newError(`curve`, crv.String(), ``)
+	}
+	return nil
+}
+
+// CheckKeyCurve is the same as CheckCurve, for the curve of the
+// ECDSA or X25519 key given. Other types of keys are ignored
+func CheckKeyCurve(key interface{}) error {
+	if !Enabled() {
+		return nil
+	}
+	switch key := key.(type) {
+	case x25519.PublicKey, x25519.PrivateKey:
+		return CheckCurve(jwa.X25519)
+	case *ecdsa.PublicKey:
+		return checkECDSACurve(key)
+	case *ecdsa.PrivateKey:
+		return checkECDSACurve(&key.PublicKey)
+	}
+	return nil
+}
+
+func checkECDSACurve(key *ecdsa.PublicKey) error {
+	switch key.Curve {
+	case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		return nil
+	}
+	return newError(`curve`, key.Curve.Params().Name, ``)
+}
+
+// CheckHMACKey returns an error if FIPS mode is enabled, and the
+// HMAC key for alg is shorter than MinHMACKeySize
+func CheckHMACKey(alg jwa.SignatureAlgorithm, size int) error {
+	if !Enabled() {
+		return nil
+	}
+	if size < MinHMACKeySize {
+		return newError(`signature algorithm`, alg.String(), fmt.Sprintf(`key must be at least %d bytes long, got %d`, MinHMACKeySize, size))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package fips_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/fips"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecks(t *testing.T) {
+	// DO NOT MAKE THIS TEST PARALLEL. This test uses features with global side effects
+	defer fips.SetEnabled(fips.Enabled())
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+		return
+	}
+	_, x25519key, err := x25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err, `x25519.GenerateKey should succeed`) {
+		return
+	}
+
+	fips.SetEnabled(false)
+	if !assert.NoError(t, fips.CheckSignatureAlgorithm(jwa.NoSignature), `checks should pass when disabled`) {
+		return
+	}
+
+	fips.SetEnabled(true)
+	approved := []error{
+		fips.CheckSignatureAlgorithm(jwa.ES256),
+		fips.CheckKeyEncryptionAlgorithm(jwa.RSA_OAEP_256),
+		fips.CheckContentEncryptionAlgorithm(jwa.A256GCM),
+		fips.CheckKeyCurve(p256),
+		fips.CheckKeyCurve(&p256.PublicKey),
+		fips.CheckHMACKey(jwa.HS256, fips.MinHMACKeySize),
+	}
+	for i, err := range approved {
+		if !assert.NoError(t, err, `check #%d should pass`, i) {
+			return
+		}
+	}
+
+	rejected := []error{
+		fips.CheckSignatureAlgorithm(jwa.NoSignature),
+		fips.CheckSignatureAlgorithm(jwa.SignatureAlgorithm(`ES256K`)),
+		fips.CheckKeyEncryptionAlgorithm(jwa.RSA1_5),
+		fips.CheckContentEncryptionAlgorithm(jwa.ContentEncryptionAlgorithm(`C20P`)),
+		fips.CheckCurve(jwa.EllipticCurveAlgorithm(`secp256k1`)),
+		fips.CheckKeyCurve(x25519key),
+		fips.CheckHMACKey(jwa.HS256, fips.MinHMACKeySize-1),
+	}
+	for i, err := range rejected {
+		if !assert.True(t, errors.Is(err, fips.ErrNotApproved), `check #%d should fail`, i) {
+			return
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build go1.24
+// +build go1.24
+
+package fips
+
+import "crypto/fips140"
+
+// moduleEnabled returns true if the Go Cryptographic Module is running
+// in FIPS 140-3 mode (e.g. GODEBUG=fips140=on), in which case FIPS mode
+// is enabled by default
+func moduleEnabled() bool {
+	return fips140.Enabled()
+}
@@ -0,0 +1,6 @@
+//go:build !jwx_fips
+// +build !jwx_fips
+
+package fips
+
+const buildTag = false
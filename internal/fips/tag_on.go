@@ -0,0 +1,8 @@
+//go:build jwx_fips
+// +build jwx_fips
+
+package fips
+
+// buildTag is true when compiled with the jwx_fips build tag, which
+// enables FIPS mode by default
+const buildTag = true
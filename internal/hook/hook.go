@@ -0,0 +1,141 @@
+// Package hook implements the machinery behind jwx.WithHook(), which
+// notifies users of operations performed by the library
+package hook
+
+import (
+	"sync"
+	"time"
+)
+
+// Kind describes the type of operation being reported
+type Kind int
+
+const (
+	InvalidKind Kind = iota
+	FetchKind
+	SignKind
+	VerifyKind
+	EncryptKind
+	DecryptKind
+)
+
+func (k Kind) String() string {
+	switch k {
+	case FetchKind:
+		return "fetch"
+	case SignKind:
+		return "sign"
+	case VerifyKind:
+		return "verify"
+	case EncryptKind:
+		return "encrypt"
+	case DecryptKind:
+		return "decrypt"
+	default:
+		return "invalid"
+	}
+}
+
+// Event describes a single operation performed by the library.
+// Fields that do not apply to the operation are left empty.
+type Event struct {
+	// Kind is the type of the operation
+	Kind Kind
+	// Algorithm is the signature or key encryption algorithm used
+	Algorithm string
+	// KeyID is the key ID of the key used, if known
+	KeyID string
+	// URL is the URL of the resource being fetched
+	URL string
+	// Start is the time the operation started
+	Start time.Time
+	// Duration is the time it took to complete the operation
+	Duration time.Duration
+	// Err is the error that caused the operation to fail, or nil on success
+	Err error
+}
+
+// Hook is notified of each operation after it has completed.
+// Handle is called synchronously from the goroutine performing the
+// operation, so it should return quickly.
+type Hook interface {
+	Handle(Event)
+}
+
+// HookFunc is a function that implements the Hook interface
+type HookFunc func(Event)
+
+func (f HookFunc) Handle(ev Event) {
+	f(ev)
+}
+
+var muGlobal sync.RWMutex
+var global Hook
+
+// Set sets the global hook. Passing nil disables notifications
+func Set(h Hook) {
+	muGlobal.Lock()
+	global = h
+	muGlobal.Unlock()
+}
+
+func get() Hook {
+	muGlobal.RLock()
+	defer muGlobal.RUnlock()
+	return global
+}
+
+// Span records an operation in progress. All methods can be called on
+// a nil *Span, which is what Start returns when no hook is set, so that
+// callers need not check for it.
+type Span struct {
+	hook  Hook
+	event Event
+}
+
+// Start starts recording an operation of the given kind. If no hook
+// has been set, it returns nil
+func Start(kind Kind) *Span {
+	h := get()
+	if h == nil {
+		return nil
+	}
+	return &Span{
+		hook: h,
+		event: Event{
+			Kind:  kind,
+			Start: time.Now(),
+		},
+	}
+}
+
+func (s *Span) SetAlgorithm(v string) {
+	if s == nil {
+		return
+	}
+	s.event.Algorithm = v
+}
+
+func (s *Span) SetKeyID(v string) {
+	if s == nil {
+		return
+	}
+	s.event.KeyID = v
+}
+
+func (s *Span) SetURL(v string) {
+	if s == nil {
+		return
+	}
+	s.event.URL = v
+}
+
+// End records the outcome of the operation, and notifies the hook
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.event.Duration = time.Since(s.event.Start)
+	s.event.Err = err
+	s.hook.Handle(s.event)
+}
@@ -23,28 +23,32 @@ func Engine() string {
 // NewDecoder respects the values specified in DecoderSettings,
 // and creates a Decoder that has certain features turned on/off
 func NewDecoder(r io.Reader) *json.Decoder {
-	dec := json.NewDecoder(r)
-
 	muGlobalConfig.RLock()
+	v := useNumber
+	muGlobalConfig.RUnlock()
+
+	return newDecoder(r, v)
+}
+
+func newDecoder(r io.Reader, useNumber bool) *json.Decoder {
+	dec := json.NewDecoder(r)
 	if useNumber {
 		dec.UseNumber()
 	}
-	muGlobalConfig.RUnlock()
-
 	return dec
 }
 
-// NewEncoder is just a proxy for "encoding/json".NewEncoder
+// NewEncoder is just a proxy for "github.com/goccy/go-json".NewEncoder
 func NewEncoder(w io.Writer) *json.Encoder {
 	return json.NewEncoder(w)
 }
 
-// Marshal is just a proxy for "encoding/json".Marshal
+// Marshal is just a proxy for "github.com/goccy/go-json".Marshal
 func Marshal(v interface{}) ([]byte, error) {
 	return json.Marshal(v)
 }
 
-// MarshalIndent is just a proxy for "encoding/json".MarshalIndent
+// MarshalIndent is just a proxy for "github.com/goccy/go-json".MarshalIndent
 func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
 	return json.MarshalIndent(v, prefix, indent)
 }
@@ -2,6 +2,7 @@ package json
 
 import (
 	"bytes"
+	"io"
 	"sync"
 	"sync/atomic"
 
@@ -103,3 +104,73 @@ func NewDecodeCtx(r *Registry) DecodeCtx {
 func (dc *decodeCtx) Registry() *Registry {
 	return dc.registry
 }
+
+// UseNumberDecodeCtx is a DecodeCtx that also specifies whether numbers
+// should be decoded as Number, overriding the values specified in
+// DecoderSettings
+type UseNumberDecodeCtx interface {
+	DecodeCtx
+	UseNumber() bool
+}
+
+type useNumberDecodeCtx struct {
+	decodeCtx
+	useNumber bool
+}
+
+func NewUseNumberDecodeCtx(r *Registry, useNumber bool) DecodeCtx {
+	return &useNumberDecodeCtx{
+		decodeCtx: decodeCtx{registry: r},
+		useNumber: useNumber,
+	}
+}
+
+func (dc *useNumberDecodeCtx) UseNumber() bool {
+	return dc.useNumber
+}
+
+// NewDecoderCtx creates a Decoder just like NewDecoder, except that if
+// dc is a UseNumberDecodeCtx, its setting takes precedence over the
+// values specified in DecoderSettings
+func NewDecoderCtx(dc DecodeCtx, r io.Reader) *Decoder {
+	if v, ok := dc.(UseNumberDecodeCtx); ok {
+		return newDecoder(r, v.UseNumber())
+	}
+	return NewDecoder(r)
+}
+
+// CheckDepth scans the JSON document in buf, and returns an error if
+// objects and/or arrays are nested more than max levels deep.
+// The document is not validated: this is only meant to be a cheap
+// guard against pathologically nested input before it is decoded.
+func CheckDepth(buf []byte, max int) error {
+	var depth int
+	var inString bool
+	var escaped bool
+	for _, c := range buf {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > max {
+				return errors.Errorf(`JSON nesting depth exceeds maximum of %d`, max)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
@@ -8,8 +8,9 @@ import (
 )
 
 type Registry struct {
-	mu   *sync.RWMutex
-	data map[string]reflect.Type
+	mu       *sync.RWMutex
+	data     map[string]reflect.Type
+	fallback *Registry
 }
 
 func NewRegistry() *Registry {
@@ -19,6 +20,14 @@ func NewRegistry() *Registry {
 	}
 }
 
+// NewRegistryWithFallback creates a new Registry that consults the
+// fallback registry for fields that have not been registered in itself.
+func NewRegistryWithFallback(fallback *Registry) *Registry {
+	r := NewRegistry()
+	r.fallback = fallback
+	return r
+}
+
 func (r *Registry) Register(name string, object interface{}) {
 	if object == nil {
 		r.mu.Lock()
@@ -35,9 +44,14 @@ func (r *Registry) Register(name string, object interface{}) {
 
 func (r *Registry) Decode(dec *Decoder, name string) (interface{}, error) {
 	r.mu.RLock()
-	defer r.mu.RUnlock()
+	typ, ok := r.data[name]
+	r.mu.RUnlock()
 
-	if typ, ok := r.data[name]; ok {
+	if !ok && r.fallback != nil {
+		return r.fallback.Decode(dec, name)
+	}
+
+	if ok {
 		ptr := reflect.New(typ).Interface()
 		if err := dec.Decode(ptr); err != nil {
 			return nil, errors.Wrapf(err, `failed to decode field %s`, name)
@@ -51,3 +65,21 @@ func (r *Registry) Decode(dec *Decoder, name string) (interface{}, error) {
 	}
 	return decoded, nil
 }
+
+// Fields returns the types of all registered fields, including those
+// registered in the fallback registry (unless overridden)
+func (r *Registry) Fields() map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	if r.fallback != nil {
+		for name, typ := range r.fallback.Fields() {
+			fields[name] = typ
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name, typ := range r.data {
+		fields[name] = typ
+	}
+	return fields
+}
@@ -22,14 +22,18 @@ func Engine() string {
 // NewDecoder respects the values specified in DecoderSettings,
 // and creates a Decoder that has certain features turned on/off
 func NewDecoder(r io.Reader) *json.Decoder {
-	dec := json.NewDecoder(r)
-
 	muGlobalConfig.RLock()
+	v := useNumber
+	muGlobalConfig.RUnlock()
+
+	return newDecoder(r, v)
+}
+
+func newDecoder(r io.Reader, useNumber bool) *json.Decoder {
+	dec := json.NewDecoder(r)
 	if useNumber {
 		dec.UseNumber()
 	}
-	muGlobalConfig.RUnlock()
-
 	return dec
 }
 
@@ -6,6 +6,7 @@ import (
 	"crypto/rsa"
 
 	"github.com/lestrrat-go/blackmagic"
+	"github.com/lestrrat-go/jwx/internal/rawkey"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ed25519"
@@ -40,7 +41,14 @@ func RSAPrivateKey(dst, src interface{}) error {
 // `dst` should be a pointer to a non-zero rsa.PublicKey.
 // `src` may be rsa.PublicKey, *rsa.PublicKey, or a jwk.Key
 func RSAPublicKey(dst, src interface{}) error {
-	if jwkKey, ok := src.(jwk.Key); ok {
+	// keys that cache their raw key can be used without copying it, as
+	// none of the callers of this function modify the key
+	if raw, ok, err := rawkey.Get(src); ok {
+		if err != nil {
+			return errors.Wrapf(err, `failed to produce rsa.PublicKey from %T`, src)
+		}
+		src = raw
+	} else if jwkKey, ok := src.(jwk.Key); ok {
 		var raw rsa.PublicKey
 		if err := jwkKey.Raw(&raw); err != nil {
 			return errors.Wrapf(err, `failed to produce rsa.PublicKey from %T`, src)
@@ -87,7 +95,12 @@ func ECDSAPrivateKey(dst, src interface{}) error {
 // ECDSAPublicKey assigns src to dst, converting its type from a
 // non-pointer to a pointer
 func ECDSAPublicKey(dst, src interface{}) error {
-	if jwkKey, ok := src.(jwk.Key); ok {
+	if raw, ok, err := rawkey.Get(src); ok {
+		if err != nil {
+			return errors.Wrapf(err, `failed to produce ecdsa.PublicKey from %T`, src)
+		}
+		src = raw
+	} else if jwkKey, ok := src.(jwk.Key); ok {
 		var raw ecdsa.PublicKey
 		if err := jwkKey.Raw(&raw); err != nil {
 			return errors.Wrapf(err, `failed to produce ecdsa.PublicKey from %T`, src)
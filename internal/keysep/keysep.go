@@ -0,0 +1,171 @@
+// Package keysep implements the machinery behind jwx.WithKeySeparation(),
+// which detects keys that are used both for signatures and for encryption
+package keysep
+
+import (
+	"crypto"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrKeySeparation is the error that all key separation violations match
+// via `errors.Is()`
+var ErrKeySeparation = errors.New(`key separation violation`)
+
+// Policy describes what happens when a violation is detected
+type Policy int32
+
+const (
+	// Ignore disables detection altogether
+	Ignore Policy = iota
+	// Warn reports violations to the warning handler, but lets the
+	// operation proceed
+	Warn
+	// Enforce makes the operation fail
+	Enforce
+)
+
+// Purpose describes what a key is being used for. The values
+// correspond to those of the "use" field in a JWK
+type Purpose string
+
+const (
+	Signature  Purpose = "sig"
+	Encryption Purpose = "enc"
+)
+
+// Key is the subset of jwk.Key that is required to identify a key
+type Key interface {
+	Thumbprint(crypto.Hash) ([]byte, error)
+	KeyUsage() string
+}
+
+// Tracker records the purpose that each key was first used for, so
+// that keys used both for signatures and for encryption can be
+// detected. The keys are identified by the thumbprint of their
+// (public) key. A Tracker is created and owned by the user, who
+// decides how long it lives: the record grows with the number of
+// distinct keys used until Reset is called.
+type Tracker struct {
+	mu   sync.Mutex
+	used map[string]Purpose
+}
+
+// NewTracker creates a new, empty Tracker
+func NewTracker() *Tracker {
+	return &Tracker{used: make(map[string]Purpose)}
+}
+
+// Len returns the number of keys recorded
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.used)
+}
+
+// Reset discards the record of the keys used so far
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	t.used = make(map[string]Purpose)
+	t.mu.Unlock()
+}
+
+// record records that the key identified by tp is used for purpose,
+// and returns the purpose that it was previously used for, if any
+func (t *Tracker) record(tp string, purpose Purpose) (Purpose, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if prev, ok := t.used[tp]; ok {
+		return prev, true
+	}
+	t.used[tp] = purpose
+	return purpose, false
+}
+
+var policy int32
+
+var muGlobal sync.RWMutex
+var tracker *Tracker
+var warnHandler func(error)
+
+// SetPolicy sets the global policy
+func SetPolicy(p Policy) {
+	atomic.StoreInt32(&policy, int32(p))
+}
+
+// SetTracker sets the Tracker that records the keys used. Passing nil
+// disables tracking, in which case only the "use" field of jwk.Key
+// objects is checked
+func SetTracker(t *Tracker) {
+	muGlobal.Lock()
+	tracker = t
+	muGlobal.Unlock()
+}
+
+// SetWarningHandler sets the function that receives the violations
+// when the policy is Warn. Passing nil restores the default, which
+// discards them
+func SetWarningHandler(fn func(error)) {
+	muGlobal.Lock()
+	warnHandler = fn
+	muGlobal.Unlock()
+}
+
+// Enabled returns true if violations should be detected
+func Enabled() bool {
+	return Policy(atomic.LoadInt32(&policy)) != Ignore
+}
+
+// Check handles the violation according to the policy if the key
+// carries a "use" field for a different purpose, or if the Tracker
+// has recorded that the key was previously used for a different
+// purpose. Otherwise the use of the key is recorded. An error is
+// only returned when the policy is Enforce.
+func Check(purpose Purpose, key Key) error {
+	p := Policy(atomic.LoadInt32(&policy))
+	if p == Ignore {
+		return nil
+	}
+
+	muGlobal.RLock()
+	t := tracker
+	fn := warnHandler
+	muGlobal.RUnlock()
+
+	err := check(t, purpose, key)
+	if err == nil {
+		return nil
+	}
+
+	if p == Enforce {
+		return err
+	}
+
+	if fn != nil {
+		fn(err)
+	}
+	return nil
+}
+
+func check(t *Tracker, purpose Purpose, key Key) error {
+	if use := key.KeyUsage(); use != "" && use != string(purpose) {
+		return errors.Wrapf(ErrKeySeparation, `key with "use" of %q cannot be used for %q`, use, purpose)
+	}
+
+	if t == nil {
+		return nil
+	}
+
+	tp, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		// keys that we can't identify can't be tracked
+		return nil //nolint:nilerr
+	}
+
+	if prev, loaded := t.record(string(tp), purpose); loaded && prev != purpose {
+		return errors.Wrapf(ErrKeySeparation, `key already used for %q cannot be used for %q`, prev, purpose)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package limits holds the process-wide limits imposed on untrusted
+// input, as configured via jwx.Settings(). A value of 0 means that
+// the corresponding limit is not enforced.
+package limits
+
+import (
+	"sync/atomic"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/pkg/errors"
+)
+
+var maxInputSize int64
+var maxDepth int64
+var maxSignatures int64
+var maxRecipients int64
+var maxHeaderCount int64
+
+func SetMaxInputSize(v int64) {
+	atomic.StoreInt64(&maxInputSize, v)
+}
+
+func SetMaxDepth(v int) {
+	atomic.StoreInt64(&maxDepth, int64(v))
+}
+
+func SetMaxSignatures(v int) {
+	atomic.StoreInt64(&maxSignatures, int64(v))
+}
+
+func SetMaxRecipients(v int) {
+	atomic.StoreInt64(&maxRecipients, int64(v))
+}
+
+func SetMaxHeaderCount(v int) {
+	atomic.StoreInt64(&maxHeaderCount, int64(v))
+}
+
+// MaxInputSize returns the maximum size of the input in bytes
+func MaxInputSize() int64 {
+	return atomic.LoadInt64(&maxInputSize)
+}
+
+// MaxDepth returns the maximum nesting depth of JSON input
+func MaxDepth() int {
+	return int(atomic.LoadInt64(&maxDepth))
+}
+
+// MaxHeaderCount returns the maximum number of fields in a JOSE header
+func MaxHeaderCount() int {
+	return int(atomic.LoadInt64(&maxHeaderCount))
+}
+
+// CheckInputSize returns an error if n exceeds the maximum input size
+func CheckInputSize(n int) error {
+	if max := MaxInputSize(); max > 0 && int64(n) > max {
+		return errors.Errorf(`input size exceeds maximum of %d bytes`, max)
+	}
+	return nil
+}
+
+// CheckDepth returns an error if the JSON document in buf is nested
+// deeper than the maximum nesting depth
+func CheckDepth(buf []byte) error {
+	if max := MaxDepth(); max > 0 {
+		return json.CheckDepth(buf, max)
+	}
+	return nil
+}
+
+// CheckSignatures returns an error if n exceeds the maximum number
+// of signatures in a JWS message
+func CheckSignatures(n int) error {
+	if max := int(atomic.LoadInt64(&maxSignatures)); max > 0 && n > max {
+		return errors.Errorf(`number of signatures exceeds maximum of %d`, max)
+	}
+	return nil
+}
+
+// CheckRecipients returns an error if n exceeds the maximum number
+// of recipients in a JWE message
+func CheckRecipients(n int) error {
+	if max := int(atomic.LoadInt64(&maxRecipients)); max > 0 && n > max {
+		return errors.Errorf(`number of recipients exceeds maximum of %d`, max)
+	}
+	return nil
+}
+
+// CheckHeaderCount returns an error if n exceeds the maximum number
+// of fields in a JOSE header
+func CheckHeaderCount(n int) error {
+	if max := MaxHeaderCount(); max > 0 && n > max {
+		return errors.Errorf(`number of header fields exceeds maximum of %d`, max)
+	}
+	return nil
+}
+
+// CheckHeader returns an error if the JSON object in buf, which is
+// a JOSE header, has more fields than the maximum header count, or
+// is nested deeper than the maximum nesting depth
+func CheckHeader(buf []byte) error {
+	if err := CheckDepth(buf); err != nil {
+		return err
+	}
+
+	if MaxHeaderCount() <= 0 {
+		return nil
+	}
+	return CheckHeaderCount(countFields(buf))
+}
+
+// countFields counts the number of members in the top-level JSON
+// object in buf. Like json.CheckDepth, the document is not validated
+func countFields(buf []byte) int {
+	var count int
+	var depth int
+	var inString bool
+	var escaped bool
+	for _, c := range buf {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case ':':
+			if depth == 1 {
+				count++
+			}
+		}
+	}
+	return count
+}
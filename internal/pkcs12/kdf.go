@@ -0,0 +1,89 @@
+package pkcs12
+
+import (
+	"hash"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// Purposes of keys derived by pbkdf, as described in RFC 7292 Appendix B.3
+const (
+	kdfEncryptionKey = 1
+	kdfIV            = 2
+	kdfMACKey        = 3
+)
+
+// bmpString encodes s as a null terminated BMPString (UCS-2), which is
+// how RFC 7292 Appendix B.1 requires passwords to be formatted
+func bmpString(s string) ([]byte, error) {
+	ret := make([]byte, 0, 2*len(s)+2)
+	for _, r := range s {
+		if t, _ := utf16.EncodeRune(r); t != 0xfffd {
+			return nil, errors.New(`password contains characters outside of the Basic Multilingual Plane`)
+		}
+		ret = append(ret, byte(r>>8), byte(r))
+	}
+	return append(ret, 0, 0), nil
+}
+
+// pbkdf derives `size` bytes from the password and the salt, as described
+// in RFC 7292 Appendix B.2. `password` must already be a BMPString.
+func pbkdf(h func() hash.Hash, v int, salt, password []byte, iterations int, id byte, size int) []byte {
+	u := h().Size()
+
+	// 1. D = v copies of id
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+
+	// 2.-4. I = S || P, where S and P are the salt and the password
+	// repeated to fill a multiple of v bytes
+	fill := func(src []byte) []byte {
+		if len(src) == 0 {
+			return nil
+		}
+		dst := make([]byte, v*((len(src)+v-1)/v))
+		for i := range dst {
+			dst[i] = src[i%len(src)]
+		}
+		return dst
+	}
+	i := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		// 6.a A = H^r(D || I)
+		hh := h()
+		hh.Write(d)
+		hh.Write(i)
+		a := hh.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			hh = h()
+			hh.Write(a)
+			a = hh.Sum(nil)
+		}
+		out = append(out, a...)
+		if len(out) >= size {
+			break
+		}
+
+		// 6.b B = A repeated to fill v bytes
+		b := make([]byte, v)
+		for j := range b {
+			b[j] = a[j%u]
+		}
+
+		// 6.c I_j = (I_j + B + 1) mod 2^(8v) for each v byte block of I
+		for j := 0; j < len(i); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				carry += int(i[j+k]) + int(b[k])
+				i[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return out[:size]
+}
@@ -0,0 +1,583 @@
+// Package pkcs12 implements decoding and encoding of PKCS#12 (.p12/.pfx)
+// files containing a single private key and its certificate chain, as
+// described in RFC 7292.
+//
+// Files protected with PBES2 (e.g. those created by OpenSSL 3) are decoded
+// here. Files using the legacy PKCS#12 password based encryption schemes
+// are decoded using golang.org/x/crypto/pkcs12. Files are always encoded
+// using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC, and a SHA-256 MAC.
+package pkcs12
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"hash"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	xpkcs12 "golang.org/x/crypto/pkcs12"
+)
+
+// ErrIncorrectPassword is returned when the MAC of the file could not be
+// verified, which is usually caused by an incorrect password
+var ErrIncorrectPassword = errors.New(`pkcs12: decryption password incorrect`)
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+
+	oidLegacyPBEPrefix = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1}
+	oidPBES2           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1    = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256  = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384  = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512  = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	asn1NULL = asn1.RawValue{Tag: asn1.TagNull}
+)
+
+// errLegacyEncryption is returned by decrypt for the legacy PKCS#12
+// encryption schemes, which are handled by decodeLegacy
+var errLegacyEncryption = errors.New(`legacy PKCS#12 encryption scheme`)
+
+// Parameters used when encoding. These match the defaults of OpenSSL 3
+const (
+	encodeIterations = 2048
+	encodeSaltSize   = 16
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	AlgorithmIdentifier pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// explicit wraps der in a [0] EXPLICIT tag. encoding/asn1 ignores the
+// tags of asn1.RawValue fields when marshaling, so it has to be done by hand
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func unmarshal(in []byte, out interface{}) error {
+	trailing, err := asn1.Unmarshal(in, out)
+	if err != nil {
+		return err
+	}
+	if len(trailing) != 0 {
+		return errors.New(`trailing data found`)
+	}
+	return nil
+}
+
+// Decode extracts the private key and the certificates from a PKCS#12
+// file. The certificates are returned in the order in which they
+// appear in the file.
+func Decode(data []byte, password string) (interface{}, []*x509.Certificate, error) {
+	key, certs, err := decode(data, password)
+	if err == errLegacyEncryption {
+		return decodeLegacy(data, password)
+	}
+	return key, certs, err
+}
+
+func decode(data []byte, password string) (interface{}, []*x509.Certificate, error) {
+	bmpPassword, err := bmpString(password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pfx pfxPdu
+	if err := unmarshal(data, &pfx); err != nil {
+		return nil, nil, errors.Wrap(err, `failed to parse PKCS#12 data`)
+	}
+	if pfx.Version != 3 {
+		return nil, nil, errors.Errorf(`unsupported PKCS#12 version %d`, pfx.Version)
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, nil, errors.New(`only password-protected PKCS#12 files are supported`)
+	}
+
+	var authSafe []byte
+	if err := unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, errors.Wrap(err, `failed to parse authenticated safe`)
+	}
+
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) == 0 {
+		return nil, nil, errors.New(`PKCS#12 files without a MAC are not supported`)
+	}
+	if err := verifyMAC(&pfx.MacData, authSafe, bmpPassword); err != nil {
+		// Some implementations derive the MAC key from an empty byte
+		// sequence instead of an empty BMPString
+		if password != "" || verifyMAC(&pfx.MacData, authSafe, nil) != nil {
+			return nil, nil, err
+		}
+	}
+
+	var contents []contentInfo
+	if err := unmarshal(authSafe, &contents); err != nil {
+		return nil, nil, errors.Wrap(err, `failed to parse authenticated safe`)
+	}
+
+	var key interface{}
+	var certs []*x509.Certificate
+	for _, ci := range contents {
+		var safeContents []byte
+		switch {
+		case ci.ContentType.Equal(oidDataContentType):
+			if err := unmarshal(ci.Content.Bytes, &safeContents); err != nil {
+				return nil, nil, errors.Wrap(err, `failed to parse safe contents`)
+			}
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var ed encryptedData
+			if err := unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, errors.Wrap(err, `failed to parse encrypted data`)
+			}
+			safeContents, err = decrypt(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, password)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, errors.Errorf(`unsupported content type %s`, ci.ContentType)
+		}
+
+		var bags []safeBag
+		if err := unmarshal(safeContents, &bags); err != nil {
+			return nil, nil, errors.Wrap(err, `failed to parse safe bags`)
+		}
+
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				if key != nil {
+					return nil, nil, errors.New(`PKCS#12 files with more than one private key are not supported`)
+				}
+				der := bag.Value.Bytes
+				if bag.ID.Equal(oidPKCS8ShroudedKeyBag) {
+					var info encryptedPrivateKeyInfo
+					if err := unmarshal(bag.Value.Bytes, &info); err != nil {
+						return nil, nil, errors.Wrap(err, `failed to parse encrypted private key`)
+					}
+					der, err = decrypt(info.AlgorithmIdentifier, info.EncryptedData, password)
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+				key, err = x509.ParsePKCS8PrivateKey(der)
+				if err != nil {
+					return nil, nil, errors.Wrap(err, `failed to parse private key`)
+				}
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if err := unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, errors.Wrap(err, `failed to parse certificate bag`)
+				}
+				if !cb.ID.Equal(oidCertTypeX509) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, errors.Wrap(err, `failed to parse certificate`)
+				}
+				certs = append(certs, cert)
+			}
+		}
+	}
+
+	if key == nil {
+		return nil, nil, errors.New(`private key not found`)
+	}
+	return key, certs, nil
+}
+
+// decodeLegacy decodes files that use the legacy PKCS#12 encryption
+// schemes (e.g. pbeWithSHAAnd40BitRC2-CBC) using golang.org/x/crypto/pkcs12
+func decodeLegacy(data []byte, password string) (interface{}, []*x509.Certificate, error) {
+	blocks, err := xpkcs12.ToPEM(data, password)
+	if err != nil {
+		if err == xpkcs12.ErrIncorrectPassword {
+			return nil, nil, ErrIncorrectPassword
+		}
+		return nil, nil, errors.Wrap(err, `failed to decode PKCS#12 data`)
+	}
+
+	var key interface{}
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		switch block.Type {
+		case "PRIVATE KEY":
+			if key != nil {
+				return nil, nil, errors.New(`PKCS#12 files with more than one private key are not supported`)
+			}
+			// x/crypto/pkcs12 encodes RSA keys in PKCS#1, and EC keys in SEC 1
+			if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+				key = k
+				continue
+			}
+			k, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, `failed to parse private key`)
+			}
+			key = k
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, `failed to parse certificate`)
+			}
+			certs = append(certs, cert)
+		}
+	}
+	if key == nil {
+		return nil, nil, errors.New(`private key not found`)
+	}
+	return key, certs, nil
+}
+
+func hashFor(oid asn1.ObjectIdentifier) (func() hash.Hash, int, bool) {
+	switch {
+	case oid.Equal(oidSHA1), oid.Equal(oidHMACWithSHA1):
+		return sha1.New, 64, true
+	case oid.Equal(oidSHA256), oid.Equal(oidHMACWithSHA256):
+		return sha256.New, 64, true
+	case oid.Equal(oidSHA384), oid.Equal(oidHMACWithSHA384):
+		return sha512.New384, 128, true
+	case oid.Equal(oidSHA512), oid.Equal(oidHMACWithSHA512):
+		return sha512.New, 128, true
+	}
+	return nil, 0, false
+}
+
+func computeMAC(h func() hash.Hash, v int, message, salt, password []byte, iterations int) []byte {
+	key := pbkdf(h, v, salt, password, iterations, kdfMACKey, h().Size())
+	mac := hmac.New(h, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+func verifyMAC(md *macData, message, password []byte) error {
+	h, v, ok := hashFor(md.Mac.Algorithm.Algorithm)
+	if !ok {
+		return errors.Errorf(`unsupported MAC algorithm %s`, md.Mac.Algorithm.Algorithm)
+	}
+	if !hmac.Equal(md.Mac.Digest, computeMAC(h, v, message, md.MacSalt, password, md.Iterations)) {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+func decrypt(alg pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	oid := alg.Algorithm
+	if len(oid) == len(oidLegacyPBEPrefix)+1 && oid[:len(oidLegacyPBEPrefix)].Equal(oidLegacyPBEPrefix) {
+		return nil, errLegacyEncryption
+	}
+	if !oid.Equal(oidPBES2) {
+		return nil, errors.Errorf(`unsupported encryption algorithm %s`, oid)
+	}
+
+	var params pbes2Params
+	if err := unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+		return nil, errors.Wrap(err, `failed to parse PBES2 parameters`)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, errors.Errorf(`unsupported key derivation function %s`, params.KeyDerivationFunc.Algorithm)
+	}
+	var kdfParams pbkdf2Params
+	if err := unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, errors.Wrap(err, `failed to parse PBKDF2 parameters`)
+	}
+	prf := sha1.New
+	if len(kdfParams.PRF.Algorithm) > 0 {
+		h, _, ok := hashFor(kdfParams.PRF.Algorithm)
+		if !ok {
+			return nil, errors.Errorf(`unsupported PBKDF2 pseudorandom function %s`, kdfParams.PRF.Algorithm)
+		}
+		prf = h
+	}
+
+	var keySize int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keySize = 16
+	case scheme.Equal(oidAES192CBC):
+		keySize = 24
+	case scheme.Equal(oidAES256CBC):
+		keySize = 32
+	default:
+		return nil, errors.Errorf(`unsupported encryption scheme %s`, scheme)
+	}
+	var iv []byte
+	if err := unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, errors.Wrap(err, `failed to parse initialization vector`)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, errors.Errorf(`invalid initialization vector size %d`, len(iv))
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New(`invalid encrypted data size`)
+	}
+
+	key := pbkdf2.Key([]byte(password), kdfParams.Salt, kdfParams.Iterations, keySize, prf)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create AES cipher`)
+	}
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New(`failed to decrypt: invalid padding`)
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+func encrypt(rnd io.Reader, data []byte, password string) (pkix.AlgorithmIdentifier, []byte, error) {
+	var alg pkix.AlgorithmIdentifier
+
+	salt := make([]byte, encodeSaltSize)
+	if _, err := io.ReadFull(rnd, salt); err != nil {
+		return alg, nil, errors.Wrap(err, `failed to generate salt`)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rnd, iv); err != nil {
+		return alg, nil, errors.Wrap(err, `failed to generate initialization vector`)
+	}
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: encodeIterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1NULL},
+	})
+	if err != nil {
+		return alg, nil, errors.Wrap(err, `failed to marshal PBKDF2 parameters`)
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return alg, nil, errors.Wrap(err, `failed to marshal initialization vector`)
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return alg, nil, errors.Wrap(err, `failed to marshal PBES2 parameters`)
+	}
+
+	key := pbkdf2.Key([]byte(password), salt, encodeIterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return alg, nil, errors.Wrap(err, `failed to create AES cipher`)
+	}
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	encrypted := make([]byte, len(data)+padding)
+	copy(encrypted, data)
+	copy(encrypted[len(data):], bytes.Repeat([]byte{byte(padding)}, padding))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	alg = pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}
+	return alg, encrypted, nil
+}
+
+// Encode creates a PKCS#12 file containing the private key and the
+// certificates. If there are certificates, the first one must be the
+// certificate for the private key.
+func Encode(key interface{}, certs []*x509.Certificate, password string) ([]byte, error) {
+	return encode(rand.Reader, key, certs, password)
+}
+
+func encode(rnd io.Reader, key interface{}, certs []*x509.Certificate, password string) ([]byte, error) {
+	bmpPassword, err := bmpString(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes []pkcs12Attribute
+	if len(certs) > 0 {
+		// The localKeyId attribute links the private key to its certificate
+		sum := sha1.Sum(certs[0].Raw) //nolint:gosec
+		id, err := asn1.Marshal(sum[:])
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to marshal local key ID`)
+		}
+		attributes = []pkcs12Attribute{{
+			ID:    oidLocalKeyID,
+			Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: id},
+		}}
+	}
+
+	var certBags []safeBag
+	for i, cert := range certs {
+		der, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: cert.Raw})
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to marshal certificate bag`)
+		}
+		bag := safeBag{ID: oidCertBag, Value: explicit(der)}
+		if i == 0 {
+			bag.Attributes = attributes
+		}
+		certBags = append(certBags, bag)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal private key`)
+	}
+	keyAlg, encryptedKey, err := encrypt(rnd, keyDER, password)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encrypt private key`)
+	}
+	shrouded, err := asn1.Marshal(encryptedPrivateKeyInfo{AlgorithmIdentifier: keyAlg, EncryptedData: encryptedKey})
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal encrypted private key`)
+	}
+	keyBags := []safeBag{{ID: oidPKCS8ShroudedKeyBag, Value: explicit(shrouded), Attributes: attributes}}
+
+	var contents []contentInfo
+	if len(certBags) > 0 {
+		safeContents, err := asn1.Marshal(certBags)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to marshal certificates`)
+		}
+		alg, encrypted, err := encrypt(rnd, safeContents, password)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to encrypt certificates`)
+		}
+		ed, err := asn1.Marshal(encryptedData{
+			EncryptedContentInfo: encryptedContentInfo{
+				ContentType:                oidDataContentType,
+				ContentEncryptionAlgorithm: alg,
+				EncryptedContent:           encrypted,
+			},
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to marshal encrypted data`)
+		}
+		contents = append(contents, contentInfo{ContentType: oidEncryptedDataContentType, Content: explicit(ed)})
+	}
+
+	safeContents, err := asn1.Marshal(keyBags)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal private key bag`)
+	}
+	data, err := asn1.Marshal(safeContents)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal private key bag`)
+	}
+	contents = append(contents, contentInfo{ContentType: oidDataContentType, Content: explicit(data)})
+
+	authSafe, err := asn1.Marshal(contents)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal authenticated safe`)
+	}
+
+	macSalt := make([]byte, encodeSaltSize)
+	if _, err := io.ReadFull(rnd, macSalt); err != nil {
+		return nil, errors.Wrap(err, `failed to generate salt`)
+	}
+	pfx := pfxPdu{
+		Version: 3,
+		MacData: macData{
+			Mac: digestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1NULL},
+				Digest:    computeMAC(sha256.New, 64, authSafe, macSalt, bmpPassword, encodeIterations),
+			},
+			MacSalt:    macSalt,
+			Iterations: encodeIterations,
+		},
+	}
+	data, err = asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal authenticated safe`)
+	}
+	pfx.AuthSafe = contentInfo{ContentType: oidDataContentType, Content: explicit(data)}
+
+	buf, err := asn1.Marshal(pfx)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal PKCS#12 data`)
+	}
+	return buf, nil
+}
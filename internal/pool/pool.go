@@ -4,23 +4,98 @@ import (
 	"bytes"
 	"math/big"
 	"sync"
+	"sync/atomic"
 )
 
-var bytesBufferPool = sync.Pool{
-	New: allocBytesBuffer,
+// DefaultMaxBufferSize is the default capacity above which buffers are
+// not returned to the pool
+const DefaultMaxBufferSize = 64 * 1024
+
+// bufferClasses are the capacities that pooled buffers are grouped by.
+// A buffer is stored in the largest class that does not exceed its
+// capacity, so that buffers obtained from a class never need to grow
+// to hold the number of bytes the class was chosen for.
+var bufferClasses = [...]int{
+	1 << 10,
+	4 << 10,
+	16 << 10,
+	64 << 10,
+	256 << 10,
+	1 << 20,
+	4 << 20,
+}
+
+var bytesBufferPools [len(bufferClasses)]sync.Pool
+
+var maxBufferSize int64 = DefaultMaxBufferSize
+
+// SetMaxBufferSize sets the capacity above which buffers are discarded
+// instead of being returned to the pool. A value of 0 disables pooling
+// of buffers altogether.
+func SetMaxBufferSize(v int) {
+	if v < 0 {
+		v = 0
+	}
+	atomic.StoreInt64(&maxBufferSize, int64(v))
+}
+
+// MaxBufferSize returns the capacity above which buffers are discarded
+func MaxBufferSize() int {
+	return int(atomic.LoadInt64(&maxBufferSize))
 }
 
-func allocBytesBuffer() interface{} {
-	return &bytes.Buffer{}
+// bufferClass returns the index of the smallest class that can hold
+// n bytes, or -1 if n is larger than the largest class
+func bufferClass(n int) int {
+	for i, size := range bufferClasses {
+		if n <= size {
+			return i
+		}
+	}
+	return -1
 }
 
+// GetBytesBuffer returns an empty buffer, suitable for small payloads.
+// Use GetBytesBufferSize when the size of the data is known in advance.
 func GetBytesBuffer() *bytes.Buffer {
-	return bytesBufferPool.Get().(*bytes.Buffer)
+	return GetBytesBufferSize(0)
+}
+
+// GetBytesBufferSize returns an empty buffer that can hold at least
+// n bytes without growing.
+func GetBytesBufferSize(n int) *bytes.Buffer {
+	class := bufferClass(n)
+	if class < 0 || n > MaxBufferSize() {
+		// will not be pooled anyway, so allocate exactly what's needed
+		return bytes.NewBuffer(make([]byte, 0, n))
+	}
+
+	if v := bytesBufferPools[class].Get(); v != nil {
+		//nolint:forcetypeassert
+		return v.(*bytes.Buffer)
+	}
+	return bytes.NewBuffer(make([]byte, 0, bufferClasses[class]))
 }
 
+// ReleaseBytesBuffer returns the buffer to the pool. Buffers that have
+// grown beyond the maximum size set by SetMaxBufferSize are discarded,
+// so that occasional large payloads do not keep large buffers alive.
 func ReleaseBytesBuffer(b *bytes.Buffer) {
+	c := b.Cap()
+	if c > MaxBufferSize() {
+		return
+	}
+
+	class := 0
+	for i := len(bufferClasses) - 1; i > 0; i-- {
+		if c >= bufferClasses[i] {
+			class = i
+			break
+		}
+	}
+
 	b.Reset()
-	bytesBufferPool.Put(b)
+	bytesBufferPools[class].Put(b)
 }
 
 var bigIntPool = sync.Pool{
@@ -0,0 +1,41 @@
+package pool_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/pool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesBuffer(t *testing.T) {
+	t.Run("Capacity", func(t *testing.T) {
+		for _, n := range []int{0, 1, 1024, 1025, 100000, 8 << 20} {
+			buf := pool.GetBytesBufferSize(n)
+			assert.Equal(t, 0, buf.Len(), `buffer should be empty`)
+			assert.GreaterOrEqual(t, buf.Cap(), n, `buffer should hold %d bytes without growing`, n)
+			pool.ReleaseBytesBuffer(buf)
+		}
+	})
+	t.Run("Reset on release", func(t *testing.T) {
+		buf := pool.GetBytesBuffer()
+		buf.WriteString(`hello`)
+		pool.ReleaseBytesBuffer(buf)
+
+		buf = pool.GetBytesBuffer()
+		assert.Equal(t, 0, buf.Len(), `buffer should be empty`)
+		pool.ReleaseBytesBuffer(buf)
+	})
+	t.Run("SetMaxBufferSize", func(t *testing.T) {
+		defer pool.SetMaxBufferSize(pool.DefaultMaxBufferSize)
+
+		pool.SetMaxBufferSize(-1)
+		assert.Equal(t, 0, pool.MaxBufferSize(), `negative values should disable pooling`)
+
+		pool.SetMaxBufferSize(1 << 20)
+		assert.Equal(t, 1<<20, pool.MaxBufferSize(), `value should be set`)
+
+		buf := pool.GetBytesBufferSize(2 << 20)
+		assert.GreaterOrEqual(t, buf.Cap(), 2<<20, `buffers above the maximum size should still be usable`)
+		pool.ReleaseBytesBuffer(buf)
+	})
+}
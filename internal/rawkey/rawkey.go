@@ -0,0 +1,32 @@
+// Package rawkey allows packages within jwx to use the raw public keys
+// cached by jwk.Key objects without copying them.
+//
+// `(jwk.Key).Raw()` returns a copy of the cached key, so that users
+// cannot modify it. Internal consumers such as the jws verifiers never
+// modify the keys they are given, so they can use the cached key as is.
+package rawkey
+
+// Token is passed to `Shared.SharedRaw()`. As it can only be named from
+// within jwx, users cannot call `SharedRaw()` on keys
+type Token struct{}
+
+// Shared is implemented by jwk.Key objects that cache their raw key
+type Shared interface {
+	// SharedRaw returns the cached raw key, creating it if necessary.
+	// The returned value must not be modified
+	SharedRaw(Token) (interface{}, error)
+}
+
+// Get returns the raw key cached by src, if src is a Shared key.
+// The second return value is false if src does not cache its raw key
+func Get(src interface{}) (interface{}, bool, error) {
+	shared, ok := src.(Shared)
+	if !ok {
+		return nil, false, nil
+	}
+	raw, err := shared.SharedRaw(Token{})
+	if err != nil {
+		return nil, true, err
+	}
+	return raw, true, nil
+}
@@ -0,0 +1,63 @@
+// Package zeroize contains helpers to overwrite key material and
+// other sensitive values with zeros once they are no longer needed.
+//
+// Note that the Go runtime may have left copies of these values
+// elsewhere in memory (e.g. when a slice was grown, or a big.Int
+// was resized), so zeroization is a best-effort measure.
+package zeroize
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"math/big"
+
+	"github.com/lestrrat-go/jwx/x25519"
+)
+
+// Bytes overwrites the contents of b with zeros
+func Bytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// BigInt overwrites the storage of v with zeros, and sets it to 0
+func BigInt(v *big.Int) {
+	if v == nil {
+		return
+	}
+	words := v.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	v.SetInt64(0)
+}
+
+// Key overwrites the private parts of a raw key with zeros. Keys of
+// types that are not known to this function are left untouched
+func Key(key interface{}) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		BigInt(key.D)
+		for _, p := range key.Primes {
+			BigInt(p)
+		}
+		BigInt(key.Precomputed.Dp)
+		BigInt(key.Precomputed.Dq)
+		BigInt(key.Precomputed.Qinv)
+		for _, crt := range key.Precomputed.CRTValues {
+			BigInt(crt.Exp)
+			BigInt(crt.Coeff)
+			BigInt(crt.R)
+		}
+	case *ecdsa.PrivateKey:
+		BigInt(key.D)
+	case ed25519.PrivateKey:
+		Bytes(key)
+	case x25519.PrivateKey:
+		Bytes(key)
+	case []byte:
+		Bytes(key)
+	}
+}
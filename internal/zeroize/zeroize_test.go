@@ -0,0 +1,49 @@
+package zeroize_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/zeroize"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	t.Run("RSA", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if !assert.NoError(t, err, `rsa.GenerateKey should succeed`) {
+			return
+		}
+		zeroize.Key(key)
+		assert.Zero(t, key.D.Sign(), `D should be zero`)
+		for _, p := range key.Primes {
+			assert.Zero(t, p.Sign(), `primes should be zero`)
+		}
+		assert.Zero(t, key.Precomputed.Dp.Sign(), `Dp should be zero`)
+	})
+	t.Run("ECDSA", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if !assert.NoError(t, err, `ecdsa.GenerateKey should succeed`) {
+			return
+		}
+		zeroize.Key(key)
+		assert.Zero(t, key.D.Sign(), `D should be zero`)
+	})
+	t.Run("Ed25519", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if !assert.NoError(t, err, `ed25519.GenerateKey should succeed`) {
+			return
+		}
+		zeroize.Key(key)
+		assert.Equal(t, make([]byte, len(key)), []byte(key), `key should be zero`)
+	})
+	t.Run("[]byte", func(t *testing.T) {
+		key := []byte(`secret`)
+		zeroize.Key(key)
+		assert.Equal(t, make([]byte, len(key)), key, `key should be zero`)
+	})
+}
@@ -24,12 +24,37 @@ var allCompressionAlgorithms = map[CompressionAlgorithm]struct{}{
 	NoCompress: {},
 }
 
-var listCompressionAlgorithmOnce sync.Once
+var muCompressionAlgorithms sync.RWMutex
 var listCompressionAlgorithm []CompressionAlgorithm
 
+// RegisterCompressionAlgorithm registers a new CompressionAlgorithm, so that
+// it is accepted by `Accept()` (and thus when parsing JSON payloads),
+// and is included in the list returned by `CompressionAlgorithms()`.
+//
+// Registering a value does not make jwx support the corresponding
+// algorithm: it merely allows the value to be used as an identifier.
+func RegisterCompressionAlgorithm(v CompressionAlgorithm) {
+	muCompressionAlgorithms.Lock()
+	defer muCompressionAlgorithms.Unlock()
+	if _, ok := allCompressionAlgorithms[v]; ok {
+		return
+	}
+	allCompressionAlgorithms[v] = struct{}{}
+	listCompressionAlgorithm = nil // rebuilt on next call to CompressionAlgorithms()
+}
+
 // CompressionAlgorithms returns a list of all available values for CompressionAlgorithm
 func CompressionAlgorithms() []CompressionAlgorithm {
-	listCompressionAlgorithmOnce.Do(func() {
+	muCompressionAlgorithms.RLock()
+	list := listCompressionAlgorithm
+	muCompressionAlgorithms.RUnlock()
+	if list != nil {
+		return list
+	}
+
+	muCompressionAlgorithms.Lock()
+	defer muCompressionAlgorithms.Unlock()
+	if listCompressionAlgorithm == nil {
 		listCompressionAlgorithm = make([]CompressionAlgorithm, 0, len(allCompressionAlgorithms))
 		for v := range allCompressionAlgorithms {
 			listCompressionAlgorithm = append(listCompressionAlgorithm, v)
@@ -37,7 +62,7 @@ func CompressionAlgorithms() []CompressionAlgorithm {
 		sort.Slice(listCompressionAlgorithm, func(i, j int) bool {
 			return string(listCompressionAlgorithm[i]) < string(listCompressionAlgorithm[j])
 		})
-	})
+	}
 	return listCompressionAlgorithm
 }
 
@@ -59,7 +84,10 @@ func (v *CompressionAlgorithm) Accept(value interface{}) error {
 		}
 		tmp = CompressionAlgorithm(s)
 	}
-	if _, ok := allCompressionAlgorithms[tmp]; !ok {
+	muCompressionAlgorithms.RLock()
+	_, ok := allCompressionAlgorithms[tmp]
+	muCompressionAlgorithms.RUnlock()
+	if !ok {
 		return errors.Errorf(`invalid jwa.CompressionAlgorithm value`)
 	}
 
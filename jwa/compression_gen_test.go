@@ -97,4 +97,22 @@ func TestCompressionAlgorithm(t *testing.T) {
 			return
 		}
 	})
+	t.Run(`accept registered value`, func(t *testing.T) {
+		t.Parallel()
+		const custom = jwa.CompressionAlgorithm(`customCompressionAlgorithm`)
+		var dst jwa.CompressionAlgorithm
+		if !assert.Error(t, dst.Accept(custom), `accept should fail before registration`) {
+			return
+		}
+		jwa.RegisterCompressionAlgorithm(custom)
+		if !assert.NoError(t, dst.Accept(custom.String()), `accept should succeed after registration`) {
+			return
+		}
+		if !assert.Equal(t, custom, dst, `accepted value should be equal to registered value`) {
+			return
+		}
+		if !assert.Contains(t, jwa.CompressionAlgorithms(), custom, `registered value should be listed`) {
+			return
+		}
+	})
 }
@@ -32,12 +32,37 @@ var allContentEncryptionAlgorithms = map[ContentEncryptionAlgorithm]struct{}{
 	A256GCM:       {},
 }
 
-var listContentEncryptionAlgorithmOnce sync.Once
+var muContentEncryptionAlgorithms sync.RWMutex
 var listContentEncryptionAlgorithm []ContentEncryptionAlgorithm
 
+// RegisterContentEncryptionAlgorithm registers a new ContentEncryptionAlgorithm, so that
+// it is accepted by `Accept()` (and thus when parsing JSON payloads),
+// and is included in the list returned by `ContentEncryptionAlgorithms()`.
+//
+// Registering a value does not make jwx support the corresponding
+// algorithm: it merely allows the value to be used as an identifier.
+func RegisterContentEncryptionAlgorithm(v ContentEncryptionAlgorithm) {
+	muContentEncryptionAlgorithms.Lock()
+	defer muContentEncryptionAlgorithms.Unlock()
+	if _, ok := allContentEncryptionAlgorithms[v]; ok {
+		return
+	}
+	allContentEncryptionAlgorithms[v] = struct{}{}
+	listContentEncryptionAlgorithm = nil // rebuilt on next call to ContentEncryptionAlgorithms()
+}
+
 // ContentEncryptionAlgorithms returns a list of all available values for ContentEncryptionAlgorithm
 func ContentEncryptionAlgorithms() []ContentEncryptionAlgorithm {
-	listContentEncryptionAlgorithmOnce.Do(func() {
+	muContentEncryptionAlgorithms.RLock()
+	list := listContentEncryptionAlgorithm
+	muContentEncryptionAlgorithms.RUnlock()
+	if list != nil {
+		return list
+	}
+
+	muContentEncryptionAlgorithms.Lock()
+	defer muContentEncryptionAlgorithms.Unlock()
+	if listContentEncryptionAlgorithm == nil {
 		listContentEncryptionAlgorithm = make([]ContentEncryptionAlgorithm, 0, len(allContentEncryptionAlgorithms))
 		for v := range allContentEncryptionAlgorithms {
 			listContentEncryptionAlgorithm = append(listContentEncryptionAlgorithm, v)
@@ -45,7 +70,7 @@ func ContentEncryptionAlgorithms() []ContentEncryptionAlgorithm {
 		sort.Slice(listContentEncryptionAlgorithm, func(i, j int) bool {
 			return string(listContentEncryptionAlgorithm[i]) < string(listContentEncryptionAlgorithm[j])
 		})
-	})
+	}
 	return listContentEncryptionAlgorithm
 }
 
@@ -67,7 +92,10 @@ func (v *ContentEncryptionAlgorithm) Accept(value interface{}) error {
 		}
 		tmp = ContentEncryptionAlgorithm(s)
 	}
-	if _, ok := allContentEncryptionAlgorithms[tmp]; !ok {
+	muContentEncryptionAlgorithms.RLock()
+	_, ok := allContentEncryptionAlgorithms[tmp]
+	muContentEncryptionAlgorithms.RUnlock()
+	if !ok {
 		return errors.Errorf(`invalid jwa.ContentEncryptionAlgorithm value`)
 	}
 
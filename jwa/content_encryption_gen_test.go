@@ -241,4 +241,22 @@ func TestContentEncryptionAlgorithm(t *testing.T) {
 			return
 		}
 	})
+	t.Run(`accept registered value`, func(t *testing.T) {
+		t.Parallel()
+		const custom = jwa.ContentEncryptionAlgorithm(`customContentEncryptionAlgorithm`)
+		var dst jwa.ContentEncryptionAlgorithm
+		if !assert.Error(t, dst.Accept(custom), `accept should fail before registration`) {
+			return
+		}
+		jwa.RegisterContentEncryptionAlgorithm(custom)
+		if !assert.NoError(t, dst.Accept(custom.String()), `accept should succeed after registration`) {
+			return
+		}
+		if !assert.Equal(t, custom, dst, `accepted value should be equal to registered value`) {
+			return
+		}
+		if !assert.Contains(t, jwa.ContentEncryptionAlgorithms(), custom, `registered value should be listed`) {
+			return
+		}
+	})
 }
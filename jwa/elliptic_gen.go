@@ -10,7 +10,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-//  EllipticCurveAlgorithm represents the algorithms used for EC keys
+// EllipticCurveAlgorithm represents the algorithms used for EC keys
 type EllipticCurveAlgorithm string
 
 // Supported values for EllipticCurveAlgorithm
@@ -35,12 +35,37 @@ var allEllipticCurveAlgorithms = map[EllipticCurveAlgorithm]struct{}{
 	X448:    {},
 }
 
-var listEllipticCurveAlgorithmOnce sync.Once
+var muEllipticCurveAlgorithms sync.RWMutex
 var listEllipticCurveAlgorithm []EllipticCurveAlgorithm
 
+// RegisterEllipticCurveAlgorithm registers a new EllipticCurveAlgorithm, so that
+// it is accepted by `Accept()` (and thus when parsing JSON payloads),
+// and is included in the list returned by `EllipticCurveAlgorithms()`.
+//
+// Registering a value does not make jwx support the corresponding
+// algorithm: it merely allows the value to be used as an identifier.
+func RegisterEllipticCurveAlgorithm(v EllipticCurveAlgorithm) {
+	muEllipticCurveAlgorithms.Lock()
+	defer muEllipticCurveAlgorithms.Unlock()
+	if _, ok := allEllipticCurveAlgorithms[v]; ok {
+		return
+	}
+	allEllipticCurveAlgorithms[v] = struct{}{}
+	listEllipticCurveAlgorithm = nil // rebuilt on next call to EllipticCurveAlgorithms()
+}
+
 // EllipticCurveAlgorithms returns a list of all available values for EllipticCurveAlgorithm
 func EllipticCurveAlgorithms() []EllipticCurveAlgorithm {
-	listEllipticCurveAlgorithmOnce.Do(func() {
+	muEllipticCurveAlgorithms.RLock()
+	list := listEllipticCurveAlgorithm
+	muEllipticCurveAlgorithms.RUnlock()
+	if list != nil {
+		return list
+	}
+
+	muEllipticCurveAlgorithms.Lock()
+	defer muEllipticCurveAlgorithms.Unlock()
+	if listEllipticCurveAlgorithm == nil {
 		listEllipticCurveAlgorithm = make([]EllipticCurveAlgorithm, 0, len(allEllipticCurveAlgorithms))
 		for v := range allEllipticCurveAlgorithms {
 			listEllipticCurveAlgorithm = append(listEllipticCurveAlgorithm, v)
@@ -48,7 +73,7 @@ func EllipticCurveAlgorithms() []EllipticCurveAlgorithm {
 		sort.Slice(listEllipticCurveAlgorithm, func(i, j int) bool {
 			return string(listEllipticCurveAlgorithm[i]) < string(listEllipticCurveAlgorithm[j])
 		})
-	})
+	}
 	return listEllipticCurveAlgorithm
 }
 
@@ -70,7 +95,10 @@ func (v *EllipticCurveAlgorithm) Accept(value interface{}) error {
 		}
 		tmp = EllipticCurveAlgorithm(s)
 	}
-	if _, ok := allEllipticCurveAlgorithms[tmp]; !ok {
+	muEllipticCurveAlgorithms.RLock()
+	_, ok := allEllipticCurveAlgorithms[tmp]
+	muEllipticCurveAlgorithms.RUnlock()
+	if !ok {
 		return errors.Errorf(`invalid jwa.EllipticCurveAlgorithm value`)
 	}
 
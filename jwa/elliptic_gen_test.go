@@ -284,4 +284,22 @@ func TestEllipticCurveAlgorithm(t *testing.T) {
 			return
 		}
 	})
+	t.Run(`accept registered value`, func(t *testing.T) {
+		t.Parallel()
+		const custom = jwa.EllipticCurveAlgorithm(`customEllipticCurveAlgorithm`)
+		var dst jwa.EllipticCurveAlgorithm
+		if !assert.Error(t, dst.Accept(custom), `accept should fail before registration`) {
+			return
+		}
+		jwa.RegisterEllipticCurveAlgorithm(custom)
+		if !assert.NoError(t, dst.Accept(custom.String()), `accept should succeed after registration`) {
+			return
+		}
+		if !assert.Equal(t, custom, dst, `accepted value should be equal to registered value`) {
+			return
+		}
+		if !assert.Contains(t, jwa.EllipticCurveAlgorithms(), custom, `registered value should be listed`) {
+			return
+		}
+	})
 }
@@ -290,6 +290,26 @@ func _main() error {
 					value:   "ECDH-ES+A256KW",
 					comment: `ECDH-ES + AES key wrap (256)`,
 				},
+				{
+					name:    `ECDH_1PU`,
+					value:   "ECDH-1PU",
+					comment: `ECDH-1PU (authenticated key agreement)`,
+				},
+				{
+					name:    `ECDH_1PU_A128KW`,
+					value:   "ECDH-1PU+A128KW",
+					comment: `ECDH-1PU + AES key wrap (128)`,
+				},
+				{
+					name:    `ECDH_1PU_A192KW`,
+					value:   "ECDH-1PU+A192KW",
+					comment: `ECDH-1PU + AES key wrap (192)`,
+				},
+				{
+					name:    `ECDH_1PU_A256KW`,
+					value:   "ECDH-1PU+A256KW",
+					comment: `ECDH-1PU + AES key wrap (256)`,
+				},
 				{
 					name:    `A128GCMKW`,
 					value:   "A128GCMKW",
@@ -408,11 +428,33 @@ func (t typ) Generate() error {
 	}
 	fmt.Fprintf(&buf, "\n}")
 
-	fmt.Fprintf(&buf, "\n\nvar list%sOnce sync.Once", t.name)
+	fmt.Fprintf(&buf, "\n\nvar mu%[1]ss sync.RWMutex", t.name)
 	fmt.Fprintf(&buf, "\nvar list%[1]s []%[1]s", t.name)
+	fmt.Fprintf(&buf, "\n\n// Register%[1]s registers a new %[1]s, so that", t.name)
+	fmt.Fprintf(&buf, "\n// it is accepted by `Accept()` (and thus when parsing JSON payloads),")
+	fmt.Fprintf(&buf, "\n// and is included in the list returned by `%[1]ss()`.", t.name)
+	fmt.Fprintf(&buf, "\n//\n// Registering a value does not make jwx support the corresponding")
+	fmt.Fprintf(&buf, "\n// algorithm: it merely allows the value to be used as an identifier.")
+	fmt.Fprintf(&buf, "\nfunc Register%[1]s(v %[1]s) {", t.name)
+	fmt.Fprintf(&buf, "\nmu%ss.Lock()", t.name)
+	fmt.Fprintf(&buf, "\ndefer mu%ss.Unlock()", t.name)
+	fmt.Fprintf(&buf, "\nif _, ok := all%ss[v]; ok {", t.name)
+	fmt.Fprintf(&buf, "\nreturn")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nall%ss[v] = struct{}{}", t.name)
+	fmt.Fprintf(&buf, "\nlist%s = nil // rebuilt on next call to %ss()", t.name, t.name)
+	fmt.Fprintf(&buf, "\n}")
 	fmt.Fprintf(&buf, "\n\n// %[1]ss returns a list of all available values for %[1]s", t.name)
 	fmt.Fprintf(&buf, "\nfunc %[1]ss() []%[1]s {", t.name)
-	fmt.Fprintf(&buf, "\nlist%sOnce.Do(func() {", t.name)
+	fmt.Fprintf(&buf, "\nmu%ss.RLock()", t.name)
+	fmt.Fprintf(&buf, "\nlist := list%s", t.name)
+	fmt.Fprintf(&buf, "\nmu%ss.RUnlock()", t.name)
+	fmt.Fprintf(&buf, "\nif list != nil {")
+	fmt.Fprintf(&buf, "\nreturn list")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\n\nmu%ss.Lock()", t.name)
+	fmt.Fprintf(&buf, "\ndefer mu%ss.Unlock()", t.name)
+	fmt.Fprintf(&buf, "\nif list%s == nil {", t.name)
 	fmt.Fprintf(&buf, "\nlist%[1]s = make([]%[1]s, 0, len(all%[1]ss))", t.name)
 	fmt.Fprintf(&buf, "\nfor v := range all%ss {", t.name)
 	fmt.Fprintf(&buf, "\nlist%[1]s = append(list%[1]s, v)", t.name)
@@ -420,7 +462,7 @@ func (t typ) Generate() error {
 	fmt.Fprintf(&buf, "\nsort.Slice(list%s, func(i, j int) bool {", t.name)
 	fmt.Fprintf(&buf, "\nreturn string(list%[1]s[i]) < string(list%[1]s[j])", t.name)
 	fmt.Fprintf(&buf, "\n})")
-	fmt.Fprintf(&buf, "\n})")
+	fmt.Fprintf(&buf, "\n}")
 	fmt.Fprintf(&buf, "\nreturn list%s", t.name)
 	fmt.Fprintf(&buf, "\n}")
 
@@ -443,7 +485,10 @@ func (t typ) Generate() error {
 	fmt.Fprintf(&buf, "\ntmp = %s(s)", t.name)
 	fmt.Fprintf(&buf, "\n}")
 
-	fmt.Fprintf(&buf, "\nif _, ok := all%ss[tmp]; !ok {", t.name)
+	fmt.Fprintf(&buf, "\nmu%ss.RLock()", t.name)
+	fmt.Fprintf(&buf, "\n_, ok := all%ss[tmp]", t.name)
+	fmt.Fprintf(&buf, "\nmu%ss.RUnlock()", t.name)
+	fmt.Fprintf(&buf, "\nif !ok {")
 	fmt.Fprintf(&buf, "\nreturn errors.Errorf(`invalid jwa.%s value`)", t.name)
 	fmt.Fprintf(&buf, "\n}")
 
@@ -586,6 +631,25 @@ func (t typ) GenerateTest() error {
 	fmt.Fprintf(&buf, "\n}")
 	fmt.Fprintf(&buf, "\n})")
 
+	fmt.Fprintf(&buf, "\nt.Run(`accept registered value`, func(t *testing.T) {")
+	fmt.Fprintf(&buf, "\nt.Parallel()")
+	fmt.Fprintf(&buf, "\nconst custom = jwa.%s(`custom%s`)", t.name, t.name)
+	fmt.Fprintf(&buf, "\nvar dst jwa.%s", t.name)
+	fmt.Fprintf(&buf, "\nif !assert.Error(t, dst.Accept(custom), `accept should fail before registration`) {")
+	fmt.Fprintf(&buf, "\nreturn")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\njwa.Register%s(custom)", t.name)
+	fmt.Fprintf(&buf, "\nif !assert.NoError(t, dst.Accept(custom.String()), `accept should succeed after registration`) {")
+	fmt.Fprintf(&buf, "\nreturn")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nif !assert.Equal(t, custom, dst, `accepted value should be equal to registered value`) {")
+	fmt.Fprintf(&buf, "\nreturn")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nif !assert.Contains(t, jwa.%ss(), custom, `registered value should be listed`) {", t.name)
+	fmt.Fprintf(&buf, "\nreturn")
+	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\n})")
+
 	if t.name == "KeyEncryptionAlgorithm" {
 		fmt.Fprintf(&buf, "\nt.Run(`check symmetric values`, func(t *testing.T) {")
 		fmt.Fprintf(&buf, "\nt.Parallel()")
@@ -22,6 +22,10 @@ const (
 	A256GCMKW          KeyEncryptionAlgorithm = "A256GCMKW"          // AES-GCM key wrap (256)
 	A256KW             KeyEncryptionAlgorithm = "A256KW"             // AES key wrap (256)
 	DIRECT             KeyEncryptionAlgorithm = "dir"                // Direct encryption
+	ECDH_1PU           KeyEncryptionAlgorithm = "ECDH-1PU"           // ECDH-1PU (authenticated key agreement)
+	ECDH_1PU_A128KW    KeyEncryptionAlgorithm = "ECDH-1PU+A128KW"    // ECDH-1PU + AES key wrap (128)
+	ECDH_1PU_A192KW    KeyEncryptionAlgorithm = "ECDH-1PU+A192KW"    // ECDH-1PU + AES key wrap (192)
+	ECDH_1PU_A256KW    KeyEncryptionAlgorithm = "ECDH-1PU+A256KW"    // ECDH-1PU + AES key wrap (256)
 	ECDH_ES            KeyEncryptionAlgorithm = "ECDH-ES"            // ECDH-ES
 	ECDH_ES_A128KW     KeyEncryptionAlgorithm = "ECDH-ES+A128KW"     // ECDH-ES + AES key wrap (128)
 	ECDH_ES_A192KW     KeyEncryptionAlgorithm = "ECDH-ES+A192KW"     // ECDH-ES + AES key wrap (192)
@@ -42,6 +46,10 @@ var allKeyEncryptionAlgorithms = map[KeyEncryptionAlgorithm]struct{}{
 	A256GCMKW:          {},
 	A256KW:             {},
 	DIRECT:             {},
+	ECDH_1PU:           {},
+	ECDH_1PU_A128KW:    {},
+	ECDH_1PU_A192KW:    {},
+	ECDH_1PU_A256KW:    {},
 	ECDH_ES:            {},
 	ECDH_ES_A128KW:     {},
 	ECDH_ES_A192KW:     {},
@@ -54,12 +62,37 @@ var allKeyEncryptionAlgorithms = map[KeyEncryptionAlgorithm]struct{}{
 	RSA_OAEP_256:       {},
 }
 
-var listKeyEncryptionAlgorithmOnce sync.Once
+var muKeyEncryptionAlgorithms sync.RWMutex
 var listKeyEncryptionAlgorithm []KeyEncryptionAlgorithm
 
+// RegisterKeyEncryptionAlgorithm registers a new KeyEncryptionAlgorithm, so that
+// it is accepted by `Accept()` (and thus when parsing JSON payloads),
+// and is included in the list returned by `KeyEncryptionAlgorithms()`.
+//
+// Registering a value does not make jwx support the corresponding
+// algorithm: it merely allows the value to be used as an identifier.
+func RegisterKeyEncryptionAlgorithm(v KeyEncryptionAlgorithm) {
+	muKeyEncryptionAlgorithms.Lock()
+	defer muKeyEncryptionAlgorithms.Unlock()
+	if _, ok := allKeyEncryptionAlgorithms[v]; ok {
+		return
+	}
+	allKeyEncryptionAlgorithms[v] = struct{}{}
+	listKeyEncryptionAlgorithm = nil // rebuilt on next call to KeyEncryptionAlgorithms()
+}
+
 // KeyEncryptionAlgorithms returns a list of all available values for KeyEncryptionAlgorithm
 func KeyEncryptionAlgorithms() []KeyEncryptionAlgorithm {
-	listKeyEncryptionAlgorithmOnce.Do(func() {
+	muKeyEncryptionAlgorithms.RLock()
+	list := listKeyEncryptionAlgorithm
+	muKeyEncryptionAlgorithms.RUnlock()
+	if list != nil {
+		return list
+	}
+
+	muKeyEncryptionAlgorithms.Lock()
+	defer muKeyEncryptionAlgorithms.Unlock()
+	if listKeyEncryptionAlgorithm == nil {
 		listKeyEncryptionAlgorithm = make([]KeyEncryptionAlgorithm, 0, len(allKeyEncryptionAlgorithms))
 		for v := range allKeyEncryptionAlgorithms {
 			listKeyEncryptionAlgorithm = append(listKeyEncryptionAlgorithm, v)
@@ -67,7 +100,7 @@ func KeyEncryptionAlgorithms() []KeyEncryptionAlgorithm {
 		sort.Slice(listKeyEncryptionAlgorithm, func(i, j int) bool {
 			return string(listKeyEncryptionAlgorithm[i]) < string(listKeyEncryptionAlgorithm[j])
 		})
-	})
+	}
 	return listKeyEncryptionAlgorithm
 }
 
@@ -89,7 +122,10 @@ func (v *KeyEncryptionAlgorithm) Accept(value interface{}) error {
 		}
 		tmp = KeyEncryptionAlgorithm(s)
 	}
-	if _, ok := allKeyEncryptionAlgorithms[tmp]; !ok {
+	muKeyEncryptionAlgorithms.RLock()
+	_, ok := allKeyEncryptionAlgorithms[tmp]
+	muKeyEncryptionAlgorithms.RUnlock()
+	if !ok {
 		return errors.Errorf(`invalid jwa.KeyEncryptionAlgorithm value`)
 	}
 
@@ -29,12 +29,37 @@ var allKeyTypes = map[KeyType]struct{}{
 	RSA:      {},
 }
 
-var listKeyTypeOnce sync.Once
+var muKeyTypes sync.RWMutex
 var listKeyType []KeyType
 
+// RegisterKeyType registers a new KeyType, so that
+// it is accepted by `Accept()` (and thus when parsing JSON payloads),
+// and is included in the list returned by `KeyTypes()`.
+//
+// Registering a value does not make jwx support the corresponding
+// algorithm: it merely allows the value to be used as an identifier.
+func RegisterKeyType(v KeyType) {
+	muKeyTypes.Lock()
+	defer muKeyTypes.Unlock()
+	if _, ok := allKeyTypes[v]; ok {
+		return
+	}
+	allKeyTypes[v] = struct{}{}
+	listKeyType = nil // rebuilt on next call to KeyTypes()
+}
+
 // KeyTypes returns a list of all available values for KeyType
 func KeyTypes() []KeyType {
-	listKeyTypeOnce.Do(func() {
+	muKeyTypes.RLock()
+	list := listKeyType
+	muKeyTypes.RUnlock()
+	if list != nil {
+		return list
+	}
+
+	muKeyTypes.Lock()
+	defer muKeyTypes.Unlock()
+	if listKeyType == nil {
 		listKeyType = make([]KeyType, 0, len(allKeyTypes))
 		for v := range allKeyTypes {
 			listKeyType = append(listKeyType, v)
@@ -42,7 +67,7 @@ func KeyTypes() []KeyType {
 		sort.Slice(listKeyType, func(i, j int) bool {
 			return string(listKeyType[i]) < string(listKeyType[j])
 		})
-	})
+	}
 	return listKeyType
 }
 
@@ -64,7 +89,10 @@ func (v *KeyType) Accept(value interface{}) error {
 		}
 		tmp = KeyType(s)
 	}
-	if _, ok := allKeyTypes[tmp]; !ok {
+	muKeyTypes.RLock()
+	_, ok := allKeyTypes[tmp]
+	muKeyTypes.RUnlock()
+	if !ok {
 		return errors.Errorf(`invalid jwa.KeyType value`)
 	}
 
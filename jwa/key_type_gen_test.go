@@ -176,4 +176,22 @@ func TestKeyType(t *testing.T) {
 			return
 		}
 	})
+	t.Run(`accept registered value`, func(t *testing.T) {
+		t.Parallel()
+		const custom = jwa.KeyType(`customKeyType`)
+		var dst jwa.KeyType
+		if !assert.Error(t, dst.Accept(custom), `accept should fail before registration`) {
+			return
+		}
+		jwa.RegisterKeyType(custom)
+		if !assert.NoError(t, dst.Accept(custom.String()), `accept should succeed after registration`) {
+			return
+		}
+		if !assert.Equal(t, custom, dst, `accepted value should be equal to registered value`) {
+			return
+		}
+		if !assert.Contains(t, jwa.KeyTypes(), custom, `registered value should be listed`) {
+			return
+		}
+	})
 }
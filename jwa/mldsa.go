@@ -0,0 +1,23 @@
+// +build jwx_mldsa
+
+package jwa
+
+// These constants are only available if compiled with jwx_mldsa build tag.
+//
+// Support for ML-DSA is experimental, and follows the JOSE/COSE drafts
+// for post-quantum signatures (draft-ietf-cose-dilithium), which may
+// still change in incompatible ways.
+const (
+	AKP KeyType = "AKP" // Algorithm Key Pair
+
+	MLDSA44 SignatureAlgorithm = "ML-DSA-44" // ML-DSA-44 as described in FIPS 204
+	MLDSA65 SignatureAlgorithm = "ML-DSA-65" // ML-DSA-65 as described in FIPS 204
+	MLDSA87 SignatureAlgorithm = "ML-DSA-87" // ML-DSA-87 as described in FIPS 204
+)
+
+func init() {
+	RegisterKeyType(AKP)
+	RegisterSignatureAlgorithm(MLDSA44)
+	RegisterSignatureAlgorithm(MLDSA65)
+	RegisterSignatureAlgorithm(MLDSA87)
+}
@@ -0,0 +1,37 @@
+// +build jwx_mldsa
+
+package jwa_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMLDSA(t *testing.T) {
+	t.Parallel()
+	t.Run(`accept the string AKP`, func(t *testing.T) {
+		t.Parallel()
+		var dst jwa.KeyType
+		if !assert.NoError(t, dst.Accept("AKP"), `accept is successful`) {
+			return
+		}
+		if !assert.Equal(t, jwa.AKP, dst, `accepted value should be equal to constant`) {
+			return
+		}
+	})
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.MLDSA44, jwa.MLDSA65, jwa.MLDSA87} {
+		alg := alg
+		t.Run(`accept the string `+alg.String(), func(t *testing.T) {
+			t.Parallel()
+			var dst jwa.SignatureAlgorithm
+			if !assert.NoError(t, dst.Accept(alg.String()), `accept is successful`) {
+				return
+			}
+			if !assert.Equal(t, alg, dst, `accepted value should be equal to constant`) {
+				return
+			}
+		})
+	}
+}
@@ -50,12 +50,37 @@ var allSignatureAlgorithms = map[SignatureAlgorithm]struct{}{
 	RS512:       {},
 }
 
-var listSignatureAlgorithmOnce sync.Once
+var muSignatureAlgorithms sync.RWMutex
 var listSignatureAlgorithm []SignatureAlgorithm
 
+// RegisterSignatureAlgorithm registers a new SignatureAlgorithm, so that
+// it is accepted by `Accept()` (and thus when parsing JSON payloads),
+// and is included in the list returned by `SignatureAlgorithms()`.
+//
+// Registering a value does not make jwx support the corresponding
+// algorithm: it merely allows the value to be used as an identifier.
+func RegisterSignatureAlgorithm(v SignatureAlgorithm) {
+	muSignatureAlgorithms.Lock()
+	defer muSignatureAlgorithms.Unlock()
+	if _, ok := allSignatureAlgorithms[v]; ok {
+		return
+	}
+	allSignatureAlgorithms[v] = struct{}{}
+	listSignatureAlgorithm = nil // rebuilt on next call to SignatureAlgorithms()
+}
+
 // SignatureAlgorithms returns a list of all available values for SignatureAlgorithm
 func SignatureAlgorithms() []SignatureAlgorithm {
-	listSignatureAlgorithmOnce.Do(func() {
+	muSignatureAlgorithms.RLock()
+	list := listSignatureAlgorithm
+	muSignatureAlgorithms.RUnlock()
+	if list != nil {
+		return list
+	}
+
+	muSignatureAlgorithms.Lock()
+	defer muSignatureAlgorithms.Unlock()
+	if listSignatureAlgorithm == nil {
 		listSignatureAlgorithm = make([]SignatureAlgorithm, 0, len(allSignatureAlgorithms))
 		for v := range allSignatureAlgorithms {
 			listSignatureAlgorithm = append(listSignatureAlgorithm, v)
@@ -63,7 +88,7 @@ func SignatureAlgorithms() []SignatureAlgorithm {
 		sort.Slice(listSignatureAlgorithm, func(i, j int) bool {
 			return string(listSignatureAlgorithm[i]) < string(listSignatureAlgorithm[j])
 		})
-	})
+	}
 	return listSignatureAlgorithm
 }
 
@@ -85,7 +110,10 @@ func (v *SignatureAlgorithm) Accept(value interface{}) error {
 		}
 		tmp = SignatureAlgorithm(s)
 	}
-	if _, ok := allSignatureAlgorithms[tmp]; !ok {
+	muSignatureAlgorithms.RLock()
+	_, ok := allSignatureAlgorithms[tmp]
+	muSignatureAlgorithms.RUnlock()
+	if !ok {
 		return errors.Errorf(`invalid jwa.SignatureAlgorithm value`)
 	}
 
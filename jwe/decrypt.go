@@ -1,6 +1,8 @@
 package jwe
 
 import (
+	"context"
+	"crypto"
 	"crypto/aes"
 	cryptocipher "crypto/cipher"
 	"crypto/ecdsa"
@@ -8,10 +10,13 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"hash"
+	"io"
 
 	"golang.org/x/crypto/pbkdf2"
 
+	"github.com/lestrrat-go/jwx/internal/fips"
 	"github.com/lestrrat-go/jwx/internal/keyconv"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwe/internal/cipher"
 	"github.com/lestrrat-go/jwx/jwe/internal/content_crypt"
@@ -36,10 +41,12 @@ type Decrypter struct {
 	tag         []byte
 	privkey     interface{}
 	pubkey      interface{}
+	senderkey   interface{}
 	ctalg       jwa.ContentEncryptionAlgorithm
 	keyalg      jwa.KeyEncryptionAlgorithm
 	cipher      content_crypt.Cipher
 	keycount    int
+	zeroize     bool
 }
 
 // NewDecrypter Creates a new Decrypter instance. You must supply the
@@ -115,12 +122,23 @@ func (d *Decrypter) PublicKey(pubkey interface{}) *Decrypter {
 	return d
 }
 
+// SenderPublicKey sets the static public key of the sender, which is
+// required to decrypt messages encrypted using ECDH-1PU. The key must be
+// in its "raw" format (i.e. *ecdsa.PublicKey, instead of jwk.Key)
+func (d *Decrypter) SenderPublicKey(pubkey interface{}) *Decrypter {
+	d.senderkey = pubkey
+	return d
+}
+
 func (d *Decrypter) Tag(tag []byte) *Decrypter {
 	d.tag = tag
 	return d
 }
 
 func (d *Decrypter) ContentCipher() (content_crypt.Cipher, error) {
+	if err := fips.CheckContentEncryptionAlgorithm(d.ctalg); err != nil {
+		return nil, err
+	}
 	if d.cipher == nil {
 		switch d.ctalg {
 		case jwa.A128GCM, jwa.A192GCM, jwa.A256GCM, jwa.A128CBC_HS256, jwa.A192CBC_HS384, jwa.A256CBC_HS512:
@@ -148,6 +166,9 @@ func (d *Decrypter) Decrypt(recipientKey, ciphertext []byte) (plaintext []byte,
 		err = errors.Wrap(keyerr, `failed to decrypt key`)
 		return
 	}
+	if d.zeroize && d.keyalg != jwa.DIRECT {
+		defer zeroize.Bytes(cek)
+	}
 
 	cipher, ciphererr := d.ContentCipher()
 	if ciphererr != nil {
@@ -257,6 +278,9 @@ func (d *Decrypter) DecryptKey(recipientKey []byte) (cek []byte, err error) {
 		g := pdebug.FuncMarker().BindError(&err)
 		defer g.End()
 	}
+	if err := fips.CheckKeyEncryptionAlgorithm(d.keyalg); err != nil {
+		return nil, err
+	}
 	if d.keyalg.IsSymmetric() {
 		var ok bool
 		cek, ok = d.privkey.([]byte)
@@ -285,13 +309,19 @@ func (d *Decrypter) DecryptKey(recipientKey []byte) (cek []byte, err error) {
 }
 
 func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
-	cipher, err := d.ContentCipher()
-	if err != nil {
-		return nil, errors.Wrap(err, `failed to fetch content crypt cipher`)
-	}
-
 	switch alg := d.keyalg; alg {
 	case jwa.RSA1_5:
+		// The size of the content encryption key is required to generate
+		// a random key when decryption fails (see RFC 7516 Section 11.5)
+		cipher, err := d.ContentCipher()
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to fetch content crypt cipher`)
+		}
+
+		if decrypter, ok := opaqueDecrypter(d.privkey); ok {
+			return keyenc.NewCryptoDecrypt(alg, decrypter)
+		}
+
 		var privkey rsa.PrivateKey
 		if err := keyconv.RSAPrivateKey(&privkey, d.privkey); err != nil {
 			return nil, errors.Wrapf(err, "*rsa.PrivateKey is required as the key to build %s key decrypter", alg)
@@ -299,6 +329,10 @@ func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
 
 		return keyenc.NewRSAPKCS15Decrypt(alg, &privkey, cipher.KeySize()/2), nil
 	case jwa.RSA_OAEP, jwa.RSA_OAEP_256:
+		if decrypter, ok := opaqueDecrypter(d.privkey); ok {
+			return keyenc.NewCryptoDecrypt(alg, decrypter)
+		}
+
 		var privkey rsa.PrivateKey
 		if err := keyconv.RSAPrivateKey(&privkey, d.privkey); err != nil {
 			return nil, errors.Wrapf(err, "*rsa.PrivateKey is required as the key to build %s key decrypter", alg)
@@ -315,6 +349,9 @@ func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
 	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
 		switch d.pubkey.(type) {
 		case x25519.PublicKey:
+			if err := fips.CheckKeyCurve(d.pubkey); err != nil {
+				return nil, err
+			}
 			return keyenc.NewECDHESDecrypt(alg, d.ctalg, d.pubkey, d.apu, d.apv, d.privkey), nil
 		default:
 			var pubkey ecdsa.PublicKey
@@ -327,9 +364,92 @@ func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
 				return nil, errors.Wrapf(err, "*ecdsa.PrivateKey is required as the key to build %s key decrypter", alg)
 			}
 
+			if err := fips.CheckKeyCurve(&pubkey); err != nil {
+				return nil, err
+			}
 			return keyenc.NewECDHESDecrypt(alg, d.ctalg, &pubkey, d.apu, d.apv, &privkey), nil
 		}
+	case jwa.ECDH_1PU, jwa.ECDH_1PU_A128KW, jwa.ECDH_1PU_A192KW, jwa.ECDH_1PU_A256KW:
+		if d.senderkey == nil {
+			return nil, errors.Errorf("the sender's public key is required to build %s key decrypter", alg)
+		}
+
+		switch d.pubkey.(type) {
+		case x25519.PublicKey:
+			if err := fips.CheckKeyCurve(d.pubkey); err != nil {
+				return nil, err
+			}
+			return keyenc.NewECDH1PUDecrypt(alg, d.ctalg, d.pubkey, d.senderkey, d.apu, d.apv, d.tag, d.privkey), nil
+		default:
+			var pubkey ecdsa.PublicKey
+			if err := keyconv.ECDSAPublicKey(&pubkey, d.pubkey); err != nil {
+				return nil, errors.Wrapf(err, "*ecdsa.PublicKey is required as the key to build %s key decrypter", alg)
+			}
+
+			var senderkey ecdsa.PublicKey
+			if err := keyconv.ECDSAPublicKey(&senderkey, d.senderkey); err != nil {
+				return nil, errors.Wrapf(err, "*ecdsa.PublicKey is required as the sender's key to build %s key decrypter", alg)
+			}
+
+			var privkey ecdsa.PrivateKey
+			if err := keyconv.ECDSAPrivateKey(&privkey, d.privkey); err != nil {
+				return nil, errors.Wrapf(err, "*ecdsa.PrivateKey is required as the key to build %s key decrypter", alg)
+			}
+
+			if err := fips.CheckKeyCurve(&pubkey); err != nil {
+				return nil, err
+			}
+			return keyenc.NewECDH1PUDecrypt(alg, d.ctalg, &pubkey, &senderkey, d.apu, d.apv, d.tag, &privkey), nil
+		}
 	default:
 		return nil, errors.Errorf(`unsupported algorithm for key decryption (%s)`, alg)
 	}
 }
+
+// opaqueDecrypter returns the crypto.Decrypter in key, if key is a
+// crypto.Decrypter for an RSA key whose private key material is not
+// directly available to us (e.g. keys stored in a KMS or an HSM)
+func opaqueDecrypter(key interface{}) (crypto.Decrypter, bool) {
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		return nil, false
+	}
+
+	decrypter, ok := key.(crypto.Decrypter)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := decrypter.Public().(*rsa.PublicKey); !ok {
+		return nil, false
+	}
+	return decrypter, true
+}
+
+// ContextDecrypter is a crypto.Decrypter that can also honor a
+// context.Context, such as a decrypter backed by a remote KMS. When such
+// a key is passed to `jwe.DecryptContext()`, DecryptContext is called with
+// the given context instead of Decrypt, so that deadlines and cancellation
+// are propagated to the remote decrypter.
+type ContextDecrypter interface {
+	crypto.Decrypter
+	DecryptContext(context.Context, io.Reader, []byte, crypto.DecrypterOpts) ([]byte, error)
+}
+
+// contextBoundDecrypter adapts a ContextDecrypter to a crypto.Decrypter,
+// by binding it to a particular context
+type contextBoundDecrypter struct {
+	ContextDecrypter
+	ctx context.Context
+}
+
+func (d *contextBoundDecrypter) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	return d.DecryptContext(d.ctx, rand, msg, opts)
+}
+
+// bindDecrypterContext binds ctx to key, if key is a ContextDecrypter.
+// Otherwise key is returned as is
+func bindDecrypterContext(ctx context.Context, key interface{}) interface{} {
+	if decrypter, ok := key.(ContextDecrypter); ok {
+		return &contextBoundDecrypter{ContextDecrypter: decrypter, ctx: ctx}
+	}
+	return key
+}
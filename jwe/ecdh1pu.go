@@ -0,0 +1,181 @@
+package jwe
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/lestrrat-go/jwx/internal/fips"
+	"github.com/lestrrat-go/jwx/internal/keyconv"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe/internal/keyenc"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
+)
+
+// isECDH1PU returns true if alg is one of the ECDH-1PU key agreement
+// algorithms described in https://datatracker.ietf.org/doc/html/draft-madden-jose-ecdh-1pu-04
+func isECDH1PU(alg jwa.KeyEncryptionAlgorithm) bool {
+	switch alg {
+	case jwa.ECDH_1PU, jwa.ECDH_1PU_A128KW, jwa.ECDH_1PU_A192KW, jwa.ECDH_1PU_A256KW:
+		return true
+	}
+	return false
+}
+
+// ecdh1puParams holds the parameters for ECDH-1PU key agreement that
+// are shared by all recipients of a message
+type ecdh1puParams struct {
+	apu       []byte
+	apv       []byte
+	ephemeral interface{}
+	sender    interface{}
+}
+
+// prepareECDH1PU prepares the parameters for ECDH-1PU key agreement
+// using the static private key of the sender, and stores the headers
+// that the recipients need ("epk" and "skid") in the protected headers.
+//
+// A single ephemeral key is used for all recipients, so that the "epk"
+// header can be integrity protected along with the rest of the
+// protected headers.
+func prepareECDH1PU(protected Headers, key interface{}) (*ecdh1puParams, error) {
+	if key == nil {
+		return nil, errors.New(`the sender's key must be specified for ECDH-1PU (see jwe.WithSenderKey)`)
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		if kid := jwkKey.KeyID(); kid != "" && protected.SenderKeyID() == "" {
+			if err := protected.Set(SenderKeyIDKey, kid); err != nil {
+				return nil, errors.Wrapf(err, `failed to set %q header`, SenderKeyIDKey)
+			}
+		}
+
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve raw key out of %T`, key)
+		}
+		key = raw
+	}
+
+	var sender interface{}
+	switch key := key.(type) {
+	case x25519.PrivateKey:
+		sender = key
+	default:
+		var privkey ecdsa.PrivateKey
+		if err := keyconv.ECDSAPrivateKey(&privkey, key); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve the sender's private key from %T`, key)
+		}
+		sender = &privkey
+	}
+	if err := fips.CheckKeyCurve(sender); err != nil {
+		return nil, err
+	}
+
+	ephemeral, err := keyenc.GenerateEphemeralKey(sender)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to generate ephemeral key`)
+	}
+
+	var epk jwk.Key
+	switch ephemeral := ephemeral.(type) {
+	case *ecdsa.PrivateKey:
+		epk, err = jwk.New(&ephemeral.PublicKey)
+	case x25519.PrivateKey:
+		epk, err = jwk.New(ephemeral.Public())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create JWK from ephemeral key`)
+	}
+	if err := protected.Set(EphemeralPublicKeyKey, epk); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %q header`, EphemeralPublicKeyKey)
+	}
+
+	return &ecdh1puParams{
+		apu:       protected.AgreementPartyUInfo(),
+		apv:       protected.AgreementPartyVInfo(),
+		ephemeral: ephemeral,
+		sender:    sender,
+	}, nil
+}
+
+// newECDH1PUKeyEncrypter creates the ECDH-1PU key encrypter for a single
+// recipient, whose public key is `key`.
+func newECDH1PUKeyEncrypter(keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, cekSize int, params *ecdh1puParams) (keyenc.Encrypter, error) {
+	if err := fips.CheckKeyEncryptionAlgorithm(keyalg); err != nil {
+		return nil, err
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve raw key out of %T`, key)
+		}
+		key = raw
+	}
+
+	var recipient interface{}
+	switch key := key.(type) {
+	case x25519.PublicKey:
+		recipient = key
+	default:
+		var pubkey ecdsa.PublicKey
+		if err := keyconv.ECDSAPublicKey(&pubkey, key); err != nil {
+			return nil, errors.Wrapf(err, "failed to generate public key from key (%T)", key)
+		}
+		recipient = &pubkey
+	}
+	if err := fips.CheckKeyCurve(recipient); err != nil {
+		return nil, err
+	}
+
+	enc, err := keyenc.NewECDH1PUEncrypt(keyalg, contentalg, cekSize, params.apu, params.apv, params.ephemeral, params.sender, recipient)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create ECDH-1PU key encrypter`)
+	}
+	return enc, nil
+}
+
+// senderPublicKey returns the raw public key of the sender of a message
+// encrypted using ECDH-1PU. If `key` is a jwk.Set, the key is looked up
+// using the "skid" header in `h`
+func senderPublicKey(key interface{}, h Headers) (interface{}, error) {
+	if key == nil {
+		return nil, errors.New(`the sender's public key must be specified for ECDH-1PU (see jwe.WithSenderPublicKey)`)
+	}
+
+	if set, ok := key.(jwk.Set); ok {
+		skid := h.SenderKeyID()
+		if skid == "" {
+			return nil, errors.Errorf(`%q header is required to look up the sender's public key`, SenderKeyIDKey)
+		}
+		found, ok := set.LookupKeyID(skid)
+		if !ok {
+			return nil, errors.Errorf(`sender's public key %q not found`, skid)
+		}
+		key = found
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		pubkey, err := jwk.PublicKeyOf(jwkKey)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to retrieve the sender's public key`)
+		}
+		var raw interface{}
+		if err := pubkey.Raw(&raw); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve raw key out of %T`, key)
+		}
+		key = raw
+	}
+
+	switch key := key.(type) {
+	case x25519.PublicKey:
+		return key, nil
+	default:
+		var pubkey ecdsa.PublicKey
+		if err := keyconv.ECDSAPublicKey(&pubkey, key); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve the sender's public key from %T`, key)
+		}
+		return &pubkey, nil
+	}
+}
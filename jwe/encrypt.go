@@ -5,7 +5,9 @@ import (
 	"sync"
 
 	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe/internal/keyenc"
 	"github.com/lestrrat-go/pdebug/v3"
 	"github.com/pkg/errors"
 )
@@ -26,9 +28,23 @@ func releaseEncryptCtx(ctx *encryptCtx) {
 	ctx.generator = nil
 	ctx.keyEncrypters = nil
 	ctx.compress = jwa.NoCompress
+	ctx.cekReceiver = nil
+	ctx.zeroize = false
+	ctx.quirks = 0
 	encryptCtxPool.Put(ctx)
 }
 
+func asTagBoundEncrypter(enc keyenc.Encrypter) (tagBoundEncrypter, bool) {
+	if wrapped, ok := enc.(*keyIDEncrypter); ok {
+		enc = wrapped.Encrypter
+	}
+	if enc.Algorithm() == jwa.ECDH_1PU {
+		return nil, false
+	}
+	tbe, ok := enc.(tagBoundEncrypter)
+	return tbe, ok
+}
+
 // Encrypt takes the plaintext and encrypts into a JWE message.
 func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 	if pdebug.Enabled {
@@ -44,6 +60,10 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 		return nil, errors.Wrap(err, "failed to generate key")
 	}
 	cek := bk.Bytes()
+	if e.zeroize {
+		// the generator always returns a fresh copy of the CEK
+		defer zeroize.Bytes(cek)
+	}
 
 	if pdebug.Enabled {
 		pdebug.Printf("Encrypt: generated cek len = %d", len(cek))
@@ -69,6 +89,7 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 	// encrypted version of the CEK, using their key encryption
 	// algorithm of choice.
 	recipients := make([]Recipient, len(e.keyEncrypters))
+	var tagBound []int
 	for i, enc := range e.keyEncrypters {
 		r := NewRecipient()
 		if err := r.Headers().Set(AlgorithmKey, enc.Algorithm()); err != nil {
@@ -80,6 +101,14 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 			}
 		}
 
+		// The CEK can only be encrypted after the content has been
+		// encrypted, as the authentication tag is required
+		if _, ok := asTagBoundEncrypter(enc); ok {
+			tagBound = append(tagBound, i)
+			recipients[i] = r
+			continue
+		}
+
 		enckey, err := enc.Encrypt(cek)
 		if err != nil {
 			if pdebug.Enabled {
@@ -87,11 +116,15 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 			}
 			return nil, errors.Wrap(err, `failed to encrypt key`)
 		}
-		if enc.Algorithm() == jwa.ECDH_ES || enc.Algorithm() == jwa.DIRECT {
+		if alg := enc.Algorithm(); alg == jwa.ECDH_ES || alg == jwa.ECDH_1PU || alg == jwa.DIRECT {
 			if len(e.keyEncrypters) > 1 {
-				return nil, errors.Errorf("unable to support multiple recipients for ECDH-ES")
+				return nil, errors.Errorf("unable to support multiple recipients for %s", alg)
 			}
 			cek = enckey.Bytes()
+			if e.zeroize && alg != jwa.DIRECT {
+				// the derived key. In direct mode this is the caller's shared key
+				defer zeroize.Bytes(cek)
+			}
 		} else {
 			if err := r.SetEncryptedKey(enckey.Bytes()); err != nil {
 				return nil, errors.Wrap(err, "failed to set encrypted key")
@@ -102,6 +135,11 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 				return nil, errors.Wrap(err, "failed to populate")
 			}
 		}
+		if e.quirks&QuirkUnpaddedEPK != 0 {
+			if err := unpadEPK(r.Headers()); err != nil {
+				return nil, err
+			}
+		}
 		if pdebug.Enabled {
 			pdebug.Printf("Encrypt: encrypted_key = %x (%d)", enckey.Bytes(), len(enckey.Bytes()))
 		}
@@ -118,6 +156,13 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 		e.protected = h
 	}
 
+	// ECDH-1PU stores the ephemeral key in the protected header
+	if e.quirks&QuirkUnpaddedEPK != 0 {
+		if err := unpadEPK(e.protected); err != nil {
+			return nil, err
+		}
+	}
+
 	aad, err := e.protected.Encode()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to base64 encode protected headers")
@@ -137,6 +182,21 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 		return nil, errors.Wrap(err, "failed to encrypt payload")
 	}
 
+	for _, i := range tagBound {
+		tbe, _ := asTagBoundEncrypter(e.keyEncrypters[i])
+		enckey, err := tbe.EncryptWithTag(cek, tag)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to encrypt key`)
+		}
+		if err := recipients[i].SetEncryptedKey(enckey.Bytes()); err != nil {
+			return nil, errors.Wrap(err, "failed to set encrypted key")
+		}
+	}
+
+	if e.cekReceiver != nil {
+		*e.cekReceiver = append([]byte(nil), cek...)
+	}
+
 	if pdebug.Enabled {
 		pdebug.Printf("Encrypt.Encrypt: cek        = %x (%d)", cek, len(cek))
 		pdebug.Printf("Encrypt.Encrypt: aad        = %x (%d)", aad, len(aad))
@@ -0,0 +1,122 @@
+package jwe
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/jwa"
+)
+
+// ParseError is returned when a JWE message could not be parsed.
+// It matches `jwx.ErrParse` via `errors.Is()`
+type ParseError struct {
+	err error
+}
+
+func newParseError(err error) error {
+	return &ParseError{err: err}
+}
+
+func (e *ParseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == jwx.ErrParse
+}
+
+// DecryptError is returned when a JWE message could not be decrypted.
+// It matches `jwx.ErrDecryption` via `errors.Is()`
+type DecryptError struct {
+	err error
+}
+
+func newDecryptError(err error) error {
+	return &DecryptError{err: err}
+}
+
+func (e *DecryptError) Error() string {
+	return e.err.Error()
+}
+
+func (e *DecryptError) Unwrap() error {
+	return e.err
+}
+
+func (e *DecryptError) Is(target error) bool {
+	return target == jwx.ErrDecryption
+}
+
+// RecipientError describes why a recipient in a JWE message could not
+// be used to decrypt it
+type RecipientError struct {
+	index int
+	alg   jwa.KeyEncryptionAlgorithm
+	kid   string
+	err   error
+}
+
+// Index returns the position of the recipient in the message
+func (e *RecipientError) Index() int {
+	return e.index
+}
+
+// Algorithm returns the value of the "alg" header of the recipient
+func (e *RecipientError) Algorithm() jwa.KeyEncryptionAlgorithm {
+	return e.alg
+}
+
+// KeyID returns the value of the "kid" header of the recipient, if any
+func (e *RecipientError) KeyID() string {
+	return e.kid
+}
+
+func (e *RecipientError) Error() string {
+	if e.kid == "" {
+		return fmt.Sprintf(`recipient #%d (alg=%s): %s`, e.index, e.alg, e.err)
+	}
+	return fmt.Sprintf(`recipient #%d (alg=%s, kid=%s): %s`, e.index, e.alg, e.kid, e.err)
+}
+
+func (e *RecipientError) Unwrap() error {
+	return e.err
+}
+
+// RecipientsError is returned when none of the recipients in a JWE
+// message could be used to decrypt it. It lists the reason each of the
+// recipients failed, and matches `jwx.ErrDecryption` via `errors.Is()`
+type RecipientsError struct {
+	errs []*RecipientError
+}
+
+// Errors returns the error for each recipient in the message, in the
+// order in which they appear
+func (e *RecipientsError) Errors() []*RecipientError {
+	return e.errs
+}
+
+func (e *RecipientsError) Error() string {
+	var buf strings.Builder
+	buf.WriteString(`failed to find matching recipient to decrypt key`)
+	for i, err := range e.errs {
+		if i == 0 {
+			buf.WriteString(` (`)
+		} else {
+			buf.WriteString(`; `)
+		}
+		buf.WriteString(err.Error())
+	}
+	if len(e.errs) > 0 {
+		buf.WriteByte(')')
+	}
+	return buf.String()
+}
+
+func (e *RecipientsError) Is(target error) bool {
+	return target == jwx.ErrDecryption
+}
@@ -28,6 +28,7 @@ const (
 	JWKKey                    = "jwk"
 	JWKSetURLKey              = "jku"
 	KeyIDKey                  = "kid"
+	SenderKeyIDKey            = "skid"
 	TypeKey                   = "typ"
 	X509CertChainKey          = "x5c"
 	X509CertThumbprintKey     = "x5t"
@@ -50,6 +51,7 @@ type Headers interface {
 	JWK() jwk.Key
 	JWKSetURL() string
 	KeyID() string
+	SenderKeyID() string
 	Type() string
 	X509CertChain() []string
 	X509CertThumbprint() string
@@ -85,6 +87,7 @@ type stdHeaders struct {
 	jwk                    jwk.Key                         //
 	jwkSetURL              *string                         //
 	keyID                  *string                         //
+	senderKeyID            *string                         //
 	typ                    *string                         //
 	x509CertChain          []string                        //
 	x509CertThumbprint     *string                         //
@@ -185,6 +188,15 @@ func (h *stdHeaders) KeyID() string {
 	return *(h.keyID)
 }
 
+func (h *stdHeaders) SenderKeyID() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.senderKeyID == nil {
+		return ""
+	}
+	return *(h.senderKeyID)
+}
+
 func (h *stdHeaders) Type() string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -264,6 +276,9 @@ func (h *stdHeaders) makePairs() []*HeaderPair {
 	if h.keyID != nil {
 		pairs = append(pairs, &HeaderPair{Key: KeyIDKey, Value: *(h.keyID)})
 	}
+	if h.senderKeyID != nil {
+		pairs = append(pairs, &HeaderPair{Key: SenderKeyIDKey, Value: *(h.senderKeyID)})
+	}
 	if h.typ != nil {
 		pairs = append(pairs, &HeaderPair{Key: TypeKey, Value: *(h.typ)})
 	}
@@ -350,6 +365,11 @@ func (h *stdHeaders) Get(name string) (interface{}, bool) {
 			return nil, false
 		}
 		return *(h.keyID), true
+	case SenderKeyIDKey:
+		if h.senderKeyID == nil {
+			return nil, false
+		}
+		return *(h.senderKeyID), true
 	case TypeKey:
 		if h.typ == nil {
 			return nil, false
@@ -458,6 +478,12 @@ func (h *stdHeaders) setNoLock(name string, value interface{}) error {
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, KeyIDKey, value)
+	case SenderKeyIDKey:
+		if v, ok := value.(string); ok {
+			h.senderKeyID = &v
+			return nil
+		}
+		return errors.Errorf(`invalid value for %s key: %T`, SenderKeyIDKey, value)
 	case TypeKey:
 		if v, ok := value.(string); ok {
 			h.typ = &v
@@ -523,6 +549,8 @@ func (h *stdHeaders) Remove(key string) error {
 		h.jwkSetURL = nil
 	case KeyIDKey:
 		h.keyID = nil
+	case SenderKeyIDKey:
+		h.senderKeyID = nil
 	case TypeKey:
 		h.typ = nil
 	case X509CertChainKey:
@@ -551,6 +579,7 @@ func (h *stdHeaders) UnmarshalJSON(buf []byte) error {
 	h.jwk = nil
 	h.jwkSetURL = nil
 	h.keyID = nil
+	h.senderKeyID = nil
 	h.typ = nil
 	h.x509CertChain = nil
 	h.x509CertThumbprint = nil
@@ -638,6 +667,10 @@ LOOP:
 				if err := json.AssignNextStringToken(&h.keyID, dec); err != nil {
 					return errors.Wrapf(err, `failed to decode value for key %s`, KeyIDKey)
 				}
+			case SenderKeyIDKey:
+				if err := json.AssignNextStringToken(&h.senderKeyID, dec); err != nil {
+					return errors.Wrapf(err, `failed to decode value for key %s`, SenderKeyIDKey)
+				}
 			case TypeKey:
 				if err := json.AssignNextStringToken(&h.typ, dec); err != nil {
 					return errors.Wrapf(err, `failed to decode value for key %s`, TypeKey)
@@ -678,7 +711,7 @@ func (h stdHeaders) MarshalJSON() ([]byte, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	data := make(map[string]interface{})
-	fields := make([]string, 0, 16)
+	fields := make([]string, 0, 17)
 	for iter := h.Iterate(ctx); iter.Next(ctx); {
 		pair := iter.Pair()
 		fields = append(fields, pair.Key.(string))
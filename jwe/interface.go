@@ -57,6 +57,8 @@ type Message struct {
 	// When this flag is true, UnmarshalJSON() will populate the
 	// rawProtectedHeaders field
 	storeProtectedHeaders bool
+	// quirks are the interop quirks to apply in UnmarshalJSON()
+	quirks InteropQuirk
 }
 
 // contentEncrypter encrypts the content using the content using the
@@ -73,6 +75,16 @@ type encryptCtx struct {
 	contentEncrypter contentEncrypter
 	generator        keygen.Generator
 	compress         jwa.CompressionAlgorithm
+	cekReceiver      *[]byte
+	zeroize          bool
+	quirks           InteropQuirk
+}
+
+// tagBoundEncrypter is implemented by key encrypters that require the
+// authentication tag of the content to encrypt the CEK, i.e. ECDH-1PU
+// in key wrapping modes
+type tagBoundEncrypter interface {
+	EncryptWithTag(cek, tag []byte) (keygen.ByteSource, error)
 }
 
 // populater is an interface for things that may modify the
@@ -154,8 +154,9 @@ func (c AesContentCipher) Encrypt(cek, plaintext, aad []byte) (iv, ciphertext, t
 		pdebug.Printf("tagsize = %d", c.TagSize())
 	}
 	tag = combined[tagoffset:]
-	ciphertext = make([]byte, tagoffset)
-	copy(ciphertext, combined[:tagoffset])
+	// The ciphertext shares its backing array with the tag. The capacity
+	// is limited so that appending to it does not overwrite the tag.
+	ciphertext = combined[:tagoffset:tagoffset]
 
 	if pdebug.Enabled {
 		pdebug.Printf("encrypt: combined   = %x (%d)\n", combined, len(combined))
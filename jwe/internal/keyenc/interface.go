@@ -1,6 +1,7 @@
 package keyenc
 
 import (
+	"crypto"
 	"crypto/rsa"
 	"hash"
 
@@ -62,6 +63,31 @@ type ECDHESDecrypt struct {
 	pubkey     interface{}
 }
 
+// ECDH1PUEncrypt encrypts content encryption keys using ECDH-1PU.
+type ECDH1PUEncrypt struct {
+	algorithm  jwa.KeyEncryptionAlgorithm
+	contentalg jwa.ContentEncryptionAlgorithm
+	keyID      string
+	keysize    int
+	apu        []byte
+	apv        []byte
+	ephemeral  interface{}
+	sender     interface{}
+	recipient  interface{}
+}
+
+// ECDH1PUDecrypt decrypts keys using ECDH-1PU.
+type ECDH1PUDecrypt struct {
+	keyalg     jwa.KeyEncryptionAlgorithm
+	contentalg jwa.ContentEncryptionAlgorithm
+	apu        []byte
+	apv        []byte
+	tag        []byte
+	privkey    interface{}
+	pubkey     interface{}
+	senderkey  interface{}
+}
+
 // RSAOAEPEncrypt encrypts keys using RSA OAEP algorithm
 type RSAOAEPEncrypt struct {
 	alg    jwa.KeyEncryptionAlgorithm
@@ -75,6 +101,13 @@ type RSAOAEPDecrypt struct {
 	privkey *rsa.PrivateKey
 }
 
+// CryptoDecrypt decrypts keys using a crypto.Decrypter, such as
+// RSA keys stored in a KMS or an HSM
+type CryptoDecrypt struct {
+	alg       jwa.KeyEncryptionAlgorithm
+	decrypter crypto.Decrypter
+}
+
 // RSAPKCS15Decrypt decrypts keys using RSA PKCS1v15 algorithm
 type RSAPKCS15Decrypt struct {
 	alg       jwa.KeyEncryptionAlgorithm
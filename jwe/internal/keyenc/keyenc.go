@@ -386,6 +386,290 @@ func (kw ECDHESDecrypt) Decrypt(enckey []byte) ([]byte, error) {
 	return Unwrap(block, enckey)
 }
 
+// GenerateEphemeralKey generates an ephemeral private key of the same
+// type (and on the same curve) as the private key `key`
+func GenerateEphemeralKey(key interface{}) (interface{}, error) {
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return ecdsa.GenerateKey(key.Curve, rand.Reader)
+	case x25519.PrivateKey:
+		_, priv, err := x25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate x25519 key`)
+		}
+		return priv, nil
+	default:
+		return nil, errors.Errorf(`unexpected key type %T`, key)
+	}
+}
+
+// ephemeralPublicKey returns the public key of the ephemeral key
+// generated by GenerateEphemeralKey
+func ephemeralPublicKey(key interface{}) (interface{}, error) {
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey, nil
+	case x25519.PrivateKey:
+		return key.Public(), nil
+	default:
+		return nil, errors.Errorf(`unexpected key type %T`, key)
+	}
+}
+
+// CheckECDH1PUContentAlgorithm reports an error if the content encryption
+// algorithm `contentalg` cannot be used with the ECDH-1PU key agreement
+// algorithm `keyalg`. The key wrapping modes require the content to be
+// encrypted using AES-CBC-HMAC, so that the authentication tag is bound
+// to the sender's key
+func CheckECDH1PUContentAlgorithm(keyalg jwa.KeyEncryptionAlgorithm, contentalg jwa.ContentEncryptionAlgorithm) error {
+	if keyalg == jwa.ECDH_1PU {
+		return nil
+	}
+
+	switch contentalg {
+	case jwa.A128CBC_HS256, jwa.A192CBC_HS384, jwa.A256CBC_HS512:
+		return nil
+	default:
+		return errors.Errorf(`%s requires an AES-CBC-HMAC content encryption algorithm (got %s)`, keyalg, contentalg)
+	}
+}
+
+// ecdh1puKeySize returns the size of the key derived by ECDH-1PU
+func ecdh1puKeySize(keyalg jwa.KeyEncryptionAlgorithm, contentalg jwa.ContentEncryptionAlgorithm) (uint32, error) {
+	switch keyalg {
+	case jwa.ECDH_1PU:
+		c, err := contentcipher.NewAES(contentalg)
+		if err != nil {
+			return 0, errors.Wrapf(err, `failed to create content cipher for %s`, contentalg)
+		}
+		return uint32(c.KeySize()), nil
+	case jwa.ECDH_1PU_A128KW:
+		return 16, nil
+	case jwa.ECDH_1PU_A192KW:
+		return 24, nil
+	case jwa.ECDH_1PU_A256KW:
+		return 32, nil
+	default:
+		return 0, errors.Errorf("invalid ECDH-1PU key agreement algorithm (%s)", keyalg)
+	}
+}
+
+// DeriveECDH1PU derives a key using the Concat KDF, with the
+// concatenation of the ephemeral-static shared secret `ze` and the
+// static-static shared secret `zs` as Z, as described in
+// https://datatracker.ietf.org/doc/html/draft-madden-jose-ecdh-1pu-04#section-2.3
+//
+// In the key wrapping modes `tag` must be the authentication tag of the
+// content, which is appended to SuppPubInfo. It must be nil in direct
+// key agreement mode.
+func DeriveECDH1PU(alg, apu, apv, ze, zs []byte, keysize uint32, tag []byte) ([]byte, error) {
+	if pdebug.Enabled {
+		g := pdebug.FuncMarker()
+		defer g.End()
+	}
+
+	pubinfo := make([]byte, 4, 8+len(tag))
+	binary.BigEndian.PutUint32(pubinfo, keysize*8)
+	if tag != nil {
+		var taglen [4]byte
+		binary.BigEndian.PutUint32(taglen[:], uint32(len(tag)))
+		pubinfo = append(pubinfo, taglen[:]...)
+		pubinfo = append(pubinfo, tag...)
+	}
+
+	z := make([]byte, 0, len(ze)+len(zs))
+	z = append(z, ze...)
+	z = append(z, zs...)
+
+	kdf := concatkdf.New(crypto.SHA256, alg, z, apu, apv, pubinfo, []byte{})
+	key := make([]byte, keysize)
+	if _, err := kdf.Read(key); err != nil {
+		return nil, errors.Wrap(err, "failed to read kdf")
+	}
+	return key, nil
+}
+
+// NewECDH1PUEncrypt creates a new key encrypter based on ECDH-1PU.
+// `ephemeral` and `sender` are the ephemeral and the static private keys
+// of the sender, and `recipient` is the public key of the recipient.
+// All keys must be of the same type, and on the same curve.
+//
+// `keysize` is only used in direct key agreement mode, where it must be
+// the size of the content encryption key.
+func NewECDH1PUEncrypt(alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionAlgorithm, keysize int, apu, apv []byte, ephemeral, sender, recipient interface{}) (*ECDH1PUEncrypt, error) {
+	if err := CheckECDH1PUContentAlgorithm(alg, enc); err != nil {
+		return nil, err
+	}
+
+	if alg != jwa.ECDH_1PU {
+		size, err := ecdh1puKeySize(alg, enc)
+		if err != nil {
+			return nil, err
+		}
+		keysize = int(size)
+	}
+
+	return &ECDH1PUEncrypt{
+		algorithm:  alg,
+		contentalg: enc,
+		keysize:    keysize,
+		apu:        apu,
+		apv:        apv,
+		ephemeral:  ephemeral,
+		sender:     sender,
+		recipient:  recipient,
+	}, nil
+}
+
+// Algorithm returns the key encryption algorithm being used
+func (kw ECDH1PUEncrypt) Algorithm() jwa.KeyEncryptionAlgorithm {
+	return kw.algorithm
+}
+
+// KeyID returns the key ID associated with this encrypter
+func (kw ECDH1PUEncrypt) KeyID() string {
+	return kw.keyID
+}
+
+// EphemeralPublicKey returns the public key of the ephemeral key,
+// which must be sent to the recipient in the "epk" header
+func (kw ECDH1PUEncrypt) EphemeralPublicKey() (interface{}, error) {
+	return ephemeralPublicKey(kw.ephemeral)
+}
+
+func (kw ECDH1PUEncrypt) derive(tag []byte) ([]byte, error) {
+	ze, err := DeriveZ(kw.ephemeral, kw.recipient)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to compute ephemeral-static shared secret`)
+	}
+	zs, err := DeriveZ(kw.sender, kw.recipient)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to compute static-static shared secret`)
+	}
+
+	alg := kw.algorithm.String()
+	if kw.algorithm == jwa.ECDH_1PU {
+		alg = kw.contentalg.String()
+	}
+	return DeriveECDH1PU([]byte(alg), kw.apu, kw.apv, ze, zs, uint32(kw.keysize), tag)
+}
+
+// Encrypt returns the key derived using ECDH-1PU in direct key agreement
+// mode. In key wrapping modes the derived key depends on the
+// authentication tag of the content, and EncryptWithTag must be used
+// instead.
+func (kw ECDH1PUEncrypt) Encrypt(_ []byte) (keygen.ByteSource, error) {
+	if kw.algorithm != jwa.ECDH_1PU {
+		return nil, errors.Errorf(`%s requires the authentication tag of the content to wrap the key`, kw.algorithm)
+	}
+
+	key, err := kw.derive(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to derive ECDH-1PU key`)
+	}
+	return keygen.ByteKey(key), nil
+}
+
+// EncryptWithTag wraps the content encryption key using the key derived
+// using ECDH-1PU, where `tag` is the authentication tag of the content
+func (kw ECDH1PUEncrypt) EncryptWithTag(cek, tag []byte) (keygen.ByteSource, error) {
+	if kw.algorithm == jwa.ECDH_1PU {
+		return nil, errors.Errorf(`%s does not wrap keys`, kw.algorithm)
+	}
+
+	kek, err := kw.derive(tag)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to derive ECDH-1PU key`)
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate cipher from derived key")
+	}
+
+	jek, err := Wrap(block, cek)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to wrap data")
+	}
+	return keygen.ByteKey(jek), nil
+}
+
+// NewECDH1PUDecrypt creates a new key decrypter using ECDH-1PU.
+// `pubkey` is the ephemeral public key of the sender ("epk"), `senderkey`
+// is the static public key of the sender, and `tag` is the
+// authentication tag of the content.
+func NewECDH1PUDecrypt(keyalg jwa.KeyEncryptionAlgorithm, contentalg jwa.ContentEncryptionAlgorithm, pubkey, senderkey interface{}, apu, apv, tag []byte, privkey interface{}) *ECDH1PUDecrypt {
+	return &ECDH1PUDecrypt{
+		keyalg:     keyalg,
+		contentalg: contentalg,
+		apu:        apu,
+		apv:        apv,
+		tag:        tag,
+		privkey:    privkey,
+		pubkey:     pubkey,
+		senderkey:  senderkey,
+	}
+}
+
+// Algorithm returns the key encryption algorithm being used
+func (kw ECDH1PUDecrypt) Algorithm() jwa.KeyEncryptionAlgorithm {
+	return kw.keyalg
+}
+
+// Decrypt decrypts the encrypted key using ECDH-1PU
+func (kw ECDH1PUDecrypt) Decrypt(enckey []byte) ([]byte, error) {
+	if pdebug.Enabled {
+		g := pdebug.FuncMarker()
+		defer g.End()
+	}
+
+	if err := CheckECDH1PUContentAlgorithm(kw.keyalg, kw.contentalg); err != nil {
+		return nil, err
+	}
+
+	keysize, err := ecdh1puKeySize(kw.keyalg, kw.contentalg)
+	if err != nil {
+		return nil, err
+	}
+
+	ze, err := DeriveZ(kw.privkey, kw.pubkey)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to compute ephemeral-static shared secret`)
+	}
+	zs, err := DeriveZ(kw.privkey, kw.senderkey)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to compute static-static shared secret`)
+	}
+
+	alg := kw.keyalg.String()
+	var tag []byte
+	if kw.keyalg == jwa.ECDH_1PU {
+		alg = kw.contentalg.String()
+	} else {
+		tag = kw.tag
+		if tag == nil {
+			tag = []byte{}
+		}
+	}
+
+	key, err := DeriveECDH1PU([]byte(alg), kw.apu, kw.apv, ze, zs, keysize, tag)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to derive ECDH-1PU key`)
+	}
+
+	// ECDH-1PU in direct key agreement mode does not wrap keys
+	if kw.keyalg == jwa.ECDH_1PU {
+		return key, nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher for ECDH-1PU key wrap")
+	}
+
+	return Unwrap(block, enckey)
+}
+
 // NewRSAOAEPEncrypt creates a new key encrypter using RSA OAEP
 func NewRSAOAEPEncrypt(alg jwa.KeyEncryptionAlgorithm, pubkey *rsa.PublicKey) (*RSAOAEPEncrypt, error) {
 	switch alg {
@@ -563,6 +847,49 @@ func (d RSAOAEPDecrypt) Decrypt(enckey []byte) ([]byte, error) {
 	return rsa.DecryptOAEP(hash, rand.Reader, d.privkey, enckey, []byte{})
 }
 
+// NewCryptoDecrypt creates a new key decrypter that delegates the
+// decryption of keys to the given crypto.Decrypter
+func NewCryptoDecrypt(alg jwa.KeyEncryptionAlgorithm, decrypter crypto.Decrypter) (*CryptoDecrypt, error) {
+	switch alg {
+	case jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256:
+	default:
+		return nil, errors.Errorf("invalid crypto.Decrypter algorithm (%s)", alg)
+	}
+
+	return &CryptoDecrypt{
+		alg:       alg,
+		decrypter: decrypter,
+	}, nil
+}
+
+// Algorithm returns the key encryption algorithm being used
+func (d CryptoDecrypt) Algorithm() jwa.KeyEncryptionAlgorithm {
+	return d.alg
+}
+
+// Decrypt decrypts the encrypted key using the crypto.Decrypter.
+//
+// Unlike RSAPKCS15Decrypt, failures to decrypt RSA1_5 keys are
+// reported as errors, as the decryption is performed outside of
+// this library.
+func (d CryptoDecrypt) Decrypt(enckey []byte) ([]byte, error) {
+	var opts crypto.DecrypterOpts
+	switch d.alg {
+	case jwa.RSA1_5:
+		opts = &rsa.PKCS1v15DecryptOptions{}
+	case jwa.RSA_OAEP:
+		opts = &rsa.OAEPOptions{Hash: crypto.SHA1}
+	case jwa.RSA_OAEP_256:
+		opts = &rsa.OAEPOptions{Hash: crypto.SHA256}
+	}
+
+	cek, err := d.decrypter.Decrypt(rand.Reader, enckey, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decrypt key using crypto.Decrypter`)
+	}
+	return cek, nil
+}
+
 // Decrypt for DirectDecrypt does not do anything other than
 // return a copy of the embedded key
 func (d DirectDecrypt) Decrypt() ([]byte, error) {
@@ -5,14 +5,19 @@ package jwe
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"io"
 	"io/ioutil"
+	"strings"
 
 	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/fips"
+	"github.com/lestrrat-go/jwx/internal/hook"
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/internal/keyconv"
+	"github.com/lestrrat-go/jwx/internal/limits"
 	"github.com/lestrrat-go/jwx/jwk"
 
 	"github.com/lestrrat-go/jwx/jwa"
@@ -29,30 +34,326 @@ var registry = json.NewRegistry()
 // Encrypt takes the plaintext payload and encrypts it in JWE compact format.
 // `key` should be a public key, and it may be a raw key (e.g. rsa.PublicKey) or a jwk.Key
 //
-// Encrypt currently does not support multi-recipient messages.
+// Encrypt does not support multi-recipient messages. Use EncryptMulti
+// to encrypt a payload for multiple recipients.
+//
+// The ECDH-1PU key agreement algorithms additionally require the static
+// private key of the sender, which must be specified using
+// `jwe.WithSenderKey()`.
 func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...EncryptOption) ([]byte, error) {
+	span := hook.Start(hook.EncryptKind)
+	span.SetAlgorithm(keyalg.String())
+	if jwkKey, ok := key.(jwk.Key); ok {
+		span.SetKeyID(jwkKey.KeyID())
+	}
+	buf, err := encrypt(payload, keyalg, key, contentalg, compressalg, options...)
+	span.End(err)
+	return buf, err
+}
+
+func encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...EncryptOption) ([]byte, error) {
 	if pdebug.Enabled {
 		g := pdebug.FuncMarker()
 		defer g.End()
 	}
 
 	var protected Headers
+	var cek []byte
+	var cekReceiver *[]byte
+	var senderKey interface{}
+	var zeroizeCEK bool
+	var quirks InteropQuirk
 	for _, option := range options {
 		//nolint:forcetypeassert
 		switch option.Ident() {
 		case identProtectedHeader{}:
 			protected = option.Value().(Headers)
+		case identContentEncryptionKey{}:
+			cek = option.Value().([]byte)
+		case identCEKReceiver{}:
+			cekReceiver = option.Value().(*[]byte)
+		case identSenderKey{}:
+			senderKey = option.Value()
+		case identZeroizeCEK{}:
+			zeroizeCEK = option.Value().(bool)
+		case identInteropQuirk{}:
+			quirks |= option.Value().(InteropQuirk)
 		}
 	}
 	if protected == nil {
 		protected = NewHeaders()
 	}
 
+	if err := fips.CheckContentEncryptionAlgorithm(contentalg); err != nil {
+		return nil, err
+	}
+
 	contentcrypt, err := content_crypt.NewGeneric(contentalg)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to create AES encrypter`)
 	}
 
+	generator, err := newCEKGenerator(cek, contentcrypt.KeySize(), keyalg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkKeySeparation(key); err != nil {
+		return nil, errors.Wrap(err, `failed to encrypt payload`)
+	}
+
+	var enc keyenc.Encrypter
+	if isECDH1PU(keyalg) {
+		params, err := prepareECDH1PU(protected, senderKey)
+		if err != nil {
+			return nil, err
+		}
+		enc, err = newECDH1PUKeyEncrypter(keyalg, key, contentalg, contentcrypt.KeySize(), params)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		enc, err = newKeyEncrypter(keyalg, key, contentalg, contentcrypt.KeySize())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keysize := contentcrypt.KeySize()
+	if pdebug.Enabled {
+		pdebug.Printf("Encrypt: keysize = %d", keysize)
+	}
+	encctx := getEncryptCtx()
+	defer releaseEncryptCtx(encctx)
+
+	encctx.protected = protected
+	encctx.contentEncrypter = contentcrypt
+	encctx.generator = generator
+	encctx.keyEncrypters = []keyenc.Encrypter{enc}
+	encctx.compress = compressalg
+	encctx.cekReceiver = cekReceiver
+	encctx.zeroize = zeroizeCEK
+	encctx.quirks = quirks
+	msg, err := encctx.Encrypt(payload)
+	if err != nil {
+		if pdebug.Enabled {
+			pdebug.Printf("Encrypt: failed to encrypt: %s", err)
+		}
+		return nil, errors.Wrap(err, "failed to encrypt payload")
+	}
+
+	return Compact(msg)
+}
+
+// EncryptContext is the same as Encrypt, but accepts a context.Context.
+// An error is returned without encrypting if the context has already
+// been canceled.
+func EncryptContext(ctx context.Context, payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...EncryptOption) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `failed to encrypt payload`)
+	}
+	return Encrypt(payload, keyalg, key, contentalg, compressalg, options...)
+}
+
+// EncryptMulti encrypts the plaintext payload for multiple recipients,
+// and returns the message in JWE JSON serialization format. All recipients
+// share the same content encryption key, which is encrypted for each of
+// them using their respective key encryption algorithm and key.
+//
+// Use `jwe.WithRecipient(...)` to specify each recipient. If the key for
+// a recipient is a jwk.Key with a "kid", the key ID is included in
+// the recipient's header.
+//
+// Because the content encryption key cannot be shared, ECDH-ES, ECDH-1PU
+// and dir can only be used when there is exactly one recipient.
+// The key wrapping modes of ECDH-1PU can be used for any number of
+// recipients, but not along with ECDH-ES in the same message.
+//
+// When a hook is set via `jwx.WithHook()`, the algorithms and key IDs
+// of all recipients are reported as a comma separated list.
+func EncryptMulti(payload []byte, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...EncryptOption) ([]byte, error) {
+	span := hook.Start(hook.EncryptKind)
+	buf, err := encryptMulti(payload, contentalg, compressalg, span, options...)
+	span.End(err)
+	return buf, err
+}
+
+func encryptMulti(payload []byte, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, span *hook.Span, options ...EncryptOption) ([]byte, error) {
+	if pdebug.Enabled {
+		g := pdebug.FuncMarker()
+		defer g.End()
+	}
+
+	var protected Headers
+	var recipients []*recipientSpec
+	var cek []byte
+	var cekReceiver *[]byte
+	var senderKey interface{}
+	var zeroizeCEK bool
+	var quirks InteropQuirk
+	for _, option := range options {
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identProtectedHeader{}:
+			protected = option.Value().(Headers)
+		case identRecipient{}:
+			recipients = append(recipients, option.Value().(*recipientSpec))
+		case identContentEncryptionKey{}:
+			cek = option.Value().([]byte)
+		case identCEKReceiver{}:
+			cekReceiver = option.Value().(*[]byte)
+		case identSenderKey{}:
+			senderKey = option.Value()
+		case identZeroizeCEK{}:
+			zeroizeCEK = option.Value().(bool)
+		case identInteropQuirk{}:
+			quirks |= option.Value().(InteropQuirk)
+		}
+	}
+	if protected == nil {
+		protected = NewHeaders()
+	}
+
+	if len(recipients) == 0 {
+		return nil, errors.New(`no recipients specified`)
+	}
+
+	if err := fips.CheckContentEncryptionAlgorithm(contentalg); err != nil {
+		return nil, err
+	}
+
+	contentcrypt, err := content_crypt.NewGeneric(contentalg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create AES encrypter`)
+	}
+
+	if span != nil {
+		algs := make([]string, len(recipients))
+		kids := make([]string, len(recipients))
+		for i, recipient := range recipients {
+			algs[i] = recipient.alg.String()
+			if jwkKey, ok := recipient.key.(jwk.Key); ok {
+				kids[i] = jwkKey.KeyID()
+			}
+		}
+		span.SetAlgorithm(strings.Join(algs, ","))
+		span.SetKeyID(strings.Join(kids, ","))
+	}
+
+	// All ECDH-1PU recipients share the ephemeral key in the protected
+	// header, which would conflict with the per-recipient ephemeral
+	// keys of ECDH-ES
+	var params *ecdh1puParams
+	for _, recipient := range recipients {
+		if !isECDH1PU(recipient.alg) {
+			continue
+		}
+		for _, other := range recipients {
+			switch other.alg {
+			case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
+				return nil, errors.Errorf(`%s and %s cannot be used in the same message`, recipient.alg, other.alg)
+			}
+		}
+		p, err := prepareECDH1PU(protected, senderKey)
+		if err != nil {
+			return nil, err
+		}
+		params = p
+		break
+	}
+
+	encs := make([]keyenc.Encrypter, len(recipients))
+	var generator keygen.Generator
+	for i, recipient := range recipients {
+		g, err := newCEKGenerator(cek, contentcrypt.KeySize(), recipient.alg)
+		if err != nil {
+			return nil, errors.Wrapf(err, `invalid content encryption key for recipient #%d`, i)
+		}
+		generator = g
+
+		if err := checkKeySeparation(recipient.key); err != nil {
+			return nil, errors.Wrapf(err, `failed to encrypt payload for recipient #%d`, i)
+		}
+
+		var enc keyenc.Encrypter
+		if isECDH1PU(recipient.alg) {
+			enc, err = newECDH1PUKeyEncrypter(recipient.alg, recipient.key, contentalg, contentcrypt.KeySize(), params)
+		} else {
+			enc, err = newKeyEncrypter(recipient.alg, recipient.key, contentalg, contentcrypt.KeySize())
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create key encrypter for recipient #%d`, i)
+		}
+
+		if jwkKey, ok := recipient.key.(jwk.Key); ok && jwkKey.KeyID() != "" {
+			enc = &keyIDEncrypter{Encrypter: enc, keyID: jwkKey.KeyID()}
+		}
+		encs[i] = enc
+	}
+
+	encctx := getEncryptCtx()
+	defer releaseEncryptCtx(encctx)
+
+	encctx.protected = protected
+	encctx.contentEncrypter = contentcrypt
+	encctx.generator = generator
+	encctx.keyEncrypters = encs
+	encctx.compress = compressalg
+	encctx.cekReceiver = cekReceiver
+	encctx.zeroize = zeroizeCEK
+	encctx.quirks = quirks
+	msg, err := encctx.Encrypt(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt payload")
+	}
+
+	// The encryption context records the protected header as the
+	// authenticated data, but in JSON serialization "aad" denotes
+	// additional data supplied by the application. Since we didn't
+	// receive any, leave it out.
+	msg.authenticatedData = nil
+
+	return JSON(msg)
+}
+
+// newCEKGenerator returns the generator for the content encryption key.
+// If `cek` is specified, it is used as is, provided that the key
+// encryption algorithm actually encrypts the CEK.
+func newCEKGenerator(cek []byte, keysize int, keyalg jwa.KeyEncryptionAlgorithm) (keygen.Generator, error) {
+	if cek == nil {
+		return keygen.NewRandom(keysize), nil
+	}
+
+	switch keyalg {
+	case jwa.DIRECT, jwa.ECDH_ES, jwa.ECDH_1PU:
+		return nil, errors.Errorf(`content encryption key cannot be specified for %s`, keyalg)
+	}
+
+	if len(cek) != keysize {
+		return nil, errors.Errorf(`invalid content encryption key size: expected %d bytes, got %d`, keysize, len(cek))
+	}
+	return keygen.Static(cek), nil
+}
+
+// keyIDEncrypter wraps a key encrypter to report the key ID of the
+// recipient's key
+type keyIDEncrypter struct {
+	keyenc.Encrypter
+	keyID string
+}
+
+func (e *keyIDEncrypter) KeyID() string {
+	return e.keyID
+}
+
+// newKeyEncrypter creates the key encrypter for a single recipient.
+// `cekSize` is the size of the content encryption key, which is required
+// to derive the key in ECDH-ES direct key agreement mode.
+func newKeyEncrypter(keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, cekSize int) (keyenc.Encrypter, error) {
+	if err := fips.CheckKeyEncryptionAlgorithm(keyalg); err != nil {
+		return nil, err
+	}
+
 	if jwkKey, ok := key.(jwk.Key); ok {
 		var raw interface{}
 		if err := jwkKey.Raw(&raw); err != nil {
@@ -63,6 +364,7 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 	}
 
 	var enc keyenc.Encrypter
+	var err error
 	switch keyalg {
 	case jwa.RSA1_5:
 		var pubkey rsa.PublicKey
@@ -113,7 +415,7 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 			// https://tools.ietf.org/html/rfc7518#page-15
 			// In Direct Key Agreement mode, the output of the Concat KDF MUST be a
 			// key of the same length as that used by the "enc" algorithm.
-			keysize = contentcrypt.KeySize()
+			keysize = cekSize
 		case jwa.ECDH_ES_A128KW:
 			keysize = 16
 		case jwa.ECDH_ES_A192KW:
@@ -124,12 +426,18 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 
 		switch key := key.(type) {
 		case x25519.PublicKey:
+			if err := fips.CheckKeyCurve(key); err != nil {
+				return nil, err
+			}
 			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentalg, keysize, key)
 		default:
 			var pubkey ecdsa.PublicKey
 			if err := keyconv.ECDSAPublicKey(&pubkey, key); err != nil {
 				return nil, errors.Wrapf(err, "failed to generate public key from key (%T)", key)
 			}
+			if err := fips.CheckKeyCurve(&pubkey); err != nil {
+				return nil, err
+			}
 			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentalg, keysize, &pubkey)
 		}
 		if err != nil {
@@ -143,32 +451,12 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 		enc, _ = keyenc.NewNoop(keyalg, sharedkey)
 	default:
 		if pdebug.Enabled {
-			pdebug.Printf("Encrypt: unknown key encryption algorithm: %s", keyalg)
+			pdebug.Printf("newKeyEncrypter: unknown key encryption algorithm: %s", keyalg)
 		}
 		return nil, errors.Errorf(`invalid key encryption algorithm (%s)`, keyalg)
 	}
 
-	keysize := contentcrypt.KeySize()
-	if pdebug.Enabled {
-		pdebug.Printf("Encrypt: keysize = %d", keysize)
-	}
-	encctx := getEncryptCtx()
-	defer releaseEncryptCtx(encctx)
-
-	encctx.protected = protected
-	encctx.contentEncrypter = contentcrypt
-	encctx.generator = keygen.NewRandom(keysize)
-	encctx.keyEncrypters = []keyenc.Encrypter{enc}
-	encctx.compress = compressalg
-	msg, err := encctx.Encrypt(payload)
-	if err != nil {
-		if pdebug.Enabled {
-			pdebug.Printf("Encrypt: failed to encrypt: %s", err)
-		}
-		return nil, errors.Wrap(err, "failed to encrypt payload")
-	}
-
-	return Compact(msg)
+	return enc, nil
 }
 
 // DecryptCtx is used internally when jwe.Decrypt is called, and is
@@ -187,9 +475,12 @@ type DecryptCtx interface {
 }
 
 type decryptCtx struct {
-	alg jwa.KeyEncryptionAlgorithm
-	key interface{}
-	msg *Message
+	alg       jwa.KeyEncryptionAlgorithm
+	key       interface{}
+	msg       *Message
+	senderKey interface{}
+	zeroize   bool
+	quirks    InteropQuirk
 }
 
 func (ctx *decryptCtx) Algorithm() jwa.KeyEncryptionAlgorithm {
@@ -221,7 +512,32 @@ func (ctx *decryptCtx) SetMessage(m *Message) {
 // The JWE message can be either compact or full JSON format.
 //
 // `key` must be a private key. It can be either in its raw format (e.g. *rsa.PrivateKey) or a jwk.Key
+//
+// Messages encrypted using ECDH-1PU can only be decrypted if the static
+// public key of the sender is specified using `jwe.WithSenderPublicKey()`.
 func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, options ...DecryptOption) ([]byte, error) {
+	span := hook.Start(hook.DecryptKind)
+	span.SetAlgorithm(alg.String())
+	if jwkKey, ok := key.(jwk.Key); ok {
+		span.SetKeyID(jwkKey.KeyID())
+	}
+	payload, err := decrypt(buf, alg, key, options...)
+	span.End(err)
+	return payload, err
+}
+
+// DecryptContext is the same as Decrypt, but accepts a context.Context.
+// An error is returned without decrypting if the context has already
+// been canceled. If the key is a `jwe.ContextDecrypter`, the context
+// is also passed to it.
+func DecryptContext(ctx context.Context, buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, options ...DecryptOption) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `failed to decrypt message`)
+	}
+	return Decrypt(buf, alg, bindDecrypterContext(ctx, key), options...)
+}
+
+func decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, options ...DecryptOption) ([]byte, error) {
 	var ctx decryptCtx
 	ctx.key = key
 	ctx.alg = alg
@@ -235,10 +551,20 @@ func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, option
 			dst = option.Value().(*Message)
 		case identPostParser{}:
 			postParse = option.Value().(PostParser)
+		case identSenderPublicKey{}:
+			ctx.senderKey = option.Value()
+		case identZeroizeCEK{}:
+			ctx.zeroize = option.Value().(bool)
+		case identInteropQuirk{}:
+			ctx.quirks |= option.Value().(InteropQuirk)
 		}
 	}
 
-	msg, err := parseJSONOrCompact(buf, true)
+	if err := checkKeySeparation(key); err != nil {
+		return nil, errors.Wrap(err, `failed to decrypt message`)
+	}
+
+	msg, err := parseJSONOrCompact(buf, true, ctx.quirks)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse buffer for Decrypt")
 	}
@@ -252,13 +578,19 @@ func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, option
 
 	payload, err := doDecryptCtx(&ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, `failed to decrypt message`)
+		return nil, newDecryptError(errors.Wrap(err, `failed to decrypt message`))
 	}
 
 	if dst != nil {
+		if ctx.quirks&QuirkMissingContentType != 0 {
+			if err := fillContentType(msg.protectedHeaders, payload); err != nil {
+				return nil, errors.Wrap(err, `failed to set "cty" header`)
+			}
+		}
 		*dst = *msg
 		dst.rawProtectedHeaders = nil
 		dst.storeProtectedHeaders = false
+		dst.quirks = 0
 	}
 
 	return payload, nil
@@ -267,19 +599,30 @@ func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, option
 // Parse parses the JWE message into a Message object. The JWE message
 // can be either compact or full JSON format.
 func Parse(buf []byte) (*Message, error) {
-	return parseJSONOrCompact(buf, false)
+	return parseJSONOrCompact(buf, false, 0)
 }
 
-func parseJSONOrCompact(buf []byte, storeProtectedHeaders bool) (*Message, error) {
+func parseJSONOrCompact(buf []byte, storeProtectedHeaders bool, quirks InteropQuirk) (*Message, error) {
 	buf = bytes.TrimSpace(buf)
 	if len(buf) == 0 {
-		return nil, errors.New("empty buffer")
+		return nil, newParseError(errors.New("empty buffer"))
+	}
+
+	if err := limits.CheckInputSize(len(buf)); err != nil {
+		return nil, newParseError(err)
 	}
 
+	var msg *Message
+	var err error
 	if buf[0] == '{' {
-		return parseJSON(buf, storeProtectedHeaders)
+		msg, err = parseJSON(buf, storeProtectedHeaders, quirks)
+	} else {
+		msg, err = parseCompact(buf, storeProtectedHeaders, quirks)
+	}
+	if err != nil {
+		return nil, newParseError(err)
 	}
-	return parseCompact(buf, storeProtectedHeaders)
+	return msg, nil
 }
 
 // ParseString is the same as Parse, but takes a string.
@@ -289,6 +632,12 @@ func ParseString(s string) (*Message, error) {
 
 // ParseReader is the same as Parse, but takes an io.Reader.
 func ParseReader(src io.Reader) (*Message, error) {
+	if n := limits.MaxInputSize(); n > 0 {
+		// Read one extra byte so that Parse can detect that the
+		// input was too large
+		src = io.LimitReader(src, n+1)
+	}
+
 	buf, err := ioutil.ReadAll(src)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to read from io.Reader`)
@@ -296,16 +645,17 @@ func ParseReader(src io.Reader) (*Message, error) {
 	return Parse(buf)
 }
 
-func parseJSON(buf []byte, storeProtectedHeaders bool) (*Message, error) {
+func parseJSON(buf []byte, storeProtectedHeaders bool, quirks InteropQuirk) (*Message, error) {
 	m := NewMessage()
 	m.storeProtectedHeaders = storeProtectedHeaders
+	m.quirks = quirks
 	if err := json.Unmarshal(buf, &m); err != nil {
 		return nil, errors.Wrap(err, "failed to parse JSON")
 	}
 	return m, nil
 }
 
-func parseCompact(buf []byte, storeProtectedHeaders bool) (*Message, error) {
+func parseCompact(buf []byte, storeProtectedHeaders bool, quirks InteropQuirk) (*Message, error) {
 	if pdebug.Enabled {
 		pdebug.Printf("Parse(Compact): buf = '%s'", buf)
 	}
@@ -322,6 +672,17 @@ func parseCompact(buf []byte, storeProtectedHeaders bool) (*Message, error) {
 		pdebug.Printf("hdrbuf = %s", hdrbuf)
 	}
 
+	if err := limits.CheckHeader(hdrbuf); err != nil {
+		return nil, errors.Wrap(err, `invalid protected headers`)
+	}
+
+	if quirks&QuirkCaseInsensitiveEnc != 0 {
+		hdrbuf, err = normalizeEnc(hdrbuf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	protected := NewHeaders()
 	if err := json.Unmarshal(hdrbuf, protected); err != nil {
 		return nil, errors.Wrap(err, "failed to parse header JSON")
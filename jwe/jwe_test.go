@@ -1,25 +1,32 @@
 package jwe_test
 
 import (
+	"context"
 	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/lestrrat-go/jwx"
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -312,6 +319,9 @@ func TestRoundtrip_RSAES_OAEP_AES_GCM(t *testing.T) {
 }
 
 func TestRoundtrip_RSA1_5_A128CBC_HS256(t *testing.T) {
+	if jwx.FIPSMode() {
+		t.Skip(`RSA1_5 is not approved in FIPS mode`)
+	}
 	var plaintext = []byte{
 		76, 105, 118, 101, 32, 108, 111, 110, 103, 32, 97, 110, 100, 32,
 		112, 114, 111, 115, 112, 101, 114, 46,
@@ -436,6 +446,9 @@ func TestEncode_ECDH(t *testing.T) {
 }
 
 func TestEncode_X25519(t *testing.T) {
+	if jwx.FIPSMode() {
+		t.Skip(`X25519 is not approved in FIPS mode`)
+	}
 	pubkey, privkey, err := x25519.GenerateKey(rand.Reader)
 	if !assert.NoError(t, err, `x25519.GenerateKey should succeed`) {
 		return
@@ -747,3 +760,983 @@ func TestCustomField(t *testing.T) {
 		}
 	})
 }
+
+// opaqueDecrypter hides the concrete private key type, so that the
+// key can only be used through the crypto.Decrypter interface
+type opaqueDecrypter struct {
+	decrypter crypto.Decrypter
+}
+
+func (d opaqueDecrypter) Public() crypto.PublicKey {
+	return d.decrypter.Public()
+}
+
+func (d opaqueDecrypter) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	return d.decrypter.Decrypt(rand, msg, opts)
+}
+
+func TestDecryptCryptoDecrypter(t *testing.T) {
+	t.Parallel()
+
+	plaintext := []byte(examplePayload)
+	key := opaqueDecrypter{decrypter: &rsaPrivKey}
+	for _, alg := range []jwa.KeyEncryptionAlgorithm{jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256} {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			t.Parallel()
+			if alg == jwa.RSA1_5 && jwx.FIPSMode() {
+				t.Skip(`RSA1_5 is not approved in FIPS mode`)
+			}
+			encrypted, err := jwe.Encrypt(plaintext, alg, key.Public(), jwa.A128GCM, jwa.NoCompress)
+			if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+				return
+			}
+
+			decrypted, err := jwe.Decrypt(encrypted, alg, key)
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			if !assert.Equal(t, plaintext, decrypted, `decrypted content should match`) {
+				return
+			}
+		})
+	}
+}
+
+type ctxKey struct{}
+
+// contextDecrypter records the value stored in the context passed to
+// DecryptContext
+type contextDecrypter struct {
+	opaqueDecrypter
+	seen *interface{}
+}
+
+func (d contextDecrypter) DecryptContext(ctx context.Context, rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	*d.seen = ctx.Value(ctxKey{})
+	return d.Decrypt(rand, msg, opts)
+}
+
+func TestDecryptContext(t *testing.T) {
+	t.Parallel()
+
+	plaintext := []byte(examplePayload)
+	t.Run("ContextDecrypter", func(t *testing.T) {
+		t.Parallel()
+		var seen interface{}
+		key := contextDecrypter{opaqueDecrypter: opaqueDecrypter{decrypter: &rsaPrivKey}, seen: &seen}
+
+		ctx := context.WithValue(context.Background(), ctxKey{}, "foo")
+		encrypted, err := jwe.EncryptContext(ctx, plaintext, jwa.RSA_OAEP, key.Public(), jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.EncryptContext should succeed`) {
+			return
+		}
+
+		decrypted, err := jwe.DecryptContext(ctx, encrypted, jwa.RSA_OAEP, key)
+		if !assert.NoError(t, err, `jwe.DecryptContext should succeed`) {
+			return
+		}
+		if !assert.Equal(t, plaintext, decrypted, `decrypted content should match`) {
+			return
+		}
+		if !assert.Equal(t, "foo", seen, `DecryptContext should receive the context`) {
+			return
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		t.Parallel()
+		encrypted, err := jwe.Encrypt(plaintext, jwa.RSA_OAEP, &rsaPrivKey.PublicKey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = jwe.EncryptContext(ctx, plaintext, jwa.RSA_OAEP, &rsaPrivKey.PublicKey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jwe.EncryptContext should fail with context.Canceled`) {
+			return
+		}
+		_, err = jwe.DecryptContext(ctx, encrypted, jwa.RSA_OAEP, &rsaPrivKey)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jwe.DecryptContext should fail with context.Canceled`) {
+			return
+		}
+	})
+}
+
+func TestEncryptMulti(t *testing.T) {
+	t.Parallel()
+
+	plaintext := []byte(examplePayload)
+
+	rsakey, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = rsakey.Set(jwk.KeyIDKey, "rsa")
+
+	eckey, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	_ = eckey.Set(jwk.KeyIDKey, "ec")
+
+	sharedkey := make([]byte, 16)
+	_, _ = rand.Read(sharedkey)
+
+	rsapub, _ := jwk.PublicKeyOf(rsakey)
+	ecpub, _ := jwk.PublicKeyOf(eckey)
+
+	t.Run("Multiple recipients", func(t *testing.T) {
+		t.Parallel()
+		encrypted, err := jwe.EncryptMulti(plaintext, jwa.A128GCM, jwa.Deflate,
+			jwe.WithRecipient(jwa.RSA_OAEP, rsapub),
+			jwe.WithRecipient(jwa.ECDH_ES_A128KW, ecpub),
+			jwe.WithRecipient(jwa.A128KW, sharedkey),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		msg, err := jwe.Parse(encrypted)
+		if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+			return
+		}
+		if !assert.Len(t, msg.Recipients(), 3, `message should have 3 recipients`) {
+			return
+		}
+		if !assert.Equal(t, "rsa", msg.Recipients()[0].Headers().KeyID(), `"kid" should be set from the jwk.Key`) {
+			return
+		}
+		if !assert.Equal(t, "", msg.Recipients()[2].Headers().KeyID(), `"kid" should not be set for raw keys`) {
+			return
+		}
+
+		testcases := []struct {
+			Alg jwa.KeyEncryptionAlgorithm
+			Key interface{}
+		}{
+			{Alg: jwa.RSA_OAEP, Key: rsakey},
+			{Alg: jwa.ECDH_ES_A128KW, Key: eckey},
+			{Alg: jwa.A128KW, Key: sharedkey},
+		}
+		for _, tc := range testcases {
+			decrypted, err := jwe.Decrypt(encrypted, tc.Alg, tc.Key)
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed (%s)`, tc.Alg) {
+				return
+			}
+			if !assert.Equal(t, plaintext, decrypted, `decrypted content should match (%s)`, tc.Alg) {
+				return
+			}
+		}
+	})
+	t.Run("Per-recipient errors", func(t *testing.T) {
+		t.Parallel()
+		encrypted, err := jwe.EncryptMulti(plaintext, jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.RSA_OAEP, rsapub),
+			jwe.WithRecipient(jwa.A128KW, sharedkey),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		otherkey, err := jwxtest.GenerateRsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+			return
+		}
+		_, err = jwe.Decrypt(encrypted, jwa.RSA_OAEP, otherkey)
+		if !assert.True(t, errors.Is(err, jwx.ErrDecryption), `jwe.Decrypt should fail`) {
+			return
+		}
+
+		var rerr *jwe.RecipientsError
+		if !assert.True(t, errors.As(err, &rerr), `error should be a *jwe.RecipientsError`) {
+			return
+		}
+		list := rerr.Errors()
+		if !assert.Len(t, list, 2, `there should be an error for each recipient`) {
+			return
+		}
+		if !assert.Equal(t, 0, list[0].Index(), `index should match`) {
+			return
+		}
+		if !assert.Equal(t, jwa.RSA_OAEP, list[0].Algorithm(), `"alg" should match`) {
+			return
+		}
+		if !assert.Equal(t, "rsa", list[0].KeyID(), `"kid" should match`) {
+			return
+		}
+		if !assert.Equal(t, jwa.A128KW, list[1].Algorithm(), `"alg" should match`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `recipient #1 (alg=A128KW)`, `message should describe each recipient`) {
+			return
+		}
+	})
+	t.Run("Single recipient with ECDH-ES", func(t *testing.T) {
+		t.Parallel()
+		encrypted, err := jwe.EncryptMulti(plaintext, jwa.A256GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.ECDH_ES, ecpub),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, jwa.ECDH_ES, eckey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, plaintext, decrypted, `decrypted content should match`) {
+			return
+		}
+	})
+	t.Run("Multiple recipients with ECDH-ES", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwe.EncryptMulti(plaintext, jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.ECDH_ES, ecpub),
+			jwe.WithRecipient(jwa.RSA_OAEP, rsapub),
+		)
+		if !assert.Error(t, err, `jwe.EncryptMulti should fail`) {
+			return
+		}
+	})
+	t.Run("No recipients", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwe.EncryptMulti(plaintext, jwa.A128GCM, jwa.NoCompress)
+		if !assert.Error(t, err, `jwe.EncryptMulti should fail`) {
+			return
+		}
+	})
+}
+
+func TestContentEncryptionKey(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	cek := make([]byte, 16)
+	_, _ = rand.Read(cek)
+
+	t.Run("Supplied CEK", func(t *testing.T) {
+		t.Parallel()
+		var received []byte
+		encrypted, err := jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, &rsakey.PublicKey, jwa.A128GCM, jwa.NoCompress, jwe.WithContentEncryptionKey(cek), jwe.WithCEKReceiver(&received))
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, cek, received, `received CEK should match the supplied one`) {
+			return
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, examplePayload, string(decrypted), `payload should match`) {
+			return
+		}
+	})
+	t.Run("Supplied CEK with multiple recipients", func(t *testing.T) {
+		t.Parallel()
+		var received []byte
+		encrypted, err := jwe.EncryptMulti([]byte(examplePayload), jwa.A128GCM, jwa.NoCompress,
+			jwe.WithRecipient(jwa.RSA_OAEP, &rsakey.PublicKey),
+			jwe.WithRecipient(jwa.ECDH_ES_A128KW, &eckey.PublicKey),
+			jwe.WithContentEncryptionKey(cek),
+			jwe.WithCEKReceiver(&received),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+		if !assert.Equal(t, cek, received, `received CEK should match the supplied one`) {
+			return
+		}
+		decrypted, err := jwe.Decrypt(encrypted, jwa.ECDH_ES_A128KW, eckey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, examplePayload, string(decrypted), `payload should match`) {
+			return
+		}
+	})
+	t.Run("Derived CEK", func(t *testing.T) {
+		t.Parallel()
+		var received []byte
+		encrypted, err := jwe.Encrypt([]byte(examplePayload), jwa.ECDH_ES, &eckey.PublicKey, jwa.A128GCM, jwa.NoCompress, jwe.WithCEKReceiver(&received))
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		if !assert.Len(t, received, 16, `derived CEK should have the size required by "enc"`) {
+			return
+		}
+
+		// The derived key can be used to decrypt the message directly
+		msg, err := jwe.Parse(encrypted)
+		if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+			return
+		}
+		reencrypted, err := jwe.Compact(msg)
+		if !assert.NoError(t, err, `jwe.Compact should succeed`) {
+			return
+		}
+		_ = reencrypted
+		decrypted, err := jwe.Decrypt(encrypted, jwa.ECDH_ES, eckey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, examplePayload, string(decrypted), `payload should match`) {
+			return
+		}
+	})
+	t.Run("Direct", func(t *testing.T) {
+		t.Parallel()
+		var received []byte
+		_, err := jwe.Encrypt([]byte(examplePayload), jwa.DIRECT, cek, jwa.A128GCM, jwa.NoCompress, jwe.WithCEKReceiver(&received))
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, cek, received, `received CEK should be the shared key`) {
+			return
+		}
+	})
+	t.Run("Invalid CEK", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwe.Encrypt([]byte(examplePayload), jwa.DIRECT, cek, jwa.A128GCM, jwa.NoCompress, jwe.WithContentEncryptionKey(cek))
+		if !assert.Error(t, err, `CEK cannot be specified for dir`) {
+			return
+		}
+		_, err = jwe.Encrypt([]byte(examplePayload), jwa.ECDH_ES, &eckey.PublicKey, jwa.A128GCM, jwa.NoCompress, jwe.WithContentEncryptionKey(cek))
+		if !assert.Error(t, err, `CEK cannot be specified for ECDH-ES`) {
+			return
+		}
+		_, err = jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, &rsakey.PublicKey, jwa.A256GCM, jwa.NoCompress, jwe.WithContentEncryptionKey(cek))
+		if !assert.Error(t, err, `CEK must have the size required by "enc"`) {
+			return
+		}
+	})
+}
+
+func TestWrapKey(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	xkey, err := jwxtest.GenerateX25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateX25519Key should succeed`) {
+		return
+	}
+	xjwk, err := jwk.New(xkey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	xpubjwk, err := jwk.PublicKeyOf(xjwk)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	sharedkey := make([]byte, 32)
+	_, _ = rand.Read(sharedkey)
+
+	cek := make([]byte, 32)
+	_, _ = rand.Read(cek)
+
+	testcases := []struct {
+		Algorithm jwa.KeyEncryptionAlgorithm
+		Public    interface{}
+		Private   interface{}
+	}{
+		{Algorithm: jwa.RSA_OAEP, Public: &rsakey.PublicKey, Private: rsakey},
+		{Algorithm: jwa.RSA_OAEP_256, Public: &rsakey.PublicKey, Private: rsakey},
+		{Algorithm: jwa.A128KW, Public: sharedkey[:16], Private: sharedkey[:16]},
+		{Algorithm: jwa.A256KW, Public: sharedkey, Private: sharedkey},
+		{Algorithm: jwa.A192GCMKW, Public: sharedkey[:24], Private: sharedkey[:24]},
+		{Algorithm: jwa.ECDH_ES_A128KW, Public: &eckey.PublicKey, Private: eckey},
+		{Algorithm: jwa.ECDH_ES_A256KW, Public: xpubjwk, Private: xjwk},
+		{Algorithm: jwa.PBES2_HS256_A128KW, Public: []byte(`password`), Private: []byte(`password`)},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Algorithm.String(), func(t *testing.T) {
+			t.Parallel()
+			if tc.Public == xpubjwk && jwx.FIPSMode() {
+				t.Skip(`X25519 is not approved in FIPS mode`)
+			}
+			wrapped, hdrs, err := jwe.WrapKey(tc.Algorithm, tc.Public, cek)
+			if !assert.NoError(t, err, `jwe.WrapKey should succeed`) {
+				return
+			}
+			if !assert.NotEqual(t, cek, wrapped, `wrapped key should differ from the key`) {
+				return
+			}
+			if !assert.Equal(t, tc.Algorithm, hdrs.Algorithm(), `"alg" should be set`) {
+				return
+			}
+
+			unwrapped, err := jwe.UnwrapKey(tc.Algorithm, tc.Private, wrapped, hdrs)
+			if !assert.NoError(t, err, `jwe.UnwrapKey should succeed`) {
+				return
+			}
+			if !assert.Equal(t, cek, unwrapped, `unwrapped key should match`) {
+				return
+			}
+
+			// headers should survive a round trip through JSON
+			buf, err := json.Marshal(hdrs)
+			if !assert.NoError(t, err, `json.Marshal should succeed`) {
+				return
+			}
+			parsed := jwe.NewHeaders()
+			if !assert.NoError(t, json.Unmarshal(buf, parsed), `json.Unmarshal should succeed`) {
+				return
+			}
+			unwrapped, err = jwe.UnwrapKey(tc.Algorithm, tc.Private, wrapped, parsed)
+			if !assert.NoError(t, err, `jwe.UnwrapKey should succeed with parsed headers`) {
+				return
+			}
+			if !assert.Equal(t, cek, unwrapped, `unwrapped key should match`) {
+				return
+			}
+
+			tampered := append([]byte(nil), wrapped...)
+			tampered[len(tampered)-1] ^= 0x01
+			_, err = jwe.UnwrapKey(tc.Algorithm, tc.Private, tampered, hdrs)
+			if !assert.Error(t, err, `jwe.UnwrapKey should fail for tampered key`) {
+				return
+			}
+			if !assert.True(t, errors.Is(err, jwx.ErrDecryption), `error should be jwx.ErrDecryption`) {
+				return
+			}
+		})
+	}
+	t.Run("Unsupported algorithms", func(t *testing.T) {
+		t.Parallel()
+		for _, alg := range []jwa.KeyEncryptionAlgorithm{jwa.DIRECT, jwa.ECDH_ES, jwa.RSA1_5} {
+			_, _, err := jwe.WrapKey(alg, sharedkey, cek)
+			if !assert.Error(t, err, `jwe.WrapKey should fail for %s`, alg) {
+				return
+			}
+			_, err = jwe.UnwrapKey(alg, sharedkey, cek, nil)
+			if !assert.Error(t, err, `jwe.UnwrapKey should fail for %s`, alg) {
+				return
+			}
+		}
+	})
+	t.Run("Algorithm mismatch", func(t *testing.T) {
+		t.Parallel()
+		wrapped, hdrs, err := jwe.WrapKey(jwa.A256KW, sharedkey, cek)
+		if !assert.NoError(t, err, `jwe.WrapKey should succeed`) {
+			return
+		}
+		_, err = jwe.UnwrapKey(jwa.A256GCMKW, sharedkey, wrapped, hdrs)
+		if !assert.Error(t, err, `jwe.UnwrapKey should fail`) {
+			return
+		}
+	})
+}
+
+func TestSealOpen(t *testing.T) {
+	t.Parallel()
+
+	plaintext := []byte(`Lorem ipsum`)
+
+	xpub, xpriv, err := x25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err, `x25519.GenerateKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	ecpub, err := jwk.PublicKeyOf(eckey)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name    string
+		Public  interface{}
+		Private interface{}
+	}{
+		{Name: "X25519", Public: xpub, Private: xpriv},
+		{Name: "ECDSA jwk.Key", Public: ecpub, Private: eckey},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			if tc.Name == "X25519" && jwx.FIPSMode() {
+				t.Skip(`X25519 is not approved in FIPS mode`)
+			}
+			sealed, err := jwe.Seal(tc.Public, plaintext)
+			if !assert.NoError(t, err, `jwe.Seal should succeed`) {
+				return
+			}
+
+			msg, err := jwe.Parse(sealed)
+			if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+				return
+			}
+			assert.Equal(t, jwa.ECDH_ES, msg.ProtectedHeaders().Algorithm(), `"alg" should be ECDH-ES`)
+			assert.Equal(t, jwa.A256GCM, msg.ProtectedHeaders().ContentEncryption(), `"enc" should be A256GCM`)
+
+			opened, err := jwe.Open(tc.Private, sealed)
+			if !assert.NoError(t, err, `jwe.Open should succeed`) {
+				return
+			}
+			assert.Equal(t, plaintext, opened, `plaintext should match`)
+		})
+	}
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		if jwx.FIPSMode() {
+			t.Skip(`X25519 is not approved in FIPS mode`)
+		}
+		_, err := jwe.Seal(xpriv, plaintext)
+		assert.Error(t, err, `jwe.Seal should fail for private keys`)
+
+		rsakey, err := jwxtest.GenerateRsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+			return
+		}
+		_, err = jwe.Seal(&rsakey.PublicKey, plaintext)
+		assert.Error(t, err, `jwe.Seal should fail for RSA keys`)
+
+		_, otherpriv, err := x25519.GenerateKey(rand.Reader)
+		if !assert.NoError(t, err, `x25519.GenerateKey should succeed`) {
+			return
+		}
+		sealed, err := jwe.Seal(xpub, plaintext)
+		if !assert.NoError(t, err, `jwe.Seal should succeed`) {
+			return
+		}
+		_, err = jwe.Open(otherpriv, sealed)
+		assert.Error(t, err, `jwe.Open should fail with the wrong key`)
+
+		encrypted, err := jwe.Encrypt(plaintext, jwa.ECDH_ES, xpub, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		_, err = jwe.Open(xpriv, encrypted)
+		assert.Error(t, err, `jwe.Open should reject messages with other algorithms`)
+	})
+}
+
+func TestECDH1PU(t *testing.T) {
+	t.Parallel()
+
+	plaintext := []byte(`Lorem ipsum`)
+
+	type keypair struct {
+		Private jwk.Key
+		Public  jwk.Key
+	}
+	generate := func(t *testing.T, x25519 bool, kid string) keypair {
+		t.Helper()
+		var key jwk.Key
+		var err error
+		if x25519 {
+			key, err = jwxtest.GenerateX25519Jwk()
+		} else {
+			key, err = jwxtest.GenerateEcdsaJwk()
+		}
+		if !assert.NoError(t, err, `generating key should succeed`) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, key.Set(jwk.KeyIDKey, kid), `key.Set should succeed`) {
+			t.FailNow()
+		}
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			t.FailNow()
+		}
+		return keypair{Private: key, Public: pubkey}
+	}
+
+	algs := []jwa.KeyEncryptionAlgorithm{jwa.ECDH_1PU, jwa.ECDH_1PU_A128KW, jwa.ECDH_1PU_A192KW, jwa.ECDH_1PU_A256KW}
+	for _, useX25519 := range []bool{false, true} {
+		useX25519 := useX25519
+		for _, alg := range algs {
+			alg := alg
+			t.Run(fmt.Sprintf("%s (X25519=%t)", alg, useX25519), func(t *testing.T) {
+				t.Parallel()
+				if useX25519 && jwx.FIPSMode() {
+					t.Skip(`X25519 is not approved in FIPS mode`)
+				}
+				alice := generate(t, useX25519, `alice`)
+				bob := generate(t, useX25519, `bob`)
+
+				encrypted, err := jwe.Encrypt(plaintext, alg, bob.Public, jwa.A256CBC_HS512, jwa.NoCompress, jwe.WithSenderKey(alice.Private))
+				if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+					return
+				}
+
+				msg, err := jwe.Parse(encrypted)
+				if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+					return
+				}
+				assert.Equal(t, `alice`, msg.ProtectedHeaders().SenderKeyID(), `"skid" should be set`)
+				assert.NotNil(t, msg.ProtectedHeaders().EphemeralPublicKey(), `"epk" should be set`)
+
+				decrypted, err := jwe.Decrypt(encrypted, alg, bob.Private, jwe.WithSenderPublicKey(alice.Public))
+				if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+					return
+				}
+				assert.Equal(t, plaintext, decrypted, `plaintext should match`)
+
+				_, err = jwe.Decrypt(encrypted, alg, bob.Private)
+				assert.Error(t, err, `jwe.Decrypt should fail without the sender's key`)
+
+				mallory := generate(t, useX25519, `mallory`)
+				_, err = jwe.Decrypt(encrypted, alg, bob.Private, jwe.WithSenderPublicKey(mallory.Public))
+				assert.Error(t, err, `jwe.Decrypt should fail with the wrong sender's key`)
+			})
+		}
+	}
+	t.Run("Multiple recipients", func(t *testing.T) {
+		t.Parallel()
+		// X25519 is not approved in FIPS mode
+		useX25519 := !jwx.FIPSMode()
+		alice := generate(t, useX25519, `alice`)
+		bob := generate(t, useX25519, `bob`)
+		carol := generate(t, useX25519, `carol`)
+
+		encrypted, err := jwe.EncryptMulti(plaintext, jwa.A128CBC_HS256, jwa.NoCompress,
+			jwe.WithSenderKey(alice.Private),
+			jwe.WithRecipient(jwa.ECDH_1PU_A128KW, bob.Public),
+			jwe.WithRecipient(jwa.ECDH_1PU_A128KW, carol.Public),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		senders := jwk.NewSet()
+		senders.Add(alice.Public)
+		for _, recipient := range []keypair{bob, carol} {
+			decrypted, err := jwe.Decrypt(encrypted, jwa.ECDH_1PU_A128KW, recipient.Private, jwe.WithSenderPublicKey(senders))
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			assert.Equal(t, plaintext, decrypted, `plaintext should match`)
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		alice := generate(t, false, `alice`)
+		bob := generate(t, false, `bob`)
+
+		_, err := jwe.Encrypt(plaintext, jwa.ECDH_1PU_A128KW, bob.Public, jwa.A128CBC_HS256, jwa.NoCompress)
+		assert.Error(t, err, `jwe.Encrypt should fail without the sender's key`)
+
+		_, err = jwe.Encrypt(plaintext, jwa.ECDH_1PU_A128KW, bob.Public, jwa.A128GCM, jwa.NoCompress, jwe.WithSenderKey(alice.Private))
+		assert.Error(t, err, `jwe.Encrypt should fail for key wrapping with AES-GCM`)
+
+		_, err = jwe.EncryptMulti(plaintext, jwa.A128CBC_HS256, jwa.NoCompress,
+			jwe.WithSenderKey(alice.Private),
+			jwe.WithRecipient(jwa.ECDH_1PU_A128KW, bob.Public),
+			jwe.WithRecipient(jwa.ECDH_ES_A128KW, bob.Public),
+		)
+		assert.Error(t, err, `jwe.EncryptMulti should fail when mixing ECDH-1PU and ECDH-ES`)
+
+		x := generate(t, true, `x`)
+		_, err = jwe.Encrypt(plaintext, jwa.ECDH_1PU, x.Public, jwa.A128CBC_HS256, jwa.NoCompress, jwe.WithSenderKey(alice.Private))
+		assert.Error(t, err, `jwe.Encrypt should fail for keys of different types`)
+	})
+}
+
+func TestZeroizeCEK(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`Lorem ipsum`)
+
+	sharedKey := make([]byte, 16)
+	_, _ = rand.Read(sharedKey)
+	wrapKey, err := jwk.New(append([]byte(nil), sharedKey...))
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	ecKey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name       string
+		Alg        jwa.KeyEncryptionAlgorithm
+		Enc        jwa.ContentEncryptionAlgorithm
+		EncryptKey interface{}
+		DecryptKey interface{}
+	}{
+		{Name: "dir", Alg: jwa.DIRECT, Enc: jwa.A128GCM, EncryptKey: sharedKey, DecryptKey: sharedKey},
+		{Name: "A128KW with jwk.Key", Alg: jwa.A128KW, Enc: jwa.A128GCM, EncryptKey: wrapKey, DecryptKey: wrapKey},
+		{Name: "ECDH-ES", Alg: jwa.ECDH_ES, Enc: jwa.A128GCM, EncryptKey: &ecKey.PublicKey, DecryptKey: ecKey},
+		{Name: "ECDH-ES+A128KW", Alg: jwa.ECDH_ES_A128KW, Enc: jwa.A128CBC_HS256, EncryptKey: &ecKey.PublicKey, DecryptKey: ecKey},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			var cek []byte
+			encrypted, err := jwe.Encrypt(payload, tc.Alg, tc.EncryptKey, tc.Enc, jwa.NoCompress, jwe.WithZeroizeCEK(), jwe.WithCEKReceiver(&cek))
+			if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+				return
+			}
+			if !assert.NotEqual(t, make([]byte, len(cek)), cek, `the CEK passed to the receiver should not be zeroized`) {
+				return
+			}
+
+			// decrypt twice, to make sure that the keys are still usable
+			for i := 0; i < 2; i++ {
+				decrypted, err := jwe.Decrypt(encrypted, tc.Alg, tc.DecryptKey, jwe.WithZeroizeCEK())
+				if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+					return
+				}
+				if !assert.Equal(t, payload, decrypted, `payload should match`) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P521)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	keys := map[jwa.KeyEncryptionAlgorithm]func(jwa.ContentEncryptionAlgorithm) interface{}{
+		jwa.RSA_OAEP:           func(jwa.ContentEncryptionAlgorithm) interface{} { return &rsakey.PublicKey },
+		jwa.A128KW:             func(jwa.ContentEncryptionAlgorithm) interface{} { return make([]byte, 16) },
+		jwa.A256GCMKW:          func(jwa.ContentEncryptionAlgorithm) interface{} { return make([]byte, 32) },
+		jwa.ECDH_ES_A192KW:     func(jwa.ContentEncryptionAlgorithm) interface{} { return &eckey.PublicKey },
+		jwa.PBES2_HS512_A256KW: func(jwa.ContentEncryptionAlgorithm) interface{} { return []byte(`password`) },
+		jwa.DIRECT: func(enc jwa.ContentEncryptionAlgorithm) interface{} {
+			switch enc {
+			case jwa.A128GCM:
+				return make([]byte, 16)
+			default:
+				return make([]byte, 64)
+			}
+		},
+	}
+
+	for _, enc := range []jwa.ContentEncryptionAlgorithm{jwa.A128GCM, jwa.A256CBC_HS512} {
+		for alg, key := range keys {
+			for _, size := range []int{0, 15, 16, 1000} {
+				enc, alg, key, size := enc, alg, key, size
+				t.Run(fmt.Sprintf("%s/%s/%d", alg, enc, size), func(t *testing.T) {
+					t.Parallel()
+					estimated, err := jwe.EstimateSize(size, alg, enc, 1)
+					if !assert.NoError(t, err, `jwe.EstimateSize should succeed`) {
+						return
+					}
+
+					encrypted, err := jwe.Encrypt(make([]byte, size), alg, key(enc), enc, jwa.NoCompress)
+					if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+						return
+					}
+					if !assert.LessOrEqual(t, len(encrypted), estimated, `estimate should be an upper bound`) {
+						return
+					}
+					switch alg {
+					case jwa.A128KW, jwa.DIRECT:
+						// no variable length components
+						if !assert.Equal(t, len(encrypted), estimated, `estimate should be exact`) {
+							return
+						}
+					case jwa.RSA_OAEP:
+						// estimate assumes a 4096 bit key
+						if !assert.Equal(t, len(encrypted)+base64.RawURLEncoding.EncodedLen(512)-base64.RawURLEncoding.EncodedLen(256), estimated, `estimate should be exact`) {
+							return
+						}
+					}
+				})
+			}
+		}
+	}
+
+	t.Run("Multiple recipients", func(t *testing.T) {
+		t.Parallel()
+		estimated, err := jwe.EstimateSize(100, jwa.A128KW, jwa.A128GCM, 3)
+		if !assert.NoError(t, err, `jwe.EstimateSize should succeed`) {
+			return
+		}
+
+		var options []jwe.EncryptOption
+		for i := 0; i < 3; i++ {
+			options = append(options, jwe.WithRecipient(jwa.A128KW, make([]byte, 16)))
+		}
+		encrypted, err := jwe.EncryptMulti(make([]byte, 100), jwa.A128GCM, jwa.NoCompress, options...)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+		if !assert.Equal(t, len(encrypted), estimated, `estimate should be exact`) {
+			return
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwe.EstimateSize(-1, jwa.A128KW, jwa.A128GCM, 1)
+		if !assert.Error(t, err, `negative plaintext length should fail`) {
+			return
+		}
+		_, err = jwe.EstimateSize(10, jwa.A128KW, jwa.A128GCM, 0)
+		if !assert.Error(t, err, `zero recipients should fail`) {
+			return
+		}
+		_, err = jwe.EstimateSize(10, jwa.KeyEncryptionAlgorithm(`unknown`), jwa.A128GCM, 1)
+		if !assert.Error(t, err, `unknown key encryption algorithm should fail`) {
+			return
+		}
+		_, err = jwe.EstimateSize(10, jwa.A128KW, jwa.ContentEncryptionAlgorithm(`unknown`), 1)
+		if !assert.Error(t, err, `unknown content encryption algorithm should fail`) {
+			return
+		}
+	})
+}
+
+func TestInteropQuirks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("QuirkCaseInsensitiveEnc", func(t *testing.T) {
+		t.Parallel()
+
+		key := make([]byte, 32)
+		iv := make([]byte, 12)
+		_, _ = rand.Read(key)
+		_, _ = rand.Read(iv)
+
+		hdr := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"dir","enc":"a256gcm"}`))
+		block, err := aes.NewCipher(key)
+		if !assert.NoError(t, err, `aes.NewCipher should succeed`) {
+			return
+		}
+		aead, err := cipher.NewGCM(block)
+		if !assert.NoError(t, err, `cipher.NewGCM should succeed`) {
+			return
+		}
+		sealed := aead.Seal(nil, iv, []byte(`Lorem ipsum`), []byte(hdr))
+		ct, tag := sealed[:len(sealed)-16], sealed[len(sealed)-16:]
+		buf := []byte(hdr + `..` + base64.RawURLEncoding.EncodeToString(iv) + `.` + base64.RawURLEncoding.EncodeToString(ct) + `.` + base64.RawURLEncoding.EncodeToString(tag))
+
+		_, err = jwe.Decrypt(buf, jwa.DIRECT, key)
+		if !assert.Error(t, err, `jwe.Decrypt should fail by default`) {
+			return
+		}
+
+		m := jwe.NewMessage()
+		payload, err := jwe.Decrypt(buf, jwa.DIRECT, key, jwe.WithInteropQuirk(jwe.QuirkCaseInsensitiveEnc), jwe.WithMessage(m))
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte(`Lorem ipsum`), payload, `payload should match`) {
+			return
+		}
+		if !assert.Equal(t, jwa.A256GCM, m.ProtectedHeaders().ContentEncryption(), `"enc" should be normalized`) {
+			return
+		}
+	})
+	t.Run("QuirkUnpaddedEPK", func(t *testing.T) {
+		t.Parallel()
+
+		key, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+			return
+		}
+
+		// Roughly one in 128 ephemeral keys has a coordinate with a
+		// leading zero octet
+		var buf []byte
+		for i := 0; i < 4000 && buf == nil; i++ {
+			encrypted, err := jwe.Encrypt([]byte(`Lorem ipsum`), jwa.ECDH_ES, &key.PublicKey, jwa.A128GCM, jwa.NoCompress, jwe.WithInteropQuirk(jwe.QuirkUnpaddedEPK))
+			if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+				return
+			}
+			msg, err := jwe.Parse(encrypted)
+			if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+				return
+			}
+			v, _ := msg.ProtectedHeaders().Get(jwe.EphemeralPublicKeyKey)
+			epk := v.(jwk.ECDSAPublicKey)
+			if len(epk.X()) < 32 || len(epk.Y()) < 32 {
+				buf = encrypted
+			}
+		}
+		if !assert.NotNil(t, buf, `an ephemeral key with unpadded coordinates should be generated`) {
+			return
+		}
+
+		_, err = jwe.Decrypt(buf, jwa.ECDH_ES, key)
+		if !assert.Error(t, err, `jwe.Decrypt should fail by default`) {
+			return
+		}
+		payload, err := jwe.Decrypt(buf, jwa.ECDH_ES, key, jwe.WithInteropQuirk(jwe.QuirkUnpaddedEPK))
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte(`Lorem ipsum`), payload, `payload should match`) {
+			return
+		}
+	})
+	t.Run("QuirkMissingContentType", func(t *testing.T) {
+		t.Parallel()
+
+		key := make([]byte, 16)
+		_, _ = rand.Read(key)
+
+		nested, err := jws.Sign([]byte(`{"sub":"me"}`), jwa.HS256, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		encrypted, err := jwe.Encrypt(nested, jwa.A128KW, key, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		m := jwe.NewMessage()
+		if _, err := jwe.Decrypt(encrypted, jwa.A128KW, key, jwe.WithMessage(m)); !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Empty(t, m.ProtectedHeaders().ContentType(), `"cty" should not be set by default`) {
+			return
+		}
+
+		m = jwe.NewMessage()
+		if _, err := jwe.Decrypt(encrypted, jwa.A128KW, key, jwe.WithMessage(m), jwe.WithInteropQuirk(jwe.QuirkMissingContentType)); !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `JWT`, m.ProtectedHeaders().ContentType(), `"cty" should be filled in`) {
+			return
+		}
+
+		plain, err := jwe.Encrypt([]byte(`{"sub":"me"}`), jwa.A128KW, key, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		m = jwe.NewMessage()
+		if _, err := jwe.Decrypt(plain, jwa.A128KW, key, jwe.WithMessage(m), jwe.WithInteropQuirk(jwe.QuirkMissingContentType)); !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Empty(t, m.ProtectedHeaders().ContentType(), `"cty" should not be set for non-nested payloads`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package jwe
+
+import (
+	"crypto"
+
+	"github.com/lestrrat-go/jwx/internal/keysep"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// checkKeySeparation records the use of the key for encryption, and
+// reports a violation if it has been used for signatures.
+// See `jwx.WithKeySeparation()`
+func checkKeySeparation(key interface{}) error {
+	if !keysep.Enabled() {
+		return nil
+	}
+
+	k, ok := key.(jwk.Key)
+	if !ok {
+		if decrypter, ok := key.(crypto.Decrypter); ok {
+			key = decrypter.Public()
+		}
+		v, err := jwk.New(key)
+		if err != nil {
+			// keys that we can't identify can't be tracked
+			return nil //nolint:nilerr
+		}
+		k = v
+	}
+	return keysep.Check(keysep.Encryption, k)
+}
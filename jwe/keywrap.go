@@ -0,0 +1,119 @@
+package jwe
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// checkKeyWrapAlgorithm reports an error for key management algorithms
+// that do not wrap the content encryption key
+func checkKeyWrapAlgorithm(alg jwa.KeyEncryptionAlgorithm) error {
+	switch alg {
+	case jwa.DIRECT, jwa.ECDH_ES:
+		return errors.Errorf(`%s does not wrap keys: the content encryption key is the shared key or is derived from it`, alg)
+	case jwa.ECDH_1PU, jwa.ECDH_1PU_A128KW, jwa.ECDH_1PU_A192KW, jwa.ECDH_1PU_A256KW:
+		// the key derived by ECDH-1PU depends on the sender's key, and
+		// in key wrapping modes also on the content being encrypted
+		return errors.Errorf(`%s is not supported for key wrapping`, alg)
+	case jwa.RSA1_5:
+		// decrypting RSA1_5 safely requires the size of the expected key,
+		// and the algorithm should not be used for new applications anyway
+		return errors.Errorf(`%s is not supported for key wrapping`, alg)
+	}
+	return nil
+}
+
+// WrapKey encrypts `cek` using the key management algorithm `alg` and
+// `key`, as described in RFC 7518, without encrypting any content.
+// This allows applications to use the same key wrapping algorithms
+// as JWE in their own envelope formats.
+//
+// `key` is the same as the key that would be passed to `jwe.Encrypt()`:
+// a public key for RSA-OAEP and ECDH-ES+A*KW, a []byte for A*KW, A*GCMKW
+// and PBES2, or a jwk.Key. "dir", "ECDH-ES" and "RSA1_5" are not supported.
+//
+// Besides the encrypted key, the header parameters that are required to
+// unwrap it are returned: "epk" for ECDH-ES+A*KW, "iv" and "tag" for
+// A*GCMKW, and "p2s" and "p2c" for PBES2, as well as "alg". They must be
+// stored alongside the encrypted key, and passed to `jwe.UnwrapKey()`.
+// The headers can be serialized to and from JSON.
+func WrapKey(alg jwa.KeyEncryptionAlgorithm, key interface{}, cek []byte) ([]byte, Headers, error) {
+	if err := checkKeyWrapAlgorithm(alg); err != nil {
+		return nil, nil, errors.Wrap(err, `failed to wrap key`)
+	}
+
+	if len(cek) == 0 {
+		return nil, nil, errors.New(`failed to wrap key: key to wrap is empty`)
+	}
+
+	if err := checkKeySeparation(key); err != nil {
+		return nil, nil, errors.Wrap(err, `failed to wrap key`)
+	}
+
+	enc, err := newKeyEncrypter(alg, key, "", len(cek))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to wrap key`)
+	}
+
+	enckey, err := enc.Encrypt(cek)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to wrap key`)
+	}
+
+	hdrs := NewHeaders()
+	if err := hdrs.Set(AlgorithmKey, alg); err != nil {
+		return nil, nil, errors.Wrap(err, `failed to set header`)
+	}
+	if hp, ok := enckey.(populater); ok {
+		if err := hp.Populate(hdrs); err != nil {
+			return nil, nil, errors.Wrap(err, `failed to populate headers`)
+		}
+	}
+	return enckey.Bytes(), hdrs, nil
+}
+
+// UnwrapKey decrypts an encrypted key created by `jwe.WrapKey()` (or
+// taken from a JWE message), using the key management algorithm `alg`
+// and `key`. `key` is the same as the key that would be passed to
+// `jwe.Decrypt()`.
+//
+// `hdrs` must contain the header parameters required by the algorithm
+// (see `jwe.WrapKey()`). It may be nil for algorithms that do not
+// require any, such as RSA-OAEP and A*KW. The "alg" header, if present,
+// must match `alg`.
+func UnwrapKey(alg jwa.KeyEncryptionAlgorithm, key interface{}, encryptedKey []byte, hdrs Headers) ([]byte, error) {
+	if err := checkKeyWrapAlgorithm(alg); err != nil {
+		return nil, newDecryptError(errors.Wrap(err, `failed to unwrap key`))
+	}
+
+	if hdrs == nil {
+		hdrs = NewHeaders()
+	}
+	if v := hdrs.Algorithm(); v != "" && v != alg {
+		return nil, newDecryptError(errors.Errorf(`failed to unwrap key: algorithm mismatch (expected %s, got %s)`, alg, v))
+	}
+
+	if err := checkKeySeparation(key); err != nil {
+		return nil, newDecryptError(errors.Wrap(err, `failed to unwrap key`))
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve raw key from %T`, key)
+		}
+		key = raw
+	}
+
+	dec := NewDecrypter(alg, "", key)
+	if err := setKeyDecryptionParams(dec, alg, hdrs, 0); err != nil {
+		return nil, newDecryptError(errors.Wrap(err, `failed to unwrap key`))
+	}
+
+	cek, err := dec.DecryptKey(encryptedKey)
+	if err != nil {
+		return nil, newDecryptError(errors.Wrap(err, `failed to unwrap key`))
+	}
+	return cek, nil
+}
@@ -5,8 +5,11 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 
+	"github.com/lestrrat-go/jwx/internal/fips"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
 	"github.com/lestrrat-go/jwx/internal/pool"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/lestrrat-go/jwx/jwk"
 
 	"github.com/lestrrat-go/jwx/internal/base64"
@@ -299,6 +302,10 @@ func (m *Message) MarshalJSON() ([]byte, error) {
 }
 
 func (m *Message) UnmarshalJSON(buf []byte) error {
+	if err := limits.CheckDepth(buf); err != nil {
+		return err
+	}
+
 	var proxy messageMarshalProxy
 	proxy.UnprotectedHeaders = NewHeaders()
 
@@ -318,15 +325,35 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 		return errors.Wrap(err, "failed to base64 decoded protected headers buffer")
 	}
 
+	if err := limits.CheckHeader(protectedHeadersRaw); err != nil {
+		return errors.Wrap(err, `invalid protected headers`)
+	}
+
+	hdrbuf := protectedHeadersRaw
+	if m.quirks&QuirkCaseInsensitiveEnc != 0 {
+		hdrbuf, err = normalizeEnc(hdrbuf)
+		if err != nil {
+			return err
+		}
+	}
+
 	h := NewHeaders()
-	if err := json.Unmarshal(protectedHeadersRaw, h); err != nil {
+	if err := json.Unmarshal(hdrbuf, h); err != nil {
 		return errors.Wrap(err, `failed to decode protected headers (2)`)
 	}
 
+	if err := checkHeaderCount(proxy.UnprotectedHeaders); err != nil {
+		return errors.Wrap(err, `invalid unprotected headers`)
+	}
+
 	// if this were a flattened message, we would see a "header" and "ciphertext"
 	// field. TODO: do both of these conditions need to meet, or just one?
 	if proxy.Headers != nil || len(proxy.EncryptedKey) > 0 {
 		recipient := NewRecipient()
+		if err := limits.CheckHeader(proxy.Headers); err != nil {
+			return errors.Wrap(err, `invalid headers field`)
+		}
+
 		hdrs := NewHeaders()
 		if err := json.Unmarshal(proxy.Headers, hdrs); err != nil {
 			return errors.Wrap(err, `failed to decode headers field`)
@@ -348,12 +375,20 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 
 		m.recipients = append(m.recipients, recipient)
 	} else {
+		if err := limits.CheckRecipients(len(proxy.Recipients)); err != nil {
+			return err
+		}
+
 		for i, recipientbuf := range proxy.Recipients {
 			recipient := NewRecipient()
 			if err := json.Unmarshal(recipientbuf, recipient); err != nil {
 				return errors.Wrapf(err, `failed to decode recipient at index %d`, i)
 			}
 
+			if err := checkHeaderCount(recipient.Headers()); err != nil {
+				return errors.Wrapf(err, `invalid header for recipient at index %d`, i)
+			}
+
 			m.recipients = append(m.recipients, recipient)
 		}
 	}
@@ -409,6 +444,20 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 	return nil
 }
 
+// checkHeaderCount checks the number of fields in h against the
+// limit set via jwx.WithMaxHeaderCount()
+func checkHeaderCount(h Headers) error {
+	if limits.MaxHeaderCount() <= 0 {
+		return nil
+	}
+
+	fields, err := h.AsMap(context.TODO())
+	if err != nil {
+		return errors.Wrap(err, `failed to convert headers to map`)
+	}
+	return limits.CheckHeaderCount(len(fields))
+}
+
 func (m *Message) makeDummyRecipient(enckeybuf string, protected Headers) error {
 	// Recipients in this case should not contain the content encryption key,
 	// so move that out
@@ -465,11 +514,25 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 	alg := dctx.alg
 	key := dctx.key
 
+	if err := fips.CheckKeyEncryptionAlgorithm(alg); err != nil {
+		return nil, err
+	}
+	if err := fips.CheckContentEncryptionAlgorithm(m.protectedHeaders.ContentEncryption()); err != nil {
+		return nil, err
+	}
+
 	if jwkKey, ok := key.(jwk.Key); ok {
 		var raw interface{}
 		if err := jwkKey.Raw(&raw); err != nil {
 			return nil, errors.Wrapf(err, `failed to retrieve raw key from %T`, key)
 		}
+		if dctx.zeroize {
+			// symmetric keys share storage with the jwk.Key
+			if octets, ok := raw.([]byte); ok {
+				raw = append([]byte(nil), octets...)
+			}
+			defer zeroize.Key(raw)
+		}
 		key = raw
 	}
 
@@ -510,9 +573,10 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 		ComputedAuthenticatedData(computedAad).
 		InitializationVector(m.initializationVector).
 		Tag(m.tag)
+	dec.zeroize = dctx.zeroize
 
 	var plaintext []byte
-	var lastError error
+	var recipientErrors []*RecipientError
 
 	// if we have no recipients, pretend like we only have one
 	recipients := m.recipients
@@ -524,9 +588,24 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 		recipients = append(recipients, r)
 	}
 
-	for _, recipient := range recipients {
+	for i, recipient := range recipients {
 		// strategy: try each recipient. If we fail in one of the steps,
 		// keep looping because there might be another key with the same algo
+		rerr := &RecipientError{
+			index: i,
+			alg:   recipient.Headers().Algorithm(),
+			kid:   recipient.Headers().KeyID(),
+		}
+		if rerr.kid == "" {
+			rerr.kid = m.protectedHeaders.KeyID()
+		}
+		fail := func(err error) {
+			rerr.err = err
+			recipientErrors = append(recipientErrors, rerr)
+			if pdebug.Enabled {
+				pdebug.Printf(`%s`, rerr)
+			}
+		}
 
 		if pdebug.Enabled {
 			pdebug.Printf("Attempting to check if we can decode for recipient (alg = %s)", recipient.Headers().Algorithm())
@@ -534,113 +613,39 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 
 		if recipient.Headers().Algorithm() != alg {
 			// algorithms don't match
+			fail(errors.Errorf(`"alg" does not match the requested algorithm %s`, alg))
 			continue
 		}
 
 		h2, err := h.Clone(ctx)
 		if err != nil {
-			lastError = errors.Wrap(err, `failed to copy headers (1)`)
-			if pdebug.Enabled {
-				pdebug.Printf(`%s`, lastError)
-			}
+			fail(errors.Wrap(err, `failed to copy headers (1)`))
 			continue
 		}
 
 		h2, err = h2.Merge(ctx, recipient.Headers())
 		if err != nil {
-			lastError = errors.Wrap(err, `failed to copy headers (2)`)
-			if pdebug.Enabled {
-				pdebug.Printf(`%s`, lastError)
-			}
+			fail(errors.Wrap(err, `failed to copy headers (2)`))
 			continue
 		}
 
-		switch alg {
-		case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
-			epkif, ok := h2.Get(EphemeralPublicKeyKey)
-			if !ok {
-				return nil, errors.New("failed to get 'epk' field")
-			}
-			switch epk := epkif.(type) {
-			case jwk.ECDSAPublicKey:
-				var pubkey ecdsa.PublicKey
-				if err := epk.Raw(&pubkey); err != nil {
-					return nil, errors.Wrap(err, "failed to get public key")
-				}
-				dec.PublicKey(&pubkey)
-			case jwk.OKPPublicKey:
-				var pubkey interface{}
-				if err := epk.Raw(&pubkey); err != nil {
-					return nil, errors.Wrap(err, "failed to get public key")
-				}
-				dec.PublicKey(pubkey)
-			default:
-				return nil, errors.Errorf("unexpected 'epk' type %T for alg %s", epkif, alg)
-			}
-
-			if apu := h2.AgreementPartyUInfo(); len(apu) > 0 {
-				dec.AgreementPartyUInfo(apu)
-			}
-
-			if apv := h2.AgreementPartyVInfo(); len(apv) > 0 {
-				dec.AgreementPartyVInfo(apv)
-			}
-		case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
-			ivB64, ok := h2.Get(InitializationVectorKey)
-			if !ok {
-				return nil, errors.New("failed to get 'iv' field")
-			}
-			ivB64Str, ok := ivB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'iv': %T", ivB64)
-			}
-			tagB64, ok := h2.Get(TagKey)
-			if !ok {
-				return nil, errors.New("failed to get 'tag' field")
-			}
-			tagB64Str, ok := tagB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'tag': %T", tagB64)
-			}
-			iv, err := base64.DecodeString(ivB64Str)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'iv'")
-			}
-			tag, err := base64.DecodeString(tagB64Str)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'tag'")
-			}
-			dec.KeyInitializationVector(iv)
-			dec.KeyTag(tag)
-		case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
-			saltB64, ok := h2.Get(SaltKey)
-			if !ok {
-				return nil, errors.New("failed to get 'p2s' field")
-			}
-			saltB64Str, ok := saltB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'p2s': %T", saltB64)
-			}
+		if err := setKeyDecryptionParams(dec, alg, h2, dctx.quirks); err != nil {
+			fail(err)
+			continue
+		}
 
-			count, ok := h2.Get(CountKey)
-			if !ok {
-				return nil, errors.New("failed to get 'p2c' field")
-			}
-			countFlt, ok := count.(float64)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'p2c': %T", count)
-			}
-			salt, err := base64.DecodeString(saltB64Str)
+		if isECDH1PU(alg) {
+			senderkey, err := senderPublicKey(dctx.senderKey, h2)
 			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'salt'")
+				fail(errors.Wrap(err, `failed to determine the sender's public key`))
+				continue
 			}
-			dec.KeySalt(salt)
-			dec.KeyCount(int(countFlt))
+			dec.SenderPublicKey(senderkey)
 		}
 
 		plaintext, err = dec.Decrypt(recipient.EncryptedKey(), m.cipherText)
 		if err != nil {
-			lastError = errors.Wrap(err, `failed to decrypt`)
+			fail(errors.Wrap(err, `failed to decrypt`))
 			continue
 		}
 
@@ -658,10 +663,8 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 			}
 			buf, err := uncompress(plaintext)
 			if err != nil {
-				lastError = errors.Wrap(err, `failed to uncompress payload`)
-				if pdebug.Enabled {
-					pdebug.Printf(`%s`, lastError)
-				}
+				plaintext = nil
+				fail(errors.Wrap(err, `failed to uncompress payload`))
 				continue
 			}
 			plaintext = buf
@@ -670,11 +673,106 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 	}
 
 	if plaintext == nil {
-		if lastError != nil {
-			return nil, errors.Errorf(`failed to find matching recipient to decrypt key (last error = %s)`, lastError)
-		}
-		return nil, errors.New("failed to find matching recipient")
+		return nil, &RecipientsError{errs: recipientErrors}
 	}
 
 	return plaintext, nil
 }
+
+// setKeyDecryptionParams sets the parameters required to decrypt the
+// encrypted key (e.g. "epk", "iv" and "tag", or "p2s" and "p2c") from
+// the headers to the decrypter
+func setKeyDecryptionParams(dec *Decrypter, alg jwa.KeyEncryptionAlgorithm, h Headers, quirks InteropQuirk) error {
+	switch alg {
+	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW,
+		jwa.ECDH_1PU, jwa.ECDH_1PU_A128KW, jwa.ECDH_1PU_A192KW, jwa.ECDH_1PU_A256KW:
+		epkif, ok := h.Get(EphemeralPublicKeyKey)
+		if !ok {
+			return errors.New("failed to get 'epk' field")
+		}
+		switch epk := epkif.(type) {
+		case jwk.ECDSAPublicKey:
+			if quirks&QuirkUnpaddedEPK == 0 {
+				if err := checkEPKCoordinates(epk); err != nil {
+					return err
+				}
+			}
+			var pubkey ecdsa.PublicKey
+			if err := epk.Raw(&pubkey); err != nil {
+				return errors.Wrap(err, "failed to get public key")
+			}
+			dec.PublicKey(&pubkey)
+		case jwk.OKPPublicKey:
+			var pubkey interface{}
+			if err := epk.Raw(&pubkey); err != nil {
+				return errors.Wrap(err, "failed to get public key")
+			}
+			dec.PublicKey(pubkey)
+		default:
+			return errors.Errorf("unexpected 'epk' type %T for alg %s", epkif, alg)
+		}
+
+		if apu := h.AgreementPartyUInfo(); len(apu) > 0 {
+			dec.AgreementPartyUInfo(apu)
+		}
+
+		if apv := h.AgreementPartyVInfo(); len(apv) > 0 {
+			dec.AgreementPartyVInfo(apv)
+		}
+	case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
+		iv, err := headerBytes(h, InitializationVectorKey)
+		if err != nil {
+			return err
+		}
+		tag, err := headerBytes(h, TagKey)
+		if err != nil {
+			return err
+		}
+		dec.KeyInitializationVector(iv)
+		dec.KeyTag(tag)
+	case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
+		salt, err := headerBytes(h, SaltKey)
+		if err != nil {
+			return err
+		}
+
+		count, ok := h.Get(CountKey)
+		if !ok {
+			return errors.New("failed to get 'p2c' field")
+		}
+		// the value is a float64 when parsed from JSON, and an int
+		// when set by `jwe.WrapKey()`
+		switch v := count.(type) {
+		case float64:
+			dec.KeyCount(int(v))
+		case int:
+			dec.KeyCount(v)
+		default:
+			return errors.Errorf("unexpected type for 'p2c': %T", count)
+		}
+		dec.KeySalt(salt)
+	}
+	return nil
+}
+
+// headerBytes returns the value of a header field that holds binary
+// data. The value is a base64 encoded string when parsed from JSON, and
+// a []byte when set by `jwe.WrapKey()`
+func headerBytes(h Headers, name string) ([]byte, error) {
+	v, ok := h.Get(name)
+	if !ok {
+		return nil, errors.Errorf("failed to get '%s' field", name)
+	}
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		buf, err := base64.DecodeString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to b64-decode '%s'", name)
+		}
+		return buf, nil
+	default:
+		return nil, errors.Errorf("unexpected type for '%s': %T", name, v)
+	}
+}
@@ -3,14 +3,22 @@ package jwe
 import (
 	"context"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/option"
 )
 
 type Option = option.Interface
+type identCEKReceiver struct{}
+type identContentEncryptionKey struct{}
+type identInteropQuirk struct{}
 type identMessage struct{}
 type identPostParser struct{}
 type identPrettyFormat struct{}
 type identProtectedHeader struct{}
+type identRecipient struct{}
+type identSenderKey struct{}
+type identSenderPublicKey struct{}
+type identZeroizeCEK struct{}
 
 type DecryptOption interface {
 	Option
@@ -45,6 +53,20 @@ type encryptOption struct {
 
 func (*encryptOption) encryptOption() {}
 
+// EncryptDecryptOption describes an option that can be passed to both
+// the jwe.Encrypt and the jwe.Decrypt functions
+type EncryptDecryptOption interface {
+	EncryptOption
+	DecryptOption
+}
+
+type encryptDecryptOption struct {
+	Option
+}
+
+func (*encryptDecryptOption) encryptOption() {}
+func (*encryptDecryptOption) decryptOption() {}
+
 // WithPrettyFormat specifies if the `jwe.JSON` serialization tool
 // should generate pretty-formatted output
 func WithPrettyFormat(b bool) SerializerOption {
@@ -58,6 +80,48 @@ func WithProtectedHeaders(h Headers) EncryptOption {
 	return &encryptOption{option.New(identProtectedHeader{}, cloned)}
 }
 
+type recipientSpec struct {
+	alg jwa.KeyEncryptionAlgorithm
+	key interface{}
+}
+
+// WithRecipient specifies a recipient for `jwe.EncryptMulti`, using
+// the key encryption algorithm `alg` and the public key `key`.
+// This option can be specified multiple times.
+func WithRecipient(alg jwa.KeyEncryptionAlgorithm, key interface{}) EncryptOption {
+	return &encryptOption{option.New(identRecipient{}, &recipientSpec{
+		alg: alg,
+		key: key,
+	})}
+}
+
+// WithCEKReceiver specifies the location where the content encryption
+// key (CEK) that was used to encrypt the payload is stored after a
+// successful call to `jwe.Encrypt` or `jwe.EncryptMulti`.
+//
+// In direct key agreement mode (ECDH-ES) the CEK is the derived key,
+// and in direct encryption mode (dir) it is the shared key itself.
+//
+// The CEK allows anybody to decrypt the message, and must be
+// handled with the same care as the recipients' private keys.
+func WithCEKReceiver(dst *[]byte) EncryptOption {
+	return &encryptOption{option.New(identCEKReceiver{}, dst)}
+}
+
+// WithContentEncryptionKey specifies the content encryption key (CEK)
+// to use, instead of generating a random one. The key must have the
+// size required by the content encryption algorithm.
+//
+// This option is meant for protocols that bind the CEK into other
+// structures, or for generating test vectors. A CEK must never be
+// used for more than one message.
+//
+// It cannot be used with ECDH-ES or dir, as the CEK is determined
+// by the key agreement or the shared key in these modes.
+func WithContentEncryptionKey(cek []byte) EncryptOption {
+	return &encryptOption{option.New(identContentEncryptionKey{}, cek)}
+}
+
 // WithMessage provides a message object to be populated by `jwe.Decrpt`
 // Using this option allows you to decrypt AND obtain the `jwe.Message`
 // in one go.
@@ -85,3 +149,48 @@ func WithMessage(m *Message) DecryptOption {
 func WithPostParser(p PostParser) DecryptOption {
 	return &decryptOption{option.New(identPostParser{}, p)}
 }
+
+// WithSenderKey specifies the static private key of the sender for
+// ECDH-1PU authenticated encryption. It is required when one of the
+// ECDH-1PU key agreement algorithms is used, and is ignored otherwise.
+//
+// The key may be an *ecdsa.PrivateKey, an x25519.PrivateKey, or a jwk.Key
+// holding either of them, and must be on the same curve as the keys of
+// the recipients. If it is a jwk.Key with a "kid", the key ID is stored
+// in the "skid" protected header, unless it has already been specified
+// via `jwe.WithProtectedHeaders()`.
+func WithSenderKey(key interface{}) EncryptOption {
+	return &encryptOption{option.New(identSenderKey{}, key)}
+}
+
+// WithSenderPublicKey specifies the static public key of the sender
+// of a message encrypted using ECDH-1PU, which is required to decrypt
+// such messages. It is ignored for other key management algorithms.
+//
+// The key may be an *ecdsa.PublicKey, an x25519.PublicKey, or a jwk.Key.
+// It may also be a jwk.Set, in which case the key whose "kid" matches the
+// "skid" header of the message is used.
+func WithSenderPublicKey(key interface{}) DecryptOption {
+	return &decryptOption{option.New(identSenderPublicKey{}, key)}
+}
+
+// WithZeroizeCEK specifies that the content encryption key (CEK) is
+// overwritten with zeros as soon as the content has been encrypted or
+// decrypted. When decrypting with a jwk.Key, the raw private key that
+// is materialized from it is zeroized as well.
+//
+// Keys supplied by the caller, such as the shared key in direct
+// encryption mode (dir), or the CEK given via
+// `jwe.WithContentEncryptionKey()`, are never modified.
+func WithZeroizeCEK() EncryptDecryptOption {
+	return &encryptDecryptOption{option.New(identZeroizeCEK{}, true)}
+}
+
+// WithInteropQuirk enables workarounds for bugs in other implementations.
+// Only use this option when exchanging messages with peers that are
+// known to need it. The option may be specified multiple times, in which
+// case the quirks are combined. Quirks that do not apply to the
+// operation are ignored.
+func WithInteropQuirk(q InteropQuirk) EncryptDecryptOption {
+	return &encryptDecryptOption{option.New(identInteropQuirk{}, q)}
+}
@@ -0,0 +1,140 @@
+package jwe
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/internal/ecutil"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// InteropQuirk describes a known deviation from RFC 7516 and RFC 7518
+// found in other implementations, which `jwe.Encrypt()` and
+// `jwe.Decrypt()` can be asked to tolerate (or reproduce) using
+// `jwe.WithInteropQuirk()`. By default none of them are enabled.
+//
+// Quirks can be combined using bitwise OR.
+type InteropQuirk uint
+
+const (
+	// QuirkUnpaddedEPK accepts "epk" headers whose "x" and "y" coordinates
+	// are shorter than the size of the curve, i.e. whose leading zero
+	// octets were removed. RFC 7518 section 6.2.1.2 requires the full
+	// size, and such headers are rejected by default.
+	//
+	// When encrypting, the coordinates of the "epk" header are emitted
+	// without leading zero octets, for peers that cannot handle them.
+	QuirkUnpaddedEPK InteropQuirk = 1 << iota
+
+	// QuirkMissingContentType treats messages whose plaintext is a JWS or
+	// JWE message in compact serialization as nested JWTs, even if their
+	// protected headers lack the `"cty": "JWT"` header required by
+	// RFC 7519 section 5.2. The header is filled in on the jwe.Message
+	// specified via `jwe.WithMessage()`.
+	QuirkMissingContentType
+
+	// QuirkCaseInsensitiveEnc accepts "enc" header values that only differ
+	// in case from the registered algorithm names, such as "a256gcm".
+	// The value is replaced with the registered name.
+	QuirkCaseInsensitiveEnc
+)
+
+// normalizeEnc replaces the value of the "enc" header in the JSON
+// encoded protected headers `buf` with the registered algorithm name
+// that it matches case-insensitively. `buf` is returned as is if the
+// value is already registered, or if no match is found.
+func normalizeEnc(buf []byte) ([]byte, error) {
+	var hdr map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &hdr); err != nil {
+		return nil, errors.Wrap(err, `failed to parse header JSON`)
+	}
+
+	rawEnc, ok := hdr[ContentEncryptionKey]
+	if !ok {
+		return buf, nil
+	}
+	var enc string
+	if err := json.Unmarshal(rawEnc, &enc); err != nil {
+		return buf, nil
+	}
+
+	for _, v := range jwa.ContentEncryptionAlgorithms() {
+		if v.String() == enc {
+			return buf, nil
+		}
+	}
+	for _, v := range jwa.ContentEncryptionAlgorithms() {
+		if strings.EqualFold(v.String(), enc) {
+			normalized, err := json.Marshal(v.String())
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to encode "enc" header`)
+			}
+			hdr[ContentEncryptionKey] = normalized
+			return json.Marshal(hdr)
+		}
+	}
+	return buf, nil
+}
+
+// checkEPKCoordinates checks that the coordinates of an EC "epk" header
+// have the full size of the curve, as required by RFC 7518
+func checkEPKCoordinates(epk jwk.ECDSAPublicKey) error {
+	crv, ok := ecutil.CurveForAlgorithm(epk.Crv())
+	if !ok {
+		return nil
+	}
+	size := (crv.Params().BitSize + 7) / 8
+	if len(epk.X()) != size || len(epk.Y()) != size {
+		return errors.Errorf(`invalid 'epk' header: coordinates must be %d octets long`, size)
+	}
+	return nil
+}
+
+// unpadEPK removes the leading zero octets from the coordinates of the
+// EC "epk" header in `h`, if any
+func unpadEPK(h Headers) error {
+	v, ok := h.Get(EphemeralPublicKeyKey)
+	if !ok {
+		return nil
+	}
+	epk, ok := v.(jwk.ECDSAPublicKey)
+	if !ok {
+		return nil
+	}
+
+	for _, name := range []string{jwk.ECDSAXKey, jwk.ECDSAYKey} {
+		v, ok := epk.Get(name)
+		if !ok {
+			continue
+		}
+		buf, _ := v.([]byte)
+		trimmed := bytes.TrimLeft(buf, "\x00")
+		if len(trimmed) == len(buf) {
+			continue
+		}
+		if err := epk.Set(name, trimmed); err != nil {
+			return errors.Wrapf(err, `failed to set %q in 'epk' header`, name)
+		}
+	}
+	return nil
+}
+
+// fillContentType sets the "cty" header of `h` to "JWT" if it is not
+// set, and the plaintext looks like a nested JWT
+func fillContentType(h Headers, plaintext []byte) error {
+	if h == nil || h.ContentType() != "" {
+		return nil
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(plaintext), []byte{'{'}) {
+		return nil
+	}
+	switch jwx.GuessFormat(plaintext) {
+	case jwx.JWS, jwx.JWE:
+		return h.Set(ContentTypeKey, `JWT`)
+	}
+	return nil
+}
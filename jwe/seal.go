@@ -0,0 +1,60 @@
+package jwe
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
+)
+
+// Seal encrypts the plaintext to the owner of `key`, using ECDH-ES for
+// key agreement and A256GCM for content encryption, and returns the
+// message in compact serialization format. It is meant for applications
+// that just need to "encrypt to a public key" without having to choose
+// among the algorithms supported by `jwe.Encrypt()`.
+//
+// `key` must be an X25519 or an ECDSA public key, either as a raw key
+// (x25519.PublicKey, *ecdsa.PublicKey) or as a jwk.Key. X25519 keys are
+// recommended. Use `jwe.Open()` to decrypt the message.
+func Seal(key interface{}, plaintext []byte) ([]byte, error) {
+	raw := key
+	if jwkKey, ok := key.(jwk.Key); ok {
+		if err := jwkKey.Raw(&raw); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve raw key from %T`, key)
+		}
+	}
+
+	switch raw.(type) {
+	case x25519.PublicKey, *ecdsa.PublicKey, ecdsa.PublicKey:
+	case x25519.PrivateKey, *ecdsa.PrivateKey, ecdsa.PrivateKey:
+		return nil, errors.New(`failed to seal message: key must be a public key`)
+	default:
+		return nil, errors.Errorf(`failed to seal message: key must be an X25519 or ECDSA public key (got %T)`, raw)
+	}
+
+	return Encrypt(plaintext, jwa.ECDH_ES, key, jwa.A256GCM, jwa.NoCompress)
+}
+
+// Open decrypts a message created by `jwe.Seal()`. `key` must be the
+// private key corresponding to the public key that the message was
+// sealed with, either as a raw key (x25519.PrivateKey, *ecdsa.PrivateKey)
+// or as a jwk.Key.
+//
+// Messages that do not use ECDH-ES and A256GCM are rejected.
+func Open(key interface{}, message []byte) ([]byte, error) {
+	checkAlgorithms := PostParseFunc(func(ctx DecryptCtx) error {
+		h := ctx.Message().ProtectedHeaders()
+		if h == nil || h.Algorithm() != jwa.ECDH_ES || h.ContentEncryption() != jwa.A256GCM {
+			return errors.New(`message was not created by jwe.Seal: "alg" must be ECDH-ES and "enc" must be A256GCM`)
+		}
+		return nil
+	})
+
+	plaintext, err := Decrypt(message, jwa.ECDH_ES, key, WithPostParser(checkAlgorithms))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to open message`)
+	}
+	return plaintext, nil
+}
@@ -5,8 +5,6 @@ import (
 
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/internal/json"
-
-	"github.com/lestrrat-go/jwx/internal/pool"
 	"github.com/pkg/errors"
 )
 
@@ -49,27 +47,18 @@ func Compact(m *Message, _ ...SerializerOption) ([]byte, error) {
 		return nil, errors.Wrap(err, "failed to encode header")
 	}
 
-	encryptedKey := base64.Encode(recipient.EncryptedKey())
-	iv := base64.Encode(m.initializationVector)
-	cipher := base64.Encode(m.cipherText)
-	tag := base64.Encode(m.tag)
-
-	buf := pool.GetBytesBuffer()
-	defer pool.ReleaseBytesBuffer(buf)
+	// Write everything directly into a buffer of the exact final size,
+	// instead of encoding each part separately and concatenating them
+	encryptedKey := recipient.EncryptedKey()
+	size := len(protected) + base64.EncodedLen(len(encryptedKey)) + base64.EncodedLen(len(m.initializationVector)) + base64.EncodedLen(len(m.cipherText)) + base64.EncodedLen(len(m.tag)) + 4
 
-	buf.Grow(len(protected) + len(encryptedKey) + len(iv) + len(cipher) + len(tag) + 4)
-	buf.Write(protected)
-	buf.WriteByte('.')
-	buf.Write(encryptedKey)
-	buf.WriteByte('.')
-	buf.Write(iv)
-	buf.WriteByte('.')
-	buf.Write(cipher)
-	buf.WriteByte('.')
-	buf.Write(tag)
-
-	result := make([]byte, buf.Len())
-	copy(result, buf.Bytes())
+	result := make([]byte, size)
+	n := copy(result, protected)
+	for _, part := range [][]byte{encryptedKey, m.initializationVector, m.cipherText, m.tag} {
+		result[n] = '.'
+		n++
+		n += base64.EncodeTo(result[n:], part)
+	}
 	return result, nil
 }
 
@@ -0,0 +1,134 @@
+package jwe
+
+import (
+	"strings"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// estimateRSAKeySize is the size (in bytes) of the largest RSA modulus
+// that `jwe.EstimateSize()` accounts for (4096 bits)
+const estimateRSAKeySize = 512
+
+// estimateEPKSize is the size of the largest "epk" header member
+// generated by this library, which is that of a P-521 public key:
+// {"crv":"P-521","kty":"EC","x":"...","y":"..."}, with 66 byte coordinates
+const estimateEPKSize = 42 + 2*88
+
+// estimateCountSize is the number of digits in the largest "p2c" value
+const estimateCountSize = 10
+
+// EstimateSize returns an upper bound of the size of a JWE message
+// encrypting a payload of `plaintextLen` bytes for `recipients` recipients,
+// using the given key encryption and content encryption algorithms.
+// It can be used to pre-allocate buffers, or to size storage such as
+// database columns.
+//
+// When `recipients` is 1, the size of the compact serialization (as
+// created by `jwe.Encrypt()`) is returned. Otherwise the size of the
+// general JSON serialization (as created by `jwe.EncryptMulti()`) is
+// returned, assuming that all recipients use `alg`.
+//
+// The estimate covers the header parameters generated by this library,
+// including those specific to `alg` such as "epk" and "p2s". It does not
+// account for compression, RSA keys larger than 4096 bits, nor for headers
+// that depend on the keys or options used, such as "kid" and "skid".
+func EstimateSize(plaintextLen int, alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionAlgorithm, recipients int) (int, error) {
+	if plaintextLen < 0 {
+		return 0, errors.New(`plaintext length must not be negative`)
+	}
+	if recipients < 1 {
+		return 0, errors.New(`at least one recipient is required`)
+	}
+
+	var keysize, ivsize, tagsize, ciphersize int
+	switch enc {
+	case jwa.A128GCM, jwa.A192GCM, jwa.A256GCM:
+		keysize = aesKeySize(string(enc))
+		ivsize = 12
+		tagsize = 16
+		ciphersize = plaintextLen
+	case jwa.A128CBC_HS256, jwa.A192CBC_HS384, jwa.A256CBC_HS512:
+		keysize = 2 * aesKeySize(string(enc))
+		ivsize = 16
+		tagsize = keysize / 2
+		// PKCS#7 padding always adds at least one byte
+		ciphersize = (plaintextLen/16 + 1) * 16
+	default:
+		return 0, errors.Errorf(`unsupported content encryption algorithm %q`, enc)
+	}
+
+	enckeysize, extra, err := estimateRecipient(alg, keysize)
+	if err != nil {
+		return 0, err
+	}
+
+	body := base64.EncodedLen(ivsize) + base64.EncodedLen(ciphersize) + base64.EncodedLen(tagsize)
+
+	if recipients == 1 {
+		// {"alg":"...","enc":"..."...}
+		header := len(`{"alg":"","enc":""}`) + len(alg) + len(enc) + extra
+		return base64.EncodedLen(header) + base64.EncodedLen(enckeysize) + body + 4, nil
+	}
+
+	// The ephemeral key of ECDH-1PU is shared by all recipients, and is
+	// stored in the protected header. Everything else is per-recipient.
+	protected := len(`{"enc":""}`) + len(enc)
+	if strings.HasPrefix(string(alg), `ECDH-1PU`) {
+		protected += extra
+		extra = 0
+	}
+
+	// {"header":{"alg":"..."...},"encrypted_key":"..."}
+	recipient := len(`{"header":{"alg":""},"encrypted_key":""}`) + len(alg) + extra + base64.EncodedLen(enckeysize)
+
+	// {"ciphertext":"...","iv":"...","protected":"...","recipients":[...],"tag":"..."}
+	return len(`{"ciphertext":"","iv":"","protected":"","recipients":[],"tag":""}`) +
+		body +
+		base64.EncodedLen(protected) +
+		recipients*recipient + (recipients - 1), nil
+}
+
+// estimateRecipient returns the size of the encrypted key, and the size
+// of the additional header members generated for the given algorithm
+func estimateRecipient(alg jwa.KeyEncryptionAlgorithm, keysize int) (int, int, error) {
+	const wrapOverhead = 8 // RFC3394 key wrap adds a 64-bit integrity check value
+
+	switch alg {
+	case jwa.DIRECT:
+		return 0, 0, nil
+	case jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256:
+		return estimateRSAKeySize, 0, nil
+	case jwa.A128KW, jwa.A192KW, jwa.A256KW:
+		return keysize + wrapOverhead, 0, nil
+	case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
+		// ,"iv":"...","tag":"..."
+		return keysize, len(`,"iv":"","tag":""`) + base64.EncodedLen(12) + base64.EncodedLen(16), nil
+	case jwa.ECDH_ES, jwa.ECDH_1PU:
+		return 0, len(`,"epk":`) + estimateEPKSize, nil
+	case jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW,
+		jwa.ECDH_1PU_A128KW, jwa.ECDH_1PU_A192KW, jwa.ECDH_1PU_A256KW:
+		return keysize + wrapOverhead, len(`,"epk":`) + estimateEPKSize, nil
+	case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
+		// ,"p2c":...,"p2s":"..."
+		saltsize := aesKeySize(string(alg))
+		return keysize + wrapOverhead, len(`,"p2c":,"p2s":""`) + estimateCountSize + base64.EncodedLen(saltsize), nil
+	default:
+		return 0, 0, errors.Errorf(`unsupported key encryption algorithm %q`, alg)
+	}
+}
+
+// aesKeySize returns the AES key size (in bytes) indicated by the
+// algorithm name, such as 16 for "A128GCM" and "PBES2-HS256+A128KW"
+func aesKeySize(name string) int {
+	switch {
+	case strings.Contains(name, `A128`):
+		return 16
+	case strings.Contains(name, `A192`):
+		return 24
+	default:
+		return 32
+	}
+}
@@ -0,0 +1,270 @@
+// +build jwx_mldsa,go1.27
+
+package jwk
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/mldsa"
+	"fmt"
+
+	"github.com/lestrrat-go/blackmagic"
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// This file is only available if compiled with jwx_mldsa build tag,
+// and Go 1.27 or later.
+
+func init() {
+	keyTypeExtensions[jwa.AKP] = &keyTypeExtension{
+		fromRaw:        akpFromRaw,
+		publicRawKeyOf: akpPublicRawKeyOf,
+		newKey: func(private bool) Key {
+			if private {
+				return newAKPPrivateKey()
+			}
+			return newAKPPublicKey()
+		},
+		isPrivate: func(data []byte) bool {
+			var hint struct {
+				Priv json.RawMessage `json:"priv"`
+			}
+			return json.Unmarshal(data, &hint) == nil && len(hint.Priv) > 0
+		},
+	}
+}
+
+func akpFromRaw(rawKey interface{}) (Key, bool, error) {
+	switch rawKey.(type) {
+	case *mldsa.PrivateKey:
+		k := NewAKPPrivateKey()
+		return k, true, k.FromRaw(rawKey)
+	case *mldsa.PublicKey:
+		k := NewAKPPublicKey()
+		return k, true, k.FromRaw(rawKey)
+	default:
+		return nil, false, nil
+	}
+}
+
+func akpPublicRawKeyOf(rawKey interface{}) (interface{}, bool) {
+	switch x := rawKey.(type) {
+	case *mldsa.PrivateKey:
+		return x.PublicKey(), true
+	case *mldsa.PublicKey:
+		return x, true
+	default:
+		return nil, false
+	}
+}
+
+func mldsaAlgorithm(params mldsa.Parameters) (jwa.SignatureAlgorithm, error) {
+	switch params {
+	case mldsa.MLDSA44():
+		return jwa.MLDSA44, nil
+	case mldsa.MLDSA65():
+		return jwa.MLDSA65, nil
+	case mldsa.MLDSA87():
+		return jwa.MLDSA87, nil
+	default:
+		return "", errors.Errorf(`unsupported ML-DSA parameters %s`, params)
+	}
+}
+
+func mldsaParameters(alg string) (mldsa.Parameters, error) {
+	switch jwa.SignatureAlgorithm(alg) {
+	case jwa.MLDSA44:
+		return mldsa.MLDSA44(), nil
+	case jwa.MLDSA65:
+		return mldsa.MLDSA65(), nil
+	case jwa.MLDSA87:
+		return mldsa.MLDSA87(), nil
+	default:
+		return mldsa.Parameters{}, errors.Errorf(`invalid algorithm for AKP key: %q`, alg)
+	}
+}
+
+func (k *akpPublicKey) FromRaw(rawKeyIf interface{}) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.raw.invalidate()
+
+	rawKey, ok := rawKeyIf.(*mldsa.PublicKey)
+	if !ok {
+		return errors.Errorf(`unknown key type %T`, rawKeyIf)
+	}
+
+	alg, err := mldsaAlgorithm(rawKey.Parameters())
+	if err != nil {
+		return err
+	}
+	s := alg.String()
+	k.algorithm = &s
+	k.pub = rawKey.Bytes()
+	return nil
+}
+
+func (k *akpPrivateKey) FromRaw(rawKeyIf interface{}) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	rawKey, ok := rawKeyIf.(*mldsa.PrivateKey)
+	if !ok {
+		return errors.Errorf(`unknown key type %T`, rawKeyIf)
+	}
+
+	pubkey := rawKey.PublicKey()
+	alg, err := mldsaAlgorithm(pubkey.Parameters())
+	if err != nil {
+		return err
+	}
+	s := alg.String()
+	k.algorithm = &s
+	k.pub = pubkey.Bytes()
+	k.priv = rawKey.Bytes()
+	return nil
+}
+
+// Raw returns the *mldsa.PublicKey represented by this JWK. The key
+// is cached (see `Materialize()`), and each call returns a copy of it.
+func (k *akpPublicKey) Raw(v interface{}) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	pubk, err := k.raw.getCopy(k.buildRaw)
+	if err != nil {
+		return err
+	}
+	return blackmagic.AssignIfCompatible(v, pubk)
+}
+
+func (k *akpPublicKey) buildRaw() (interface{}, error) {
+	params, err := mldsaParameters(k.Algorithm())
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to build public key`)
+	}
+	pubk, err := mldsa.NewPublicKey(params, k.pub)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to build public key`)
+	}
+	return pubk, nil
+}
+
+func (k *akpPublicKey) Materialize() error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	_, err := k.raw.get(k.buildRaw)
+	return err
+}
+
+func (k *akpPrivateKey) Materialize() error {
+	var key interface{}
+	return k.Raw(&key)
+}
+
+// Zeroize is a no-op, as public keys hold no secret material
+func (k *akpPublicKey) Zeroize() {}
+
+// Raw returns the *mldsa.PrivateKey represented by this JWK
+func (k *akpPrivateKey) Raw(v interface{}) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if len(k.priv) == 0 {
+		return errors.New(`missing "priv" value (the key may have been zeroized)`)
+	}
+
+	params, err := mldsaParameters(k.Algorithm())
+	if err != nil {
+		return errors.Wrap(err, `failed to build private key`)
+	}
+	privk, err := mldsa.NewPrivateKey(params, k.priv)
+	if err != nil {
+		return errors.Wrap(err, `failed to build private key`)
+	}
+	if !bytes.Equal(k.pub, privk.PublicKey().Bytes()) {
+		return errors.Errorf(`invalid pub value given priv value`)
+	}
+	return blackmagic.AssignIfCompatible(v, privk)
+}
+
+// Zeroize overwrites the private key material with zeros, and
+// removes it from the key. See `jwk.Key` for details
+func (k *akpPrivateKey) Zeroize() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	zeroize.Bytes(k.priv)
+	k.priv = nil
+}
+
+func makeAKPPublicKey(v interface {
+	Iterate(context.Context) HeaderIterator
+}) (Key, error) {
+	newKey := NewAKPPublicKey()
+
+	// Iterate and copy everything except for the bits that should not be in the public key
+	for iter := v.Iterate(context.TODO()); iter.Next(context.TODO()); {
+		pair := iter.Pair()
+		switch pair.Key {
+		case AKPPrivKey:
+			continue
+		default:
+			if err := newKey.Set(pair.Key.(string), pair.Value); err != nil {
+				return nil, errors.Wrapf(err, `failed to set field %s`, pair.Key)
+			}
+		}
+	}
+
+	return newKey, nil
+}
+
+func (k *akpPrivateKey) PublicKey() (Key, error) {
+	return makeAKPPublicKey(k)
+}
+
+func (k *akpPublicKey) PublicKey() (Key, error) {
+	return makeAKPPublicKey(k)
+}
+
+func akpThumbprint(hash crypto.Hash, alg, pub string) []byte {
+	h := hash.New()
+	fmt.Fprint(h, `{"alg":"`)
+	fmt.Fprint(h, alg)
+	fmt.Fprint(h, `","kty":"AKP","pub":"`)
+	fmt.Fprint(h, pub)
+	fmt.Fprint(h, `"}`)
+	return h.Sum(nil)
+}
+
+// Thumbprint returns the JWK thumbprint using the indicated
+// hashing algorithm, according to RFC 7638
+func (k akpPublicKey) Thumbprint(hash crypto.Hash) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return akpThumbprint(
+		hash,
+		k.Algorithm(),
+		base64.EncodeToString(k.pub),
+	), nil
+}
+
+// Thumbprint returns the JWK thumbprint using the indicated
+// hashing algorithm, according to RFC 7638
+func (k akpPrivateKey) Thumbprint(hash crypto.Hash) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return akpThumbprint(
+		hash,
+		k.Algorithm(),
+		base64.EncodeToString(k.pub),
+	), nil
+}
@@ -0,0 +1,150 @@
+// +build jwx_mldsa,go1.27
+
+package jwk_test
+
+import (
+	"crypto"
+	"crypto/mldsa"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAKP(t *testing.T) {
+	t.Parallel()
+
+	key, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	if !assert.NoError(t, err, `mldsa.GenerateKey should succeed`) {
+		return
+	}
+
+	t.Run("private key roundtrip", func(t *testing.T) {
+		t.Parallel()
+		k, err := jwk.New(key)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		if !assert.Implements(t, (*jwk.AKPPrivateKey)(nil), k, `key should be an AKPPrivateKey`) {
+			return
+		}
+		if !assert.Equal(t, jwa.AKP, k.KeyType(), `kty should be AKP`) {
+			return
+		}
+		if !assert.Equal(t, jwa.MLDSA65.String(), k.Algorithm(), `alg should be ML-DSA-65`) {
+			return
+		}
+
+		buf, err := json.Marshal(k)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		parsed, err := jwk.ParseKey(buf)
+		if !assert.NoError(t, err, `jwk.ParseKey should succeed`) {
+			return
+		}
+		if !assert.Implements(t, (*jwk.AKPPrivateKey)(nil), parsed, `parsed key should be an AKPPrivateKey`) {
+			return
+		}
+
+		var raw mldsa.PrivateKey
+		if !assert.NoError(t, parsed.Raw(&raw), `Raw should succeed`) {
+			return
+		}
+		if !assert.True(t, key.Equal(&raw), `raw keys should match`) {
+			return
+		}
+
+		pub, err := jwk.PublicKeyOf(parsed)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+		if !assert.Implements(t, (*jwk.AKPPublicKey)(nil), pub, `public key should be an AKPPublicKey`) {
+			return
+		}
+		if _, ok := pub.Get(jwk.AKPPrivKey); !assert.False(t, ok, `public key should not contain priv`) {
+			return
+		}
+	})
+	t.Run("public key roundtrip", func(t *testing.T) {
+		t.Parallel()
+		k, err := jwk.New(key.PublicKey())
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		buf, err := json.Marshal(k)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		parsed, err := jwk.ParseKey(buf)
+		if !assert.NoError(t, err, `jwk.ParseKey should succeed`) {
+			return
+		}
+		if !assert.Implements(t, (*jwk.AKPPublicKey)(nil), parsed, `parsed key should be an AKPPublicKey`) {
+			return
+		}
+		var raw interface{}
+		if !assert.NoError(t, parsed.Raw(&raw), `Raw should succeed`) {
+			return
+		}
+		if !assert.True(t, key.PublicKey().Equal(raw.(crypto.PublicKey)), `raw keys should match`) {
+			return
+		}
+
+		tp1, err := k.Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `Thumbprint should succeed`) {
+			return
+		}
+		priv, _ := jwk.New(key)
+		tp2, err := priv.Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `Thumbprint should succeed`) {
+			return
+		}
+		if !assert.Equal(t, tp1, tp2, `public and private thumbprints should match`) {
+			return
+		}
+	})
+	t.Run("Clone", func(t *testing.T) {
+		t.Parallel()
+		priv, err := jwk.New(key)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		pub, err := jwk.New(key.PublicKey())
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		for _, k := range []jwk.Key{priv, pub} {
+			cloned, err := k.Clone()
+			if !assert.NoError(t, err, `k.Clone should succeed`) {
+				return
+			}
+			if !assert.IsType(t, k, cloned, `cloned key should be of the same type`) {
+				return
+			}
+			expected, _ := json.Marshal(k)
+			buf, err := json.Marshal(cloned)
+			if !assert.NoError(t, err, `json.Marshal should succeed`) {
+				return
+			}
+			if !assert.Equal(t, expected, buf, `cloned key should match`) {
+				return
+			}
+		}
+	})
+	t.Run("mismatched pub and priv", func(t *testing.T) {
+		t.Parallel()
+		other, err := mldsa.GenerateKey(mldsa.MLDSA65())
+		if !assert.NoError(t, err, `mldsa.GenerateKey should succeed`) {
+			return
+		}
+		k, _ := jwk.New(key)
+		if !assert.NoError(t, k.Set(jwk.AKPPubKey, other.PublicKey().Bytes()), `Set should succeed`) {
+			return
+		}
+		var raw interface{}
+		assert.Error(t, k.Raw(&raw), `Raw should fail`)
+	})
+}
@@ -0,0 +1,272 @@
+package jwk
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// algorithmRequirement describes the keys that an algorithm can be used with
+type algorithmRequirement struct {
+	kty jwa.KeyType
+	// curves lists the acceptable curves for EC and OKP keys.
+	// If empty, any curve is acceptable
+	curves []jwa.EllipticCurveAlgorithm
+	use    KeyUsageType
+	// size is the minimum size of symmetric keys in bytes. If exact
+	// is true, the key must have exactly this size
+	size  int
+	exact bool
+}
+
+func sigRequirement(kty jwa.KeyType, curves ...jwa.EllipticCurveAlgorithm) algorithmRequirement {
+	return algorithmRequirement{kty: kty, curves: curves, use: ForSignature}
+}
+
+func encRequirement(kty jwa.KeyType, curves ...jwa.EllipticCurveAlgorithm) algorithmRequirement {
+	return algorithmRequirement{kty: kty, curves: curves, use: ForEncryption}
+}
+
+func symmetricRequirement(use KeyUsageType, size int, exact bool) algorithmRequirement {
+	return algorithmRequirement{kty: jwa.OctetSeq, use: use, size: size, exact: exact}
+}
+
+// algorithmRequirements lists the algorithms that `jwk.CheckAlgorithm()`
+// knows about. Algorithms that are not listed here are not checked.
+// ES256K is added when compiled with the jwx_es256k build tag
+var algorithmRequirements = map[string]algorithmRequirement{
+	jwa.RS256.String(): sigRequirement(jwa.RSA),
+	jwa.RS384.String(): sigRequirement(jwa.RSA),
+	jwa.RS512.String(): sigRequirement(jwa.RSA),
+	jwa.PS256.String(): sigRequirement(jwa.RSA),
+	jwa.PS384.String(): sigRequirement(jwa.RSA),
+	jwa.PS512.String(): sigRequirement(jwa.RSA),
+	jwa.ES256.String(): sigRequirement(jwa.EC, jwa.P256),
+	jwa.ES384.String(): sigRequirement(jwa.EC, jwa.P384),
+	jwa.ES512.String(): sigRequirement(jwa.EC, jwa.P521),
+	jwa.EdDSA.String(): sigRequirement(jwa.OKP, jwa.Ed25519, jwa.Ed448),
+	jwa.HS256.String(): symmetricRequirement(ForSignature, 32, false),
+	jwa.HS384.String(): symmetricRequirement(ForSignature, 48, false),
+	jwa.HS512.String(): symmetricRequirement(ForSignature, 64, false),
+
+	jwa.RSA1_5.String():       encRequirement(jwa.RSA),
+	jwa.RSA_OAEP.String():     encRequirement(jwa.RSA),
+	jwa.RSA_OAEP_256.String(): encRequirement(jwa.RSA),
+	jwa.A128KW.String():       symmetricRequirement(ForEncryption, 16, true),
+	jwa.A192KW.String():       symmetricRequirement(ForEncryption, 24, true),
+	jwa.A256KW.String():       symmetricRequirement(ForEncryption, 32, true),
+	jwa.A128GCMKW.String():    symmetricRequirement(ForEncryption, 16, true),
+	jwa.A192GCMKW.String():    symmetricRequirement(ForEncryption, 24, true),
+	jwa.A256GCMKW.String():    symmetricRequirement(ForEncryption, 32, true),
+}
+
+// ecdhAlgorithms can be used with both EC and OKP keys, so they
+// are checked separately in checkECDHAlgorithm
+var ecdhAlgorithms = map[string]struct{}{
+	jwa.ECDH_ES.String():         {},
+	jwa.ECDH_ES_A128KW.String():  {},
+	jwa.ECDH_ES_A192KW.String():  {},
+	jwa.ECDH_ES_A256KW.String():  {},
+	jwa.ECDH_1PU.String():        {},
+	jwa.ECDH_1PU_A128KW.String(): {},
+	jwa.ECDH_1PU_A192KW.String(): {},
+	jwa.ECDH_1PU_A256KW.String(): {},
+}
+
+// ecdsaDefaultAlgorithms maps the curves of EC keys to the signature
+// algorithm assigned by `jwk.AssignAlgorithm()`. secp256k1 is added
+// when compiled with the jwx_es256k build tag
+var ecdsaDefaultAlgorithms = map[jwa.EllipticCurveAlgorithm]jwa.SignatureAlgorithm{
+	jwa.P256: jwa.ES256,
+	jwa.P384: jwa.ES384,
+	jwa.P521: jwa.ES512,
+}
+
+type curveKey interface {
+	Crv() jwa.EllipticCurveAlgorithm
+}
+
+// CheckAlgorithm checks that the "alg" field of the key does not
+// contradict the other parameters of the key. For example, an EC key
+// on the P-384 curve may not specify "ES256", an RSA key may not specify
+// "HS256", a key with "use" set to "enc" may not specify a signature
+// algorithm, and a symmetric key may not be shorter than required
+// by the algorithm.
+//
+// Keys without the "alg" field, and algorithms that are not known to
+// this package (e.g. those registered by users) are not checked.
+func CheckAlgorithm(key Key) error {
+	alg := key.Algorithm()
+	if alg == "" {
+		return nil
+	}
+
+	if _, ok := ecdhAlgorithms[alg]; ok {
+		return checkECDHAlgorithm(key, alg)
+	}
+
+	req, ok := algorithmRequirements[alg]
+	if !ok {
+		return nil
+	}
+
+	if use := key.KeyUsage(); use != "" && use != req.use.String() {
+		return errors.Errorf(`algorithm %q cannot be used with a key whose "use" is %q`, alg, use)
+	}
+
+	if kty := key.KeyType(); kty != req.kty {
+		return errors.Errorf(`algorithm %q cannot be used with %q keys`, alg, kty)
+	}
+
+	if len(req.curves) > 0 {
+		ck, ok := key.(curveKey)
+		if !ok {
+			return errors.Errorf(`failed to determine the curve of the key (%T)`, key)
+		}
+		if !containsCurve(req.curves, ck.Crv()) {
+			return errors.Errorf(`algorithm %q cannot be used with curve %q`, alg, ck.Crv())
+		}
+	}
+
+	if req.size > 0 {
+		sk, ok := key.(SymmetricKey)
+		if !ok {
+			return errors.Errorf(`failed to determine the size of the key (%T)`, key)
+		}
+		size := len(sk.Octets())
+		if req.exact && size != req.size {
+			return errors.Errorf(`algorithm %q requires a key of %d bytes, got %d bytes`, alg, req.size, size)
+		}
+		if size < req.size {
+			return errors.Errorf(`algorithm %q requires a key of at least %d bytes, got %d bytes`, alg, req.size, size)
+		}
+	}
+	return nil
+}
+
+func checkECDHAlgorithm(key Key, alg string) error {
+	if use := key.KeyUsage(); use != "" && use != ForEncryption.String() {
+		return errors.Errorf(`algorithm %q cannot be used with a key whose "use" is %q`, alg, use)
+	}
+
+	switch key.KeyType() {
+	case jwa.EC:
+		return nil
+	case jwa.OKP:
+		ck, ok := key.(curveKey)
+		if !ok {
+			return errors.Errorf(`failed to determine the curve of the key (%T)`, key)
+		}
+		if crv := ck.Crv(); crv != jwa.X25519 && crv != jwa.X448 {
+			return errors.Errorf(`algorithm %q cannot be used with curve %q`, alg, crv)
+		}
+		return nil
+	default:
+		return errors.Errorf(`algorithm %q cannot be used with %q keys`, alg, key.KeyType())
+	}
+}
+
+func containsCurve(list []jwa.EllipticCurveAlgorithm, crv jwa.EllipticCurveAlgorithm) bool {
+	for _, v := range list {
+		if v == crv {
+			return true
+		}
+	}
+	return false
+}
+
+// AssignAlgorithm is a convenience function to automatically assign the
+// "alg" field of the key, if it already doesn't have one. The algorithm
+// is inferred from the type of the key, its curve or size, and its "use"
+// field:
+//
+//   - RSA keys: RS256, or RSA-OAEP-256 for encryption keys
+//   - EC keys: ES256, ES384 or ES512 depending on the curve, or ECDH-ES
+//     for encryption keys
+//   - OKP keys: EdDSA for Ed25519 and Ed448, ECDH-ES for X25519 and X448
+//   - Symmetric keys: HS256, or A128KW, A192KW or A256KW depending on the
+//     size of the key for encryption keys
+//
+// If the key already has an "alg" field, it is checked using
+// `jwk.CheckAlgorithm()` instead.
+func AssignAlgorithm(key Key) error {
+	if _, ok := key.Get(AlgorithmKey); ok {
+		return CheckAlgorithm(key)
+	}
+
+	alg, err := defaultAlgorithm(key)
+	if err != nil {
+		return errors.Wrap(err, `failed to infer algorithm`)
+	}
+
+	if err := key.Set(AlgorithmKey, alg); err != nil {
+		return errors.Wrap(err, `failed to set "alg"`)
+	}
+	return nil
+}
+
+func defaultAlgorithm(key Key) (string, error) {
+	enc := key.KeyUsage() == ForEncryption.String()
+
+	switch key.KeyType() {
+	case jwa.RSA:
+		if enc {
+			return jwa.RSA_OAEP_256.String(), nil
+		}
+		return jwa.RS256.String(), nil
+	case jwa.EC:
+		if enc {
+			return jwa.ECDH_ES.String(), nil
+		}
+		ck, ok := key.(curveKey)
+		if !ok {
+			return "", errors.Errorf(`failed to determine the curve of the key (%T)`, key)
+		}
+		alg, ok := ecdsaDefaultAlgorithms[ck.Crv()]
+		if !ok {
+			return "", errors.Errorf(`unsupported curve %q`, ck.Crv())
+		}
+		return alg.String(), nil
+	case jwa.OKP:
+		ck, ok := key.(curveKey)
+		if !ok {
+			return "", errors.Errorf(`failed to determine the curve of the key (%T)`, key)
+		}
+		switch crv := ck.Crv(); crv {
+		case jwa.Ed25519, jwa.Ed448:
+			if enc {
+				return "", errors.Errorf(`curve %q cannot be used for encryption`, crv)
+			}
+			return jwa.EdDSA.String(), nil
+		case jwa.X25519, jwa.X448:
+			if key.KeyUsage() == ForSignature.String() {
+				return "", errors.Errorf(`curve %q cannot be used for signatures`, crv)
+			}
+			return jwa.ECDH_ES.String(), nil
+		default:
+			return "", errors.Errorf(`unsupported curve %q`, crv)
+		}
+	case jwa.OctetSeq:
+		sk, ok := key.(SymmetricKey)
+		if !ok {
+			return "", errors.Errorf(`failed to determine the size of the key (%T)`, key)
+		}
+		size := len(sk.Octets())
+		if enc {
+			switch size {
+			case 16:
+				return jwa.A128KW.String(), nil
+			case 24:
+				return jwa.A192KW.String(), nil
+			case 32:
+				return jwa.A256KW.String(), nil
+			}
+			return "", errors.Errorf(`no key wrapping algorithm for keys of %d bytes`, size)
+		}
+		if size < 32 {
+			return "", errors.Errorf(`key of %d bytes is too short for HS256`, size)
+		}
+		return jwa.HS256.String(), nil
+	default:
+		return "", errors.Errorf(`unsupported key type %q`, key.KeyType())
+	}
+}
@@ -54,6 +54,13 @@ func (c *CertificateChain) Accept(v interface{}) error {
 		}
 	case []string:
 		list = x
+	case []*x509.Certificate:
+		certs := make([]*x509.Certificate, len(x))
+		copy(certs, x)
+		*c = CertificateChain{
+			certs: certs,
+		}
+		return nil
 	case CertificateChain:
 		certs := make([]*x509.Certificate, len(x.certs))
 		copy(certs, x.certs)
@@ -67,7 +74,7 @@ func (c *CertificateChain) Accept(v interface{}) error {
 
 	certs := make([]*x509.Certificate, len(list))
 	for i, e := range list {
-		buf, err := base64.DecodeString(e)
+		buf, err := base64.DecodeStdString(e)
 		if err != nil {
 			return errors.Wrap(err, `failed to base64 decode list element`)
 		}
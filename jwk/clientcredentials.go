@@ -0,0 +1,172 @@
+package jwk
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/pkg/errors"
+)
+
+// tokenExpiryDelta is subtracted from the lifetime of access tokens,
+// so that they are not presented right before they expire
+const tokenExpiryDelta = 10 * time.Second
+
+// ClientCredentials obtains OAuth 2.0 access tokens using the client
+// credentials grant (RFC 6749 Section 4.4), which are presented when
+// fetching JWKS from endpoints that require authentication.
+// Use `jwk.NewClientCredentials()` to create one, and pass it to
+// `jwk.Fetch()` or `(*jwk.AutoRefresh).Configure()` using
+// `jwk.WithClientCredentials()`.
+//
+// Access tokens are cached until they expire, and are shared between
+// all fetches that use the same object. If the JWKS endpoint responds
+// with 401 Unauthorized, a new access token is obtained and the
+// request is retried once.
+type ClientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewClientCredentials creates a new ClientCredentials object that
+// obtains access tokens from the token endpoint at `tokenURL`. The client
+// authenticates itself using HTTP Basic authentication, as described in
+// RFC 6749 Section 2.3.1.
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *ClientCredentials {
+	return &ClientCredentials{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+	}
+}
+
+// accessToken returns the cached access token, or obtains a new one
+// if there is none, or if it has expired
+func (cc *ClientCredentials) accessToken(ctx context.Context, httpcl HTTPClient) (string, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.token != "" && (cc.expires.IsZero() || time.Now().Before(cc.expires)) {
+		return cc.token, nil
+	}
+
+	token, expiresIn, err := cc.requestToken(ctx, httpcl)
+	if err != nil {
+		return "", err
+	}
+
+	cc.token = token
+	cc.expires = time.Time{}
+	if expiresIn > 0 {
+		lifetime := time.Duration(expiresIn) * time.Second
+		if lifetime > 2*tokenExpiryDelta {
+			lifetime -= tokenExpiryDelta
+		}
+		cc.expires = time.Now().Add(lifetime)
+	}
+	return cc.token, nil
+}
+
+// invalidate discards the cached access token, if it is still `token`.
+// If another fetch has already obtained a new token, it is kept
+func (cc *ClientCredentials) invalidate(token string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.token == token {
+		cc.token = ""
+	}
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (cc *ClientCredentials) requestToken(ctx context.Context, httpcl HTTPClient) (string, int64, error) {
+	form := url.Values{}
+	form.Set(`grant_type`, `client_credentials`)
+	if len(cc.scopes) > 0 {
+		form.Set(`scope`, strings.Join(cc.scopes, ` `))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cc.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, errors.Wrap(err, `failed to create token request`)
+	}
+	req.Header.Set(`Content-Type`, `application/x-www-form-urlencoded`)
+	req.Header.Set(`Accept`, `application/json`)
+	req.SetBasicAuth(url.QueryEscape(cc.clientID), url.QueryEscape(cc.clientSecret))
+
+	res, err := httpcl.Do(req)
+	if err != nil {
+		return "", 0, errors.Wrap(err, `failed to request access token`)
+	}
+	defer res.Body.Close()
+
+	var tr tokenResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&tr); err != nil && res.StatusCode == http.StatusOK {
+		return "", 0, errors.Wrap(err, `failed to decode token response`)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		if tr.Error != "" {
+			if tr.ErrorDescription != "" {
+				return "", 0, errors.Errorf(`failed to request access token (status = %d): %s: %s`, res.StatusCode, tr.Error, tr.ErrorDescription)
+			}
+			return "", 0, errors.Errorf(`failed to request access token (status = %d): %s`, res.StatusCode, tr.Error)
+		}
+		return "", 0, errors.Errorf(`failed to request access token (status = %d)`, res.StatusCode)
+	}
+
+	if tr.AccessToken == "" {
+		return "", 0, errors.New(`token response does not contain an access token`)
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, `bearer`) {
+		return "", 0, errors.Errorf(`unsupported token type %q`, tr.TokenType)
+	}
+	return tr.AccessToken, tr.ExpiresIn, nil
+}
+
+// doAuthenticated sends the request with an access token obtained via
+// `cc`. If the response is 401 Unauthorized, the token is discarded and
+// the request is sent once more with a new token
+func (cc *ClientCredentials) doAuthenticated(ctx context.Context, httpcl HTTPClient, req *http.Request) (*http.Response, error) {
+	for i := 0; ; i++ {
+		token, err := cc.accessToken(ctx, httpcl)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to obtain access token`)
+		}
+
+		authreq := req.Clone(ctx)
+		authreq.Header.Set(`Authorization`, `Bearer `+token)
+		res, err := httpcl.Do(authreq)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusUnauthorized || i > 0 {
+			return res, nil
+		}
+
+		// drain the body so that the connection can be reused
+		_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<16))
+		res.Body.Close()
+		cc.invalidate(token)
+	}
+}
@@ -0,0 +1,214 @@
+package jwk
+
+import (
+	"context"
+	"crypto"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lestrrat-go/iter/arrayiter"
+)
+
+// NewConcurrentSet creates an empty `jwk.Set` object that is safe for
+// concurrent use.
+//
+// The keys are stored in an immutable slice that is replaced wholesale
+// on every modification (copy-on-write). Readers such as `Get()`,
+// `LookupKeyID()`, `Iterate()` and `json.Marshal()` never block, and
+// always observe a consistent snapshot of the set, while writers such as
+// `Add()`, `Remove()` and `Replace()` are serialized.
+//
+// This is useful for servers that rotate the keys in a published JWKS
+// while serving requests. Modifications are more expensive than those
+// of `jwk.NewSet()`, so it is best suited for sets that are read much
+// more often than they are written.
+func NewConcurrentSet() Set {
+	var s concurrentSet
+	s.keys.Store([]Key(nil))
+	return &s
+}
+
+type concurrentSet struct {
+	// keys holds a []Key, which must never be modified in place
+	keys atomic.Value
+	// mu serializes writers
+	mu sync.Mutex
+	// dc holds a decodeCtxBox, as atomic.Value cannot store nil
+	dc atomic.Value
+}
+
+type decodeCtxBox struct {
+	dc DecodeCtx
+}
+
+func (s *concurrentSet) load() []Key {
+	return s.keys.Load().([]Key) //nolint:forcetypeassert
+}
+
+func (s *concurrentSet) Get(idx int) (Key, bool) {
+	keys := s.load()
+	if idx >= 0 && idx < len(keys) {
+		return keys[idx], true
+	}
+	return nil, false
+}
+
+func (s *concurrentSet) Len() int {
+	return len(s.load())
+}
+
+func indexOf(keys []Key, key Key) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *concurrentSet) Index(key Key) int {
+	return indexOf(s.load(), key)
+}
+
+func (s *concurrentSet) LookupKeyID(kid string) (Key, bool) {
+	for _, key := range s.load() {
+		if key.KeyID() == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (s *concurrentSet) Add(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.load()
+	if indexOf(keys, key) > -1 {
+		return false
+	}
+
+	newKeys := make([]Key, len(keys), len(keys)+1)
+	copy(newKeys, keys)
+	s.keys.Store(append(newKeys, key))
+	return true
+}
+
+func (s *concurrentSet) Remove(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.load()
+	i := indexOf(keys, key)
+	if i < 0 {
+		return false
+	}
+
+	newKeys := make([]Key, 0, len(keys)-1)
+	newKeys = append(newKeys, keys[:i]...)
+	s.keys.Store(append(newKeys, keys[i+1:]...))
+	return true
+}
+
+func (s *concurrentSet) RemoveByKeyID(kid string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.load()
+	newKeys := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		if key.KeyID() != kid {
+			newKeys = append(newKeys, key)
+		}
+	}
+
+	removed := len(keys) - len(newKeys)
+	if removed > 0 {
+		s.keys.Store(newKeys)
+	}
+	return removed
+}
+
+func (s *concurrentSet) Replace(idx int, key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.load()
+	if idx < 0 || idx >= len(keys) {
+		return false
+	}
+
+	if i := indexOf(keys, key); i > -1 && i != idx {
+		return false
+	}
+
+	newKeys := make([]Key, len(keys))
+	copy(newKeys, keys)
+	newKeys[idx] = key
+	s.keys.Store(newKeys)
+	return true
+}
+
+func (s *concurrentSet) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys.Store([]Key(nil))
+}
+
+func (s *concurrentSet) Iterate(ctx context.Context) KeyIterator {
+	keys := s.load()
+	ch := make(chan *KeyPair, len(keys))
+	go iterate(ctx, keys, ch)
+	return arrayiter.New(ch)
+}
+
+func (s *concurrentSet) MarshalJSON() ([]byte, error) {
+	return marshalKeys(s.load())
+}
+
+func (s *concurrentSet) UnmarshalJSON(data []byte) error {
+	var tmp set
+	tmp.dc = s.DecodeCtx()
+	if err := tmp.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.load()
+	newKeys := make([]Key, 0, len(keys)+len(tmp.keys))
+	newKeys = append(newKeys, keys...)
+	s.keys.Store(append(newKeys, tmp.keys...))
+	return nil
+}
+
+func (s *concurrentSet) DecodeCtx() DecodeCtx {
+	box, _ := s.dc.Load().(decodeCtxBox)
+	return box.dc
+}
+
+func (s *concurrentSet) SetDecodeCtx(dc DecodeCtx) {
+	s.dc.Store(decodeCtxBox{dc: dc})
+}
+
+func (s *concurrentSet) Clone() (Set, error) {
+	s2 := NewConcurrentSet().(*concurrentSet) //nolint:forcetypeassert
+	keys := s.load()
+	newKeys := make([]Key, len(keys))
+	copy(newKeys, keys)
+	s2.keys.Store(newKeys)
+	return s2, nil
+}
+
+func (s *concurrentSet) ActiveKeys(now time.Time) Set {
+	s2 := NewConcurrentSet().(*concurrentSet) //nolint:forcetypeassert
+	s2.keys.Store(activeKeys(s.load(), now))
+	return s2
+}
+
+func (s *concurrentSet) Fingerprint(hash crypto.Hash) ([]byte, error) {
+	return fingerprint(hash, s.load())
+}
@@ -0,0 +1,201 @@
+package jwk
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/pkg/errors"
+)
+
+// MergeStrategy specifies how `jwk.Merge()` resolves conflicts between
+// keys that are present in both sets, but have different contents.
+type MergeStrategy int
+
+const (
+	// MergeKeepExisting keeps the key from the first set
+	MergeKeepExisting MergeStrategy = iota
+	// MergeReplace replaces the key from the first set with the key
+	// from the second set
+	MergeReplace
+	// MergeFailOnConflict makes `jwk.Merge()` return an error
+	MergeFailOnConflict
+)
+
+// KeySetEvent describes the difference between two versions of a
+// key set, as computed by `jwk.Diff()`. It is delivered to handlers
+// specified by `jwk.WithKeyChangeNotification()`, and to channels
+// obtained via `(*jwk.AutoRefresh).Subscribe()`
+type KeySetEvent struct {
+	// URL is the location of the key set. It is only populated for
+	// events generated by `jwk.AutoRefresh`
+	URL     string
+	Added   []Key
+	Removed []Key
+	Changed []Key
+}
+
+// KeyChangeHandler is called by `jwk.AutoRefresh` when a refreshed
+// key set differs from the previous one. See `jwk.WithKeyChangeNotification()`
+type KeyChangeHandler func(KeySetEvent)
+
+// keyIdentity returns the value that is used to determine if two keys
+// in different sets refer to the same key: the key ID if available,
+// and the thumbprint of the key otherwise.
+func keyIdentity(key Key) string {
+	if kid := key.KeyID(); kid != "" {
+		return `kid:` + kid
+	}
+
+	if tp, err := key.Thumbprint(crypto.SHA256); err == nil {
+		return `tp:` + base64.RawURLEncoding.EncodeToString(tp)
+	}
+
+	// Should not happen for well-formed keys, but fall back to
+	// the serialized form so that the key is still tracked
+	buf, _ := json.Marshal(key)
+	return `json:` + string(buf)
+}
+
+// sameKey returns true if both keys have identical contents, including
+// their parameters such as "use" and "alg"
+func sameKey(a, b Key) bool {
+	if a == b {
+		return true
+	}
+
+	abuf, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bbuf, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(abuf, bbuf)
+}
+
+func keysOf(set Set) []Key {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keys := make([]Key, 0, set.Len())
+	for iter := set.Iterate(ctx); iter.Next(ctx); {
+		keys = append(keys, iter.Pair().Value.(Key)) //nolint:forcetypeassert
+	}
+	return keys
+}
+
+// Diff computes the difference between two versions of a key set.
+//
+// Keys are matched using their key ID ("kid"), or their thumbprint if they
+// do not have a key ID. `added` contains the keys that are only present in
+// `newSet`, `removed` contains the keys that are only present in `oldSet`,
+// and `changed` contains the keys in `newSet` whose counterparts in
+// `oldSet` have different contents (e.g. the same key ID with different
+// key material).
+//
+// A nil set is treated as an empty set.
+func Diff(oldSet, newSet Set) (added, removed, changed []Key) {
+	var oldKeys, newKeys []Key
+	if oldSet != nil {
+		oldKeys = keysOf(oldSet)
+	}
+	if newSet != nil {
+		newKeys = keysOf(newSet)
+	}
+
+	index := make(map[string]Key, len(oldKeys))
+	for _, key := range oldKeys {
+		id := keyIdentity(key)
+		if _, ok := index[id]; !ok {
+			index[id] = key
+		}
+	}
+
+	seen := make(map[string]struct{}, len(newKeys))
+	for _, key := range newKeys {
+		id := keyIdentity(key)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		prev, ok := index[id]
+		switch {
+		case !ok:
+			added = append(added, key)
+		case !sameKey(prev, key):
+			changed = append(changed, key)
+		}
+	}
+
+	for _, key := range oldKeys {
+		id := keyIdentity(key)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		removed = append(removed, key)
+	}
+	return added, removed, changed
+}
+
+// Merge creates a new set containing the keys from both `a` and `b`.
+// Neither of the original sets is modified.
+//
+// Keys are matched in the same way as `jwk.Diff()`. Keys from `a` come
+// first, followed by the keys that are only present in `b`. If a key
+// is present in both sets with different contents, `strategy` determines
+// which one is used.
+func Merge(a, b Set, strategy MergeStrategy) (Set, error) {
+	switch strategy {
+	case MergeKeepExisting, MergeReplace, MergeFailOnConflict:
+	default:
+		return nil, errors.Errorf(`invalid merge strategy %d`, strategy)
+	}
+
+	var keys []Key
+	index := make(map[string]int)
+	if a != nil {
+		for _, key := range keysOf(a) {
+			id := keyIdentity(key)
+			if _, ok := index[id]; ok {
+				continue
+			}
+			index[id] = len(keys)
+			keys = append(keys, key)
+		}
+	}
+
+	if b != nil {
+		for _, key := range keysOf(b) {
+			id := keyIdentity(key)
+			i, ok := index[id]
+			if !ok {
+				index[id] = len(keys)
+				keys = append(keys, key)
+				continue
+			}
+
+			if sameKey(keys[i], key) {
+				continue
+			}
+
+			switch strategy {
+			case MergeReplace:
+				keys[i] = key
+			case MergeFailOnConflict:
+				return nil, errors.Errorf(`conflicting keys found for %q`, id)
+			}
+		}
+	}
+
+	merged := NewSet()
+	for _, key := range keys {
+		merged.Add(key)
+	}
+	return merged, nil
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/lestrrat-go/blackmagic"
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/internal/ecutil"
+	"github.com/lestrrat-go/jwx/internal/rawkey"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
 )
@@ -24,6 +26,7 @@ func init() {
 func (k *ecdsaPublicKey) FromRaw(rawKey *ecdsa.PublicKey) error {
 	k.mu.Lock()
 	defer k.mu.Unlock()
+	k.raw.invalidate()
 
 	if rawKey.X == nil {
 		return errors.Errorf(`invalid ecdsa.PublicKey`)
@@ -108,23 +111,60 @@ func buildECDSAPublicKey(alg jwa.EllipticCurveAlgorithm, xbuf, ybuf []byte) (*ec
 	return &ecdsa.PublicKey{Curve: crv, X: &x, Y: &y}, nil
 }
 
-// Raw returns the EC-DSA public key represented by this JWK
+// Raw returns the EC-DSA public key represented by this JWK. The key
+// is cached (see `Materialize()`), and each call returns a copy of it.
 func (k *ecdsaPublicKey) Raw(v interface{}) error {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
+	pubk, err := k.raw.getCopy(k.buildRaw)
+	if err != nil {
+		return err
+	}
+	return blackmagic.AssignIfCompatible(v, pubk)
+}
+
+func (k *ecdsaPublicKey) buildRaw() (interface{}, error) {
 	pubk, err := buildECDSAPublicKey(k.Crv(), k.x, k.y)
 	if err != nil {
-		return errors.Wrap(err, `failed to build public key`)
+		return nil, errors.Wrap(err, `failed to build public key`)
 	}
+	return pubk, nil
+}
 
-	return blackmagic.AssignIfCompatible(v, pubk)
+func (k *ecdsaPublicKey) Materialize() error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	_, err := k.raw.get(k.buildRaw)
+	return err
 }
 
+// SharedRaw returns the cached *ecdsa.PublicKey without copying it. It is
+// used by jws and jwe, which do not modify the key (see internal/rawkey)
+func (k *ecdsaPublicKey) SharedRaw(rawkey.Token) (interface{}, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.raw.get(k.buildRaw)
+}
+
+func (k *ecdsaPrivateKey) Materialize() error {
+	var key ecdsa.PrivateKey
+	return k.Raw(&key)
+}
+
+// Zeroize is a no-op, as public keys hold no secret material
+func (k *ecdsaPublicKey) Zeroize() {}
+
 func (k *ecdsaPrivateKey) Raw(v interface{}) error {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
+	if len(k.d) == 0 {
+		return errors.New(`missing "d" value (the key may have been zeroized)`)
+	}
+
 	pubk, err := buildECDSAPublicKey(k.Crv(), k.x, k.y)
 	if err != nil {
 		return errors.Wrap(err, `failed to build public key`)
@@ -139,6 +179,16 @@ func (k *ecdsaPrivateKey) Raw(v interface{}) error {
 	return blackmagic.AssignIfCompatible(v, &key)
 }
 
+// Zeroize overwrites the private key material with zeros, and
+// removes it from the key. See `jwk.Key` for details
+func (k *ecdsaPrivateKey) Zeroize() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	zeroize.Bytes(k.d)
+	k.d = nil
+}
+
 func makeECDSAPublicKey(v interface {
 	Iterate(context.Context) HeaderIterator
 }) (Key, error) {
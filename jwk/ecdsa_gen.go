@@ -297,7 +297,8 @@ func (h *ecdsaPrivateKey) setNoLock(name string, value interface{}) error {
 		return errors.Errorf(`invalid value for %s key: %T`, ECDSACrvKey, value)
 	case ECDSADKey:
 		if v, ok := value.([]byte); ok {
-			h.d = v
+			h.d = make([]byte, len(v))
+			copy(h.d, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, ECDSADKey, value)
@@ -331,7 +332,8 @@ func (h *ecdsaPrivateKey) setNoLock(name string, value interface{}) error {
 		return nil
 	case ECDSAXKey:
 		if v, ok := value.([]byte); ok {
-			h.x = v
+			h.x = make([]byte, len(v))
+			copy(h.x, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, ECDSAXKey, value)
@@ -362,7 +364,8 @@ func (h *ecdsaPrivateKey) setNoLock(name string, value interface{}) error {
 		return errors.Errorf(`invalid value for %s key: %T`, X509URLKey, value)
 	case ECDSAYKey:
 		if v, ok := value.([]byte); ok {
-			h.y = v
+			h.y = make([]byte, len(v))
+			copy(h.y, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, ECDSAYKey, value)
@@ -641,6 +644,7 @@ type ecdsaPublicKey struct {
 	x509URL                *string           // https://tools.ietf.org/html/rfc7515#section-4.1.5
 	y                      []byte
 	privateParams          map[string]interface{}
+	raw                    *rawKeyCache
 	mu                     *sync.RWMutex
 	dc                     DecodeCtx
 }
@@ -653,6 +657,7 @@ func newECDSAPublicKey() *ecdsaPublicKey {
 	return &ecdsaPublicKey{
 		mu:            &sync.RWMutex{},
 		privateParams: make(map[string]interface{}),
+		raw:           &rawKeyCache{},
 	}
 }
 
@@ -858,6 +863,7 @@ func (h *ecdsaPublicKey) setNoLock(name string, value interface{}) error {
 	case "kty":
 		return nil
 	case AlgorithmKey:
+		h.raw.invalidate()
 		switch v := value.(type) {
 		case string:
 			h.algorithm = &v
@@ -869,6 +875,7 @@ func (h *ecdsaPublicKey) setNoLock(name string, value interface{}) error {
 		}
 		return nil
 	case ECDSACrvKey:
+		h.raw.invalidate()
 		if v, ok := value.(jwa.EllipticCurveAlgorithm); ok {
 			h.crv = &v
 			return nil
@@ -903,8 +910,10 @@ func (h *ecdsaPublicKey) setNoLock(name string, value interface{}) error {
 		h.keyops = &acceptor
 		return nil
 	case ECDSAXKey:
+		h.raw.invalidate()
 		if v, ok := value.([]byte); ok {
-			h.x = v
+			h.x = make([]byte, len(v))
+			copy(h.x, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, ECDSAXKey, value)
@@ -934,8 +943,10 @@ func (h *ecdsaPublicKey) setNoLock(name string, value interface{}) error {
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, X509URLKey, value)
 	case ECDSAYKey:
+		h.raw.invalidate()
 		if v, ok := value.([]byte); ok {
-			h.y = v
+			h.y = make([]byte, len(v))
+			copy(h.y, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, ECDSAYKey, value)
@@ -953,8 +964,10 @@ func (k *ecdsaPublicKey) Remove(key string) error {
 	defer k.mu.Unlock()
 	switch key {
 	case AlgorithmKey:
+		k.raw.invalidate()
 		k.algorithm = nil
 	case ECDSACrvKey:
+		k.raw.invalidate()
 		k.crv = nil
 	case KeyIDKey:
 		k.keyID = nil
@@ -963,6 +976,7 @@ func (k *ecdsaPublicKey) Remove(key string) error {
 	case KeyOpsKey:
 		k.keyops = nil
 	case ECDSAXKey:
+		k.raw.invalidate()
 		k.x = nil
 	case X509CertChainKey:
 		k.x509CertChain = nil
@@ -973,6 +987,7 @@ func (k *ecdsaPublicKey) Remove(key string) error {
 	case X509URLKey:
 		k.x509URL = nil
 	case ECDSAYKey:
+		k.raw.invalidate()
 		k.y = nil
 	default:
 		delete(k.privateParams, key)
@@ -997,6 +1012,7 @@ func (k *ecdsaPublicKey) SetDecodeCtx(dc DecodeCtx) {
 }
 
 func (h *ecdsaPublicKey) UnmarshalJSON(buf []byte) error {
+	h.raw.invalidate()
 	h.algorithm = nil
 	h.crv = nil
 	h.keyID = nil
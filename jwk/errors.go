@@ -0,0 +1,25 @@
+package jwk
+
+import "github.com/lestrrat-go/jwx"
+
+// ParseError is returned when a JWK or a JWK set could not be parsed.
+// It matches `jwx.ErrParse` via `errors.Is()`
+type ParseError struct {
+	err error
+}
+
+func newParseError(err error) error {
+	return &ParseError{err: err}
+}
+
+func (e *ParseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == jwx.ErrParse
+}
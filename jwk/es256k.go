@@ -10,4 +10,6 @@ import (
 
 func init() {
 	ecutil.RegisterCurve(secp256k1.S256(), jwa.Secp256k1)
+	algorithmRequirements[jwa.ES256K.String()] = sigRequirement(jwa.EC, jwa.Secp256k1)
+	ecdsaDefaultAlgorithms[jwa.Secp256k1] = jwa.ES256K
 }
@@ -0,0 +1,107 @@
+// +build go1.16
+
+package jwk
+
+import (
+	"io/fs"
+	"sort"
+
+	"github.com/lestrrat-go/option"
+	"github.com/pkg/errors"
+)
+
+// WithMergeFiles specifies whether `jwk.ParseFS()` accepts patterns that
+// match more than one file, in which case the keys from all files are
+// merged into one jwk.Set. By default only a single file may match,
+// so that an extra file that is accidentally added to the file system
+// does not silently become part of the key set.
+func WithMergeFiles(b bool) ParseFSOption {
+	return &parseFSOption{option.New(identMergeFiles{}, b)}
+}
+
+// ParseFS parses the JWK sets (or single keys) stored in the files of
+// `fsys` that match `patterns`, using the syntax of `fs.Glob()`. It is
+// meant to load key sets that are shipped with a binary, such as trust
+// anchors embedded with the go:embed directive:
+//
+//   //go:embed keys/*.json
+//   var keys embed.FS
+//
+//   set, err := jwk.ParseFS(keys, []string{"keys/*.json"}, jwk.WithMergeFiles(true))
+//
+// Each pattern must match at least one file. Unless `jwk.WithMergeFiles(true)`
+// is specified, the patterns must match exactly one file in total. When
+// files are merged, they are read in lexical order, and an error is
+// returned if the same "kid" appears in more than one file.
+//
+// `jwk.ParseOption` values such as `jwk.WithPEM()` are passed to
+// `jwk.Parse()` for each file.
+func ParseFS(fsys fs.FS, patterns []string, options ...ParseFSOption) (Set, error) {
+	var merge bool
+	var parseOptions []ParseOption
+	for _, option := range options {
+		if po, ok := option.(ParseOption); ok {
+			parseOptions = append(parseOptions, po)
+			continue
+		}
+
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identMergeFiles{}:
+			merge = option.Value().(bool)
+		}
+	}
+
+	if len(patterns) == 0 {
+		return nil, errors.New(`no patterns specified`)
+	}
+
+	seen := make(map[string]struct{})
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, `invalid pattern %q`, pattern)
+		}
+		if len(matches) == 0 {
+			return nil, errors.Errorf(`pattern %q matches no files`, pattern)
+		}
+		for _, name := range matches {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) > 1 && !merge {
+		return nil, errors.Errorf(`patterns match %d files, but merging was not requested (see jwk.WithMergeFiles)`, len(files))
+	}
+
+	result := NewSet()
+	origins := make(map[string]string)
+	for _, name := range files {
+		buf, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to read %q`, name)
+		}
+		set, err := Parse(buf, parseOptions...)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to parse %q`, name)
+		}
+
+		for i := 0; i < set.Len(); i++ {
+			key, _ := set.Get(i)
+			if kid := key.KeyID(); kid != "" {
+				if origin, ok := origins[kid]; ok && origin != name {
+					return nil, errors.Errorf(`key ID %q in %q is already used in %q`, kid, name, origin)
+				}
+				origins[kid] = name
+			}
+			result.Add(key)
+		}
+	}
+	return result, nil
+}
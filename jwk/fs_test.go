@@ -0,0 +1,101 @@
+// +build go1.16
+
+package jwk_test
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFS(t *testing.T) {
+	t.Parallel()
+
+	newSet := func(t *testing.T, kids ...string) []byte {
+		t.Helper()
+		set := jwk.NewSet()
+		for _, kid := range kids {
+			key, err := jwxtest.GenerateEcdsaJwk()
+			if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+				return nil
+			}
+			pubkey, _ := jwk.PublicKeyOf(key)
+			pubkey.Set(jwk.KeyIDKey, kid)
+			set.Add(pubkey)
+		}
+		buf, err := json.Marshal(set)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return nil
+		}
+		return buf
+	}
+
+	fsys := fstest.MapFS{
+		`keys/a.json`:     {Data: newSet(t, `a1`, `a2`)},
+		`keys/b.json`:     {Data: newSet(t, `b1`)},
+		`other/dup.json`:  {Data: newSet(t, `a1`)},
+		`other/bad.json`:  {Data: []byte(`not json`)},
+		`other/readme.md`: {Data: []byte(`# keys`)},
+	}
+
+	t.Run("Single file", func(t *testing.T) {
+		t.Parallel()
+		set, err := jwk.ParseFS(fsys, []string{`keys/a.json`})
+		if !assert.NoError(t, err, `jwk.ParseFS should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, set.Len(), `set should contain 2 keys`) {
+			return
+		}
+	})
+	t.Run("Merge files", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwk.ParseFS(fsys, []string{`keys/*.json`})
+		if !assert.Error(t, err, `jwk.ParseFS should fail without jwk.WithMergeFiles`) {
+			return
+		}
+
+		set, err := jwk.ParseFS(fsys, []string{`keys/*.json`, `keys/a.json`}, jwk.WithMergeFiles(true))
+		if !assert.NoError(t, err, `jwk.ParseFS should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 3, set.Len(), `set should contain 3 keys`) {
+			return
+		}
+		for _, kid := range []string{`a1`, `a2`, `b1`} {
+			if _, ok := set.LookupKeyID(kid); !assert.True(t, ok, `key %q should be found`, kid) {
+				return
+			}
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwk.ParseFS(fsys, []string{`keys/a.json`, `other/dup.json`}, jwk.WithMergeFiles(true))
+		if !assert.Error(t, err, `jwk.ParseFS should fail for duplicate key IDs`) {
+			return
+		}
+		_, err = jwk.ParseFS(fsys, []string{`nothing/*.json`})
+		if !assert.Error(t, err, `jwk.ParseFS should fail when a pattern matches no files`) {
+			return
+		}
+		_, err = jwk.ParseFS(fsys, []string{`other/bad.json`})
+		if !assert.Error(t, err, `jwk.ParseFS should fail for invalid files`) {
+			return
+		}
+		_, err = jwk.ParseFS(fsys, nil)
+		if !assert.Error(t, err, `jwk.ParseFS should fail without patterns`) {
+			return
+		}
+	})
+	t.Run("ParseOption", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwk.ParseFS(fsys, []string{`keys/b.json`}, jwk.WithPEM(true))
+		if !assert.Error(t, err, `jwk.ParseFS should pass jwk.WithPEM to jwk.Parse`) {
+			return
+		}
+	})
+}
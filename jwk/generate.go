@@ -0,0 +1,262 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"math/big"
+
+	"github.com/lestrrat-go/jwx/internal/ecutil"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRSAKeySize       = 2048
+	defaultSymmetricKeySize = 256
+)
+
+// GenerateKey generates a new private key (or a symmetric key) of
+// the given key type. Use `jwk.WithKeySize()` and `jwk.WithCurve()`
+// to control the parameters of the key:
+//
+//   * jwa.RSA: 2048 bits by default
+//   * jwa.EC: jwa.P256 by default
+//   * jwa.OKP: jwa.Ed25519 by default
+//   * jwa.OctetSeq: 256 bits by default
+//
+// The "alg" and "kid" fields are not set.
+func GenerateKey(kty jwa.KeyType, options ...GenerateOption) (Key, error) {
+	return generateKey(kty, nil, options)
+}
+
+// GenerateKeyWithReader works like `jwk.GenerateKey()`, except that
+// all randomness is read from `rand`. The generated key is a function
+// of the bytes read, so passing a reader that produces a fixed stream
+// of bytes (for example, one seeded with a constant) produces the same
+// key every time.
+//
+// This is meant for test fixtures. Note that since Go 1.26, the
+// standard library ignores the reader passed to rsa.GenerateKey and
+// ecdsa.GenerateKey, so RSA and EC keys are derived by this function
+// itself: EC private scalars are read directly from `rand`, and RSA
+// primes are found by an incremental search starting from values read
+// from `rand`. Keys that protect real data should be generated using
+// `jwk.GenerateKey()` instead.
+func GenerateKeyWithReader(kty jwa.KeyType, rand io.Reader, options ...GenerateOption) (Key, error) {
+	if rand == nil {
+		return nil, errors.New(`rand must not be nil`)
+	}
+	return generateKey(kty, rand, options)
+}
+
+// generateKey uses the standard library key generation when `r` is nil,
+// and derives the key from `r` otherwise
+func generateKey(kty jwa.KeyType, r io.Reader, options []GenerateOption) (Key, error) {
+	var size int
+	var crv jwa.EllipticCurveAlgorithm
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identKeySize{}:
+			size = option.Value().(int)
+		case identCurve{}:
+			crv = option.Value().(jwa.EllipticCurveAlgorithm)
+		}
+	}
+
+	var raw interface{}
+	switch kty {
+	case jwa.RSA:
+		if size == 0 {
+			size = defaultRSAKeySize
+		}
+		var key *rsa.PrivateKey
+		var err error
+		if r == nil {
+			key, err = rsa.GenerateKey(rand.Reader, size)
+		} else {
+			key, err = rsaKeyFromReader(r, size)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate RSA private key`)
+		}
+		raw = key
+	case jwa.EC:
+		if crv == "" {
+			crv = jwa.P256
+		}
+		curve, ok := ecutil.CurveForAlgorithm(crv)
+		if !ok {
+			return nil, errors.Errorf(`invalid curve algorithm %s`, crv)
+		}
+		var key *ecdsa.PrivateKey
+		var err error
+		if r == nil {
+			key, err = ecdsa.GenerateKey(curve, rand.Reader)
+		} else {
+			key, err = ecdsaKeyFromReader(r, curve)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate ECDSA private key`)
+		}
+		raw = key
+	case jwa.OKP:
+		if crv == "" {
+			crv = jwa.Ed25519
+		}
+		if crv != jwa.Ed25519 && crv != jwa.X25519 {
+			return nil, errors.Errorf(`invalid curve algorithm %s`, crv)
+		}
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := io.ReadFull(readerOrDefault(r), seed); err != nil {
+			return nil, errors.Wrap(err, `failed to read seed`)
+		}
+		return FromOKPSeed(crv, seed)
+	case jwa.OctetSeq:
+		if size == 0 {
+			size = defaultSymmetricKeySize
+		}
+		if size <= 0 || size%8 != 0 {
+			return nil, errors.Errorf(`invalid key size %d: must be a positive multiple of 8`, size)
+		}
+		octets := make([]byte, size/8)
+		if _, err := io.ReadFull(readerOrDefault(r), octets); err != nil {
+			return nil, errors.Wrap(err, `failed to read symmetric key`)
+		}
+		raw = octets
+	default:
+		return nil, errors.Errorf(`unsupported key type %s`, kty)
+	}
+	return New(raw)
+}
+
+func readerOrDefault(r io.Reader) io.Reader {
+	if r == nil {
+		return rand.Reader
+	}
+	return r
+}
+
+// ecdsaKeyFromReader reads the private scalar from r, retrying until
+// it falls in [1, N-1]
+func ecdsaKeyFromReader(r io.Reader, curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
+	params := curve.Params()
+	bitSize := params.N.BitLen()
+	buf := make([]byte, (bitSize+7)/8)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, errors.Wrap(err, `failed to read private scalar`)
+		}
+		if excess := len(buf)*8 - bitSize; excess > 0 {
+			buf[0] &= byte(0xff >> excess)
+		}
+		d := new(big.Int).SetBytes(buf)
+		if d.Sign() == 0 || d.Cmp(params.N) >= 0 {
+			continue
+		}
+
+		var key ecdsa.PrivateKey
+		key.Curve = curve
+		key.D = d
+		key.X, key.Y = curve.ScalarBaseMult(buf)
+		return &key, nil
+	}
+}
+
+var rsaPublicExponent = big.NewInt(65537)
+
+// rsaKeyFromReader creates a two-prime RSA key with e = 65537, using
+// primes found by rsaPrimeFromReader
+func rsaKeyFromReader(r io.Reader, bits int) (*rsa.PrivateKey, error) {
+	if bits < 1024 {
+		return nil, errors.Errorf(`invalid key size %d: must be at least 1024 bits`, bits)
+	}
+
+	one := big.NewInt(1)
+	for {
+		p, err := rsaPrimeFromReader(r, (bits+1)/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := rsaPrimeFromReader(r, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits {
+			continue
+		}
+
+		pminus1 := new(big.Int).Sub(p, one)
+		qminus1 := new(big.Int).Sub(q, one)
+		phi := new(big.Int).Mul(pminus1, qminus1)
+		d := new(big.Int).ModInverse(rsaPublicExponent, phi)
+		if d == nil {
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{
+				N: n,
+				E: int(rsaPublicExponent.Int64()),
+			},
+			D:      d,
+			Primes: []*big.Int{p, q},
+		}
+		key.Precompute()
+		if err := key.Validate(); err != nil {
+			return nil, errors.Wrap(err, `generated RSA key is invalid`)
+		}
+		return key, nil
+	}
+}
+
+// rsaPrimeFromReader reads a starting point with the two most
+// significant bits set from r, and returns the first probable prime
+// p at or above it for which p-1 is coprime to the public exponent
+func rsaPrimeFromReader(r io.Reader, bits int) (*big.Int, error) {
+	buf := make([]byte, (bits+7)/8)
+	one := big.NewInt(1)
+	two := big.NewInt(2)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, errors.Wrap(err, `failed to read prime candidate`)
+		}
+
+		// clear the excess bits, and set the top two bits so that the
+		// product of two such primes has exactly the expected size
+		b := uint(bits % 8)
+		if b == 0 {
+			b = 8
+		}
+		buf[0] &= byte(int(1<<b) - 1)
+		if b >= 2 {
+			buf[0] |= 3 << (b - 2)
+		} else {
+			buf[0] |= 1
+			buf[1] |= 0x80
+		}
+		buf[len(buf)-1] |= 1
+
+		p := new(big.Int).SetBytes(buf)
+		pminus1 := new(big.Int)
+		gcd := new(big.Int)
+		for p.BitLen() == bits {
+			if p.ProbablyPrime(20) {
+				pminus1.Sub(p, one)
+				if gcd.GCD(nil, nil, pminus1, rsaPublicExponent).Cmp(one) == 0 {
+					return p, nil
+				}
+			}
+			p.Add(p, two)
+		}
+	}
+}
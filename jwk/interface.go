@@ -2,9 +2,11 @@ package jwk
 
 import (
 	"context"
+	"crypto"
 	"crypto/x509"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/lestrrat-go/iter/arrayiter"
 	"github.com/lestrrat-go/iter/mapiter"
@@ -50,7 +52,8 @@ const (
 // consider using `jwk.Parse()` to always get a `jwk.Set` out of it.
 type Set interface {
 	// Add adds the specified key. If the key already exists in the set, it is
-	// not added.
+	// not added. If the set was created with `jwk.WithAutoKeyID()`, a "kid"
+	// is assigned to the key if it does not have one.
 	Add(Key) bool
 
 	// Clear resets the list of keys associated with this set, emptying the
@@ -73,20 +76,47 @@ type Set interface {
 	// need all of them, use `Iterate()`
 	LookupKeyID(string) (Key, bool)
 
-	// Remove removes the key from the set.
+	// Remove removes the key from the set. The order of the remaining
+	// keys is preserved. Returns false if the key was not in the set.
 	Remove(Key) bool
 
+	// RemoveByKeyID removes all keys matching the given key id from the set,
+	// and returns the number of keys that were removed. The order of the
+	// remaining keys is preserved.
+	RemoveByKeyID(string) int
+
+	// Replace replaces the key at index `idx` with the given key, keeping
+	// its position in the set. Returns false if the index is out of range,
+	// or if the key already exists at another position in the set.
+	Replace(int, Key) bool
+
 	// Iterate creates an iterator to iterate through all keys in the set.
 	Iterate(context.Context) KeyIterator
 
 	// Clone create a new set with identical keys. Keys themselves are not cloned.
 	Clone() (Set, error)
+
+	// Fingerprint computes a digest of the keys in the set, which can be
+	// used to detect unexpected changes to a JWKS, e.g. by pinning it in
+	// configuration. The digest is computed over the sorted RFC 7638
+	// thumbprints of the keys, so it does not depend on the order of the
+	// keys, nor on whether the set contains private or public keys.
+	// Parameters that are not part of the thumbprint, such as "kid" and
+	// "alg", are not covered.
+	Fingerprint(crypto.Hash) ([]byte, error)
+
+	// ActiveKeys creates a new set containing the keys that may be used
+	// at the given time, according to the non-standard "nbf", "exp", and
+	// "revoked" members that some identity providers add to their keys.
+	// See `jwk.IsKeyActive()` for details. Keys themselves are not cloned.
+	ActiveKeys(time.Time) Set
 }
 
 type set struct {
-	keys []Key
-	mu   sync.RWMutex
-	dc   DecodeCtx
+	keys      []Key
+	mu        sync.RWMutex
+	dc        DecodeCtx
+	autoKeyID KeyIDStrategy
 }
 
 type HeaderVisitor = iter.MapVisitor
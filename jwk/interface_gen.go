@@ -82,6 +82,28 @@ type Key interface {
 	// Clone creates a new instance of the same type
 	Clone() (Key, error)
 
+	// Zeroize overwrites the private key material held by the key
+	// (e.g. "d", "p" and "q", or the octets of a symmetric key) with
+	// zeros, and removes it from the key. Afterwards, `Raw()` returns an
+	// error for private and symmetric keys. Public keys are not affected.
+	//
+	// Byte slices that were passed to or obtained from the key (e.g. via
+	// `Get()`, or `FromRaw()` and `Raw()` on a symmetric key) may share
+	// storage with the key, and are zeroed as well. Copies made elsewhere,
+	// such as raw RSA or ECDSA keys, or keys created via `Clone()`, are
+	// not affected.
+	Zeroize()
+
+	// Materialize creates the raw key ahead of time, so that it does not
+	// have to be created on the first call to `Raw()`. It returns an error
+	// if the key cannot be converted to a raw key.
+	//
+	// The raw keys of public keys are cached by the key until a parameter
+	// that affects the raw key is changed, and `Raw()` returns a copy of
+	// the cached key. Private and symmetric keys are not cached, so that
+	// each raw key can be zeroized independently.
+	Materialize() error
+
 	KeyType() jwa.KeyType
 	KeyUsage() string
 	KeyOps() KeyOperationList
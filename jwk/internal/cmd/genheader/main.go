@@ -55,6 +55,13 @@ type headerField struct {
 	optional   bool
 }
 
+// affectsRaw returns true if the field is used to create the raw key.
+// Besides the key material, this includes "alg", which determines the
+// parameters of some key types (e.g. AKP)
+func (f headerField) affectsRaw() bool {
+	return !f.isStd || f.name == `algorithm`
+}
+
 func (f headerField) IsList() bool {
 	return f.isList || strings.HasPrefix(f.typ, "[]")
 }
@@ -185,6 +192,7 @@ type keyType struct {
 	prefix      string
 	headerTypes []headerType
 	keyType     string
+	buildTags   string
 }
 
 type headerType struct {
@@ -194,6 +202,7 @@ type headerType struct {
 	name       string
 	structName string
 	ifName     string
+	cacheRaw   bool // cache the raw key (public keys only)
 }
 
 var keyTypes = []keyType{
@@ -204,6 +213,7 @@ var keyTypes = []keyType{
 		headerTypes: []headerType{
 			{
 				name:       `PublicKey`,
+				cacheRaw:   true,
 				rawKeyType: `*rsa.PublicKey`,
 				headers: []headerField{
 					{
@@ -286,6 +296,7 @@ var keyTypes = []keyType{
 		headerTypes: []headerType{
 			{
 				name:       `PublicKey`,
+				cacheRaw:   true,
 				rawKeyType: `*ecdsa.PublicKey`,
 				headers: []headerField{
 					{
@@ -368,6 +379,7 @@ var keyTypes = []keyType{
 		headerTypes: []headerType{
 			{
 				name:       "PublicKey",
+				cacheRaw:   true,
 				rawKeyType: `interface{}`,
 				headers: []headerField{
 					{
@@ -410,6 +422,45 @@ var keyTypes = []keyType{
 			},
 		},
 	},
+	{
+		filename:  `akp_gen.go`,
+		prefix:    `AKP`,
+		keyType:   `jwa.AKP`,
+		buildTags: `jwx_mldsa,go1.27`,
+		headerTypes: []headerType{
+			{
+				name:       "PublicKey",
+				cacheRaw:   true,
+				rawKeyType: `interface{}`,
+				headers: []headerField{
+					{
+						name:   `pub`,
+						method: `Pub`,
+						typ:    `[]byte`,
+						key:    `pub`,
+					},
+				},
+			},
+			{
+				name:       "PrivateKey",
+				rawKeyType: `interface{}`,
+				headers: []headerField{
+					{
+						name:   `pub`,
+						method: `Pub`,
+						typ:    `[]byte`,
+						key:    `pub`,
+					},
+					{
+						name:   `priv`,
+						method: `Priv`,
+						typ:    `[]byte`,
+						key:    `priv`,
+					},
+				},
+			},
+		},
+	},
 }
 
 func generateGenericHeaders() error {
@@ -483,6 +534,24 @@ func generateGenericHeaders() error {
 	fmt.Fprintf(&buf, "\nPrivateParams() map[string]interface{}")
 	fmt.Fprintf(&buf, "\n\n// Clone creates a new instance of the same type")
 	fmt.Fprintf(&buf, "\nClone() (Key, error)")
+	fmt.Fprintf(&buf, "\n\n// Zeroize overwrites the private key material held by the key")
+	fmt.Fprintf(&buf, "\n// (e.g. \"d\", \"p\" and \"q\", or the octets of a symmetric key) with")
+	fmt.Fprintf(&buf, "\n// zeros, and removes it from the key. Afterwards, `Raw()` returns an")
+	fmt.Fprintf(&buf, "\n// error for private and symmetric keys. Public keys are not affected.")
+	fmt.Fprintf(&buf, "\n//\n// Byte slices that were passed to or obtained from the key (e.g. via")
+	fmt.Fprintf(&buf, "\n// `Get()`, or `FromRaw()` and `Raw()` on a symmetric key) may share")
+	fmt.Fprintf(&buf, "\n// storage with the key, and are zeroed as well. Copies made elsewhere,")
+	fmt.Fprintf(&buf, "\n// such as raw RSA or ECDSA keys, or keys created via `Clone()`, are")
+	fmt.Fprintf(&buf, "\n// not affected.")
+	fmt.Fprintf(&buf, "\nZeroize()")
+	fmt.Fprintf(&buf, "\n\n// Materialize creates the raw key ahead of time, so that it does not")
+	fmt.Fprintf(&buf, "\n// have to be created on the first call to `Raw()`. It returns an error")
+	fmt.Fprintf(&buf, "\n// if the key cannot be converted to a raw key.")
+	fmt.Fprintf(&buf, "\n//\n// The raw keys of public keys are cached by the key until a parameter")
+	fmt.Fprintf(&buf, "\n// that affects the raw key is changed, and `Raw()` returns a copy of")
+	fmt.Fprintf(&buf, "\n// the cached key. Private and symmetric keys are not cached, so that")
+	fmt.Fprintf(&buf, "\n// each raw key can be zeroized independently.")
+	fmt.Fprintf(&buf, "\nMaterialize() error")
 	fmt.Fprintf(&buf, "\n\nKeyType() jwa.KeyType")
 	for _, f := range standardHeaders {
 		fmt.Fprintf(&buf, "\n%s() ", f.method)
@@ -521,6 +590,9 @@ func generateHeader(kt keyType) error {
 
 	var buf bytes.Buffer
 
+	if kt.buildTags != "" {
+		fmt.Fprintf(&buf, "// +build %s\n", kt.buildTags)
+	}
 	fmt.Fprintf(&buf, "\n// This file is auto-generated. DO NOT EDIT")
 	fmt.Fprintf(&buf, "\n\npackage jwk")
 
@@ -605,6 +677,9 @@ func generateHeader(kt keyType) error {
 			}
 		}
 		fmt.Fprintf(&buf, "\nprivateParams map[string]interface{}")
+		if ht.cacheRaw {
+			fmt.Fprintf(&buf, "\nraw *rawKeyCache")
+		}
 		fmt.Fprintf(&buf, "\nmu *sync.RWMutex")
 		fmt.Fprintf(&buf, "\ndc DecodeCtx")
 		fmt.Fprintf(&buf, "\n}")
@@ -616,6 +691,9 @@ func generateHeader(kt keyType) error {
 		fmt.Fprintf(&buf, "\nreturn &%s{", structName)
 		fmt.Fprintf(&buf, "\nmu: &sync.RWMutex{},")
 		fmt.Fprintf(&buf, "\nprivateParams: make(map[string]interface{}),")
+		if ht.cacheRaw {
+			fmt.Fprintf(&buf, "\nraw: &rawKeyCache{},")
+		}
 		fmt.Fprintf(&buf, "\n}")
 		fmt.Fprintf(&buf, "\n}")
 
@@ -732,6 +810,9 @@ func generateHeader(kt keyType) error {
 				keyName = kt.prefix + f.method + "Key"
 			}
 			fmt.Fprintf(&buf, "\ncase %s:", keyName)
+			if ht.cacheRaw && f.affectsRaw() {
+				fmt.Fprintf(&buf, "\nh.raw.invalidate()")
+			}
 			if f.name == `algorithm` {
 				fmt.Fprintf(&buf, "\nswitch v := value.(type) {")
 				fmt.Fprintf(&buf, "\ncase string:")
@@ -773,6 +854,12 @@ func generateHeader(kt keyType) error {
 				fmt.Fprintf(&buf, "\nif v, ok := value.(%s); ok {", f.typ)
 				if fieldStorageTypeIsIndirect(f.typ) {
 					fmt.Fprintf(&buf, "\nh.%s = &v", f.name)
+				} else if f.typ == `[]byte` {
+					// key material is copied, so that changes made by the
+					// caller to the slice afterwards do not affect the key
+					// (or the raw key cached from it)
+					fmt.Fprintf(&buf, "\nh.%s = make([]byte, len(v))", f.name)
+					fmt.Fprintf(&buf, "\ncopy(h.%s, v)", f.name)
 				} else {
 					fmt.Fprintf(&buf, "\nh.%s = v", f.name)
 				}
@@ -802,6 +889,9 @@ func generateHeader(kt keyType) error {
 				keyName = kt.prefix + f.method + "Key"
 			}
 			fmt.Fprintf(&buf, "\ncase %s:", keyName)
+			if ht.cacheRaw && f.affectsRaw() {
+				fmt.Fprintf(&buf, "\nk.raw.invalidate()")
+			}
 			fmt.Fprintf(&buf, "\nk.%s = nil", f.name)
 		}
 		fmt.Fprintf(&buf, "\ndefault:")
@@ -827,6 +917,9 @@ func generateHeader(kt keyType) error {
 		fmt.Fprintf(&buf, "\n}")
 
 		fmt.Fprintf(&buf, "\n\nfunc (h *%s) UnmarshalJSON(buf []byte) error {", structName)
+		if ht.cacheRaw {
+			fmt.Fprintf(&buf, "\nh.raw.invalidate()")
+		}
 		for _, f := range ht.allHeaders {
 			fmt.Fprintf(&buf, "\nh.%s = nil", f.name)
 		}
@@ -868,7 +961,7 @@ func generateHeader(kt keyType) error {
 			} else if f.typ == "[]byte" {
 				name := f.method
 				switch f.name {
-				case "n", "e", "d", "p", "dp", "dq", "x", "y", "q", "qi", "octets":
+				case "n", "e", "d", "p", "dp", "dq", "x", "y", "q", "qi", "octets", "pub", "priv":
 					name = kt.prefix + f.method
 				}
 				fmt.Fprintf(&buf, "\ncase %sKey:", name)
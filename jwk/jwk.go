@@ -18,8 +18,9 @@ import (
 	"net/http"
 
 	"github.com/lestrrat-go/backoff/v2"
-	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/hook"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/x25519"
 	"github.com/pkg/errors"
@@ -27,6 +28,23 @@ import (
 
 var registry = json.NewRegistry()
 
+// keyTypeExtension describes a key type that is only available when
+// jwx is compiled with certain build tags (e.g. jwx_mldsa)
+type keyTypeExtension struct {
+	// fromRaw creates a jwk.Key from a raw key. The boolean return
+	// value is false if the raw key is not handled by this extension
+	fromRaw func(interface{}) (Key, bool, error)
+	// publicRawKeyOf returns the raw public key of a raw key. The boolean
+	// return value is false if the raw key is not handled by this extension
+	publicRawKeyOf func(interface{}) (interface{}, bool)
+	// newKey creates an empty jwk.Key to unmarshal JSON into
+	newKey func(private bool) Key
+	// isPrivate reports if the JSON representation is that of a private key
+	isPrivate func([]byte) bool
+}
+
+var keyTypeExtensions = make(map[jwa.KeyType]*keyTypeExtension)
+
 func bigIntToBytes(n *big.Int) ([]byte, error) {
 	if n == nil {
 		return nil, errors.New(`invalid *big.Int value`)
@@ -118,6 +136,16 @@ func New(key interface{}) (Key, error) {
 		}
 		return k, nil
 	default:
+		for _, ext := range keyTypeExtensions {
+			k, ok, err := ext.fromRaw(rawKey)
+			if !ok {
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to initialize jwk.Key from %T`, rawKey)
+			}
+			return k, nil
+		}
 		return nil, errors.Errorf(`invalid key type '%T' for jwk.New`, key)
 	}
 }
@@ -204,6 +232,11 @@ func PublicRawKeyOf(v interface{}) (interface{}, error) {
 	case []byte:
 		return x, nil
 	default:
+		for _, ext := range keyTypeExtensions {
+			if pubkey, ok := ext.publicRawKeyOf(x); ok {
+				return pubkey, nil
+			}
+		}
 		return nil, errors.Errorf(`invalid key type passed to PublicKeyOf (%T)`, v)
 	}
 }
@@ -217,13 +250,33 @@ func PublicRawKeyOf(v interface{}) (interface{}, error) {
 // consider using `jwk.AutoRefresh`, which automatically refreshes
 // jwk.Set objects asynchronously.
 func Fetch(ctx context.Context, urlstring string, options ...FetchOption) (Set, error) {
+	span := hook.Start(hook.FetchKind)
+	span.SetURL(urlstring)
+	keyset, err := fetchSet(ctx, urlstring, options...)
+	span.End(err)
+	return keyset, err
+}
+
+func fetchSet(ctx context.Context, urlstring string, options ...FetchOption) (Set, error) {
 	res, err := fetch(ctx, urlstring, options...)
 	if err != nil {
 		return nil, err
 	}
 
+	var verifier SignatureVerifier
+	var parser KeySetParser
+	for _, option := range options {
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identSignatureVerifier{}:
+			verifier = option.Value().(SignatureVerifier)
+		case identKeySetParser{}:
+			parser = option.Value().(KeySetParser)
+		}
+	}
+
 	defer res.Body.Close()
-	keyset, err := ParseReader(res.Body)
+	keyset, err := parseFetched(res.Body, verifier, parser)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to parse JWK set`)
 	}
@@ -232,10 +285,13 @@ func Fetch(ctx context.Context, urlstring string, options ...FetchOption) (Set,
 
 func fetch(ctx context.Context, urlstring string, options ...FetchOption) (*http.Response, error) {
 	var httpcl HTTPClient = http.DefaultClient
+	var cc *ClientCredentials
 	bo := backoff.Null()
 	for _, option := range options {
 		//nolint:forcetypeassert
 		switch option.Ident() {
+		case identClientCredentials{}:
+			cc = option.Value().(*ClientCredentials)
 		case identHTTPClient{}:
 			httpcl = option.Value().(HTTPClient)
 		case identFetchBackoff{}:
@@ -251,7 +307,13 @@ func fetch(ctx context.Context, urlstring string, options ...FetchOption) (*http
 	b := bo.Start(ctx)
 	var lastError error
 	for backoff.Continue(b) {
-		res, err := httpcl.Do(req)
+		var res *http.Response
+		var err error
+		if cc != nil {
+			res, err = cc.doAuthenticated(ctx, httpcl, req)
+		} else {
+			res, err = httpcl.Do(req)
+		}
 		if err != nil {
 			lastError = errors.Wrap(err, "failed to fetch remote JWK")
 			continue
@@ -356,12 +418,15 @@ func parsePEMEncodedRawKey(src []byte) (interface{}, []byte, error) {
 // parameters are performed, etc.
 func ParseKey(data []byte, options ...ParseOption) (Key, error) {
 	var parsePEM bool
+	var strictAlgorithm bool
 	var localReg *json.Registry
 	for _, option := range options {
 		//nolint:forcetypeassert
 		switch option.Ident() {
 		case identPEM{}:
 			parsePEM = option.Value().(bool)
+		case identStrictAlgorithm{}:
+			strictAlgorithm = option.Value().(bool)
 		case identLocalRegistry{}:
 			// in reality you can only pass either withLocalRegistry or
 			// WithTypedField, but since withLocalRegistry is used only by us,
@@ -376,21 +441,29 @@ func ParseKey(data []byte, options ...ParseOption) (Key, error) {
 		}
 	}
 
+	if err := limits.CheckInputSize(len(data)); err != nil {
+		return nil, newParseError(err)
+	}
+
 	if parsePEM {
 		raw, _, err := parsePEMEncodedRawKey(data)
 		if err != nil {
-			return nil, errors.Wrap(err, `failed to parse PEM encoded key`)
+			return nil, newParseError(errors.Wrap(err, `failed to parse PEM encoded key`))
 		}
 		return New(raw)
 	}
 
+	if err := limits.CheckDepth(data); err != nil {
+		return nil, newParseError(err)
+	}
+
 	var hint struct {
 		Kty string          `json:"kty"`
 		D   json.RawMessage `json:"d"`
 	}
 
 	if err := json.Unmarshal(data, &hint); err != nil {
-		return nil, errors.Wrap(err, `failed to unmarshal JSON into key hint`)
+		return nil, newParseError(errors.Wrap(err, `failed to unmarshal JSON into key hint`))
 	}
 
 	var key Key
@@ -416,7 +489,11 @@ func ParseKey(data []byte, options ...ParseOption) (Key, error) {
 			key = newOKPPublicKey()
 		}
 	default:
-		return nil, errors.Errorf(`invalid key type from JSON (%s)`, hint.Kty)
+		ext, ok := keyTypeExtensions[jwa.KeyType(hint.Kty)]
+		if !ok {
+			return nil, newParseError(errors.Errorf(`invalid key type from JSON (%s)`, hint.Kty))
+		}
+		key = ext.newKey(ext.isPrivate(data))
 	}
 
 	if localReg != nil {
@@ -430,7 +507,13 @@ func ParseKey(data []byte, options ...ParseOption) (Key, error) {
 	}
 
 	if err := json.Unmarshal(data, key); err != nil {
-		return nil, errors.Wrapf(err, `failed to unmarshal JSON into key (%T)`, key)
+		return nil, newParseError(errors.Wrapf(err, `failed to unmarshal JSON into key (%T)`, key))
+	}
+
+	if strictAlgorithm {
+		if err := CheckAlgorithm(key); err != nil {
+			return nil, newParseError(errors.Wrap(err, `invalid "alg"`))
+		}
 	}
 
 	return key, nil
@@ -452,12 +535,15 @@ func ParseKey(data []byte, options ...ParseOption) (Key, error) {
 // for `jwk.ParseKey()`.
 func Parse(src []byte, options ...ParseOption) (Set, error) {
 	var parsePEM bool
+	var strictAlgorithm bool
 	var localReg *json.Registry
 	for _, option := range options {
 		//nolint:forcetypeassert
 		switch option.Ident() {
 		case identPEM{}:
 			parsePEM = option.Value().(bool)
+		case identStrictAlgorithm{}:
+			strictAlgorithm = option.Value().(bool)
 		case identTypedField{}:
 			pair := option.Value().(typedFieldPair)
 			if localReg == nil {
@@ -467,6 +553,10 @@ func Parse(src []byte, options ...ParseOption) (Set, error) {
 		}
 	}
 
+	if err := limits.CheckInputSize(len(src)); err != nil {
+		return nil, newParseError(err)
+	}
+
 	s := NewSet()
 
 	if parsePEM {
@@ -474,7 +564,7 @@ func Parse(src []byte, options ...ParseOption) (Set, error) {
 		for len(src) > 0 {
 			raw, rest, err := parsePEMEncodedRawKey(src)
 			if err != nil {
-				return nil, errors.Wrap(err, `failed to parse PEM encoded key`)
+				return nil, newParseError(errors.Wrap(err, `failed to parse PEM encoded key`))
 			}
 			key, err := New(raw)
 			if err != nil {
@@ -496,8 +586,21 @@ func Parse(src []byte, options ...ParseOption) (Set, error) {
 		defer func() { dcKs.SetDecodeCtx(nil) }()
 	}
 
+	if err := limits.CheckDepth(src); err != nil {
+		return nil, newParseError(err)
+	}
+
 	if err := json.Unmarshal(src, s); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal JWK set")
+		return nil, newParseError(errors.Wrap(err, "failed to unmarshal JWK set"))
+	}
+
+	if strictAlgorithm {
+		for i := 0; i < s.Len(); i++ {
+			key, _ := s.Get(i)
+			if err := CheckAlgorithm(key); err != nil {
+				return nil, newParseError(errors.Wrapf(err, `invalid "alg" in key #%d`, i))
+			}
+		}
 	}
 	return s, nil
 }
@@ -506,6 +609,11 @@ func Parse(src []byte, options ...ParseOption) (Set, error) {
 func ParseReader(src io.Reader, options ...ParseOption) (Set, error) {
 	// meh, there's no way to tell if a stream has "ended" a single
 	// JWKs except when we encounter an EOF, so just... ReadAll
+	if n := limits.MaxInputSize(); n > 0 {
+		// Read one extra byte so that Parse can detect that the
+		// input was too large
+		src = io.LimitReader(src, n+1)
+	}
 	buf, err := ioutil.ReadAll(src)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to read from io.Reader`)
@@ -520,28 +628,39 @@ func ParseString(s string, options ...ParseOption) (Set, error) {
 }
 
 // AssignKeyID is a convenience function to automatically assign the "kid"
-// section of the key, if it already doesn't have one. It uses Key.Thumbprint
-// method with crypto.SHA256 as the default hashing algorithm
+// section of the key, if it already doesn't have one.
+//
+// By default it uses Key.Thumbprint method with crypto.SHA256 as the
+// hashing algorithm (`jwk.KidStrategySHA256Thumbprint`). Use
+// `jwk.WithThumbprintHash()` to change the hashing algorithm, or
+// `jwk.WithKeyIDStrategy()` to use a different strategy, such as
+// `jwk.KidStrategyUUID`.
 func AssignKeyID(key Key, options ...Option) error {
 	if _, ok := key.Get(KeyIDKey); ok {
 		return nil
 	}
 
-	hash := crypto.SHA256
+	strategy := KidStrategySHA256Thumbprint
 	for _, option := range options {
 		//nolint:forcetypeassert
 		switch option.Ident() {
 		case identThumbprintHash{}:
-			hash = option.Value().(crypto.Hash)
+			strategy = ThumbprintKeyID(option.Value().(crypto.Hash))
+		case identKeyIDStrategy{}:
+			strategy = option.Value().(KeyIDStrategy)
 		}
 	}
 
-	h, err := key.Thumbprint(hash)
+	return assignKeyID(key, strategy)
+}
+
+func assignKeyID(key Key, strategy KeyIDStrategy) error {
+	kid, err := strategy.KeyID(key)
 	if err != nil {
-		return errors.Wrap(err, `failed to generate thumbprint`)
+		return errors.Wrap(err, `failed to compute "kid"`)
 	}
 
-	if err := key.Set(KeyIDKey, base64.EncodeToString(h)); err != nil {
+	if err := key.Set(KeyIDKey, kid); err != nil {
 		return errors.Wrap(err, `failed to set "kid"`)
 	}
 
@@ -566,7 +685,15 @@ func cloneKey(src Key) (Key, error) {
 	case SymmetricKey:
 		dst = NewSymmetricKey()
 	default:
-		return nil, errors.Errorf(`unknown key type %T`, src)
+		ext, ok := keyTypeExtensions[src.KeyType()]
+		if !ok {
+			return nil, errors.Errorf(`unknown key type %T`, src)
+		}
+		buf, err := json.Marshal(src)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to marshal key (%T)`, src)
+		}
+		dst = ext.newKey(ext.isPrivate(buf))
 	}
 
 	ctx := context.Background()
@@ -6,6 +6,8 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
+	stdbase64 "encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"reflect"
@@ -14,6 +16,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/lestrrat-go/jwx"
 	"github.com/lestrrat-go/jwx/internal/ecutil"
 	"github.com/lestrrat-go/jwx/internal/jose"
 	"github.com/lestrrat-go/jwx/internal/json"
@@ -23,6 +26,7 @@ import (
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwk/jwktest"
 	"github.com/lestrrat-go/jwx/x25519"
 	"github.com/stretchr/testify/assert"
 )
@@ -1337,6 +1341,203 @@ func TestOKP(t *testing.T) {
 	})
 }
 
+func TestOKPSeed(t *testing.T) {
+	t.Parallel()
+
+	// RFC8032 section 7.1, test 1
+	const ed25519Seed = `9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60`
+	const ed25519Public = `d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a`
+	// RFC7748 section 6.1
+	const x25519Scalar = `77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a`
+	const x25519Public = `8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a`
+
+	mustHex := func(s string) []byte {
+		buf, err := hex.DecodeString(s)
+		if err != nil {
+			panic(err)
+		}
+		return buf
+	}
+
+	t.Run("Encodings", func(t *testing.T) {
+		t.Parallel()
+
+		seed := mustHex(ed25519Seed)
+		expanded := append(append([]byte(nil), seed...), mustHex(ed25519Public)...)
+		testcases := []struct {
+			Name  string
+			Input []byte
+		}{
+			{Name: "raw", Input: seed},
+			{Name: "raw expanded", Input: expanded},
+			{Name: "hex", Input: []byte(ed25519Seed + "\n")},
+			{Name: "hex expanded", Input: []byte(ed25519Seed + ed25519Public)},
+			{Name: "base64", Input: []byte(stdbase64.StdEncoding.EncodeToString(seed))},
+			{Name: "base64 expanded", Input: []byte(stdbase64.StdEncoding.EncodeToString(expanded))},
+			{Name: "base64url", Input: []byte(base64.EncodeToString(seed))},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				key, err := jwk.ParseOKPSeed(jwa.Ed25519, tc.Input)
+				if !assert.NoError(t, err, `jwk.ParseOKPSeed should succeed`) {
+					return
+				}
+				okp, ok := key.(jwk.OKPPrivateKey)
+				if !assert.True(t, ok, `key should be a jwk.OKPPrivateKey`) {
+					return
+				}
+				if !assert.Equal(t, jwa.Ed25519, okp.Crv(), `crv should match`) {
+					return
+				}
+				if !assert.Equal(t, mustHex(ed25519Public), okp.X(), `public key should match`) {
+					return
+				}
+
+				exported, err := jwk.OKPSeed(key)
+				if !assert.NoError(t, err, `jwk.OKPSeed should succeed`) {
+					return
+				}
+				if !assert.Equal(t, seed, exported, `exported seed should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("X25519", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwk.ParseOKPSeed(jwa.X25519, []byte(x25519Scalar))
+		if !assert.NoError(t, err, `jwk.ParseOKPSeed should succeed`) {
+			return
+		}
+		//nolint:forcetypeassert
+		if !assert.Equal(t, mustHex(x25519Public), key.(jwk.OKPPrivateKey).X(), `public key should match`) {
+			return
+		}
+	})
+	t.Run("Invalid input", func(t *testing.T) {
+		t.Parallel()
+		expanded := append(mustHex(ed25519Seed), make([]byte, 32)...)
+		for _, input := range [][]byte{
+			[]byte("short"),
+			mustHex(ed25519Seed)[:31],
+			expanded, // public key does not match
+			[]byte(strings.Repeat("zz", 32)),
+		} {
+			_, err := jwk.ParseOKPSeed(jwa.Ed25519, input)
+			if !assert.Error(t, err, `jwk.ParseOKPSeed should fail`) {
+				return
+			}
+		}
+
+		_, err := jwk.FromOKPSeed(jwa.P256, mustHex(ed25519Seed))
+		if !assert.Error(t, err, `jwk.FromOKPSeed with non-OKP curve should fail`) {
+			return
+		}
+
+		_, err = jwk.ParseOKPSeed(jwa.X25519, expanded)
+		if !assert.Error(t, err, `jwk.ParseOKPSeed with 64 byte X25519 key should fail`) {
+			return
+		}
+
+		pubkey, err := jwk.New(ed25519.PublicKey(mustHex(ed25519Public)))
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		_, err = jwk.OKPSeed(pubkey)
+		if !assert.Error(t, err, `jwk.OKPSeed with public key should fail`) {
+			return
+		}
+	})
+	t.Run("ConvertEd25519ToX25519", func(t *testing.T) {
+		t.Parallel()
+
+		// test vector from libsodium (test/default/ed25519_convert.c)
+		key, err := jwk.FromOKPSeed(jwa.Ed25519, mustHex(`421151a459faeade3d247115f94aedae42318124095afabe4d1451a559faedee`))
+		if !assert.NoError(t, err, `jwk.FromOKPSeed should succeed`) {
+			return
+		}
+
+		converted, err := jwk.ConvertEd25519ToX25519(key)
+		if !assert.NoError(t, err, `jwk.ConvertEd25519ToX25519 should succeed`) {
+			return
+		}
+		okp := converted.(jwk.OKPPrivateKey) //nolint:forcetypeassert
+		if !assert.Equal(t, jwa.X25519, okp.Crv(), `crv should be X25519`) {
+			return
+		}
+		if !assert.Equal(t, mustHex(`8052030376d47112be7f73ed7a019293dd12ad910b654455798b4667d73de166`), okp.D(), `scalar should match`) {
+			return
+		}
+		if !assert.Equal(t, mustHex(`f1814f0e8ff1043d8a44d25babff3cedcae6c22c3edaa48f857ae70de2baae50`), okp.X(), `public key should match`) {
+			return
+		}
+
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+		convertedPub, err := jwk.ConvertEd25519ToX25519(pubkey)
+		if !assert.NoError(t, err, `jwk.ConvertEd25519ToX25519 should succeed`) {
+			return
+		}
+		//nolint:forcetypeassert
+		if !assert.Equal(t, okp.X(), convertedPub.(jwk.OKPPublicKey).X(), `converted public key should match public key of converted private key`) {
+			return
+		}
+
+		xkey, err := jwk.ParseOKPSeed(jwa.X25519, []byte(x25519Scalar))
+		if !assert.NoError(t, err, `jwk.ParseOKPSeed should succeed`) {
+			return
+		}
+		_, err = jwk.ConvertEd25519ToX25519(xkey)
+		if !assert.Error(t, err, `jwk.ConvertEd25519ToX25519 with X25519 key should fail`) {
+			return
+		}
+	})
+	t.Run("DeriveX25519Key", func(t *testing.T) {
+		t.Parallel()
+
+		key, err := jwk.FromOKPSeed(jwa.Ed25519, mustHex(`421151a459faeade3d247115f94aedae42318124095afabe4d1451a559faedee`))
+		if !assert.NoError(t, err, `jwk.FromOKPSeed should succeed`) {
+			return
+		}
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+
+		derived, err := jwk.DeriveX25519Key(key)
+		if !assert.NoError(t, err, `jwk.DeriveX25519Key should succeed`) {
+			return
+		}
+		derivedPub, err := jwk.DeriveX25519Key(pubkey)
+		if !assert.NoError(t, err, `jwk.DeriveX25519Key should succeed`) {
+			return
+		}
+
+		if !assert.Equal(t, jwk.ForEncryption.String(), derived.KeyUsage(), `"use" should be "enc"`) {
+			return
+		}
+		if !assert.NotEmpty(t, derived.KeyID(), `"kid" should be set`) {
+			return
+		}
+		if !assert.Equal(t, derived.KeyID(), derivedPub.KeyID(), `"kid" of private and public keys should match`) {
+			return
+		}
+		//nolint:forcetypeassert
+		if !assert.Equal(t, mustHex(`f1814f0e8ff1043d8a44d25babff3cedcae6c22c3edaa48f857ae70de2baae50`), derivedPub.(jwk.OKPPublicKey).X(), `public key should match`) {
+			return
+		}
+
+		_, err = jwk.DeriveX25519Key(derived)
+		if !assert.Error(t, err, `jwk.DeriveX25519Key with X25519 key should fail`) {
+			return
+		}
+	})
+}
+
 func TestCustomField(t *testing.T) {
 	// XXX has global effect!!!
 	jwk.RegisterCustomField(`x-birthday`, time.Time{})
@@ -1638,3 +1839,669 @@ func TestGH412(t *testing.T) {
 		})
 	}
 }
+
+// Some legacy producers emit key parameters using the standard base64
+// alphabet and/or padding instead of base64url. These are only accepted
+// when lenient decoding is enabled.
+func TestLenientBase64(t *testing.T) {
+	// not parallel, as this changes global settings
+	raw := []byte{0xfb, 0xff, 0xbf, 0x00, 0xfb, 0xff, 0xbf}
+	encodings := []*stdbase64.Encoding{stdbase64.StdEncoding, stdbase64.RawStdEncoding, stdbase64.URLEncoding}
+	for _, encoding := range encodings {
+		src := `{"kty":"oct","k":"` + encoding.EncodeToString(raw) + `"}`
+		if _, err := jwk.ParseKey([]byte(src)); !assert.Error(t, err, `jwk.ParseKey should fail (%s)`, src) {
+			return
+		}
+	}
+
+	jwx.Settings(jwx.WithLenientBase64(true))
+	defer jwx.Settings(jwx.WithLenientBase64(false))
+	for _, encoding := range encodings {
+		src := `{"kty":"oct","k":"` + encoding.EncodeToString(raw) + `"}`
+		key, err := jwk.ParseKey([]byte(src))
+		if !assert.NoError(t, err, `jwk.ParseKey should succeed (%s)`, src) {
+			return
+		}
+
+		var octets []byte
+		if !assert.NoError(t, key.Raw(&octets), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, raw, octets, `key should match`) {
+			return
+		}
+	}
+}
+
+func TestAssignAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	newKey := func(t *testing.T, raw interface{}, use jwk.KeyUsageType) jwk.Key {
+		t.Helper()
+		key, err := jwk.New(raw)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			t.FailNow()
+		}
+		if use != "" {
+			_ = key.Set(jwk.KeyUsageKey, use)
+		}
+		return key
+	}
+
+	rsaKey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	p256, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	p384, err := jwxtest.GenerateEcdsaKey(jwa.P384)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	edKey, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+		return
+	}
+	xKey, err := jwxtest.GenerateX25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateX25519Key should succeed`) {
+		return
+	}
+
+	t.Run("Infer", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name     string
+			Raw      interface{}
+			Use      jwk.KeyUsageType
+			Expected string
+			Error    bool
+		}{
+			{Name: "RSA", Raw: rsaKey, Expected: `RS256`},
+			{Name: "RSA (enc)", Raw: rsaKey, Use: jwk.ForEncryption, Expected: `RSA-OAEP-256`},
+			{Name: "P-256", Raw: p256, Expected: `ES256`},
+			{Name: "P-384", Raw: &p384.PublicKey, Expected: `ES384`},
+			{Name: "P-256 (enc)", Raw: p256, Use: jwk.ForEncryption, Expected: `ECDH-ES`},
+			{Name: "Ed25519", Raw: edKey, Expected: `EdDSA`},
+			{Name: "Ed25519 (enc)", Raw: edKey, Use: jwk.ForEncryption, Error: true},
+			{Name: "X25519", Raw: xKey, Expected: `ECDH-ES`},
+			{Name: "X25519 (sig)", Raw: xKey, Use: jwk.ForSignature, Error: true},
+			{Name: "oct", Raw: make([]byte, 32), Expected: `HS256`},
+			{Name: "oct (short)", Raw: make([]byte, 16), Error: true},
+			{Name: "oct (enc)", Raw: make([]byte, 24), Use: jwk.ForEncryption, Expected: `A192KW`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				key := newKey(t, tc.Raw, tc.Use)
+				err := jwk.AssignAlgorithm(key)
+				if tc.Error {
+					if !assert.Error(t, err, `jwk.AssignAlgorithm should fail`) {
+						return
+					}
+					if !assert.Empty(t, key.Algorithm(), `"alg" should not be set`) {
+						return
+					}
+					return
+				}
+				if !assert.NoError(t, err, `jwk.AssignAlgorithm should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Expected, key.Algorithm(), `"alg" should match`) {
+					return
+				}
+				if !assert.NoError(t, jwk.CheckAlgorithm(key), `inferred "alg" should pass jwk.CheckAlgorithm`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Check", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name  string
+			Raw   interface{}
+			Use   jwk.KeyUsageType
+			Alg   string
+			Error bool
+		}{
+			{Name: "RSA/PS512", Raw: rsaKey, Alg: `PS512`},
+			{Name: "RSA/ES256", Raw: rsaKey, Alg: `ES256`, Error: true},
+			{Name: "RSA/RSA-OAEP (sig)", Raw: rsaKey, Use: jwk.ForSignature, Alg: `RSA-OAEP`, Error: true},
+			{Name: "P-384/ES256", Raw: p384, Alg: `ES256`, Error: true},
+			{Name: "P-384/ES384", Raw: p384, Alg: `ES384`},
+			{Name: "P-256/ECDH-ES+A128KW", Raw: p256, Alg: `ECDH-ES+A128KW`},
+			{Name: "Ed25519/EdDSA", Raw: edKey, Alg: `EdDSA`},
+			{Name: "Ed25519/ECDH-ES", Raw: edKey, Alg: `ECDH-ES`, Error: true},
+			{Name: "X25519/EdDSA", Raw: xKey, Alg: `EdDSA`, Error: true},
+			{Name: "oct/HS512 (short)", Raw: make([]byte, 32), Alg: `HS512`, Error: true},
+			{Name: "oct/A128KW (wrong size)", Raw: make([]byte, 32), Alg: `A128KW`, Error: true},
+			{Name: "oct/RS256", Raw: make([]byte, 32), Alg: `RS256`, Error: true},
+			{Name: "unknown algorithm", Raw: rsaKey, Alg: `X-CUSTOM`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				key := newKey(t, tc.Raw, tc.Use)
+				_ = key.Set(jwk.AlgorithmKey, tc.Alg)
+				err := jwk.CheckAlgorithm(key)
+				if tc.Error {
+					if !assert.Error(t, err, `jwk.CheckAlgorithm should fail`) {
+						return
+					}
+					if !assert.Error(t, jwk.AssignAlgorithm(key), `jwk.AssignAlgorithm should fail`) {
+						return
+					}
+					return
+				}
+				if !assert.NoError(t, err, `jwk.CheckAlgorithm should succeed`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Parse", func(t *testing.T) {
+		t.Parallel()
+		key := newKey(t, &p384.PublicKey, "")
+		_ = key.Set(jwk.AlgorithmKey, jwa.ES256)
+		buf, err := json.Marshal(key)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+
+		if _, err := jwk.ParseKey(buf); !assert.NoError(t, err, `jwk.ParseKey should succeed without strict mode`) {
+			return
+		}
+		if _, err := jwk.ParseKey(buf, jwk.WithStrictAlgorithm(true)); !assert.Error(t, err, `jwk.ParseKey should fail in strict mode`) {
+			return
+		}
+
+		set := jwk.NewSet()
+		set.Add(newKey(t, rsaKey, ""))
+		set.Add(key)
+		buf, err = json.Marshal(set)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		if _, err := jwk.Parse(buf); !assert.NoError(t, err, `jwk.Parse should succeed without strict mode`) {
+			return
+		}
+		if _, err := jwk.Parse(buf, jwk.WithStrictAlgorithm(true)); !assert.Error(t, err, `jwk.Parse should fail in strict mode`) {
+			return
+		}
+	})
+}
+
+func TestZeroize(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Name     string
+		Generate func() (jwk.Key, error)
+		Private  []string
+	}{
+		{Name: "RSA", Generate: jwxtest.GenerateRsaJwk, Private: []string{jwk.RSADKey, jwk.RSAPKey, jwk.RSAQKey, jwk.RSADPKey, jwk.RSADQKey, jwk.RSAQIKey}},
+		{Name: "ECDSA", Generate: jwxtest.GenerateEcdsaJwk, Private: []string{jwk.ECDSADKey}},
+		{Name: "Ed25519", Generate: jwxtest.GenerateEd25519Jwk, Private: []string{jwk.OKPDKey}},
+		{Name: "X25519", Generate: jwxtest.GenerateX25519Jwk, Private: []string{jwk.OKPDKey}},
+		{Name: "Symmetric", Generate: jwxtest.GenerateSymmetricJwk, Private: []string{jwk.SymmetricOctetsKey}},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			key, err := tc.Generate()
+			if !assert.NoError(t, err, `generating key should succeed`) {
+				return
+			}
+
+			var public jwk.Key
+			if tc.Name != "Symmetric" {
+				public, err = jwk.PublicKeyOf(key)
+				if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+					return
+				}
+			}
+
+			var buffers [][]byte
+			for _, name := range tc.Private {
+				v, ok := key.Get(name)
+				if !assert.True(t, ok, `%q should be present`, name) {
+					return
+				}
+				buffers = append(buffers, v.([]byte))
+			}
+
+			key.Zeroize()
+			for i, name := range tc.Private {
+				if !assert.Equal(t, make([]byte, len(buffers[i])), buffers[i], `%q should be overwritten with zeros`, name) {
+					return
+				}
+				if _, ok := key.Get(name); !assert.False(t, ok, `%q should be removed`, name) {
+					return
+				}
+			}
+
+			var raw interface{}
+			if !assert.Error(t, key.Raw(&raw), `Raw should fail after Zeroize`) {
+				return
+			}
+
+			if public != nil {
+				public.Zeroize()
+				if !assert.NoError(t, public.Raw(&raw), `Raw should succeed for a public key after Zeroize`) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestMaterialize(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RSA", func(t *testing.T) {
+		t.Parallel()
+		privkey, err := jwxtest.GenerateRsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+			return
+		}
+		key, err := jwk.PublicKeyOf(privkey)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Materialize(), `key.Materialize should succeed`) {
+			return
+		}
+
+		var raw1, raw2 interface{}
+		if !assert.NoError(t, key.Raw(&raw1), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, raw1, raw2, `raw keys should match`) {
+			return
+		}
+		if !assert.False(t, raw1 == raw2, `raw key should be a copy`) {
+			return
+		}
+
+		// modifying the returned value does not affect the cached key
+		raw2.(*rsa.PublicKey).N.SetInt64(1)
+		raw2.(*rsa.PublicKey).E = 3
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, raw1, raw2, `raw key should not be affected by modifications`) {
+			return
+		}
+
+		// parameters that do not affect the raw key keep the cache
+		if !assert.NoError(t, key.Set(jwk.KeyIDKey, `foo`), `key.Set should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, raw1, raw2, `raw keys should match`) {
+			return
+		}
+
+		// changing the key material invalidates the cache
+		other, err := jwxtest.GenerateRsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+			return
+		}
+		n := other.PublicKey.N.Bytes()
+		if !assert.NoError(t, key.Set(jwk.RSANKey, n), `key.Set should succeed`) {
+			return
+		}
+
+		// the value given to Set is copied, so modifying it afterwards
+		// does not affect the key
+		for i := range n {
+			n[i] = 0
+		}
+		if !assert.Equal(t, other.PublicKey.N.Bytes(), key.(jwk.RSAPublicKey).N(), `"n" should not be affected by modifications`) {
+			return
+		}
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, other.PublicKey.N, raw2.(*rsa.PublicKey).N, `raw key should reflect the new "n"`) {
+			return
+		}
+
+		var rawpriv rsa.PrivateKey
+		if !assert.NoError(t, privkey.Raw(&rawpriv), `privkey.Raw should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.(jwk.RSAPublicKey).FromRaw(&rawpriv.PublicKey), `key.FromRaw should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, raw1, raw2, `raw key should reflect FromRaw`) {
+			return
+		}
+	})
+	t.Run("ECDSA", func(t *testing.T) {
+		t.Parallel()
+		privkey, err := jwxtest.GenerateEcdsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+			return
+		}
+		key, err := jwk.PublicKeyOf(privkey)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+
+		var raw1, raw2 interface{}
+		if !assert.NoError(t, key.Raw(&raw1), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.False(t, raw1 == raw2, `raw key should be a copy`) {
+			return
+		}
+
+		// modifying the returned value does not affect the cached key
+		raw2.(*ecdsa.PublicKey).X.SetInt64(1)
+		raw2.(*ecdsa.PublicKey).Y.SetInt64(1)
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, raw1, raw2, `raw key should not be affected by modifications`) {
+			return
+		}
+
+		if !assert.NoError(t, key.Remove(jwk.ECDSACrvKey), `key.Remove should succeed`) {
+			return
+		}
+		if !assert.Error(t, key.Materialize(), `key.Materialize should fail without "crv"`) {
+			return
+		}
+	})
+	t.Run("OKP", func(t *testing.T) {
+		t.Parallel()
+		privkey, err := jwxtest.GenerateEd25519Jwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateEd25519Jwk should succeed`) {
+			return
+		}
+		key, err := jwk.PublicKeyOf(privkey)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+
+		var raw1, raw2 ed25519.PublicKey
+		if !assert.NoError(t, key.Raw(&raw1), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+
+		// modifying the returned value does not affect the cached key
+		for i := range raw2 {
+			raw2[i] = 0
+		}
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.Equal(t, raw1, raw2, `raw key should not be affected by modifications`) {
+			return
+		}
+	})
+	t.Run("Private keys are not cached", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwxtest.GenerateRsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Materialize(), `key.Materialize should succeed`) {
+			return
+		}
+
+		var raw1, raw2 interface{}
+		if !assert.NoError(t, key.Raw(&raw1), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Raw(&raw2), `key.Raw should succeed`) {
+			return
+		}
+		if !assert.False(t, raw1 == raw2, `raw key should not be shared`) {
+			return
+		}
+
+		key.Zeroize()
+		if !assert.Error(t, key.Materialize(), `key.Materialize should fail after Zeroize`) {
+			return
+		}
+	})
+}
+
+func sameKey(t *testing.T, a, b jwk.Key) bool {
+	t.Helper()
+	tpa, err := a.Thumbprint(crypto.SHA256)
+	if !assert.NoError(t, err, `a.Thumbprint should succeed`) {
+		return false
+	}
+	tpb, err := b.Thumbprint(crypto.SHA256)
+	if !assert.NoError(t, err, `b.Thumbprint should succeed`) {
+		return false
+	}
+	return string(tpa) == string(tpb)
+}
+
+func TestGenerateKey(t *testing.T) {
+	testcases := []struct {
+		Name    string
+		KeyType jwa.KeyType
+		Options []jwk.GenerateOption
+		Check   func(*testing.T, jwk.Key)
+		Error   bool
+	}{
+		{
+			Name:    "RSA",
+			KeyType: jwa.RSA,
+			Check: func(t *testing.T, key jwk.Key) {
+				var raw rsa.PrivateKey
+				if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+					return
+				}
+				if !assert.Equal(t, 2048, raw.N.BitLen(), `key size should match`) {
+					return
+				}
+				assert.NoError(t, raw.Validate(), `raw.Validate should succeed`)
+			},
+		},
+		{
+			Name:    "EC (P-384)",
+			KeyType: jwa.EC,
+			Options: []jwk.GenerateOption{jwk.WithCurve(jwa.P384)},
+			Check: func(t *testing.T, key jwk.Key) {
+				if !assert.Equal(t, jwa.P384, key.(jwk.ECDSAPrivateKey).Crv(), `curve should match`) {
+					return
+				}
+				var raw ecdsa.PrivateKey
+				if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+					return
+				}
+				x, y := raw.Curve.ScalarBaseMult(raw.D.Bytes())
+				assert.True(t, x.Cmp(raw.X) == 0 && y.Cmp(raw.Y) == 0, `public key should match private key`)
+			},
+		},
+		{
+			Name:    "OKP (X25519)",
+			KeyType: jwa.OKP,
+			Options: []jwk.GenerateOption{jwk.WithCurve(jwa.X25519)},
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Equal(t, jwa.X25519, key.(jwk.OKPPrivateKey).Crv(), `curve should match`)
+			},
+		},
+		{
+			Name:    "oct",
+			KeyType: jwa.OctetSeq,
+			Options: []jwk.GenerateOption{jwk.WithKeySize(128)},
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Len(t, key.(jwk.SymmetricKey).Octets(), 16, `key size should match`)
+			},
+		},
+		{
+			Name:    "RSA key too small",
+			KeyType: jwa.RSA,
+			Options: []jwk.GenerateOption{jwk.WithKeySize(512)},
+			Error:   true,
+		},
+		{
+			Name:    "OKP with EC curve",
+			KeyType: jwa.OKP,
+			Options: []jwk.GenerateOption{jwk.WithCurve(jwa.P256)},
+			Error:   true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			key1, err := jwk.GenerateKeyWithReader(tc.KeyType, jwktest.NewReader(tc.Name), tc.Options...)
+			if tc.Error {
+				assert.Error(t, err, `jwk.GenerateKeyWithReader should fail`)
+				return
+			}
+			if !assert.NoError(t, err, `jwk.GenerateKeyWithReader should succeed`) {
+				return
+			}
+			tc.Check(t, key1)
+
+			key2, err := jwk.GenerateKeyWithReader(tc.KeyType, jwktest.NewReader(tc.Name), tc.Options...)
+			if !assert.NoError(t, err, `jwk.GenerateKeyWithReader should succeed`) {
+				return
+			}
+			if !assert.True(t, sameKey(t, key1, key2), `keys generated from the same seed should be equal`) {
+				return
+			}
+
+			key3, err := jwk.GenerateKeyWithReader(tc.KeyType, jwktest.NewReader(tc.Name+"/other"), tc.Options...)
+			if !assert.NoError(t, err, `jwk.GenerateKeyWithReader should succeed`) {
+				return
+			}
+			if !assert.False(t, sameKey(t, key1, key3), `keys generated from different seeds should differ`) {
+				return
+			}
+
+			key4, err := jwk.GenerateKey(tc.KeyType, tc.Options...)
+			if !assert.NoError(t, err, `jwk.GenerateKey should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.KeyType, key4.KeyType(), `key type should match`) {
+				return
+			}
+			tc.Check(t, key4)
+		})
+	}
+}
+
+func TestKeyIDStrategies(t *testing.T) {
+	t.Parallel()
+
+	newKey := func(t *testing.T) jwk.Key {
+		t.Helper()
+		key, err := jwk.GenerateKeyWithReader(jwa.OKP, jwktest.NewReader(`TestKeyIDStrategies`))
+		if !assert.NoError(t, err, `jwk.GenerateKeyWithReader should succeed`) {
+			t.FailNow()
+		}
+		return key
+	}
+
+	t.Run("SHA256 thumbprint", func(t *testing.T) {
+		t.Parallel()
+		key := newKey(t)
+		if !assert.NoError(t, jwk.AssignKeyID(key, jwk.WithKeyIDStrategy(jwk.KidStrategySHA256Thumbprint)), `jwk.AssignKeyID should succeed`) {
+			return
+		}
+		tp, err := key.Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `key.Thumbprint should succeed`) {
+			return
+		}
+		assert.Equal(t, base64.EncodeToString(tp), key.KeyID(), `"kid" should be the thumbprint`)
+	})
+	t.Run("UUID", func(t *testing.T) {
+		t.Parallel()
+		key := newKey(t)
+		if !assert.NoError(t, jwk.AssignKeyID(key, jwk.WithKeyIDStrategy(jwk.KidStrategyUUID)), `jwk.AssignKeyID should succeed`) {
+			return
+		}
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, key.KeyID(), `"kid" should be a UUID`)
+	})
+	t.Run("Timestamp", func(t *testing.T) {
+		t.Parallel()
+		key := newKey(t)
+		now := func() time.Time {
+			return time.Date(2021, 12, 31, 23, 59, 59, 0, time.UTC)
+		}
+		if !assert.NoError(t, jwk.AssignKeyID(key, jwk.WithKeyIDStrategy(jwk.TimestampKeyID(now))), `jwk.AssignKeyID should succeed`) {
+			return
+		}
+		tp, err := key.Thumbprint(crypto.SHA256)
+		if !assert.NoError(t, err, `key.Thumbprint should succeed`) {
+			return
+		}
+		assert.Equal(t, `20211231T235959Z-`+base64.EncodeToString(tp)[:8], key.KeyID(), `"kid" should match`)
+	})
+	t.Run("x5t", func(t *testing.T) {
+		t.Parallel()
+		key := newKey(t)
+		if !assert.Error(t, jwk.AssignKeyID(key, jwk.WithKeyIDStrategy(jwk.KidStrategyX5T)), `jwk.AssignKeyID should fail without a certificate`) {
+			return
+		}
+		if !assert.NoError(t, key.Set(jwk.X509CertThumbprintKey, `x5t-value`), `key.Set should succeed`) {
+			return
+		}
+		if !assert.NoError(t, jwk.AssignKeyID(key, jwk.WithKeyIDStrategy(jwk.KidStrategyX5T)), `jwk.AssignKeyID should succeed`) {
+			return
+		}
+		assert.Equal(t, `x5t-value`, key.KeyID(), `"kid" should be the x5t`)
+	})
+	t.Run("Existing kid is kept", func(t *testing.T) {
+		t.Parallel()
+		key := newKey(t)
+		if !assert.NoError(t, key.Set(jwk.KeyIDKey, `my-key`), `key.Set should succeed`) {
+			return
+		}
+		if !assert.NoError(t, jwk.AssignKeyID(key, jwk.WithKeyIDStrategy(jwk.KidStrategyUUID)), `jwk.AssignKeyID should succeed`) {
+			return
+		}
+		assert.Equal(t, `my-key`, key.KeyID(), `"kid" should not change`)
+	})
+	t.Run("Set with WithAutoKeyID", func(t *testing.T) {
+		t.Parallel()
+		set := jwk.NewSet(jwk.WithAutoKeyID(jwk.KidStrategySHA256Thumbprint))
+
+		key := newKey(t)
+		if !assert.True(t, set.Add(key), `set.Add should succeed`) {
+			return
+		}
+		if !assert.NotEmpty(t, key.KeyID(), `"kid" should be assigned`) {
+			return
+		}
+		if _, ok := set.LookupKeyID(key.KeyID()); !assert.True(t, ok, `key should be found by "kid"`) {
+			return
+		}
+
+		// keys for which a "kid" cannot be computed are not added
+		failing := jwk.NewSet(jwk.WithAutoKeyID(jwk.KidStrategyX5T))
+		if !assert.False(t, failing.Add(newKey(t)), `set.Add should fail`) {
+			return
+		}
+		assert.Equal(t, 0, failing.Len(), `set should be empty`)
+	})
+}
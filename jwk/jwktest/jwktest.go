@@ -0,0 +1,150 @@
+// Package jwktest provides fixed keys for use in tests.
+//
+// The keys are derived from constant seeds using
+// `jwk.GenerateKeyWithReader()`, so they are the same in every run, and
+// on every machine. They are generated the first time they are
+// requested, and cached afterwards.
+//
+// As the key material is public, these keys MUST NOT be used outside
+// of tests.
+package jwktest
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+type reader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+// NewReader returns a reader that produces an infinite, deterministic
+// stream of bytes derived from `seed`, to be passed to
+// `jwk.GenerateKeyWithReader()`. Readers created with the same seed
+// produce the same stream.
+func NewReader(seed string) io.Reader {
+	return &reader{seed: []byte(seed)}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			h := sha256.New()
+			h.Write(r.seed)
+			var counter [8]byte
+			binary.BigEndian.PutUint64(counter[:], r.counter)
+			h.Write(counter[:])
+			r.counter++
+			r.buf = h.Sum(nil)
+		}
+		copied := copy(p[n:], r.buf)
+		r.buf = r.buf[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+type keySpec struct {
+	kty     jwa.KeyType
+	options []jwk.GenerateOption
+}
+
+var signatureKeySpecs = map[jwa.SignatureAlgorithm]keySpec{
+	jwa.HS256:  {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(256)}},
+	jwa.HS384:  {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(384)}},
+	jwa.HS512:  {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(512)}},
+	jwa.RS256:  {jwa.RSA, nil},
+	jwa.RS384:  {jwa.RSA, nil},
+	jwa.RS512:  {jwa.RSA, nil},
+	jwa.PS256:  {jwa.RSA, nil},
+	jwa.PS384:  {jwa.RSA, nil},
+	jwa.PS512:  {jwa.RSA, nil},
+	jwa.ES256:  {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+	jwa.ES384:  {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P384)}},
+	jwa.ES512:  {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P521)}},
+	jwa.ES256K: {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.EllipticCurveAlgorithm(`secp256k1`))}},
+	jwa.EdDSA:  {jwa.OKP, []jwk.GenerateOption{jwk.WithCurve(jwa.Ed25519)}},
+}
+
+var encryptionKeySpecs = map[jwa.KeyEncryptionAlgorithm]keySpec{
+	jwa.RSA1_5:          {jwa.RSA, nil},
+	jwa.RSA_OAEP:        {jwa.RSA, nil},
+	jwa.RSA_OAEP_256:    {jwa.RSA, nil},
+	jwa.A128KW:          {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(128)}},
+	jwa.A192KW:          {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(192)}},
+	jwa.A256KW:          {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(256)}},
+	jwa.A128GCMKW:       {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(128)}},
+	jwa.A192GCMKW:       {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(192)}},
+	jwa.A256GCMKW:       {jwa.OctetSeq, []jwk.GenerateOption{jwk.WithKeySize(256)}},
+	jwa.ECDH_ES:         {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+	jwa.ECDH_ES_A128KW:  {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+	jwa.ECDH_ES_A192KW:  {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+	jwa.ECDH_ES_A256KW:  {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+	jwa.ECDH_1PU:        {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+	jwa.ECDH_1PU_A128KW: {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+	jwa.ECDH_1PU_A192KW: {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+	jwa.ECDH_1PU_A256KW: {jwa.EC, []jwk.GenerateOption{jwk.WithCurve(jwa.P256)}},
+}
+
+var muCache sync.Mutex
+var cache = map[string]jwk.Key{}
+
+// SigningKey returns the fixed private key (or symmetric key) for
+// the signature algorithm `alg`. The "alg" field of the key is set to
+// `alg`, and the "kid" field is set to "jwktest-" followed by `alg`.
+//
+// Each algorithm has its own key, so for example the keys for
+// jwa.RS256 and jwa.PS256 differ. The returned key is a copy, and may
+// be modified freely.
+func SigningKey(alg jwa.SignatureAlgorithm) (jwk.Key, error) {
+	spec, ok := signatureKeySpecs[alg]
+	if !ok {
+		return nil, errors.Errorf(`unsupported signature algorithm %s`, alg)
+	}
+	return fixedKey(alg.String(), spec)
+}
+
+// EncryptionKey returns the fixed private key (or symmetric key) for
+// the key encryption algorithm `alg`, in the same manner as
+// `jwktest.SigningKey()`.
+//
+// jwa.DIRECT and the PBES2 family are not supported, as their key
+// depends on the content encryption algorithm or is a password.
+func EncryptionKey(alg jwa.KeyEncryptionAlgorithm) (jwk.Key, error) {
+	spec, ok := encryptionKeySpecs[alg]
+	if !ok {
+		return nil, errors.Errorf(`unsupported key encryption algorithm %s`, alg)
+	}
+	return fixedKey(alg.String(), spec)
+}
+
+func fixedKey(alg string, spec keySpec) (jwk.Key, error) {
+	muCache.Lock()
+	defer muCache.Unlock()
+
+	key, ok := cache[alg]
+	if !ok {
+		generated, err := jwk.GenerateKeyWithReader(spec.kty, NewReader(`jwktest/`+alg), spec.options...)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to generate key for %s`, alg)
+		}
+		if err := generated.Set(jwk.AlgorithmKey, alg); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, jwk.AlgorithmKey)
+		}
+		if err := generated.Set(jwk.KeyIDKey, `jwktest-`+alg); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, jwk.KeyIDKey)
+		}
+		cache[alg] = generated
+		key = generated
+	}
+	return key.Clone()
+}
@@ -0,0 +1,133 @@
+package jwktest_test
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwk/jwktest"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningKey(t *testing.T) {
+	algs := []jwa.SignatureAlgorithm{
+		jwa.HS256, jwa.HS512, jwa.RS256, jwa.PS256, jwa.ES256, jwa.ES384, jwa.ES512, jwa.EdDSA,
+	}
+	for _, alg := range algs {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			key, err := jwktest.SigningKey(alg)
+			if !assert.NoError(t, err, `jwktest.SigningKey should succeed`) {
+				return
+			}
+			if !assert.Equal(t, alg.String(), key.Algorithm(), `"alg" should match`) {
+				return
+			}
+			if !assert.Equal(t, `jwktest-`+alg.String(), key.KeyID(), `"kid" should match`) {
+				return
+			}
+
+			signed, err := jws.Sign([]byte(`Lorem ipsum`), alg, key)
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+
+			verifyKey := key
+			if key.KeyType() != jwa.OctetSeq {
+				verifyKey, err = jwk.PublicKeyOf(key)
+				if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+					return
+				}
+			}
+			_, err = jws.Verify(signed, alg, verifyKey)
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Returned keys are copies", func(t *testing.T) {
+		key, err := jwktest.SigningKey(jwa.ES256)
+		if !assert.NoError(t, err, `jwktest.SigningKey should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Set(jwk.KeyIDKey, `modified`), `key.Set should succeed`) {
+			return
+		}
+		key, err = jwktest.SigningKey(jwa.ES256)
+		if !assert.NoError(t, err, `jwktest.SigningKey should succeed`) {
+			return
+		}
+		assert.Equal(t, `jwktest-ES256`, key.KeyID(), `"kid" should not be modified`)
+	})
+	t.Run("Keys are stable", func(t *testing.T) {
+		// The thumbprints below must never change, as users may have
+		// recorded values signed with these keys in their test data
+		expected := map[jwa.SignatureAlgorithm]string{
+			jwa.ES256: `ZLpGrQ6568ZlxOu8rkHK1dC3B7Uzn2Wv3IUOqm8mPGg`,
+			jwa.EdDSA: `0Q64gcHVvXkRCVNQG5xaOYgGnBLI56JmHXpxk_W7q98`,
+			jwa.RS256: `d0QBuZtVaW1yHJIiEzZS1jFd3UDhetLYc-_emdYxHQs`,
+			jwa.HS256: `c5rlcL2ax4SCqUw6ce79DaLwhfb9Nx2fMB3c3YC3ZNE`,
+		}
+		for alg, tp := range expected {
+			key, err := jwktest.SigningKey(alg)
+			if !assert.NoError(t, err, `jwktest.SigningKey should succeed`) {
+				return
+			}
+			actual, err := key.Thumbprint(crypto.SHA256)
+			if !assert.NoError(t, err, `key.Thumbprint should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tp, base64.EncodeToString(actual), `thumbprint for %s should match`, alg) {
+				return
+			}
+		}
+	})
+	t.Run("Unsupported algorithm", func(t *testing.T) {
+		_, err := jwktest.SigningKey(jwa.NoSignature)
+		assert.Error(t, err, `jwktest.SigningKey should fail`)
+	})
+}
+
+func TestEncryptionKey(t *testing.T) {
+	algs := []jwa.KeyEncryptionAlgorithm{
+		jwa.RSA_OAEP, jwa.A128KW, jwa.A256GCMKW, jwa.ECDH_ES, jwa.ECDH_ES_A256KW,
+	}
+	for _, alg := range algs {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			key, err := jwktest.EncryptionKey(alg)
+			if !assert.NoError(t, err, `jwktest.EncryptionKey should succeed`) {
+				return
+			}
+
+			encryptKey := key
+			if key.KeyType() != jwa.OctetSeq {
+				encryptKey, err = jwk.PublicKeyOf(key)
+				if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+					return
+				}
+			}
+			encrypted, err := jwe.Encrypt([]byte(`Lorem ipsum`), alg, encryptKey, jwa.A128GCM, jwa.NoCompress)
+			if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+				return
+			}
+			decrypted, err := jwe.Decrypt(encrypted, alg, key)
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			if !assert.Equal(t, []byte(`Lorem ipsum`), decrypted, `payload should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Unsupported algorithm", func(t *testing.T) {
+		_, err := jwktest.EncryptionKey(jwa.DIRECT)
+		assert.Error(t, err, `jwktest.EncryptionKey should fail`)
+	})
+}
@@ -0,0 +1,157 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
+	"github.com/pkg/errors"
+)
+
+// KeySetParser converts the contents of a remote resource that is not a
+// JWK set document, such as a map of certificates, into a jwk.Set.
+// Pass it to `jwk.Fetch()` or `(*jwk.AutoRefresh).Configure()` using
+// `jwk.WithKeySetParser()`.
+type KeySetParser interface {
+	ParseKeySet([]byte) (Set, error)
+}
+
+// KeySetParserFunc is a function that implements the KeySetParser interface
+type KeySetParserFunc func([]byte) (Set, error)
+
+func (f KeySetParserFunc) ParseKeySet(buf []byte) (Set, error) {
+	return f(buf)
+}
+
+// ParsePEMMap parses a JSON object that maps key IDs to PEM encoded
+// certificates or public keys, which is the format used by endpoints
+// such as https://www.googleapis.com/oauth2/v1/certs and
+// https://www.gstatic.com/iap/verify/public_key.
+//
+//   {
+//     "6f7254101f56e41cf35c9926de84a2d552b4c6f1": "-----BEGIN CERTIFICATE-----\n...",
+//     "0oeLcQ": "-----BEGIN PUBLIC KEY-----\n..."
+//   }
+//
+// Each entry becomes a key whose "kid" is the name of the member. Keys
+// taken from certificates also have the certificate in their "x5c" field.
+// The keys are sorted by their key ID.
+func ParsePEMMap(src []byte) (Set, error) {
+	if err := limits.CheckInputSize(len(src)); err != nil {
+		return nil, newParseError(err)
+	}
+
+	var m map[string]string
+	if err := json.Unmarshal(src, &m); err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to unmarshal PEM map`))
+	}
+
+	kids := make([]string, 0, len(m))
+	for kid := range m {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := NewSet()
+	for _, kid := range kids {
+		block, _ := pem.Decode([]byte(m[kid]))
+		if block == nil {
+			return nil, newParseError(errors.Errorf(`failed to decode PEM data for key %q`, kid))
+		}
+		key, err := parsePEMPublicKey(block)
+		if err != nil {
+			return nil, newParseError(errors.Wrapf(err, `failed to parse key %q`, kid))
+		}
+		if err := key.Set(KeyIDKey, kid); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, KeyIDKey)
+		}
+		set.Add(key)
+	}
+	return set, nil
+}
+
+// PEMParser returns a KeySetParser for resources that contain one or more
+// PEM encoded certificates or public keys, such as the endpoints used by
+// AWS Application Load Balancers, which serve a single public key at
+// https://public-keys.auth.elb.{region}.amazonaws.com/{kid}.
+//
+// As these resources do not contain key IDs, `kid` is assigned to all of
+// the keys. If `kid` is empty, the keys are left without a key ID.
+func PEMParser(kid string) KeySetParser {
+	return KeySetParserFunc(func(src []byte) (Set, error) {
+		if err := limits.CheckInputSize(len(src)); err != nil {
+			return nil, newParseError(err)
+		}
+
+		set := NewSet()
+		src = bytes.TrimSpace(src)
+		for len(src) > 0 {
+			block, rest := pem.Decode(src)
+			if block == nil {
+				return nil, newParseError(errors.New(`failed to decode PEM data`))
+			}
+
+			key, err := parsePEMPublicKey(block)
+			if err != nil {
+				return nil, newParseError(err)
+			}
+			if kid != "" {
+				if err := key.Set(KeyIDKey, kid); err != nil {
+					return nil, errors.Wrapf(err, `failed to set %q`, KeyIDKey)
+				}
+			}
+			set.Add(key)
+			src = bytes.TrimSpace(rest)
+		}
+
+		if set.Len() == 0 {
+			return nil, newParseError(errors.New(`no keys found in PEM data`))
+		}
+		return set, nil
+	})
+}
+
+// parsePEMPublicKey parses a PEM block containing a certificate or a
+// public key. Private keys are rejected, as remote resources are only
+// expected to publish public keys
+func parsePEMPublicKey(block *pem.Block) (Key, error) {
+	var raw interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse certificate`)
+		}
+		key, err := New(cert.PublicKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create jwk.Key from %T`, cert.PublicKey)
+		}
+		if err := key.Set(X509CertChainKey, []*x509.Certificate{cert}); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, X509CertChainKey)
+		}
+		return key, nil
+	case "PUBLIC KEY":
+		v, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse PKIX public key`)
+		}
+		raw = v
+	case "RSA PUBLIC KEY":
+		v, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse PKCS1 public key`)
+		}
+		raw = v
+	default:
+		return nil, errors.Errorf(`expected a certificate or a public key, got %q block`, block.Type)
+	}
+
+	key, err := New(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create jwk.Key from %T`, raw)
+	}
+	return key, nil
+}
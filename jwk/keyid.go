@@ -0,0 +1,95 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"fmt"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/pkg/errors"
+)
+
+// KeyIDStrategy computes the "kid" to assign to a key. It is used by
+// `jwk.AssignKeyID()` and by sets created with `jwk.WithAutoKeyID()`.
+type KeyIDStrategy interface {
+	KeyID(Key) (string, error)
+}
+
+// KeyIDStrategyFunc is a KeyIDStrategy represented as a function
+type KeyIDStrategyFunc func(Key) (string, error)
+
+func (f KeyIDStrategyFunc) KeyID(key Key) (string, error) {
+	return f(key)
+}
+
+var (
+	// KidStrategySHA256Thumbprint uses the base64url encoded RFC7638
+	// thumbprint of the key, computed using SHA-256. This is the
+	// default strategy.
+	KidStrategySHA256Thumbprint = ThumbprintKeyID(crypto.SHA256)
+
+	// KidStrategyUUID uses a random (version 4) UUID
+	KidStrategyUUID KeyIDStrategy = KeyIDStrategyFunc(uuidKeyID)
+
+	// KidStrategyTimestamp uses the current time in UTC, followed by
+	// the first 8 characters of the SHA-256 thumbprint of the key, such
+	// as "20211231T235959Z-NzbLsXh8". Keys that are assigned a "kid"
+	// later sort after keys that were assigned one earlier.
+	KidStrategyTimestamp = TimestampKeyID(time.Now)
+
+	// KidStrategyX5T uses the "x5t" field of the key if present, or
+	// otherwise the base64url encoded SHA-1 digest of the first
+	// certificate in the "x5c" field. This matches the convention used
+	// by some identity providers, where "kid" and "x5t" are the same.
+	KidStrategyX5T KeyIDStrategy = KeyIDStrategyFunc(x5tKeyID)
+)
+
+// ThumbprintKeyID returns a KeyIDStrategy that uses the base64url
+// encoded RFC7638 thumbprint of the key, computed using `hash`.
+func ThumbprintKeyID(hash crypto.Hash) KeyIDStrategy {
+	return KeyIDStrategyFunc(func(key Key) (string, error) {
+		h, err := key.Thumbprint(hash)
+		if err != nil {
+			return "", errors.Wrap(err, `failed to generate thumbprint`)
+		}
+		return base64.EncodeToString(h), nil
+	})
+}
+
+// TimestampKeyID returns a KeyIDStrategy that works like
+// jwk.KidStrategyTimestamp, but obtains the current time from `now`.
+func TimestampKeyID(now func() time.Time) KeyIDStrategy {
+	return KeyIDStrategyFunc(func(key Key) (string, error) {
+		h, err := key.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return "", errors.Wrap(err, `failed to generate thumbprint`)
+		}
+		return now().UTC().Format(`20060102T150405Z`) + `-` + base64.EncodeToString(h)[:8], nil
+	})
+}
+
+func uuidKeyID(Key) (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", errors.Wrap(err, `failed to generate UUID`)
+	}
+	buf[6] = (buf[6] & 0x0f) | 0x40 // version 4
+	buf[8] = (buf[8] & 0x3f) | 0x80 // RFC4122 variant
+	return fmt.Sprintf(`%x-%x-%x-%x-%x`, buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16]), nil
+}
+
+func x5tKeyID(key Key) (string, error) {
+	if v := key.X509CertThumbprint(); v != "" {
+		return v, nil
+	}
+
+	certs := key.X509CertChain()
+	if len(certs) == 0 {
+		return "", errors.New(`key has neither "x5t" nor "x5c"`)
+	}
+	//nolint:gosec
+	h := sha1.Sum(certs[0].Raw)
+	return base64.EncodeToString(h[:]), nil
+}
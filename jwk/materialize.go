@@ -0,0 +1,83 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"math/big"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/x25519"
+)
+
+// rawKeyCache holds the raw key created from a public key, so that
+// repeated calls to `Raw()` (e.g. when verifying many messages with the
+// same key) do not have to unmarshal and validate the key material every
+// time. `Raw()` returns copies of the cached key (see getCopy).
+//
+// It has its own lock, as `Raw()` may be called while the key's lock is
+// held for reading (e.g. from `Thumbprint()`). The cache is invalidated
+// while the key's lock is held for writing, and populated while it is
+// held for reading, so the cached value always matches the key.
+type rawKeyCache struct {
+	mu  sync.Mutex
+	raw interface{}
+}
+
+// get returns the cached raw key, or creates one using `build`
+// and caches it
+func (c *rawKeyCache) get(build func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return build()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.raw != nil {
+		return c.raw, nil
+	}
+
+	raw, err := build()
+	if err != nil {
+		return nil, err
+	}
+	c.raw = raw
+	return raw, nil
+}
+
+// getCopy is the same as get, but returns a copy of the cached raw key,
+// so that callers of `Raw()` cannot modify the cached value
+func (c *rawKeyCache) getCopy(build func() (interface{}, error)) (interface{}, error) {
+	raw, err := c.get(build)
+	if err != nil {
+		return nil, err
+	}
+	return copyRawPublicKey(raw), nil
+}
+
+// copyRawPublicKey returns a copy of a raw public key that does not
+// share any mutable storage with it. Immutable keys, such as ML-DSA
+// keys, are returned as is
+func copyRawPublicKey(raw interface{}) interface{} {
+	switch raw := raw.(type) {
+	case *rsa.PublicKey:
+		return &rsa.PublicKey{N: new(big.Int).Set(raw.N), E: raw.E}
+	case *ecdsa.PublicKey:
+		return &ecdsa.PublicKey{Curve: raw.Curve, X: new(big.Int).Set(raw.X), Y: new(big.Int).Set(raw.Y)}
+	case ed25519.PublicKey:
+		return append(ed25519.PublicKey(nil), raw...)
+	case x25519.PublicKey:
+		return append(x25519.PublicKey(nil), raw...)
+	}
+	return raw
+}
+
+func (c *rawKeyCache) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.raw = nil
+	c.mu.Unlock()
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/lestrrat-go/blackmagic"
 	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/x25519"
 	"github.com/pkg/errors"
@@ -17,6 +18,7 @@ import (
 func (k *okpPublicKey) FromRaw(rawKeyIf interface{}) error {
 	k.mu.Lock()
 	defer k.mu.Unlock()
+	k.raw.invalidate()
 
 	var crv jwa.EllipticCurveAlgorithm
 	switch rawKey := rawKeyIf.(type) {
@@ -69,19 +71,43 @@ func buildOKPPublicKey(alg jwa.EllipticCurveAlgorithm, xbuf []byte) (interface{}
 	}
 }
 
-// Raw returns the EC-DSA public key represented by this JWK
+// Raw returns the EC-DSA public key represented by this JWK. The key
+// is cached (see `Materialize()`), and each call returns a copy of it.
 func (k *okpPublicKey) Raw(v interface{}) error {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
+	pubk, err := k.raw.getCopy(k.buildRaw)
+	if err != nil {
+		return err
+	}
+	return blackmagic.AssignIfCompatible(v, pubk)
+}
+
+func (k *okpPublicKey) buildRaw() (interface{}, error) {
 	pubk, err := buildOKPPublicKey(k.Crv(), k.x)
 	if err != nil {
-		return errors.Wrap(err, `failed to build public key`)
+		return nil, errors.Wrap(err, `failed to build public key`)
 	}
+	return pubk, nil
+}
 
-	return blackmagic.AssignIfCompatible(v, pubk)
+func (k *okpPublicKey) Materialize() error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	_, err := k.raw.get(k.buildRaw)
+	return err
 }
 
+func (k *okpPrivateKey) Materialize() error {
+	var key interface{}
+	return k.Raw(&key)
+}
+
+// Zeroize is a no-op, as public keys hold no secret material
+func (k *okpPublicKey) Zeroize() {}
+
 func buildOKPPrivateKey(alg jwa.EllipticCurveAlgorithm, xbuf []byte, dbuf []byte) (interface{}, error) {
 	switch alg {
 	case jwa.Ed25519:
@@ -108,6 +134,10 @@ func (k *okpPrivateKey) Raw(v interface{}) error {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
+	if len(k.d) == 0 {
+		return errors.New(`missing "d" value (the key may have been zeroized)`)
+	}
+
 	privk, err := buildOKPPrivateKey(k.Crv(), k.x, k.d)
 	if err != nil {
 		return errors.Wrap(err, `failed to build public key`)
@@ -116,6 +146,16 @@ func (k *okpPrivateKey) Raw(v interface{}) error {
 	return blackmagic.AssignIfCompatible(v, privk)
 }
 
+// Zeroize overwrites the private key material with zeros, and
+// removes it from the key. See `jwk.Key` for details
+func (k *okpPrivateKey) Zeroize() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	zeroize.Bytes(k.d)
+	k.d = nil
+}
+
 func makeOKPPublicKey(v interface {
 	Iterate(context.Context) HeaderIterator
 }) (Key, error) {
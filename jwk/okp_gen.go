@@ -281,7 +281,8 @@ func (h *okpPrivateKey) setNoLock(name string, value interface{}) error {
 		return errors.Errorf(`invalid value for %s key: %T`, OKPCrvKey, value)
 	case OKPDKey:
 		if v, ok := value.([]byte); ok {
-			h.d = v
+			h.d = make([]byte, len(v))
+			copy(h.d, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, OKPDKey, value)
@@ -315,7 +316,8 @@ func (h *okpPrivateKey) setNoLock(name string, value interface{}) error {
 		return nil
 	case OKPXKey:
 		if v, ok := value.([]byte); ok {
-			h.x = v
+			h.x = make([]byte, len(v))
+			copy(h.x, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, OKPXKey, value)
@@ -607,6 +609,7 @@ type okpPublicKey struct {
 	x509CertThumbprintS256 *string           // https://tools.ietf.org/html/rfc7515#section-4.1.8
 	x509URL                *string           // https://tools.ietf.org/html/rfc7515#section-4.1.5
 	privateParams          map[string]interface{}
+	raw                    *rawKeyCache
 	mu                     *sync.RWMutex
 	dc                     DecodeCtx
 }
@@ -619,6 +622,7 @@ func newOKPPublicKey() *okpPublicKey {
 	return &okpPublicKey{
 		mu:            &sync.RWMutex{},
 		privateParams: make(map[string]interface{}),
+		raw:           &rawKeyCache{},
 	}
 }
 
@@ -812,6 +816,7 @@ func (h *okpPublicKey) setNoLock(name string, value interface{}) error {
 	case "kty":
 		return nil
 	case AlgorithmKey:
+		h.raw.invalidate()
 		switch v := value.(type) {
 		case string:
 			h.algorithm = &v
@@ -823,6 +828,7 @@ func (h *okpPublicKey) setNoLock(name string, value interface{}) error {
 		}
 		return nil
 	case OKPCrvKey:
+		h.raw.invalidate()
 		if v, ok := value.(jwa.EllipticCurveAlgorithm); ok {
 			h.crv = &v
 			return nil
@@ -857,8 +863,10 @@ func (h *okpPublicKey) setNoLock(name string, value interface{}) error {
 		h.keyops = &acceptor
 		return nil
 	case OKPXKey:
+		h.raw.invalidate()
 		if v, ok := value.([]byte); ok {
-			h.x = v
+			h.x = make([]byte, len(v))
+			copy(h.x, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, OKPXKey, value)
@@ -901,8 +909,10 @@ func (k *okpPublicKey) Remove(key string) error {
 	defer k.mu.Unlock()
 	switch key {
 	case AlgorithmKey:
+		k.raw.invalidate()
 		k.algorithm = nil
 	case OKPCrvKey:
+		k.raw.invalidate()
 		k.crv = nil
 	case KeyIDKey:
 		k.keyID = nil
@@ -911,6 +921,7 @@ func (k *okpPublicKey) Remove(key string) error {
 	case KeyOpsKey:
 		k.keyops = nil
 	case OKPXKey:
+		k.raw.invalidate()
 		k.x = nil
 	case X509CertChainKey:
 		k.x509CertChain = nil
@@ -943,6 +954,7 @@ func (k *okpPublicKey) SetDecodeCtx(dc DecodeCtx) {
 }
 
 func (h *okpPublicKey) UnmarshalJSON(buf []byte) error {
+	h.raw.invalidate()
 	h.algorithm = nil
 	h.crv = nil
 	h.keyID = nil
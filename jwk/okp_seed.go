@@ -0,0 +1,225 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/pkg/errors"
+)
+
+// FromOKPSeed creates an OKP private key from a raw 32 byte seed, as
+// used by tools outside of the JOSE ecosystem. For jwa.Ed25519 the seed
+// is the RFC8032 private key (e.g. minisign, signify, libsodium's
+// crypto_sign_seed_keypair), and for jwa.X25519 it is the RFC7748
+// scalar (e.g. age, WireGuard). The public key is derived from the seed.
+func FromOKPSeed(crv jwa.EllipticCurveAlgorithm, seed []byte) (Key, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.Errorf(`invalid seed size: expected %d bytes, got %d`, ed25519.SeedSize, len(seed))
+	}
+
+	var raw interface{}
+	switch crv {
+	case jwa.Ed25519:
+		raw = ed25519.NewKeyFromSeed(seed)
+	case jwa.X25519:
+		v, err := x25519.NewKeyFromSeed(seed)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to create x25519 private key`)
+		}
+		raw = v
+	default:
+		return nil, errors.Errorf(`invalid curve algorithm %s`, crv)
+	}
+
+	key := NewOKPPrivateKey()
+	if err := key.FromRaw(raw); err != nil {
+		return nil, errors.Wrap(err, `failed to initialize OKP private key`)
+	}
+	return key, nil
+}
+
+// ParseOKPSeed parses a seed stored in a key file, and creates an OKP
+// private key from it using FromOKPSeed. The following encodings are
+// accepted, with leading and trailing whitespace ignored:
+//
+//   - 32 raw bytes
+//   - hex (64 characters)
+//   - base64, in either standard or URL encoding, with or without padding
+//
+// For jwa.Ed25519, the 64 byte "expanded" form consisting of the seed
+// followed by the public key (as produced by libsodium and Go's
+// ed25519.PrivateKey) is also accepted in any of the above encodings.
+// In that case the public key half is verified against the seed.
+func ParseOKPSeed(crv jwa.EllipticCurveAlgorithm, src []byte) (Key, error) {
+	buf, err := decodeOKPSeed(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if crv == jwa.Ed25519 && len(buf) == ed25519.PrivateKeySize {
+		key, err := FromOKPSeed(crv, buf[:ed25519.SeedSize])
+		if err != nil {
+			return nil, err
+		}
+		//nolint:forcetypeassert
+		if !bytes.Equal(key.(OKPPrivateKey).X(), buf[ed25519.SeedSize:]) {
+			return nil, errors.New(`public key does not match seed`)
+		}
+		return key, nil
+	}
+	return FromOKPSeed(crv, buf)
+}
+
+func decodeOKPSeed(src []byte) ([]byte, error) {
+	// hex is checked first, as a hex encoded 32 byte seed has the
+	// same length as the raw 64 byte form
+	trimmed := bytes.TrimSpace(src)
+	if len(trimmed) == 2*ed25519.SeedSize || len(trimmed) == 2*ed25519.PrivateKeySize {
+		buf := make([]byte, hex.DecodedLen(len(trimmed)))
+		if _, err := hex.Decode(buf, trimmed); err == nil {
+			return buf, nil
+		}
+	}
+
+	if len(src) == ed25519.SeedSize || len(src) == ed25519.PrivateKeySize {
+		return src, nil
+	}
+
+	buf, err := base64.DecodeLenient(trimmed)
+	if err != nil {
+		return nil, errors.New(`seed is neither raw bytes, hex, nor base64 encoded`)
+	}
+	return buf, nil
+}
+
+// OKPSeed returns a copy of the raw seed of an OKP private key, which
+// is the inverse of FromOKPSeed. Use encoding/hex or encoding/base64 to
+// write it in the format expected by other tools.
+func OKPSeed(key Key) ([]byte, error) {
+	okp, ok := key.(OKPPrivateKey)
+	if !ok {
+		return nil, errors.Errorf(`expected jwk.OKPPrivateKey, got %T`, key)
+	}
+
+	d := okp.D()
+	if len(d) != ed25519.SeedSize {
+		return nil, errors.Errorf(`invalid seed size: expected %d bytes, got %d`, ed25519.SeedSize, len(d))
+	}
+	seed := make([]byte, len(d))
+	copy(seed, d)
+	return seed, nil
+}
+
+// ConvertEd25519ToX25519 converts an Ed25519 key to the equivalent
+// X25519 key, so that a single signing key can also be used to receive
+// ECDH-ES encrypted messages (this is the same conversion used by age
+// for SSH keys, and libsodium's crypto_sign_ed25519_*_to_curve25519).
+//
+// Private keys are converted to private keys, and public keys to
+// public keys. Only the "crv", "x" and "d" fields are converted.
+func ConvertEd25519ToX25519(key Key) (Key, error) {
+	switch key := key.(type) {
+	case OKPPrivateKey:
+		if key.Crv() != jwa.Ed25519 {
+			return nil, errors.Errorf(`expected Ed25519 key, got %s`, key.Crv())
+		}
+		h := sha512.Sum512(key.D())
+		// clamping is also done by X25519 itself, but the stored scalar
+		// should match what other implementations produce
+		h[0] &= 248
+		h[31] &= 127
+		h[31] |= 64
+		return FromOKPSeed(jwa.X25519, h[:x25519.SeedSize])
+	case OKPPublicKey:
+		if key.Crv() != jwa.Ed25519 {
+			return nil, errors.Errorf(`expected Ed25519 key, got %s`, key.Crv())
+		}
+		u, err := edwardsToMontgomery(key.X())
+		if err != nil {
+			return nil, err
+		}
+		newKey := NewOKPPublicKey()
+		if err := newKey.FromRaw(x25519.PublicKey(u)); err != nil {
+			return nil, errors.Wrap(err, `failed to initialize OKP public key`)
+		}
+		return newKey, nil
+	default:
+		return nil, errors.Errorf(`expected OKP key, got %T`, key)
+	}
+}
+
+// DeriveX25519Key derives the X25519 key agreement key that corresponds
+// to the Ed25519 signing key `key` using ConvertEd25519ToX25519, for
+// protocols that use a single identity key for both signatures and
+// key agreement.
+//
+// The derived key has "use" set to "enc", and its "kid" is set to its
+// RFC7638 thumbprint (SHA-256, base64url encoded). As the thumbprint
+// only depends on the public key, deriving from an Ed25519 private key
+// and from its public key results in the same "kid", so that the two
+// parties can refer to the derived key without exchanging it.
+func DeriveX25519Key(key Key) (Key, error) {
+	converted, err := ConvertEd25519ToX25519(key)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to convert Ed25519 key`)
+	}
+
+	if err := converted.Set(KeyUsageKey, ForEncryption); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %q`, KeyUsageKey)
+	}
+
+	tp, err := converted.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to compute thumbprint`)
+	}
+	if err := converted.Set(KeyIDKey, base64.EncodeToString(tp)); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %q`, KeyIDKey)
+	}
+	return converted, nil
+}
+
+var curve25519P, _ = new(big.Int).SetString("7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed", 16)
+
+// edwardsToMontgomery maps the y coordinate of an Edwards25519 point
+// to the u coordinate of the birationally equivalent Curve25519
+// point: u = (1 + y) / (1 - y) (RFC7748, section 4.1)
+func edwardsToMontgomery(x []byte) ([]byte, error) {
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.Errorf(`invalid public key size: expected %d bytes, got %d`, ed25519.PublicKeySize, len(x))
+	}
+
+	// little endian, with the sign bit of the x coordinate removed
+	buf := make([]byte, len(x))
+	for i := range x {
+		buf[len(x)-1-i] = x[i]
+	}
+	buf[0] &= 0x7f
+	y := new(big.Int).SetBytes(buf)
+	if y.Cmp(curve25519P) >= 0 {
+		return nil, errors.New(`invalid Ed25519 public key`)
+	}
+
+	one := big.NewInt(1)
+	denom := new(big.Int).Sub(one, y)
+	denom.Mod(denom, curve25519P)
+	if denom.Sign() == 0 {
+		return nil, errors.New(`invalid Ed25519 public key`)
+	}
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, denom.ModInverse(denom, curve25519P))
+	u.Mod(u, curve25519P)
+
+	out := make([]byte, x25519.PublicKeySize)
+	u.FillBytes(out)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
@@ -6,19 +6,30 @@ import (
 
 	"github.com/lestrrat-go/backoff/v2"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/option"
 )
 
 type Option = option.Interface
 
+type identClientCredentials struct{}
 type identHTTPClient struct{}
 type identThumbprintHash struct{}
 type identRefreshInterval struct{}
 type identMinRefreshInterval struct{}
+type identKeyChangeNotification struct{}
+type identSignatureVerifier struct{}
+type identKeySetParser struct{}
 type identFetchBackoff struct{}
 type identPEM struct{}
+type identStrictAlgorithm struct{}
 type identTypedField struct{}
 type identLocalRegistry struct{}
+type identKeySize struct{}
+type identCurve struct{}
+type identKeyIDStrategy struct{}
+type identAutoKeyID struct{}
+type identMergeFiles struct{}
 
 // AutoRefreshOption is a type of Option that can be passed to the
 // AutoRefresh object.
@@ -48,9 +59,23 @@ type fetchOption struct {
 func (*fetchOption) autoRefreshOption() {}
 func (*fetchOption) fetchOption()       {}
 
+// ParseFSOption is a type of Option that can be passed to `jwk.ParseFS()`
+type ParseFSOption interface {
+	Option
+	parseFSOption()
+}
+
+type parseFSOption struct {
+	Option
+}
+
+func (*parseFSOption) parseFSOption() {}
+
 // ParseOption is a type of Option that can be passed to `jwk.Parse()`
+// This type also implements the `ReadFileOption` and `ParseFSOption`
 type ParseOption interface {
 	ReadFileOption
+	ParseFSOption
 	parseOption()
 }
 
@@ -60,6 +85,32 @@ type parseOption struct {
 
 func (*parseOption) parseOption()    {}
 func (*parseOption) readFileOption() {}
+func (*parseOption) parseFSOption()  {}
+
+// GenerateOption is a type of Option that can be passed to
+// `jwk.GenerateKey()` and `jwk.GenerateKeyWithReader()`
+type GenerateOption interface {
+	Option
+	generateOption()
+}
+
+type generateOption struct {
+	Option
+}
+
+func (*generateOption) generateOption() {}
+
+// SetOption is a type of Option that can be passed to `jwk.NewSet()`
+type SetOption interface {
+	Option
+	setOption()
+}
+
+type setOption struct {
+	Option
+}
+
+func (*setOption) setOption() {}
 
 // WithHTTPClient allows users to specify the "net/http".Client object that
 // is used when fetching jwk.Set objects.
@@ -67,6 +118,16 @@ func WithHTTPClient(cl HTTPClient) FetchOption {
 	return &fetchOption{option.New(identHTTPClient{}, cl)}
 }
 
+// WithClientCredentials specifies that the JWKS endpoint requires
+// authentication using an OAuth 2.0 access token, which is obtained
+// via the client credentials grant. See `jwk.ClientCredentials` for details.
+//
+// The token endpoint is accessed using the same HTTP client as the
+// JWKS endpoint.
+func WithClientCredentials(cc *ClientCredentials) FetchOption {
+	return &fetchOption{option.New(identClientCredentials{}, cc)}
+}
+
 // WithFetchBackoff specifies the backoff policy to use when
 // refreshing a JWKS from a remote server fails.
 //
@@ -76,6 +137,45 @@ func WithFetchBackoff(v backoff.Policy) FetchOption {
 	return &fetchOption{option.New(identFetchBackoff{}, v)}
 }
 
+// WithSignatureVerifier specifies that the remote resource is a signed
+// JWK set document, whose signature must be verified using `v` before
+// the keys are used. See `jwk.ParseSigned()` for details.
+//
+// When passed to `(*jwk.AutoRefresh).Configure()`, the signature is
+// verified on every refresh. If the verification fails, the previously
+// fetched key set is kept.
+func WithSignatureVerifier(v SignatureVerifier) FetchOption {
+	return &fetchOption{option.New(identSignatureVerifier{}, v)}
+}
+
+// WithKeySetParser specifies that the remote resource is not a JWK set
+// document, and that `p` should be used to convert it into a jwk.Set.
+// See `jwk.ParsePEMMap()` and `jwk.PEMParser()` for parsers for some
+// of the formats in common use.
+//
+// When combined with `jwk.WithSignatureVerifier()`, the parser is
+// applied to the verified payload.
+func WithKeySetParser(p KeySetParser) FetchOption {
+	return &fetchOption{option.New(identKeySetParser{}, p)}
+}
+
+// WithKeyIDStrategy specifies the strategy that `jwk.AssignKeyID()`
+// uses to compute the "kid".
+func WithKeyIDStrategy(s KeyIDStrategy) Option {
+	return option.New(identKeyIDStrategy{}, s)
+}
+
+// WithAutoKeyID specifies that keys without a "kid" that are added to
+// the set via `Add()` are assigned one using the strategy `s`, as if
+// `jwk.AssignKeyID()` was called. Note that this modifies the key.
+//
+// If the "kid" cannot be computed (e.g. jwk.KidStrategyX5T is used
+// for a key without a certificate), the key is not added, and `Add()`
+// returns false.
+func WithAutoKeyID(s KeyIDStrategy) SetOption {
+	return &setOption{option.New(identAutoKeyID{}, s)}
+}
+
 func WithThumbprintHash(h crypto.Hash) Option {
 	return option.New(identThumbprintHash{}, h)
 }
@@ -117,6 +217,20 @@ func WithMinRefreshInterval(d time.Duration) AutoRefreshOption {
 	}
 }
 
+// WithKeyChangeNotification specifies a function that is called when
+// a refresh of the key set by `jwk.AutoRefresh` returns a set that differs
+// from the previous one, as computed by `jwk.Diff()`. This can be used to
+// log or audit key rotations.
+//
+// The handler is not called for the initial fetch of the key set. It is
+// called synchronously from the goroutine that performed the refresh, so
+// it should return quickly.
+func WithKeyChangeNotification(h KeyChangeHandler) AutoRefreshOption {
+	return &autoRefreshOption{
+		option.New(identKeyChangeNotification{}, h),
+	}
+}
+
 // WithPEM specifies that the input to `Parse()` is a PEM encoded key.
 func WithPEM(v bool) ParseOption {
 	return &parseOption{
@@ -124,6 +238,15 @@ func WithPEM(v bool) ParseOption {
 	}
 }
 
+// WithStrictAlgorithm specifies that `jwk.Parse()` and `jwk.ParseKey()`
+// should reject keys whose "alg" field contradicts the other parameters
+// of the key, as described in `jwk.CheckAlgorithm()`.
+func WithStrictAlgorithm(v bool) ParseOption {
+	return &parseOption{
+		option.New(identStrictAlgorithm{}, v),
+	}
+}
+
 type typedFieldPair struct {
 	Name  string
 	Value interface{}
@@ -159,3 +282,19 @@ func WithTypedField(name string, object interface{}) ParseOption {
 func withLocalRegistry(r *json.Registry) ParseOption {
 	return &parseOption{option.New(identLocalRegistry{}, r)}
 }
+
+// WithKeySize specifies the size of the key to generate in bits, for
+// RSA and symmetric keys. For symmetric keys, the size must be a
+// multiple of 8.
+//
+// If unspecified, RSA keys are 2048 bits and symmetric keys are 256 bits.
+func WithKeySize(bits int) GenerateOption {
+	return &generateOption{option.New(identKeySize{}, bits)}
+}
+
+// WithCurve specifies the curve of the key to generate, for EC and OKP keys.
+//
+// If unspecified, EC keys use jwa.P256 and OKP keys use jwa.Ed25519.
+func WithCurve(crv jwa.EllipticCurveAlgorithm) GenerateOption {
+	return &generateOption{option.New(identCurve{}, crv)}
+}
@@ -0,0 +1,129 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+
+	"github.com/lestrrat-go/jwx/internal/pkcs12"
+	"github.com/pkg/errors"
+)
+
+// ParsePKCS12 parses a PKCS#12 (.p12/.pfx) file protected by `password`,
+// and returns the private key contained in it as a jwk.Key. The
+// certificates in the file are stored in the "x5c" field of the key,
+// starting with the certificate for the private key, followed by the
+// rest of the chain.
+//
+// Files with exactly one private key are supported. Both files using
+// PBES2 (the default since OpenSSL 3) and the legacy encryption schemes
+// are supported, although legacy files may only contain RSA or EC keys.
+func ParsePKCS12(data []byte, password string) (Key, error) {
+	rawKey, certs, err := pkcs12.Decode(data, password)
+	if err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to decode PKCS#12 data`))
+	}
+
+	key, err := New(rawKey)
+	if err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to create jwk.Key from private key`))
+	}
+
+	if len(certs) > 0 {
+		chain, err := orderCertificateChain(rawKey, certs)
+		if err != nil {
+			return nil, newParseError(err)
+		}
+		if err := key.Set(X509CertChainKey, chain); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, X509CertChainKey)
+		}
+	}
+	return key, nil
+}
+
+// orderCertificateChain puts the certificate for the private key first,
+// followed by its issuer, the issuer's issuer, and so on. Certificates
+// that are not part of the chain are appended at the end.
+func orderCertificateChain(rawKey interface{}, certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	signer, ok := rawKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf(`failed to retrieve public key from %T`, rawKey)
+	}
+	pubkey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return nil, errors.Errorf(`failed to compare public key of %T`, rawKey)
+	}
+
+	remaining := make([]*x509.Certificate, 0, len(certs))
+	var chain []*x509.Certificate
+	for _, cert := range certs {
+		if chain == nil && pubkey.Equal(cert.PublicKey) {
+			chain = append(chain, cert)
+			continue
+		}
+		remaining = append(remaining, cert)
+	}
+	if chain == nil {
+		return nil, errors.New(`no certificate matches the private key`)
+	}
+
+	for len(remaining) > 0 {
+		last := chain[len(chain)-1]
+		if bytes.Equal(last.RawIssuer, last.RawSubject) {
+			break
+		}
+		found := -1
+		for i, cert := range remaining {
+			if bytes.Equal(cert.RawSubject, last.RawIssuer) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			break
+		}
+		chain = append(chain, remaining[found])
+		remaining = append(remaining[:found], remaining[found+1:]...)
+	}
+	return append(chain, remaining...), nil
+}
+
+// EncodePKCS12 creates a PKCS#12 (.p12/.pfx) file protected by `password`,
+// which contains the private key and the certificates stored in its
+// "x5c" field. The first certificate in "x5c" must be the certificate
+// for the private key.
+//
+// The file is encrypted using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC,
+// and authenticated with HMAC-SHA256, which is the default of OpenSSL 3.
+// Older software that only supports the legacy encryption schemes may not
+// be able to read it.
+func EncodePKCS12(key Key, password string) ([]byte, error) {
+	switch key.(type) {
+	case RSAPrivateKey, ECDSAPrivateKey, OKPPrivateKey:
+	default:
+		return nil, errors.Errorf(`private key is required to create a PKCS#12 file, got %T`, key)
+	}
+
+	var rawKey interface{}
+	if err := key.Raw(&rawKey); err != nil {
+		return nil, errors.Wrap(err, `failed to get raw key from jwk.Key`)
+	}
+
+	certs := key.X509CertChain()
+	if len(certs) > 0 {
+		signer, ok := rawKey.(crypto.Signer)
+		if !ok {
+			return nil, errors.Errorf(`failed to retrieve public key from %T`, rawKey)
+		}
+		pubkey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+		if !ok || !pubkey.Equal(certs[0].PublicKey) {
+			return nil, errors.Errorf(`the first certificate in %q does not match the private key`, X509CertChainKey)
+		}
+	}
+
+	buf, err := pkcs12.Encode(rawKey, certs, password)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode PKCS#12 data`)
+	}
+	return buf, nil
+}
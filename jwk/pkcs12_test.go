@@ -0,0 +1,142 @@
+package jwk_test
+
+import (
+	"crypto/rsa"
+	"io/ioutil"
+	"testing"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func assertSameKey(t *testing.T, expected, actual jwk.Key) bool {
+	t.Helper()
+	expectedJSON, err := json.Marshal(expected)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return false
+	}
+	actualJSON, err := json.Marshal(actual)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return false
+	}
+	return assert.Equal(t, string(expectedJSON), string(actualJSON), `keys should match`)
+}
+
+func TestPKCS12(t *testing.T) {
+	t.Parallel()
+
+	// The test files were created by OpenSSL 3 using
+	//   openssl pkcs12 -export [-legacy] -inkey leaf.key -in leaf.crt -certfile ca.crt -passout pass:password
+	for _, name := range []string{`pkcs12_pbes2.p12`, `pkcs12_legacy.p12`} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			data, err := ioutil.ReadFile(`testdata/` + name)
+			if !assert.NoError(t, err, `ioutil.ReadFile should succeed`) {
+				return
+			}
+
+			key, err := jwk.ParsePKCS12(data, `password`)
+			if !assert.NoError(t, err, `jwk.ParsePKCS12 should succeed`) {
+				return
+			}
+			if !assert.Implements(t, (*jwk.RSAPrivateKey)(nil), key, `key should be an RSA private key`) {
+				return
+			}
+			certs := key.X509CertChain()
+			if !assert.Len(t, certs, 2, `"x5c" should contain the chain`) {
+				return
+			}
+			if !assert.Equal(t, `jwx test leaf`, certs[0].Subject.CommonName, `leaf certificate should come first`) {
+				return
+			}
+			if !assert.Equal(t, `jwx test CA`, certs[1].Subject.CommonName, `CA certificate should come last`) {
+				return
+			}
+
+			var raw rsa.PrivateKey
+			if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+				return
+			}
+			if !assert.True(t, raw.PublicKey.Equal(certs[0].PublicKey), `leaf certificate should match the key`) {
+				return
+			}
+
+			_, err = jwk.ParsePKCS12(data, `wrong password`)
+			if !assert.Error(t, err, `jwk.ParsePKCS12 should fail with wrong password`) {
+				return
+			}
+			if !assert.True(t, errors.Is(err, jwx.ErrParse), `error should be jwx.ErrParse`) {
+				return
+			}
+
+			// Round trip, keeping the chain
+			encoded, err := jwk.EncodePKCS12(key, `new password`)
+			if !assert.NoError(t, err, `jwk.EncodePKCS12 should succeed`) {
+				return
+			}
+			parsed, err := jwk.ParsePKCS12(encoded, `new password`)
+			if !assert.NoError(t, err, `jwk.ParsePKCS12 should succeed`) {
+				return
+			}
+			if !assertSameKey(t, key, parsed) {
+				return
+			}
+		})
+	}
+
+	t.Run("Round trip", func(t *testing.T) {
+		t.Parallel()
+		eckey, err := jwxtest.GenerateEcdsaKey(jwa.P384)
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+			return
+		}
+		edkey, err := jwxtest.GenerateEd25519Key()
+		if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+			return
+		}
+		for _, raw := range []interface{}{eckey, edkey} {
+			key, err := jwk.New(raw)
+			if !assert.NoError(t, err, `jwk.New should succeed`) {
+				return
+			}
+			for _, password := range []string{``, `pässwörd`} {
+				encoded, err := jwk.EncodePKCS12(key, password)
+				if !assert.NoError(t, err, `jwk.EncodePKCS12 should succeed`) {
+					return
+				}
+				parsed, err := jwk.ParsePKCS12(encoded, password)
+				if !assert.NoError(t, err, `jwk.ParsePKCS12 should succeed`) {
+					return
+				}
+				if !assertSameKey(t, key, parsed) {
+					return
+				}
+				if !assert.Empty(t, parsed.X509CertChain(), `"x5c" should be empty`) {
+					return
+				}
+			}
+		}
+	})
+
+	t.Run("Public key", func(t *testing.T) {
+		t.Parallel()
+		rsakey, err := jwxtest.GenerateRsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+			return
+		}
+		pubkey, err := jwk.PublicKeyOf(rsakey)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+		_, err = jwk.EncodePKCS12(pubkey, `password`)
+		if !assert.Error(t, err, `jwk.EncodePKCS12 should fail for public keys`) {
+			return
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/lestrrat-go/backoff/v2"
 	"github.com/lestrrat-go/httpcc"
+	"github.com/lestrrat-go/jwx/internal/hook"
 	"github.com/pkg/errors"
 )
 
@@ -28,14 +29,23 @@ import (
 type AutoRefresh struct {
 	cache        map[string]Set
 	configureCh  chan struct{}
+	invalidated  map[string]Set
 	fetching     map[string]chan struct{}
 	muCache      sync.RWMutex
 	muFetching   sync.Mutex
 	muRegistry   sync.RWMutex
 	registry     map[string]*target
 	resetTimerCh chan *resetTimerReq
+
+	muSubscribers sync.Mutex
+	subscribers   map[string][]chan KeySetEvent
+	closed        bool
 }
 
+// subscriberBufferSize is the number of events that can be queued
+// for a subscriber before further events are dropped
+const subscriberBufferSize = 8
+
 type target struct {
 	// The backoff policy to use when fetching the JWKS fails
 	backoff backoff.Policy
@@ -44,6 +54,14 @@ type target struct {
 	// aware of HTTP caching, or one that goes through a proxy
 	httpcl HTTPClient
 
+	// The parser used to convert the resource into a key set, if it
+	// is not a JWK set document
+	parser KeySetParser
+
+	// The credentials used to obtain access tokens for the JWKS endpoint,
+	// if it requires authentication
+	credentials *ClientCredentials
+
 	// Interval between refreshes are calculated two ways.
 	// 1) You can set an explicit refresh interval by using WithRefreshInterval().
 	//    In this mode, it doesn't matter what the HTTP response says in its
@@ -73,6 +91,12 @@ type target struct {
 
 	url string
 
+	// Verifies the signature of signed JWK set documents, if specified
+	verifier SignatureVerifier
+
+	// Called when a refresh yields a key set that differs from the previous one
+	keyChangeHandler KeyChangeHandler
+
 	// The timer for refreshing the keyset. should not be set by anyone
 	// other than the refreshing goroutine
 	timer *time.Timer
@@ -111,9 +135,11 @@ func NewAutoRefresh(ctx context.Context) *AutoRefresh {
 	af := &AutoRefresh{
 		cache:        make(map[string]Set),
 		configureCh:  make(chan struct{}),
+		invalidated:  make(map[string]Set),
 		fetching:     make(map[string]chan struct{}),
 		registry:     make(map[string]*target),
 		resetTimerCh: make(chan *resetTimerReq),
+		subscribers:  make(map[string][]chan KeySetEvent),
 	}
 	go af.refreshLoop(ctx)
 	return af
@@ -150,10 +176,16 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 	var hasRefreshInterval bool
 	var refreshInterval time.Duration
 	minRefreshInterval := time.Hour
+	var keyChangeHandler KeyChangeHandler
+	var verifier SignatureVerifier
+	var parser KeySetParser
+	var credentials *ClientCredentials
 	bo := backoff.Null()
 	for _, option := range options {
 		//nolint:forcetypeassert
 		switch option.Ident() {
+		case identClientCredentials{}:
+			credentials = option.Value().(*ClientCredentials)
 		case identFetchBackoff{}:
 			bo = option.Value().(backoff.Policy)
 		case identRefreshInterval{}:
@@ -163,6 +195,12 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 			minRefreshInterval = option.Value().(time.Duration)
 		case identHTTPClient{}:
 			httpcl = option.Value().(HTTPClient)
+		case identKeyChangeNotification{}:
+			keyChangeHandler = option.Value().(KeyChangeHandler)
+		case identSignatureVerifier{}:
+			verifier = option.Value().(SignatureVerifier)
+		case identKeySetParser{}:
+			parser = option.Value().(KeySetParser)
 		}
 	}
 
@@ -170,6 +208,13 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 	af.muRegistry.Lock()
 	t, ok := af.registry[url]
 	if ok {
+		// The handler does not affect the refresh schedule, so
+		// there is no need to reconfigure
+		t.keyChangeHandler = keyChangeHandler
+		t.verifier = verifier
+		t.parser = parser
+		t.credentials = credentials
+
 		if t.httpcl != httpcl {
 			t.httpcl = httpcl
 			doReconfigure = true
@@ -199,6 +244,10 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 			backoff:            bo,
 			httpcl:             httpcl,
 			minRefreshInterval: minRefreshInterval,
+			keyChangeHandler:   keyChangeHandler,
+			verifier:           verifier,
+			parser:             parser,
+			credentials:        credentials,
 			url:                url,
 			sem:                make(chan struct{}, 1),
 			// This is a placeholder timer so we can call Reset() on it later
@@ -271,6 +320,28 @@ func (af *AutoRefresh) Fetch(ctx context.Context, url string) (Set, error) {
 	return af.refresh(ctx, url)
 }
 
+// Invalidate drops the jwk.Set cached for `url`, so that the next call
+// to `Fetch()` performs an HTTP request synchronously. The url stays
+// registered, and its options and subscribers are kept. This is useful
+// when an out-of-band signal, such as a webhook from the identity
+// provider, indicates that the keys have been rotated.
+//
+// Note that until the key set has been fetched again, `Fetch()` returns
+// an error if the HTTP request fails, as there is no stale key set to
+// fall back to. The dropped key set is still used to detect changes, so
+// the first refresh after invalidation notifies subscribers and the
+// handler given in `jwk.WithKeyChangeNotification()` as usual.
+//
+// It is a no-op if `url` has not been fetched yet.
+func (af *AutoRefresh) Invalidate(url string) {
+	af.muCache.Lock()
+	defer af.muCache.Unlock()
+	if ks, ok := af.cache[url]; ok {
+		af.invalidated[url] = ks
+		delete(af.cache, url)
+	}
+}
+
 // Refresh is the same as Fetch(), except that HTTP fetching is done synchronously.
 //
 // This is useful when you want to force an HTTP fetch instead of waiting
@@ -284,6 +355,32 @@ func (af *AutoRefresh) Refresh(ctx context.Context, url string) (Set, error) {
 	return af.refresh(ctx, url)
 }
 
+// TriggerRefresh schedules an immediate refresh of the key set at `url`
+// in the background, and returns without waiting for it to complete.
+// This is useful when an out-of-band signal, such as a webhook from the
+// identity provider, indicates that the keys have been rotated.
+//
+// The refresh is performed the same way as those triggered by the timer:
+// if a refresh for `url` is already in progress, no new refresh is
+// started, and the backoff policy specified in `jwk.WithFetchBackoff()`
+// is used when the request fails. Consumers calling `Fetch()` keep
+// receiving the cached key set until the refresh succeeds.
+//
+// The context is only used to abort scheduling the refresh.
+func (af *AutoRefresh) TriggerRefresh(ctx context.Context, url string) error {
+	t, ok := af.getRegistered(url)
+	if !ok {
+		return errors.Errorf(`url %s must be configured using "Configure()" first`, url)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case af.resetTimerCh <- &resetTimerReq{t: t, d: 0}:
+	}
+	return nil
+}
+
 func (af *AutoRefresh) refresh(ctx context.Context, url string) (Set, error) {
 	// To avoid a thundering herd, only one goroutine per url may enter into this
 	// initial fetch phase.
@@ -323,6 +420,8 @@ func (af *AutoRefresh) refresh(ctx context.Context, url string) (Set, error) {
 
 // Keeps looping, while refreshing the KeySet.
 func (af *AutoRefresh) refreshLoop(ctx context.Context) {
+	defer af.closeSubscribers()
+
 	// reflect.Select() is slow IF we are executing it over and over
 	// in a very fast iteration, but we assume here that refreshes happen
 	// seldom enough that being able to call one `select{}` with multiple
@@ -430,6 +529,16 @@ func (af *AutoRefresh) refreshLoop(ctx context.Context) {
 func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableBackoff bool) error {
 	af.muRegistry.RLock()
 	t, ok := af.registry[url]
+	var keyChangeHandler KeyChangeHandler
+	var verifier SignatureVerifier
+	var parser KeySetParser
+	var credentials *ClientCredentials
+	if ok {
+		keyChangeHandler = t.keyChangeHandler
+		verifier = t.verifier
+		parser = t.parser
+		credentials = t.credentials
+	}
 	af.muRegistry.RUnlock()
 
 	if !ok {
@@ -440,19 +549,47 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 	// we want to retry. Create a backoff object,
 
 	options := []FetchOption{WithHTTPClient(t.httpcl)}
+	if credentials != nil {
+		options = append(options, WithClientCredentials(credentials))
+	}
 	if enableBackoff {
 		options = append(options, WithFetchBackoff(t.backoff))
 	}
 
+	span := hook.Start(hook.FetchKind)
+	span.SetURL(url)
 	res, err := fetch(ctx, url, options...)
 	if err == nil {
 		defer res.Body.Close()
-		keyset, parseErr := ParseReader(res.Body)
+		keyset, parseErr := parseFetched(res.Body, verifier, parser)
 		if parseErr == nil {
+			span.End(nil)
+
 			// Got a new key set. replace the keyset in the target
 			af.muCache.Lock()
+			prev, hasPrev := af.cache[url]
+			if !hasPrev {
+				prev, hasPrev = af.invalidated[url]
+				delete(af.invalidated, url)
+			}
 			af.cache[url] = keyset
 			af.muCache.Unlock()
+
+			if hasPrev && (keyChangeHandler != nil || af.hasSubscribers(url)) {
+				added, removed, changed := Diff(prev, keyset)
+				if len(added) > 0 || len(removed) > 0 || len(changed) > 0 {
+					ev := KeySetEvent{
+						URL:     url,
+						Added:   added,
+						Removed: removed,
+						Changed: changed,
+					}
+					if keyChangeHandler != nil {
+						keyChangeHandler(ev)
+					}
+					af.publish(ev)
+				}
+			}
 			nextInterval := calculateRefreshDuration(res, t.refreshInterval, t.minRefreshInterval)
 			rtr := &resetTimerReq{
 				t: t,
@@ -472,6 +609,7 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 		}
 		err = parseErr
 	}
+	span.End(err)
 	t.lastError = err
 
 	// We either failed to perform the HTTP GET, or we failed to parse the
@@ -493,6 +631,82 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 	return err
 }
 
+// Subscribe returns a channel that receives an event each time a refresh
+// of the key set at `url` produces a set that differs from the previously
+// cached one. This can be used to promptly invalidate data that depends on
+// the keys, such as verification caches.
+//
+// No event is sent for the initial fetch of the key set. Events are sent
+// without blocking the refresh: if the subscriber does not keep up and the
+// channel's buffer is full, further events are dropped until it is drained.
+//
+// The channel is closed when `(*jwk.AutoRefresh).Unsubscribe()` is called
+// with it, or when the context passed to `jwk.NewAutoRefresh()` is canceled.
+func (af *AutoRefresh) Subscribe(url string) <-chan KeySetEvent {
+	ch := make(chan KeySetEvent, subscriberBufferSize)
+
+	af.muSubscribers.Lock()
+	defer af.muSubscribers.Unlock()
+	if af.closed {
+		close(ch)
+		return ch
+	}
+	af.subscribers[url] = append(af.subscribers[url], ch)
+	return ch
+}
+
+// Unsubscribe stops the delivery of events to a channel obtained via
+// `(*jwk.AutoRefresh).Subscribe()`, and closes it. It is a no-op if the
+// channel is not subscribed to `url`.
+func (af *AutoRefresh) Unsubscribe(url string, sub <-chan KeySetEvent) {
+	af.muSubscribers.Lock()
+	defer af.muSubscribers.Unlock()
+
+	list := af.subscribers[url]
+	for i, ch := range list {
+		if ch != sub {
+			continue
+		}
+		close(ch)
+		list = append(list[:i], list[i+1:]...)
+		if len(list) == 0 {
+			delete(af.subscribers, url)
+		} else {
+			af.subscribers[url] = list
+		}
+		return
+	}
+}
+
+func (af *AutoRefresh) hasSubscribers(url string) bool {
+	af.muSubscribers.Lock()
+	defer af.muSubscribers.Unlock()
+	return len(af.subscribers[url]) > 0
+}
+
+func (af *AutoRefresh) publish(ev KeySetEvent) {
+	af.muSubscribers.Lock()
+	defer af.muSubscribers.Unlock()
+	for _, ch := range af.subscribers[ev.URL] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (af *AutoRefresh) closeSubscribers() {
+	af.muSubscribers.Lock()
+	defer af.muSubscribers.Unlock()
+	for url, list := range af.subscribers {
+		for _, ch := range list {
+			close(ch)
+		}
+		delete(af.subscribers, url)
+	}
+	af.closed = true
+}
+
 func calculateRefreshDuration(res *http.Response, refreshInterval *time.Duration, minRefreshInterval time.Duration) time.Duration {
 	// This always has precedence
 	if refreshInterval != nil {
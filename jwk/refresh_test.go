@@ -3,7 +3,11 @@ package jwk_test
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -13,7 +17,10 @@ import (
 	"github.com/lestrrat-go/backoff/v2"
 	"github.com/lestrrat-go/iter/arrayiter"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -302,3 +309,608 @@ func TestRefreshSnapshotWithErrors(t *testing.T) {
 		assert.Error(t, target.LastError, "last error in snapshot should not be nil")
 	}
 }
+
+func TestKeyChangeNotification(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	set := jwk.NewSet()
+	generate := func(kid string) jwk.Key {
+		k, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			t.FailNow()
+		}
+		_ = k.Set(jwk.KeyIDKey, kid)
+		return k
+	}
+	set.Add(generate("old"))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	defer srv.Close()
+
+	var events []jwk.KeySetEvent
+	ar := jwk.NewAutoRefresh(ctx)
+	ar.Configure(srv.URL, jwk.WithKeyChangeNotification(func(ev jwk.KeySetEvent) {
+		events = append(events, ev)
+	}))
+
+	if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+	if !assert.Empty(t, events, `initial fetch should not trigger a notification`) {
+		return
+	}
+
+	if _, err := ar.Refresh(ctx, srv.URL); !assert.NoError(t, err, `ar.Refresh should succeed`) {
+		return
+	}
+	if !assert.Empty(t, events, `unchanged key set should not trigger a notification`) {
+		return
+	}
+
+	mu.Lock()
+	set.Add(generate("new"))
+	mu.Unlock()
+
+	if _, err := ar.Refresh(ctx, srv.URL); !assert.NoError(t, err, `ar.Refresh should succeed`) {
+		return
+	}
+	if !assert.Len(t, events, 1, `rotated key set should trigger a notification`) {
+		return
+	}
+	if !assert.Equal(t, srv.URL, events[0].URL, `URL should match`) {
+		return
+	}
+	if !assert.Len(t, events[0].Added, 1, `one key should be added`) {
+		return
+	}
+	if !assert.Equal(t, "new", events[0].Added[0].KeyID(), `added key should match`) {
+		return
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	set := jwk.NewSet()
+	generate := func(kid string) jwk.Key {
+		k, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			t.FailNow()
+		}
+		_ = k.Set(jwk.KeyIDKey, kid)
+		return k
+	}
+	old := generate("old")
+	set.Add(old)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	defer srv.Close()
+
+	ar := jwk.NewAutoRefresh(ctx)
+	ar.Configure(srv.URL)
+	sub := ar.Subscribe(srv.URL)
+	other := ar.Subscribe(srv.URL)
+	ar.Unsubscribe(srv.URL, other)
+	if _, ok := <-other; !assert.False(t, ok, `unsubscribed channel should be closed`) {
+		return
+	}
+
+	if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+
+	mu.Lock()
+	set.Remove(old)
+	set.Add(generate("new"))
+	mu.Unlock()
+
+	if _, err := ar.Refresh(ctx, srv.URL); !assert.NoError(t, err, `ar.Refresh should succeed`) {
+		return
+	}
+
+	select {
+	case ev := <-sub:
+		if !assert.Len(t, ev.Added, 1, `one key should be added`) {
+			return
+		}
+		if !assert.Equal(t, "new", ev.Added[0].KeyID(), `added key should match`) {
+			return
+		}
+		if !assert.Len(t, ev.Removed, 1, `one key should be removed`) {
+			return
+		}
+		if !assert.Equal(t, "old", ev.Removed[0].KeyID(), `removed key should match`) {
+			return
+		}
+	default:
+		t.Errorf(`an event should have been delivered`)
+		return
+	}
+
+	cancel()
+	select {
+	case _, ok := <-sub:
+		assert.False(t, ok, `channel should be closed when the context is canceled`)
+	case <-time.After(5 * time.Second):
+		t.Errorf(`channel should be closed when the context is canceled`)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var accessCount int
+	set := jwk.NewSet()
+	old, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `key generation should succeed`) {
+		return
+	}
+	_ = old.Set(jwk.KeyIDKey, "old")
+	set.Add(old)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		accessCount++
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	defer srv.Close()
+
+	ar := jwk.NewAutoRefresh(ctx)
+	ar.Configure(srv.URL, jwk.WithRefreshInterval(time.Hour))
+	sub := ar.Subscribe(srv.URL)
+
+	// no-op before the first fetch
+	ar.Invalidate(srv.URL)
+
+	for i := 0; i < 2; i++ {
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+	}
+
+	mu.Lock()
+	if !assert.Equal(t, 1, accessCount, `cached key set should be used`) {
+		mu.Unlock()
+		return
+	}
+	set.Remove(old)
+	newKey, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `key generation should succeed`) {
+		mu.Unlock()
+		return
+	}
+	_ = newKey.Set(jwk.KeyIDKey, "new")
+	set.Add(newKey)
+	mu.Unlock()
+
+	ar.Invalidate(srv.URL)
+	fetched, err := ar.Fetch(ctx, srv.URL)
+	if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+	if _, ok := fetched.LookupKeyID("new"); !assert.True(t, ok, `rotated key should be available`) {
+		return
+	}
+
+	mu.Lock()
+	count := accessCount
+	mu.Unlock()
+	if !assert.Equal(t, 2, count, `ar.Fetch should hit the network after ar.Invalidate`) {
+		return
+	}
+
+	select {
+	case ev := <-sub:
+		if !assert.Len(t, ev.Added, 1, `one key should be added`) {
+			return
+		}
+		if !assert.Len(t, ev.Removed, 1, `one key should be removed`) {
+			return
+		}
+	default:
+		t.Errorf(`an event should have been delivered`)
+	}
+}
+
+func TestTriggerRefresh(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var accessCount int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		accessCount++
+
+		key := map[string]interface{}{
+			"kty":         "EC",
+			"crv":         "P-256",
+			"x":           "SVqB4JcUD6lsfvqMr-OKUNUphdNn64Eay60978ZlL74",
+			"y":           "lf0u0pMj4lGAzZix5u4Cm5CMQIgMNpkwy163wtKYVKI",
+			"accessCount": accessCount,
+		}
+		hdrs := w.Header()
+		hdrs.Set(`Content-Type`, `application/json`)
+		hdrs.Set(`Cache-Control`, `max-age=7200`)
+		_ = json.NewEncoder(w).Encode(key)
+	}))
+	defer srv.Close()
+
+	ar := jwk.NewAutoRefresh(ctx)
+	if !assert.Error(t, ar.TriggerRefresh(ctx, srv.URL), `ar.TriggerRefresh should fail for unregistered url`) {
+		return
+	}
+
+	ar.Configure(srv.URL, jwk.WithMinRefreshInterval(time.Hour))
+	set, err := ar.Fetch(ctx, srv.URL)
+	if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+	if !checkAccessCount(t, ctx, set, 1) {
+		return
+	}
+
+	if !assert.NoError(t, ar.TriggerRefresh(ctx, srv.URL), `ar.TriggerRefresh should succeed`) {
+		return
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		set, err := ar.Fetch(ctx, srv.URL)
+		if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+		iter := set.Iterate(ctx)
+		iter.Next(ctx)
+		//nolint:forcetypeassert
+		if v, _ := iter.Pair().Value.(jwk.Key).Get(`accessCount`); v == float64(2) {
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Errorf(`key set should have been refreshed in the background`)
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestSignedSet(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	otherKey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	verifier := jwk.SignatureVerifierFunc(func(buf []byte) ([]byte, error) {
+		return jws.Verify(buf, jwa.RS256, &signingKey.PublicKey)
+	})
+
+	newSignedSet := func(t *testing.T, key interface{}, kids ...string) []byte {
+		t.Helper()
+		set := jwk.NewSet()
+		for _, kid := range kids {
+			k, err := jwxtest.GenerateSymmetricJwk()
+			if !assert.NoError(t, err, `key generation should succeed`) {
+				t.FailNow()
+			}
+			_ = k.Set(jwk.KeyIDKey, kid)
+			set.Add(k)
+		}
+		payload, err := json.Marshal(set)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			t.FailNow()
+		}
+		signed, err := jws.Sign(payload, jwa.RS256, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("ParseSigned", func(t *testing.T) {
+		t.Parallel()
+		set, err := jwk.ParseSigned(newSignedSet(t, signingKey, "a", "b"), verifier)
+		if !assert.NoError(t, err, `jwk.ParseSigned should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, set.Len(), `set should contain 2 keys`) {
+			return
+		}
+
+		_, err = jwk.ParseSigned(newSignedSet(t, otherKey, "a"), verifier)
+		if !assert.Error(t, err, `jwk.ParseSigned should fail for invalid signatures`) {
+			return
+		}
+	})
+	t.Run("AutoRefresh", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var mu sync.Mutex
+		served := newSignedSet(t, signingKey, "a")
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			_, _ = w.Write(served)
+		}))
+		defer srv.Close()
+
+		if _, err := jwk.Fetch(ctx, srv.URL); !assert.Error(t, err, `jwk.Fetch should fail without a verifier`) {
+			return
+		}
+		set, err := jwk.Fetch(ctx, srv.URL, jwk.WithSignatureVerifier(verifier))
+		if !assert.NoError(t, err, `jwk.Fetch should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1, set.Len(), `set should contain 1 key`) {
+			return
+		}
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL, jwk.WithSignatureVerifier(verifier))
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+
+		// A document signed by an untrusted key must not replace the keys
+		mu.Lock()
+		served = newSignedSet(t, otherKey, "a", "b")
+		mu.Unlock()
+		if _, err := ar.Refresh(ctx, srv.URL); !assert.Error(t, err, `ar.Refresh should fail`) {
+			return
+		}
+		set, err = ar.Fetch(ctx, srv.URL)
+		if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1, set.Len(), `previous key set should be kept`) {
+			return
+		}
+	})
+}
+
+func TestClientCredentials(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `key generation should succeed`) {
+		return
+	}
+	set := jwk.NewSet()
+	set.Add(key)
+	served, err := json.Marshal(set)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+
+	var mu sync.Mutex
+	var issued int
+	var valid string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != `client` || secret != `secret` || r.FormValue(`grant_type`) != `client_credentials` || r.FormValue(`scope`) != `jwks.read` {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		issued++
+		valid = fmt.Sprintf(`token-%d`, issued)
+		w.Header().Set(`Content-Type`, `application/json`)
+		_, _ = fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":3600}`, valid)
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get(`Authorization`) != `Bearer `+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write(served)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tokenCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return issued
+	}
+	revoke := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		valid = ``
+	}
+
+	if _, err := jwk.Fetch(ctx, srv.URL+"/jwks"); !assert.Error(t, err, `jwk.Fetch should fail without credentials`) {
+		return
+	}
+
+	cc := jwk.NewClientCredentials(srv.URL+"/token", `client`, `secret`, `jwks.read`)
+	for i := 0; i < 2; i++ {
+		fetched, err := jwk.Fetch(ctx, srv.URL+"/jwks", jwk.WithClientCredentials(cc))
+		if !assert.NoError(t, err, `jwk.Fetch should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1, fetched.Len(), `set should contain 1 key`) {
+			return
+		}
+	}
+	if !assert.Equal(t, 1, tokenCount(), `access token should be reused`) {
+		return
+	}
+
+	// when the token is rejected, a new one is obtained
+	revoke()
+	ar := jwk.NewAutoRefresh(ctx)
+	ar.Configure(srv.URL+"/jwks", jwk.WithClientCredentials(cc))
+	if _, err := ar.Fetch(ctx, srv.URL+"/jwks"); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+	if !assert.Equal(t, 2, tokenCount(), `access token should be refreshed after 401`) {
+		return
+	}
+
+	bad := jwk.NewClientCredentials(srv.URL+"/token", `client`, `wrong`, `jwks.read`)
+	_, err = jwk.Fetch(ctx, srv.URL+"/jwks", jwk.WithClientCredentials(bad))
+	if !assert.Error(t, err, `jwk.Fetch should fail with invalid credentials`) {
+		return
+	}
+	assert.Contains(t, err.Error(), `invalid_client`, `error should contain the OAuth error code`)
+}
+
+func TestKeySetParser(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	ecKey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &rsaKey.PublicKey, rsaKey)
+	if !assert.NoError(t, err, `x509.CreateCertificate should succeed`) {
+		return
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	pkix, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if !assert.NoError(t, err, `x509.MarshalPKIXPublicKey should succeed`) {
+		return
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})
+
+	pemMap, err := json.Marshal(map[string]string{
+		"rsa-cert": string(certPEM),
+		"ec-pub":   string(pubPEM),
+	})
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+
+	t.Run("ParsePEMMap", func(t *testing.T) {
+		t.Parallel()
+		set, err := jwk.ParsePEMMap(pemMap)
+		if !assert.NoError(t, err, `jwk.ParsePEMMap should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, set.Len(), `set should contain 2 keys`) {
+			return
+		}
+
+		key, ok := set.LookupKeyID("rsa-cert")
+		if !assert.True(t, ok, `set.LookupKeyID should succeed`) {
+			return
+		}
+		if !assert.Equal(t, jwa.RSA, key.KeyType(), `key type should be RSA`) {
+			return
+		}
+		if !assert.Len(t, key.X509CertChain(), 1, `"x5c" should contain the certificate`) {
+			return
+		}
+
+		key, ok = set.LookupKeyID("ec-pub")
+		if !assert.True(t, ok, `set.LookupKeyID should succeed`) {
+			return
+		}
+		if !assert.Equal(t, jwa.EC, key.KeyType(), `key type should be EC`) {
+			return
+		}
+
+		privPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+		buf, _ := json.Marshal(map[string]string{"priv": string(privPEM)})
+		if _, err := jwk.ParsePEMMap(buf); !assert.Error(t, err, `jwk.ParsePEMMap should reject private keys`) {
+			return
+		}
+	})
+	t.Run("Fetch", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(pemMap)
+		}))
+		defer srv.Close()
+
+		if _, err := jwk.Fetch(ctx, srv.URL); !assert.Error(t, err, `jwk.Fetch should fail without a parser`) {
+			return
+		}
+		set, err := jwk.Fetch(ctx, srv.URL, jwk.WithKeySetParser(jwk.KeySetParserFunc(jwk.ParsePEMMap)))
+		if !assert.NoError(t, err, `jwk.Fetch should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, set.Len(), `set should contain 2 keys`) {
+			return
+		}
+	})
+	t.Run("AutoRefresh", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(pubPEM)
+		}))
+		defer srv.Close()
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL, jwk.WithKeySetParser(jwk.PEMParser("alb-key")))
+		set, err := ar.Fetch(ctx, srv.URL)
+		if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+		if _, ok := set.LookupKeyID("alb-key"); !assert.True(t, ok, `key should have the given key ID`) {
+			return
+		}
+	})
+}
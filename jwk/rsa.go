@@ -10,6 +10,8 @@ import (
 	"github.com/lestrrat-go/blackmagic"
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/internal/pool"
+	"github.com/lestrrat-go/jwx/internal/rawkey"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/pkg/errors"
 )
 
@@ -81,6 +83,7 @@ func rsaPublicKeyByteValuesFromRaw(rawKey *rsa.PublicKey) ([]byte, []byte, error
 func (k *rsaPublicKey) FromRaw(rawKey *rsa.PublicKey) error {
 	k.mu.Lock()
 	defer k.mu.Unlock()
+	k.raw.invalidate()
 
 	n, e, err := rsaPublicKeyByteValuesFromRaw(rawKey)
 	if err != nil {
@@ -96,6 +99,10 @@ func (k *rsaPrivateKey) Raw(v interface{}) error {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
+	if len(k.d) == 0 {
+		return errors.New(`missing "d" value (the key may have been zeroized)`)
+	}
+
 	var d, q, p big.Int // note: do not use from sync.Pool
 
 	d.SetBytes(k.d)
@@ -145,12 +152,33 @@ func (k *rsaPrivateKey) Raw(v interface{}) error {
 	return blackmagic.AssignIfCompatible(v, &key)
 }
 
+// Zeroize overwrites the private key material with zeros, and
+// removes it from the key. See `jwk.Key` for details
+func (k *rsaPrivateKey) Zeroize() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, v := range [][]byte{k.d, k.p, k.q, k.dp, k.dq, k.qi} {
+		zeroize.Bytes(v)
+	}
+	k.d, k.p, k.q, k.dp, k.dq, k.qi = nil, nil, nil, nil, nil, nil
+}
+
 // Raw takes the values stored in the Key object, and creates the
-// corresponding *rsa.PublicKey object.
+// corresponding *rsa.PublicKey object. The object is cached (see
+// `Materialize()`), and each call returns a copy of it.
 func (k *rsaPublicKey) Raw(v interface{}) error {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
 
+	key, err := k.raw.getCopy(k.buildRaw)
+	if err != nil {
+		return err
+	}
+	return blackmagic.AssignIfCompatible(v, key)
+}
+
+func (k *rsaPublicKey) buildRaw() (interface{}, error) {
 	var key rsa.PublicKey
 
 	n := pool.GetBigInt()
@@ -163,9 +191,34 @@ func (k *rsaPublicKey) Raw(v interface{}) error {
 	key.N = n
 	key.E = int(e.Int64())
 
-	return blackmagic.AssignIfCompatible(v, &key)
+	return &key, nil
 }
 
+func (k *rsaPublicKey) Materialize() error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	_, err := k.raw.get(k.buildRaw)
+	return err
+}
+
+// SharedRaw returns the cached *rsa.PublicKey without copying it. It is
+// used by jws and jwe, which do not modify the key (see internal/rawkey)
+func (k *rsaPublicKey) SharedRaw(rawkey.Token) (interface{}, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.raw.get(k.buildRaw)
+}
+
+func (k *rsaPrivateKey) Materialize() error {
+	var key rsa.PrivateKey
+	return k.Raw(&key)
+}
+
+// Zeroize is a no-op, as public keys hold no secret material
+func (k *rsaPublicKey) Zeroize() {}
+
 func makeRSAPublicKey(v interface {
 	Iterate(context.Context) HeaderIterator
 }) (Key, error) {
@@ -348,25 +348,29 @@ func (h *rsaPrivateKey) setNoLock(name string, value interface{}) error {
 		return nil
 	case RSADKey:
 		if v, ok := value.([]byte); ok {
-			h.d = v
+			h.d = make([]byte, len(v))
+			copy(h.d, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSADKey, value)
 	case RSADPKey:
 		if v, ok := value.([]byte); ok {
-			h.dp = v
+			h.dp = make([]byte, len(v))
+			copy(h.dp, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSADPKey, value)
 	case RSADQKey:
 		if v, ok := value.([]byte); ok {
-			h.dq = v
+			h.dq = make([]byte, len(v))
+			copy(h.dq, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSADQKey, value)
 	case RSAEKey:
 		if v, ok := value.([]byte); ok {
-			h.e = v
+			h.e = make([]byte, len(v))
+			copy(h.e, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSAEKey, value)
@@ -400,25 +404,29 @@ func (h *rsaPrivateKey) setNoLock(name string, value interface{}) error {
 		return nil
 	case RSANKey:
 		if v, ok := value.([]byte); ok {
-			h.n = v
+			h.n = make([]byte, len(v))
+			copy(h.n, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSANKey, value)
 	case RSAPKey:
 		if v, ok := value.([]byte); ok {
-			h.p = v
+			h.p = make([]byte, len(v))
+			copy(h.p, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSAPKey, value)
 	case RSAQKey:
 		if v, ok := value.([]byte); ok {
-			h.q = v
+			h.q = make([]byte, len(v))
+			copy(h.q, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSAQKey, value)
 	case RSAQIKey:
 		if v, ok := value.([]byte); ok {
-			h.qi = v
+			h.qi = make([]byte, len(v))
+			copy(h.qi, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSAQIKey, value)
@@ -749,6 +757,7 @@ type rsaPublicKey struct {
 	x509CertThumbprintS256 *string           // https://tools.ietf.org/html/rfc7515#section-4.1.8
 	x509URL                *string           // https://tools.ietf.org/html/rfc7515#section-4.1.5
 	privateParams          map[string]interface{}
+	raw                    *rawKeyCache
 	mu                     *sync.RWMutex
 	dc                     DecodeCtx
 }
@@ -761,6 +770,7 @@ func newRSAPublicKey() *rsaPublicKey {
 	return &rsaPublicKey{
 		mu:            &sync.RWMutex{},
 		privateParams: make(map[string]interface{}),
+		raw:           &rawKeyCache{},
 	}
 }
 
@@ -951,6 +961,7 @@ func (h *rsaPublicKey) setNoLock(name string, value interface{}) error {
 	case "kty":
 		return nil
 	case AlgorithmKey:
+		h.raw.invalidate()
 		switch v := value.(type) {
 		case string:
 			h.algorithm = &v
@@ -962,8 +973,10 @@ func (h *rsaPublicKey) setNoLock(name string, value interface{}) error {
 		}
 		return nil
 	case RSAEKey:
+		h.raw.invalidate()
 		if v, ok := value.([]byte); ok {
-			h.e = v
+			h.e = make([]byte, len(v))
+			copy(h.e, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSAEKey, value)
@@ -996,8 +1009,10 @@ func (h *rsaPublicKey) setNoLock(name string, value interface{}) error {
 		h.keyops = &acceptor
 		return nil
 	case RSANKey:
+		h.raw.invalidate()
 		if v, ok := value.([]byte); ok {
-			h.n = v
+			h.n = make([]byte, len(v))
+			copy(h.n, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, RSANKey, value)
@@ -1040,8 +1055,10 @@ func (k *rsaPublicKey) Remove(key string) error {
 	defer k.mu.Unlock()
 	switch key {
 	case AlgorithmKey:
+		k.raw.invalidate()
 		k.algorithm = nil
 	case RSAEKey:
+		k.raw.invalidate()
 		k.e = nil
 	case KeyIDKey:
 		k.keyID = nil
@@ -1050,6 +1067,7 @@ func (k *rsaPublicKey) Remove(key string) error {
 	case KeyOpsKey:
 		k.keyops = nil
 	case RSANKey:
+		k.raw.invalidate()
 		k.n = nil
 	case X509CertChainKey:
 		k.x509CertChain = nil
@@ -1082,6 +1100,7 @@ func (k *rsaPublicKey) SetDecodeCtx(dc DecodeCtx) {
 }
 
 func (h *rsaPublicKey) UnmarshalJSON(buf []byte) error {
+	h.raw.invalidate()
 	h.algorithm = nil
 	h.e = nil
 	h.keyID = nil
@@ -1,7 +1,11 @@
 package jwk
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"sort"
+	"time"
 
 	"github.com/lestrrat-go/iter/arrayiter"
 	"github.com/lestrrat-go/jwx/internal/json"
@@ -10,8 +14,16 @@ import (
 )
 
 // NewSet creates and empty `jwk.Set` object
-func NewSet() Set {
-	return &set{}
+func NewSet(options ...SetOption) Set {
+	var s set
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identAutoKeyID{}:
+			s.autoKeyID = option.Value().(KeyIDStrategy)
+		}
+	}
+	return &s
 }
 
 func (s *set) Get(idx int) (Key, bool) {
@@ -55,6 +67,11 @@ func (s *set) Add(key Key) bool {
 	if i := s.indexNL(key); i > -1 {
 		return false
 	}
+	if s.autoKeyID != nil && key.KeyID() == "" {
+		if err := assignKeyID(key, s.autoKeyID); err != nil {
+			return false
+		}
+	}
 	s.keys = append(s.keys, key)
 	return true
 }
@@ -63,20 +80,55 @@ func (s *set) Remove(key Key) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for i, k := range s.keys {
-		if k == key {
-			switch i {
-			case 0:
-				s.keys = s.keys[1:]
-			case len(s.keys) - 1:
-				s.keys = s.keys[:i]
-			default:
-				s.keys = append(s.keys[:i], s.keys[i+1:]...)
-			}
-			return true
+	i := s.indexNL(key)
+	if i < 0 {
+		return false
+	}
+	s.removeNL(i)
+	return true
+}
+
+func (s *set) RemoveByKeyID(kid string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if s.keys[i].KeyID() == kid {
+			s.removeNL(i)
+			removed++
 		}
 	}
-	return false
+	return removed
+}
+
+// removeNL removes the key at index i, without the locking. A new
+// slice is always allocated, so that iterators that are already
+// running are not affected
+func (s *set) removeNL(i int) {
+	keys := make([]Key, 0, len(s.keys)-1)
+	keys = append(keys, s.keys[:i]...)
+	keys = append(keys, s.keys[i+1:]...)
+	s.keys = keys
+}
+
+func (s *set) Replace(idx int, key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if idx < 0 || idx >= len(s.keys) {
+		return false
+	}
+
+	if i := s.indexNL(key); i > -1 && i != idx {
+		return false
+	}
+
+	keys := make([]Key, len(s.keys))
+	copy(keys, s.keys)
+	keys[idx] = key
+	s.keys = keys
+	return true
 }
 
 func (s *set) Clear() {
@@ -113,12 +165,16 @@ func (s *set) MarshalJSON() ([]byte, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	return marshalKeys(s.keys)
+}
+
+func marshalKeys(keys []Key) ([]byte, error) {
 	buf := pool.GetBytesBuffer()
 	defer pool.ReleaseBytesBuffer(buf)
 	enc := json.NewEncoder(buf)
 
 	buf.WriteString(`{"keys":[`)
-	for i, k := range s.keys {
+	for i, k := range keys {
 		if i > 0 {
 			buf.WriteByte(',')
 		}
@@ -194,7 +250,7 @@ func (s *set) SetDecodeCtx(dc DecodeCtx) {
 }
 
 func (s *set) Clone() (Set, error) {
-	s2 := &set{}
+	s2 := &set{autoKeyID: s.autoKeyID}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -206,3 +262,49 @@ func (s *set) Clone() (Set, error) {
 	}
 	return s2, nil
 }
+
+func (s *set) ActiveKeys(now time.Time) Set {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &set{
+		keys:      activeKeys(s.keys, now),
+		autoKeyID: s.autoKeyID,
+	}
+}
+
+func (s *set) Fingerprint(hash crypto.Hash) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fingerprint(hash, s.keys)
+}
+
+// fingerprint computes the digest of the sorted thumbprints of the keys
+func fingerprint(hash crypto.Hash, keys []Key) ([]byte, error) {
+	if !hash.Available() {
+		return nil, errors.Errorf(`hash function %s is not available`, hash)
+	}
+
+	thumbprints := make([][]byte, 0, len(keys))
+	for i, key := range keys {
+		tp, err := key.Thumbprint(hash)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to compute thumbprint of key #%d`, i)
+		}
+		thumbprints = append(thumbprints, tp)
+	}
+
+	sort.Slice(thumbprints, func(i, j int) bool {
+		return bytes.Compare(thumbprints[i], thumbprints[j]) < 0
+	})
+
+	h := hash.New()
+	for i, tp := range thumbprints {
+		// the same key may appear more than once, e.g. as a private
+		// and a public key. it should only be counted once
+		if i > 0 && bytes.Equal(tp, thumbprints[i-1]) {
+			continue
+		}
+		h.Write(tp)
+	}
+	return h.Sum(nil), nil
+}
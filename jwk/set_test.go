@@ -1,10 +1,19 @@
 package jwk_test
 
 import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -70,3 +79,542 @@ func TestSet(t *testing.T) {
 		return
 	}
 }
+
+func TestSetRemoveReplace(t *testing.T) {
+	t.Parallel()
+
+	newSet := func(t *testing.T, kids ...string) (jwk.Set, []jwk.Key) {
+		t.Helper()
+		set := jwk.NewSet()
+		keys := make([]jwk.Key, len(kids))
+		for i, kid := range kids {
+			k, err := jwxtest.GenerateSymmetricJwk()
+			if !assert.NoError(t, err, `key generation should succeed`) {
+				t.FailNow()
+			}
+			_ = k.Set(jwk.KeyIDKey, kid)
+			set.Add(k)
+			keys[i] = k
+		}
+		return set, keys
+	}
+
+	kidsOf := func(set jwk.Set) []string {
+		kids := make([]string, set.Len())
+		for i := 0; i < set.Len(); i++ {
+			k, _ := set.Get(i)
+			kids[i] = k.KeyID()
+		}
+		return kids
+	}
+
+	t.Run("RemoveByKeyID", func(t *testing.T) {
+		t.Parallel()
+		set, _ := newSet(t, "a", "b", "c", "b", "d")
+		if !assert.Equal(t, 2, set.RemoveByKeyID("b"), `set.RemoveByKeyID should remove 2 keys`) {
+			return
+		}
+		if !assert.Equal(t, []string{"a", "c", "d"}, kidsOf(set), `remaining keys should keep their order`) {
+			return
+		}
+		if !assert.Equal(t, 0, set.RemoveByKeyID("b"), `set.RemoveByKeyID should remove nothing`) {
+			return
+		}
+	})
+	t.Run("Remove", func(t *testing.T) {
+		t.Parallel()
+		set, keys := newSet(t, "a", "b", "c")
+		if !assert.True(t, set.Remove(keys[1]), `set.Remove should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{"a", "c"}, kidsOf(set), `remaining keys should keep their order`) {
+			return
+		}
+		if !assert.False(t, set.Remove(keys[1]), `set.Remove should fail`) {
+			return
+		}
+	})
+	t.Run("Replace", func(t *testing.T) {
+		t.Parallel()
+		set, keys := newSet(t, "a", "b", "c")
+		replacement, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			return
+		}
+		_ = replacement.Set(jwk.KeyIDKey, "b2")
+
+		if !assert.True(t, set.Replace(1, replacement), `set.Replace should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{"a", "b2", "c"}, kidsOf(set), `key should be replaced in place`) {
+			return
+		}
+		if !assert.False(t, set.Replace(3, replacement), `set.Replace should fail for out of range index`) {
+			return
+		}
+		if !assert.False(t, set.Replace(0, keys[2]), `set.Replace should fail for a key at another position`) {
+			return
+		}
+		if !assert.True(t, set.Replace(2, keys[2]), `set.Replace should succeed for the key at the same position`) {
+			return
+		}
+	})
+}
+
+func TestConcurrentSet(t *testing.T) {
+	t.Parallel()
+
+	set := jwk.NewConcurrentSet()
+	for i := 0; i < 3; i++ {
+		k, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			return
+		}
+		_ = k.Set(jwk.KeyIDKey, "static-"+strconv.Itoa(i))
+		if !assert.True(t, set.Add(k), `set.Add should succeed`) {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if _, ok := set.LookupKeyID("static-1"); !ok {
+					t.Errorf(`set.LookupKeyID should always find a static key`)
+					return
+				}
+				for iter := set.Iterate(ctx); iter.Next(ctx); {
+					_ = iter.Pair()
+				}
+				if _, err := json.Marshal(set); err != nil {
+					t.Errorf(`json.Marshal should succeed: %s`, err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		k, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			return
+		}
+		_ = k.Set(jwk.KeyIDKey, "rotated")
+		if !assert.True(t, set.Add(k), `set.Add should succeed`) {
+			return
+		}
+		if i%2 == 0 {
+			if !assert.True(t, set.Replace(set.Index(k), k), `set.Replace should succeed`) {
+				return
+			}
+		}
+		if !assert.Equal(t, 1, set.RemoveByKeyID("rotated"), `set.RemoveByKeyID should remove 1 key`) {
+			return
+		}
+	}
+	cancel()
+	wg.Wait()
+
+	if !assert.Equal(t, 3, set.Len(), `set.Len should be 3`) {
+		return
+	}
+
+	buf, err := json.Marshal(set)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+
+	parsed := jwk.NewConcurrentSet()
+	if !assert.NoError(t, json.Unmarshal(buf, parsed), `json.Unmarshal should succeed`) {
+		return
+	}
+	if !assert.Equal(t, 3, parsed.Len(), `parsed.Len should be 3`) {
+		return
+	}
+}
+
+func TestDiffMerge(t *testing.T) {
+	t.Parallel()
+
+	generate := func(t *testing.T, kid string) jwk.Key {
+		t.Helper()
+		k, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			t.FailNow()
+		}
+		if kid != "" {
+			_ = k.Set(jwk.KeyIDKey, kid)
+		}
+		return k
+	}
+	kidsOf := func(keys []jwk.Key) []string {
+		kids := make([]string, len(keys))
+		for i, k := range keys {
+			kids[i] = k.KeyID()
+		}
+		return kids
+	}
+
+	a, b, c := generate(t, "a"), generate(t, "b"), generate(t, "c")
+	b2 := generate(t, "b")
+	anonymous := generate(t, "")
+
+	oldSet := jwk.NewSet()
+	oldSet.Add(a)
+	oldSet.Add(b)
+	oldSet.Add(anonymous)
+
+	newSet := jwk.NewSet()
+	newSet.Add(b2)
+	newSet.Add(c)
+	newSet.Add(anonymous)
+
+	t.Run("Diff", func(t *testing.T) {
+		t.Parallel()
+		added, removed, changed := jwk.Diff(oldSet, newSet)
+		if !assert.Equal(t, []string{"c"}, kidsOf(added), `added should match`) {
+			return
+		}
+		if !assert.Equal(t, []string{"a"}, kidsOf(removed), `removed should match`) {
+			return
+		}
+		if !assert.Equal(t, []jwk.Key{b2}, changed, `changed should match`) {
+			return
+		}
+
+		added, removed, changed = jwk.Diff(oldSet, oldSet)
+		if !assert.Empty(t, added, `added should be empty`) {
+			return
+		}
+		if !assert.Empty(t, removed, `removed should be empty`) {
+			return
+		}
+		if !assert.Empty(t, changed, `changed should be empty`) {
+			return
+		}
+
+		added, _, _ = jwk.Diff(nil, newSet)
+		if !assert.Len(t, added, 3, `all keys should be added`) {
+			return
+		}
+	})
+	t.Run("Merge", func(t *testing.T) {
+		t.Parallel()
+		for _, tc := range []struct {
+			Strategy jwk.MergeStrategy
+			Expected jwk.Key
+		}{
+			{Strategy: jwk.MergeKeepExisting, Expected: b},
+			{Strategy: jwk.MergeReplace, Expected: b2},
+		} {
+			merged, err := jwk.Merge(oldSet, newSet, tc.Strategy)
+			if !assert.NoError(t, err, `jwk.Merge should succeed`) {
+				return
+			}
+			if !assert.Equal(t, 4, merged.Len(), `merged set should contain 4 keys`) {
+				return
+			}
+			got, ok := merged.LookupKeyID("b")
+			if !assert.True(t, ok, `merged.LookupKeyID should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, got, `conflicting key should be resolved by the strategy`) {
+				return
+			}
+		}
+
+		if !assert.Equal(t, 3, oldSet.Len(), `original set should not be modified`) {
+			return
+		}
+
+		_, err := jwk.Merge(oldSet, newSet, jwk.MergeFailOnConflict)
+		if !assert.Error(t, err, `jwk.Merge should fail on conflict`) {
+			return
+		}
+	})
+}
+
+func TestSetFingerprint(t *testing.T) {
+	t.Parallel()
+
+	var keys []jwk.Key
+	for _, gen := range []func() (jwk.Key, error){
+		jwxtest.GenerateRsaJwk,
+		jwxtest.GenerateEcdsaJwk,
+		jwxtest.GenerateEd25519Jwk,
+	} {
+		k, err := gen()
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			return
+		}
+		keys = append(keys, k)
+	}
+
+	fingerprint := func(t *testing.T, set jwk.Set) []byte {
+		t.Helper()
+		fp, err := set.Fingerprint(crypto.SHA256)
+		if !assert.NoError(t, err, `set.Fingerprint should succeed`) {
+			t.FailNow()
+		}
+		return fp
+	}
+
+	set := jwk.NewSet()
+	for _, k := range keys {
+		set.Add(k)
+	}
+	expected := fingerprint(t, set)
+	assert.Len(t, expected, crypto.SHA256.Size(), `fingerprint should be a SHA-256 digest`)
+
+	t.Run("Order", func(t *testing.T) {
+		t.Parallel()
+		reversed := jwk.NewConcurrentSet()
+		for i := len(keys) - 1; i >= 0; i-- {
+			reversed.Add(keys[i])
+		}
+		assert.Equal(t, expected, fingerprint(t, reversed), `fingerprint should not depend on the order of keys`)
+	})
+	t.Run("Public keys", func(t *testing.T) {
+		t.Parallel()
+		pubset, err := jwk.PublicSetOf(set)
+		if !assert.NoError(t, err, `jwk.PublicSetOf should succeed`) {
+			return
+		}
+		assert.Equal(t, expected, fingerprint(t, pubset), `fingerprint should be the same for public keys`)
+
+		// a key that appears twice is only counted once
+		pubkey, _ := pubset.Get(0)
+		dup, _ := set.Clone()
+		dup.Add(pubkey)
+		assert.Equal(t, expected, fingerprint(t, dup), `fingerprint should not change for duplicate keys`)
+	})
+	t.Run("Changes", func(t *testing.T) {
+		t.Parallel()
+		changed, _ := set.Clone()
+		k, err := jwxtest.GenerateEcdsaJwk()
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			return
+		}
+		changed.Add(k)
+		assert.NotEqual(t, expected, fingerprint(t, changed), `fingerprint should change when a key is added`)
+
+		changed.Remove(k)
+		changed.Remove(keys[0])
+		assert.NotEqual(t, expected, fingerprint(t, changed), `fingerprint should change when a key is removed`)
+	})
+	t.Run("Unavailable hash", func(t *testing.T) {
+		t.Parallel()
+		_, err := set.Fingerprint(crypto.Hash(0))
+		assert.Error(t, err, `set.Fingerprint should fail`)
+	})
+}
+
+func TestSetActiveKeys(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	src := `{"keys":[
+  {"kty":"oct","kid":"plain","k":"AAAA"},
+  {"kty":"oct","kid":"current","k":"AAAB","nbf":1600000000,"exp":1800000000},
+  {"kty":"oct","kid":"future","k":"AAAC","nbf":1750000000},
+  {"kty":"oct","kid":"expired","k":"AAAD","exp":1700000000},
+  {"kty":"oct","kid":"revoked","k":"AAAE","revoked":true},
+  {"kty":"oct","kid":"not-revoked","k":"AAAF","revoked":false},
+  {"kty":"oct","kid":"revoked-later","k":"AAAG","revoked":{"revoked_at":1750000000,"reason":"superseded"}},
+  {"kty":"oct","kid":"revoked-earlier","k":"AAAH","revoked":{"revoked_at":1650000000}},
+  {"kty":"oct","kid":"rfc3339","k":"AAAI","exp":"2030-01-01T00:00:00Z"},
+  {"kty":"oct","kid":"malformed","k":"AAAJ","nbf":"yesterday"}
+]}`
+
+	for _, set := range []jwk.Set{jwk.NewSet(), jwk.NewConcurrentSet()} {
+		if !assert.NoError(t, json.Unmarshal([]byte(src), set), `json.Unmarshal should succeed`) {
+			return
+		}
+
+		active := set.ActiveKeys(now)
+		var kids []string
+		for i := 0; i < active.Len(); i++ {
+			key, _ := active.Get(i)
+			kids = append(kids, key.KeyID())
+		}
+		if !assert.Equal(t, []string{`plain`, `current`, `not-revoked`, `revoked-later`, `rfc3339`}, kids, `active keys should match`) {
+			return
+		}
+		if !assert.Equal(t, 10, set.Len(), `original set should not be modified`) {
+			return
+		}
+	}
+
+	t.Run("Accessors", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwk.ParseKey([]byte(`{"kty":"oct","k":"AAAA","nbf":1600000000.5,"revoked":{"revoked_at":1650000000}}`))
+		if !assert.NoError(t, err, `jwk.ParseKey should succeed`) {
+			return
+		}
+
+		nbf, ok := jwk.KeyNotBefore(key)
+		if !assert.True(t, ok, `"nbf" should be available`) {
+			return
+		}
+		if !assert.Equal(t, time.Unix(1600000000, 500000000), nbf, `"nbf" should match`) {
+			return
+		}
+
+		if _, ok := jwk.KeyExpiration(key); !assert.False(t, ok, `"exp" should not be available`) {
+			return
+		}
+
+		at, revoked := jwk.KeyRevoked(key)
+		if !assert.True(t, revoked, `key should be revoked`) {
+			return
+		}
+		if !assert.Equal(t, time.Unix(1650000000, 0), at, `revocation time should match`) {
+			return
+		}
+
+		// values set programmatically are also honored
+		key, err = jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+			return
+		}
+		if !assert.True(t, jwk.IsKeyActive(key, now), `key should be active`) {
+			return
+		}
+		if !assert.NoError(t, key.Set(jwk.ExpirationKey, now), `key.Set should succeed`) {
+			return
+		}
+		if !assert.False(t, jwk.IsKeyActive(key, now), `key should not be active`) {
+			return
+		}
+	})
+}
+
+func TestTrackedSet(t *testing.T) {
+	set := jwk.NewSet()
+	keys := make(map[string]jwk.Key)
+	for _, kid := range []string{`old`, `new`, `unused`} {
+		key, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+			return
+		}
+		key.Set(jwk.KeyIDKey, kid)
+		key.Set(jwk.AlgorithmKey, jwa.HS256)
+		set.Add(key)
+		keys[kid] = key
+	}
+
+	tracked := jwk.NewTrackedSet(set)
+	start := time.Now()
+
+	signedOld, err := jws.Sign([]byte(`hello`), jwa.HS256, keys[`old`])
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+	signedNew, err := jws.Sign([]byte(`hello`), "", nil, jws.WithKeyProviderForSigning(jws.KeySetSigningKeyProvider(tracked, `new`)))
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+	token, err := jwt.Sign(jwt.New(), "", nil, jwt.WithKeyProviderForSigning(jws.KeySetSigningKeyProvider(tracked, `new`)))
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	if _, err := jws.VerifySet(signedOld, tracked); !assert.NoError(t, err, `jws.VerifySet should succeed`) {
+		return
+	}
+	if _, err := jws.VerifySet(signedNew, tracked); !assert.NoError(t, err, `jws.VerifySet should succeed`) {
+		return
+	}
+	if _, err := jwt.Parse(token, jwt.WithKeySet(tracked)); !assert.NoError(t, err, `jwt.Parse should succeed`) {
+		return
+	}
+	// tokens that fail validation are not counted
+	expired := jwt.New()
+	expired.Set(jwt.ExpirationKey, time.Now().Add(-time.Hour))
+	expiredToken, err := jwt.Sign(expired, jwa.HS256, keys[`new`])
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+	if _, err := jwt.Parse(expiredToken, jwt.WithKeySet(tracked), jwt.WithValidate(true)); !assert.Error(t, err, `jwt.Parse should fail`) {
+		return
+	}
+	verifier, err := jws.NewKeySetVerifier(tracked)
+	if !assert.NoError(t, err, `jws.NewKeySetVerifier should succeed`) {
+		return
+	}
+	if _, err := verifier.Verify(signedOld); !assert.NoError(t, err, `(jws.KeySetVerifier).Verify should succeed`) {
+		return
+	}
+	if _, err := jws.VerifySet([]byte(`eyJhbGciOiJIUzI1NiJ9.aGVsbG8.AAAA`), tracked); !assert.Error(t, err, `jws.VerifySet should fail`) {
+		return
+	}
+
+	stats := tracked.AllStats()
+	if !assert.Len(t, stats, 3, `there should be statistics for each key`) {
+		return
+	}
+	expected := []struct {
+		Key      jwk.Key
+		Signed   uint64
+		Verified uint64
+	}{
+		{Key: keys[`old`], Signed: 0, Verified: 2},
+		{Key: keys[`new`], Signed: 2, Verified: 2},
+		{Key: keys[`unused`], Signed: 0, Verified: 0},
+	}
+	for i, e := range expected {
+		if !assert.Equal(t, e.Key, stats[i].Key, `keys should be in the same order as the set`) {
+			return
+		}
+		if !assert.Equal(t, e.Signed, stats[i].Signed, `sign count for %q should match`, e.Key.KeyID()) {
+			return
+		}
+		if !assert.Equal(t, e.Verified, stats[i].Verified, `verify count for %q should match`, e.Key.KeyID()) {
+			return
+		}
+		if e.Signed+e.Verified > 0 {
+			if !assert.False(t, stats[i].LastUsed.Before(start), `last used time for %q should be set`, e.Key.KeyID()) {
+				return
+			}
+		} else {
+			if !assert.True(t, stats[i].LastUsed.IsZero(), `last used time for %q should not be set`, e.Key.KeyID()) {
+				return
+			}
+		}
+	}
+
+	buf, err := json.Marshal(tracked)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+	expectedBuf, err := json.Marshal(set)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+	if !assert.Equal(t, expectedBuf, buf, `tracked set should serialize as the wrapped set`) {
+		return
+	}
+
+	tracked.Remove(keys[`old`])
+	if _, ok := tracked.Stats(keys[`old`]); !assert.False(t, ok, `removed keys should not have statistics`) {
+		return
+	}
+	if !assert.Len(t, tracked.AllStats(), 2, `there should be statistics for each remaining key`) {
+		return
+	}
+
+	tracked.ResetStats()
+	st, ok := tracked.Stats(keys[`new`])
+	if !assert.True(t, ok, `tracked.Stats should succeed`) {
+		return
+	}
+	if !assert.Equal(t, jwk.KeyStats{Key: keys[`new`]}, st, `statistics should be reset`) {
+		return
+	}
+}
@@ -0,0 +1,91 @@
+package jwk
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/lestrrat-go/jwx/internal/limits"
+	"github.com/pkg/errors"
+)
+
+// SignatureVerifier verifies the signature of a signed JWK set document,
+// such as the JWS-wrapped JWKS documents used in OpenID Federation,
+// and returns the payload (i.e. the JWK set) if the signature is valid.
+//
+// This package cannot depend on `github.com/lestrrat-go/jwx/jws`, so the
+// verification is usually implemented by wrapping `jws.Verify()` or
+// `jws.VerifySet()` using `jwk.SignatureVerifierFunc`
+type SignatureVerifier interface {
+	Verify([]byte) ([]byte, error)
+}
+
+// SignatureVerifierFunc is a function that implements the SignatureVerifier interface
+type SignatureVerifierFunc func([]byte) ([]byte, error)
+
+func (f SignatureVerifierFunc) Verify(buf []byte) ([]byte, error) {
+	return f(buf)
+}
+
+// ParseSigned parses a signed JWK set document. The signature is verified
+// using `v` before the keys are parsed, and an error is returned if the
+// verification fails. The options are passed to `jwk.Parse()` when parsing
+// the verified payload.
+//
+//   set, err := jwk.ParseSigned(buf, jwk.SignatureVerifierFunc(func(buf []byte) ([]byte, error) {
+//     return jws.VerifySet(buf, trustAnchors)
+//   }))
+//
+// Members of the payload other than "keys" (e.g. "iss" and "iat") are ignored.
+func ParseSigned(src []byte, v SignatureVerifier, options ...ParseOption) (Set, error) {
+	if v == nil {
+		return nil, errors.New(`signature verifier must be specified`)
+	}
+
+	if err := limits.CheckInputSize(len(src)); err != nil {
+		return nil, newParseError(err)
+	}
+
+	payload, err := v.Verify(src)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to verify signed JWK set`)
+	}
+
+	set, err := Parse(payload, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse signed JWK set`)
+	}
+	return set, nil
+}
+
+// parseFetched parses the body of a response fetched from a remote
+// resource, verifying its signature first if `v` is specified, and
+// converting it using `p` if it is not a JWK set document
+func parseFetched(src io.Reader, v SignatureVerifier, p KeySetParser) (Set, error) {
+	if v == nil && p == nil {
+		return ParseReader(src)
+	}
+
+	if n := limits.MaxInputSize(); n > 0 {
+		src = io.LimitReader(src, n+1)
+	}
+	buf, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to read from io.Reader`)
+	}
+
+	if p == nil {
+		return ParseSigned(buf, v)
+	}
+
+	if err := limits.CheckInputSize(len(buf)); err != nil {
+		return nil, newParseError(err)
+	}
+
+	if v != nil {
+		buf, err = v.Verify(buf)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to verify signed key set`)
+		}
+	}
+	return p.ParseKeySet(buf)
+}
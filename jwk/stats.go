@@ -0,0 +1,151 @@
+package jwk
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+)
+
+// UsageRecorder is implemented by sets that keep track of how their keys
+// are used, such as those created by `jwk.NewTrackedSet()`. The jws and
+// jwt packages call RecordUsage when a key taken from such a set has been
+// used to verify a message, or has been selected to sign one.
+type UsageRecorder interface {
+	RecordUsage(Key, KeyOperation)
+}
+
+// KeyStats describes how a key in a `jwk.TrackedSet` has been used
+type KeyStats struct {
+	// Key is the key that the statistics are about
+	Key Key
+	// Signed is the number of times the key was selected to sign a message
+	Signed uint64
+	// Verified is the number of messages that were successfully verified
+	// using the key. For `jwt.Parse()`, only tokens that also passed
+	// validation are counted
+	Verified uint64
+	// LastUsed is the last time the key was used, or the zero time.Time
+	// if it has not been used
+	LastUsed time.Time
+}
+
+// TrackedSet is a `jwk.Set` that records how often, and how recently,
+// each of its keys was used to sign or verify messages. This can help to
+// decide when a key can be retired, for example by checking that no
+// tokens signed with the old key are received anymore.
+//
+// All the methods of `jwk.Set` are delegated to the wrapped set, and keys
+// are tracked by identity: a key that is replaced by an equivalent key
+// starts with empty statistics. Sets returned by `Clone()` and
+// `ActiveKeys()` are not tracked.
+//
+// A TrackedSet is safe for concurrent use if the wrapped set is.
+type TrackedSet struct {
+	Set
+	mu    sync.Mutex
+	stats map[Key]*KeyStats
+}
+
+// NewTrackedSet creates a TrackedSet that wraps `set`. Statistics are
+// only kept in memory, and start empty.
+func NewTrackedSet(set Set) *TrackedSet {
+	return &TrackedSet{
+		Set:   set,
+		stats: make(map[Key]*KeyStats),
+	}
+}
+
+// RecordUsage records that `key` was used for the operation `op`.
+// Only `jwk.KeyOpSign` and `jwk.KeyOpVerify` are counted, other
+// operations only update the last used time. Keys that are not in
+// the set are ignored.
+func (s *TrackedSet) RecordUsage(key Key, op KeyOperation) {
+	if key == nil || s.Set.Index(key) < 0 {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.stats[key]
+	if !ok {
+		st = &KeyStats{Key: key}
+		s.stats[key] = st
+	}
+	switch op {
+	case KeyOpSign:
+		st.Signed++
+	case KeyOpVerify:
+		st.Verified++
+	}
+	st.LastUsed = now
+}
+
+// Stats returns the statistics of `key`. The second return value is
+// false if the key is not in the set.
+func (s *TrackedSet) Stats(key Key) (KeyStats, bool) {
+	if key == nil || s.Set.Index(key) < 0 {
+		return KeyStats{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.stats[key]; ok {
+		return *st, true
+	}
+	return KeyStats{Key: key}, true
+}
+
+// AllStats returns the statistics of all the keys in the set, in the
+// same order as the keys. Keys that have not been used are included,
+// with a zero LastUsed.
+func (s *TrackedSet) AllStats() []KeyStats {
+	// Snapshot the keys before taking the lock, as the wrapped set
+	// has its own synchronization
+	keys := make([]Key, 0, s.Set.Len())
+	for i := 0; i < s.Set.Len(); i++ {
+		if key, ok := s.Set.Get(i); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]KeyStats, len(keys))
+	for i, key := range keys {
+		if st, ok := s.stats[key]; ok {
+			list[i] = *st
+		} else {
+			list[i] = KeyStats{Key: key}
+		}
+	}
+
+	// Drop the statistics of keys that were removed from the set
+	if len(s.stats) > len(keys) {
+		present := make(map[Key]struct{}, len(keys))
+		for _, key := range keys {
+			present[key] = struct{}{}
+		}
+		for key := range s.stats {
+			if _, ok := present[key]; !ok {
+				delete(s.stats, key)
+			}
+		}
+	}
+	return list
+}
+
+// ResetStats discards all the statistics recorded so far
+func (s *TrackedSet) ResetStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = make(map[Key]*KeyStats)
+}
+
+// MarshalJSON serializes the wrapped set. The statistics are not included.
+func (s *TrackedSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Set)
+}
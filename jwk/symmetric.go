@@ -7,6 +7,7 @@ import (
 
 	"github.com/lestrrat-go/blackmagic"
 	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/pkg/errors"
 )
 
@@ -28,9 +29,28 @@ func (k *symmetricKey) FromRaw(rawKey []byte) error {
 func (k *symmetricKey) Raw(v interface{}) error {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
+
+	if len(k.octets) == 0 {
+		return errors.New(`missing "k" value (the key may have been zeroized)`)
+	}
 	return blackmagic.AssignIfCompatible(v, k.octets)
 }
 
+func (k *symmetricKey) Materialize() error {
+	var key []byte
+	return k.Raw(&key)
+}
+
+// Zeroize overwrites the octets of the key with zeros, and
+// removes them from the key. See `jwk.Key` for details
+func (k *symmetricKey) Zeroize() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	zeroize.Bytes(k.octets)
+	k.octets = nil
+}
+
 // Thumbprint returns the JWK thumbprint using the indicated
 // hashing algorithm, according to RFC 7638
 func (k *symmetricKey) Thumbprint(hash crypto.Hash) ([]byte, error) {
@@ -270,7 +270,8 @@ func (h *symmetricKey) setNoLock(name string, value interface{}) error {
 		return nil
 	case SymmetricOctetsKey:
 		if v, ok := value.([]byte); ok {
-			h.octets = v
+			h.octets = make([]byte, len(v))
+			copy(h.octets, v)
 			return nil
 		}
 		return errors.Errorf(`invalid value for %s key: %T`, SymmetricOctetsKey, value)
@@ -1,6 +1,12 @@
 package jwk
 
-import "github.com/pkg/errors"
+import (
+	"context"
+	"crypto"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/pkg/errors"
+)
 
 func (k KeyUsageType) String() string {
 	return string(k)
@@ -28,3 +34,48 @@ func (k *KeyUsageType) Accept(v interface{}) error {
 
 	return errors.Errorf("invalid value for key usage type %s", v)
 }
+
+// CheckKeySeparation checks that no key in the set is published both
+// for signatures and for encryption, i.e. that the same key material
+// (as identified by the thumbprint of the key) does not appear once with
+// "use" set to "sig" and once with "use" set to "enc". Keys without a
+// "use" field are ignored.
+//
+// The returned error matches `jwx.ErrKeySeparation` via `errors.Is()`.
+// To detect keys that are used for both purposes at runtime, see
+// `jwx.WithKeySeparation()`
+func CheckKeySeparation(set Set) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type usage struct {
+		index int
+		use   string
+	}
+	seen := make(map[string]usage)
+	for iter := set.Iterate(ctx); iter.Next(ctx); {
+		pair := iter.Pair()
+		key := pair.Value.(Key) //nolint:forcetypeassert
+
+		use := key.KeyUsage()
+		if use == "" {
+			continue
+		}
+
+		tp, err := key.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return errors.Wrapf(err, `failed to compute thumbprint of key #%d`, pair.Index)
+		}
+
+		prev, ok := seen[string(tp)]
+		if !ok {
+			seen[string(tp)] = usage{index: pair.Index, use: use}
+			continue
+		}
+
+		if prev.use != use {
+			return errors.Wrapf(jwx.ErrKeySeparation, `key #%d (use %q) and key #%d (use %q) share the same key material`, prev.index, prev.use, pair.Index, use)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package jwk
+
+import (
+	"math"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+)
+
+// Names of the non-standard members that some identity providers add
+// to the keys in their JWKS, to announce when a key becomes usable,
+// when it stops being usable, and whether it has been revoked. These
+// are not defined by RFC7517, and are available via `key.Get()` like
+// any other private parameter.
+const (
+	NotBeforeKey  = "nbf"
+	ExpirationKey = "exp"
+	RevokedKey    = "revoked"
+)
+
+// KeyNotBefore returns the time specified in the "nbf" member of the
+// key, before which the key should not be used. The value may be
+// a number of seconds since the epoch, a string in RFC3339 format, or
+// a time.Time (for example when set programmatically). The second
+// return value is false if the member is missing or cannot be
+// interpreted as a time.
+func KeyNotBefore(key Key) (time.Time, bool) {
+	return keyTime(key, NotBeforeKey)
+}
+
+// KeyExpiration returns the time specified in the "exp" member of the
+// key, at or after which the key should not be used. The value is
+// interpreted as in `jwk.KeyNotBefore()`.
+func KeyExpiration(key Key) (time.Time, bool) {
+	return keyTime(key, ExpirationKey)
+}
+
+// KeyRevoked reports whether the "revoked" member of the key marks it
+// as revoked. The member may be a boolean, or an object whose
+// "revoked_at" member specifies when the key was revoked, as a number
+// of seconds since the epoch. Any other value that is not false or
+// null also marks the key as revoked.
+//
+// The first return value is the time of the revocation, which is the
+// zero value if it was not specified.
+func KeyRevoked(key Key) (time.Time, bool) {
+	v, ok := key.Get(RevokedKey)
+	if !ok || v == nil {
+		return time.Time{}, false
+	}
+
+	switch v := v.(type) {
+	case bool:
+		return time.Time{}, v
+	case map[string]interface{}:
+		if at, ok := v[`revoked_at`]; ok {
+			if t, ok := toTime(at); ok {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, true
+}
+
+// IsKeyActive reports whether the key may be used at `now`, according
+// to its "nbf", "exp", and "revoked" members. Keys without any of these
+// members are always active. A key whose "nbf" or "exp" member cannot be
+// interpreted as a time is considered inactive, as is a key that has
+// been revoked at or before `now` (or at an unspecified time).
+func IsKeyActive(key Key, now time.Time) bool {
+	if _, ok := key.Get(NotBeforeKey); ok {
+		nbf, ok := KeyNotBefore(key)
+		if !ok || now.Before(nbf) {
+			return false
+		}
+	}
+
+	if _, ok := key.Get(ExpirationKey); ok {
+		exp, ok := KeyExpiration(key)
+		if !ok || !now.Before(exp) {
+			return false
+		}
+	}
+
+	if at, revoked := KeyRevoked(key); revoked && (at.IsZero() || !now.Before(at)) {
+		return false
+	}
+	return true
+}
+
+// activeKeys returns the keys that are active at `now`, in the same order
+func activeKeys(keys []Key, now time.Time) []Key {
+	active := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		if IsKeyActive(key, now) {
+			active = append(active, key)
+		}
+	}
+	return active
+}
+
+func keyTime(key Key, name string) (time.Time, bool) {
+	v, ok := key.Get(name)
+	if !ok {
+		return time.Time{}, false
+	}
+	return toTime(v)
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		return unixTime(f)
+	case float64:
+		return unixTime(v)
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func unixTime(f float64) (time.Time, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
@@ -0,0 +1,366 @@
+package jws
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+
+	"filippo.io/edwards25519"
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/fips"
+	"github.com/lestrrat-go/jwx/internal/hook"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/keyconv"
+	"github.com/lestrrat-go/jwx/internal/limits"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// BatchError is returned by `jws.VerifyBatch()` when one or more of the
+// messages could not be verified. It matches `jwx.ErrVerification` via
+// `errors.Is()`
+type BatchError struct {
+	errs   []error
+	failed int
+}
+
+// Errors returns the error for each message passed to `jws.VerifyBatch()`,
+// in the same order. The entries for messages that were verified are nil
+func (e *BatchError) Errors() []error {
+	return e.errs
+}
+
+func (e *BatchError) Error() string {
+	for i, err := range e.errs {
+		if err != nil {
+			return fmt.Sprintf(`%d of %d messages failed to verify (message #%d: %s)`, e.failed, len(e.errs), i, err)
+		}
+	}
+	return `no messages failed to verify`
+}
+
+func (e *BatchError) Is(target error) bool {
+	return target == jwx.ErrVerification
+}
+
+// batchEntry holds the decoded parts of a message in a batch
+type batchEntry struct {
+	index     int
+	publicKey ed25519.PublicKey
+	input     []byte
+	signature []byte
+	payload   []byte
+}
+
+// VerifyBatch verifies multiple EdDSA (Ed25519) messages in compact
+// serialization format at once, which is considerably faster than
+// calling `jws.Verify()` for each of them. Instead of verifying each
+// signature on its own, a random linear combination of all signatures
+// is checked using a single multi-scalar multiplication. If the check
+// fails, the messages are verified one by one to find out which of them
+// are invalid.
+//
+// `key` may be an Ed25519 public key ("raw" or jwk.Key), which is used
+// for all messages, or a jwk.Set, in which case the key whose "kid"
+// matches that of each message is used (or the only key in the set, if
+// the message does not have a "kid").
+//
+// The payloads are returned in the same order as the messages. If any
+// of the messages fail to verify, a `*jws.BatchError` describing the
+// failures is returned along with the payloads, where the entries for
+// the invalid messages are nil.
+//
+// The only options that are accepted are `jws.WithExpectedType()` and
+// `jws.WithExpectedContentType()`.
+//
+// Like other batch verification schemes, the combined check uses the
+// cofactored verification equation. It therefore accepts a small class
+// of signatures that `jws.Verify()` would reject, but these can only be
+// produced deliberately by the holder of the private key.
+func VerifyBatch(messages [][]byte, key interface{}, options ...VerifyOption) ([][]byte, error) {
+	span := hook.Start(hook.VerifyKind)
+	span.SetAlgorithm(jwa.EdDSA.String())
+	payloads, err := verifyBatch(messages, key, options...)
+	span.End(err)
+	return payloads, err
+}
+
+func verifyBatch(messages [][]byte, key interface{}, options ...VerifyOption) ([][]byte, error) {
+	var reqs headerRequirements
+	for _, option := range options {
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identExpectedType{}:
+			v := option.Value().(string)
+			reqs.typ = &v
+		case identExpectedContentType{}:
+			v := option.Value().(string)
+			reqs.cty = &v
+		default:
+			return nil, errors.Errorf(`option %T is not supported by jws.VerifyBatch()`, option.Ident())
+		}
+	}
+
+	if key == nil {
+		return nil, errors.New(`missing public key while verifying payloads`)
+	}
+
+	payloads := make([][]byte, len(messages))
+	errs := make([]error, len(messages))
+	entries := make([]*batchEntry, 0, len(messages))
+	for i, buf := range messages {
+		entry, err := decodeBatchEntry(bytes.TrimSpace(buf), key, &reqs)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		entry.index = i
+		entries = append(entries, entry)
+	}
+
+	// batch verification is not part of the validated module, so
+	// in FIPS mode each signature is verified by crypto/ed25519
+	if len(entries) > 1 && !fips.Enabled() && verifyEd25519Batch(entries) {
+		for _, entry := range entries {
+			payloads[entry.index] = entry.payload
+		}
+	} else {
+		for _, entry := range entries {
+			if !ed25519.Verify(entry.publicKey, entry.input, entry.signature) {
+				errs[entry.index] = newVerificationError(errors.New(`failed to match EdDSA signature`))
+				continue
+			}
+			payloads[entry.index] = entry.payload
+		}
+	}
+
+	var failed int
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return payloads, &BatchError{errs: errs, failed: failed}
+	}
+	return payloads, nil
+}
+
+// decodeBatchEntry performs all the checks that do not involve the
+// signature itself, and decodes the parts of the message
+func decodeBatchEntry(buf []byte, key interface{}, reqs *headerRequirements) (*batchEntry, error) {
+	if len(buf) == 0 {
+		return nil, errors.New(`attempt to verify empty buffer`)
+	}
+	if err := limits.CheckInputSize(len(buf)); err != nil {
+		return nil, newParseError(err)
+	}
+
+	protected, payload, signature, err := SplitCompact(buf)
+	if err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed extract from compact serialization format`))
+	}
+
+	decodedProtected, err := base64.Decode(protected)
+	if err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to decode headers`))
+	}
+	if err := limits.CheckHeader(decodedProtected); err != nil {
+		return nil, newParseError(errors.Wrap(err, `invalid protected headers`))
+	}
+	hdr := NewHeaders()
+	if err := json.Unmarshal(decodedProtected, hdr); err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to decode headers`))
+	}
+
+	if alg := hdr.Algorithm(); alg != jwa.EdDSA {
+		return nil, newVerificationError(errors.Errorf(`expected "alg" to be %s, got %q`, jwa.EdDSA, alg))
+	}
+
+	key, err = batchKey(key, hdr.KeyID())
+	if err != nil {
+		return nil, newVerificationError(err)
+	}
+	if err := checkKeySeparation(key); err != nil {
+		return nil, errors.Wrap(err, `failed to verify message`)
+	}
+	if err := reqs.check(hdr); err != nil {
+		return nil, newVerificationError(err)
+	}
+
+	var pubkey ed25519.PublicKey
+	if err := keyconv.Ed25519PublicKey(&pubkey, key); err != nil {
+		return nil, errors.Wrapf(err, `failed to retrieve ed25519.PublicKey out of %T`, key)
+	}
+	if len(pubkey) != ed25519.PublicKeySize {
+		return nil, errors.Errorf(`invalid Ed25519 public key length %d`, len(pubkey))
+	}
+
+	decodedSignature, err := base64.Decode(signature)
+	if err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to decode signature`))
+	}
+	if len(decodedSignature) != ed25519.SignatureSize {
+		return nil, newVerificationError(errors.New(`failed to match EdDSA signature`))
+	}
+
+	decodedPayload, err := base64.Decode(payload)
+	if err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to decode payload`))
+	}
+
+	input := make([]byte, 0, len(protected)+1+len(payload))
+	input = append(input, protected...)
+	input = append(input, '.')
+	input = append(input, payload...)
+
+	return &batchEntry{
+		publicKey: pubkey,
+		input:     input,
+		signature: decodedSignature,
+		payload:   decodedPayload,
+	}, nil
+}
+
+// batchKey selects the key to verify a message with
+func batchKey(key interface{}, kid string) (interface{}, error) {
+	set, ok := key.(jwk.Set)
+	if !ok {
+		if jwkKey, ok := key.(jwk.Key); ok && kid != "" && jwkKey.KeyID() != kid {
+			return nil, errors.New(`"kid" fields do not match`)
+		}
+		return key, nil
+	}
+
+	if kid != "" {
+		k, ok := set.LookupKeyID(kid)
+		if !ok {
+			return nil, errors.Errorf(`key %q not found in key set`, kid)
+		}
+		return k, nil
+	}
+
+	if set.Len() != 1 {
+		return nil, errors.Errorf(`message has no "kid", and key set contains %d keys`, set.Len())
+	}
+	k, _ := set.Get(0)
+	return k, nil
+}
+
+// verifyEd25519Batch checks that
+//
+//   [8](-(sum z_i * s_i) B + sum z_i * R_i + sum (z_i * k_i) A_i) = 0
+//
+// where z_i are random 128-bit scalars. Signatures made with the same
+// public key share a single term. It returns false if any of the
+// signatures is malformed, or if the check fails
+func verifyEd25519Batch(entries []*batchEntry) bool {
+	random := make([]byte, 16*len(entries))
+	if _, err := rand.Read(random); err != nil {
+		return false
+	}
+
+	scalars := make([]*edwards25519.Scalar, 1, 1+2*len(entries))
+	points := make([]*edwards25519.Point, 1, 1+2*len(entries))
+	scalars[0] = edwards25519.NewScalar()
+	points[0] = edwards25519.NewGeneratorPoint()
+
+	// index of the term for each public key in scalars/points
+	keyTerms := make(map[string]int)
+
+	var wide [64]byte
+	h := sha512.New()
+	for i, entry := range entries {
+		sig := entry.signature
+		if !canonicalPoint(sig[:32]) {
+			return false
+		}
+		R, err := new(edwards25519.Point).SetBytes(sig[:32])
+		if err != nil {
+			return false
+		}
+		s, err := edwards25519.NewScalar().SetCanonicalBytes(sig[32:])
+		if err != nil {
+			return false
+		}
+
+		copy(wide[:16], random[16*i:16*(i+1)])
+		z, _ := edwards25519.NewScalar().SetUniformBytes(wide[:])
+
+		h.Reset()
+		h.Write(sig[:32])
+		h.Write(entry.publicKey)
+		h.Write(entry.input)
+		k, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(wide[:0]))
+		for j := range wide {
+			wide[j] = 0
+		}
+
+		// B term: - sum z_i * s_i
+		scalars[0].Subtract(scalars[0], s.Multiply(s, z))
+
+		// R term: z_i * R_i
+		scalars = append(scalars, z)
+		points = append(points, R)
+
+		// A term: sum (z_i * k_i) over all signatures made with A
+		zk := k.Multiply(k, z)
+		if j, ok := keyTerms[string(entry.publicKey)]; ok {
+			scalars[j].Add(scalars[j], zk)
+			continue
+		}
+		A, err := new(edwards25519.Point).SetBytes(entry.publicKey)
+		if err != nil {
+			return false
+		}
+		keyTerms[string(entry.publicKey)] = len(points)
+		scalars = append(scalars, zk)
+		points = append(points, A)
+	}
+
+	var check edwards25519.Point
+	check.VarTimeMultiScalarMult(scalars, points)
+	check.MultByCofactor(&check)
+	return check.Equal(edwards25519.NewIdentityPoint()) == 1
+}
+
+// canonicalPoint reports whether b is the canonical encoding of a point,
+// as required of "R" by RFC 8032 (and crypto/ed25519). Non-canonical
+// encodings have a y coordinate that is not reduced modulo p, or the sign
+// bit set for a point whose x coordinate is 0 (i.e. y = 1 or y = p-1)
+func canonicalPoint(b []byte) bool {
+	// y >= p = 2^255 - 19
+	if b[0] >= 0xed && b[31]&0x7f == 0x7f {
+		allOnes := true
+		for _, v := range b[1:31] {
+			if v != 0xff {
+				allOnes = false
+				break
+			}
+		}
+		if allOnes {
+			return false
+		}
+	}
+
+	if b[31]&0x80 == 0 {
+		return true
+	}
+
+	var y [32]byte
+	copy(y[:], b)
+	y[31] &= 0x7f
+
+	one := [32]byte{1}
+	pMinusOne := [32]byte{0xec}
+	for i := 1; i < 31; i++ {
+		pMinusOne[i] = 0xff
+	}
+	pMinusOne[31] = 0x7f
+	return y != one && y != pMinusOne
+}
+
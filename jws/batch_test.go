@@ -0,0 +1,144 @@
+package jws_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	t.Parallel()
+
+	newKey := func(t *testing.T, kid string) (jwk.Key, jwk.Key) {
+		t.Helper()
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if !assert.NoError(t, err, `ed25519.GenerateKey should succeed`) {
+			t.FailNow()
+		}
+		key, err := jwk.New(priv)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			t.FailNow()
+		}
+		_ = key.Set(jwk.KeyIDKey, kid)
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			t.FailNow()
+		}
+		return key, pubkey
+	}
+
+	sign := func(t *testing.T, key jwk.Key, n int) [][]byte {
+		t.Helper()
+		messages := make([][]byte, n)
+		for i := range messages {
+			signed, err := jws.Sign([]byte(fmt.Sprintf(`message %d`, i)), jwa.EdDSA, key)
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				t.FailNow()
+			}
+			messages[i] = signed
+		}
+		return messages
+	}
+
+	t.Run("Single key", func(t *testing.T) {
+		t.Parallel()
+		key, pubkey := newKey(t, "a")
+		messages := sign(t, key, 16)
+
+		payloads, err := jws.VerifyBatch(messages, pubkey)
+		if !assert.NoError(t, err, `jws.VerifyBatch should succeed`) {
+			return
+		}
+		for i, payload := range payloads {
+			if !assert.Equal(t, fmt.Sprintf(`message %d`, i), string(payload), `payload should match`) {
+				return
+			}
+		}
+	})
+	t.Run("Key set", func(t *testing.T) {
+		t.Parallel()
+		key1, pubkey1 := newKey(t, "a")
+		key2, pubkey2 := newKey(t, "b")
+		set := jwk.NewSet()
+		set.Add(pubkey1)
+		set.Add(pubkey2)
+
+		messages := append(sign(t, key1, 4), sign(t, key2, 4)...)
+		payloads, err := jws.VerifyBatch(messages, set)
+		if !assert.NoError(t, err, `jws.VerifyBatch should succeed`) {
+			return
+		}
+		if !assert.Len(t, payloads, 8, `there should be 8 payloads`) {
+			return
+		}
+	})
+	t.Run("Invalid messages", func(t *testing.T) {
+		t.Parallel()
+		key, pubkey := newKey(t, "a")
+		otherKey, _ := newKey(t, "a")
+		messages := sign(t, key, 8)
+
+		forged := sign(t, otherKey, 1)[0]
+		messages[2] = forged
+		messages[5] = []byte(`garbage`)
+
+		hs256, err := jws.Sign([]byte(`message`), jwa.HS256, []byte(`Avracadabra-Avracadabra-Avracadabra`))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		messages = append(messages, hs256)
+
+		payloads, err := jws.VerifyBatch(messages, pubkey)
+		if !assert.Error(t, err, `jws.VerifyBatch should fail`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, jwx.ErrVerification), `error should match jwx.ErrVerification`) {
+			return
+		}
+
+		var berr *jws.BatchError
+		if !assert.True(t, errors.As(err, &berr), `error should be a *jws.BatchError`) {
+			return
+		}
+		for i, err := range berr.Errors() {
+			switch i {
+			case 2, 5, 8:
+				if !assert.Error(t, err, `message #%d should fail`, i) {
+					return
+				}
+				if !assert.Nil(t, payloads[i], `payload #%d should be nil`, i) {
+					return
+				}
+			default:
+				if !assert.NoError(t, err, `message #%d should succeed`, i) {
+					return
+				}
+				if !assert.Equal(t, fmt.Sprintf(`message %d`, i), string(payloads[i]), `payload should match`) {
+					return
+				}
+			}
+		}
+	})
+	t.Run("Options", func(t *testing.T) {
+		t.Parallel()
+		key, pubkey := newKey(t, "a")
+		messages := sign(t, key, 2)
+
+		_, err := jws.VerifyBatch(messages, pubkey, jws.WithExpectedType(`JWT`))
+		if !assert.Error(t, err, `jws.VerifyBatch should fail when "typ" does not match`) {
+			return
+		}
+		_, err = jws.VerifyBatch(messages, pubkey, jws.WithMessage(jws.NewMessage()))
+		if !assert.Error(t, err, `jws.VerifyBatch should reject unsupported options`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package jws
+
+import (
+	"container/list"
+	"crypto"
+	"crypto/sha256"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// verificationCache remembers the payloads of messages that have been
+// successfully verified, so that verifying the identical message with
+// the same algorithm and key again does not require the signature to be
+// verified. Entries are evicted in least recently used order once the
+// cache is full, and expire after the configured TTL.
+type verificationCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+type verificationCacheEntry struct {
+	id      [sha256.Size]byte
+	payload []byte
+	expires time.Time
+}
+
+func newVerificationCache(size int, ttl time.Duration) *verificationCache {
+	return &verificationCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// id computes the cache key for verifying buf using the given
+// algorithm and key. The key is identified by its thumbprint, so
+// that the entry is not used once the key has been rotated out,
+// even if the message is presented again. The second return value
+// is false if the key cannot be identified, in which case the cache
+// must not be used. The header requirements and the EdDSA options are
+// part of the key, as a message that was verified without them must not
+// be accepted when they are present. So is the "kid" of a jwk.Key, as
+// it is compared against the "kid" header of the message.
+func (c *verificationCache) id(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, reqs *headerRequirements, edopts *EdDSAOptions) ([sha256.Size]byte, bool) {
+	var id [sha256.Size]byte
+
+	// the outcome may depend on remote keys, which can change at any time
+	if reqs.remote != nil {
+		return id, false
+	}
+
+	// the outcome depends on the timestamp verifier, as well as on
+	// headers that are not covered by the signature
+	if reqs.timestamp != nil {
+		return id, false
+	}
+
+	k, isJWK := key.(jwk.Key)
+	if !isJWK {
+		if signer, ok := key.(crypto.Signer); ok {
+			key = signer.Public()
+		}
+		v, err := jwk.New(key)
+		if err != nil {
+			return id, false
+		}
+		k = v
+	}
+
+	tp, err := k.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return id, false
+	}
+
+	h := sha256.New()
+	h.Write(buf)
+	h.Write([]byte{0})
+	h.Write([]byte(alg.String()))
+	h.Write([]byte{0})
+	h.Write(tp)
+	if isJWK {
+		h.Write([]byte{0, 4})
+		h.Write([]byte(k.KeyID()))
+	}
+	if !reqs.empty() {
+		for _, v := range []*string{reqs.typ, reqs.cty} {
+			h.Write([]byte{0})
+			if v != nil {
+				h.Write([]byte{1})
+				h.Write([]byte(strings.ToLower(trimMediaType(*v))))
+			}
+		}
+	}
+	if reqs.hardened != nil {
+		h.Write([]byte{0, 3})
+	}
+	if edopts != nil {
+		h.Write([]byte{0, 2})
+		if edopts.Prehash {
+			h.Write([]byte{1})
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(edopts.Context))
+	}
+	copy(id[:], h.Sum(nil))
+	return id, true
+}
+
+// get returns a copy of the payload of a previously verified message
+func (c *verificationCache) get(id [sha256.Size]byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*verificationCacheEntry) //nolint:forcetypeassert
+	if !c.now().Before(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, id)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	payload := make([]byte, len(entry.payload))
+	copy(payload, entry.payload)
+	return payload, true
+}
+
+func (c *verificationCache) set(id [sha256.Size]byte, payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &verificationCacheEntry{
+		id:      id,
+		payload: make([]byte, len(payload)),
+		expires: c.now().Add(c.ttl),
+	}
+	copy(entry.payload, payload)
+
+	if elem, ok := c.entries[id]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[id] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*verificationCacheEntry).id) //nolint:forcetypeassert
+	}
+}
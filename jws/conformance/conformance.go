@@ -0,0 +1,220 @@
+// Package conformance runs JWS test vectors against this library, so
+// that applications can check programmatically that the keys and
+// algorithms they use behave as described in the specifications.
+//
+// The vectors from RFC7515, RFC7520, and RFC8037 are available via
+// `conformance.Vectors()`. Additional vectors can be read from JSON
+// files using `conformance.ReadFile()`:
+//
+//   results := conformance.Run(conformance.Vectors())
+//   if err := results.Err(); err != nil {
+//     ...
+//   }
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
+)
+
+// Vector is a single JWS test vector. In JSON, it is represented as
+// an object with the field names given in the struct tags, and a file
+// of vectors (as read by `conformance.ReadFile()`) is a JSON array
+// of such objects.
+type Vector struct {
+	// Name identifies the vector in the results
+	Name string `json:"name"`
+
+	// Reference optionally describes where the vector comes from,
+	// such as a section of an RFC
+	Reference string `json:"reference,omitempty"`
+
+	// Algorithm is the signature algorithm to verify with
+	Algorithm jwa.SignatureAlgorithm `json:"alg"`
+
+	// Key is the JWK to verify with. If it is a private key (or a
+	// symmetric key), it is also used to sign `Payload`, and the
+	// result is verified using the corresponding public key.
+	Key json.RawMessage `json:"key"`
+
+	// Serialized is the JWS message, in compact or JSON serialization
+	Serialized string `json:"serialized"`
+
+	// Payload is the payload that `Serialized` is expected to carry
+	Payload string `json:"payload"`
+
+	// Deterministic specifies that signing the signing input of
+	// `Serialized` with `Key` must reproduce the signature exactly.
+	// This only applies to compact serialization, and to algorithms
+	// that do not use a random factor, such as HS256, RS256, and EdDSA.
+	Deterministic bool `json:"deterministic,omitempty"`
+
+	// ExpectFailure specifies that `Serialized` must NOT verify.
+	// This is used for negative vectors, such as tampered messages.
+	ExpectFailure bool `json:"expect_failure,omitempty"`
+}
+
+// Result is the outcome of running a single vector
+type Result struct {
+	// Name is the name of the vector
+	Name string
+
+	// Err is non-nil if the vector failed
+	Err error
+}
+
+// Results is the list of outcomes returned by `conformance.Run()`,
+// in the same order as the vectors
+type Results []Result
+
+// Failed returns the results of the vectors that failed
+func (r Results) Failed() Results {
+	var failed Results
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing all failed vectors, or nil if all
+// vectors passed
+func (r Results) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(failed))
+	for i, result := range failed {
+		msgs[i] = fmt.Sprintf(`%s: %s`, result.Name, result.Err)
+	}
+	return errors.Errorf(`%d of %d vectors failed: %s`, len(failed), len(r), strings.Join(msgs, `; `))
+}
+
+// Parse parses a JSON array of vectors
+func Parse(src []byte) ([]Vector, error) {
+	var vectors []Vector
+	if err := json.Unmarshal(src, &vectors); err != nil {
+		return nil, errors.Wrap(err, `failed to parse vectors`)
+	}
+	return vectors, nil
+}
+
+// ReadFile reads a JSON array of vectors from the file `path`
+func ReadFile(path string) ([]Vector, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to read %s`, path)
+	}
+	return Parse(src)
+}
+
+// Run runs each of the vectors, and returns their results
+func Run(vectors []Vector) Results {
+	results := make(Results, len(vectors))
+	for i, v := range vectors {
+		results[i] = Result{
+			Name: v.Name,
+			Err:  run(&v),
+		}
+	}
+	return results
+}
+
+func run(v *Vector) error {
+	key, err := jwk.ParseKey(v.Key)
+	if err != nil {
+		return errors.Wrap(err, `failed to parse key`)
+	}
+	verifyKey, err := jwk.PublicKeyOf(key)
+	if err != nil {
+		return errors.Wrap(err, `failed to obtain public key`)
+	}
+
+	payload, err := jws.Verify([]byte(v.Serialized), v.Algorithm, verifyKey)
+	if v.ExpectFailure {
+		if err == nil {
+			return errors.New(`verification succeeded, but was expected to fail`)
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, `failed to verify message`)
+	}
+	if string(payload) != v.Payload {
+		return errors.Errorf(`payload mismatch: expected %q, got %q`, v.Payload, payload)
+	}
+
+	if !canSign(key) {
+		return nil
+	}
+
+	if v.Deterministic {
+		if err := resign(v, key); err != nil {
+			return err
+		}
+	}
+
+	signed, err := jws.Sign([]byte(v.Payload), v.Algorithm, key)
+	if err != nil {
+		return errors.Wrap(err, `failed to sign payload`)
+	}
+	if _, err := jws.Verify(signed, v.Algorithm, verifyKey); err != nil {
+		return errors.Wrap(err, `failed to verify newly signed message`)
+	}
+	return nil
+}
+
+func canSign(key jwk.Key) bool {
+	switch key.(type) {
+	case jwk.RSAPrivateKey, jwk.ECDSAPrivateKey, jwk.OKPPrivateKey, jwk.SymmetricKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// resign computes the signature over the signing input of the
+// vector, and compares it against the signature in the vector
+func resign(v *Vector, key jwk.Key) error {
+	protected, payload, signature, err := jws.SplitCompact([]byte(v.Serialized))
+	if err != nil {
+		return errors.Wrap(err, `deterministic vectors must use compact serialization`)
+	}
+
+	expected, err := base64.Decode(signature)
+	if err != nil {
+		return errors.Wrap(err, `failed to decode signature`)
+	}
+
+	signer, err := jws.NewSigner(v.Algorithm)
+	if err != nil {
+		return errors.Wrap(err, `failed to create signer`)
+	}
+
+	var rawKey interface{}
+	if err := key.Raw(&rawKey); err != nil {
+		return errors.Wrap(err, `failed to obtain raw key`)
+	}
+
+	signingInput := bytes.Join([][]byte{protected, payload}, []byte{'.'})
+	computed, err := signer.Sign(signingInput, rawKey)
+	if err != nil {
+		return errors.Wrap(err, `failed to sign signing input`)
+	}
+	if !bytes.Equal(expected, computed) {
+		return errors.New(`computed signature does not match the signature in the vector`)
+	}
+	return nil
+}
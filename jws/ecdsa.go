@@ -4,6 +4,8 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
+	"encoding/asn1"
+	"math/big"
 
 	"github.com/lestrrat-go/jwx/internal/keyconv"
 	"github.com/lestrrat-go/jwx/internal/pool"
@@ -13,6 +15,7 @@ import (
 
 var ecdsaSignFuncs = map[jwa.SignatureAlgorithm]ecdsaSignFunc{}
 var ecdsaVerifyFuncs = map[jwa.SignatureAlgorithm]ecdsaVerifyFunc{}
+var ecdsaHashes = map[jwa.SignatureAlgorithm]crypto.Hash{}
 
 func init() {
 	algs := map[jwa.SignatureAlgorithm]crypto.Hash{
@@ -25,6 +28,7 @@ func init() {
 	for alg, h := range algs {
 		ecdsaSignFuncs[alg] = makeECDSASignFunc(h)
 		ecdsaVerifyFuncs[alg] = makeECDSAVerifyFunc(h)
+		ecdsaHashes[alg] = h
 	}
 }
 
@@ -69,11 +73,24 @@ func (s ECDSASigner) Algorithm() jwa.SignatureAlgorithm {
 	return s.alg
 }
 
+// Sign creates a signature using crypto/ecdsa. key must be a non-nil instance of
+// `*"crypto/ecdsa".PrivateKey`, or a crypto.Signer whose public key is
+// a `*"crypto/ecdsa".PublicKey` (e.g. a key stored in a KMS or an HSM)
 func (s ECDSASigner) Sign(payload []byte, key interface{}) ([]byte, error) {
 	if key == nil {
 		return nil, errors.New(`missing private key while signing payload`)
 	}
 
+	if signer, ok := opaqueSigner(key); ok {
+		if pubkey, ok := signer.Public().(*ecdsa.PublicKey); ok {
+			der, err := signWithCryptoSigner(signer, payload, ecdsaHashes[s.alg])
+			if err != nil {
+				return nil, err
+			}
+			return ecdsaDERToRaw(der, pubkey)
+		}
+	}
+
 	var privkey ecdsa.PrivateKey
 	if err := keyconv.ECDSAPrivateKey(&privkey, key); err != nil {
 		return nil, errors.Wrapf(err, `failed to retrieve ecdsa.PrivateKey out of %T`, key)
@@ -123,3 +140,36 @@ func (v ECDSAVerifier) Verify(payload []byte, signature []byte, key interface{})
 
 	return v.verify(payload, signature, &pubkey)
 }
+
+// ecdsaDERToRaw converts an ASN.1 DER encoded ECDSA signature, as
+// returned by crypto.Signer implementations, to the fixed length
+// R || S format required by JWS
+func ecdsaDERToRaw(der []byte, pubkey *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse ASN.1 encoded ECDSA signature`)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New(`trailing data after ASN.1 encoded ECDSA signature`)
+	}
+
+	curveBits := pubkey.Curve.Params().BitSize
+	keyBytes := curveBits / 8
+	if curveBits%8 > 0 {
+		keyBytes++
+	}
+
+	rBytes := sig.R.Bytes()
+	sBytes := sig.S.Bytes()
+	if len(rBytes) > keyBytes || len(sBytes) > keyBytes {
+		return nil, errors.New(`invalid ECDSA signature size`)
+	}
+
+	out := make([]byte, 2*keyBytes)
+	copy(out[keyBytes-len(rBytes):keyBytes], rBytes)
+	copy(out[2*keyBytes-len(sBytes):], sBytes)
+	return out, nil
+}
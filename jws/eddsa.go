@@ -1,7 +1,9 @@
 package jws
 
 import (
+	"crypto"
 	"crypto/ed25519"
+	"crypto/rand"
 
 	"github.com/lestrrat-go/jwx/internal/keyconv"
 	"github.com/lestrrat-go/jwx/jwa"
@@ -21,10 +23,22 @@ func (s EdDSASigner) Sign(payload []byte, key interface{}) ([]byte, error) {
 		return nil, errors.New(`missing private key while signing payload`)
 	}
 
+	if signer, ok := opaqueSigner(key); ok {
+		if _, ok := signer.Public().(ed25519.PublicKey); ok {
+			if s.options != nil {
+				return signEdDSAWithOptions(signer, payload, s.options)
+			}
+			return signer.Sign(rand.Reader, payload, crypto.Hash(0))
+		}
+	}
+
 	var privkey ed25519.PrivateKey
 	if err := keyconv.Ed25519PrivateKey(&privkey, key); err != nil {
 		return nil, errors.Wrapf(err, `failed to retrieve ed25519.PrivateKey out of %T`, key)
 	}
+	if s.options != nil {
+		return signEdDSAWithOptions(privkey, payload, s.options)
+	}
 	return ed25519.Sign(privkey, payload), nil
 }
 
@@ -41,8 +55,43 @@ func (v EdDSAVerifier) Verify(payload, signature []byte, key interface{}) (err e
 	if err := keyconv.Ed25519PublicKey(&pubkey, key); err != nil {
 		return errors.Wrapf(err, `failed to retrieve ed25519.PublicKey out of %T`, key)
 	}
+	if v.options != nil {
+		return verifyEdDSAWithOptions(pubkey, payload, signature, v.options)
+	}
 	if !ed25519.Verify(pubkey, payload, signature) {
 		return errors.New(`failed to match EdDSA signature`)
 	}
 	return nil
 }
+
+// validate checks that the options can be used to sign or verify
+func (opts *EdDSAOptions) validate() error {
+	if len(opts.Context) > 255 {
+		return errors.Errorf(`EdDSA context must be at most 255 bytes long, got %d bytes`, len(opts.Context))
+	}
+	return nil
+}
+
+// newEdDSASignerWithOptions returns a signer that creates signatures
+// using the variant of EdDSA specified by opts
+func newEdDSASignerWithOptions(alg jwa.SignatureAlgorithm, opts *EdDSAOptions) (Signer, error) {
+	if alg != jwa.EdDSA {
+		return nil, errors.Errorf(`EdDSA options cannot be used with %s`, alg)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return &EdDSASigner{options: opts}, nil
+}
+
+// newEdDSAVerifierWithOptions returns a verifier that verifies
+// signatures using the variant of EdDSA specified by opts
+func newEdDSAVerifierWithOptions(alg jwa.SignatureAlgorithm, opts *EdDSAOptions) (Verifier, error) {
+	if alg != jwa.EdDSA {
+		return nil, errors.Errorf(`EdDSA options cannot be used with %s`, alg)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return &EdDSAVerifier{options: opts}, nil
+}
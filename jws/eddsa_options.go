@@ -0,0 +1,37 @@
+// +build go1.20
+
+package jws
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+
+	"github.com/pkg/errors"
+)
+
+func ed25519Options(payload []byte, opts *EdDSAOptions) ([]byte, *ed25519.Options) {
+	if opts.Prehash {
+		digest := sha512.Sum512(payload)
+		return digest[:], &ed25519.Options{Hash: crypto.SHA512, Context: opts.Context}
+	}
+	return payload, &ed25519.Options{Context: opts.Context}
+}
+
+func signEdDSAWithOptions(signer crypto.Signer, payload []byte, opts *EdDSAOptions) ([]byte, error) {
+	msg, edopts := ed25519Options(payload, opts)
+	signature, err := signer.Sign(rand.Reader, msg, edopts)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign payload using EdDSA options`)
+	}
+	return signature, nil
+}
+
+func verifyEdDSAWithOptions(pubkey ed25519.PublicKey, payload, signature []byte, opts *EdDSAOptions) error {
+	msg, edopts := ed25519Options(payload, opts)
+	if err := ed25519.VerifyWithOptions(pubkey, msg, signature, edopts); err != nil {
+		return errors.Wrap(err, `failed to match EdDSA signature`)
+	}
+	return nil
+}
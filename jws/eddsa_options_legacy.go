@@ -0,0 +1,21 @@
+// +build !go1.20
+
+package jws
+
+import (
+	"crypto"
+	"crypto/ed25519"
+
+	"github.com/pkg/errors"
+)
+
+// Ed25519ph and Ed25519ctx are only supported by crypto/ed25519
+// as of Go 1.20
+
+func signEdDSAWithOptions(_ crypto.Signer, _ []byte, _ *EdDSAOptions) ([]byte, error) {
+	return nil, errors.New(`EdDSA options require Go 1.20 or later`)
+}
+
+func verifyEdDSAWithOptions(_ ed25519.PublicKey, _, _ []byte, _ *EdDSAOptions) error {
+	return errors.New(`EdDSA options require Go 1.20 or later`)
+}
@@ -0,0 +1,111 @@
+// +build go1.20
+
+package jws_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEdDSAOptions(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Hello, World!")
+	key, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+		return
+	}
+	pubkey := key.Public().(ed25519.PublicKey)
+
+	testcases := []struct {
+		Name    string
+		Options jws.EdDSAOptions
+	}{
+		{Name: "Ed25519ctx", Options: jws.EdDSAOptions{Context: `example context`}},
+		{Name: "Ed25519ph", Options: jws.EdDSAOptions{Prehash: true}},
+		{Name: "Ed25519ph with context", Options: jws.EdDSAOptions{Context: `example context`, Prehash: true}},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			signed, err := jws.Sign(payload, jwa.EdDSA, key, jws.WithEdDSAOptions(tc.Options))
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+
+			msg, err := jws.Parse(signed)
+			if !assert.NoError(t, err, `jws.Parse should succeed`) {
+				return
+			}
+			if !assert.Equal(t, jwa.EdDSA, msg.Signatures()[0].ProtectedHeaders().Algorithm(), `"alg" should be EdDSA`) {
+				return
+			}
+
+			// The signature must be valid according to crypto/ed25519
+			parts := strings.Split(string(signed), ".")
+			input := []byte(parts[0] + "." + parts[1])
+			opts := &ed25519.Options{Context: tc.Options.Context}
+			if tc.Options.Prehash {
+				digest := sha512.Sum512(input)
+				input = digest[:]
+				opts.Hash = crypto.SHA512
+			}
+			if !assert.NoError(t, ed25519.VerifyWithOptions(pubkey, input, msg.Signatures()[0].Signature(), opts), `ed25519.VerifyWithOptions should succeed`) {
+				return
+			}
+
+			verified, err := jws.Verify(signed, jwa.EdDSA, pubkey, jws.WithEdDSAOptions(tc.Options))
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payload should match`) {
+				return
+			}
+
+			_, err = jws.Verify(signed, jwa.EdDSA, pubkey)
+			if !assert.Error(t, err, `jws.Verify should fail without options`) {
+				return
+			}
+			_, err = jws.Verify(signed, jwa.EdDSA, pubkey, jws.WithEdDSAOptions(jws.EdDSAOptions{Context: `other context`, Prehash: tc.Options.Prehash}))
+			if !assert.Error(t, err, `jws.Verify should fail with different context`) {
+				return
+			}
+		})
+	}
+	t.Run("Verification cache", func(t *testing.T) {
+		t.Parallel()
+		opts := jws.EdDSAOptions{Context: `example context`}
+		signed, err := jws.Sign(payload, jwa.EdDSA, key, jws.WithEdDSAOptions(opts))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		cache := jws.WithVerificationCache(10, time.Minute)
+		if _, err := jws.Verify(signed, jwa.EdDSA, pubkey, cache, jws.WithEdDSAOptions(opts)); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(signed, jwa.EdDSA, pubkey, cache); !assert.Error(t, err, `cached result should not be used without options`) {
+			return
+		}
+	})
+	t.Run("Invalid usage", func(t *testing.T) {
+		t.Parallel()
+		_, err := jws.Sign(payload, jwa.ES256, key, jws.WithEdDSAOptions(jws.EdDSAOptions{Prehash: true}))
+		if !assert.Error(t, err, `jws.Sign should fail for algorithms other than EdDSA`) {
+			return
+		}
+		_, err = jws.Sign(payload, jwa.EdDSA, key, jws.WithEdDSAOptions(jws.EdDSAOptions{Context: strings.Repeat("a", 256)}))
+		if !assert.Error(t, err, `jws.Sign should fail for long context`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package jws
+
+import "github.com/lestrrat-go/jwx"
+
+// ParseError is returned when a JWS message could not be parsed.
+// It matches `jwx.ErrParse` via `errors.Is()`
+type ParseError struct {
+	err error
+}
+
+func newParseError(err error) error {
+	return &ParseError{err: err}
+}
+
+func (e *ParseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == jwx.ErrParse
+}
+
+// VerificationError is returned when the signature(s) in a JWS
+// message could not be verified. It matches `jwx.ErrVerification`
+// via `errors.Is()`
+type VerificationError struct {
+	err error
+}
+
+func newVerificationError(err error) error {
+	return &VerificationError{err: err}
+}
+
+func (e *VerificationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.err
+}
+
+func (e *VerificationError) Is(target error) bool {
+	return target == jwx.ErrVerification
+}
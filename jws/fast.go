@@ -0,0 +1,236 @@
+package jws
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/internal/fips"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/keysep"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// hmacState holds everything required to compute an HMAC without
+// allocating, so that it can be reused via a sync.Pool. crypto/hmac
+// cannot be used here, as its instances are bound to a single key, and
+// creating one per call allocates. The key material is cleared by
+// reset() before the state is returned to the pool.
+type hmacState struct {
+	inner hash.Hash
+	outer hash.Hash
+	pad   [sha512.BlockSize]byte
+	sum   [sha512.Size]byte
+}
+
+// compute calculates the HMAC of the payload as described in RFC 2104.
+// The returned slice refers to s.sum, and is only valid until s is reused
+func (s *hmacState) compute(key, payload []byte) []byte {
+	bs := s.inner.BlockSize()
+	if len(key) > bs {
+		s.inner.Reset()
+		s.inner.Write(key)
+		key = s.inner.Sum(s.sum[:0])
+	}
+
+	pad := s.pad[:bs]
+	n := copy(pad, key)
+	for i := n; i < bs; i++ {
+		pad[i] = 0
+	}
+
+	for i := range pad {
+		pad[i] ^= 0x36
+	}
+	s.inner.Reset()
+	s.inner.Write(pad)
+	s.inner.Write(payload)
+	isum := s.inner.Sum(s.sum[:0])
+
+	for i := range pad {
+		pad[i] ^= 0x36 ^ 0x5c
+	}
+	s.outer.Reset()
+	s.outer.Write(pad)
+	s.outer.Write(isum)
+	return s.outer.Sum(s.sum[:0])
+}
+
+// reset clears the key dependent data from s
+func (s *hmacState) reset() {
+	s.inner.Reset()
+	s.outer.Reset()
+	zeroize.Bytes(s.pad[:])
+	zeroize.Bytes(s.sum[:])
+}
+
+func newHMACStatePool(h func() hash.Hash) *sync.Pool {
+	return &sync.Pool{
+		New: func() interface{} {
+			return &hmacState{inner: h(), outer: h()}
+		},
+	}
+}
+
+var hmacStatePools = map[jwa.SignatureAlgorithm]*sync.Pool{
+	jwa.HS256: newHMACStatePool(sha256.New),
+	jwa.HS384: newHMACStatePool(sha512.New384),
+	jwa.HS512: newHMACStatePool(sha512.New),
+}
+
+// compactHSHeaders holds the encoded protected headers for the most
+// common cases, so that they do not have to be marshaled every time
+var compactHSHeaders = map[jwa.SignatureAlgorithm]map[string][]byte{}
+
+func init() {
+	for alg := range hmacStatePools {
+		compactHSHeaders[alg] = make(map[string][]byte)
+		for _, typ := range []string{"", "JWT"} {
+			h := NewHeaders()
+			if typ != "" {
+				_ = h.Set(TypeKey, typ)
+			}
+			encoded, err := encodeCompactHSHeader(alg, h)
+			if err != nil {
+				panic(err)
+			}
+			compactHSHeaders[alg][typ] = encoded
+		}
+	}
+}
+
+func encodeCompactHSHeader(alg jwa.SignatureAlgorithm, h Headers) ([]byte, error) {
+	if err := h.Set(AlgorithmKey, alg); err != nil {
+		return nil, errors.Wrap(err, `failed to set "alg"`)
+	}
+
+	buf, err := json.Marshal(h)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal headers`)
+	}
+
+	encoded := make([]byte, base64.RawURLEncoding.EncodedLen(len(buf)))
+	base64.RawURLEncoding.Encode(encoded, buf)
+	return encoded, nil
+}
+
+// SignCompactHS is an optimized version of `jws.Sign()` for the HMAC
+// algorithms (HS256, HS384, and HS512). It appends the message in compact
+// serialization format to `dst`, and returns the extended slice, much
+// like the `append` builtin. If `dst` has enough spare capacity, and no
+// option other than `jws.WithType("JWT")` is given, the message is
+// generated without allocating any memory.
+//
+// The only options that are accepted are `jws.WithHeaders()`,
+// `jws.WithType()`, and `jws.WithContentType()`. Unlike `jws.Sign()`,
+// the operation is not reported to the hook set via `jwx.WithHook()`.
+func SignCompactHS(dst []byte, alg jwa.SignatureAlgorithm, key, payload []byte, options ...SignOption) ([]byte, error) {
+	p, ok := hmacStatePools[alg]
+	if !ok {
+		return nil, errors.Errorf(`unsupported algorithm %s: only HS256, HS384, and HS512 are allowed`, alg)
+	}
+
+	if len(key) == 0 {
+		return nil, errors.New(`missing key while signing payload`)
+	}
+
+	if err := fips.CheckHMACKey(alg, len(key)); err != nil {
+		return nil, err
+	}
+
+	var hdrs Headers
+	var typ, cty string
+	var hasCty bool
+	for _, o := range options {
+		//nolint:forcetypeassert
+		switch o.Ident() {
+		case identHeaders{}:
+			hdrs = o.Value().(Headers)
+		case identType{}:
+			typ = o.Value().(string)
+		case identContentType{}:
+			cty = o.Value().(string)
+			hasCty = true
+		default:
+			return nil, errors.Errorf(`option %T is not supported by jws.SignCompactHS()`, o.Ident())
+		}
+	}
+
+	encodedHdr, ok := compactHSHeaders[alg][typ]
+	if !ok || hdrs != nil || hasCty {
+		h := NewHeaders()
+		if hdrs != nil {
+			if err := hdrs.Copy(context.Background(), h); err != nil {
+				return nil, errors.Wrap(err, `failed to copy headers`)
+			}
+		}
+		if typ != "" {
+			if err := h.Set(TypeKey, typ); err != nil {
+				return nil, errors.Wrap(err, `failed to set "typ"`)
+			}
+		}
+		if hasCty {
+			if err := h.Set(ContentTypeKey, cty); err != nil {
+				return nil, errors.Wrap(err, `failed to set "cty"`)
+			}
+		}
+
+		v, err := encodeCompactHSHeader(alg, h)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to encode headers`)
+		}
+		encodedHdr = v
+	}
+
+	if keysep.Enabled() {
+		if err := checkKeySeparation(key); err != nil {
+			return nil, errors.Wrap(err, `failed to sign payload`)
+		}
+	}
+
+	//nolint:forcetypeassert
+	st := p.Get().(*hmacState)
+	defer func() {
+		st.reset()
+		p.Put(st)
+	}()
+
+	enc := base64.RawURLEncoding
+	payloadLen := enc.EncodedLen(len(payload))
+	n := len(encodedHdr) + 1 + payloadLen + 1 + enc.EncodedLen(st.outer.Size())
+
+	start := len(dst)
+	if cap(dst)-start < n {
+		grown := make([]byte, start, start+n)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:start+n]
+
+	i := start + copy(dst[start:], encodedHdr)
+	dst[i] = '.'
+	i++
+	enc.Encode(dst[i:], payload)
+	i += payloadLen
+
+	var signature []byte
+	if fips.Enabled() {
+		// use crypto/hmac, so that the validated module is used
+		v, err := hmacSignFuncs[alg](dst[start:i], key)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to sign payload`)
+		}
+		signature = v
+	} else {
+		signature = st.compute(key, dst[start:i])
+	}
+	dst[i] = '.'
+	i++
+	enc.Encode(dst[i:], signature)
+	return dst, nil
+}
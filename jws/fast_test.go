@@ -0,0 +1,99 @@
+package jws_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignCompactHS(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"sub":"alice","exp":1300819380}`)
+	keys := map[string][]byte{
+		"Short": []byte(`Avracadabra-Avracadabra-Avracadabra`),
+		// longer than the block size of all algorithms, so it is hashed
+		"Long": bytes.Repeat([]byte{'k'}, 200),
+	}
+
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.HS256, jwa.HS384, jwa.HS512} {
+		alg := alg
+		for name, key := range keys {
+			key := key
+			t.Run(alg.String()+"/"+name, func(t *testing.T) {
+				t.Parallel()
+				for _, options := range [][]jws.SignOption{
+					nil,
+					{jws.WithType(`JWT`)},
+					{jws.WithType(`logout+jwt`), jws.WithContentType(`JWT`)},
+				} {
+					expected, err := jws.Sign(payload, alg, key, options...)
+					if !assert.NoError(t, err, `jws.Sign should succeed`) {
+						return
+					}
+
+					prefix := []byte(`prefix:`)
+					signed, err := jws.SignCompactHS(prefix, alg, key, payload, options...)
+					if !assert.NoError(t, err, `jws.SignCompactHS should succeed`) {
+						return
+					}
+					if !assert.Equal(t, `prefix:`+string(expected), string(signed), `result should match jws.Sign()`) {
+						return
+					}
+
+					verified, err := jws.Verify(signed[len(prefix):], alg, key)
+					if !assert.NoError(t, err, `jws.Verify should succeed`) {
+						return
+					}
+					assert.Equal(t, payload, verified, `payload should match`)
+				}
+			})
+		}
+	}
+	t.Run("Headers", func(t *testing.T) {
+		t.Parallel()
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.KeyIDKey, `my-key`)
+
+		signed, err := jws.SignCompactHS(nil, jwa.HS256, keys["Short"], payload, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.SignCompactHS should succeed`) {
+			return
+		}
+
+		m, err := jws.Parse(signed)
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		assert.Equal(t, `my-key`, m.Signatures()[0].ProtectedHeaders().KeyID(), `"kid" should match`)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := jws.SignCompactHS(nil, jwa.RS256, keys["Short"], payload)
+		assert.Error(t, err, `jws.SignCompactHS should fail for non-HMAC algorithms`)
+
+		_, err = jws.SignCompactHS(nil, jwa.HS256, nil, payload)
+		assert.Error(t, err, `jws.SignCompactHS should fail without a key`)
+
+		_, err = jws.SignCompactHS(nil, jwa.HS256, keys["Short"], payload, jws.WithEdDSAOptions(jws.EdDSAOptions{}))
+		assert.Error(t, err, `jws.SignCompactHS should fail for unsupported options`)
+	})
+}
+
+func TestSignCompactHSAllocations(t *testing.T) {
+	// not parallel, as other tests would skew the allocation count
+	if jwx.FIPSMode() {
+		t.Skip(`FIPS mode uses crypto/hmac, which allocates`)
+	}
+	key := []byte(`Avracadabra-Avracadabra-Avracadabra`)
+	payload := []byte(`{"sub":"alice","exp":1300819380}`)
+	typ := jws.WithType(`JWT`)
+	dst := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = jws.SignCompactHS(dst[:0], jwa.HS256, key, payload, typ)
+	})
+	assert.Equal(t, float64(0), allocs, `jws.SignCompactHS should not allocate`)
+}
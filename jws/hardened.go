@@ -0,0 +1,87 @@
+package jws
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/internal/zeroize"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// hardenedCheck holds the state for the additional checks performed
+// when `jws.WithHardenedVerification()` is specified
+type hardenedCheck struct {
+	alg jwa.SignatureAlgorithm
+}
+
+// standardSignatureAlgorithms lists the algorithms defined in RFC 7518
+// and RFC 8037, whose names are checked by checkAlgorithmCase
+var standardSignatureAlgorithms = []jwa.SignatureAlgorithm{
+	jwa.ES256, jwa.ES256K, jwa.ES384, jwa.ES512, jwa.EdDSA,
+	jwa.HS256, jwa.HS384, jwa.HS512, jwa.NoSignature,
+	jwa.PS256, jwa.PS384, jwa.PS512, jwa.RS256, jwa.RS384, jwa.RS512,
+}
+
+// checkAlgorithmCase rejects algorithm names that only differ in case
+// from one of the standard signature algorithms, such as "hs256" or
+// "None". Such names are never produced by conforming implementations,
+// and may be used to confuse code that compares algorithms loosely
+func checkAlgorithmCase(alg jwa.SignatureAlgorithm) error {
+	for _, known := range standardSignatureAlgorithms {
+		if alg != known && strings.EqualFold(alg.String(), known.String()) {
+			return errors.Errorf(`algorithm %q differs in case from %q`, alg, known)
+		}
+	}
+	return nil
+}
+
+// checkAlgorithm verifies that the "alg" protected header is present,
+// and exactly matches the algorithm that the message is being
+// verified with
+func (c *hardenedCheck) checkAlgorithm(protected Headers) error {
+	if protected == nil {
+		return errors.Errorf(`"alg" header is required`)
+	}
+	alg := protected.Algorithm()
+	if alg == "" {
+		return errors.Errorf(`"alg" header is required`)
+	}
+	if err := checkAlgorithmCase(alg); err != nil {
+		return errors.Wrap(err, `invalid "alg" header`)
+	}
+	if alg != c.alg {
+		return errors.Errorf(`"alg" header %q does not match expected algorithm %q`, alg, c.alg)
+	}
+	return nil
+}
+
+// verify calls the verifier, after rejecting empty signatures
+func (c *hardenedCheck) verify(verifier Verifier, payload, signature []byte, key interface{}) error {
+	if len(signature) == 0 {
+		return errors.New(`signature is empty`)
+	}
+	return verifier.Verify(payload, signature, key)
+}
+
+// zeroizeBuffer overwrites the entire underlying storage of buf with
+// zeros, including the bytes that have already been read or reset
+func zeroizeBuffer(buf *bytes.Buffer) {
+	b := buf.Bytes()
+	zeroize.Bytes(b[:cap(b)])
+}
+
+// rawSigningKey materializes the raw key out of a jwk.Key, so that it
+// can be zeroized after signing. Symmetric keys are copied, as the raw
+// octets share storage with the jwk.Key
+func rawSigningKey(key jwk.Key) (interface{}, error) {
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, errors.Wrapf(err, `failed to retrieve raw key out of %T`, key)
+	}
+	if octets, ok := raw.([]byte); ok {
+		raw = append([]byte(nil), octets...)
+	}
+	return raw, nil
+}
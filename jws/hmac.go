@@ -6,7 +6,9 @@ import (
 	"crypto/sha512"
 	"hash"
 
+	"github.com/lestrrat-go/jwx/internal/fips"
 	"github.com/lestrrat-go/jwx/internal/keyconv"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
 )
@@ -56,6 +58,10 @@ func (s HMACSigner) Sign(payload []byte, key interface{}) ([]byte, error) {
 		return nil, errors.New(`missing key while signing payload`)
 	}
 
+	if err := fips.CheckHMACKey(s.alg, len(hmackey)); err != nil {
+		return nil, err
+	}
+
 	return s.sign(payload, hmackey)
 }
 
@@ -69,6 +75,7 @@ func (v HMACVerifier) Verify(payload, signature []byte, key interface{}) (err er
 	if err != nil {
 		return errors.Wrap(err, `failed to generated signature`)
 	}
+	defer zeroize.Bytes(expected)
 
 	if !hmac.Equal(signature, expected) {
 		return errors.New(`failed to match hmac signature`)
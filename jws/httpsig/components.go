@@ -0,0 +1,212 @@
+package httpsig
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Derived component names, as defined in RFC 9421 section 2.2
+const (
+	ComponentMethod        = `@method`
+	ComponentTargetURI     = `@target-uri`
+	ComponentAuthority     = `@authority`
+	ComponentScheme        = `@scheme`
+	ComponentRequestTarget = `@request-target`
+	ComponentPath          = `@path`
+	ComponentQuery         = `@query`
+	ComponentQueryParam    = `@query-param`
+	ComponentStatus        = `@status`
+)
+
+const componentSignatureParams = `@signature-params`
+
+// message is the part of a request or a response that is covered by
+// a signature
+type message struct {
+	req    *http.Request
+	status int // only set for responses
+	header http.Header
+}
+
+func requestMessage(req *http.Request) *message {
+	return &message{req: req, header: req.Header}
+}
+
+func responseMessage(res *http.Response) *message {
+	return &message{status: res.StatusCode, header: res.Header}
+}
+
+// parseComponent parses a component identifier, which is either a bare
+// name such as `@method` or `content-type`, or a serialized component
+// identifier with parameters such as `"@query-param";name="pet"`
+func parseComponent(s string) (sfItem, error) {
+	if !strings.HasPrefix(s, `"`) {
+		if s == "" {
+			return sfItem{}, errors.New(`empty component identifier`)
+		}
+		return sfItem{value: strings.ToLower(s)}, nil
+	}
+
+	item, err := parseItemString(s)
+	if err != nil {
+		return sfItem{}, errors.Wrapf(err, `invalid component identifier %s`, s)
+	}
+	name, ok := item.value.(string)
+	if !ok {
+		return sfItem{}, errors.Errorf(`invalid component identifier %s: name must be a string`, s)
+	}
+	item.value = strings.ToLower(name)
+	return item, nil
+}
+
+func componentString(item sfItem) string {
+	var sb strings.Builder
+	serializeItem(&sb, item)
+	return sb.String()
+}
+
+// componentValue returns the canonicalized value of the component
+// identified by `item`
+func (m *message) componentValue(item sfItem) (string, error) {
+	name := item.value.(string)
+
+	for _, param := range item.params {
+		if param.key == `name` && name == ComponentQueryParam {
+			continue
+		}
+		return "", errors.Errorf(`unsupported parameter %q for component %q`, param.key, name)
+	}
+
+	if !strings.HasPrefix(name, `@`) {
+		values := m.header.Values(name)
+		if len(values) == 0 {
+			return "", errors.Errorf(`header field %q is not present`, name)
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.TrimSpace(v)
+		}
+		return strings.Join(trimmed, `, `), nil
+	}
+
+	if name == ComponentStatus {
+		if m.req != nil {
+			return "", errors.Errorf(`component %q is only available in responses`, name)
+		}
+		return strconv.Itoa(m.status), nil
+	}
+
+	if m.req == nil {
+		return "", errors.Errorf(`component %q is not available in responses`, name)
+	}
+
+	u := m.req.URL
+	switch name {
+	case ComponentMethod:
+		return m.req.Method, nil
+	case ComponentTargetURI:
+		return requestScheme(m.req) + `://` + requestAuthority(m.req) + requestTarget(u), nil
+	case ComponentAuthority:
+		return requestAuthority(m.req), nil
+	case ComponentScheme:
+		return requestScheme(m.req), nil
+	case ComponentRequestTarget:
+		return requestTarget(u), nil
+	case ComponentPath:
+		if p := u.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return `/`, nil
+	case ComponentQuery:
+		return `?` + u.RawQuery, nil
+	case ComponentQueryParam:
+		v, ok := item.params.get(`name`)
+		if !ok {
+			return "", errors.Errorf(`component %q requires the "name" parameter`, name)
+		}
+		key, ok := v.(string)
+		if !ok {
+			return "", errors.Errorf(`the "name" parameter of component %q must be a string`, name)
+		}
+		values, ok := u.Query()[key]
+		if !ok {
+			return "", errors.Errorf(`query parameter %q is not present`, key)
+		}
+		// Values of a parameter that occurs more than once cannot be
+		// told apart by the verifier, so refuse to cover them
+		if len(values) > 1 {
+			return "", errors.Errorf(`query parameter %q occurs more than once`, key)
+		}
+		return percentEncode(values[0]), nil
+	default:
+		return "", errors.Errorf(`unsupported component %q`, name)
+	}
+}
+
+func requestScheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+	// Requests received by a server do not carry the scheme
+	if req.TLS != nil {
+		return `https`
+	}
+	return `http`
+}
+
+// requestAuthority returns the host and port of the request, in lower
+// case and without the default port of the scheme
+func requestAuthority(req *http.Request) string {
+	authority := req.Host
+	if authority == "" {
+		authority = req.URL.Host
+	}
+	authority = strings.ToLower(authority)
+
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		return authority
+	}
+	switch scheme := requestScheme(req); {
+	case scheme == `http` && port == `80`, scheme == `https` && port == `443`:
+		if strings.Contains(host, `:`) {
+			return `[` + host + `]`
+		}
+		return host
+	}
+	return authority
+}
+
+func requestTarget(u *url.URL) string {
+	target := u.EscapedPath()
+	if target == "" {
+		target = `/`
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		target += `?` + u.RawQuery
+	}
+	return target
+}
+
+// percentEncode encodes all but the unreserved characters of RFC 3986,
+// which is how RFC 9421 requires query parameter values to be encoded
+func percentEncode(s string) string {
+	const hex = `0123456789ABCDEF`
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hex[c>>4])
+		sb.WriteByte(hex[c&0xf])
+	}
+	return sb.String()
+}
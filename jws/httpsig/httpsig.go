@@ -0,0 +1,523 @@
+// Package httpsig implements HTTP Message Signatures as described in
+// RFC 9421, using the signature algorithms of the jws package and
+// the keys of the jwk package.
+//
+// Only the features needed for the common case are supported: the
+// derived components of RFC 9421 section 2.2 (except for "@query-param"
+// values occurring more than once), header fields without parameters,
+// and the "created", "expires", "nonce", "alg", "keyid" and "tag"
+// signature parameters. Components of the request cannot be included
+// in the signature of a response.
+package httpsig
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
+)
+
+// Header field names used by HTTP Message Signatures
+const (
+	SignatureInputHeader = `Signature-Input`
+	SignatureHeader      = `Signature`
+)
+
+// Clock is the interface of the clock used to create and check
+// signature timestamps
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is a function that implements Clock
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+var algorithmNames = map[string]jwa.SignatureAlgorithm{
+	`rsa-pss-sha512`:    jwa.PS512,
+	`rsa-v1_5-sha256`:   jwa.RS256,
+	`hmac-sha256`:       jwa.HS256,
+	`ecdsa-p256-sha256`: jwa.ES256,
+	`ecdsa-p384-sha384`: jwa.ES384,
+	`ed25519`:           jwa.EdDSA,
+}
+
+// Algorithm returns the JWS signature algorithm that corresponds to the
+// HTTP Message Signatures algorithm `name`, such as "ecdsa-p256-sha256"
+func Algorithm(name string) (jwa.SignatureAlgorithm, error) {
+	alg, ok := algorithmNames[name]
+	if !ok {
+		return "", errors.Errorf(`unsupported algorithm %q`, name)
+	}
+	return alg, nil
+}
+
+// AlgorithmName returns the HTTP Message Signatures algorithm name that
+// corresponds to the JWS signature algorithm `alg`. Only the algorithms
+// registered by RFC 9421 have a name.
+func AlgorithmName(alg jwa.SignatureAlgorithm) (string, error) {
+	for name, v := range algorithmNames {
+		if v == alg {
+			return name, nil
+		}
+	}
+	return "", errors.Errorf(`algorithm %q has no HTTP Message Signatures name`, alg)
+}
+
+// Params describes a verified signature
+type Params struct {
+	// Label is the label of the signature in the "Signature-Input" and
+	// "Signature" header fields
+	Label string
+	// Components lists the serialized identifiers of the covered
+	// components, such as `"@method"` or `"@query-param";name="pet"`
+	Components []string
+	// Created and Expires are the zero time.Time if the corresponding
+	// signature parameter is not present
+	Created   time.Time
+	Expires   time.Time
+	Nonce     string
+	Algorithm jwa.SignatureAlgorithm
+	KeyID     string
+	Tag       string
+}
+
+// Covers returns true if the component identified by `component`, in the
+// format accepted by `httpsig.WithComponents()`, is covered by the signature
+func (p *Params) Covers(component string) bool {
+	item, err := parseComponent(component)
+	if err != nil {
+		return false
+	}
+	s := componentString(item)
+	for _, c := range p.Components {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SignRequest signs `req` with the given algorithm and key, and adds the
+// signature to its "Signature-Input" and "Signature" header fields under
+// `label`. Signatures with other labels are left untouched. `key` may be
+// a jwk.Key or a raw key, as in `jws.Sign()`.
+//
+// The body of the request is not covered by the signature. To protect it,
+// set the "Content-Digest" header field (RFC 9530) and include it in
+// the components.
+func SignRequest(req *http.Request, label string, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) error {
+	components := []string{ComponentMethod, ComponentTargetURI}
+	return sign(requestMessage(req), components, label, alg, key, options...)
+}
+
+// SignResponse is the same as SignRequest, but signs a response
+func SignResponse(res *http.Response, label string, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) error {
+	if res.Header == nil {
+		res.Header = make(http.Header)
+	}
+	components := []string{ComponentStatus}
+	return sign(responseMessage(res), components, label, alg, key, options...)
+}
+
+func sign(m *message, components []string, label string, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) error {
+	if _, err := parseKey(label); err != nil {
+		return errors.Wrap(err, `invalid signature label`)
+	}
+
+	var clock Clock = ClockFunc(time.Now)
+	var keyID, nonce, tag string
+	var expiration time.Duration
+	var algParam bool
+	if jwkKey, ok := key.(jwk.Key); ok {
+		keyID = jwkKey.KeyID()
+	}
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identComponents{}:
+			components = option.Value().([]string)
+		case identKeyID{}:
+			keyID = option.Value().(string)
+		case identNonce{}:
+			nonce = option.Value().(string)
+		case identTag{}:
+			tag = option.Value().(string)
+		case identExpiration{}:
+			expiration = option.Value().(time.Duration)
+		case identAlgorithmParameter{}:
+			algParam = option.Value().(bool)
+		case identClock{}:
+			clock = option.Value().(Clock)
+		}
+	}
+
+	created := clock.Now().Unix()
+	params := sfParams{{key: `created`, value: created}}
+	if expiration > 0 {
+		params = append(params, sfParam{key: `expires`, value: created + int64(expiration/time.Second)})
+	}
+	if nonce != "" {
+		params = append(params, sfParam{key: `nonce`, value: nonce})
+	}
+	if algParam {
+		name, err := AlgorithmName(alg)
+		if err != nil {
+			return err
+		}
+		params = append(params, sfParam{key: `alg`, value: name})
+	}
+	if keyID != "" {
+		params = append(params, sfParam{key: `keyid`, value: keyID})
+	}
+	if tag != "" {
+		params = append(params, sfParam{key: `tag`, value: tag})
+	}
+
+	items := make([]sfItem, len(components))
+	for i, c := range components {
+		item, err := parseComponent(c)
+		if err != nil {
+			return err
+		}
+		items[i] = item
+	}
+
+	base, input, err := m.signatureBase(items, params)
+	if err != nil {
+		return errors.Wrap(err, `failed to create signature base`)
+	}
+
+	signer, err := jws.NewSigner(alg)
+	if err != nil {
+		return errors.Wrapf(err, `failed to create signer for %q`, alg)
+	}
+	signature, err := signer.Sign(base, key)
+	if err != nil {
+		return errors.Wrap(err, `failed to sign message`)
+	}
+
+	if err := m.setMember(SignatureInputHeader, label, input); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	serializeBareItem(&sb, signature)
+	return m.setMember(SignatureHeader, label, sb.String())
+}
+
+// VerifyRequest verifies the signature labeled `label` in the
+// "Signature-Input" and "Signature" header fields of `req`, and returns
+// its parameters. If `label` is empty, the request must carry exactly
+// one signature, which is verified.
+//
+// The key is specified using `httpsig.WithKey()` or `httpsig.WithKeySet()`.
+// Expired signatures, and signatures created in the future, are rejected.
+// Note that a valid signature only protects the components that it covers:
+// use `httpsig.WithRequiredComponents()` to make sure that the components
+// that the application relies on are covered.
+func VerifyRequest(req *http.Request, label string, options ...VerifyOption) (*Params, error) {
+	return verify(requestMessage(req), label, options...)
+}
+
+// VerifyResponse is the same as VerifyRequest, but verifies a response
+func VerifyResponse(res *http.Response, label string, options ...VerifyOption) (*Params, error) {
+	return verify(responseMessage(res), label, options...)
+}
+
+func verify(m *message, label string, options ...VerifyOption) (*Params, error) {
+	var clock Clock = ClockFunc(time.Now)
+	var skew, maxAge time.Duration
+	var tag string
+	var hasTag bool
+	var keyParam *keyParams
+	var set jwk.Set
+	var required []string
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identClock{}:
+			clock = option.Value().(Clock)
+		case identAcceptableSkew{}:
+			skew = option.Value().(time.Duration)
+		case identMaxAge{}:
+			maxAge = option.Value().(time.Duration)
+		case identTag{}:
+			tag = option.Value().(string)
+			hasTag = true
+		case identKey{}:
+			keyParam = option.Value().(*keyParams)
+		case identKeySet{}:
+			set = option.Value().(jwk.Set)
+		case identRequiredComponents{}:
+			required = append(required, option.Value().([]string)...)
+		}
+	}
+
+	if keyParam == nil && set == nil {
+		return nil, errors.New(`either httpsig.WithKey() or httpsig.WithKeySet() must be specified`)
+	}
+
+	inputs, err := parseDictionary(strings.Join(m.header.Values(SignatureInputHeader), `, `))
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to parse %q header field`, SignatureInputHeader)
+	}
+	signatures, err := parseDictionary(strings.Join(m.header.Values(SignatureHeader), `, `))
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to parse %q header field`, SignatureHeader)
+	}
+
+	if label == "" {
+		if len(inputs) != 1 {
+			return nil, errors.Errorf(`expected exactly one signature, found %d`, len(inputs))
+		}
+		label = inputs[0].key
+	}
+
+	input, ok := findMember(inputs, label)
+	if !ok || !input.isList {
+		return nil, errors.Errorf(`signature input %q not found`, label)
+	}
+	sigMember, ok := findMember(signatures, label)
+	if !ok || sigMember.item == nil {
+		return nil, errors.Errorf(`signature %q not found`, label)
+	}
+	signature, ok := sigMember.item.value.([]byte)
+	if !ok {
+		return nil, errors.Errorf(`signature %q must be a byte sequence`, label)
+	}
+
+	params, err := newParams(label, input)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range required {
+		if !params.Covers(c) {
+			return nil, errors.Errorf(`required component %s is not covered by the signature`, c)
+		}
+	}
+
+	if hasTag && params.Tag != tag {
+		return nil, errors.Errorf(`signature tag %q does not match %q`, params.Tag, tag)
+	}
+
+	now := clock.Now()
+	if !params.Created.IsZero() && params.Created.After(now.Add(skew)) {
+		return nil, errors.New(`signature was created in the future`)
+	}
+	if !params.Expires.IsZero() && !now.Before(params.Expires.Add(skew)) {
+		return nil, errors.New(`signature has expired`)
+	}
+	if maxAge > 0 {
+		if params.Created.IsZero() {
+			return nil, errors.New(`signature does not have the "created" parameter`)
+		}
+		if now.Sub(params.Created) > maxAge+skew {
+			return nil, errors.New(`signature is too old`)
+		}
+	}
+
+	base, _, err := m.signatureBase(input.list, input.params)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create signature base`)
+	}
+
+	if keyParam != nil {
+		if params.Algorithm != "" && params.Algorithm != keyParam.alg {
+			return nil, errors.Errorf(`signature algorithm %q does not match %q`, params.Algorithm, keyParam.alg)
+		}
+		if err := verifySignature(base, signature, keyParam.alg, keyParam.key); err != nil {
+			return nil, err
+		}
+		return params, nil
+	}
+
+	if params.KeyID == "" {
+		return nil, errors.New(`signature does not have the "keyid" parameter`)
+	}
+	key, ok := set.LookupKeyID(params.KeyID)
+	if !ok {
+		return nil, errors.Errorf(`key %q not found in key set`, params.KeyID)
+	}
+	alg := params.Algorithm
+	if v := key.Algorithm(); v != "" {
+		if alg != "" && jwa.SignatureAlgorithm(v) != alg {
+			return nil, errors.Errorf(`signature algorithm %q does not match the algorithm of key %q`, alg, params.KeyID)
+		}
+		alg = jwa.SignatureAlgorithm(v)
+	}
+	if alg == "" {
+		return nil, errors.Errorf(`could not determine the algorithm for key %q`, params.KeyID)
+	}
+	if err := verifySignature(base, signature, alg, key); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func verifySignature(base, signature []byte, alg jwa.SignatureAlgorithm, key interface{}) error {
+	verifier, err := jws.NewVerifier(alg)
+	if err != nil {
+		return errors.Wrapf(err, `failed to create verifier for %q`, alg)
+	}
+	if err := verifier.Verify(base, signature, key); err != nil {
+		return errors.Wrap(err, `failed to verify signature`)
+	}
+	return nil
+}
+
+func newParams(label string, input sfMember) (*Params, error) {
+	params := &Params{Label: label}
+	for _, item := range input.list {
+		if _, ok := item.value.(string); !ok {
+			return nil, errors.New(`component identifiers must be strings`)
+		}
+		params.Components = append(params.Components, componentString(item))
+	}
+
+	for _, param := range input.params {
+		var ok bool
+		switch param.key {
+		case `created`, `expires`:
+			var v int64
+			if v, ok = param.value.(int64); ok {
+				if param.key == `created` {
+					params.Created = time.Unix(v, 0)
+				} else {
+					params.Expires = time.Unix(v, 0)
+				}
+			}
+		case `alg`:
+			var name string
+			if name, ok = param.value.(string); ok {
+				alg, err := Algorithm(name)
+				if err != nil {
+					return nil, err
+				}
+				params.Algorithm = alg
+			}
+		case `nonce`:
+			params.Nonce, ok = param.value.(string)
+		case `keyid`:
+			params.KeyID, ok = param.value.(string)
+		case `tag`:
+			params.Tag, ok = param.value.(string)
+		default:
+			// unknown parameters are covered by the signature, but
+			// otherwise ignored
+			ok = true
+		}
+		if !ok {
+			return nil, errors.Errorf(`invalid value for signature parameter %q`, param.key)
+		}
+	}
+	return params, nil
+}
+
+func findMember(members []sfMember, key string) (sfMember, bool) {
+	for _, member := range members {
+		if member.key == key {
+			return member, true
+		}
+	}
+	return sfMember{}, false
+}
+
+// signatureBase creates the signature base as described in RFC 9421
+// section 2.5, and returns it along with the serialized signature
+// parameters, which is the value of the signature in the
+// "Signature-Input" header field
+func (m *message) signatureBase(items []sfItem, params sfParams) ([]byte, string, error) {
+	var sb strings.Builder
+	seen := make(map[string]struct{})
+	for _, item := range items {
+		id := componentString(item)
+		if _, ok := seen[id]; ok {
+			return nil, "", errors.Errorf(`component %s is included more than once`, id)
+		}
+		seen[id] = struct{}{}
+
+		value, err := m.componentValue(item)
+		if err != nil {
+			return nil, "", err
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, "", errors.Errorf(`value of component %s contains a line break`, id)
+		}
+		sb.WriteString(id)
+		sb.WriteString(`: `)
+		sb.WriteString(value)
+		sb.WriteByte('\n')
+	}
+
+	var input strings.Builder
+	serializeInnerList(&input, items, params)
+
+	sb.WriteString(componentString(sfItem{value: componentSignatureParams}))
+	sb.WriteString(`: `)
+	sb.WriteString(input.String())
+	return []byte(sb.String()), input.String(), nil
+}
+
+// setMember replaces the member `key` of the dictionary header field
+// `name` with `value`, keeping the other members
+func (m *message) setMember(name, key, value string) error {
+	existing := m.header.Values(name)
+	var members []sfMember
+	if len(existing) > 0 {
+		var err error
+		members, err = parseDictionary(strings.Join(existing, `, `))
+		if err != nil {
+			return errors.Wrapf(err, `failed to parse %q header field`, name)
+		}
+	}
+
+	var sb strings.Builder
+	for _, member := range members {
+		if member.key == key {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(`, `)
+		}
+		sb.WriteString(member.key)
+		switch {
+		case member.isList:
+			sb.WriteByte('=')
+			serializeInnerList(&sb, member.list, member.params)
+		case member.item.value == true:
+			serializeParams(&sb, member.item.params)
+		default:
+			sb.WriteByte('=')
+			serializeItem(&sb, *member.item)
+		}
+	}
+	if sb.Len() > 0 {
+		sb.WriteString(`, `)
+	}
+	sb.WriteString(key)
+	sb.WriteByte('=')
+	sb.WriteString(value)
+
+	m.header.Set(name, sb.String())
+	return nil
+}
+
+func parseKey(s string) (string, error) {
+	p := &sfParser{s: s}
+	key, err := p.parseKey()
+	if err != nil {
+		return "", err
+	}
+	if !p.eof() {
+		return "", errors.Errorf(`invalid key %q`, s)
+	}
+	return key, nil
+}
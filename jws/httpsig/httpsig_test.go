@@ -0,0 +1,225 @@
+package httpsig_test
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws/httpsig"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, `https://example.com/foo?param=Value&Pet=dog`, strings.NewReader(`{"hello": "world"}`))
+	if !assert.NoError(t, err, `http.NewRequest should succeed`) {
+		return nil
+	}
+	req.Header.Set(`Date`, `Tue, 20 Apr 2021 02:07:55 GMT`)
+	req.Header.Set(`Content-Type`, `application/json`)
+	req.Header.Set(`Content-Length`, `18`)
+	return req
+}
+
+func TestRFC9421(t *testing.T) {
+	// RFC 9421 Appendix B.2.6
+	der, err := base64.StdEncoding.DecodeString(`MC4CAQAwBQYDK2VwBCIEIJ+DYvh6SEqVTm50DFtMDoQikTmiCqirVv9mWG9qfSnF`)
+	if !assert.NoError(t, err, `base64 decode should succeed`) {
+		return
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if !assert.NoError(t, err, `x509.ParsePKCS8PrivateKey should succeed`) {
+		return
+	}
+	priv := parsed.(ed25519.PrivateKey)
+
+	const input = `sig-b26=("date" "@method" "@path" "@authority" "content-type" "content-length");created=1618884473;keyid="test-key-ed25519"`
+	const signature = `sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:`
+	clock := httpsig.ClockFunc(func() time.Time { return time.Unix(1618884473, 0) })
+
+	t.Run("Sign", func(t *testing.T) {
+		req := newRequest(t)
+		err := httpsig.SignRequest(req, `sig-b26`, jwa.EdDSA, priv,
+			httpsig.WithComponents(`date`, `@method`, `@path`, `@authority`, `content-type`, `content-length`),
+			httpsig.WithKeyID(`test-key-ed25519`),
+			httpsig.WithClock(clock),
+		)
+		if !assert.NoError(t, err, `httpsig.SignRequest should succeed`) {
+			return
+		}
+		if !assert.Equal(t, input, req.Header.Get(httpsig.SignatureInputHeader), `Signature-Input should match`) {
+			return
+		}
+		if !assert.Equal(t, signature, req.Header.Get(httpsig.SignatureHeader), `Signature should match`) {
+			return
+		}
+	})
+	t.Run("Verify", func(t *testing.T) {
+		req := newRequest(t)
+		req.Header.Set(httpsig.SignatureInputHeader, input)
+		req.Header.Set(httpsig.SignatureHeader, signature)
+
+		params, err := httpsig.VerifyRequest(req, `sig-b26`, httpsig.WithKey(jwa.EdDSA, priv.Public()), httpsig.WithClock(clock))
+		if !assert.NoError(t, err, `httpsig.VerifyRequest should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `test-key-ed25519`, params.KeyID, `keyid should match`) {
+			return
+		}
+		if !assert.True(t, params.Covers(`@authority`), `@authority should be covered`) {
+			return
+		}
+		if !assert.False(t, params.Covers(`@query`), `@query should not be covered`) {
+			return
+		}
+
+		req.Header.Set(`Content-Type`, `text/plain`)
+		_, err = httpsig.VerifyRequest(req, `sig-b26`, httpsig.WithKey(jwa.EdDSA, priv.Public()), httpsig.WithClock(clock))
+		if !assert.Error(t, err, `httpsig.VerifyRequest should fail for a modified request`) {
+			return
+		}
+	})
+}
+
+func TestSignVerify(t *testing.T) {
+	raw, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	key, err := jwk.New(raw)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	key.Set(jwk.KeyIDKey, `my-key`)
+	key.Set(jwk.AlgorithmKey, jwa.ES256)
+
+	pubkey, err := jwk.PublicKeyOf(key)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	set := jwk.NewSet()
+	set.Add(pubkey)
+
+	now := time.Unix(1618884473, 0)
+	clock := httpsig.ClockFunc(func() time.Time { return now })
+
+	t.Run("Request", func(t *testing.T) {
+		req := newRequest(t)
+		// an unrelated signature must be preserved
+		req.Header.Set(httpsig.SignatureInputHeader, `other=("@method");created=1`)
+		req.Header.Set(httpsig.SignatureHeader, `other=:AAAA:`)
+
+		err := httpsig.SignRequest(req, `sig1`, jwa.ES256, key,
+			httpsig.WithComponents(`@method`, `@target-uri`, `"@query-param";name="Pet"`, `Content-Type`),
+			httpsig.WithClock(clock),
+			httpsig.WithExpiration(time.Minute),
+			httpsig.WithNonce(`abc`),
+			httpsig.WithTag(`app`),
+			httpsig.WithAlgorithmParameter(true),
+		)
+		if !assert.NoError(t, err, `httpsig.SignRequest should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `other=("@method");created=1, sig1=("@method" "@target-uri" "@query-param";name="Pet" "content-type");created=1618884473;expires=1618884533;nonce="abc";alg="ecdsa-p256-sha256";keyid="my-key";tag="app"`, req.Header.Get(httpsig.SignatureInputHeader), `Signature-Input should match`) {
+			return
+		}
+
+		params, err := httpsig.VerifyRequest(req, `sig1`,
+			httpsig.WithKeySet(set),
+			httpsig.WithClock(clock),
+			httpsig.WithTag(`app`),
+			httpsig.WithRequiredComponents(`@method`, `content-type`),
+		)
+		if !assert.NoError(t, err, `httpsig.VerifyRequest should succeed`) {
+			return
+		}
+		if !assert.Equal(t, jwa.ES256, params.Algorithm, `alg should match`) {
+			return
+		}
+		if !assert.Equal(t, `abc`, params.Nonce, `nonce should match`) {
+			return
+		}
+		if !assert.Equal(t, now.Add(time.Minute), params.Expires, `expires should match`) {
+			return
+		}
+
+		testcases := []struct {
+			Name    string
+			Options []httpsig.VerifyOption
+		}{
+			{
+				Name:    "wrong tag",
+				Options: []httpsig.VerifyOption{httpsig.WithKeySet(set), httpsig.WithClock(clock), httpsig.WithTag(`other`)},
+			},
+			{
+				Name:    "missing required component",
+				Options: []httpsig.VerifyOption{httpsig.WithKeySet(set), httpsig.WithClock(clock), httpsig.WithRequiredComponents(`@status`)},
+			},
+			{
+				Name:    "expired",
+				Options: []httpsig.VerifyOption{httpsig.WithKeySet(set), httpsig.WithClock(httpsig.ClockFunc(func() time.Time { return now.Add(time.Hour) }))},
+			},
+			{
+				Name:    "too old",
+				Options: []httpsig.VerifyOption{httpsig.WithKeySet(set), httpsig.WithClock(httpsig.ClockFunc(func() time.Time { return now.Add(30 * time.Second) })), httpsig.WithMaxAge(10 * time.Second)},
+			},
+			{
+				Name:    "created in the future",
+				Options: []httpsig.VerifyOption{httpsig.WithKeySet(set), httpsig.WithClock(httpsig.ClockFunc(func() time.Time { return now.Add(-time.Hour) }))},
+			},
+			{
+				Name:    "algorithm mismatch",
+				Options: []httpsig.VerifyOption{httpsig.WithKey(jwa.ES384, pubkey), httpsig.WithClock(clock)},
+			},
+			{
+				Name:    "no key",
+				Options: []httpsig.VerifyOption{httpsig.WithClock(clock)},
+			},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := httpsig.VerifyRequest(req, `sig1`, tc.Options...)
+				if !assert.Error(t, err, `httpsig.VerifyRequest should fail`) {
+					return
+				}
+			})
+		}
+
+		req.URL.RawQuery = `param=Value&Pet=cat`
+		_, err = httpsig.VerifyRequest(req, `sig1`, httpsig.WithKeySet(set), httpsig.WithClock(clock))
+		if !assert.Error(t, err, `httpsig.VerifyRequest should fail for a modified query parameter`) {
+			return
+		}
+	})
+	t.Run("Response", func(t *testing.T) {
+		res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+		res.Header.Set(`Content-Type`, `application/json`)
+
+		err := httpsig.SignResponse(res, `sig1`, jwa.ES256, key, httpsig.WithClock(clock))
+		if !assert.NoError(t, err, `httpsig.SignResponse should succeed`) {
+			return
+		}
+
+		params, err := httpsig.VerifyResponse(res, "", httpsig.WithKey(jwa.ES256, pubkey), httpsig.WithClock(clock))
+		if !assert.NoError(t, err, `httpsig.VerifyResponse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{`"@status"`}, params.Components, `components should match`) {
+			return
+		}
+
+		res.StatusCode = http.StatusNotFound
+		_, err = httpsig.VerifyResponse(res, "", httpsig.WithKey(jwa.ES256, pubkey), httpsig.WithClock(clock))
+		if !assert.Error(t, err, `httpsig.VerifyResponse should fail for a modified status`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package httpsig
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// SignOption describes an Option that can be passed to `httpsig.SignRequest()`
+// and `httpsig.SignResponse()`
+type SignOption interface {
+	Option
+	signOption()
+}
+
+type signOption struct {
+	Option
+}
+
+func (*signOption) signOption() {}
+
+// VerifyOption describes an Option that can be passed to `httpsig.VerifyRequest()`
+// and `httpsig.VerifyResponse()`
+type VerifyOption interface {
+	Option
+	verifyOption()
+}
+
+type verifyOption struct {
+	Option
+}
+
+func (*verifyOption) verifyOption() {}
+
+// SignVerifyOption describes an Option that can be passed to both
+// the signing and the verification functions
+type SignVerifyOption interface {
+	SignOption
+	VerifyOption
+}
+
+type signVerifyOption struct {
+	Option
+}
+
+func (*signVerifyOption) signOption()   {}
+func (*signVerifyOption) verifyOption() {}
+
+type identAcceptableSkew struct{}
+type identAlgorithmParameter struct{}
+type identClock struct{}
+type identComponents struct{}
+type identExpiration struct{}
+type identKey struct{}
+type identKeyID struct{}
+type identKeySet struct{}
+type identMaxAge struct{}
+type identNonce struct{}
+type identRequiredComponents struct{}
+type identTag struct{}
+
+type keyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+// WithComponents specifies the components of the message that are
+// covered by the signature, in order. Each component is either the name
+// of a header field, the name of a derived component such as `@method`,
+// or a serialized component identifier with parameters such as
+// `"@query-param";name="pet"`.
+//
+// By default `@method` and `@target-uri` are covered for requests, and
+// `@status` is covered for responses.
+func WithComponents(components ...string) SignOption {
+	return &signOption{option.New(identComponents{}, components)}
+}
+
+// WithKeyID specifies the value of the "keyid" signature parameter.
+// If the key used to sign is a jwk.Key, its "kid" is used by default
+func WithKeyID(s string) SignOption {
+	return &signOption{option.New(identKeyID{}, s)}
+}
+
+// WithNonce specifies the value of the "nonce" signature parameter
+func WithNonce(s string) SignOption {
+	return &signOption{option.New(identNonce{}, s)}
+}
+
+// WithExpiration specifies the lifetime of the signature. When specified,
+// the "expires" signature parameter is set to the creation time plus `d`
+func WithExpiration(d time.Duration) SignOption {
+	return &signOption{option.New(identExpiration{}, d)}
+}
+
+// WithAlgorithmParameter specifies whether the "alg" signature parameter
+// is included in the signature. It is not included by default, as RFC 9421
+// recommends that the algorithm is derived from the key instead.
+func WithAlgorithmParameter(b bool) SignOption {
+	return &signOption{option.New(identAlgorithmParameter{}, b)}
+}
+
+// WithTag specifies the value of the "tag" signature parameter. When
+// verifying, the signature is rejected unless its "tag" matches `s`
+func WithTag(s string) SignVerifyOption {
+	return &signVerifyOption{option.New(identTag{}, s)}
+}
+
+// WithClock specifies the clock used to set the "created" signature
+// parameter when signing, and to check the "created" and "expires"
+// signature parameters when verifying
+func WithClock(c Clock) SignVerifyOption {
+	return &signVerifyOption{option.New(identClock{}, c)}
+}
+
+// WithKey specifies the algorithm and the key used to verify the
+// signature. If the signature carries the "alg" parameter, it must
+// match `alg`
+func WithKey(alg jwa.SignatureAlgorithm, key interface{}) VerifyOption {
+	return &verifyOption{option.New(identKey{}, &keyParams{alg: alg, key: key})}
+}
+
+// WithKeySet specifies the key set from which the key used to verify the
+// signature is chosen, using the "keyid" signature parameter. The algorithm
+// is taken from the "alg" field of the key, or from the "alg" signature
+// parameter if the key does not have one
+func WithKeySet(set jwk.Set) VerifyOption {
+	return &verifyOption{option.New(identKeySet{}, set)}
+}
+
+// WithRequiredComponents specifies the components that must be covered
+// by the signature, in the same format as `httpsig.WithComponents()`
+func WithRequiredComponents(components ...string) VerifyOption {
+	return &verifyOption{option.New(identRequiredComponents{}, components)}
+}
+
+// WithMaxAge specifies the maximum age of the signature, as determined
+// by its "created" signature parameter. When specified, signatures
+// without the "created" parameter are rejected
+func WithMaxAge(d time.Duration) VerifyOption {
+	return &verifyOption{option.New(identMaxAge{}, d)}
+}
+
+// WithAcceptableSkew specifies the amount of clock skew that is
+// tolerated when checking the "created" and "expires" signature parameters
+func WithAcceptableSkew(d time.Duration) VerifyOption {
+	return &verifyOption{option.New(identAcceptableSkew{}, d)}
+}
@@ -0,0 +1,395 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the subset of Structured Field Values for HTTP
+// (RFC 8941) that is required to handle the "Signature-Input" and
+// "Signature" fields: dictionaries whose members are inner lists or
+// items, with parameters. Decimals are not supported.
+
+// sfToken is a token, as opposed to a string
+type sfToken string
+
+type sfParam struct {
+	key   string
+	value interface{}
+}
+
+type sfParams []sfParam
+
+func (p sfParams) get(key string) (interface{}, bool) {
+	for _, param := range p {
+		if param.key == key {
+			return param.value, true
+		}
+	}
+	return nil, false
+}
+
+type sfItem struct {
+	value  interface{}
+	params sfParams
+}
+
+type sfMember struct {
+	key    string
+	item   *sfItem
+	isList bool
+	list   []sfItem
+	params sfParams // parameters of the inner list
+}
+
+type sfParser struct {
+	s   string
+	pos int
+}
+
+func (p *sfParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *sfParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *sfParser) skipSP() {
+	for !p.eof() && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func parseDictionary(s string) ([]sfMember, error) {
+	p := &sfParser{s: strings.TrimSpace(s)}
+	var members []sfMember
+	for !p.eof() {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		member := sfMember{key: key}
+		if p.peek() == '=' {
+			p.pos++
+			if p.peek() == '(' {
+				member.isList = true
+				member.list, member.params, err = p.parseInnerList()
+			} else {
+				var item sfItem
+				item, err = p.parseItem()
+				member.item = &item
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, `invalid value for member %q`, key)
+			}
+		} else {
+			params, err := p.parseParams()
+			if err != nil {
+				return nil, err
+			}
+			member.item = &sfItem{value: true, params: params}
+		}
+
+		// later members override earlier ones with the same key
+		replaced := false
+		for i := range members {
+			if members[i].key == key {
+				members[i] = member
+				replaced = true
+			}
+		}
+		if !replaced {
+			members = append(members, member)
+		}
+
+		p.skipOWS()
+		if p.eof() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, errors.Errorf(`expected ',' at position %d`, p.pos)
+		}
+		p.pos++
+		p.skipOWS()
+		if p.eof() {
+			return nil, errors.New(`trailing ',' in dictionary`)
+		}
+	}
+	return members, nil
+}
+
+// parseItemString parses a single item with parameters, such as
+// a component identifier
+func parseItemString(s string) (sfItem, error) {
+	p := &sfParser{s: strings.TrimSpace(s)}
+	item, err := p.parseItem()
+	if err != nil {
+		return sfItem{}, err
+	}
+	if !p.eof() {
+		return sfItem{}, errors.Errorf(`unexpected character at position %d`, p.pos)
+	}
+	return item, nil
+}
+
+func (p *sfParser) parseInnerList() ([]sfItem, sfParams, error) {
+	p.pos++ // '('
+	var items []sfItem
+	for {
+		p.skipSP()
+		if p.eof() {
+			return nil, nil, errors.New(`unterminated inner list`)
+		}
+		if p.peek() == ')' {
+			p.pos++
+			params, err := p.parseParams()
+			if err != nil {
+				return nil, nil, err
+			}
+			return items, params, nil
+		}
+		item, err := p.parseItem()
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, nil, errors.Errorf(`expected ' ' or ')' at position %d`, p.pos)
+		}
+	}
+}
+
+func (p *sfParser) parseItem() (sfItem, error) {
+	value, err := p.parseBareItem()
+	if err != nil {
+		return sfItem{}, err
+	}
+	params, err := p.parseParams()
+	if err != nil {
+		return sfItem{}, err
+	}
+	return sfItem{value: value, params: params}, nil
+}
+
+func (p *sfParser) parseParams() (sfParams, error) {
+	var params sfParams
+	for p.peek() == ';' {
+		p.pos++
+		p.skipSP()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var value interface{} = true
+		if p.peek() == '=' {
+			p.pos++
+			value, err = p.parseBareItem()
+			if err != nil {
+				return nil, errors.Wrapf(err, `invalid value for parameter %q`, key)
+			}
+		}
+
+		replaced := false
+		for i := range params {
+			if params[i].key == key {
+				params[i].value = value
+				replaced = true
+			}
+		}
+		if !replaced {
+			params = append(params, sfParam{key: key, value: value})
+		}
+	}
+	return params, nil
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	start := p.pos
+	if c := p.peek(); !(c >= 'a' && c <= 'z') && c != '*' {
+		return "", errors.Errorf(`invalid key at position %d`, p.pos)
+	}
+	for !p.eof() {
+		c := p.s[p.pos]
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '-' || c == '.' || c == '*' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *sfParser) parseBareItem() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseInteger()
+	case c == '"':
+		return p.parseString()
+	case c == ':':
+		return p.parseByteSequence()
+	case c == '?':
+		return p.parseBoolean()
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '*':
+		return p.parseToken(), nil
+	default:
+		return nil, errors.Errorf(`invalid item at position %d`, p.pos)
+	}
+}
+
+func (p *sfParser) parseInteger() (int64, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for !p.eof() && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.peek() == '.' {
+		return 0, errors.New(`decimals are not supported`)
+	}
+	if p.pos-start > 16 {
+		return 0, errors.New(`integer is too long`)
+	}
+	return strconv.ParseInt(p.s[start:p.pos], 10, 64)
+}
+
+func (p *sfParser) parseString() (string, error) {
+	p.pos++ // '"'
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			if p.eof() {
+				return "", errors.New(`unterminated string`)
+			}
+			next := p.s[p.pos]
+			if next != '"' && next != '\\' {
+				return "", errors.Errorf(`invalid escape at position %d`, p.pos)
+			}
+			sb.WriteByte(next)
+			p.pos++
+		case c == '"':
+			return sb.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", errors.Errorf(`invalid character in string at position %d`, p.pos-1)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", errors.New(`unterminated string`)
+}
+
+func (p *sfParser) parseByteSequence() ([]byte, error) {
+	p.pos++ // ':'
+	end := strings.IndexByte(p.s[p.pos:], ':')
+	if end < 0 {
+		return nil, errors.New(`unterminated byte sequence`)
+	}
+	encoded := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, `invalid byte sequence`)
+	}
+	return buf, nil
+}
+
+func (p *sfParser) parseBoolean() (bool, error) {
+	p.pos++ // '?'
+	switch p.peek() {
+	case '1':
+		p.pos++
+		return true, nil
+	case '0':
+		p.pos++
+		return false, nil
+	default:
+		return false, errors.Errorf(`invalid boolean at position %d`, p.pos)
+	}
+}
+
+func (p *sfParser) parseToken() sfToken {
+	start := p.pos
+	for !p.eof() {
+		c := p.s[p.pos]
+		if c > 0x20 && c < 0x7f && !strings.ContainsRune(`"(),;<=>?@[\]{}`, rune(c)) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return sfToken(p.s[start:p.pos])
+}
+
+func serializeBareItem(sb *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		sb.WriteByte('"')
+		for i := 0; i < len(v); i++ {
+			if v[i] == '"' || v[i] == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(v[i])
+		}
+		sb.WriteByte('"')
+	case sfToken:
+		sb.WriteString(string(v))
+	case int64:
+		sb.WriteString(strconv.FormatInt(v, 10))
+	case bool:
+		if v {
+			sb.WriteString(`?1`)
+		} else {
+			sb.WriteString(`?0`)
+		}
+	case []byte:
+		sb.WriteByte(':')
+		sb.WriteString(base64.StdEncoding.EncodeToString(v))
+		sb.WriteByte(':')
+	}
+}
+
+func serializeParams(sb *strings.Builder, params sfParams) {
+	for _, param := range params {
+		sb.WriteByte(';')
+		sb.WriteString(param.key)
+		if b, ok := param.value.(bool); ok && b {
+			continue
+		}
+		sb.WriteByte('=')
+		serializeBareItem(sb, param.value)
+	}
+}
+
+func serializeItem(sb *strings.Builder, item sfItem) {
+	serializeBareItem(sb, item.value)
+	serializeParams(sb, item.params)
+}
+
+func serializeInnerList(sb *strings.Builder, items []sfItem, params sfParams) {
+	sb.WriteByte('(')
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		serializeItem(sb, item)
+	}
+	sb.WriteByte(')')
+	serializeParams(sb, params)
+}
@@ -0,0 +1,190 @@
+package jws
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/pool"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// HybridPolicy specifies which of the signatures in a hybrid JWS
+// message must be verified for VerifyHybrid to succeed.
+type HybridPolicy int
+
+const (
+	// HybridRequireBoth requires both the classical and the
+	// post-quantum signatures to be verified. This is the default.
+	HybridRequireBoth HybridPolicy = iota
+	// HybridRequireEither requires at least one of the signatures
+	// to be verified. This is useful during migration periods where
+	// some verifiers may not yet be able to process one of the algorithms.
+	HybridRequireEither
+)
+
+// SignHybrid creates a JWS message in JSON serialization format that
+// carries two signatures over the same payload: one using a classical
+// algorithm (e.g. ES256) and another using a post-quantum algorithm
+// (e.g. ML-DSA-65). Each signature has its own protected header
+// containing the respective "alg" and, if the key is a jwk.Key with
+// a key ID, the "kid".
+//
+// The layout follows the composite signature approach of the emerging
+// JOSE drafts for post-quantum migration, where the message remains
+// verifiable by parties that only understand one of the algorithms.
+//
+// Headers specified via WithHeaders are included in both protected headers.
+func SignHybrid(payload []byte, classicalAlg jwa.SignatureAlgorithm, classicalKey interface{}, pqAlg jwa.SignatureAlgorithm, pqKey interface{}, options ...SignOption) ([]byte, error) {
+	var hdrs Headers
+	var signers []Option
+	for _, o := range options {
+		//nolint:forcetypeassert
+		switch o.Ident() {
+		case identHeaders{}:
+			hdrs = o.Value().(Headers)
+		case identSigningPolicy{}:
+			signers = append(signers, o)
+		}
+	}
+
+	if classicalAlg == pqAlg {
+		return nil, errors.Errorf(`classical and post-quantum algorithms must differ (both are %s)`, classicalAlg)
+	}
+
+	for _, pair := range []struct {
+		alg jwa.SignatureAlgorithm
+		key interface{}
+	}{
+		{alg: classicalAlg, key: classicalKey},
+		{alg: pqAlg, key: pqKey},
+	} {
+		signer, err := NewSigner(pair.alg)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create signer for %s`, pair.alg)
+		}
+
+		protected := NewHeaders()
+		if hdrs != nil {
+			if err := hdrs.Copy(context.Background(), protected); err != nil {
+				return nil, errors.Wrap(err, `failed to copy headers`)
+			}
+		}
+		// Signature.Sign only adds "kid" to the headers used to compute
+		// the signature, so make sure it is also serialized
+		if jwkKey, ok := pair.key.(jwk.Key); ok {
+			if kid := jwkKey.KeyID(); kid != "" {
+				if err := protected.Set(KeyIDKey, kid); err != nil {
+					return nil, errors.Wrap(err, `failed to set "kid"`)
+				}
+			}
+		}
+		signers = append(signers, WithSigner(signer, pair.key, nil, protected))
+	}
+
+	return SignMulti(payload, signers...)
+}
+
+// VerifyHybrid verifies a JWS message in JSON serialization format
+// created by SignHybrid (or any other JWS message that carries
+// signatures using both algorithms).
+//
+// By default both signatures must be verified. Pass
+// `jws.WithHybridPolicy(jws.HybridRequireEither)` to accept messages
+// where only one of them could be verified.
+//
+// Signatures are matched to keys by the "alg" field in their
+// protected headers.
+func VerifyHybrid(buf []byte, classicalAlg jwa.SignatureAlgorithm, classicalKey interface{}, pqAlg jwa.SignatureAlgorithm, pqKey interface{}, options ...VerifyOption) ([]byte, error) {
+	var dst *Message
+	policy := HybridRequireBoth
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identMessage{}:
+			dst = option.Value().(*Message)
+		case identHybridPolicy{}:
+			policy = option.Value().(HybridPolicy)
+		}
+	}
+
+	buf = bytes.TrimSpace(buf)
+	if len(buf) == 0 || buf[0] != '{' {
+		return nil, errors.New(`hybrid JWS messages must be in JSON serialization format`)
+	}
+
+	var m Message
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, errors.Wrap(err, `failed to unmarshal JSON message`)
+	}
+
+	classicalErr := verifyHybridSignature(&m, classicalAlg, classicalKey)
+	pqErr := verifyHybridSignature(&m, pqAlg, pqKey)
+
+	switch policy {
+	case HybridRequireEither:
+		if classicalErr != nil && pqErr != nil {
+			return nil, errors.Errorf(`failed to verify any of the signatures: %s: %s, %s: %s`, classicalAlg, classicalErr, pqAlg, pqErr)
+		}
+	default:
+		if classicalErr != nil {
+			return nil, errors.Wrapf(classicalErr, `failed to verify %s signature`, classicalAlg)
+		}
+		if pqErr != nil {
+			return nil, errors.Wrapf(pqErr, `failed to verify %s signature`, pqAlg)
+		}
+	}
+
+	if dst != nil {
+		*dst = m
+	}
+	return m.payload, nil
+}
+
+func verifyHybridSignature(m *Message, alg jwa.SignatureAlgorithm, key interface{}) error {
+	verifier, err := NewVerifier(alg)
+	if err != nil {
+		return errors.Wrap(err, `failed to create verifier`)
+	}
+
+	payload := base64.EncodeToString(m.payload)
+
+	buf := pool.GetBytesBufferSize(len(payload) + signatureSlack)
+	defer pool.ReleaseBytesBuffer(buf)
+
+	var found bool
+	for i, sig := range m.signatures {
+		if sig.protected == nil || sig.protected.Algorithm() != alg {
+			continue
+		}
+		found = true
+
+		if kid := sig.protected.KeyID(); kid != "" {
+			if jwkKey, ok := key.(jwk.Key); ok && jwkKey.KeyID() != kid {
+				continue
+			}
+		}
+
+		protected, err := json.Marshal(sig.protected)
+		if err != nil {
+			return errors.Wrapf(err, `failed to marshal "protected" for signature #%d`, i+1)
+		}
+
+		buf.Reset()
+		buf.WriteString(base64.EncodeToString(protected))
+		buf.WriteByte('.')
+		buf.WriteString(payload)
+
+		if err := verifier.Verify(buf.Bytes(), sig.signature, key); err == nil {
+			return nil
+		}
+	}
+
+	if !found {
+		return errors.Errorf(`no signature using %s found`, alg)
+	}
+	return errors.New(`could not verify with any of the signatures`)
+}
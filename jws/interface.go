@@ -57,6 +57,12 @@ type Signature struct {
 	headers   Headers // Unprotected Headers
 	protected Headers // Protected Headers
 	signature []byte  // Signature
+
+	// policy is checked when signing, if specified
+	policy *SigningPolicy
+
+	// zeroize is true if transient buffers are zeroized after signing
+	zeroize bool
 }
 
 type Visitor = iter.MapVisitor
@@ -102,6 +108,7 @@ type HMACSigner struct {
 }
 
 type EdDSASigner struct {
+	options *EdDSAOptions
 }
 
 type Verifier interface {
@@ -132,4 +139,5 @@ type HMACVerifier struct {
 }
 
 type EdDSAVerifier struct {
+	options *EdDSAOptions
 }
@@ -25,6 +25,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto"
 	"io"
 	"io/ioutil"
 	"strings"
@@ -32,8 +33,11 @@ import (
 	"unicode/utf8"
 
 	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/hook"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
 	"github.com/lestrrat-go/jwx/internal/pool"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/pkg/errors"
@@ -80,21 +84,107 @@ func (s *payloadSigner) PublicHeader() Headers {
 //
 // If you would like to pass custom headers, use the WithHeaders option.
 func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	return sign(context.Background(), payload, alg, key, options...)
+}
+
+// SignContext is the same as Sign, but accepts a context.Context.
+// An error is returned without signing if the context has already
+// been canceled. If the key is a `jws.ContextSigner`, the context
+// is also passed to it.
+func SignContext(ctx context.Context, payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `failed to sign payload`)
+	}
+	return sign(ctx, payload, alg, bindSignerContext(ctx, key), options...)
+}
+
+func sign(ctx context.Context, payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
 	var hdrs Headers
+	var provider SigningKeyProvider
+	var typ, cty *string
+	var edopts *EdDSAOptions
+	var policy *SigningPolicy
+	var zeroizeBuffers bool
 	for _, o := range options {
 		//nolint:forcetypeassert
 		switch o.Ident() {
+		case identSigningPolicy{}:
+			policy = o.Value().(*SigningPolicy)
+		case identZeroizeBuffers{}:
+			zeroizeBuffers = o.Value().(bool)
+		case identEdDSAOptions{}:
+			edopts = o.Value().(*EdDSAOptions)
 		case identHeaders{}:
 			hdrs = o.Value().(Headers)
+		case identKeyProviderForSigning{}:
+			provider = o.Value().(SigningKeyProvider)
+		case identType{}:
+			v := o.Value().(string)
+			typ = &v
+		case identContentType{}:
+			v := o.Value().(string)
+			cty = &v
+		}
+	}
+
+	if typ != nil || cty != nil {
+		// Do not modify the headers passed by the user, as they
+		// may be shared between calls
+		h := NewHeaders()
+		if hdrs != nil {
+			if err := hdrs.Copy(ctx, h); err != nil {
+				return nil, errors.Wrap(err, `failed to copy headers`)
+			}
+		}
+		if typ != nil {
+			if err := h.Set(TypeKey, *typ); err != nil {
+				return nil, errors.Wrap(err, `failed to set "typ"`)
+			}
+		}
+		if cty != nil {
+			if err := h.Set(ContentTypeKey, *cty); err != nil {
+				return nil, errors.Wrap(err, `failed to set "cty"`)
+			}
 		}
+		hdrs = h
 	}
 
-	signer, err := NewSigner(alg)
+	if provider != nil {
+		palg, pkey, kid, err := provider(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to obtain signing key from key provider`)
+		}
+		alg = palg
+		key = bindSignerContext(ctx, pkey)
+
+		if kid != "" {
+			// Do not modify the headers passed by the user, as they
+			// may be shared between calls
+			h := NewHeaders()
+			if hdrs != nil {
+				if err := hdrs.Copy(ctx, h); err != nil {
+					return nil, errors.Wrap(err, `failed to copy headers`)
+				}
+			}
+			if err := h.Set(KeyIDKey, kid); err != nil {
+				return nil, errors.Wrap(err, `failed to set "kid"`)
+			}
+			hdrs = h
+		}
+	}
+
+	var signer Signer
+	var err error
+	if edopts != nil {
+		signer, err = newEdDSASignerWithOptions(alg, edopts)
+	} else {
+		signer, err = NewSigner(alg)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to create signer`)
 	}
 
-	sig := &Signature{protected: hdrs}
+	sig := &Signature{protected: hdrs, policy: policy, zeroize: zeroizeBuffers}
 	_, signature, err := sig.Sign(payload, signer, key)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed sign payload`)
@@ -111,10 +201,19 @@ func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options .
 // each signature in the `"signatures": [ ... ]` field.
 func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 	var signers []*payloadSigner
+	var policy *SigningPolicy
+	var zeroizeBuffers bool
+	var ts Timestamper
 	for _, o := range options {
 		switch o.Ident() {
+		case identTimestamper{}:
+			ts = o.Value().(Timestamper)
 		case identPayloadSigner{}:
 			signers = append(signers, o.Value().(*payloadSigner))
+		case identSigningPolicy{}:
+			policy = o.Value().(*SigningPolicy)
+		case identZeroizeBuffers{}:
+			zeroizeBuffers = o.Value().(bool)
 		}
 	}
 
@@ -140,12 +239,20 @@ func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 		sig := &Signature{
 			headers:   signer.PublicHeader(),
 			protected: protected,
+			policy:    policy,
+			zeroize:   zeroizeBuffers,
 		}
 		_, _, err := sig.Sign(payload, signer.signer, signer.key)
 		if err != nil {
 			return nil, errors.Wrapf(err, `failed to generate signature for signer #%d (alg=%s)`, i, signer.Algorithm())
 		}
 
+		if ts != nil {
+			if err := timestampSignature(context.Background(), ts, sig); err != nil {
+				return nil, errors.Wrapf(err, `failed to timestamp signature for signer #%d (alg=%s)`, i, signer.Algorithm())
+			}
+		}
+
 		result.signatures = append(result.signatures, sig)
 	}
 
@@ -162,12 +269,66 @@ func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 // If you need to access signatures and JOSE headers in a JWS message,
 // use `Parse` function to get `Message` object.
 func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...VerifyOption) ([]byte, error) {
+	span := hook.Start(hook.VerifyKind)
+	span.SetAlgorithm(alg.String())
+	if jwkKey, ok := key.(jwk.Key); ok {
+		span.SetKeyID(jwkKey.KeyID())
+	}
+	payload, err := verify(context.Background(), buf, alg, key, nil, options...)
+	span.End(err)
+	return payload, err
+}
+
+// VerifyContext is the same as Verify, but accepts a context.Context.
+// An error is returned without verifying if the context has already
+// been canceled.
+func VerifyContext(ctx context.Context, buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...VerifyOption) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `failed to verify message`)
+	}
+
+	span := hook.Start(hook.VerifyKind)
+	span.SetAlgorithm(alg.String())
+	if jwkKey, ok := key.(jwk.Key); ok {
+		span.SetKeyID(jwkKey.KeyID())
+	}
+	payload, err := verify(ctx, buf, alg, key, nil, options...)
+	span.End(err)
+	return payload, err
+}
+
+// verify verifies the message in buf. If `verifier` is nil, a new
+// verifier for `alg` is created.
+func verify(ctx context.Context, buf []byte, alg jwa.SignatureAlgorithm, key interface{}, verifier Verifier, options ...VerifyOption) ([]byte, error) {
 	var dst *Message
+	var cache *verificationCache
+	var reqs headerRequirements
+	var edopts *EdDSAOptions
 	//nolint:forcetypeassert
 	for _, option := range options {
 		switch option.Ident() {
+		case identEdDSAOptions{}:
+			edopts = option.Value().(*EdDSAOptions)
 		case identMessage{}:
 			dst = option.Value().(*Message)
+		case identVerificationCache{}:
+			cache = option.Value().(*verificationCache)
+		case identExpectedType{}:
+			v := option.Value().(string)
+			reqs.typ = &v
+		case identExpectedContentType{}:
+			v := option.Value().(string)
+			reqs.cty = &v
+		case identRemoteKeyPolicy{}:
+			reqs.remote = &remoteKeyCheck{ctx: ctx, policy: option.Value().(*RemoteKeyPolicy)}
+		case identHardenedVerification{}:
+			if option.Value().(bool) {
+				reqs.hardened = &hardenedCheck{alg: alg}
+			}
+		case identThreshold{}:
+			return nil, errors.New(`jws.WithThreshold() can only be used with jws.VerifySet()`)
+		case identTimestampVerifier{}:
+			reqs.timestamp = &timestampCheck{ctx: ctx, verifier: option.Value().(TimestampVerifier)}
 		}
 	}
 
@@ -176,10 +337,63 @@ func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...
 		return nil, errors.New(`attempt to verify empty buffer`)
 	}
 
+	if err := limits.CheckInputSize(len(buf)); err != nil {
+		return nil, newParseError(err)
+	}
+
+	if err := checkKeySeparation(key); err != nil {
+		return nil, errors.Wrap(err, `failed to verify message`)
+	}
+
+	if reqs.hardened != nil {
+		if err := checkAlgorithmCase(alg); err != nil {
+			return nil, newVerificationError(errors.Wrap(err, `failed to verify message`))
+		}
+	}
+
+	var err error
+	if edopts != nil {
+		verifier, err = newEdDSAVerifierWithOptions(alg, edopts)
+	} else if verifier == nil {
+		verifier, err = NewVerifier(alg)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create verifier")
+	}
+
+	if cache == nil || cache.size <= 0 {
+		return verifyBuffer(buf, verifier, key, dst, &reqs)
+	}
+
+	id, ok := cache.id(buf, alg, key, &reqs, edopts)
+	if !ok {
+		return verifyBuffer(buf, verifier, key, dst, &reqs)
+	}
+
+	if payload, ok := cache.get(id); ok {
+		if dst == nil {
+			return payload, nil
+		}
+		// The message is only needed if the user asked for it
+		if m, err := Parse(buf); err == nil {
+			*dst = *m
+			return payload, nil
+		}
+	}
+
+	payload, err := verifyBuffer(buf, verifier, key, dst, &reqs)
+	if err != nil {
+		return nil, err
+	}
+	cache.set(id, payload)
+	return payload, nil
+}
+
+func verifyBuffer(buf []byte, verifier Verifier, key interface{}, dst *Message, reqs *headerRequirements) ([]byte, error) {
 	if buf[0] == '{' {
-		return verifyJSON(buf, alg, key, dst)
+		return verifyJSON(buf, verifier, key, dst, reqs)
 	}
-	return verifyCompact(buf, alg, key, dst)
+	return verifyCompact(buf, verifier, key, dst, reqs)
 }
 
 // VerifySet uses keys store in a jwk.Set to verify the payload in `buf`.
@@ -190,10 +404,91 @@ func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...
 //
 // Furthermore if the JWS signature asks for a spefici "kid", the
 // `jwk.Key` must have the same "kid" as the signature.
-func VerifySet(buf []byte, set jwk.Set) ([]byte, error) {
+//
+// By default, the verification succeeds if any of the keys verifies the
+// message. Use `jws.WithThreshold()` to require that multiple signatures
+// are verified. Other options are passed to `Verify()`.
+//
+// If the set implements `jwk.UsageRecorder`, such as `jwk.TrackedSet`,
+// the keys that verified the message are recorded as used.
+func VerifySet(buf []byte, set jwk.Set, options ...VerifyOption) ([]byte, error) {
+	var threshold int
+	var hasThreshold bool
+	var dst *Message
+	var verifyOptions []VerifyOption
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identThreshold{}:
+			threshold = option.Value().(int)
+			hasThreshold = true
+		case identMessage{}:
+			dst = option.Value().(*Message)
+		default:
+			verifyOptions = append(verifyOptions, option)
+		}
+	}
+
+	keys := signatureKeys(set)
+	if hasThreshold {
+		return verifyThreshold(buf, set, keys, threshold, dst, verifyOptions)
+	}
+
+	if dst != nil {
+		verifyOptions = append(verifyOptions, WithMessage(dst))
+	}
+	for _, key := range keys {
+		buf, err := Verify(buf, jwa.SignatureAlgorithm(key.Algorithm()), key, verifyOptions...)
+		if err != nil {
+			continue
+		}
+
+		recordUsage(set, key, jwk.KeyOpVerify)
+		return buf, nil
+	}
+
+	return nil, newVerificationError(errors.New(`failed to verify message with any of the keys in the jwk.Set object`))
+}
+
+// KeySetSigningKeyProvider returns a SigningKeyProvider, to be used with
+// `jws.WithKeyProviderForSigning()`, that signs with the key identified by
+// `kid` in `set`, using the algorithm in its "alg" field. The key is looked
+// up every time a payload is signed, so that changes to the set are
+// picked up.
+//
+// If the set implements `jwk.UsageRecorder`, such as `jwk.TrackedSet`,
+// the key is recorded as used for signing every time it is selected.
+func KeySetSigningKeyProvider(set jwk.Set, kid string) SigningKeyProvider {
+	return func(context.Context) (jwa.SignatureAlgorithm, interface{}, string, error) {
+		key, ok := set.LookupKeyID(kid)
+		if !ok {
+			return "", nil, "", errors.Errorf(`key %q not found in key set`, kid)
+		}
+
+		var alg jwa.SignatureAlgorithm
+		if err := alg.Accept(key.Algorithm()); err != nil {
+			return "", nil, "", errors.Wrapf(err, `invalid signature algorithm for key %q`, kid)
+		}
+
+		recordUsage(set, key, jwk.KeyOpSign)
+		return alg, key, kid, nil
+	}
+}
+
+// recordUsage records that `key` was used for `op`, if `set` keeps
+// track of key usage
+func recordUsage(set jwk.Set, key jwk.Key, op jwk.KeyOperation) {
+	if r, ok := set.(jwk.UsageRecorder); ok {
+		r.RecordUsage(key, op)
+	}
+}
+
+// signatureKeys returns the keys in set that can be used by VerifySet()
+func signatureKeys(set jwk.Set) []jwk.Key {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	var keys []jwk.Key
 	//nolint:forcetypeassert
 	for iter := set.Iterate(ctx); iter.Next(ctx); {
 		pair := iter.Pair()
@@ -205,45 +500,139 @@ func VerifySet(buf []byte, set jwk.Set) ([]byte, error) {
 		if usage := key.KeyUsage(); usage != "" && usage != jwk.ForSignature.String() {
 			continue
 		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// verifyThreshold verifies each signature in the message in buf
+// separately, and succeeds if at least `threshold` distinct keys
+// verified one of them
+func verifyThreshold(buf []byte, set jwk.Set, keys []jwk.Key, threshold int, dst *Message, options []VerifyOption) ([]byte, error) {
+	if threshold < 1 {
+		return nil, errors.Errorf(`invalid threshold %d: must be at least 1`, threshold)
+	}
+
+	m, err := Parse(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse message`)
+	}
+
+	verified := make(map[string]struct{})
+	var used []jwk.Key
+	for _, sig := range m.signatures {
+		if sig.protected == nil {
+			// "alg" must be integrity protected
+			continue
+		}
 
-		buf, err := Verify(buf, jwa.SignatureAlgorithm(key.Algorithm()), key)
+		single, err := json.Marshal(&Message{payload: m.payload, signatures: []*Signature{sig}})
 		if err != nil {
-			continue
+			return nil, errors.Wrap(err, `failed to marshal signature`)
 		}
 
-		return buf, nil
+		alg := sig.protected.Algorithm()
+		kid := sig.protected.KeyID()
+		if kid == "" && sig.headers != nil {
+			kid = sig.headers.KeyID()
+		}
+		for _, key := range keys {
+			if jwa.SignatureAlgorithm(key.Algorithm()) != alg {
+				continue
+			}
+			if kid != "" && key.KeyID() != kid {
+				continue
+			}
+
+			tp, err := key.Thumbprint(crypto.SHA256)
+			if err != nil {
+				continue
+			}
+			if _, ok := verified[string(tp)]; ok {
+				continue
+			}
+
+			if _, err := Verify(single, alg, key, options...); err != nil {
+				continue
+			}
+			verified[string(tp)] = struct{}{}
+			used = append(used, key)
+			break
+		}
 	}
 
-	return nil, errors.New(`failed to verify message with any of the keys in the jwk.Set object`)
-}
+	if len(verified) < threshold {
+		return nil, newVerificationError(errors.Errorf(`only %d of the required %d signatures could be verified`, len(verified), threshold))
+	}
 
-func verifyJSON(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, dst *Message) ([]byte, error) {
-	verifier, err := NewVerifier(alg)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create verifier")
+	for _, key := range used {
+		recordUsage(set, key, jwk.KeyOpVerify)
+	}
+
+	if dst != nil {
+		*dst = *m
 	}
+	return m.payload, nil
+}
 
+func verifyJSON(signed []byte, verifier Verifier, key interface{}, dst *Message, reqs *headerRequirements) ([]byte, error) {
 	var m Message
 	if err := json.Unmarshal(signed, &m); err != nil {
-		return nil, errors.Wrap(err, `failed to unmarshal JSON message`)
+		return nil, newParseError(errors.Wrap(err, `failed to unmarshal JSON message`))
 	}
 
 	// Pre-compute the base64 encoded version of payload
 	payload := base64.EncodeToString(m.payload)
 
-	buf := pool.GetBytesBuffer()
+	buf := pool.GetBytesBufferSize(len(payload) + signatureSlack)
 	defer pool.ReleaseBytesBuffer(buf)
+	if reqs.hardened != nil {
+		defer zeroizeBuffer(buf)
+		if dst == nil {
+			defer func() {
+				for _, sig := range m.signatures {
+					zeroize.Bytes(sig.signature)
+				}
+			}()
+		}
+	}
 
+	var lastErr error
 	for i, sig := range m.signatures {
 		buf.Reset()
+		if reqs.hardened != nil && len(sig.signature) == 0 {
+			lastErr = errors.New(`signature is empty`)
+			continue
+		}
+		sigKey := key
+		if reqs.remote != nil {
+			resolved, err := reqs.remote.resolve(key, sig.protected, sig.headers)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			sigKey = resolved
+		}
+
 		if hdr := sig.headers; hdr != nil && hdr.KeyID() != "" {
-			if jwkKey, ok := key.(jwk.Key); ok {
+			if jwkKey, ok := sigKey.(jwk.Key); ok {
 				if jwkKey.KeyID() != hdr.KeyID() {
 					continue
 				}
 			}
 		}
 
+		if err := reqs.check(sig.protected); err != nil {
+			lastErr = err
+			continue
+		}
+		if reqs.hardened != nil {
+			if err := reqs.hardened.checkAlgorithm(sig.protected); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+
 		protected, err := json.Marshal(sig.protected)
 		if err != nil {
 			return nil, errors.Wrapf(err, `failed to marshal "protected" for signature #%d`, i+1)
@@ -253,29 +642,45 @@ func verifyJSON(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, dst
 		buf.WriteByte('.')
 		buf.WriteString(payload)
 
-		if err := verifier.Verify(buf.Bytes(), sig.signature, key); err == nil {
-			if dst != nil {
-				*dst = m
+		if reqs.hardened != nil {
+			zeroize.Bytes(protected)
+			if err := reqs.hardened.verify(verifier, buf.Bytes(), sig.signature, sigKey); err != nil {
+				lastErr = err
+				continue
+			}
+		} else if err := verifier.Verify(buf.Bytes(), sig.signature, sigKey); err != nil {
+			continue
+		}
+
+		if reqs.timestamp != nil {
+			if err := reqs.timestamp.verify(sig); err != nil {
+				lastErr = err
+				continue
 			}
-			return m.payload, nil
 		}
+
+		if dst != nil {
+			*dst = m
+		}
+		return m.payload, nil
 	}
-	return nil, errors.New(`could not verify with any of the signatures`)
+	if lastErr != nil {
+		return nil, newVerificationError(errors.Wrap(lastErr, `could not verify with any of the signatures`))
+	}
+	return nil, newVerificationError(errors.New(`could not verify with any of the signatures`))
 }
 
-func verifyCompact(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, dst *Message) ([]byte, error) {
+func verifyCompact(signed []byte, verifier Verifier, key interface{}, dst *Message, reqs *headerRequirements) ([]byte, error) {
 	protected, payload, signature, err := SplitCompact(signed)
 	if err != nil {
-		return nil, errors.Wrap(err, `failed extract from compact serialization format`)
-	}
-
-	verifier, err := NewVerifier(alg)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create verifier")
+		return nil, newParseError(errors.Wrap(err, `failed extract from compact serialization format`))
 	}
 
-	verifyBuf := pool.GetBytesBuffer()
+	verifyBuf := pool.GetBytesBufferSize(len(protected) + 1 + len(payload))
 	defer pool.ReleaseBytesBuffer(verifyBuf)
+	if reqs.hardened != nil {
+		defer zeroizeBuffer(verifyBuf)
+	}
 
 	verifyBuf.Write(protected)
 	verifyBuf.WriteByte('.')
@@ -283,33 +688,71 @@ func verifyCompact(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, d
 
 	decodedSignature, err := base64.Decode(signature)
 	if err != nil {
-		return nil, errors.Wrap(err, `failed to decode signature`)
+		return nil, newParseError(errors.Wrap(err, `failed to decode signature`))
+	}
+	if reqs.hardened != nil {
+		if len(decodedSignature) == 0 {
+			return nil, newVerificationError(errors.New(`signature is empty`))
+		}
+		if dst == nil {
+			defer zeroize.Bytes(decodedSignature)
+		}
 	}
 
 	hdr := NewHeaders()
 	decodedProtected, err := base64.Decode(protected)
 	if err != nil {
-		return nil, errors.Wrap(err, `failed to decode headers`)
+		return nil, newParseError(errors.Wrap(err, `failed to decode headers`))
+	}
+
+	if err := limits.CheckHeader(decodedProtected); err != nil {
+		return nil, newParseError(errors.Wrap(err, `invalid protected headers`))
+	}
+
+	if reqs.hardened != nil {
+		defer zeroize.Bytes(decodedProtected)
 	}
 
 	if err := json.Unmarshal(decodedProtected, hdr); err != nil {
-		return nil, errors.Wrap(err, `failed to decode headers`)
+		return nil, newParseError(errors.Wrap(err, `failed to decode headers`))
+	}
+
+	if reqs.remote != nil {
+		resolved, err := reqs.remote.resolve(key, hdr, nil)
+		if err != nil {
+			return nil, newVerificationError(err)
+		}
+		key = resolved
 	}
 
 	if hdr.KeyID() != "" {
 		if jwkKey, ok := key.(jwk.Key); ok {
 			if jwkKey.KeyID() != hdr.KeyID() {
-				return nil, errors.New(`"kid" fields do not match`)
+				return nil, newVerificationError(errors.New(`"kid" fields do not match`))
 			}
 		}
 	}
-	if err := verifier.Verify(verifyBuf.Bytes(), decodedSignature, key); err != nil {
-		return nil, errors.Wrap(err, `failed to verify message`)
+	if err := reqs.check(hdr); err != nil {
+		return nil, newVerificationError(err)
+	}
+	if reqs.hardened != nil {
+		if err := reqs.hardened.checkAlgorithm(hdr); err != nil {
+			return nil, newVerificationError(err)
+		}
+		if err := reqs.hardened.verify(verifier, verifyBuf.Bytes(), decodedSignature, key); err != nil {
+			return nil, newVerificationError(errors.Wrap(err, `failed to verify message`))
+		}
+	} else if err := verifier.Verify(verifyBuf.Bytes(), decodedSignature, key); err != nil {
+		return nil, newVerificationError(errors.Wrap(err, `failed to verify message`))
+	}
+
+	if reqs.timestamp != nil {
+		return nil, newVerificationError(errors.New(`messages in compact serialization cannot carry a timestamp token`))
 	}
 
 	decodedPayload, err := base64.Decode(payload)
 	if err != nil {
-		return nil, errors.Wrap(err, `message verified, failed to decode payload`)
+		return nil, newParseError(errors.Wrap(err, `message verified, failed to decode payload`))
 	}
 
 	if dst != nil {
@@ -345,6 +788,10 @@ func readAll(rdr io.Reader) ([]byte, bool) {
 // Parse parses contents from the given source and creates a jws.Message
 // struct. The input can be in either compact or full JSON serialization.
 func Parse(src []byte) (*Message, error) {
+	if err := limits.CheckInputSize(len(src)); err != nil {
+		return nil, newParseError(err)
+	}
+
 	for i := 0; i < len(src); i++ {
 		r := rune(src[i])
 		if r >= utf8.RuneSelf {
@@ -357,7 +804,7 @@ func Parse(src []byte) (*Message, error) {
 			return parseCompact(src)
 		}
 	}
-	return nil, errors.New("invalid byte sequence")
+	return nil, newParseError(errors.New("invalid byte sequence"))
 }
 
 // Parse parses contents from the given source and creates a jws.Message
@@ -373,12 +820,22 @@ func ParseReader(src io.Reader) (*Message, error) {
 		return Parse(data)
 	}
 
+	if n := limits.MaxInputSize(); n > 0 {
+		// Read one extra byte so that Parse can detect that the
+		// input was too large
+		data, err := ioutil.ReadAll(io.LimitReader(src, n+1))
+		if err != nil {
+			return nil, newParseError(errors.Wrap(err, `failed to read from io.Reader`))
+		}
+		return Parse(data)
+	}
+
 	rdr := bufio.NewReader(src)
 	var first rune
 	for {
 		r, _, err := rdr.ReadRune()
 		if err != nil {
-			return nil, errors.Wrap(err, `failed to read rune`)
+			return nil, newParseError(errors.Wrap(err, `failed to read rune`))
 		}
 		if !unicode.IsSpace(r) {
 			first = r
@@ -408,7 +865,7 @@ func ParseReader(src io.Reader) (*Message, error) {
 func parseJSONReader(src io.Reader) (result *Message, err error) {
 	var m Message
 	if err := json.NewDecoder(src).Decode(&m); err != nil {
-		return nil, errors.Wrap(err, `failed to unmarshal jws message`)
+		return nil, newParseError(errors.Wrap(err, `failed to unmarshal jws message`))
 	}
 	return &m, nil
 }
@@ -416,7 +873,7 @@ func parseJSONReader(src io.Reader) (result *Message, err error) {
 func parseJSON(data []byte) (result *Message, err error) {
 	var m Message
 	if err := json.Unmarshal(data, &m); err != nil {
-		return nil, errors.Wrap(err, `failed to unmarshal jws message`)
+		return nil, newParseError(errors.Wrap(err, `failed to unmarshal jws message`))
 	}
 	return &m, nil
 }
@@ -515,17 +972,25 @@ func SplitCompactReader(rdr io.Reader) ([]byte, []byte, []byte, error) {
 func parseCompactReader(rdr io.Reader) (m *Message, err error) {
 	protected, payload, signature, err := SplitCompactReader(rdr)
 	if err != nil {
-		return nil, errors.Wrap(err, `invalid compact serialization format`)
+		return nil, newParseError(errors.Wrap(err, `invalid compact serialization format`))
+	}
+	m, err = parse(protected, payload, signature)
+	if err != nil {
+		return nil, newParseError(err)
 	}
-	return parse(protected, payload, signature)
+	return m, nil
 }
 
 func parseCompact(data []byte) (m *Message, err error) {
 	protected, payload, signature, err := SplitCompact(data)
 	if err != nil {
-		return nil, errors.Wrap(err, `invalid compact serialization format`)
+		return nil, newParseError(errors.Wrap(err, `invalid compact serialization format`))
+	}
+	m, err = parse(protected, payload, signature)
+	if err != nil {
+		return nil, newParseError(err)
 	}
-	return parse(protected, payload, signature)
+	return m, nil
 }
 
 func parse(protected, payload, signature []byte) (*Message, error) {
@@ -534,6 +999,10 @@ func parse(protected, payload, signature []byte) (*Message, error) {
 		return nil, errors.Wrap(err, `failed to decode protected headers`)
 	}
 
+	if err := limits.CheckHeader(decodedHeader); err != nil {
+		return nil, errors.Wrap(err, `invalid protected headers`)
+	}
+
 	hdr := NewHeaders()
 	if err := json.Unmarshal(decodedHeader, hdr); err != nil {
 		return nil, errors.Wrap(err, `failed to parse JOSE headers`)
@@ -3,14 +3,17 @@ package jws_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha512"
+	stdbase64 "encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,9 +21,11 @@ import (
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 
+	"github.com/lestrrat-go/jwx"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -174,7 +179,7 @@ func TestRoundtrip(t *testing.T) {
 
 	t.Run("HMAC", func(t *testing.T) {
 		t.Parallel()
-		sharedkey := []byte("Avracadabra")
+		sharedkey := []byte("Avracadabra-Avracadabra-Avracadabra")
 		jwkKey, _ := jwk.New(sharedkey)
 		keys := map[string]interface{}{
 			"[]byte":  sharedkey,
@@ -253,7 +258,7 @@ func TestRoundtrip(t *testing.T) {
 }
 
 func TestSignMulti2(t *testing.T) {
-	sharedkey := []byte("Avracadabra")
+	sharedkey := []byte("Avracadabra-Avracadabra-Avracadabra")
 	payload := []byte("Lorem ipsum")
 	hmacAlgorithms := []jwa.SignatureAlgorithm{jwa.HS256, jwa.HS384, jwa.HS512}
 	var signed []byte
@@ -1044,6 +1049,217 @@ func TestVerifySet(t *testing.T) {
 	}
 }
 
+func TestKeySetVerifier(t *testing.T) {
+	t.Parallel()
+	payload := []byte(`Lorem ipsum`)
+
+	set := jwk.NewSet()
+	var privkeys []jwk.Key
+	for i, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.HS256} {
+		var key jwk.Key
+		var err error
+		switch alg {
+		case jwa.RS256:
+			key, err = jwxtest.GenerateRsaJwk()
+		case jwa.ES256:
+			key, err = jwxtest.GenerateEcdsaJwk()
+		default:
+			key, err = jwxtest.GenerateSymmetricJwk()
+		}
+		if !assert.NoError(t, err, `generating key should succeed`) {
+			return
+		}
+		_ = key.Set(jwk.KeyIDKey, fmt.Sprintf(`key-%d`, i))
+		_ = key.Set(jwk.AlgorithmKey, alg)
+		privkeys = append(privkeys, key)
+
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+		set.Add(pubkey)
+	}
+	// keys without "alg" are ignored
+	noalg, _ := jwk.New([]byte(`abracadavra`))
+	set.Add(noalg)
+
+	v, err := jws.NewKeySetVerifier(set)
+	if !assert.NoError(t, err, `jws.NewKeySetVerifier should succeed`) {
+		return
+	}
+
+	for _, useJSON := range []bool{true, false} {
+		for _, key := range privkeys {
+			signed, err := jws.Sign(payload, jwa.SignatureAlgorithm(key.Algorithm()), key)
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+			if useJSON {
+				m, err := jws.Parse(signed)
+				if !assert.NoError(t, err, `jws.Parse should succeed`) {
+					return
+				}
+				signed, err = json.Marshal(m)
+				if !assert.NoError(t, err, `json.Marshal should succeed`) {
+					return
+				}
+			}
+
+			var m jws.Message
+			verified, err := v.Verify(signed, jws.WithMessage(&m))
+			if !assert.NoError(t, err, `v.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payload should match`) {
+				return
+			}
+			if !assert.Equal(t, key.KeyID(), m.Signatures()[0].ProtectedHeaders().KeyID(), `message should be populated`) {
+				return
+			}
+		}
+	}
+
+	t.Run("Unknown key", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwxtest.GenerateRsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+			return
+		}
+		_ = key.Set(jwk.KeyIDKey, `key-0`)
+
+		signed, err := jws.Sign(payload, jwa.RS256, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = v.Verify(signed)
+		if !assert.True(t, errors.Is(err, jwx.ErrVerification), `v.Verify should fail with a verification error`) {
+			return
+		}
+	})
+	t.Run("Algorithm mismatch", func(t *testing.T) {
+		t.Parallel()
+		// signed by key-0, but with an algorithm that key-0 is not used for
+		signed, err := jws.Sign(payload, jwa.PS256, privkeys[0])
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = v.Verify(signed)
+		if !assert.Error(t, err, `v.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Malformed message", func(t *testing.T) {
+		t.Parallel()
+		_, err := v.Verify([]byte(`not.a-jws`))
+		if !assert.Error(t, err, `v.Verify should fail`) {
+			return
+		}
+	})
+}
+
+func TestVerifySetThreshold(t *testing.T) {
+	t.Parallel()
+	payload := []byte(`Lorem ipsum`)
+
+	type party struct {
+		alg     jwa.SignatureAlgorithm
+		privkey jwk.Key
+	}
+	var parties []party
+	set := jwk.NewSet()
+	for i, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.EdDSA} {
+		var key jwk.Key
+		var err error
+		switch alg {
+		case jwa.RS256:
+			key, err = jwxtest.GenerateRsaJwk()
+		case jwa.ES256:
+			key, err = jwxtest.GenerateEcdsaJwk()
+		default:
+			key, err = jwxtest.GenerateEd25519Jwk()
+		}
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			return
+		}
+		_ = key.Set(jwk.KeyIDKey, fmt.Sprintf(`party-%d`, i))
+		_ = key.Set(jwk.AlgorithmKey, alg)
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+		set.Add(pubkey)
+		parties = append(parties, party{alg: alg, privkey: key})
+	}
+
+	signMulti := func(t *testing.T, signers ...party) []byte {
+		t.Helper()
+		var options []jws.Option
+		for _, p := range signers {
+			signer, err := jws.NewSigner(p.alg)
+			if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+				t.FailNow()
+			}
+			protected := jws.NewHeaders()
+			_ = protected.Set(jws.KeyIDKey, p.privkey.KeyID())
+			options = append(options, jws.WithSigner(signer, p.privkey, nil, protected))
+		}
+		signed, err := jws.SignMulti(payload, options...)
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("Threshold met", func(t *testing.T) {
+		t.Parallel()
+		signed := signMulti(t, parties[0], parties[2])
+
+		var m jws.Message
+		verified, err := jws.VerifySet(signed, set, jws.WithThreshold(2), jws.WithMessage(&m))
+		if !assert.NoError(t, err, `jws.VerifySet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payload should match`) {
+			return
+		}
+		if !assert.Len(t, m.Signatures(), 2, `message should contain all signatures`) {
+			return
+		}
+	})
+	t.Run("Threshold not met", func(t *testing.T) {
+		t.Parallel()
+		signed := signMulti(t, parties[0], parties[2])
+
+		_, err := jws.VerifySet(signed, set, jws.WithThreshold(3))
+		if !assert.True(t, errors.Is(err, jwx.ErrVerification), `jws.VerifySet should fail`) {
+			return
+		}
+	})
+	t.Run("Same key signing twice", func(t *testing.T) {
+		t.Parallel()
+		signed := signMulti(t, parties[1], parties[1])
+
+		_, err := jws.VerifySet(signed, set, jws.WithThreshold(2))
+		if !assert.Error(t, err, `jws.VerifySet should fail`) {
+			return
+		}
+		if _, err := jws.VerifySet(signed, set, jws.WithThreshold(1)); !assert.NoError(t, err, `jws.VerifySet should succeed`) {
+			return
+		}
+	})
+	t.Run("Invalid usage", func(t *testing.T) {
+		t.Parallel()
+		signed := signMulti(t, parties[0])
+
+		if _, err := jws.VerifySet(signed, set, jws.WithThreshold(0)); !assert.Error(t, err, `jws.VerifySet should fail`) {
+			return
+		}
+		if _, err := jws.Verify(signed, jwa.RS256, parties[0].privkey, jws.WithThreshold(1)); !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+}
+
 func TestCustomField(t *testing.T) {
 	// XXX has global effect!!!
 	jws.RegisterCustomField(`x-birthday`, time.Time{})
@@ -1137,3 +1353,858 @@ func TestWithMessage(t *testing.T) {
 		return
 	}
 }
+
+func TestHybrid(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Lorem ipsum")
+	ecKey, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	_ = ecKey.Set(jwk.KeyIDKey, `classical`)
+	edKey, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+		return
+	}
+
+	ecPubKey, err := jwk.PublicKeyOf(ecKey)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	edPubKey := edKey.Public()
+
+	signed, err := jws.SignHybrid(payload, jwa.ES256, ecKey, jwa.EdDSA, edKey)
+	if !assert.NoError(t, err, `jws.SignHybrid should succeed`) {
+		return
+	}
+
+	t.Run("both signatures", func(t *testing.T) {
+		t.Parallel()
+		var m jws.Message
+		verified, err := jws.VerifyHybrid(signed, jwa.ES256, ecPubKey, jwa.EdDSA, edPubKey, jws.WithMessage(&m))
+		if !assert.NoError(t, err, `jws.VerifyHybrid should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+		if !assert.Len(t, m.Signatures(), 2, `message should contain two signatures`) {
+			return
+		}
+		if !assert.Equal(t, `classical`, m.Signatures()[0].ProtectedHeaders().KeyID(), `kid should be serialized`) {
+			return
+		}
+	})
+	t.Run("each signature can be verified with jws.Verify", func(t *testing.T) {
+		t.Parallel()
+		if _, err := jws.Verify(signed, jwa.ES256, ecPubKey); !assert.NoError(t, err, `jws.Verify(ES256) should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(signed, jwa.EdDSA, edPubKey); !assert.NoError(t, err, `jws.Verify(EdDSA) should succeed`) {
+			return
+		}
+	})
+	t.Run("one bad key", func(t *testing.T) {
+		t.Parallel()
+		otherKey, err := jwxtest.GenerateEd25519Key()
+		if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+			return
+		}
+
+		_, err = jws.VerifyHybrid(signed, jwa.ES256, ecPubKey, jwa.EdDSA, otherKey.Public())
+		if !assert.Error(t, err, `jws.VerifyHybrid should fail when requiring both`) {
+			return
+		}
+
+		verified, err := jws.VerifyHybrid(signed, jwa.ES256, ecPubKey, jwa.EdDSA, otherKey.Public(), jws.WithHybridPolicy(jws.HybridRequireEither))
+		if !assert.NoError(t, err, `jws.VerifyHybrid should succeed when requiring either`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+	})
+	t.Run("missing signature", func(t *testing.T) {
+		t.Parallel()
+		single, err := jws.SignMulti(payload, jws.WithSigner(mustSigner(t, jwa.EdDSA), edKey, nil, nil))
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+		_, err = jws.VerifyHybrid(single, jwa.ES256, ecPubKey, jwa.EdDSA, edPubKey)
+		if !assert.Error(t, err, `jws.VerifyHybrid should fail`) {
+			return
+		}
+		_, err = jws.VerifyHybrid(single, jwa.ES256, ecPubKey, jwa.EdDSA, edPubKey, jws.WithHybridPolicy(jws.HybridRequireEither))
+		if !assert.NoError(t, err, `jws.VerifyHybrid should succeed when requiring either`) {
+			return
+		}
+	})
+	t.Run("compact serialization", func(t *testing.T) {
+		t.Parallel()
+		compact, err := jws.Sign(payload, jwa.EdDSA, edKey)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = jws.VerifyHybrid(compact, jwa.ES256, ecPubKey, jwa.EdDSA, edPubKey, jws.WithHybridPolicy(jws.HybridRequireEither))
+		if !assert.Error(t, err, `jws.VerifyHybrid should fail`) {
+			return
+		}
+	})
+}
+
+func mustSigner(t *testing.T, alg jwa.SignatureAlgorithm) jws.Signer {
+	t.Helper()
+	signer, err := jws.NewSigner(alg)
+	if err != nil {
+		t.Fatalf(`failed to create signer for %s: %s`, alg, err)
+	}
+	return signer
+}
+
+// Some legacy producers emit the standard base64 alphabet and/or padding
+// instead of base64url. These are only accepted when lenient decoding is enabled.
+func TestLenientBase64(t *testing.T) {
+	// not parallel, as this changes global settings
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	// "\xfb\xff" encodes to "+/8=" in the standard alphabet
+	payload := []byte("{\"data\":\"\xfb\xff\"}")
+
+	signer, err := jws.NewSigner(jwa.HS256)
+	if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+		return
+	}
+
+	encoding := stdbase64.StdEncoding
+	protected := encoding.EncodeToString([]byte(`{"alg":"HS256"}`))
+	encodedPayload := encoding.EncodeToString(payload)
+	if !assert.True(t, strings.ContainsAny(encodedPayload, "+/="), `payload should be encoded using the standard alphabet with padding`) {
+		return
+	}
+
+	signature, err := signer.Sign([]byte(protected+"."+encodedPayload), key)
+	if !assert.NoError(t, err, `signer.Sign should succeed`) {
+		return
+	}
+
+	compact := protected + "." + encodedPayload + "." + encoding.EncodeToString(signature)
+
+	_, err = jws.Verify([]byte(compact), jwa.HS256, key)
+	if !assert.Error(t, err, `jws.Verify should fail by default`) {
+		return
+	}
+
+	jwx.Settings(jwx.WithLenientBase64(true))
+	defer jwx.Settings(jwx.WithLenientBase64(false))
+	verified, err := jws.Verify([]byte(compact), jwa.HS256, key)
+	if !assert.NoError(t, err, `jws.Verify should succeed`) {
+		return
+	}
+	if !assert.Equal(t, payload, verified, `payload should match`) {
+		return
+	}
+
+	msg, err := jws.Parse([]byte(compact))
+	if !assert.NoError(t, err, `jws.Parse should succeed`) {
+		return
+	}
+	if !assert.Equal(t, signature, msg.Signatures()[0].Signature(), `signature should match`) {
+		return
+	}
+}
+
+type countingVerifier struct {
+	jws.Verifier
+	count *int64
+}
+
+func (v *countingVerifier) Verify(payload, signature []byte, key interface{}) error {
+	atomic.AddInt64(v.count, 1)
+	return v.Verifier.Verify(payload, signature, key)
+}
+
+func TestVerificationCache(t *testing.T) {
+	// DO NOT MAKE THIS TEST PARALLEL. RegisterVerifier() modifies global state
+	if jwx.FIPSMode() {
+		t.Skip(`user registered algorithms are not approved in FIPS mode`)
+	}
+	const alg = jwa.SignatureAlgorithm(`X-COUNTING-HS256`)
+	var count int64
+	jws.RegisterVerifier(alg, jws.VerifierFactoryFn(func() (jws.Verifier, error) {
+		v, err := jws.NewVerifier(jwa.HS256)
+		if err != nil {
+			return nil, err
+		}
+		return &countingVerifier{Verifier: v, count: &count}, nil
+	}))
+
+	key := jwxtest.GenerateSymmetricKey()
+	sign := func(t *testing.T, payload string) []byte {
+		t.Helper()
+		signed, err := jws.Sign([]byte(payload), jwa.HS256, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("Hit", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		cache := jws.WithVerificationCache(10, time.Minute)
+		signed := sign(t, `Lorem ipsum`)
+
+		for i := 0; i < 3; i++ {
+			payload, err := jws.Verify(signed, alg, key, cache)
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, []byte(`Lorem ipsum`), payload, `payload should match`) {
+				return
+			}
+			// modifying the result should not affect the cached payload
+			payload[0] = 'X'
+		}
+		if !assert.Equal(t, int64(1), atomic.LoadInt64(&count), `signature should only be verified once`) {
+			return
+		}
+
+		m := jws.NewMessage()
+		if _, err := jws.Verify(signed, alg, key, cache, jws.WithMessage(m)); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte(`Lorem ipsum`), m.Payload(), `message should be populated`) {
+			return
+		}
+		if !assert.Equal(t, int64(1), atomic.LoadInt64(&count), `signature should only be verified once`) {
+			return
+		}
+	})
+	t.Run("Different key", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		cache := jws.WithVerificationCache(10, time.Minute)
+		signed := sign(t, `Lorem ipsum`)
+
+		if _, err := jws.Verify(signed, alg, key, cache); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(signed, alg, jwxtest.GenerateSymmetricKey(), cache); !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+		if !assert.Equal(t, int64(2), atomic.LoadInt64(&count), `signature should be verified with the new key`) {
+			return
+		}
+	})
+	t.Run("Different kid", func(t *testing.T) {
+		cache := jws.WithVerificationCache(10, time.Minute)
+		jwkKey := func(kid string) jwk.Key {
+			t.Helper()
+			k, err := jwk.New(key)
+			if !assert.NoError(t, err, `jwk.New should succeed`) {
+				t.FailNow()
+			}
+			_ = k.Set(jwk.KeyIDKey, kid)
+			return k
+		}
+
+		signed, err := jws.Sign([]byte(`Lorem ipsum`), jwa.HS256, jwkKey(`a`))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(signed, alg, jwkKey(`a`), cache); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		// same key material, but the "kid" does not match the header
+		if _, err := jws.Verify(signed, alg, jwkKey(`b`), cache); !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Eviction", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		cache := jws.WithVerificationCache(1, time.Minute)
+		first := sign(t, `first`)
+		second := sign(t, `second`)
+
+		for _, signed := range [][]byte{first, second, first} {
+			if _, err := jws.Verify(signed, alg, key, cache); !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+		}
+		if !assert.Equal(t, int64(3), atomic.LoadInt64(&count), `evicted entries should be verified again`) {
+			return
+		}
+	})
+	t.Run("Expiration", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		cache := jws.WithVerificationCache(10, time.Nanosecond)
+		signed := sign(t, `Lorem ipsum`)
+
+		for i := 0; i < 2; i++ {
+			if _, err := jws.Verify(signed, alg, key, cache); !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if !assert.Equal(t, int64(2), atomic.LoadInt64(&count), `expired entries should be verified again`) {
+			return
+		}
+	})
+}
+
+func TestDetachedSignature(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	hdrs := jws.NewHeaders()
+	_ = hdrs.Set(jws.AlgorithmKey, jwa.RS256)
+	payload := []byte(examplePayload)
+
+	input, err := jws.SigningInput(hdrs, payload, true)
+	if !assert.NoError(t, err, `jws.SigningInput should succeed`) {
+		return
+	}
+
+	signed, err := jws.Sign(payload, jwa.RS256, key)
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+	if !assert.Equal(t, string(signed[:bytes.LastIndexByte(signed, '.')]), string(input), `signing input should match the one used by jws.Sign`) {
+		return
+	}
+
+	signer, err := jws.NewSigner(jwa.RS256)
+	if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+		return
+	}
+	signature, err := signer.Sign(input, key)
+	if !assert.NoError(t, err, `signer.Sign should succeed`) {
+		return
+	}
+
+	if !assert.NoError(t, jws.VerifyDetachedSignature(input, signature, jwa.RS256, &key.PublicKey), `jws.VerifyDetachedSignature should succeed`) {
+		return
+	}
+
+	tampered := append([]byte{}, input...)
+	tampered[len(tampered)-1] ^= 0x01
+	err = jws.VerifyDetachedSignature(tampered, signature, jwa.RS256, &key.PublicKey)
+	if !assert.True(t, errors.Is(err, jwx.ErrVerification), `tampered input should fail verification`) {
+		return
+	}
+
+	unencoded, err := jws.SigningInput(hdrs, []byte(`$.02`), false)
+	if !assert.NoError(t, err, `jws.SigningInput should succeed`) {
+		return
+	}
+	if !assert.True(t, bytes.HasSuffix(unencoded, []byte(`.$.02`)), `payload should not be encoded`) {
+		return
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	payload := []byte(examplePayload)
+
+	hdrs := jws.NewHeaders()
+	_ = hdrs.Set(jws.TypeKey, `JWT`)
+	signed, err := jws.Sign(payload, jwa.RS256, key, jws.WithHeaders(hdrs), jws.WithType(`logout+jwt`), jws.WithContentType(`JWT`))
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `JWT`, hdrs.Type(), `headers passed by the user should not be modified`) {
+		return
+	}
+
+	msg, err := jws.Parse(signed)
+	if !assert.NoError(t, err, `jws.Parse should succeed`) {
+		return
+	}
+	protected := msg.Signatures()[0].ProtectedHeaders()
+	if !assert.Equal(t, `logout+jwt`, protected.Type(), `"typ" should match`) {
+		return
+	}
+	if !assert.Equal(t, `JWT`, protected.ContentType(), `"cty" should match`) {
+		return
+	}
+
+	t.Run("Compact", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name    string
+			Options []jws.VerifyOption
+			Error   bool
+		}{
+			{Name: "no requirements"},
+			{Name: "typ", Options: []jws.VerifyOption{jws.WithExpectedType(`logout+jwt`)}},
+			{Name: "typ with prefix", Options: []jws.VerifyOption{jws.WithExpectedType(`application/Logout+JWT`)}},
+			{Name: "typ and cty", Options: []jws.VerifyOption{jws.WithExpectedType(`logout+jwt`), jws.WithExpectedContentType(`jwt`)}},
+			{Name: "typ mismatch", Options: []jws.VerifyOption{jws.WithExpectedType(`at+jwt`)}, Error: true},
+			{Name: "cty mismatch", Options: []jws.VerifyOption{jws.WithExpectedContentType(`JOSE`)}, Error: true},
+			{Name: "empty typ", Options: []jws.VerifyOption{jws.WithExpectedType(``)}, Error: true},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				_, err := jws.Verify(signed, jwa.RS256, &key.PublicKey, tc.Options...)
+				if tc.Error {
+					if !assert.True(t, errors.Is(err, jwx.ErrVerification), `jws.Verify should fail with a verification error`) {
+						return
+					}
+					return
+				}
+				if !assert.NoError(t, err, `jws.Verify should succeed`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("JSON", func(t *testing.T) {
+		t.Parallel()
+		at := jws.NewHeaders()
+		_ = at.Set(jws.TypeKey, `at+jwt`)
+		logout := jws.NewHeaders()
+		_ = logout.Set(jws.TypeKey, `logout+jwt`)
+
+		signer, err := jws.NewSigner(jwa.RS256)
+		if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+			return
+		}
+		signed, err := jws.SignMulti(payload,
+			jws.WithSigner(signer, key, nil, at),
+			// typ in the public header must never be considered
+			jws.WithSigner(signer, key, logout, nil),
+		)
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.RS256, &key.PublicKey, jws.WithExpectedType(`at+jwt`))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.RS256, &key.PublicKey, jws.WithExpectedType(`logout+jwt`))
+		if !assert.True(t, errors.Is(err, jwx.ErrVerification), `jws.Verify should fail with a verification error`) {
+			return
+		}
+	})
+	t.Run("Cache", func(t *testing.T) {
+		t.Parallel()
+		cache := jws.WithVerificationCache(10, time.Minute)
+		_, err := jws.Verify(signed, jwa.RS256, &key.PublicKey, cache)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.RS256, &key.PublicKey, cache, jws.WithExpectedType(`at+jwt`))
+		if !assert.Error(t, err, `cached verification should not bypass requirements`) {
+			return
+		}
+	})
+}
+
+func TestSigningPolicy(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, `my-key`)
+
+	policy := jws.WithSigningPolicy(jws.SigningPolicy{
+		ProtectedHeaders: []string{jws.KeyIDKey, jws.TypeKey},
+		ForbiddenHeaders: []string{jws.JWKSetURLKey},
+	})
+	payload := []byte(`hello`)
+
+	t.Run("Sign", func(t *testing.T) {
+		t.Parallel()
+		signed, err := jws.Sign(payload, jwa.HS256, key, policy, jws.WithType(`JWT`))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(signed, jwa.HS256, key); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+
+		_, err = jws.Sign(payload, jwa.HS256, key, policy)
+		if !assert.Error(t, err, `jws.Sign should fail without "typ"`) {
+			return
+		}
+		assert.Contains(t, err.Error(), `"typ" is required`, `error should name the missing header`)
+
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.JWKSetURLKey, `https://example.com/jwks.json`)
+		_, err = jws.Sign(payload, jwa.HS256, key, policy, jws.WithType(`JWT`), jws.WithHeaders(hdrs))
+		if !assert.Error(t, err, `jws.Sign should fail with "jku"`) {
+			return
+		}
+		assert.Contains(t, err.Error(), `"jku" is forbidden`, `error should name the forbidden header`)
+	})
+	t.Run("AllowedHeaders", func(t *testing.T) {
+		t.Parallel()
+		allowed := jws.WithSigningPolicy(jws.SigningPolicy{
+			AllowedHeaders: []string{jws.KeyIDKey, jws.TypeKey},
+		})
+		if _, err := jws.Sign(payload, jwa.HS256, key, allowed, jws.WithType(`JWT`)); !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err := jws.Sign(payload, jwa.HS256, key, allowed, jws.WithContentType(`JWT`))
+		assert.Error(t, err, `jws.Sign should fail with "cty"`)
+	})
+	t.Run("SignMulti", func(t *testing.T) {
+		t.Parallel()
+		signer, err := jws.NewSigner(jwa.HS256)
+		if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+			return
+		}
+
+		protected := jws.NewHeaders()
+		_ = protected.Set(jws.KeyIDKey, `my-key`)
+		_ = protected.Set(jws.TypeKey, `JWT`)
+		if _, err := jws.SignMulti(payload, policy, jws.WithSigner(signer, key, nil, protected)); !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		// "typ" in the unprotected header does not satisfy the policy
+		protected = jws.NewHeaders()
+		_ = protected.Set(jws.KeyIDKey, `my-key`)
+		public := jws.NewHeaders()
+		_ = public.Set(jws.TypeKey, `JWT`)
+		_, err = jws.SignMulti(payload, policy, jws.WithSigner(signer, key, public, protected))
+		if !assert.Error(t, err, `jws.SignMulti should fail`) {
+			return
+		}
+		assert.Contains(t, err.Error(), `"typ" must be protected`, `error should name the unprotected header`)
+	})
+}
+
+func TestRemoteKeyPolicy(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, `my-key`)
+	pubkey, err := jwk.PublicKeyOf(key)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+
+	sign := func(t *testing.T, name, u string) []byte {
+		t.Helper()
+		hdrs := jws.NewHeaders()
+		if name != "" {
+			_ = hdrs.Set(name, u)
+		}
+		signed, err := jws.Sign([]byte(`hello`), jwa.ES256, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		t.Parallel()
+		_, err := jws.Verify(sign(t, jws.JWKSetURLKey, `https://evil.example.com/jwks.json`), jwa.ES256, pubkey)
+		assert.NoError(t, err, `jws.Verify should ignore "jku" without a policy`)
+	})
+	t.Run("Reject", func(t *testing.T) {
+		t.Parallel()
+		policy := jws.WithRemoteKeyPolicy(jws.RemoteKeyPolicy{})
+		for _, name := range []string{jws.JWKSetURLKey, jws.X509URLKey} {
+			_, err := jws.Verify(sign(t, name, `https://keys.example.com/jwks.json`), jwa.ES256, pubkey, policy)
+			assert.Error(t, err, `jws.Verify should reject %q`, name)
+		}
+		_, err := jws.Verify(sign(t, "", ""), jwa.ES256, pubkey, policy)
+		assert.NoError(t, err, `jws.Verify should accept messages without remote key headers`)
+	})
+	t.Run("AllowedURLs", func(t *testing.T) {
+		t.Parallel()
+		policy := jws.WithRemoteKeyPolicy(jws.RemoteKeyPolicy{
+			AllowedURLs: []string{`https://keys.example.com/jwks/`, `https://auth.example.com/certs.pem`},
+		})
+		testcases := map[string]bool{
+			`https://keys.example.com/jwks/current.json`: true,
+			`https://auth.example.com/certs.pem`:         true,
+			`https://auth.example.com/certs.pem.evil`:    false,
+			`https://keys.example.com/jwks/../evil.json`: false,
+			`https://keys.example.com.evil.com/jwks/a`:   false,
+			`http://keys.example.com/jwks/current.json`:  false,
+			`https://user@keys.example.com/jwks/a.json`:  false,
+			`https://evil.example.com/jwks/current.json`: false,
+			`https://keys.example.com/jwksevil/key.json`: false,
+		}
+		for u, allowed := range testcases {
+			_, err := jws.Verify(sign(t, jws.JWKSetURLKey, u), jwa.ES256, pubkey, policy)
+			if allowed {
+				assert.NoError(t, err, `jws.Verify should allow %s`, u)
+			} else {
+				assert.Error(t, err, `jws.Verify should reject %s`, u)
+			}
+		}
+	})
+	t.Run("Resolver", func(t *testing.T) {
+		t.Parallel()
+		const jku = `https://keys.example.com/jwks.json`
+		var resolved []string
+		set := jwk.NewSet()
+		set.Add(pubkey)
+		policy := jws.WithRemoteKeyPolicy(jws.RemoteKeyPolicy{
+			AllowedURLs: []string{jku},
+			Resolver: func(_ context.Context, name, u string) (jwk.Set, error) {
+				resolved = append(resolved, name+" "+u)
+				return set, nil
+			},
+		})
+
+		payload, err := jws.Verify(sign(t, jws.JWKSetURLKey, jku), jwa.ES256, nil, policy)
+		if !assert.NoError(t, err, `jws.Verify should succeed with the resolved key`) {
+			return
+		}
+		assert.Equal(t, []byte(`hello`), payload, `payload should match`)
+		assert.Equal(t, []string{`jku ` + jku}, resolved, `resolver should be called once`)
+
+		set.Remove(pubkey)
+		_, err = jws.Verify(sign(t, jws.JWKSetURLKey, jku), jwa.ES256, nil, policy)
+		assert.Error(t, err, `jws.Verify should fail when the key is not in the resolved set`)
+	})
+	t.Run("JSON", func(t *testing.T) {
+		t.Parallel()
+		signer, err := jws.NewSigner(jwa.ES256)
+		if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+			return
+		}
+		public := jws.NewHeaders()
+		_ = public.Set(jws.JWKSetURLKey, `https://evil.example.com/jwks.json`)
+		signed, err := jws.SignMulti([]byte(`hello`), jws.WithSigner(signer, key, public, nil))
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.ES256, pubkey, jws.WithRemoteKeyPolicy(jws.RemoteKeyPolicy{}))
+		assert.Error(t, err, `jws.Verify should reject "jku" in the unprotected header`)
+	})
+}
+
+func TestHardenedVerification(t *testing.T) {
+	t.Parallel()
+
+	key := []byte(`a-very-secret-hmac-key-of-sufficient-length`)
+	payload := []byte(examplePayload)
+
+	signed, err := jws.Sign(payload, jwa.HS256, key)
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+
+	// a message whose "alg" header does not match the signature
+	signer, err := jws.NewSigner(jwa.HS256)
+	if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+		return
+	}
+	input := base64.EncodeToString([]byte(`{"alg":"HS384"}`)) + `.` + base64.EncodeToString(payload)
+	sig, err := signer.Sign([]byte(input), key)
+	if !assert.NoError(t, err, `signer.Sign should succeed`) {
+		return
+	}
+	mismatched := []byte(input + `.` + base64.EncodeToString(sig))
+
+	protected, encodedPayload, _, err := jws.SplitCompact(signed)
+	if !assert.NoError(t, err, `jws.SplitCompact should succeed`) {
+		return
+	}
+	empty := []byte(string(protected) + `.` + string(encodedPayload) + `.`)
+
+	jsonSigned, err := jws.SignMulti(payload, jws.WithSigner(signer, key, nil, nil))
+	if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name   string
+		Signed []byte
+		Alg    jwa.SignatureAlgorithm
+		Key    interface{}
+		Lax    bool // true if verification without the option should succeed
+		Error  bool
+	}{
+		{Name: "compact", Signed: signed, Alg: jwa.HS256, Key: key, Lax: true},
+		{Name: "JSON", Signed: jsonSigned, Alg: jwa.HS256, Key: key, Lax: true},
+		{Name: "wrong key", Signed: signed, Alg: jwa.HS256, Key: []byte(`wrong`), Error: true},
+		{Name: "mismatched alg header", Signed: mismatched, Alg: jwa.HS256, Key: key, Lax: true, Error: true},
+		{Name: "mixed-case alg", Signed: signed, Alg: jwa.SignatureAlgorithm(`hs256`), Key: key, Error: true},
+		{Name: "empty signature", Signed: empty, Alg: jwa.HS256, Key: key, Error: true},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			if tc.Lax {
+				_, err := jws.Verify(tc.Signed, tc.Alg, tc.Key)
+				if !assert.NoError(t, err, `jws.Verify without hardening should succeed`) {
+					return
+				}
+			}
+
+			verified, err := jws.Verify(tc.Signed, tc.Alg, tc.Key, jws.WithHardenedVerification())
+			if tc.Error {
+				if !assert.True(t, errors.Is(err, jwx.ErrVerification), `jws.Verify should fail with a verification error`) {
+					return
+				}
+				return
+			}
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payload should match`) {
+				return
+			}
+		})
+	}
+}
+
+func TestZeroizeBuffers(t *testing.T) {
+	t.Parallel()
+
+	symmetric, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+		return
+	}
+	ecdsaKey, err := jwxtest.GenerateEcdsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaJwk should succeed`) {
+		return
+	}
+	ed25519Key, err := jwxtest.GenerateEd25519Jwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Jwk should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name string
+		Alg  jwa.SignatureAlgorithm
+		Key  jwk.Key
+	}{
+		{Name: "HS256", Alg: jwa.HS256, Key: symmetric},
+		{Name: "ES256", Alg: jwa.ES256, Key: ecdsaKey},
+		{Name: "EdDSA", Alg: jwa.EdDSA, Key: ed25519Key},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			pubkey, err := jwk.PublicKeyOf(tc.Key)
+			if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+				return
+			}
+
+			// sign twice, to make sure that the key is still usable
+			for i := 0; i < 2; i++ {
+				signed, err := jws.Sign([]byte(examplePayload), tc.Alg, tc.Key, jws.WithZeroizeBuffers())
+				if !assert.NoError(t, err, `jws.Sign should succeed`) {
+					return
+				}
+				if _, err := jws.Verify(signed, tc.Alg, pubkey); !assert.NoError(t, err, `jws.Verify should succeed`) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("Avracadabra-Avracadabra-Avracadabra")
+	payload := []byte("Lorem ipsum")
+
+	// A stand-in for a TSA: the "token" is a digest of the signature,
+	// which the verifier recomputes
+	tokenFor := func(signature []byte) []byte {
+		h := sha512.Sum512(append([]byte(`tsa:`), signature...))
+		return h[:]
+	}
+	timestamper := jws.TimestamperFunc(func(_ context.Context, signature []byte) ([]byte, error) {
+		return tokenFor(signature), nil
+	})
+	tsVerifier := jws.TimestampVerifierFunc(func(_ context.Context, signature, token []byte) error {
+		if !bytes.Equal(tokenFor(signature), token) {
+			return errors.New(`token does not match signature`)
+		}
+		return nil
+	})
+
+	signer, err := jws.NewSigner(jwa.HS256)
+	if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+		return
+	}
+	signed, err := jws.SignMulti(payload, jws.WithSigner(signer, key, nil, nil), jws.WithTimestamper(timestamper))
+	if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+		return
+	}
+
+	t.Run("Verify", func(t *testing.T) {
+		t.Parallel()
+		var m jws.Message
+		verified, err := jws.Verify(signed, jwa.HS256, key, jws.WithTimestampVerifier(tsVerifier), jws.WithMessage(&m))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payload should match`) {
+			return
+		}
+
+		sig := m.Signatures()[0]
+		token, ok := sig.TimestampToken()
+		if !assert.True(t, ok, `signature should have a timestamp token`) {
+			return
+		}
+		assert.Equal(t, tokenFor(sig.Signature()), token, `token should match`)
+	})
+	t.Run("Rejected by verifier", func(t *testing.T) {
+		t.Parallel()
+		reject := jws.TimestampVerifierFunc(func(context.Context, []byte, []byte) error {
+			return errors.New(`timestamp is too old`)
+		})
+		_, err := jws.Verify(signed, jwa.HS256, key, jws.WithTimestampVerifier(reject))
+		assert.Error(t, err, `jws.Verify should fail`)
+	})
+	t.Run("Missing timestamp token", func(t *testing.T) {
+		t.Parallel()
+		unstamped, err := jws.SignMulti(payload, jws.WithSigner(signer, key, nil, nil))
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(unstamped, jwa.HS256, key); !assert.NoError(t, err, `jws.Verify should succeed without jws.WithTimestampVerifier`) {
+			return
+		}
+		_, err = jws.Verify(unstamped, jwa.HS256, key, jws.WithTimestampVerifier(tsVerifier))
+		assert.Error(t, err, `jws.Verify should fail`)
+	})
+	t.Run("Compact serialization", func(t *testing.T) {
+		t.Parallel()
+		compact, err := jws.Sign(payload, jwa.HS256, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = jws.Verify(compact, jwa.HS256, key, jws.WithTimestampVerifier(tsVerifier))
+		assert.Error(t, err, `jws.Verify should fail`)
+	})
+	t.Run("Timestamper error", func(t *testing.T) {
+		t.Parallel()
+		failing := jws.TimestamperFunc(func(context.Context, []byte) ([]byte, error) {
+			return nil, errors.New(`TSA unavailable`)
+		})
+		_, err := jws.SignMulti(payload, jws.WithSigner(signer, key, nil, nil), jws.WithTimestamper(failing))
+		assert.Error(t, err, `jws.SignMulti should fail`)
+	})
+}
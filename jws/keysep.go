@@ -0,0 +1,31 @@
+package jws
+
+import (
+	"crypto"
+
+	"github.com/lestrrat-go/jwx/internal/keysep"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// checkKeySeparation records the use of the key for signatures, and
+// reports a violation if it has been used for encryption.
+// See `jwx.WithKeySeparation()`
+func checkKeySeparation(key interface{}) error {
+	if !keysep.Enabled() {
+		return nil
+	}
+
+	k, ok := key.(jwk.Key)
+	if !ok {
+		if signer, ok := key.(crypto.Signer); ok {
+			key = signer.Public()
+		}
+		v, err := jwk.New(key)
+		if err != nil {
+			// keys that we can't identify can't be tracked
+			return nil //nolint:nilerr
+		}
+		k = v
+	}
+	return keysep.Check(keysep.Signature, k)
+}
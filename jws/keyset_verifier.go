@@ -0,0 +1,203 @@
+package jws
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/hook"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// KeySetVerifier verifies messages against the keys in a jwk.Set, much
+// like `jws.VerifySet()`, but does the per-key work up front: the raw
+// keys are materialized and the verifiers are created once, when the
+// KeySetVerifier is created, and the keys are indexed by "kid" and "alg".
+// This makes a notable difference when verifying many messages against
+// a large key set.
+//
+// Changes made to the jwk.Set after the KeySetVerifier is created are
+// not reflected. Create a new KeySetVerifier when the set is updated.
+// If the set implements `jwk.UsageRecorder`, successful verifications
+// are recorded with it.
+//
+// A KeySetVerifier is safe for concurrent use.
+type KeySetVerifier struct {
+	byKeyID  map[string][]*keySetEntry
+	byAlg    map[jwa.SignatureAlgorithm][]*keySetEntry
+	recorder jwk.UsageRecorder
+}
+
+type keySetEntry struct {
+	key      jwk.Key
+	raw      interface{}
+	alg      jwa.SignatureAlgorithm
+	verifier Verifier
+}
+
+// NewKeySetVerifier creates a KeySetVerifier from the keys in `set`.
+// The same keys as `jws.VerifySet()` are used: keys must have a valid
+// "alg" field, and either an empty value or the value "sig" in the
+// "use" field. Other keys are ignored.
+//
+// An error is returned if a key cannot be converted to a raw key, or if
+// no verifier is available for its algorithm.
+func NewKeySetVerifier(set jwk.Set) (*KeySetVerifier, error) {
+	v := &KeySetVerifier{
+		byKeyID: make(map[string][]*keySetEntry),
+		byAlg:   make(map[jwa.SignatureAlgorithm][]*keySetEntry),
+	}
+	v.recorder, _ = set.(jwk.UsageRecorder)
+
+	for i, key := range signatureKeys(set) {
+		alg := jwa.SignatureAlgorithm(key.Algorithm())
+		verifier, err := NewVerifier(alg)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create verifier for key #%d`, i)
+		}
+
+		var raw interface{}
+		if err := key.Raw(&raw); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve raw key for key #%d`, i)
+		}
+		// Private keys are only ever used to verify, so keep the
+		// public part only
+		if pub, err := jwk.PublicRawKeyOf(raw); err == nil {
+			raw = pub
+		}
+
+		entry := &keySetEntry{
+			key:      key,
+			raw:      raw,
+			alg:      alg,
+			verifier: verifier,
+		}
+		v.byAlg[alg] = append(v.byAlg[alg], entry)
+		if kid := key.KeyID(); kid != "" {
+			v.byKeyID[kid] = append(v.byKeyID[kid], entry)
+		}
+	}
+	return v, nil
+}
+
+// Verify verifies the message in `buf` using the keys that match the
+// "alg" and "kid" (if any) of its signatures, and returns the payload.
+// The verification succeeds if any of the keys verifies the message.
+//
+// Options are handled as in `jws.Verify()`. `jws.WithThreshold()` is not
+// supported.
+func (v *KeySetVerifier) Verify(buf []byte, options ...VerifyOption) ([]byte, error) {
+	return v.VerifyContext(context.Background(), buf, options...)
+}
+
+// VerifyContext is the same as Verify, but accepts a context.Context.
+// An error is returned without verifying if the context has already
+// been canceled.
+func (v *KeySetVerifier) VerifyContext(ctx context.Context, buf []byte, options ...VerifyOption) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `failed to verify message`)
+	}
+
+	buf = bytes.TrimSpace(buf)
+	if len(buf) == 0 {
+		return nil, errors.New(`attempt to verify empty buffer`)
+	}
+
+	entries, err := v.candidates(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		span := hook.Start(hook.VerifyKind)
+		span.SetAlgorithm(entry.alg.String())
+		span.SetKeyID(entry.key.KeyID())
+		payload, err := verify(ctx, buf, entry.alg, entry.raw, entry.verifier, options...)
+		span.End(err)
+		if err != nil {
+			continue
+		}
+		if v.recorder != nil {
+			v.recorder.RecordUsage(entry.key, jwk.KeyOpVerify)
+		}
+		return payload, nil
+	}
+
+	return nil, newVerificationError(errors.New(`failed to verify message with any of the keys in the jwk.Set object`))
+}
+
+// candidates returns the keys that may have been used to sign the message,
+// based on the "alg" and "kid" headers of each signature
+func (v *KeySetVerifier) candidates(buf []byte) ([]*keySetEntry, error) {
+	if err := limits.CheckInputSize(len(buf)); err != nil {
+		return nil, newParseError(err)
+	}
+
+	if buf[0] != '{' {
+		protected, _, _, err := SplitCompact(buf)
+		if err != nil {
+			return nil, newParseError(errors.Wrap(err, `failed extract from compact serialization format`))
+		}
+		decoded, err := base64.Decode(protected)
+		if err != nil {
+			return nil, newParseError(errors.Wrap(err, `failed to decode headers`))
+		}
+		if err := limits.CheckHeader(decoded); err != nil {
+			return nil, newParseError(errors.Wrap(err, `invalid protected headers`))
+		}
+
+		var hdr struct {
+			Algorithm jwa.SignatureAlgorithm `json:"alg"`
+			KeyID     string                 `json:"kid"`
+		}
+		if err := json.Unmarshal(decoded, &hdr); err != nil {
+			return nil, newParseError(errors.Wrap(err, `failed to decode headers`))
+		}
+		return v.lookup(nil, hdr.Algorithm, hdr.KeyID), nil
+	}
+
+	m, err := Parse(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse message`)
+	}
+
+	var entries []*keySetEntry
+	for _, sig := range m.signatures {
+		if sig.protected == nil {
+			continue
+		}
+		kid := sig.protected.KeyID()
+		if kid == "" && sig.headers != nil {
+			kid = sig.headers.KeyID()
+		}
+		entries = v.lookup(entries, sig.protected.Algorithm(), kid)
+	}
+	return entries, nil
+}
+
+// lookup appends the keys matching `alg` and `kid` to `entries`,
+// skipping those that are already included
+func (v *KeySetVerifier) lookup(entries []*keySetEntry, alg jwa.SignatureAlgorithm, kid string) []*keySetEntry {
+	list := v.byAlg[alg]
+	if kid != "" {
+		list = v.byKeyID[kid]
+	}
+
+LOOP:
+	for _, entry := range list {
+		if entry.alg != alg {
+			continue
+		}
+		for _, seen := range entries {
+			if seen == entry {
+				continue LOOP
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
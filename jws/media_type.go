@@ -0,0 +1,61 @@
+package jws
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// headerRequirements holds the values that the "typ" and "cty" protected
+// headers must have for a signature to be accepted. A nil field means
+// that the header is not checked. `remote`, if not nil, controls how
+// the "jku" and "x5u" headers are handled, and `hardened`, if not nil,
+// enables the checks of `jws.WithHardenedVerification()`.
+type headerRequirements struct {
+	typ      *string
+	cty      *string
+	remote   *remoteKeyCheck
+	hardened  *hardenedCheck
+	timestamp *timestampCheck
+}
+
+func (r *headerRequirements) empty() bool {
+	return r == nil || (r.typ == nil && r.cty == nil)
+}
+
+// check verifies the protected headers of a signature. It must only be
+// given headers that are covered by the signature.
+func (r *headerRequirements) check(protected Headers) error {
+	if r.empty() {
+		return nil
+	}
+
+	var typ, cty string
+	if protected != nil {
+		typ = protected.Type()
+		cty = protected.ContentType()
+	}
+
+	if r.typ != nil && !equalMediaType(*r.typ, typ) {
+		return errors.Errorf(`"typ" header %q does not match expected value %q`, typ, *r.typ)
+	}
+	if r.cty != nil && !equalMediaType(*r.cty, cty) {
+		return errors.Errorf(`"cty" header %q does not match expected value %q`, cty, *r.cty)
+	}
+	return nil
+}
+
+// equalMediaType compares two media types as described in
+// RFC 7515 section 4.1.9: the comparison is case-insensitive, and
+// the "application/" prefix is optional
+func equalMediaType(a, b string) bool {
+	return strings.EqualFold(trimMediaType(a), trimMediaType(b))
+}
+
+func trimMediaType(s string) string {
+	const prefix = `application/`
+	if len(s) > len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) && !strings.Contains(s[len(prefix):], `/`) {
+		return s[len(prefix):]
+	}
+	return s
+}
@@ -4,12 +4,20 @@ import (
 	"context"
 
 	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/hook"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
 	"github.com/lestrrat-go/jwx/internal/pool"
+	"github.com/lestrrat-go/jwx/internal/zeroize"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/pkg/errors"
 )
 
+// signatureSlack is the number of bytes reserved in pooled buffers for
+// the parts of a message other than the payload, such as the encoded
+// protected headers and signature
+const signatureSlack = 1024
+
 func NewSignature() *Signature {
 	return &Signature{}
 }
@@ -48,6 +56,14 @@ func (s *Signature) SetSignature(v []byte) *Signature {
 // The second return value s the full three-segment signature
 // (e.g. "eyXXXX.XXXXX.XXXX")
 func (s *Signature) Sign(payload []byte, signer Signer, key interface{}) ([]byte, []byte, error) {
+	span := hook.Start(hook.SignKind)
+	span.SetAlgorithm(signer.Algorithm().String())
+	signature, serialized, err := s.sign(payload, signer, key, span)
+	span.End(err)
+	return signature, serialized, err
+}
+
+func (s *Signature) sign(payload []byte, signer Signer, key interface{}, span *hook.Span) ([]byte, []byte, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -69,17 +85,53 @@ func (s *Signature) Sign(payload []byte, signer Signer, key interface{}) ([]byte
 			}
 		}
 	}
+	span.SetKeyID(hdrs.KeyID())
+
+	if s.policy != nil {
+		// In compact serialization all headers are protected. Otherwise
+		// only s.protected is serialized as the protected header
+		protected := hdrs
+		if s.headers != nil {
+			protected = s.protected
+		}
+		if err := s.policy.check(protected, s.headers); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	hdrbuf, err := json.Marshal(hdrs)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, `failed to marshal headers`)
 	}
 
-	buf := pool.GetBytesBuffer()
+	encodedHdr := base64.EncodeToString(hdrbuf)
+	encodedPayload := base64.EncodeToString(payload)
+
+	// leave room for the signature, which is appended to the same buffer
+	buf := pool.GetBytesBufferSize(len(encodedHdr) + len(encodedPayload) + signatureSlack)
 	defer pool.ReleaseBytesBuffer(buf)
+	if s.zeroize {
+		defer zeroizeBuffer(buf)
+	}
 
-	buf.WriteString(base64.EncodeToString(hdrbuf))
+	buf.WriteString(encodedHdr)
 	buf.WriteByte('.')
-	buf.WriteString(base64.EncodeToString(payload))
+	buf.WriteString(encodedPayload)
+
+	if err := checkKeySeparation(key); err != nil {
+		return nil, nil, errors.Wrap(err, `failed to sign payload`)
+	}
+
+	if s.zeroize {
+		if jwkKey, ok := key.(jwk.Key); ok {
+			raw, err := rawSigningKey(jwkKey)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, `failed to sign payload`)
+			}
+			defer zeroize.Key(raw)
+			key = raw
+		}
+	}
 
 	signature, err := signer.Sign(buf.Bytes(), key)
 	if err != nil {
@@ -166,6 +218,10 @@ type signatureProxy struct {
 }
 
 func (m *Message) UnmarshalJSON(buf []byte) error {
+	if err := limits.CheckDepth(buf); err != nil {
+		return err
+	}
+
 	var proxy messageProxy
 	if err := json.Unmarshal(buf, &proxy); err != nil {
 		return errors.Wrap(err, `failed to unmarshal into temporary structure`)
@@ -199,10 +255,17 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 		proxy.Signatures = append(proxy.Signatures, &sigproxy)
 	}
 
+	if err := limits.CheckSignatures(len(proxy.Signatures)); err != nil {
+		return err
+	}
+
 	for i, sigproxy := range proxy.Signatures {
 		var sig Signature
 
 		if len(sigproxy.Header) > 0 {
+			if err := limits.CheckHeader(sigproxy.Header); err != nil {
+				return errors.Wrapf(err, `invalid "header" for signature #%d`, i+1)
+			}
 			sig.headers = NewHeaders()
 			if err := json.Unmarshal(sigproxy.Header, sig.headers); err != nil {
 				return errors.Wrapf(err, `failed to unmarshal "header" for signature #%d`, i+1)
@@ -214,6 +277,9 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 			if err != nil {
 				return errors.Wrapf(err, `failed to decode "protected" for signature #%d`, i+1)
 			}
+			if err := limits.CheckHeader(buf); err != nil {
+				return errors.Wrapf(err, `invalid "protected" for signature #%d`, i+1)
+			}
 			sig.protected = NewHeaders()
 			if err := json.Unmarshal(buf, sig.protected); err != nil {
 				return errors.Wrapf(err, `failed to unmarshal "protected" for signature #%d`, i+1)
@@ -242,8 +308,14 @@ func (m Message) MarshalJSON() ([]byte, error) {
 	return m.marshalFull()
 }
 
+// encodedSize estimates the size of the serialized message, so that
+// a buffer of the appropriate size can be obtained from the pool
+func (m Message) encodedSize() int {
+	return (len(m.payload)+2)/3*4 + len(m.signatures)*(signatureSlack*2)
+}
+
 func (m Message) marshalFlattened() ([]byte, error) {
-	buf := pool.GetBytesBuffer()
+	buf := pool.GetBytesBufferSize(m.encodedSize())
 	defer pool.ReleaseBytesBuffer(buf)
 
 	sig := m.signatures[0]
@@ -289,7 +361,7 @@ func (m Message) marshalFlattened() ([]byte, error) {
 }
 
 func (m Message) marshalFull() ([]byte, error) {
-	buf := pool.GetBytesBuffer()
+	buf := pool.GetBytesBufferSize(m.encodedSize())
 	defer pool.ReleaseBytesBuffer(buf)
 
 	buf.WriteString(`{"payload":"`)
@@ -0,0 +1,127 @@
+// +build jwx_mldsa,go1.27
+
+package jws
+
+import (
+	"crypto"
+	"crypto/mldsa"
+	"crypto/rand"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// This file is only available if compiled with jwx_mldsa build tag,
+// and Go 1.27 or later.
+
+func init() {
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.MLDSA44, jwa.MLDSA65, jwa.MLDSA87} {
+		RegisterSigner(alg, func(alg jwa.SignatureAlgorithm) SignerFactory {
+			return SignerFactoryFn(func() (Signer, error) {
+				return newMLDSASigner(alg), nil
+			})
+		}(alg))
+		RegisterVerifier(alg, func(alg jwa.SignatureAlgorithm) VerifierFactory {
+			return VerifierFactoryFn(func() (Verifier, error) {
+				return newMLDSAVerifier(alg), nil
+			})
+		}(alg))
+	}
+}
+
+func mldsaParameters(alg jwa.SignatureAlgorithm) mldsa.Parameters {
+	switch alg {
+	case jwa.MLDSA44:
+		return mldsa.MLDSA44()
+	case jwa.MLDSA65:
+		return mldsa.MLDSA65()
+	default:
+		return mldsa.MLDSA87()
+	}
+}
+
+// MLDSASigner signs payloads using ML-DSA. The key may be a
+// *mldsa.PrivateKey, a jwk.Key containing an ML-DSA private key,
+// or any crypto.Signer whose public key is a *mldsa.PublicKey.
+type MLDSASigner struct {
+	alg jwa.SignatureAlgorithm
+}
+
+func newMLDSASigner(alg jwa.SignatureAlgorithm) Signer {
+	return &MLDSASigner{alg: alg}
+}
+
+func (s MLDSASigner) Algorithm() jwa.SignatureAlgorithm {
+	return s.alg
+}
+
+func (s MLDSASigner) Sign(payload []byte, key interface{}) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New(`missing private key while signing payload`)
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return nil, errors.Wrapf(err, `failed to retrieve raw key out of %T`, key)
+		}
+		key = raw
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf(`invalid key type %T for %s: expected crypto.Signer`, key, s.alg)
+	}
+	pubkey, ok := signer.Public().(*mldsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf(`invalid public key type %T for %s`, signer.Public(), s.alg)
+	}
+	if pubkey.Parameters() != mldsaParameters(s.alg) {
+		return nil, errors.Errorf(`key parameters %s do not match algorithm %s`, pubkey.Parameters(), s.alg)
+	}
+	return signer.Sign(rand.Reader, payload, &mldsa.Options{})
+}
+
+// MLDSAVerifier verifies ML-DSA signatures. The key may be a
+// *mldsa.PublicKey, a *mldsa.PrivateKey, or a jwk.Key containing
+// an ML-DSA key.
+type MLDSAVerifier struct {
+	alg jwa.SignatureAlgorithm
+}
+
+func newMLDSAVerifier(alg jwa.SignatureAlgorithm) Verifier {
+	return &MLDSAVerifier{alg: alg}
+}
+
+func (v MLDSAVerifier) Verify(payload, signature []byte, key interface{}) error {
+	if key == nil {
+		return errors.New(`missing public key while verifying payload`)
+	}
+
+	if jwkKey, ok := key.(jwk.Key); ok {
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return errors.Wrapf(err, `failed to retrieve raw key out of %T`, key)
+		}
+		key = raw
+	}
+
+	var pubkey *mldsa.PublicKey
+	switch key := key.(type) {
+	case *mldsa.PublicKey:
+		pubkey = key
+	case *mldsa.PrivateKey:
+		pubkey = key.PublicKey()
+	default:
+		return errors.Errorf(`invalid key type %T for %s`, key, v.alg)
+	}
+	if pubkey.Parameters() != mldsaParameters(v.alg) {
+		return errors.Errorf(`key parameters %s do not match algorithm %s`, pubkey.Parameters(), v.alg)
+	}
+
+	if err := mldsa.Verify(pubkey, payload, signature, &mldsa.Options{}); err != nil {
+		return errors.Wrap(err, `failed to match ML-DSA signature`)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// +build jwx_mldsa,go1.27
+
+package jws_test
+
+import (
+	"crypto/mldsa"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMLDSA(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Hello, World!")
+	algs := map[jwa.SignatureAlgorithm]mldsa.Parameters{
+		jwa.MLDSA44: mldsa.MLDSA44(),
+		jwa.MLDSA65: mldsa.MLDSA65(),
+		jwa.MLDSA87: mldsa.MLDSA87(),
+	}
+	for alg, params := range algs {
+		alg := alg
+		params := params
+		t.Run(alg.String(), func(t *testing.T) {
+			t.Parallel()
+			key, err := mldsa.GenerateKey(params)
+			if !assert.NoError(t, err, `mldsa.GenerateKey should succeed`) {
+				return
+			}
+			jwkKey, err := jwk.New(key.PublicKey())
+			if !assert.NoError(t, err, `jwk.New should succeed`) {
+				return
+			}
+			keys := map[string]interface{}{
+				"Verify(*mldsa.PublicKey)": key.PublicKey(),
+				"Verify(jwk.Key)":          jwkKey,
+			}
+			testRoundtrip(t, payload, alg, key, keys)
+
+			t.Run("Sign(jwk.Key)", func(t *testing.T) {
+				privJWK, err := jwk.New(key)
+				if !assert.NoError(t, err, `jwk.New should succeed`) {
+					return
+				}
+				signed, err := jws.Sign(payload, alg, privJWK)
+				if !assert.NoError(t, err, `jws.Sign should succeed`) {
+					return
+				}
+				verified, err := jws.Verify(signed, alg, key.PublicKey())
+				if !assert.NoError(t, err, `jws.Verify should succeed`) {
+					return
+				}
+				assert.Equal(t, payload, verified, `payloads should match`)
+			})
+			t.Run("parameter mismatch", func(t *testing.T) {
+				for other := range algs {
+					if other == alg {
+						continue
+					}
+					_, err := jws.Sign(payload, other, key)
+					assert.Error(t, err, `jws.Sign with mismatched algorithm should fail`)
+				}
+			})
+		})
+	}
+}
+
+func TestMLDSAHybrid(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Hello, World!")
+	ecKey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	pqKey, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	if !assert.NoError(t, err, `mldsa.GenerateKey should succeed`) {
+		return
+	}
+
+	signed, err := jws.SignHybrid(payload, jwa.ES256, ecKey, jwa.MLDSA65, pqKey)
+	if !assert.NoError(t, err, `jws.SignHybrid should succeed`) {
+		return
+	}
+
+	verified, err := jws.VerifyHybrid(signed, jwa.ES256, &ecKey.PublicKey, jwa.MLDSA65, pqKey.PublicKey())
+	if !assert.NoError(t, err, `jws.VerifyHybrid should succeed`) {
+		return
+	}
+	if !assert.Equal(t, payload, verified, `payloads should match`) {
+		return
+	}
+}
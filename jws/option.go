@@ -1,14 +1,33 @@
 package jws
 
 import (
+	"context"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/option"
 )
 
 type Option = option.Interface
 
 type identPayloadSigner struct{}
+type identRemoteKeyPolicy struct{}
+type identContentType struct{}
+type identEdDSAOptions struct{}
+type identExpectedContentType struct{}
+type identExpectedType struct{}
 type identHeaders struct{}
 type identMessage struct{}
+type identHardenedVerification struct{}
+type identHybridPolicy struct{}
+type identKeyProviderForSigning struct{}
+type identSigningPolicy struct{}
+type identThreshold struct{}
+type identTimestamper struct{}
+type identTimestampVerifier struct{}
+type identType struct{}
+type identZeroizeBuffers struct{}
+type identVerificationCache struct{}
 
 func WithSigner(signer Signer, key interface{}, public, protected Headers) Option {
 	return option.New(identPayloadSigner{}, &payloadSigner{
@@ -36,6 +55,99 @@ func WithHeaders(h Headers) SignOption {
 	return &signOption{option.New(identHeaders{}, h)}
 }
 
+// WithType specifies the value of the "typ" protected header, which
+// declares the media type of the complete JWS message (e.g. "JOSE" or
+// "logout+jwt"). It takes precedence over a "typ" header specified
+// via `jws.WithHeaders()`.
+func WithType(typ string) SignOption {
+	return &signOption{option.New(identType{}, typ)}
+}
+
+// WithSigningPolicy specifies the policy that the JOSE headers of the
+// message must conform to. If they do not, signing fails.
+// See `jws.SigningPolicy` for details.
+//
+// This option can also be passed to `jws.SignMulti()`, in which case
+// the policy applies to all signatures.
+func WithSigningPolicy(p SigningPolicy) SignOption {
+	return &signOption{option.New(identSigningPolicy{}, &p)}
+}
+
+// WithZeroizeBuffers specifies that the transient buffers used while
+// signing are overwritten with zeros after use. If the key is a jwk.Key,
+// this includes the raw private key that is materialized from it. Raw
+// keys passed by the caller are never modified.
+//
+// This option can also be passed to `jws.SignMulti()`. See also
+// `jwk.Key.Zeroize()` and `jws.WithHardenedVerification()`.
+func WithZeroizeBuffers() SignOption {
+	return &signOption{option.New(identZeroizeBuffers{}, true)}
+}
+
+// WithContentType specifies the value of the "cty" protected header,
+// which declares the media type of the payload (e.g. "JWT" for nested
+// tokens). It takes precedence over a "cty" header specified via
+// `jws.WithHeaders()`.
+func WithContentType(cty string) SignOption {
+	return &signOption{option.New(identContentType{}, cty)}
+}
+
+// SigningKeyProvider is called by `jws.Sign()` to obtain the algorithm
+// and the key to sign with, as well as the key ID to include in the
+// "kid" header. An empty key ID leaves the header unchanged.
+type SigningKeyProvider func(context.Context) (jwa.SignatureAlgorithm, interface{}, string, error)
+
+// WithKeyProviderForSigning specifies a SigningKeyProvider that is
+// called every time a payload is signed. The algorithm and the key
+// that it returns are used instead of those passed to `jws.Sign()`,
+// which are ignored.
+//
+// This allows long-running processes to pick up rotated keys as soon
+// as they become available, without having to be restarted. The context
+// passed to `jws.SignContext()` is passed on to the provider.
+func WithKeyProviderForSigning(p SigningKeyProvider) SignOption {
+	return &signOption{option.New(identKeyProviderForSigning{}, p)}
+}
+
+// EdDSAOptions specifies the variant of EdDSA described in RFC 8032
+// that is used to sign or verify EdDSA signatures. The zero value
+// selects plain Ed25519, which is what the "EdDSA" algorithm means
+// in JWS. See `jws.WithEdDSAOptions()`
+type EdDSAOptions struct {
+	// Context is bound to the signature, and must be the same when
+	// signing and verifying. It may be at most 255 bytes long. A non-empty
+	// context without Prehash selects Ed25519ctx
+	Context string
+	// Prehash selects Ed25519ph, where the SHA-512 digest of the signing
+	// input is signed instead of the signing input itself
+	Prehash bool
+}
+
+// SignVerifyOption describes an option that can be passed to both
+// the jws.Sign and the jws.Verify functions
+type SignVerifyOption interface {
+	SignOption
+	VerifyOption
+}
+
+type signVerifyOption struct {
+	Option
+}
+
+func (*signVerifyOption) signOption()   {}
+func (*signVerifyOption) verifyOption() {}
+
+// WithEdDSAOptions specifies that EdDSA signatures are created or verified
+// using the Ed25519ph or Ed25519ctx variants of RFC 8032, for interoperability
+// with systems that require them. The "alg" header remains "EdDSA", so
+// the same options must be passed to both jws.Sign and jws.Verify.
+//
+// The option may only be used with the EdDSA algorithm and Ed25519 keys,
+// and requires Go 1.20 or later.
+func WithEdDSAOptions(opts EdDSAOptions) SignVerifyOption {
+	return &signVerifyOption{option.New(identEdDSAOptions{}, &opts)}
+}
+
 // VerifyOption describes an option that can be passed to the jws.Verify function
 type VerifyOption interface {
 	Option
@@ -53,3 +165,109 @@ func (*verifyOption) verifyOption() {}
 func WithMessage(m *Message) VerifyOption {
 	return &verifyOption{option.New(identMessage{}, m)}
 }
+
+// WithHybridPolicy can be passed to VerifyHybrid() to specify whether
+// both signatures (the default), or just one of them need to be verified.
+func WithHybridPolicy(p HybridPolicy) VerifyOption {
+	return &verifyOption{option.New(identHybridPolicy{}, p)}
+}
+
+// WithRemoteKeyPolicy specifies how the "jku" and "x5u" headers are
+// handled during verification: messages that refer to URLs that are not
+// in the allow-list are rejected, and if a resolver is given, the keys
+// are obtained from it. See `jws.RemoteKeyPolicy` for details.
+//
+// Verified messages are not cached when this option is specified.
+func WithRemoteKeyPolicy(p RemoteKeyPolicy) VerifyOption {
+	return &verifyOption{option.New(identRemoteKeyPolicy{}, &p)}
+}
+
+// WithExpectedType can be passed to Verify() to require that the "typ"
+// protected header of the verified signature has the given value. This
+// prevents a message that was signed for one purpose from being accepted
+// for another, as long as the issuer declares the type of its messages.
+//
+// As described in RFC 7515, the values are compared case-insensitively,
+// and the "application/" prefix may be omitted on either side. Headers
+// that are not integrity protected are never considered.
+func WithExpectedType(typ string) VerifyOption {
+	return &verifyOption{option.New(identExpectedType{}, typ)}
+}
+
+// WithExpectedContentType is the same as `jws.WithExpectedType()`, but
+// applies to the "cty" protected header.
+func WithExpectedContentType(cty string) VerifyOption {
+	return &verifyOption{option.New(identExpectedContentType{}, cty)}
+}
+
+// WithHardenedVerification enables additional checks during Verify(),
+// for deployments that require a high level of assurance:
+//
+//   * The "alg" protected header must be present, and must exactly match
+//     the algorithm passed to Verify()
+//   * Algorithm names that only differ in case from a known algorithm
+//     (e.g. "hs256") are rejected
+//   * Empty signatures are rejected before any cryptographic operation
+//   * Intermediate buffers holding decoded headers and signatures are
+//     overwritten with zeros after use
+//
+// HMAC signatures are always compared in constant time, and the computed
+// MAC is always overwritten with zeros, with or without this option.
+//
+// Note that the Go runtime may still leave copies of these values in
+// memory, so zeroization is a best-effort measure.
+func WithHardenedVerification() VerifyOption {
+	return &verifyOption{option.New(identHardenedVerification{}, true)}
+}
+
+// WithVerificationCache creates a cache of up to `size` successfully
+// verified messages, which can be passed to Verify(). When the identical
+// message is verified again with the same algorithm and key within `ttl`
+// of its first verification, the payload is returned without verifying
+// the signature again. This is useful when the same token is presented
+// repeatedly, for example an access token that is sent to many endpoints.
+//
+// The cache lives within the returned option, so the option must be
+// created once and passed to every call to Verify() that should share
+// the cache. It is safe to use the option concurrently.
+//
+// Keys are identified by their thumbprint, so cached entries are never
+// used after the key has been changed, e.g. when it has been rotated out
+// of a key set. Keys that cannot be converted to a jwk.Key are never cached.
+func WithVerificationCache(size int, ttl time.Duration) VerifyOption {
+	return &verifyOption{option.New(identVerificationCache{}, newVerificationCache(size, ttl))}
+}
+
+// WithThreshold can be passed to VerifySet() to require that at least
+// `k` of the signatures in a message in JSON serialization are verified,
+// each by a different key in the jwk.Set. This can be used to implement
+// documents that must be approved by multiple parties.
+//
+// Keys are identified by their thumbprint, so a key that appears in the
+// set multiple times, or that produced multiple signatures, is counted once.
+// `k` must be at least 1.
+func WithThreshold(k int) VerifyOption {
+	return &verifyOption{option.New(identThreshold{}, k)}
+}
+
+// WithTimestamper specifies that after each signature is generated by
+// `jws.SignMulti()`, `ts` is used to obtain a timestamp token (e.g. an
+// RFC3161 TimeStampToken) for it. The token is stored in the "tst"
+// field of the unprotected headers of the signature.
+//
+// As the compact serialization does not have unprotected headers, this
+// option only works with `jws.SignMulti()`.
+func WithTimestamper(ts Timestamper) Option {
+	return option.New(identTimestamper{}, ts)
+}
+
+// WithTimestampVerifier specifies that the signature that is verified
+// must have a timestamp token in its unprotected headers, and that the
+// token must be accepted by `v`. Use this to enforce policies that
+// require trusted time, such as for long-lived document signatures.
+//
+// As the compact serialization does not have unprotected headers,
+// messages in compact serialization are always rejected.
+func WithTimestampVerifier(v TimestampVerifier) VerifyOption {
+	return &verifyOption{option.New(identTimestampVerifier{}, v)}
+}
@@ -0,0 +1,81 @@
+package jws
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// SigningPolicy describes constraints on the JOSE headers of the messages
+// that are signed, so that organization-wide rules can be enforced at
+// the time of signing. Pass it to `jws.Sign()` or `jws.SignMulti()`
+// using `jws.WithSigningPolicy()`.
+//
+// The headers are checked after the "alg" header, and the "kid" header
+// of `jwk.Key` objects, have been added. Protected headers are always
+// serialized with their names in lexical order, so the encoded headers
+// do not depend on the order in which they were set.
+type SigningPolicy struct {
+	// ProtectedHeaders lists the names of the headers that must be
+	// present in the protected header, e.g. "kid" and "typ".
+	ProtectedHeaders []string
+
+	// ForbiddenHeaders lists the names of the headers that must not
+	// be present in either the protected or the unprotected header,
+	// e.g. "jku" and "x5u".
+	ForbiddenHeaders []string
+
+	// AllowedHeaders, if not empty, lists the names of the only headers
+	// that may be present in either the protected or the unprotected
+	// header. "alg" is always allowed.
+	AllowedHeaders []string
+}
+
+func hasHeader(h Headers, name string) bool {
+	if h == nil {
+		return false
+	}
+	_, ok := h.Get(name)
+	return ok
+}
+
+// check reports an error if the headers of a signature violate the policy
+func (p *SigningPolicy) check(protected, public Headers) error {
+	for _, name := range p.ProtectedHeaders {
+		if !hasHeader(protected, name) {
+			if hasHeader(public, name) {
+				return errors.Errorf(`signing policy violation: header %q must be protected`, name)
+			}
+			return errors.Errorf(`signing policy violation: header %q is required`, name)
+		}
+	}
+
+	for _, name := range p.ForbiddenHeaders {
+		if hasHeader(protected, name) || hasHeader(public, name) {
+			return errors.Errorf(`signing policy violation: header %q is forbidden`, name)
+		}
+	}
+
+	if len(p.AllowedHeaders) > 0 {
+		allowed := make(map[string]struct{}, len(p.AllowedHeaders)+1)
+		allowed[AlgorithmKey] = struct{}{}
+		for _, name := range p.AllowedHeaders {
+			allowed[name] = struct{}{}
+		}
+		for _, h := range []Headers{protected, public} {
+			if h == nil {
+				continue
+			}
+			m, err := h.AsMap(context.Background())
+			if err != nil {
+				return errors.Wrap(err, `failed to convert headers to map`)
+			}
+			for name := range m {
+				if _, ok := allowed[name]; !ok {
+					return errors.Errorf(`signing policy violation: header %q is not allowed`, name)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package jws
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// RemoteKeyResolver fetches the keys referred to by the "jku" or "x5u"
+// header of a message. `name` is the name of the header, and `u` is
+// its value, which has already been checked against the allow-list
+// in `jws.RemoteKeyPolicy`. For "x5u", the resolver should return a
+// set containing the key of the first certificate in the chain.
+type RemoteKeyResolver func(ctx context.Context, name, u string) (jwk.Set, error)
+
+// RemoteKeyPolicy controls how the "jku" and "x5u" headers, which refer
+// to keys hosted at remote URLs, are handled during verification.
+// Pass it to `jws.Verify()` using `jws.WithRemoteKeyPolicy()`.
+//
+// Trusting these headers blindly allows attackers to make the verifier
+// fetch arbitrary URLs (SSRF), or to substitute their own keys.
+// Without the option, the headers are ignored, and messages are only
+// verified with the key passed to `jws.Verify()`.
+type RemoteKeyPolicy struct {
+	// AllowedURLs lists the URLs that "jku" and "x5u" headers may refer
+	// to. Entries that end with a "/" match all URLs under that path,
+	// otherwise URLs must match exactly. If the list is empty, messages
+	// that contain either header are rejected.
+	AllowedURLs []string
+
+	// Resolver, if specified, fetches the keys referred to by the
+	// headers. The message is then verified with the key from the
+	// resolved set whose "kid" matches that of the message (or the only
+	// key in the set, if the message does not have a "kid"), and the key
+	// passed to `jws.Verify()` is only used for messages without either
+	// header. Otherwise, the key passed to `jws.Verify()` is always used.
+	Resolver RemoteKeyResolver
+}
+
+type remoteKeyCheck struct {
+	ctx    context.Context
+	policy *RemoteKeyPolicy
+}
+
+// allowed reports whether u matches one of the allowed URLs
+func (p *RemoteKeyPolicy) allowed(u string) bool {
+	candidate, err := url.Parse(u)
+	if err != nil || candidate.User != nil || candidate.Fragment != "" || candidate.Opaque != "" {
+		return false
+	}
+
+	for _, pattern := range p.AllowedURLs {
+		if !strings.HasSuffix(pattern, "/") {
+			if u == pattern {
+				return true
+			}
+			continue
+		}
+
+		allowed, err := url.Parse(pattern)
+		if err != nil {
+			continue
+		}
+		if !strings.EqualFold(candidate.Scheme, allowed.Scheme) || !strings.EqualFold(candidate.Host, allowed.Host) {
+			continue
+		}
+		// path.Clean removes "..", so that the URL cannot escape the
+		// allowed path
+		if cleaned := path.Clean("/" + candidate.Path); strings.HasPrefix(cleaned+"/", allowed.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteKeyHeader returns the name and the value of the first remote
+// key header found in the headers
+func remoteKeyHeader(headers ...Headers) (string, string) {
+	for _, h := range headers {
+		if h == nil {
+			continue
+		}
+		if v := h.JWKSetURL(); v != "" {
+			return JWKSetURLKey, v
+		}
+		if v := h.X509URL(); v != "" {
+			return X509URLKey, v
+		}
+	}
+	return "", ""
+}
+
+// resolve checks the remote key headers against the policy, and returns
+// the key that the signature should be verified with
+func (c *remoteKeyCheck) resolve(key interface{}, protected, public Headers) (interface{}, error) {
+	name, u := remoteKeyHeader(protected, public)
+	if name == "" {
+		return key, nil
+	}
+
+	if !c.policy.allowed(u) {
+		return nil, errors.Errorf(`%q header %q is not allowed`, name, u)
+	}
+
+	if c.policy.Resolver == nil {
+		return key, nil
+	}
+
+	set, err := c.policy.Resolver(c.ctx, name, u)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to resolve keys from %q header`, name)
+	}
+
+	var kid string
+	if protected != nil {
+		kid = protected.KeyID()
+	}
+	if kid == "" && public != nil {
+		kid = public.KeyID()
+	}
+
+	if kid != "" {
+		k, ok := set.LookupKeyID(kid)
+		if !ok {
+			return nil, errors.Errorf(`key %q not found in keys resolved from %q header`, kid, name)
+		}
+		return k, nil
+	}
+
+	if set.Len() != 1 {
+		return nil, errors.Errorf(`message has no "kid", and keys resolved from %q header contain %d keys`, name, set.Len())
+	}
+	k, _ := set.Get(0)
+	return k, nil
+}
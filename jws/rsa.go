@@ -7,53 +7,29 @@ import (
 
 	"github.com/lestrrat-go/jwx/internal/keyconv"
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws/rsaprim"
 	"github.com/pkg/errors"
 )
 
 var rsaSignFuncs = map[jwa.SignatureAlgorithm]rsaSignFunc{}
 var rsaVerifyFuncs = map[jwa.SignatureAlgorithm]rsaVerifyFunc{}
+var rsaSignerOpts = map[jwa.SignatureAlgorithm]crypto.SignerOpts{}
 
 func init() {
-	algs := map[jwa.SignatureAlgorithm]struct {
-		SignFunc   func(crypto.Hash) rsaSignFunc
-		VerifyFunc func(crypto.Hash) rsaVerifyFunc
-		Hash       crypto.Hash
-	}{
-		jwa.RS256: {
-			Hash:       crypto.SHA256,
-			SignFunc:   makeSignPKCS1v15,
-			VerifyFunc: makeVerifyPKCS1v15,
-		},
-		jwa.RS384: {
-			Hash:       crypto.SHA384,
-			SignFunc:   makeSignPKCS1v15,
-			VerifyFunc: makeVerifyPKCS1v15,
-		},
-		jwa.RS512: {
-			Hash:       crypto.SHA512,
-			SignFunc:   makeSignPKCS1v15,
-			VerifyFunc: makeVerifyPKCS1v15,
-		},
-		jwa.PS256: {
-			Hash:       crypto.SHA256,
-			SignFunc:   makeSignPSS,
-			VerifyFunc: makeVerifyPSS,
-		},
-		jwa.PS384: {
-			Hash:       crypto.SHA384,
-			SignFunc:   makeSignPSS,
-			VerifyFunc: makeVerifyPSS,
-		},
-		jwa.PS512: {
-			Hash:       crypto.SHA512,
-			SignFunc:   makeSignPSS,
-			VerifyFunc: makeVerifyPSS,
-		},
-	}
-
-	for alg, item := range algs {
-		rsaSignFuncs[alg] = item.SignFunc(item.Hash)
-		rsaVerifyFuncs[alg] = item.VerifyFunc(item.Hash)
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512} {
+		// The parameters of each algorithm are shared with extensions
+		// through the rsaprim package, so these cannot fail
+		hash, _ := rsaprim.Hash(alg)
+		opts, _ := rsaprim.SignerOpts(alg)
+
+		if rsaprim.IsPSS(alg) {
+			rsaSignFuncs[alg] = makeSignPSS(hash)
+			rsaVerifyFuncs[alg] = makeVerifyPSS(hash)
+		} else {
+			rsaSignFuncs[alg] = makeSignPKCS1v15(hash)
+			rsaVerifyFuncs[alg] = makeVerifyPKCS1v15(hash)
+		}
+		rsaSignerOpts[alg] = opts
 	}
 }
 
@@ -91,12 +67,19 @@ func (s RSASigner) Algorithm() jwa.SignatureAlgorithm {
 }
 
 // Sign creates a signature using crypto/rsa. key must be a non-nil instance of
-// `*"crypto/rsa".PrivateKey`.
+// `*"crypto/rsa".PrivateKey`, or a crypto.Signer whose public key is
+// a `*"crypto/rsa".PublicKey` (e.g. a key stored in a KMS or an HSM)
 func (s RSASigner) Sign(payload []byte, key interface{}) ([]byte, error) {
 	if key == nil {
 		return nil, errors.New(`missing private key while signing payload`)
 	}
 
+	if signer, ok := opaqueSigner(key); ok {
+		if _, ok := signer.Public().(*rsa.PublicKey); ok {
+			return signWithCryptoSigner(signer, payload, rsaSignerOpts[s.alg])
+		}
+	}
+
 	var privkey rsa.PrivateKey
 	if err := keyconv.RSAPrivateKey(&privkey, key); err != nil {
 		return nil, errors.Wrapf(err, `failed to retrieve rsa.PrivateKey out of %T`, key)
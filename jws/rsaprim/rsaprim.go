@@ -0,0 +1,318 @@
+// Package rsaprim exposes the RSA signature encoding primitives used by
+// the RS* and PS* signers in the jws package, so that extensions which
+// need to perform the RSA operation separately from the encoding, such
+// as RSA blind signatures (RFC 9474) or signers backed by hardware that
+// only offers raw RSA, can produce signatures that verify as regular
+// JWS signatures without duplicating the signer code.
+//
+// The functions in this package follow RFC 8017 and only perform the
+// encoding steps: they do not hash payloads (except for `Digest()`),
+// nor perform any operation with the private key. The API of this
+// package is kept stable within a major version, but it is low-level:
+// most users should use `jws.Sign()` and `jws.Verify()` instead.
+package rsaprim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"hash"
+	"io"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// digestInfoPrefixes are the DER encoded DigestInfo values, without the
+// digest itself, used by EMSA-PKCS1-v1_5 (RFC 8017, section 9.2)
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+var algorithms = map[jwa.SignatureAlgorithm]struct {
+	hash crypto.Hash
+	pss  bool
+}{
+	jwa.RS256: {hash: crypto.SHA256},
+	jwa.RS384: {hash: crypto.SHA384},
+	jwa.RS512: {hash: crypto.SHA512},
+	jwa.PS256: {hash: crypto.SHA256, pss: true},
+	jwa.PS384: {hash: crypto.SHA384, pss: true},
+	jwa.PS512: {hash: crypto.SHA512, pss: true},
+}
+
+// Hash returns the hash function used by the RS* or PS* algorithm `alg`
+func Hash(alg jwa.SignatureAlgorithm) (crypto.Hash, error) {
+	v, ok := algorithms[alg]
+	if !ok {
+		return 0, errors.Errorf(`unsupported RSA signature algorithm %q`, alg)
+	}
+	return v.hash, nil
+}
+
+// IsPSS returns true if `alg` is one of the PS* algorithms, which use
+// EMSA-PSS encoding. The RS* algorithms use EMSA-PKCS1-v1_5 encoding
+func IsPSS(alg jwa.SignatureAlgorithm) bool {
+	return algorithms[alg].pss
+}
+
+// SignerOpts returns the options to pass to `(crypto.Signer).Sign()`
+// to create a signature for `alg`. For the PS* algorithms the salt
+// length is equal to the length of the hash, as used by the jws package
+func SignerOpts(alg jwa.SignatureAlgorithm) (crypto.SignerOpts, error) {
+	h, err := Hash(alg)
+	if err != nil {
+		return nil, err
+	}
+	if IsPSS(alg) {
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: h}, nil
+	}
+	return h, nil
+}
+
+// Digest computes the hash of `payload` (usually the JWS signing input)
+// using the hash function of `alg`
+func Digest(alg jwa.SignatureAlgorithm, payload []byte) ([]byte, error) {
+	h, err := Hash(alg)
+	if err != nil {
+		return nil, err
+	}
+	hh := h.New()
+	if _, err := hh.Write(payload); err != nil {
+		return nil, errors.Wrap(err, `failed to write payload`)
+	}
+	return hh.Sum(nil), nil
+}
+
+// DigestInfo returns the DER encoded DigestInfo structure for `digest`,
+// as used by EMSA-PKCS1-v1_5 (RFC 8017, section 9.2 step 2). This is
+// the input expected by devices that perform PKCS#1 v1.5 padding
+// themselves, such as PKCS#11 tokens using CKM_RSA_PKCS
+func DigestInfo(h crypto.Hash, digest []byte) ([]byte, error) {
+	prefix, ok := digestInfoPrefixes[h]
+	if !ok {
+		return nil, errors.Errorf(`unsupported hash function %s`, h)
+	}
+	if len(digest) != h.Size() {
+		return nil, errors.Errorf(`invalid digest length: expected %d, got %d`, h.Size(), len(digest))
+	}
+
+	buf := make([]byte, 0, len(prefix)+len(digest))
+	buf = append(buf, prefix...)
+	buf = append(buf, digest...)
+	return buf, nil
+}
+
+// EncodePKCS1v15 returns the EMSA-PKCS1-v1_5 encoding of `digest`
+// (RFC 8017, section 9.2) for the key `key`. The result has the same
+// length as the modulus, and the signature is obtained by applying
+// the raw RSA private key operation to it
+func EncodePKCS1v15(key *rsa.PublicKey, h crypto.Hash, digest []byte) ([]byte, error) {
+	if key == nil || key.N == nil {
+		return nil, errors.New(`missing public key`)
+	}
+
+	t, err := DigestInfo(h, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	emLen := key.Size()
+	if emLen < len(t)+11 {
+		return nil, errors.New(`key is too short for the digest`)
+	}
+
+	// EM = 0x00 || 0x01 || PS || 0x00 || T
+	em := make([]byte, emLen)
+	em[1] = 0x01
+	for i := 2; i < emLen-len(t)-1; i++ {
+		em[i] = 0xff
+	}
+	copy(em[emLen-len(t):], t)
+	return em, nil
+}
+
+// EncodePSS returns the EMSA-PSS encoding of `digest` (RFC 8017,
+// section 9.1.1) for the key `key`, using MGF1 with the same hash
+// function. The signature is obtained by applying the raw RSA private
+// key operation to the result.
+//
+// `saltLength` is the length of the salt in bytes, which is read from
+// `rnd` (`crypto/rand.Reader` if nil). Unlike `rsa.PSSOptions`, 0 means
+// that no salt is used, as in the deterministic variants of RFC 9474.
+// Use `rsa.PSSSaltLengthEqualsHash` for the salt length used by the
+// PS* algorithms.
+func EncodePSS(rnd io.Reader, key *rsa.PublicKey, h crypto.Hash, digest []byte, saltLength int) ([]byte, error) {
+	if key == nil || key.N == nil {
+		return nil, errors.New(`missing public key`)
+	}
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+
+	saltLength, err := pssSaltLength(h, saltLength)
+	if err != nil {
+		return nil, err
+	}
+	if len(digest) != h.Size() {
+		return nil, errors.Errorf(`invalid digest length: expected %d, got %d`, h.Size(), len(digest))
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := io.ReadFull(rnd, salt); err != nil {
+		return nil, errors.Wrap(err, `failed to generate salt`)
+	}
+
+	emBits := key.N.BitLen() - 1
+	emLen := (emBits + 7) / 8
+	hLen := h.Size()
+	if emLen < hLen+saltLength+2 {
+		return nil, errors.New(`key is too short for the digest and salt`)
+	}
+
+	em := make([]byte, emLen)
+	psLen := emLen - saltLength - hLen - 2
+	db := em[:psLen+1+saltLength]
+	mHash := em[psLen+1+saltLength : emLen-1]
+
+	// H = Hash(0x00 x 8 || mHash || salt)
+	hh := h.New()
+	var prefix [8]byte
+	hh.Write(prefix[:])
+	hh.Write(digest)
+	hh.Write(salt)
+	mHash = hh.Sum(mHash[:0])
+	hh.Reset()
+
+	// DB = PS || 0x01 || salt, masked with MGF1(H)
+	db[psLen] = 0x01
+	copy(db[psLen+1:], salt)
+	mgf1XOR(db, hh, mHash)
+	db[0] &= 0xff >> uint(8*emLen-emBits)
+
+	em[emLen-1] = 0xbc
+	return em, nil
+}
+
+// VerifyPSS checks that `em` is a valid EMSA-PSS encoding of `digest`
+// (RFC 8017, section 9.1.2) for the key `key`. `em` is the result of
+// applying the raw RSA public key operation to a signature, and may
+// include a leading zero byte. `saltLength` is interpreted as in
+// `EncodePSS()`
+func VerifyPSS(key *rsa.PublicKey, h crypto.Hash, digest, em []byte, saltLength int) error {
+	if key == nil || key.N == nil {
+		return errors.New(`missing public key`)
+	}
+
+	saltLength, err := pssSaltLength(h, saltLength)
+	if err != nil {
+		return err
+	}
+	hLen := h.Size()
+	if len(digest) != hLen {
+		return errors.Errorf(`invalid digest length: expected %d, got %d`, hLen, len(digest))
+	}
+
+	emBits := key.N.BitLen() - 1
+	emLen := (emBits + 7) / 8
+	if len(em) == emLen+1 && em[0] == 0 {
+		em = em[1:]
+	}
+	if len(em) != emLen || emLen < hLen+saltLength+2 || em[emLen-1] != 0xbc {
+		return errors.New(`invalid PSS encoding`)
+	}
+
+	bitMask := byte(0xff >> uint(8*emLen-emBits))
+	if em[0]&^bitMask != 0 {
+		return errors.New(`invalid PSS encoding`)
+	}
+
+	db := make([]byte, emLen-hLen-1)
+	copy(db, em)
+	mHash := em[emLen-hLen-1 : emLen-1]
+
+	hh := h.New()
+	mgf1XOR(db, hh, mHash)
+	db[0] &= bitMask
+
+	psLen := emLen - hLen - saltLength - 2
+	for _, b := range db[:psLen] {
+		if b != 0 {
+			return errors.New(`invalid PSS encoding`)
+		}
+	}
+	if db[psLen] != 0x01 {
+		return errors.New(`invalid PSS encoding`)
+	}
+	salt := db[len(db)-saltLength:]
+
+	var prefix [8]byte
+	hh.Write(prefix[:])
+	hh.Write(digest)
+	hh.Write(salt)
+	if subtle.ConstantTimeCompare(hh.Sum(nil), mHash) != 1 {
+		return errors.New(`PSS verification failed`)
+	}
+	return nil
+}
+
+// VerifyPKCS1v15 checks that `em` is the EMSA-PKCS1-v1_5 encoding of
+// `digest` for the key `key`. `em` is the result of applying the raw
+// RSA public key operation to a signature
+func VerifyPKCS1v15(key *rsa.PublicKey, h crypto.Hash, digest, em []byte) error {
+	expected, err := EncodePKCS1v15(key, h, digest)
+	if err != nil {
+		return err
+	}
+	if len(em) < len(expected) {
+		em = append(make([]byte, len(expected)-len(em)), em...)
+	}
+	if !bytes.Equal(em, expected) {
+		return errors.New(`PKCS1v15 verification failed`)
+	}
+	return nil
+}
+
+func pssSaltLength(h crypto.Hash, saltLength int) (int, error) {
+	if !h.Available() {
+		return 0, errors.Errorf(`hash function %s is not available`, h)
+	}
+	switch {
+	case saltLength == rsa.PSSSaltLengthEqualsHash:
+		return h.Size(), nil
+	case saltLength < 0:
+		return 0, errors.Errorf(`invalid salt length %d`, saltLength)
+	default:
+		return saltLength, nil
+	}
+}
+
+// mgf1XOR XORs `out` with the output of MGF1 (RFC 8017, appendix B.2.1)
+// applied to `seed`
+func mgf1XOR(out []byte, hh hash.Hash, seed []byte) {
+	var counter [4]byte
+	var digest []byte
+
+	for done := 0; done < len(out); {
+		hh.Write(seed)
+		hh.Write(counter[:])
+		digest = hh.Sum(digest[:0])
+		hh.Reset()
+
+		for i := 0; i < len(digest) && done < len(out); i++ {
+			out[done] ^= digest[i]
+			done++
+		}
+
+		for i := len(counter) - 1; i >= 0; i-- {
+			counter[i]++
+			if counter[i] != 0 {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package rsaprim_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jws/rsaprim"
+	"github.com/stretchr/testify/assert"
+)
+
+// blindSign signs the encoded message `em` the way an RSA blind signature
+// protocol would: the client blinds the message, the signer applies the
+// raw private key operation, and the client unblinds the result
+func blindSign(t *testing.T, key *rsa.PrivateKey, em []byte) []byte {
+	t.Helper()
+
+	n := key.N
+	e := big.NewInt(int64(key.E))
+
+	var r *big.Int
+	for {
+		v, err := rand.Int(rand.Reader, n)
+		if !assert.NoError(t, err, `rand.Int should succeed`) {
+			return nil
+		}
+		if v.Sign() > 0 && new(big.Int).GCD(nil, nil, v, n).Cmp(big.NewInt(1)) == 0 {
+			r = v
+			break
+		}
+	}
+
+	// client: blinded = m * r^e mod n
+	m := new(big.Int).SetBytes(em)
+	blinded := new(big.Int).Mul(m, new(big.Int).Exp(r, e, n))
+	blinded.Mod(blinded, n)
+
+	// signer: raw RSA private key operation
+	blindSig := new(big.Int).Exp(blinded, key.D, n)
+
+	// client: sig = blindSig * r^-1 mod n
+	sig := new(big.Int).Mul(blindSig, new(big.Int).ModInverse(r, n))
+	sig.Mod(sig, n)
+
+	buf := make([]byte, key.Size())
+	sig.FillBytes(buf)
+	return buf
+}
+
+func rawPublic(key *rsa.PublicKey, sig []byte) []byte {
+	m := new(big.Int).Exp(new(big.Int).SetBytes(sig), big.NewInt(int64(key.E)), key.N)
+	return m.Bytes()
+}
+
+func TestRSAPrimitives(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512} {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			t.Parallel()
+
+			hdr := base64.EncodeToString([]byte(`{"alg":"` + alg.String() + `"}`))
+			payload := base64.EncodeToString([]byte(`Lorem ipsum`))
+			input := hdr + `.` + payload
+
+			h, err := rsaprim.Hash(alg)
+			if !assert.NoError(t, err, `rsaprim.Hash should succeed`) {
+				return
+			}
+			digest, err := rsaprim.Digest(alg, []byte(input))
+			if !assert.NoError(t, err, `rsaprim.Digest should succeed`) {
+				return
+			}
+
+			var em []byte
+			if rsaprim.IsPSS(alg) {
+				em, err = rsaprim.EncodePSS(nil, &key.PublicKey, h, digest, rsa.PSSSaltLengthEqualsHash)
+			} else {
+				em, err = rsaprim.EncodePKCS1v15(&key.PublicKey, h, digest)
+			}
+			if !assert.NoError(t, err, `encoding should succeed`) {
+				return
+			}
+
+			sig := blindSign(t, key, em)
+			signed := input + `.` + base64.EncodeToString(sig)
+			if _, err := jws.Verify([]byte(signed), alg, &key.PublicKey); !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+
+			// and the other way around: signatures created by jws.Sign
+			// can be checked with the primitives
+			signed2, err := jws.Sign([]byte(`Lorem ipsum`), alg, key)
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+			_, _, sig2, err := jws.SplitCompact(signed2)
+			if !assert.NoError(t, err, `jws.SplitCompact should succeed`) {
+				return
+			}
+			decoded, err := base64.Decode(sig2)
+			if !assert.NoError(t, err, `base64.Decode should succeed`) {
+				return
+			}
+			digest2, _ := rsaprim.Digest(alg, signed2[:len(signed2)-len(sig2)-1])
+			em2 := rawPublic(&key.PublicKey, decoded)
+			if rsaprim.IsPSS(alg) {
+				err = rsaprim.VerifyPSS(&key.PublicKey, h, digest2, em2, rsa.PSSSaltLengthEqualsHash)
+			} else {
+				err = rsaprim.VerifyPKCS1v15(&key.PublicKey, h, digest2, em2)
+			}
+			if !assert.NoError(t, err, `verification of the encoding should succeed`) {
+				return
+			}
+			other, _ := rsaprim.Digest(alg, []byte(`other`))
+			if rsaprim.IsPSS(alg) {
+				err = rsaprim.VerifyPSS(&key.PublicKey, h, other, em2, rsa.PSSSaltLengthEqualsHash)
+			} else {
+				err = rsaprim.VerifyPKCS1v15(&key.PublicKey, h, other, em2)
+			}
+			if !assert.Error(t, err, `verification of the encoding should fail for a different digest`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Deterministic PSS", func(t *testing.T) {
+		t.Parallel()
+		digest := make([]byte, crypto.SHA384.Size())
+		em1, err := rsaprim.EncodePSS(nil, &key.PublicKey, crypto.SHA384, digest, 0)
+		if !assert.NoError(t, err, `rsaprim.EncodePSS should succeed`) {
+			return
+		}
+		em2, err := rsaprim.EncodePSS(nil, &key.PublicKey, crypto.SHA384, digest, 0)
+		if !assert.NoError(t, err, `rsaprim.EncodePSS should succeed`) {
+			return
+		}
+		if !assert.Equal(t, em1, em2, `encoding without salt should be deterministic`) {
+			return
+		}
+		if !assert.NoError(t, rsaprim.VerifyPSS(&key.PublicKey, crypto.SHA384, digest, em1, 0), `rsaprim.VerifyPSS should succeed`) {
+			return
+		}
+	})
+	t.Run("DigestInfo", func(t *testing.T) {
+		t.Parallel()
+		if _, err := rsaprim.DigestInfo(crypto.SHA256, []byte(`short`)); !assert.Error(t, err, `rsaprim.DigestInfo should fail for invalid digest`) {
+			return
+		}
+		if _, err := rsaprim.Hash(jwa.ES256); !assert.Error(t, err, `rsaprim.Hash should fail for non-RSA algorithms`) {
+			return
+		}
+	})
+}
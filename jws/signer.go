@@ -1,6 +1,15 @@
 package jws
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+
+	"github.com/lestrrat-go/jwx/internal/fips"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
 )
@@ -14,7 +23,7 @@ func (fn SignerFactoryFn) Create() (Signer, error) {
 	return fn()
 }
 
-var signerDB map[jwa.SignatureAlgorithm]SignerFactory
+var signerDB = make(map[jwa.SignatureAlgorithm]SignerFactory)
 
 // RegisterSigner is used to register a factory object that creates
 // Signer objects based on the given algorithm.
@@ -27,8 +36,6 @@ func RegisterSigner(alg jwa.SignatureAlgorithm, f SignerFactory) {
 }
 
 func init() {
-	signerDB = make(map[jwa.SignatureAlgorithm]SignerFactory)
-
 	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512} {
 		RegisterSigner(alg, func(alg jwa.SignatureAlgorithm) SignerFactory {
 			return SignerFactoryFn(func() (Signer, error) {
@@ -60,9 +67,72 @@ func init() {
 
 // NewSigner creates a signer that signs payloads using the given signature algorithm.
 func NewSigner(alg jwa.SignatureAlgorithm) (Signer, error) {
+	if err := fips.CheckSignatureAlgorithm(alg); err != nil {
+		return nil, err
+	}
 	f, ok := signerDB[alg]
 	if ok {
 		return f.Create()
 	}
 	return nil, errors.Errorf(`unsupported signature algorithm "%s"`, alg)
 }
+
+// opaqueSigner returns the crypto.Signer in key, if key is a
+// crypto.Signer whose private key material is not directly available
+// to us (e.g. keys stored in a KMS or an HSM). Private keys from the
+// standard library are excluded so that they keep using the
+// regular code paths.
+func opaqueSigner(key interface{}) (crypto.Signer, bool) {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return nil, false
+	}
+
+	signer, ok := key.(crypto.Signer)
+	return signer, ok
+}
+
+// signWithCryptoSigner hashes payload using the hash function specified
+// in opts, and signs the digest using signer
+func signWithCryptoSigner(signer crypto.Signer, payload []byte, opts crypto.SignerOpts) ([]byte, error) {
+	h := opts.HashFunc().New()
+	if _, err := h.Write(payload); err != nil {
+		return nil, errors.Wrap(err, `failed to write payload to hash`)
+	}
+
+	signature, err := signer.Sign(rand.Reader, h.Sum(nil), opts)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign payload using crypto.Signer`)
+	}
+	return signature, nil
+}
+
+// ContextSigner is a crypto.Signer that can also honor a context.Context,
+// such as a signer backed by a remote KMS. When such a key is passed to
+// `jws.SignContext()` (or `jwt.SignContext()`), SignContext is called with
+// the given context instead of Sign, so that deadlines and cancellation
+// are propagated to the remote signer.
+type ContextSigner interface {
+	crypto.Signer
+	SignContext(context.Context, io.Reader, []byte, crypto.SignerOpts) ([]byte, error)
+}
+
+// contextBoundSigner adapts a ContextSigner to a crypto.Signer, by
+// binding it to a particular context
+type contextBoundSigner struct {
+	ContextSigner
+	ctx context.Context
+}
+
+func (s *contextBoundSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(s.ctx, rand, digest, opts)
+}
+
+// bindSignerContext binds ctx to key, if key is a ContextSigner.
+// Otherwise key is returned as is
+func bindSignerContext(ctx context.Context, key interface{}) interface{} {
+	if signer, ok := key.(ContextSigner); ok {
+		return &contextBoundSigner{ContextSigner: signer, ctx: ctx}
+	}
+	return key
+}
@@ -1,12 +1,17 @@
 package jws_test
 
 import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
 
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -99,3 +104,205 @@ func TestSignMulti(t *testing.T) {
 
 	t.Logf("%s", m)
 }
+
+// opaqueSigner hides the concrete private key type, so that the
+// key can only be used through the crypto.Signer interface
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestSignCryptoSigner(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Lorem ipsum")
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, "RSA key generated") {
+		return
+	}
+	edkey, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, "Ed25519 key generated") {
+		return
+	}
+
+	testcases := []struct {
+		Algorithm jwa.SignatureAlgorithm
+		Signer    crypto.Signer
+	}{
+		{Algorithm: jwa.RS256, Signer: rsakey},
+		{Algorithm: jwa.RS512, Signer: rsakey},
+		{Algorithm: jwa.PS256, Signer: rsakey},
+		{Algorithm: jwa.PS384, Signer: rsakey},
+		{Algorithm: jwa.EdDSA, Signer: edkey},
+	}
+	for _, crv := range []struct {
+		Algorithm jwa.SignatureAlgorithm
+		Curve     jwa.EllipticCurveAlgorithm
+	}{
+		{Algorithm: jwa.ES256, Curve: jwa.P256},
+		{Algorithm: jwa.ES384, Curve: jwa.P384},
+		{Algorithm: jwa.ES512, Curve: jwa.P521},
+	} {
+		eckey, err := jwxtest.GenerateEcdsaKey(crv.Curve)
+		if !assert.NoError(t, err, "ECDSA key generated") {
+			return
+		}
+		testcases = append(testcases, struct {
+			Algorithm jwa.SignatureAlgorithm
+			Signer    crypto.Signer
+		}{Algorithm: crv.Algorithm, Signer: eckey})
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Algorithm.String(), func(t *testing.T) {
+			t.Parallel()
+			signed, err := jws.Sign(payload, tc.Algorithm, opaqueSigner{signer: tc.Signer})
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+
+			verified, err := jws.Verify(signed, tc.Algorithm, tc.Signer.Public())
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payloads should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("mismatched key type", func(t *testing.T) {
+		t.Parallel()
+		_, err := jws.Sign(payload, jwa.ES256, opaqueSigner{signer: rsakey})
+		if !assert.Error(t, err, `jws.Sign should fail`) {
+			return
+		}
+	})
+}
+
+type ctxKey struct{}
+
+// contextSigner records the value stored in the context passed to
+// SignContext
+type contextSigner struct {
+	opaqueSigner
+	seen *interface{}
+}
+
+func (s contextSigner) SignContext(ctx context.Context, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	*s.seen = ctx.Value(ctxKey{})
+	return s.Sign(rand, digest, opts)
+}
+
+func TestSignContext(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Lorem ipsum")
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, "RSA key generated") {
+		return
+	}
+
+	t.Run("ContextSigner", func(t *testing.T) {
+		t.Parallel()
+		var seen interface{}
+		signer := contextSigner{opaqueSigner: opaqueSigner{signer: rsakey}, seen: &seen}
+
+		ctx := context.WithValue(context.Background(), ctxKey{}, "foo")
+		signed, err := jws.SignContext(ctx, payload, jwa.RS256, signer)
+		if !assert.NoError(t, err, `jws.SignContext should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "foo", seen, `SignContext should receive the context`) {
+			return
+		}
+
+		verified, err := jws.VerifyContext(ctx, signed, jwa.RS256, &rsakey.PublicKey)
+		if !assert.NoError(t, err, `jws.VerifyContext should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, verified, `payloads should match`) {
+			return
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		t.Parallel()
+		signed, err := jws.Sign(payload, jwa.RS256, rsakey)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = jws.SignContext(ctx, payload, jwa.RS256, rsakey)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jws.SignContext should fail with context.Canceled`) {
+			return
+		}
+		_, err = jws.VerifyContext(ctx, signed, jwa.RS256, &rsakey.PublicKey)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jws.VerifyContext should fail with context.Canceled`) {
+			return
+		}
+	})
+}
+
+func TestKeyProviderForSigning(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Lorem ipsum")
+	keys := make([][]byte, 3)
+	for i := range keys {
+		keys[i] = jwxtest.GenerateSymmetricKey()
+	}
+
+	var current int
+	provider := jws.SigningKeyProvider(func(ctx context.Context) (jwa.SignatureAlgorithm, interface{}, string, error) {
+		if v, ok := ctx.Value(ctxKey{}).(string); ok && v == "fail" {
+			return "", nil, "", errors.New(`key is not available`)
+		}
+		return jwa.HS256, keys[current], fmt.Sprintf(`key-%d`, current), nil
+	})
+
+	hdrs := jws.NewHeaders()
+	_ = hdrs.Set(`x-custom`, `foo`)
+	for i := range keys {
+		current = i
+		signed, err := jws.Sign(payload, "", nil, jws.WithHeaders(hdrs), jws.WithKeyProviderForSigning(provider))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		if _, err := jws.Verify(signed, jwa.HS256, keys[i]); !assert.NoError(t, err, `jws.Verify should succeed with the current key`) {
+			return
+		}
+
+		m, err := jws.Parse(signed)
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		protected := m.Signatures()[0].ProtectedHeaders()
+		if !assert.Equal(t, fmt.Sprintf(`key-%d`, i), protected.KeyID(), `"kid" should match`) {
+			return
+		}
+		if v, _ := protected.Get(`x-custom`); !assert.Equal(t, `foo`, v, `other headers should be kept`) {
+			return
+		}
+	}
+
+	if !assert.Empty(t, hdrs.KeyID(), `headers passed by the user should not be modified`) {
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "fail")
+	if _, err := jws.SignContext(ctx, payload, "", nil, jws.WithKeyProviderForSigning(provider)); !assert.Error(t, err, `jws.SignContext should fail`) {
+		return
+	}
+}
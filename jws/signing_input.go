@@ -0,0 +1,73 @@
+package jws
+
+import (
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// SigningInput computes the JWS Signing Input for the given protected
+// headers and payload, i.e. the value that is fed to the signature
+// algorithm: BASE64URL(UTF8(JWS Protected Header)) || '.' || BASE64URL(JWS Payload)
+//
+// If `b64` is false, the payload is used as is, without base64 encoding,
+// as described in RFC 7797. Note that in this case it is the caller's
+// responsibility to include the "b64" and "crit" header parameters in
+// the protected headers.
+//
+// This function, along with `jws.VerifyDetachedSignature()`, is meant for
+// protocols that transport the signing input out-of-band, and only need
+// the signature algorithms. For regular JWS messages, use `jws.Sign()`
+// and `jws.Verify()`
+func SigningInput(protectedHeaders Headers, payload []byte, b64 bool) ([]byte, error) {
+	if protectedHeaders == nil {
+		return nil, errors.New(`protected headers must not be nil`)
+	}
+
+	hdrbuf, err := json.Marshal(protectedHeaders)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal protected headers`)
+	}
+
+	encodedHeaders := base64.Encode(hdrbuf)
+
+	var encodedPayload []byte
+	if b64 {
+		encodedPayload = base64.Encode(payload)
+	} else {
+		encodedPayload = payload
+	}
+
+	input := make([]byte, 0, len(encodedHeaders)+1+len(encodedPayload))
+	input = append(input, encodedHeaders...)
+	input = append(input, '.')
+	input = append(input, encodedPayload...)
+	return input, nil
+}
+
+// VerifyDetachedSignature verifies that `signature` is a valid signature
+// over `input` using the given algorithm and key. `signature` is the raw
+// signature, not its base64 encoded form.
+//
+// `input` is typically computed by `jws.SigningInput()`, but this function
+// does not interpret it in any way. `key` may be a "raw" key (e.g. rsa.PublicKey)
+// or a jwk.Key
+//
+// The returned error matches `jwx.ErrVerification` via `errors.Is()`
+// if the signature could not be verified.
+func VerifyDetachedSignature(input, signature []byte, alg jwa.SignatureAlgorithm, key interface{}) error {
+	verifier, err := NewVerifier(alg)
+	if err != nil {
+		return errors.Wrap(err, `failed to create verifier`)
+	}
+
+	if err := checkKeySeparation(key); err != nil {
+		return errors.Wrap(err, `failed to verify signature`)
+	}
+
+	if err := verifier.Verify(input, signature, key); err != nil {
+		return newVerificationError(errors.Wrap(err, `failed to verify signature`))
+	}
+	return nil
+}
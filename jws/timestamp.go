@@ -0,0 +1,110 @@
+package jws
+
+import (
+	"context"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/pkg/errors"
+)
+
+// TimestampTokenKey is the name of the unprotected header field that
+// holds the timestamp token obtained via `jws.WithTimestamper()`. The
+// value is the base64url encoded token. This field is not registered
+// with IANA, so other implementations will ignore it.
+const TimestampTokenKey = "tst"
+
+// Timestamper obtains a timestamp token for a signature, such as an
+// RFC3161 TimeStampToken from a Time-Stamping Authority. It is given
+// the raw signature bytes, and is responsible for computing the
+// message imprint, and for communicating with the TSA.
+//
+// This package does not interpret the token in any way.
+type Timestamper interface {
+	Timestamp(ctx context.Context, signature []byte) ([]byte, error)
+}
+
+// TimestamperFunc is a Timestamper represented as a function
+type TimestamperFunc func(context.Context, []byte) ([]byte, error)
+
+func (f TimestamperFunc) Timestamp(ctx context.Context, signature []byte) ([]byte, error) {
+	return f(ctx, signature)
+}
+
+// TimestampVerifier verifies the timestamp token attached to a
+// signature, such as by checking that the RFC3161 TimeStampToken is
+// signed by a trusted TSA, that its message imprint matches the
+// signature, and that the time it asserts is acceptable.
+type TimestampVerifier interface {
+	VerifyTimestamp(ctx context.Context, signature, token []byte) error
+}
+
+// TimestampVerifierFunc is a TimestampVerifier represented as a function
+type TimestampVerifierFunc func(context.Context, []byte, []byte) error
+
+func (f TimestampVerifierFunc) VerifyTimestamp(ctx context.Context, signature, token []byte) error {
+	return f(ctx, signature, token)
+}
+
+// TimestampToken returns the decoded timestamp token stored in the
+// unprotected headers of the signature. The second return value is
+// false if the signature does not have a timestamp token.
+//
+// Note that the timestamp token is not covered by the signature. Use
+// `jws.WithTimestampVerifier()` to verify it along with the signature.
+func (s *Signature) TimestampToken() ([]byte, bool) {
+	if s.headers == nil {
+		return nil, false
+	}
+	v, ok := s.headers.Get(TimestampTokenKey)
+	if !ok {
+		return nil, false
+	}
+	encoded, ok := v.(string)
+	if !ok {
+		return nil, false
+	}
+	token, err := base64.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return token, true
+}
+
+// timestampSignature attaches a timestamp token for the signature to
+// the unprotected headers of `sig`
+func timestampSignature(ctx context.Context, ts Timestamper, sig *Signature) error {
+	token, err := ts.Timestamp(ctx, sig.signature)
+	if err != nil {
+		return errors.Wrap(err, `failed to obtain timestamp token`)
+	}
+
+	// Do not modify the headers passed by the user, as they
+	// may be shared between calls
+	h := NewHeaders()
+	if sig.headers != nil {
+		if err := sig.headers.Copy(ctx, h); err != nil {
+			return errors.Wrap(err, `failed to copy headers`)
+		}
+	}
+	if err := h.Set(TimestampTokenKey, base64.EncodeToString(token)); err != nil {
+		return errors.Wrapf(err, `failed to set %q`, TimestampTokenKey)
+	}
+	sig.headers = h
+	return nil
+}
+
+type timestampCheck struct {
+	ctx      context.Context
+	verifier TimestampVerifier
+}
+
+func (c *timestampCheck) verify(sig *Signature) error {
+	token, ok := sig.TimestampToken()
+	if !ok {
+		return errors.Errorf(`signature does not have a valid %q header`, TimestampTokenKey)
+	}
+	if err := c.verifier.VerifyTimestamp(c.ctx, sig.signature, token); err != nil {
+		return errors.Wrap(err, `failed to verify timestamp token`)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package jws
 
 import (
+	"github.com/lestrrat-go/jwx/internal/fips"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
 )
@@ -14,7 +15,7 @@ func (fn VerifierFactoryFn) Create() (Verifier, error) {
 	return fn()
 }
 
-var verifierDB map[jwa.SignatureAlgorithm]VerifierFactory
+var verifierDB = make(map[jwa.SignatureAlgorithm]VerifierFactory)
 
 // RegisterVerifier is used to register a factory object that creates
 // Verifier objects based on the given algorithm.
@@ -27,8 +28,6 @@ func RegisterVerifier(alg jwa.SignatureAlgorithm, f VerifierFactory) {
 }
 
 func init() {
-	verifierDB = make(map[jwa.SignatureAlgorithm]VerifierFactory)
-
 	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512} {
 		RegisterVerifier(alg, func(alg jwa.SignatureAlgorithm) VerifierFactory {
 			return VerifierFactoryFn(func() (Verifier, error) {
@@ -60,6 +59,9 @@ func init() {
 
 // NewVerifier creates a verifier that signs payloads using the given signature algorithm.
 func NewVerifier(alg jwa.SignatureAlgorithm) (Verifier, error) {
+	if err := fips.CheckSignatureAlgorithm(alg); err != nil {
+		return nil, err
+	}
 	f, ok := verifierDB[alg]
 	if ok {
 		return f.Create()
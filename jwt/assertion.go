@@ -0,0 +1,189 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// ClientAssertionType is the value of the "client_assertion_type" parameter
+// used when authenticating clients using JWTs, as described in RFC 7523
+const ClientAssertionType = `urn:ietf:params:oauth:client-assertion-type:jwt-bearer`
+
+// DefaultClientAssertionLifetime is the default lifetime of client
+// assertions created by `NewClientAssertion()`
+const DefaultClientAssertionLifetime = 5 * time.Minute
+
+// NewClientAssertion creates a signed JWT that can be used to authenticate
+// the client `clientID` against the token endpoint `tokenURL`, as described
+// in RFC 7523 section 2.2 and OpenID Connect Core section 9 (the
+// "private_key_jwt" and "client_secret_jwt" methods).
+//
+// The "iss" and "sub" claims are set to `clientID`, the "aud" claim is set
+// to `tokenURL`, and the "jti", "iat", and "exp" claims are populated
+// automatically.
+//
+// `key` may be a private key, a `jwk.Key`, or a shared secret as a []byte,
+// in which case the "client_secret_jwt" method is used. Unless
+// `jwt.WithAssertionAlgorithm()` is specified, the signature algorithm is
+// chosen based on the key. The "none" algorithm is never allowed.
+func NewClientAssertion(clientID, tokenURL string, key interface{}, options ...ClientAssertionOption) ([]byte, error) {
+	if clientID == "" {
+		return nil, errors.New(`client ID must not be empty`)
+	}
+	if tokenURL == "" {
+		return nil, errors.New(`token URL must not be empty`)
+	}
+
+	var alg jwa.SignatureAlgorithm
+	var jti string
+	var clock Clock = ClockFunc(time.Now)
+	lifetime := DefaultClientAssertionLifetime
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identAssertionAlgorithm{}:
+			alg = option.Value().(jwa.SignatureAlgorithm)
+		case identAssertionJwtID{}:
+			jti = option.Value().(string)
+		case identAssertionClock{}:
+			clock = option.Value().(Clock)
+		case identAssertionLifetime{}:
+			lifetime = option.Value().(time.Duration)
+		}
+	}
+
+	if lifetime <= 0 {
+		return nil, errors.New(`client assertion lifetime must be positive`)
+	}
+
+	if alg == "" {
+		v, err := clientAssertionAlgorithm(key)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to determine signature algorithm`)
+		}
+		alg = v
+	}
+	if alg == jwa.NoSignature {
+		return nil, errors.New(`client assertions must be signed: "none" algorithm is not allowed`)
+	}
+
+	if jti == "" {
+		v, err := newJwtID()
+		if err != nil {
+			return nil, err
+		}
+		jti = v
+	}
+
+	now := clock.Now()
+	t := New()
+	for _, pair := range []struct {
+		Name  string
+		Value interface{}
+	}{
+		{IssuerKey, clientID},
+		{SubjectKey, clientID},
+		{AudienceKey, tokenURL},
+		{JwtIDKey, jti},
+		{IssuedAtKey, now},
+		{ExpirationKey, now.Add(lifetime)},
+	} {
+		if err := t.Set(pair.Name, pair.Value); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, pair.Name)
+		}
+	}
+
+	return Sign(t, alg, key)
+}
+
+func clientAssertionAlgorithm(key interface{}) (jwa.SignatureAlgorithm, error) {
+	if jwkKey, ok := key.(jwk.Key); ok {
+		if v := jwkKey.Algorithm(); v != "" {
+			var alg jwa.SignatureAlgorithm
+			if err := alg.Accept(v); err != nil {
+				return "", errors.Wrapf(err, `invalid "alg" in key`)
+			}
+			return alg, nil
+		}
+
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return "", errors.Wrap(err, `failed to get raw key`)
+		}
+		key = raw
+	}
+
+	switch key := key.(type) {
+	case []byte:
+		return jwa.HS256, nil
+	case *rsa.PrivateKey:
+		return jwa.RS256, nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwa.ES256, nil
+		case elliptic.P384():
+			return jwa.ES384, nil
+		case elliptic.P521():
+			return jwa.ES512, nil
+		}
+		return "", errors.Errorf(`unsupported curve %s`, key.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return jwa.EdDSA, nil
+	default:
+		return "", errors.Errorf(`unsupported key type %T`, key)
+	}
+}
+
+// ParseClientAssertion verifies and validates a client assertion received
+// at the token endpoint `tokenURL`, as described in RFC 7523 section 3.
+//
+// Options such as `jwt.WithVerify()` or `jwt.WithKeySet()` must be used to
+// specify the key of the client. Validation is always performed, and the
+// "iss", "sub", "aud", "exp", and "jti" claims are required. The "iss" and
+// "sub" claims must be the same, and the "aud" claim must contain `tokenURL`.
+//
+// Use `jwt.WithReplayProtection()` or `jwt.WithAssertionReplayCheck()` to
+// reject assertions whose "jti" has already been used.
+func ParseClientAssertion(data []byte, tokenURL string, options ...ParseOption) (Token, error) {
+	var replayCheck AssertionReplayCheckFunc
+	parseOptions := []ParseOption{
+		WithValidate(true),
+		WithAudience(tokenURL),
+	}
+	for _, name := range []string{IssuerKey, SubjectKey, AudienceKey, ExpirationKey, JwtIDKey} {
+		parseOptions = append(parseOptions, WithRequiredClaim(name))
+	}
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identAssertionReplayCheck{}:
+			replayCheck = option.Value().(AssertionReplayCheckFunc)
+		default:
+			parseOptions = append(parseOptions, option)
+		}
+	}
+
+	t, err := Parse(data, parseOptions...)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse client assertion`)
+	}
+
+	if t.Issuer() != t.Subject() {
+		return nil, errors.New(`client assertion "iss" and "sub" must be the same`)
+	}
+
+	if replayCheck != nil {
+		if err := replayCheck(t.JwtID(), t.Expiration()); err != nil {
+			return nil, errors.Wrap(err, `client assertion replay check failed`)
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,206 @@
+package jwt
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultCacheSize = 1000
+	defaultCacheTTL  = time.Minute
+)
+
+// Cache memoizes the result of parsing (and thus verifying and
+// validating) tokens, so that services that receive the same bearer
+// token many times do not need to verify its signature every time.
+//
+// Successfully parsed tokens are remembered until the earlier of their
+// "exp" claim and the TTL given by `jwt.WithCacheTTL()`. Failures are
+// never cached. When the same token is parsed concurrently, it is only
+// parsed once, and all callers receive the same result.
+//
+// Tokens are cached by their serialized form, and all tokens are parsed
+// using the options given by `jwt.WithCacheParseOptions()`. If the keys
+// that tokens are verified against are revoked, call `Purge()` to make
+// sure that tokens are verified again.
+type Cache struct {
+	mu        sync.Mutex
+	size      int
+	ttl       time.Duration
+	options   []ParseOption
+	clock     Clock
+	cacheable bool
+	entries   map[[sha256.Size]byte]*list.Element
+	lru       *list.List
+	inflight  map[[sha256.Size]byte]*cacheCall
+}
+
+type cacheEntry struct {
+	id      [sha256.Size]byte
+	token   Token
+	expires time.Time
+}
+
+type cacheCall struct {
+	wg    sync.WaitGroup
+	token Token
+	err   error
+}
+
+// NewCache creates a new Cache. The options that `jwt.Parse()` is
+// called with, such as `jwt.WithKeySet()` and `jwt.WithValidate()`,
+// must be specified using `jwt.WithCacheParseOptions()`.
+//
+// If `jwt.WithReplayProtection()` is among the parse options, tokens
+// are never cached, as each token must only be accepted once. If
+// `jwt.WithClock()` is among them, the clock is also used to determine
+// when cached tokens expire.
+func NewCache(options ...CacheOption) *Cache {
+	c := &Cache{
+		size:      defaultCacheSize,
+		ttl:       defaultCacheTTL,
+		clock:     ClockFunc(time.Now),
+		cacheable: true,
+		entries:   make(map[[sha256.Size]byte]*list.Element),
+		lru:       list.New(),
+		inflight:  make(map[[sha256.Size]byte]*cacheCall),
+	}
+
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identCacheSize{}:
+			c.size = option.Value().(int)
+		case identCacheTTL{}:
+			c.ttl = option.Value().(time.Duration)
+		case identCacheParseOptions{}:
+			c.options = append(c.options, option.Value().([]ParseOption)...)
+		}
+	}
+
+	//nolint:forcetypeassert
+	for _, option := range c.options {
+		switch option.Ident() {
+		case identReplayProtection{}:
+			c.cacheable = false
+		case identClock{}:
+			c.clock = option.Value().(Clock)
+		}
+	}
+	return c
+}
+
+// Parse parses the token in `src` just like `jwt.Parse()`, but returns
+// the result from the cache if the identical token has already been
+// parsed successfully, and has not expired from the cache.
+//
+// The returned token is always a copy, and can be freely modified.
+func (c *Cache) Parse(src []byte) (Token, error) {
+	if !c.cacheable || c.size <= 0 {
+		return Parse(src, c.options...)
+	}
+
+	id := sha256.Sum256(src)
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	if elem, ok := c.entries[id]; ok {
+		entry := elem.Value.(*cacheEntry) //nolint:forcetypeassert
+		if now.Before(entry.expires) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry.token.Clone()
+		}
+		c.lru.Remove(elem)
+		delete(c.entries, id)
+	}
+
+	if call, ok := c.inflight[id]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		if call.err != nil {
+			return nil, call.err
+		}
+		return call.token.Clone()
+	}
+
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.inflight[id] = call
+	c.mu.Unlock()
+
+	c.parse(id, call, src, now)
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.token.Clone()
+}
+
+// parse parses src on behalf of all callers waiting for `call`. The
+// call is completed even if parsing panics, so that they are not
+// blocked forever.
+func (c *Cache) parse(id [sha256.Size]byte, call *cacheCall, src []byte, now time.Time) {
+	// this is what the waiting callers receive if Parse panics
+	call.err = errors.New(`failed to parse token`)
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, id)
+		if call.err == nil {
+			c.addNL(id, call.token, now)
+		}
+		c.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.token, call.err = Parse(src, c.options...)
+}
+
+// ParseString is the same as Parse, but accepts a string
+func (c *Cache) ParseString(s string) (Token, error) {
+	return c.Parse([]byte(s))
+}
+
+// addNL adds the token to the cache, without the locking
+func (c *Cache) addNL(id [sha256.Size]byte, token Token, now time.Time) {
+	expires := now.Add(c.ttl)
+	if exp := token.Expiration(); !exp.IsZero() && exp.Before(expires) {
+		expires = exp
+	}
+	if !now.Before(expires) {
+		return
+	}
+
+	if elem, ok := c.entries[id]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[id] = c.lru.PushFront(&cacheEntry{
+		id:      id,
+		token:   token,
+		expires: expires,
+	})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).id) //nolint:forcetypeassert
+	}
+}
+
+// Len returns the number of tokens in the cache, including those
+// that have expired but have not been evicted yet
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Purge removes all tokens from the cache
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[[sha256.Size]byte]*list.Element)
+	c.lru.Init()
+}
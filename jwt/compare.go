@@ -0,0 +1,113 @@
+package jwt
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StringComparison specifies how the values of the "iss", "sub", and
+// "aud" claims are compared against the values given to `jwt.Validate()`.
+// See `jwt.WithClaimComparison()`.
+type StringComparison int
+
+const (
+	// ExactComparison compares the values byte by byte. This is the default.
+	ExactComparison StringComparison = iota
+
+	// CaseInsensitiveComparison compares the values using Unicode case
+	// folding (as in strings.EqualFold), which does not depend on the
+	// locale. For example, "Example" matches "EXAMPLE".
+	CaseInsensitiveComparison
+
+	// URLComparison compares the values as URLs, after normalizing the
+	// casing of the scheme and the host, removing the default port for
+	// the scheme, and removing trailing slashes from the path. For
+	// example, "HTTPS://Login.Example.com:443/" matches
+	// "https://login.example.com". The path, query, and fragment are
+	// otherwise compared byte by byte.
+	//
+	// Values that are not absolute URLs are compared byte by byte.
+	URLComparison
+)
+
+func (c StringComparison) String() string {
+	switch c {
+	case ExactComparison:
+		return `exact`
+	case CaseInsensitiveComparison:
+		return `case-insensitive`
+	case URLComparison:
+		return `url`
+	default:
+		return `invalid`
+	}
+}
+
+func (c StringComparison) validate() error {
+	switch c {
+	case ExactComparison, CaseInsensitiveComparison, URLComparison:
+		return nil
+	default:
+		return errors.Errorf(`invalid string comparison %d`, int(c))
+	}
+}
+
+func (c StringComparison) equal(expected, actual string) bool {
+	switch c {
+	case CaseInsensitiveComparison:
+		return strings.EqualFold(expected, actual)
+	case URLComparison:
+		return normalizeURL(expected) == normalizeURL(actual)
+	default:
+		return expected == actual
+	}
+}
+
+var defaultPorts = map[string]string{
+	`http`:  `80`,
+	`https`: `443`,
+}
+
+// normalizeURL returns the normalized form of `s` used by URLComparison,
+// or `s` itself if it is not an absolute URL
+func normalizeURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Opaque != "" {
+		return s
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, `:`) {
+		// IPv6 literal
+		host = `[` + host + `]`
+	}
+
+	var sb strings.Builder
+	sb.WriteString(scheme)
+	sb.WriteString(`://`)
+	if u.User != nil {
+		sb.WriteString(u.User.String())
+		sb.WriteByte('@')
+	}
+	sb.WriteString(host)
+	sb.WriteString(strings.TrimRight(u.EscapedPath(), `/`))
+	if u.RawQuery != "" || u.ForceQuery {
+		sb.WriteByte('?')
+		sb.WriteString(u.RawQuery)
+	}
+	if u.Fragment != "" {
+		sb.WriteByte('#')
+		sb.WriteString(u.EscapedFragment())
+	}
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package jwt
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// IntegrityModel describes how the integrity of a token is protected.
+// It is used to explicitly acknowledge the weaker guarantees of tokens
+// that are encrypted but not signed. See `jwt.WithEncryptedOnly()`
+type IntegrityModel int
+
+const (
+	// IntegrityAEADOnly acknowledges that the integrity of the token only
+	// relies on the authenticated encryption of the JWE message. Anyone
+	// who is able to decrypt such tokens is also able to create them,
+	// and there is no signature that a third party could verify.
+	IntegrityAEADOnly IntegrityModel = iota + 1
+)
+
+// Encrypt encrypts the token `t` without signing it, using the given
+// key encryption and content encryption algorithms. This is typically
+// used for internal tokens that are directly encrypted with a key that
+// is shared by the issuer and the recipient.
+//
+// Because the token is not signed, the option `jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly)`
+// must be passed to acknowledge the integrity model, and only key encryption
+// algorithms where the recipient key is not public are allowed: the
+// symmetric algorithms ("dir", AES key wrap, AES-GCM key wrap and PBES2)
+// and ECDH-1PU, which authenticates the sender.
+//
+// The protected header has "typ" set to "JWT", and never has "cty" set
+// to "JWT", as the plaintext is the claims rather than a nested JWT.
+// Use (jwt.Serializer).Sign and (jwt.Serializer).Encrypt to create
+// tokens that are both signed and encrypted.
+func Encrypt(t Token, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, options ...EncryptOption) ([]byte, error) {
+	return EncryptContext(context.Background(), t, keyalg, key, contentalg, options...)
+}
+
+// EncryptContext is the same as Encrypt, but accepts a context.Context,
+// which is passed on to `jwe.EncryptContext()`.
+func EncryptContext(ctx context.Context, t Token, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, options ...EncryptOption) ([]byte, error) {
+	var model IntegrityModel
+	for _, option := range options {
+		if option.Ident() == (identEncryptedOnly{}) {
+			model = option.Value().(IntegrityModel)
+		}
+	}
+	if model != IntegrityAEADOnly {
+		return nil, errors.New(`jwt.Encrypt: tokens that are not signed require jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly)`)
+	}
+	return NewSerializer().Encrypt(keyalg, key, contentalg, jwa.NoCompress, options...).SerializeContext(ctx, t)
+}
+
+// checkEncryptedOnly returns an error if tokens that are encrypted using
+// `alg` without being signed can be created by parties other than the
+// holders of the recipient key
+func checkEncryptedOnly(alg jwa.KeyEncryptionAlgorithm) error {
+	if alg.IsSymmetric() || strings.HasPrefix(alg.String(), `ECDH-1PU`) {
+		return nil
+	}
+	return errors.Errorf(`key encryption algorithm %q cannot be used for tokens that are not signed: anyone with the public key could create them`, alg)
+}
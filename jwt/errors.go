@@ -0,0 +1,131 @@
+package jwt
+
+import (
+	"fmt"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/pkg/errors"
+)
+
+// ParseError is returned when a JWT could not be parsed.
+// It matches `jwx.ErrParse` via `errors.Is()`
+type ParseError struct {
+	err error
+}
+
+func newParseError(err error) error {
+	return &ParseError{err: err}
+}
+
+func (e *ParseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == jwx.ErrParse
+}
+
+// VerificationError is returned when a JWT is not signed, but
+// verification was requested. Failure to verify the signature itself
+// is reported via `jws.VerificationError`. Both match
+// `jwx.ErrVerification` via `errors.Is()`
+type VerificationError struct {
+	err error
+}
+
+func newVerificationError(err error) error {
+	return &VerificationError{err: err}
+}
+
+func (e *VerificationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.err
+}
+
+func (e *VerificationError) Is(target error) bool {
+	return target == jwx.ErrVerification
+}
+
+// KeyResolutionError is returned when a key to verify the JWT with
+// could not be found in the key set passed via `jwt.WithKeySet()`.
+// It matches `jwx.ErrKeyResolution` via `errors.Is()`
+type KeyResolutionError struct {
+	err error
+}
+
+func newKeyResolutionError(err error) error {
+	return &KeyResolutionError{err: err}
+}
+
+func (e *KeyResolutionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *KeyResolutionError) Unwrap() error {
+	return e.err
+}
+
+func (e *KeyResolutionError) Is(target error) bool {
+	return target == jwx.ErrKeyResolution
+}
+
+// The following errors are the causes of `jwt.ValidationError`,
+// and can be matched using `errors.Is()` to find out which check failed
+var (
+	ErrMissingRequiredClaim = errors.New(`required claim is missing`)
+	ErrInvalidTimeDelta     = errors.New(`time delta between claims is out of range`)
+	ErrInvalidIssuer        = errors.New(`"iss" claim does not match`)
+	ErrInvalidJwtID         = errors.New(`"jti" claim does not match`)
+	ErrInvalidSubject       = errors.New(`"sub" claim does not match`)
+	ErrInvalidAudience      = errors.New(`"aud" claim does not match`)
+	ErrTokenExpired         = errors.New(`token is expired`)
+	ErrInvalidIssuedAt      = errors.New(`"iat" claim is in the future`)
+	ErrTokenNotYetValid     = errors.New(`token is not yet valid`)
+	ErrInvalidClaim         = errors.New(`claim does not match`)
+	ErrTokenReplayed        = errors.New(`token has already been used`)
+	ErrInsufficientScope    = errors.New(`token lacks required scope`)
+)
+
+// ValidationError is returned by `jwt.Validate()` (and `jwt.Parse()`
+// when `jwt.WithValidate(true)` is specified) when the token fails
+// validation. It matches `jwx.ErrValidation` via `errors.Is()`, as
+// well as one of the `jwt.ErrXXX` errors describing the cause.
+// Errors returned from a `jwt.Validator` are also available via
+// `errors.Is()` and `errors.As()`
+type ValidationError struct {
+	claim string
+	msg   string
+	cause error
+}
+
+func newValidationError(claim string, cause error, f string, args ...interface{}) error {
+	return &ValidationError{
+		claim: claim,
+		msg:   fmt.Sprintf(f, args...),
+		cause: cause,
+	}
+}
+
+// Claim returns the name of the claim that failed validation, if known
+func (e *ValidationError) Claim() string {
+	return e.claim
+}
+
+func (e *ValidationError) Error() string {
+	return e.msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.cause
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == jwx.ErrValidation
+}
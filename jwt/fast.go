@@ -0,0 +1,32 @@
+package jwt
+
+import (
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
+)
+
+var typJWT = jws.WithType(`JWT`)
+
+// SignFast is an optimized version of `jwt.Sign()` for the HMAC algorithms
+// (HS256, HS384, and HS512), intended for services that issue large
+// numbers of tokens. It appends the signed token to `dst`, and returns
+// the extended slice, much like the `append` builtin. Reusing `dst`
+// between calls avoids allocating memory for the result.
+//
+// The protected header contains only "alg" and "typ" (set to "JWT").
+// Use `jwt.Sign()` if you need any other headers. See `jws.SignCompactHS()`
+// for details.
+func SignFast(dst []byte, t Token, alg jwa.SignatureAlgorithm, key []byte) ([]byte, error) {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal token`)
+	}
+
+	signed, err := jws.SignCompactHS(dst, alg, key, payload, typJWT)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign token`)
+	}
+	return signed, nil
+}
@@ -0,0 +1,14 @@
+module github.com/lestrrat-go/jwx/jwt/grpc
+
+go 1.15
+
+require (
+	github.com/lestrrat-go/jwx v0.0.0-00010101000000-000000000000
+	github.com/lestrrat-go/option v1.0.0
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.7.0
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.25.0
+)
+
+replace github.com/lestrrat-go/jwx => ../../
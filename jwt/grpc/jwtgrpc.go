@@ -0,0 +1,279 @@
+// Package jwtgrpc implements gRPC server interceptors that authenticate
+// calls using JWTs sent as bearer tokens in the call metadata.
+//
+//   v := jwtgrpc.NewVerifier(
+//     jwtgrpc.WithAutoRefresh(ar, `https://example.com/.well-known/jwks.json`),
+//     jwtgrpc.WithParseOptions(jwt.WithIssuer(`https://example.com`)),
+//     jwtgrpc.WithMethodScopes(`/example.Service/Delete`, `admin`),
+//   )
+//   srv := grpc.NewServer(
+//     grpc.UnaryInterceptor(v.UnaryServerInterceptor()),
+//     grpc.StreamInterceptor(v.StreamServerInterceptor()),
+//   )
+//
+// Handlers can access the verified token using `jwtgrpc.FromContext()`.
+// To propagate its claims in messages or audit logs, convert them to
+// protobuf values using `jwtgrpc.ToStruct()` or `jwtgrpc.ToAnyMap()`.
+//
+// This package is a separate module, so that users of the jwx packages
+// do not depend on gRPC unless they need to.
+package jwtgrpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/kidrefresh"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const defaultMetadataKey = `authorization`
+
+// Verifier extracts bearer tokens from the metadata of incoming calls,
+// and verifies and validates them. Use `jwtgrpc.NewVerifier()` to create one.
+type Verifier struct {
+	metadataKey  string
+	keySource    jwt.ParseOption
+	autoRefresh  *autoRefreshParams
+	parseOptions []jwt.ParseOption
+	audiences    map[string][]string
+	scopes       map[string][]string
+
+	// refresher is set if jwtgrpc.WithRefreshOnUnknownKeyID() has been
+	// specified along with jwtgrpc.WithAutoRefresh()
+	refresher *kidrefresh.Refresher
+}
+
+// NewVerifier creates a new Verifier. The keys that tokens are verified
+// against must be specified using one of `jwtgrpc.WithKeySet()`,
+// `jwtgrpc.WithAutoRefresh()`, or `jwtgrpc.WithVerify()`. Otherwise
+// all calls are rejected.
+func NewVerifier(options ...VerifierOption) *Verifier {
+	v := &Verifier{
+		metadataKey: defaultMetadataKey,
+		audiences:   make(map[string][]string),
+		scopes:      make(map[string][]string),
+	}
+
+	var refreshOnUnknownKeyID bool
+	var unknownKeyIDWait time.Duration
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identMetadataKey{}:
+			// metadata keys are always lower case
+			v.metadataKey = strings.ToLower(option.Value().(string))
+		case identKeySet{}:
+			v.keySource = jwt.WithKeySet(option.Value().(jwk.Set))
+			v.autoRefresh = nil
+		case identVerify{}:
+			params := option.Value().(*verifyParams)
+			v.keySource = jwt.WithVerify(params.alg, params.key)
+			v.autoRefresh = nil
+		case identAutoRefresh{}:
+			v.autoRefresh = option.Value().(*autoRefreshParams)
+			v.keySource = nil
+		case identParseOptions{}:
+			v.parseOptions = append(v.parseOptions, option.Value().([]jwt.ParseOption)...)
+		case identMethodAudience{}:
+			ma := option.Value().(*methodAudience)
+			v.audiences[ma.method] = append(v.audiences[ma.method], ma.audience)
+		case identMethodScopes{}:
+			ms := option.Value().(*methodScopes)
+			v.scopes[ms.method] = append(v.scopes[ms.method], ms.scopes...)
+		case identRefreshOnUnknownKeyID{}:
+			refreshOnUnknownKeyID = true
+			unknownKeyIDWait = option.Value().(time.Duration)
+		}
+	}
+
+	if refreshOnUnknownKeyID && v.autoRefresh != nil {
+		v.refresher = kidrefresh.New(v.autoRefresh.ar, v.autoRefresh.url, unknownKeyIDWait)
+	}
+	return v
+}
+
+// Verify extracts the token from the metadata in ctx, verifies and
+// validates it, and checks that it satisfies the requirements for
+// `method`. The returned error is a gRPC status error.
+func (v *Verifier) Verify(ctx context.Context, method string) (jwt.Token, error) {
+	src, err := v.extract(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	var keySource jwt.ParseOption
+	switch {
+	case v.keySource != nil:
+		keySource = v.keySource
+	case v.autoRefresh != nil:
+		set, err := v.autoRefresh.ar.Fetch(ctx, v.autoRefresh.url)
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, `failed to fetch key set`)
+		}
+		if v.refresher != nil {
+			if kid, ok := kidrefresh.UnknownKeyID([]byte(src), set); ok {
+				set, err = v.awaitKeyID(ctx, kid)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		keySource = jwt.WithKeySet(set)
+	default:
+		return nil, status.Error(codes.Internal, `no key to verify tokens against has been configured`)
+	}
+
+	options := make([]jwt.ParseOption, 0, len(v.parseOptions)+2)
+	options = append(options, jwt.WithValidate(true))
+	options = append(options, v.parseOptions...)
+	options = append(options, keySource)
+
+	tok, err := jwt.ParseContext(ctx, []byte(src), options...)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, status.Error(codes.Unauthenticated, `token is expired`)
+		}
+		return nil, status.Error(codes.Unauthenticated, `token is invalid`)
+	}
+
+	if audiences, ok := v.audiences[method]; ok && !containsAny(tok.Audience(), audiences) {
+		return nil, status.Error(codes.Unauthenticated, `token is not intended for this method`)
+	}
+
+	if scopes, ok := v.scopes[method]; ok {
+		granted := grantedScopes(tok)
+		for _, scope := range scopes {
+			if _, ok := granted[scope]; !ok {
+				return nil, status.Errorf(codes.PermissionDenied, `token lacks required scope %q`, scope)
+			}
+		}
+	}
+	return tok, nil
+}
+
+// awaitKeyID schedules a refresh of the key set, and waits for a key
+// set that contains kid. The returned error is a gRPC status error.
+func (v *Verifier) awaitKeyID(ctx context.Context, kid string) (jwk.Set, error) {
+	set, err := v.refresher.Await(ctx, kid)
+	if err != nil {
+		if errors.Is(err, kidrefresh.ErrUnknownKeyID) {
+			return nil, status.Errorf(codes.Unavailable, `key ID %q is not in the key set (yet)`, kid)
+		}
+		return nil, status.Error(codes.Unavailable, `failed to refresh key set`)
+	}
+	return set, nil
+}
+
+func (v *Verifier) extract(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", errors.New(`no metadata found in context`)
+	}
+
+	values := md.Get(v.metadataKey)
+	switch len(values) {
+	case 0:
+		return "", errors.Errorf(`no token found in metadata %q`, v.metadataKey)
+	case 1:
+	default:
+		return "", errors.Errorf(`more than one value found in metadata %q`, v.metadataKey)
+	}
+
+	value := strings.TrimSpace(values[0])
+	if v.metadataKey == defaultMetadataKey {
+		value = bearerToken(value)
+	}
+	if value == "" {
+		return "", errors.Errorf(`no token found in metadata %q`, v.metadataKey)
+	}
+	return value, nil
+}
+
+// bearerToken returns the token in the value of an authorization
+// header, or an empty string if it does not use the "Bearer" scheme
+func bearerToken(v string) string {
+	i := strings.IndexByte(v, ' ')
+	if i < 0 || !strings.EqualFold(v[:i], `Bearer`) {
+		return ""
+	}
+	return strings.TrimSpace(v[i+1:])
+}
+
+func containsAny(list []string, candidates []string) bool {
+	for _, v := range list {
+		for _, candidate := range candidates {
+			if v == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// grantedScopes returns the scopes granted to the token
+func grantedScopes(tok jwt.Token) map[string]struct{} {
+	granted := make(map[string]struct{})
+	for _, scope := range tok.Scopes() {
+		granted[scope] = struct{}{}
+	}
+	return granted
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor that
+// verifies the token of each call before passing it on to the handler.
+// The verified token is stored in the context, and can be retrieved
+// using `jwtgrpc.FromContext()`.
+func (v *Verifier) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		tok, err := v.Verify(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(NewContext(ctx, tok), req)
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor that
+// verifies the token of each stream before passing it on to the handler.
+// The verified token is stored in the context of the stream, and can be
+// retrieved using `jwtgrpc.FromContext()`.
+func (v *Verifier) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		tok, err := v.Verify(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: NewContext(ss.Context(), tok)})
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries the token
+func NewContext(ctx context.Context, tok jwt.Token) context.Context {
+	return context.WithValue(ctx, contextKey{}, tok)
+}
+
+// FromContext returns the token stored in ctx by the interceptors
+func FromContext(ctx context.Context) (jwt.Token, bool) {
+	tok, ok := ctx.Value(contextKey{}).(jwt.Token)
+	return tok, ok
+}
@@ -0,0 +1,129 @@
+package jwtgrpc
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// VerifierOption describes an Option that can be passed to `jwtgrpc.NewVerifier()`
+type VerifierOption interface {
+	Option
+	verifierOption()
+}
+
+type verifierOption struct {
+	Option
+}
+
+func (*verifierOption) verifierOption() {}
+
+func newVerifierOption(n interface{}, v interface{}) VerifierOption {
+	return &verifierOption{option.New(n, v)}
+}
+
+type identAutoRefresh struct{}
+type identKeySet struct{}
+type identMetadataKey struct{}
+type identMethodAudience struct{}
+type identMethodScopes struct{}
+type identParseOptions struct{}
+type identRefreshOnUnknownKeyID struct{}
+type identVerify struct{}
+
+type autoRefreshParams struct {
+	ar  *jwk.AutoRefresh
+	url string
+}
+
+type verifyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+type methodAudience struct {
+	method   string
+	audience string
+}
+
+type methodScopes struct {
+	method string
+	scopes []string
+}
+
+// WithMetadataKey specifies the metadata key from which the token is
+// extracted. The default is "authorization", in which case only values
+// using the "Bearer" scheme are considered.
+func WithMetadataKey(name string) VerifierOption {
+	return newVerifierOption(identMetadataKey{}, name)
+}
+
+// WithKeySet specifies the key set that tokens are verified against.
+// See `jwt.WithKeySet()` for details.
+func WithKeySet(set jwk.Set) VerifierOption {
+	return newVerifierOption(identKeySet{}, set)
+}
+
+// WithAutoRefresh specifies that tokens are verified against the key set
+// at `url`, which is fetched using `ar`. The url must have been registered
+// using `(jwk.AutoRefresh).Configure()`.
+func WithAutoRefresh(ar *jwk.AutoRefresh, url string) VerifierOption {
+	return newVerifierOption(identAutoRefresh{}, &autoRefreshParams{ar: ar, url: url})
+}
+
+// WithVerify specifies the algorithm and the key that tokens are
+// verified against. See `jwt.WithVerify()` for details.
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) VerifierOption {
+	return newVerifierOption(identVerify{}, &verifyParams{alg: alg, key: key})
+}
+
+// WithParseOptions specifies extra options that are passed to
+// `jwt.Parse()` for all methods, such as `jwt.WithIssuer()`.
+// Tokens are always validated, unless `jwt.WithValidate(false)` is
+// explicitly specified.
+func WithParseOptions(options ...jwt.ParseOption) VerifierOption {
+	return newVerifierOption(identParseOptions{}, options)
+}
+
+// WithMethodAudience specifies that tokens for the method (e.g.
+// "/package.Service/Method") must contain `aud` in their "aud" claim.
+// It may be specified multiple times for the same method, in which case
+// any of the audiences is accepted.
+func WithMethodAudience(method, aud string) VerifierOption {
+	return newVerifierOption(identMethodAudience{}, &methodAudience{method: method, audience: aud})
+}
+
+// WithMethodScopes specifies that tokens for the method (e.g.
+// "/package.Service/Method") must have been granted all of the given
+// scopes. Scopes are read using `(jwt.Token).Scopes()`, so both the
+// "scope" and "scp" claims are honored.
+//
+// Requests whose token lacks any of the scopes are rejected with
+// `codes.PermissionDenied`.
+func WithMethodScopes(method string, scopes ...string) VerifierOption {
+	return newVerifierOption(identMethodScopes{}, &methodScopes{method: method, scopes: scopes})
+}
+
+// WithRefreshOnUnknownKeyID specifies how to handle tokens signed with
+// a key whose "kid" is not in the key set specified by
+// `jwtgrpc.WithAutoRefresh()`, as happens while the identity provider
+// rolls over its keys. By default, such tokens are rejected with
+// codes.Unauthenticated.
+//
+// When this option is specified, a refresh of the key set is scheduled
+// using `(jwk.AutoRefresh).TriggerRefresh()`, and if `wait` is positive,
+// the call is blocked for up to `wait` until a key set containing the
+// key arrives. If the key is still not available, the call is rejected
+// with codes.Unavailable, so that clients may retry it.
+//
+// To avoid flooding the identity provider, refreshes are triggered at
+// most once every few seconds per Verifier. This option has no effect
+// unless `jwtgrpc.WithAutoRefresh()` is specified.
+func WithRefreshOnUnknownKeyID(wait time.Duration) VerifierOption {
+	return newVerifierOption(identRefreshOnUnknownKeyID{}, wait)
+}
@@ -0,0 +1,121 @@
+package jwtgrpc
+
+import (
+	"encoding/json"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// claimsOf returns the claims in the token as they would appear in its
+// JSON representation. Date claims such as "exp" become numbers of seconds
+// since the epoch, and private claims keep their JSON form.
+func claimsOf(tok jwt.Token) (map[string]interface{}, error) {
+	buf, err := json.Marshal(tok)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal token`)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(buf, &claims); err != nil {
+		return nil, errors.Wrap(err, `failed to unmarshal claims`)
+	}
+	return claims, nil
+}
+
+// tokenOf creates a token from claims in their JSON representation
+func tokenOf(claims map[string]interface{}) (jwt.Token, error) {
+	buf, err := json.Marshal(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal claims`)
+	}
+
+	tok := jwt.New()
+	if err := json.Unmarshal(buf, tok); err != nil {
+		return nil, errors.Wrap(err, `failed to unmarshal token`)
+	}
+	return tok, nil
+}
+
+// ToStruct converts the claims in the token to a protobuf Struct, so that
+// they can be embedded in messages or audit logs. Date claims are stored
+// as numbers of seconds since the epoch, as they are in the JWT itself,
+// and can be restored using `jwtgrpc.FromStruct()`.
+func ToStruct(tok jwt.Token) (*structpb.Struct, error) {
+	claims, err := claimsOf(tok)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to convert token to struct`)
+	}
+
+	s, err := structpb.NewStruct(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to convert token to struct`)
+	}
+	return s, nil
+}
+
+// FromStruct creates a token from the claims in a protobuf Struct created
+// by `jwtgrpc.ToStruct()`. The token is NOT verified nor validated: only
+// use it with claims that come from a trusted source.
+func FromStruct(s *structpb.Struct) (jwt.Token, error) {
+	if s == nil {
+		return nil, errors.New(`failed to convert struct to token: struct is nil`)
+	}
+
+	tok, err := tokenOf(s.AsMap())
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to convert struct to token`)
+	}
+	return tok, nil
+}
+
+// ToAnyMap converts the claims in the token to a map of claim names to
+// protobuf Any messages, each holding a `structpb.Value`. Date claims are
+// stored as numbers of seconds since the epoch.
+func ToAnyMap(tok jwt.Token) (map[string]*anypb.Any, error) {
+	claims, err := claimsOf(tok)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to convert token to map`)
+	}
+
+	m := make(map[string]*anypb.Any, len(claims))
+	for name, claim := range claims {
+		v, err := structpb.NewValue(claim)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to convert claim %q`, name)
+		}
+
+		a, err := anypb.New(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to convert claim %q`, name)
+		}
+		m[name] = a
+	}
+	return m, nil
+}
+
+// FromAnyMap creates a token from a map created by `jwtgrpc.ToAnyMap()`.
+// Each value must hold a `structpb.Value`. As with `jwtgrpc.FromStruct()`,
+// the token is NOT verified nor validated.
+func FromAnyMap(m map[string]*anypb.Any) (jwt.Token, error) {
+	claims := make(map[string]interface{}, len(m))
+	for name, a := range m {
+		if a == nil {
+			continue
+		}
+
+		var v structpb.Value
+		if err := a.UnmarshalTo(&v); err != nil {
+			return nil, errors.Wrapf(err, `failed to convert claim %q`, name)
+		}
+		claims[name] = v.AsInterface()
+	}
+
+	tok, err := tokenOf(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to convert map to token`)
+	}
+	return tok, nil
+}
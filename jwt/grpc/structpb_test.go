@@ -0,0 +1,90 @@
+package jwtgrpc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	jwtgrpc "github.com/lestrrat-go/jwx/jwt/grpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestStructpb(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	tok := jwt.New()
+	for k, v := range map[string]interface{}{
+		jwt.SubjectKey:    `alice`,
+		jwt.AudienceKey:   []string{`a`, `b`},
+		jwt.IssuedAtKey:   now,
+		jwt.ExpirationKey: now.Add(time.Hour),
+		`scope`:           `read write`,
+		`admin`:           true,
+		`nested`:          map[string]interface{}{`level`: 3.0},
+	} {
+		if !assert.NoError(t, tok.Set(k, v), `tok.Set should succeed`) {
+			return
+		}
+	}
+
+	check := func(t *testing.T, got jwt.Token) {
+		t.Helper()
+		assert.Equal(t, `alice`, got.Subject(), `sub should match`)
+		assert.Equal(t, []string{`a`, `b`}, got.Audience(), `aud should match`)
+		assert.True(t, now.Equal(got.IssuedAt()), `iat should match`)
+		assert.True(t, now.Add(time.Hour).Equal(got.Expiration()), `exp should match`)
+
+		for k, expected := range map[string]interface{}{
+			`scope`:  `read write`,
+			`admin`:  true,
+			`nested`: map[string]interface{}{`level`: 3.0},
+		} {
+			v, ok := got.Get(k)
+			if assert.True(t, ok, `%s should exist`, k) {
+				assert.Equal(t, expected, v, `%s should match`, k)
+			}
+		}
+	}
+
+	t.Run("Struct", func(t *testing.T) {
+		t.Parallel()
+		s, err := jwtgrpc.ToStruct(tok)
+		if !assert.NoError(t, err, `jwtgrpc.ToStruct should succeed`) {
+			return
+		}
+		assert.Equal(t, float64(now.Add(time.Hour).Unix()), s.Fields[jwt.ExpirationKey].GetNumberValue(), `exp should be stored as seconds`)
+
+		got, err := jwtgrpc.FromStruct(s)
+		if !assert.NoError(t, err, `jwtgrpc.FromStruct should succeed`) {
+			return
+		}
+		check(t, got)
+
+		_, err = jwtgrpc.FromStruct(nil)
+		assert.Error(t, err, `jwtgrpc.FromStruct should fail for nil`)
+	})
+	t.Run("AnyMap", func(t *testing.T) {
+		t.Parallel()
+		m, err := jwtgrpc.ToAnyMap(tok)
+		if !assert.NoError(t, err, `jwtgrpc.ToAnyMap should succeed`) {
+			return
+		}
+		assert.Len(t, m, 7, `there should be one entry per claim`)
+
+		got, err := jwtgrpc.FromAnyMap(m)
+		if !assert.NoError(t, err, `jwtgrpc.FromAnyMap should succeed`) {
+			return
+		}
+		check(t, got)
+
+		bad, err := anypb.New(&structpb.Struct{})
+		if !assert.NoError(t, err, `anypb.New should succeed`) {
+			return
+		}
+		_, err = jwtgrpc.FromAnyMap(map[string]*anypb.Any{`sub`: bad})
+		assert.Error(t, err, `jwtgrpc.FromAnyMap should fail for values that are not structpb.Value`)
+	})
+}
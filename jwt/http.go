@@ -47,6 +47,8 @@ func ParseForm(values url.Values, name string, options ...ParseOption) (Token, e
 //
 // By default, "Authorization" header will be searched.
 //
+// The request's context is used in the same way as `jwt.ParseContext()`.
+//
 // If WithHeaderKey() is used, you must explicitly re-enable searching for "Authorization" header.
 //
 //   # searches for "Authorization"
@@ -60,7 +62,7 @@ func ParseForm(values url.Values, name string, options ...ParseOption) (Token, e
 func ParseRequest(req *http.Request, options ...ParseOption) (Token, error) {
 	var hdrkeys []string
 	var formkeys []string
-	var parseOptions []ParseOption
+	parseOptions := []ParseOption{newParseOption(identContext{}, req.Context())}
 	for _, option := range options {
 		switch option.Ident() {
 		case identHeaderKey{}:
@@ -0,0 +1,346 @@
+// Package jwthttp implements a net/http middleware that authenticates
+// requests using JWTs sent as bearer tokens, as described in RFC 6750
+// (https://tools.ietf.org/html/rfc6750)
+//
+//   v := jwthttp.NewVerifier(
+//     jwthttp.WithAutoRefresh(ar, `https://example.com/.well-known/jwks.json`),
+//     jwthttp.WithParseOptions(jwt.WithIssuer(`https://example.com`)),
+//   )
+//   http.Handle(`/api/`, v.Middleware(apiHandler))
+//
+// Handlers can access the verified token using `jwthttp.FromContext()`
+package jwthttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/kidrefresh"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrMissingToken is returned when the request does not contain a token
+	ErrMissingToken = errors.New(`no token found in request`)
+	// ErrMultipleTokens is returned when the request contains a token in
+	// more than one location, which RFC 6750 forbids
+	ErrMultipleTokens = errors.New(`token found in more than one location in request`)
+)
+
+// UnknownKeyIDError is returned by `(*jwthttp.Verifier).Verify()` when
+// `jwthttp.WithRefreshOnUnknownKeyID()` is specified, and the token was
+// signed with a key that is not in the key set, even after waiting for
+// the refresh of the key set. This usually happens while the identity
+// provider is rolling over its keys, so the request may be retried
+// after a short while.
+type UnknownKeyIDError struct {
+	// KeyID is the "kid" of the token
+	KeyID string
+}
+
+func (e *UnknownKeyIDError) Error() string {
+	return `key ID ` + strconv.Quote(e.KeyID) + ` is not in the key set (yet)`
+}
+
+// Temporary always returns true, as the key may become available once
+// the key set has been refreshed
+func (e *UnknownKeyIDError) Temporary() bool {
+	return true
+}
+
+type locationKind int
+
+const (
+	inHeader locationKind = iota
+	inCookie
+	inQuery
+)
+
+type location struct {
+	kind locationKind
+	name string
+}
+
+// Verifier extracts bearer tokens from requests, and verifies and
+// validates them. Use `jwthttp.NewVerifier()` to create one.
+type Verifier struct {
+	locations    []location
+	keySource    jwt.ParseOption
+	autoRefresh  *autoRefreshParams
+	parseOptions []jwt.ParseOption
+	realm        string
+	errorHandler ErrorHandler
+
+	// refresher is set if jwthttp.WithRefreshOnUnknownKeyID() has been
+	// specified along with jwthttp.WithAutoRefresh()
+	refresher *kidrefresh.Refresher
+}
+
+// serverError describes a failure that is not the client's fault,
+// such as a failure to fetch the key set
+type serverError struct {
+	error
+}
+
+func (e serverError) Unwrap() error {
+	return e.error
+}
+
+// NewVerifier creates a new Verifier. The keys that tokens are verified
+// against must be specified using one of `jwthttp.WithKeySet()`,
+// `jwthttp.WithAutoRefresh()`, or `jwthttp.WithVerify()`. Otherwise
+// all requests are rejected.
+func NewVerifier(options ...VerifierOption) *Verifier {
+	v := &Verifier{}
+
+	var refreshOnUnknownKeyID bool
+	var unknownKeyIDWait time.Duration
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identHeader{}:
+			v.locations = append(v.locations, location{kind: inHeader, name: option.Value().(string)})
+		case identCookie{}:
+			v.locations = append(v.locations, location{kind: inCookie, name: option.Value().(string)})
+		case identQuery{}:
+			v.locations = append(v.locations, location{kind: inQuery, name: option.Value().(string)})
+		case identKeySet{}:
+			v.keySource = jwt.WithKeySet(option.Value().(jwk.Set))
+			v.autoRefresh = nil
+		case identVerify{}:
+			params := option.Value().(*verifyParams)
+			v.keySource = jwt.WithVerify(params.alg, params.key)
+			v.autoRefresh = nil
+		case identAutoRefresh{}:
+			v.autoRefresh = option.Value().(*autoRefreshParams)
+			v.keySource = nil
+		case identParseOptions{}:
+			v.parseOptions = append(v.parseOptions, option.Value().([]jwt.ParseOption)...)
+		case identRealm{}:
+			v.realm = option.Value().(string)
+		case identErrorHandler{}:
+			v.errorHandler = option.Value().(ErrorHandler)
+		case identRefreshOnUnknownKeyID{}:
+			refreshOnUnknownKeyID = true
+			unknownKeyIDWait = option.Value().(time.Duration)
+		}
+	}
+
+	if refreshOnUnknownKeyID && v.autoRefresh != nil {
+		v.refresher = kidrefresh.New(v.autoRefresh.ar, v.autoRefresh.url, unknownKeyIDWait)
+	}
+
+	if len(v.locations) == 0 {
+		v.locations = []location{{kind: inHeader, name: `Authorization`}}
+	}
+	return v
+}
+
+// Verify extracts the token from the request, and verifies and
+// validates it.
+func (v *Verifier) Verify(req *http.Request) (jwt.Token, error) {
+	src, err := v.extract(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var keySource jwt.ParseOption
+	switch {
+	case v.keySource != nil:
+		keySource = v.keySource
+	case v.autoRefresh != nil:
+		set, err := v.autoRefresh.ar.Fetch(req.Context(), v.autoRefresh.url)
+		if err != nil {
+			return nil, serverError{errors.Wrap(err, `failed to fetch key set`)}
+		}
+		if v.refresher != nil {
+			if kid, ok := kidrefresh.UnknownKeyID([]byte(src), set); ok {
+				set, err = v.awaitKeyID(req.Context(), kid)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		keySource = jwt.WithKeySet(set)
+	default:
+		return nil, serverError{errors.New(`no key to verify tokens against has been configured`)}
+	}
+
+	options := make([]jwt.ParseOption, 0, len(v.parseOptions)+2)
+	options = append(options, jwt.WithValidate(true))
+	options = append(options, v.parseOptions...)
+	options = append(options, keySource)
+
+	tok, err := jwt.ParseContext(req.Context(), []byte(src), options...)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to verify token`)
+	}
+	return tok, nil
+}
+
+// awaitKeyID schedules a refresh of the key set, and waits for a key
+// set that contains kid
+func (v *Verifier) awaitKeyID(ctx context.Context, kid string) (jwk.Set, error) {
+	set, err := v.refresher.Await(ctx, kid)
+	if err != nil {
+		if errors.Is(err, kidrefresh.ErrUnknownKeyID) {
+			return nil, &UnknownKeyIDError{KeyID: kid}
+		}
+		return nil, serverError{err}
+	}
+	return set, nil
+}
+
+func (v *Verifier) extract(req *http.Request) (string, error) {
+	var found []string
+	for _, loc := range v.locations {
+		var value string
+		switch loc.kind {
+		case inHeader:
+			value = strings.TrimSpace(req.Header.Get(loc.name))
+			if http.CanonicalHeaderKey(loc.name) == `Authorization` {
+				value = bearerToken(value)
+			}
+		case inCookie:
+			if cookie, err := req.Cookie(loc.name); err == nil {
+				value = strings.TrimSpace(cookie.Value)
+			}
+		case inQuery:
+			value = strings.TrimSpace(req.URL.Query().Get(loc.name))
+		}
+
+		if value != "" {
+			found = append(found, value)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", ErrMissingToken
+	case 1:
+		return found[0], nil
+	default:
+		return "", ErrMultipleTokens
+	}
+}
+
+// bearerToken returns the token in the value of an Authorization
+// header, or an empty string if it does not use the "Bearer" scheme
+func bearerToken(v string) string {
+	i := strings.IndexByte(v, ' ')
+	if i < 0 || !strings.EqualFold(v[:i], `Bearer`) {
+		return ""
+	}
+	return strings.TrimSpace(v[i+1:])
+}
+
+// Middleware returns a http.Handler that verifies the token in each
+// request before passing it on to `next`. The verified token is stored
+// in the request context, and can be retrieved using `jwthttp.FromContext()`.
+//
+// Requests are rejected as described in RFC 6750: requests without a
+// token receive a 401 response, requests with an invalid token receive
+// a 401 response with error="invalid_token", and requests with more than
+// one token receive a 400 response with error="invalid_request". If the
+// key set cannot be fetched, a 500 response is returned. If the token was
+// signed with a key that is not in the key set, and
+// `jwthttp.WithRefreshOnUnknownKeyID()` is specified, a 503 response with
+// a "Retry-After" header is returned.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tok, err := v.Verify(req)
+		if err != nil {
+			v.reject(w, req, err)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(NewContext(req.Context(), tok)))
+	})
+}
+
+func (v *Verifier) reject(w http.ResponseWriter, req *http.Request, err error) {
+	var status int
+	var code, description string
+
+	var serr serverError
+	var kerr *UnknownKeyIDError
+	switch {
+	case errors.As(err, &serr):
+		status = http.StatusInternalServerError
+	case errors.As(err, &kerr):
+		status = http.StatusServiceUnavailable
+		w.Header().Set(`Retry-After`, strconv.Itoa(int(kidrefresh.MinInterval/time.Second)))
+	case errors.Is(err, ErrMissingToken):
+		status = http.StatusUnauthorized
+	case errors.Is(err, ErrMultipleTokens):
+		status = http.StatusBadRequest
+		code = `invalid_request`
+		description = `The request contains more than one access token`
+	default:
+		status = http.StatusUnauthorized
+		code = `invalid_token`
+		description = `The access token is invalid`
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			description = `The access token expired`
+		}
+	}
+
+	if status < http.StatusInternalServerError {
+		w.Header().Set(`WWW-Authenticate`, v.challenge(code, description))
+	}
+
+	if h := v.errorHandler; h != nil {
+		h.HandleError(w, req, status, err)
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// challenge builds the value of the WWW-Authenticate header
+func (v *Verifier) challenge(code, description string) string {
+	var params []string
+	if v.realm != "" {
+		params = append(params, `realm=`+quote(v.realm))
+	}
+	if code != "" {
+		params = append(params, `error=`+quote(code))
+	}
+	if description != "" {
+		params = append(params, `error_description=`+quote(description))
+	}
+
+	if len(params) == 0 {
+		return `Bearer`
+	}
+	return `Bearer ` + strings.Join(params, `, `)
+}
+
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries the token
+func NewContext(ctx context.Context, tok jwt.Token) context.Context {
+	return context.WithValue(ctx, contextKey{}, tok)
+}
+
+// FromContext returns the token stored in ctx by the middleware
+func FromContext(ctx context.Context) (jwt.Token, bool) {
+	tok, ok := ctx.Value(contextKey{}).(jwt.Token)
+	return tok, ok
+}
@@ -0,0 +1,389 @@
+package jwthttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	jwthttp "github.com/lestrrat-go/jwx/jwt/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, `my-key`)
+	_ = key.Set(jwk.AlgorithmKey, jwa.RS256)
+
+	pubkey, err := jwk.PublicKeyOf(key)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	set := jwk.NewSet()
+	set.Add(pubkey)
+
+	sign := func(t *testing.T, tok jwt.Token) string {
+		t.Helper()
+		signed, err := jwt.Sign(tok, jwa.RS256, key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			t.FailNow()
+		}
+		return string(signed)
+	}
+
+	valid := jwt.New()
+	_ = valid.Set(jwt.SubjectKey, `lestrrat`)
+	_ = valid.Set(jwt.IssuerKey, `https://github.com/lestrrat-go/jwx`)
+	validToken := sign(t, valid)
+
+	expired := jwt.New()
+	_ = expired.Set(jwt.IssuerKey, `https://github.com/lestrrat-go/jwx`)
+	_ = expired.Set(jwt.ExpirationKey, time.Now().Add(-time.Hour))
+	expiredToken := sign(t, expired)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tok, ok := jwthttp.FromContext(req.Context())
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(tok.Subject()))
+	})
+
+	testcases := []struct {
+		Name      string
+		Options   []jwthttp.VerifierOption
+		Setup     func(*http.Request)
+		Status    int
+		Challenge string
+		Body      string
+	}{
+		{
+			Name: "Valid token in Authorization header",
+			Setup: func(req *http.Request) {
+				req.Header.Set(`Authorization`, `Bearer `+validToken)
+			},
+			Status: http.StatusOK,
+			Body:   `lestrrat`,
+		},
+		{
+			Name: "Scheme is case insensitive",
+			Setup: func(req *http.Request) {
+				req.Header.Set(`Authorization`, `bearer `+validToken)
+			},
+			Status: http.StatusOK,
+			Body:   `lestrrat`,
+		},
+		{
+			Name:      "Missing token",
+			Setup:     func(*http.Request) {},
+			Status:    http.StatusUnauthorized,
+			Challenge: `Bearer realm="example"`,
+		},
+		{
+			Name: "Other authentication scheme",
+			Setup: func(req *http.Request) {
+				req.SetBasicAuth(`user`, `password`)
+			},
+			Status:    http.StatusUnauthorized,
+			Challenge: `Bearer realm="example"`,
+		},
+		{
+			Name: "Malformed token",
+			Setup: func(req *http.Request) {
+				req.Header.Set(`Authorization`, `Bearer foo.bar.baz`)
+			},
+			Status:    http.StatusUnauthorized,
+			Challenge: `Bearer realm="example", error="invalid_token", error_description="The access token is invalid"`,
+		},
+		{
+			Name: "Expired token",
+			Setup: func(req *http.Request) {
+				req.Header.Set(`Authorization`, `Bearer `+expiredToken)
+			},
+			Status:    http.StatusUnauthorized,
+			Challenge: `Bearer realm="example", error="invalid_token", error_description="The access token expired"`,
+		},
+		{
+			Name: "Failed validation",
+			Options: []jwthttp.VerifierOption{
+				jwthttp.WithParseOptions(jwt.WithIssuer(`https://example.com`)),
+			},
+			Setup: func(req *http.Request) {
+				req.Header.Set(`Authorization`, `Bearer `+validToken)
+			},
+			Status:    http.StatusUnauthorized,
+			Challenge: `Bearer realm="example", error="invalid_token", error_description="The access token is invalid"`,
+		},
+		{
+			Name: "Valid token in cookie",
+			Options: []jwthttp.VerifierOption{
+				jwthttp.WithCookie(`access_token`),
+			},
+			Setup: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: `access_token`, Value: validToken})
+			},
+			Status: http.StatusOK,
+			Body:   `lestrrat`,
+		},
+		{
+			Name: "Valid token in query",
+			Options: []jwthttp.VerifierOption{
+				jwthttp.WithQuery(`access_token`),
+			},
+			Setup: func(req *http.Request) {
+				q := req.URL.Query()
+				q.Set(`access_token`, validToken)
+				req.URL.RawQuery = q.Encode()
+			},
+			Status: http.StatusOK,
+			Body:   `lestrrat`,
+		},
+		{
+			Name: "Authorization header is not searched when other locations are specified",
+			Options: []jwthttp.VerifierOption{
+				jwthttp.WithCookie(`access_token`),
+			},
+			Setup: func(req *http.Request) {
+				req.Header.Set(`Authorization`, `Bearer `+validToken)
+			},
+			Status:    http.StatusUnauthorized,
+			Challenge: `Bearer realm="example"`,
+		},
+		{
+			Name: "Multiple tokens",
+			Options: []jwthttp.VerifierOption{
+				jwthttp.WithHeader(`Authorization`),
+				jwthttp.WithQuery(`access_token`),
+			},
+			Setup: func(req *http.Request) {
+				req.Header.Set(`Authorization`, `Bearer `+validToken)
+				q := req.URL.Query()
+				q.Set(`access_token`, validToken)
+				req.URL.RawQuery = q.Encode()
+			},
+			Status:    http.StatusBadRequest,
+			Challenge: `Bearer realm="example", error="invalid_request", error_description="The request contains more than one access token"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			options := append([]jwthttp.VerifierOption{
+				jwthttp.WithKeySet(set),
+				jwthttp.WithRealm(`example`),
+			}, tc.Options...)
+			handler := jwthttp.NewVerifier(options...).Middleware(next)
+
+			req := httptest.NewRequest(http.MethodGet, `/`, nil)
+			tc.Setup(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if !assert.Equal(t, tc.Status, rec.Code, `status code should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.Challenge, rec.Header().Get(`WWW-Authenticate`), `WWW-Authenticate should match`) {
+				return
+			}
+			if tc.Body != "" {
+				if !assert.Equal(t, tc.Body, rec.Body.String(), `body should match`) {
+					return
+				}
+			}
+		})
+	}
+
+	t.Run("AutoRefresh", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_ = json.NewEncoder(w).Encode(set)
+		}))
+		defer srv.Close()
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL)
+
+		handler := jwthttp.NewVerifier(jwthttp.WithAutoRefresh(ar, srv.URL)).Middleware(next)
+		req := httptest.NewRequest(http.MethodGet, `/`, nil)
+		req.Header.Set(`Authorization`, `Bearer `+validToken)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if !assert.Equal(t, http.StatusOK, rec.Code, `status code should match`) {
+			return
+		}
+
+		// URLs that have not been configured cannot be fetched
+		handler = jwthttp.NewVerifier(jwthttp.WithAutoRefresh(ar, srv.URL+`/unknown`)).Middleware(next)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if !assert.Equal(t, http.StatusInternalServerError, rec.Code, `status code should match`) {
+			return
+		}
+	})
+	t.Run("RefreshOnUnknownKeyID", func(t *testing.T) {
+		t.Parallel()
+
+		// the key that signed validToken is only published from the
+		// second fetch onwards, as if the identity provider was rolling
+		// over its keys
+		oldKey, err := jwxtest.GenerateRsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+			return
+		}
+		_ = oldKey.Set(jwk.KeyIDKey, `old-key`)
+		oldSet := jwk.NewSet()
+		oldSet.Add(oldKey)
+
+		newServer := func() *httptest.Server {
+			var mu sync.Mutex
+			var count int
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				mu.Lock()
+				count++
+				rotated := count > 1
+				mu.Unlock()
+				if rotated {
+					_ = json.NewEncoder(w).Encode(set)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(oldSet)
+			}))
+		}
+
+		serve := func(handler http.Handler) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, `/`, nil)
+			req.Header.Set(`Authorization`, `Bearer `+validToken)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			return rec
+		}
+
+		t.Run("Disabled", func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			srv := newServer()
+			defer srv.Close()
+
+			ar := jwk.NewAutoRefresh(ctx)
+			ar.Configure(srv.URL)
+			handler := jwthttp.NewVerifier(jwthttp.WithAutoRefresh(ar, srv.URL)).Middleware(next)
+			if !assert.Equal(t, http.StatusUnauthorized, serve(handler).Code, `status code should match`) {
+				return
+			}
+		})
+		t.Run("No wait", func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			srv := newServer()
+			defer srv.Close()
+
+			ar := jwk.NewAutoRefresh(ctx)
+			ar.Configure(srv.URL)
+			v := jwthttp.NewVerifier(
+				jwthttp.WithAutoRefresh(ar, srv.URL),
+				jwthttp.WithRefreshOnUnknownKeyID(0),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, `/`, nil)
+			req.Header.Set(`Authorization`, `Bearer `+validToken)
+			_, err := v.Verify(req)
+			var kerr *jwthttp.UnknownKeyIDError
+			if !assert.True(t, errors.As(err, &kerr), `error should be a *jwthttp.UnknownKeyIDError`) {
+				return
+			}
+			if !assert.Equal(t, `my-key`, kerr.KeyID, `key ID should match`) {
+				return
+			}
+
+			rec := serve(v.Middleware(next))
+			if !assert.Equal(t, http.StatusServiceUnavailable, rec.Code, `status code should match`) {
+				return
+			}
+			if !assert.NotEmpty(t, rec.Header().Get(`Retry-After`), `Retry-After header should be set`) {
+				return
+			}
+
+			// the refresh happens in the background
+			if !assert.Eventually(t, func() bool {
+				return serve(v.Middleware(next)).Code == http.StatusOK
+			}, 5*time.Second, 50*time.Millisecond, `token should be accepted after the refresh`) {
+				return
+			}
+		})
+		t.Run("Wait", func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			srv := newServer()
+			defer srv.Close()
+
+			ar := jwk.NewAutoRefresh(ctx)
+			ar.Configure(srv.URL)
+			handler := jwthttp.NewVerifier(
+				jwthttp.WithAutoRefresh(ar, srv.URL),
+				jwthttp.WithRefreshOnUnknownKeyID(5*time.Second),
+			).Middleware(next)
+			rec := serve(handler)
+			if !assert.Equal(t, http.StatusOK, rec.Code, `status code should match`) {
+				return
+			}
+			if !assert.Equal(t, `lestrrat`, rec.Body.String(), `body should match`) {
+				return
+			}
+		})
+	})
+	t.Run("No key", func(t *testing.T) {
+		t.Parallel()
+		handler := jwthttp.NewVerifier().Middleware(next)
+		req := httptest.NewRequest(http.MethodGet, `/`, nil)
+		req.Header.Set(`Authorization`, `Bearer `+validToken)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if !assert.Equal(t, http.StatusInternalServerError, rec.Code, `unverified tokens should never be accepted`) {
+			return
+		}
+	})
+	t.Run("ErrorHandler", func(t *testing.T) {
+		t.Parallel()
+		handler := jwthttp.NewVerifier(
+			jwthttp.WithKeySet(set),
+			jwthttp.WithErrorHandler(jwthttp.ErrorHandlerFunc(func(w http.ResponseWriter, _ *http.Request, status int, _ error) {
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{"error":"unauthorized"}`))
+			})),
+		).Middleware(next)
+		req := httptest.NewRequest(http.MethodGet, `/`, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if !assert.Equal(t, http.StatusUnauthorized, rec.Code, `status code should match`) {
+			return
+		}
+		if !assert.Equal(t, `Bearer`, rec.Header().Get(`WWW-Authenticate`), `WWW-Authenticate should match`) {
+			return
+		}
+		if !assert.Equal(t, `{"error":"unauthorized"}`, rec.Body.String(), `body should match`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package jwthttp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// VerifierOption describes an Option that can be passed to `jwthttp.NewVerifier()`
+type VerifierOption interface {
+	Option
+	verifierOption()
+}
+
+type verifierOption struct {
+	Option
+}
+
+func (*verifierOption) verifierOption() {}
+
+func newVerifierOption(n interface{}, v interface{}) VerifierOption {
+	return &verifierOption{option.New(n, v)}
+}
+
+type identAutoRefresh struct{}
+type identCookie struct{}
+type identErrorHandler struct{}
+type identHeader struct{}
+type identKeySet struct{}
+type identParseOptions struct{}
+type identQuery struct{}
+type identRealm struct{}
+type identRefreshOnUnknownKeyID struct{}
+type identVerify struct{}
+
+type autoRefreshParams struct {
+	ar  *jwk.AutoRefresh
+	url string
+}
+
+type verifyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+// ErrorHandler is called to write the response when a request is
+// rejected by the middleware. `status` is the HTTP status code that
+// would have been used by default.
+type ErrorHandler interface {
+	HandleError(w http.ResponseWriter, req *http.Request, status int, err error)
+}
+
+// ErrorHandlerFunc is a function that implements the ErrorHandler interface
+type ErrorHandlerFunc func(http.ResponseWriter, *http.Request, int, error)
+
+func (f ErrorHandlerFunc) HandleError(w http.ResponseWriter, req *http.Request, status int, err error) {
+	f(w, req, status, err)
+}
+
+// WithHeader specifies the name of a header from which the token is
+// extracted. For the "Authorization" header, only values using the
+// "Bearer" scheme are considered, as described in RFC 6750.
+//
+// If none of `jwthttp.WithHeader()`, `jwthttp.WithCookie()`, and
+// `jwthttp.WithQuery()` are specified, the token is extracted from the
+// "Authorization" header. Otherwise, only the specified locations are
+// searched.
+func WithHeader(name string) VerifierOption {
+	return newVerifierOption(identHeader{}, name)
+}
+
+// WithCookie specifies the name of a cookie from which the token is extracted
+func WithCookie(name string) VerifierOption {
+	return newVerifierOption(identCookie{}, name)
+}
+
+// WithQuery specifies the name of a query parameter from which the
+// token is extracted. Note that RFC 6750 discourages passing tokens
+// in the URL, as they are likely to be logged.
+func WithQuery(name string) VerifierOption {
+	return newVerifierOption(identQuery{}, name)
+}
+
+// WithKeySet specifies the key set that tokens are verified against.
+// See `jwt.WithKeySet()` for details.
+func WithKeySet(set jwk.Set) VerifierOption {
+	return newVerifierOption(identKeySet{}, set)
+}
+
+// WithAutoRefresh specifies that tokens are verified against the key set
+// at `url`, which is fetched using `ar`. The url must have been registered
+// using `(jwk.AutoRefresh).Configure()`.
+func WithAutoRefresh(ar *jwk.AutoRefresh, url string) VerifierOption {
+	return newVerifierOption(identAutoRefresh{}, &autoRefreshParams{ar: ar, url: url})
+}
+
+// WithVerify specifies the algorithm and the key that tokens are
+// verified against. See `jwt.WithVerify()` for details.
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) VerifierOption {
+	return newVerifierOption(identVerify{}, &verifyParams{alg: alg, key: key})
+}
+
+// WithParseOptions specifies extra options that are passed to
+// `jwt.Parse()`, such as `jwt.WithIssuer()` and `jwt.WithAudience()`.
+// Tokens are always validated, unless `jwt.WithValidate(false)` is
+// explicitly specified.
+func WithParseOptions(options ...jwt.ParseOption) VerifierOption {
+	return newVerifierOption(identParseOptions{}, options)
+}
+
+// WithRealm specifies the value of the "realm" attribute in the
+// WWW-Authenticate header of error responses.
+func WithRealm(s string) VerifierOption {
+	return newVerifierOption(identRealm{}, s)
+}
+
+// WithErrorHandler specifies the ErrorHandler that writes the response
+// when a request is rejected. The WWW-Authenticate header is set before
+// the handler is called.
+func WithErrorHandler(h ErrorHandler) VerifierOption {
+	return newVerifierOption(identErrorHandler{}, h)
+}
+
+// WithRefreshOnUnknownKeyID specifies how to handle tokens signed with
+// a key whose "kid" is not in the key set specified by
+// `jwthttp.WithAutoRefresh()`, as happens while the identity provider
+// rolls over its keys. By default, such tokens are rejected as invalid.
+//
+// When this option is specified, a refresh of the key set is scheduled
+// using `(jwk.AutoRefresh).TriggerRefresh()`, and if `wait` is positive,
+// the request is blocked for up to `wait` until a key set containing
+// the key arrives. If the key is still not available, the request is
+// rejected with a `*jwthttp.UnknownKeyIDError`, which results in a 503
+// response with a "Retry-After" header.
+//
+// To avoid flooding the identity provider, refreshes are triggered at
+// most once every few seconds per Verifier. This option has no effect
+// unless `jwthttp.WithAutoRefresh()` is specified.
+func WithRefreshOnUnknownKeyID(wait time.Duration) VerifierOption {
+	return newVerifierOption(identRefreshOnUnknownKeyID{}, wait)
+}
@@ -0,0 +1,193 @@
+package jwt
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
+	"github.com/lestrrat-go/jwx/jwt/internal/types"
+	"github.com/pkg/errors"
+)
+
+// HeaderInfo holds the values of the JOSE header of a token, as
+// reported by `jwt.Inspect()`. The values have NOT been verified.
+type HeaderInfo struct {
+	// Algorithm is the value of the "alg" header. For encrypted tokens,
+	// this is the key encryption algorithm.
+	Algorithm   string
+	KeyID       string
+	Type        string
+	ContentType string
+
+	// Encrypted is true if the token is a JWE, in which case the claims
+	// are not available.
+	Encrypted bool
+}
+
+// ClaimInfo holds the values of the registered claims of a token, as
+// reported by `jwt.Inspect()`. The values have NOT been verified, and
+// must never be used to make security decisions.
+type ClaimInfo struct {
+	Issuer     string
+	Subject    string
+	Audience   []string
+	JwtID      string
+	Expiration time.Time
+	IssuedAt   time.Time
+	NotBefore  time.Time
+}
+
+// Inspect decodes the JOSE header and the registered claims of a token
+// in compact serialization format, WITHOUT verifying its signature nor
+// validating its claims. It is intended for log scrubbing, request
+// routing, and metrics pipelines that need to know who issued a token
+// and when it expires, but do not act on its contents.
+//
+// Inspect only checks that the token is well-formed. The results are
+// deliberately not returned as a `jwt.Token`, so that they cannot be
+// passed to code that expects a verified token. If you need the claims
+// of a token to decide how to verify it, use `jwt.ParseInsecure()`
+// instead, and always verify the token using `jwt.Parse()` afterwards.
+//
+// For encrypted tokens, only the header is returned. If some of the
+// claims are malformed, the claims that could be decoded are returned
+// along with the error.
+func Inspect(buf []byte) (HeaderInfo, ClaimInfo, error) {
+	var hi HeaderInfo
+	var ci ClaimInfo
+
+	buf = bytes.TrimSpace(buf)
+	if err := limits.CheckInputSize(len(buf)); err != nil {
+		return hi, ci, newParseError(err)
+	}
+
+	parts := bytes.Split(buf, []byte{'.'})
+	switch len(parts) {
+	case 3:
+	case 5:
+		hi.Encrypted = true
+	default:
+		return hi, ci, newParseError(errors.New(`token is not in compact serialization format`))
+	}
+
+	if err := inspectHeader(parts[0], &hi); err != nil {
+		return hi, ci, newParseError(err)
+	}
+	if hi.Encrypted {
+		return hi, ci, nil
+	}
+
+	if err := inspectClaims(parts[1], &ci); err != nil {
+		return hi, ci, newParseError(err)
+	}
+	return hi, ci, nil
+}
+
+func inspectHeader(src []byte, hi *HeaderInfo) error {
+	decoded, err := base64.Decode(src)
+	if err != nil {
+		return errors.Wrap(err, `failed to decode header`)
+	}
+	if err := limits.CheckHeader(decoded); err != nil {
+		return errors.Wrap(err, `invalid header`)
+	}
+
+	var hdr struct {
+		Algorithm   string `json:"alg"`
+		KeyID       string `json:"kid"`
+		Type        string `json:"typ"`
+		ContentType string `json:"cty"`
+	}
+	if err := json.Unmarshal(decoded, &hdr); err != nil {
+		return errors.Wrap(err, `failed to unmarshal header`)
+	}
+	if hdr.Algorithm == "" {
+		return errors.New(`header does not contain "alg"`)
+	}
+
+	hi.Algorithm = hdr.Algorithm
+	hi.KeyID = hdr.KeyID
+	hi.Type = hdr.Type
+	hi.ContentType = hdr.ContentType
+	return nil
+}
+
+func inspectClaims(src []byte, ci *ClaimInfo) error {
+	decoded, err := base64.Decode(src)
+	if err != nil {
+		return errors.Wrap(err, `failed to decode payload`)
+	}
+	if err := limits.CheckDepth(decoded); err != nil {
+		return errors.Wrap(err, `invalid payload`)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(decoded, &claims); err != nil {
+		return errors.Wrap(err, `failed to unmarshal claims`)
+	}
+
+	// decode as many claims as possible, and report the first error
+	var firstErr error
+	setErr := func(name string, err error) {
+		if firstErr == nil {
+			firstErr = errors.Wrapf(err, `invalid %q claim`, name)
+		}
+	}
+
+	stringClaims := []struct {
+		name string
+		dst  *string
+	}{
+		{IssuerKey, &ci.Issuer},
+		{SubjectKey, &ci.Subject},
+		{JwtIDKey, &ci.JwtID},
+	}
+	for _, c := range stringClaims {
+		name, dst := c.name, c.dst
+		v, ok := claims[name]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			setErr(name, errors.Errorf(`expected string, got %T`, v))
+			continue
+		}
+		*dst = s
+	}
+
+	if v, ok := claims[AudienceKey]; ok {
+		var aud types.StringList
+		if err := aud.Accept(v); err != nil {
+			setErr(AudienceKey, err)
+		} else {
+			ci.Audience = aud.Get()
+		}
+	}
+
+	timeClaims := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{ExpirationKey, &ci.Expiration},
+		{IssuedAtKey, &ci.IssuedAt},
+		{NotBeforeKey, &ci.NotBefore},
+	}
+	for _, c := range timeClaims {
+		name, dst := c.name, c.dst
+		v, ok := claims[name]
+		if !ok {
+			continue
+		}
+		var t types.NumericDate
+		if err := t.Accept(v); err != nil {
+			setErr(name, err)
+			continue
+		}
+		*dst = t.Get()
+	}
+
+	return firstErr
+}
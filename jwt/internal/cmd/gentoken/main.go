@@ -364,6 +364,14 @@ func generateToken(tt tokenType) error {
 		fmt.Fprintf(&buf, "\n//\n// Embedding jwt.Token into another struct is not recommended, becase")
 		fmt.Fprintf(&buf, "\n// jwt.Token needs to handle private claims, and this really does not")
 		fmt.Fprintf(&buf, "\n// work well when it is embedded in other structure")
+		fmt.Fprintf(&buf, "\n//\n// `Iterate()` and `Walk()` visit the claims in lexicographical order of")
+		fmt.Fprintf(&buf, "\n// their names, so that the results are reproducible across runs.")
+		fmt.Fprintf(&buf, "\n//\n// `Remove()` deletes the claim (standard or private) from the token,")
+		fmt.Fprintf(&buf, "\n// which means that it will no longer be included when the token is")
+		fmt.Fprintf(&buf, "\n// serialized. Removing a claim that does not exist is not an error.")
+		fmt.Fprintf(&buf, "\n//\n// `Scopes()` returns the scopes granted to the token, taken from both the")
+		fmt.Fprintf(&buf, "\n// space-delimited \"scope\" claim (RFC 8693) and the \"scp\" claim, which some")
+		fmt.Fprintf(&buf, "\n// identity providers use to hold the scopes as an array.")
 	}
 
 	fmt.Fprintf(&buf, "\ntype %s interface {", tt.ifName)
@@ -374,6 +382,7 @@ func generateToken(tt tokenType) error {
 	fmt.Fprintf(&buf, "\nGet(string) (interface{}, bool)")
 	fmt.Fprintf(&buf, "\nSet(string, interface{}) error")
 	fmt.Fprintf(&buf, "\nRemove(string) error")
+	fmt.Fprintf(&buf, "\nScopes() []string")
 	if tt.pkg != "jwt" {
 		fmt.Fprintf(&buf, "\nClone() (jwt.Token, error)")
 	} else {
@@ -588,6 +597,9 @@ func generateToken(tt tokenType) error {
 	fmt.Fprintf(&buf, "\nfor k, v := range t.privateClaims {")
 	fmt.Fprintf(&buf, "\npairs = append(pairs, &ClaimPair{Key: k, Value: v})")
 	fmt.Fprintf(&buf, "\n}")
+	fmt.Fprintf(&buf, "\nsort.Slice(pairs, func(i, j int) bool {")
+	fmt.Fprintf(&buf, "\nreturn pairs[i].Key.(string) < pairs[j].Key.(string)")
+	fmt.Fprintf(&buf, "\n})")
 	fmt.Fprintf(&buf, "\nreturn pairs")
 	fmt.Fprintf(&buf, "\n}") // end of (h *stdHeaders) iterate(...)
 
@@ -598,7 +610,7 @@ func generateToken(tt tokenType) error {
 		fmt.Fprintf(&buf, "\nt.%s = nil", f.name)
 	}
 
-	fmt.Fprintf(&buf, "\ndec := json.NewDecoder(bytes.NewReader(buf))")
+	fmt.Fprintf(&buf, "\ndec := json.NewDecoderCtx(t.dc, bytes.NewReader(buf))")
 	fmt.Fprintf(&buf, "\nLOOP:")
 	fmt.Fprintf(&buf, "\nfor {")
 	fmt.Fprintf(&buf, "\ntok, err := dec.Token()")
@@ -727,7 +739,7 @@ func generateToken(tt tokenType) error {
 			}
 		}
 		fmt.Fprintf(&buf, ":")
-		fmt.Fprintf(&buf, "\nenc.Encode(data[f].(time.Time).Unix())")
+		fmt.Fprintf(&buf, "\nbuf.WriteString(types.FormatNumericDate(data[f].(time.Time)))")
 		fmt.Fprintf(&buf, "\ncontinue")
 	}
 	fmt.Fprintf(&buf, "\n}")
@@ -0,0 +1,126 @@
+// Package kidrefresh implements the machinery behind the
+// WithRefreshOnUnknownKeyID() options of jwthttp and jwtgrpc, which
+// refresh the key set when a token is signed with a key that it does
+// not contain
+package kidrefresh
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
+)
+
+// MinInterval is the minimum interval between refreshes triggered by
+// tokens with unknown key IDs, so that a stream of such tokens does
+// not flood the identity provider with requests
+const MinInterval = 5 * time.Second
+
+// ErrUnknownKeyID is returned by `(*Refresher).Await()` when the key
+// set does not contain the key ID, even after waiting for the refresh
+var ErrUnknownKeyID = errors.New(`key ID is not in the key set`)
+
+// Refresher triggers refreshes of a key set managed by a
+// jwk.AutoRefresh, and waits for them to complete
+type Refresher struct {
+	ar   *jwk.AutoRefresh
+	url  string
+	wait time.Duration
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+}
+
+// New creates a new Refresher for the key set at url. `wait` is the
+// maximum duration that Await waits for the refreshed key set
+func New(ar *jwk.AutoRefresh, url string, wait time.Duration) *Refresher {
+	return &Refresher{
+		ar:   ar,
+		url:  url,
+		wait: wait,
+	}
+}
+
+// UnknownKeyID returns the key ID of the token in src, if none of its
+// signatures has a key ID that can be found in set
+func UnknownKeyID(src []byte, set jwk.Set) (string, bool) {
+	msg, err := jws.Parse(src)
+	if err != nil {
+		return "", false
+	}
+
+	var kid string
+	for _, sig := range msg.Signatures() {
+		kid = sig.ProtectedHeaders().KeyID()
+		if kid == "" {
+			return "", false
+		}
+		if _, ok := set.LookupKeyID(kid); ok {
+			return "", false
+		}
+	}
+	return kid, kid != ""
+}
+
+// Await schedules a refresh of the key set, and waits for a key set
+// that contains kid. If the key set does not contain kid by the time
+// the wait is over, an error matching ErrUnknownKeyID is returned.
+// Other errors indicate that the key set could not be refreshed.
+func (r *Refresher) Await(ctx context.Context, kid string) (jwk.Set, error) {
+	// subscribe before triggering the refresh, so that the event
+	// is not missed
+	var events <-chan jwk.KeySetEvent
+	if r.wait > 0 {
+		events = r.ar.Subscribe(r.url)
+		defer r.ar.Unsubscribe(r.url, events)
+	}
+
+	if r.shouldRefresh() {
+		if err := r.ar.TriggerRefresh(ctx, r.url); err != nil {
+			return nil, errors.Wrap(err, `failed to trigger refresh of key set`)
+		}
+	}
+
+	if r.wait <= 0 {
+		return nil, ErrUnknownKeyID
+	}
+
+	timer := time.NewTimer(r.wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ErrUnknownKeyID
+		case <-timer.C:
+			return nil, ErrUnknownKeyID
+		case _, ok := <-events:
+			if !ok {
+				return nil, ErrUnknownKeyID
+			}
+			set, err := r.ar.Fetch(ctx, r.url)
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to fetch key set`)
+			}
+			if _, ok := set.LookupKeyID(kid); ok {
+				return set, nil
+			}
+		}
+	}
+}
+
+// shouldRefresh reports whether a refresh may be triggered for an
+// unknown key ID, and records the time if so
+func (r *Refresher) shouldRefresh() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if !r.lastRefresh.IsZero() && now.Sub(r.lastRefresh) < MinInterval {
+		return false
+	}
+	r.lastRefresh = now
+	return true
+}
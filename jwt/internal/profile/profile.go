@@ -0,0 +1,113 @@
+// Package profile contains the common logic used to parse JWTs that
+// follow a specific profile, such as JWT-secured authorization requests
+// and responses. Such profiles usually mandate a particular "typ" header
+// value and a restricted set of signature algorithms, both of which must
+// be checked before the claims are trusted.
+package profile
+
+import (
+	"strings"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+)
+
+// Config describes the expectations for a JWT profile
+type Config struct {
+	// Type is the expected value of the "typ" header. The comparison is
+	// case-insensitive, and the "application/" prefix may be omitted.
+	// If empty, the "typ" header is not checked
+	Type string
+
+	// AllowedAlgorithms is the list of signature algorithms that are
+	// accepted. If empty, any algorithm except for "none" is accepted
+	AllowedAlgorithms []jwa.SignatureAlgorithm
+
+	// DecryptAlgorithm and DecryptKey are used to decrypt the payload
+	// if it is encrypted. If DecryptKey is nil, encrypted payloads
+	// are rejected
+	DecryptAlgorithm jwa.KeyEncryptionAlgorithm
+	DecryptKey       interface{}
+
+	// ParseOptions are passed to jwt.Parse, and should contain
+	// the options to verify the signature
+	ParseOptions []jwt.ParseOption
+}
+
+// Parse decrypts the payload if it is encrypted, checks the protected
+// headers of the JWS message against the configuration, and finally
+// verifies and parses the token using jwt.Parse.
+//
+// The protected headers of the JWS message are returned along with the token.
+func Parse(data []byte, cfg *Config) (jwt.Token, jws.Headers, error) {
+	payload := data
+	if jwx.GuessFormat(payload) == jwx.JWE {
+		if cfg.DecryptKey == nil {
+			return nil, nil, errors.New(`payload is encrypted, but no decryption key was specified`)
+		}
+		decrypted, err := jwe.Decrypt(payload, cfg.DecryptAlgorithm, cfg.DecryptKey)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, `failed to decrypt payload`)
+		}
+		payload = decrypted
+	}
+
+	if jwx.GuessFormat(payload) != jwx.JWS {
+		return nil, nil, errors.New(`payload must be a signed JWT`)
+	}
+
+	msg, err := jws.Parse(payload)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to parse JWS message`)
+	}
+
+	sigs := msg.Signatures()
+	if len(sigs) != 1 {
+		return nil, nil, errors.Errorf(`expected exactly 1 signature, got %d`, len(sigs))
+	}
+	hdrs := sigs[0].ProtectedHeaders()
+
+	if err := checkAlgorithm(hdrs.Algorithm(), cfg.AllowedAlgorithms); err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Type != "" && !TypeMatches(hdrs.Type(), cfg.Type) {
+		return nil, nil, errors.Errorf(`invalid "typ" header: expected %q, got %q`, cfg.Type, hdrs.Type())
+	}
+
+	tok, err := jwt.Parse(payload, cfg.ParseOptions...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tok, hdrs, nil
+}
+
+// TypeMatches compares the value of a "typ" header against the expected
+// media type, as recommended in RFC 7515 section 4.1.9
+func TypeMatches(got, expected string) bool {
+	const prefix = `application/`
+	got = strings.ToLower(got)
+	expected = strings.ToLower(expected)
+	return strings.TrimPrefix(got, prefix) == strings.TrimPrefix(expected, prefix)
+}
+
+func checkAlgorithm(alg jwa.SignatureAlgorithm, allowed []jwa.SignatureAlgorithm) error {
+	if alg == jwa.NoSignature {
+		return errors.New(`"none" algorithm is not allowed`)
+	}
+
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	for _, v := range allowed {
+		if v == alg {
+			return nil
+		}
+	}
+	return errors.Errorf(`algorithm %q is not allowed`, alg)
+}
@@ -1,7 +1,10 @@
 package types
 
 import (
+	"bytes"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/lestrrat-go/jwx/internal/json"
@@ -9,6 +12,23 @@ import (
 	"github.com/pkg/errors"
 )
 
+const maxPrecision = 9 // nanoseconds
+
+// ParsePrecision is the number of fractional digits (0 to 9) that
+// are retained when a NumericDate is parsed. Digits beyond this
+// precision are truncated, unless Pedantic is set.
+var ParsePrecision uint32
+
+// FormatPrecision is the number of fractional digits (0 to 9) that
+// are emitted when a NumericDate is serialized. When 0, the value
+// is serialized as an integer.
+var FormatPrecision uint32
+
+// Pedantic is a flag to specify if we should reject NumericDate values
+// that carry more fractional digits than ParsePrecision, instead of
+// silently truncating them.
+var Pedantic uint32
+
 // NumericDate represents the date format used in the 'nbf' claim
 type NumericDate struct {
 	time.Time
@@ -21,7 +41,7 @@ func (n *NumericDate) Get() time.Time {
 	return n.Time
 }
 
-func numericToTime(v interface{}, t *time.Time) bool {
+func numericToTime(v interface{}, t *time.Time) (bool, error) {
 	var n int64
 	switch x := v.(type) {
 	case int64:
@@ -35,15 +55,54 @@ func numericToTime(v interface{}, t *time.Time) bool {
 	case int:
 		n = int64(x)
 	case float32:
-		n = int64(x)
+		return true, parseNumericString(strconv.FormatFloat(float64(x), 'f', -1, 32), t)
 	case float64:
-		n = int64(x)
+		return true, parseNumericString(strconv.FormatFloat(x, 'f', -1, 64), t)
 	default:
-		return false
+		return false, nil
 	}
 
 	*t = time.Unix(n, 0)
-	return true
+	return true, nil
+}
+
+// parseNumericString parses a string representation of a (possibly
+// fractional) number of seconds since the epoch, honoring the
+// ParsePrecision and Pedantic settings
+func parseNumericString(s string, t *time.Time) error {
+	intpart, fracpart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intpart, fracpart = s[:i], s[i+1:]
+	}
+
+	sec, err := strconv.ParseInt(intpart, 10, 64)
+	if err != nil {
+		return errors.Errorf(`invalid epoch value %#v`, s)
+	}
+
+	precision := int(atomic.LoadUint32(&ParsePrecision))
+	if len(fracpart) > precision {
+		if atomic.LoadUint32(&Pedantic) == 1 && strings.TrimRight(fracpart[precision:], "0") != "" {
+			return errors.Errorf(`epoch value %#v exceeds the allowed precision of %d fractional digits`, s, precision)
+		}
+		fracpart = fracpart[:precision]
+	}
+
+	var nsec int64
+	if len(fracpart) > 0 {
+		fracpart += strings.Repeat("0", maxPrecision-len(fracpart))
+		v, err := strconv.ParseInt(fracpart, 10, 64)
+		if err != nil || v < 0 {
+			return errors.Errorf(`invalid epoch value %#v`, s)
+		}
+		nsec = v
+		if strings.HasPrefix(intpart, "-") {
+			nsec = -nsec
+		}
+	}
+
+	*t = time.Unix(sec, nsec)
+	return nil
 }
 
 func (n *NumericDate) Accept(v interface{}) error {
@@ -51,41 +110,70 @@ func (n *NumericDate) Accept(v interface{}) error {
 
 	switch x := v.(type) {
 	case string:
-		i, err := strconv.ParseInt(x[:], 10, 64)
-		if err != nil {
-			return errors.Errorf(`invalid epoch value %#v`, x)
+		if err := parseNumericString(x, &t); err != nil {
+			return err
 		}
-		t = time.Unix(i, 0)
-
 	case json.Number:
-		intval, err := x.Int64()
-		if err != nil {
-			return errors.Wrapf(err, `failed to convert json value %#v to int64`, x)
+		if err := parseNumericString(x.String(), &t); err != nil {
+			return errors.Wrapf(err, `failed to convert json value %#v to time`, x)
 		}
-		t = time.Unix(intval, 0)
 	case time.Time:
 		t = x
 	default:
-		if !numericToTime(v, &t) {
+		ok, err := numericToTime(v, &t)
+		if !ok {
 			return errors.Errorf(`invalid type %T`, v)
 		}
+		if err != nil {
+			return err
+		}
 	}
 	n.Time = t.UTC()
 	return nil
 }
 
+// FormatNumericDate returns the JSON representation of t as a
+// NumericDate, honoring the FormatPrecision setting
+func FormatNumericDate(t time.Time) string {
+	precision := int(atomic.LoadUint32(&FormatPrecision))
+	sec := t.Unix()
+	if precision <= 0 {
+		return strconv.FormatInt(sec, 10)
+	}
+	if precision > maxPrecision {
+		precision = maxPrecision
+	}
+
+	nsec := int64(t.Nanosecond())
+	var sign string
+	if sec < 0 && nsec > 0 {
+		// e.g. -1.5 is represented as sec = -2, nsec = 500000000
+		sign = "-"
+		sec = -(sec + 1)
+		nsec = int64(time.Second) - nsec
+	}
+
+	frac := strconv.FormatInt(nsec, 10)
+	frac = strings.Repeat("0", maxPrecision-len(frac)) + frac
+	return sign + strconv.FormatInt(sec, 10) + "." + frac[:precision]
+}
+
 // MarshalJSON translates from internal representation to JSON NumericDate
 // See https://tools.ietf.org/html/rfc7519#page-6
 func (n *NumericDate) MarshalJSON() ([]byte, error) {
 	if n.IsZero() {
 		return json.Marshal(nil)
 	}
-	return json.Marshal(n.Unix())
+	return []byte(FormatNumericDate(n.Time)), nil
 }
 
 func (n *NumericDate) UnmarshalJSON(data []byte) error {
+	// Always use json.Number, so that we do not lose precision
+	// on fractional values by going through float64
 	var v interface{}
-	if err := json.Unmarshal(data, &v); err != nil {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
 		return errors.Wrap(err, `failed to unmarshal date`)
 	}
 
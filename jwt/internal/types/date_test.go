@@ -59,4 +59,83 @@ func TestDate(t *testing.T) {
 			})
 		}
 	})
+	t.Run("Fractional dates", func(t *testing.T) {
+		// These tests modify global settings, and therefore cannot
+		// be run in parallel with the other tests
+		defer jwt.Settings(
+			jwt.WithNumericDateParsePrecision(0),
+			jwt.WithNumericDateFormatPrecision(0),
+			jwt.WithNumericDateParsePedantic(false),
+		)
+
+		const src = `{"iat":1234567890.123456789}`
+		testcases := []struct {
+			Name      string
+			Parse     int
+			Format    int
+			Pedantic  bool
+			Error     bool
+			Expected  time.Time
+			Formatted string
+		}{
+			{
+				Name:      "defaults (truncate to seconds)",
+				Expected:  time.Unix(1234567890, 0).UTC(),
+				Formatted: `{"iat":1234567890}`,
+			},
+			{
+				Name:      "parse and format milliseconds",
+				Parse:     3,
+				Format:    3,
+				Expected:  time.Unix(1234567890, 123000000).UTC(),
+				Formatted: `{"iat":1234567890.123}`,
+			},
+			{
+				Name:      "parse nanoseconds, format microseconds",
+				Parse:     9,
+				Format:    6,
+				Expected:  time.Unix(1234567890, 123456789).UTC(),
+				Formatted: `{"iat":1234567890.123456}`,
+			},
+			{
+				Name:     "pedantic",
+				Parse:    3,
+				Pedantic: true,
+				Error:    true,
+			},
+		}
+
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				jwt.Settings(
+					jwt.WithNumericDateParsePrecision(tc.Parse),
+					jwt.WithNumericDateFormatPrecision(tc.Format),
+					jwt.WithNumericDateParsePedantic(tc.Pedantic),
+				)
+
+				tok := jwt.New()
+				err := json.Unmarshal([]byte(src), tok)
+				if tc.Error {
+					assert.Error(t, err, `json.Unmarshal should fail`)
+					return
+				}
+				if !assert.NoError(t, err, `json.Unmarshal should succeed`) {
+					return
+				}
+
+				if !assert.Equal(t, tc.Expected, tok.IssuedAt(), `values should match`) {
+					return
+				}
+
+				buf, err := json.Marshal(tok)
+				if !assert.NoError(t, err, `json.Marshal should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Formatted, string(buf), `serialized values should match`) {
+					return
+				}
+			})
+		}
+	})
 }
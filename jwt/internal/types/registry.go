@@ -0,0 +1,8 @@
+package types
+
+import "github.com/lestrrat-go/jwx/internal/json"
+
+// Registry holds the types of private claims registered via
+// jwt.RegisterCustomField. It lives here so that token types defined
+// in other packages (e.g. openid.Token) can fall back to it.
+var Registry = json.NewRegistry()
@@ -0,0 +1,135 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+)
+
+// Schema describes the claims of a token type, so that jwt.SchemaFor
+// can generate a JSON Schema for it. Token types defined in other
+// packages (e.g. openid.Token) register their own schema.
+type Schema struct {
+	// Claims holds the JSON Schema of each registered claim
+	Claims map[string]map[string]interface{}
+	// Registry holds the types of the custom claims
+	Registry *json.Registry
+}
+
+var muSchemas sync.RWMutex
+var schemas = make(map[reflect.Type]*Schema)
+
+// RegisterSchema associates the schema with the type of `token`
+func RegisterSchema(token interface{}, s *Schema) {
+	muSchemas.Lock()
+	defer muSchemas.Unlock()
+	schemas[reflect.TypeOf(token)] = s
+}
+
+// LookupSchema returns the schema associated with the type of `token`
+func LookupSchema(token interface{}) (*Schema, bool) {
+	muSchemas.RLock()
+	defer muSchemas.RUnlock()
+	s, ok := schemas[reflect.TypeOf(token)]
+	return s, ok
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	numericDateType = reflect.TypeOf(NumericDate{})
+	stringListType  = reflect.TypeOf(StringList{})
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// NumericDateSchema returns the JSON Schema of a NumericDate
+func NumericDateSchema() map[string]interface{} {
+	return map[string]interface{}{`type`: `number`}
+}
+
+// StringListSchema returns the JSON Schema of a StringList, which may
+// be a single string or an array of strings
+func StringListSchema() map[string]interface{} {
+	return map[string]interface{}{
+		`oneOf`: []interface{}{
+			map[string]interface{}{`type`: `string`},
+			map[string]interface{}{`type`: `array`, `items`: map[string]interface{}{`type`: `string`}},
+		},
+	}
+}
+
+// TypeSchema returns the JSON Schema of the JSON representation of
+// values of type `typ`. Types that implement json.Marshaler (other than
+// the ones known to this package) are not restricted, as their
+// representation cannot be determined.
+func TypeSchema(typ reflect.Type) map[string]interface{} {
+	return typeSchema(typ, make(map[reflect.Type]struct{}))
+}
+
+func typeSchema(typ reflect.Type, seen map[reflect.Type]struct{}) map[string]interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case timeType:
+		return map[string]interface{}{`type`: `string`, `format`: `date-time`}
+	case numericDateType:
+		return NumericDateSchema()
+	case stringListType:
+		return StringListSchema()
+	}
+
+	if typ.Implements(marshalerType) || reflect.PtrTo(typ).Implements(marshalerType) {
+		return map[string]interface{}{}
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return map[string]interface{}{`type`: `string`}
+	case reflect.Bool:
+		return map[string]interface{}{`type`: `boolean`}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{`type`: `integer`}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{`type`: `number`}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 && typ.Kind() == reflect.Slice {
+			return map[string]interface{}{`type`: `string`, `contentEncoding`: `base64`}
+		}
+		return map[string]interface{}{`type`: `array`, `items`: typeSchema(typ.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{`type`: `object`, `additionalProperties`: typeSchema(typ.Elem(), seen)}
+	case reflect.Struct:
+		// recursive types are not restricted beyond the first level
+		if _, ok := seen[typ]; ok {
+			return map[string]interface{}{`type`: `object`}
+		}
+		seen[typ] = struct{}{}
+		defer delete(seen, typ)
+
+		props := make(map[string]interface{})
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" || field.Anonymous {
+				continue
+			}
+			name := field.Name
+			if tag, ok := field.Tag.Lookup(`json`); ok {
+				if tag == `-` {
+					continue
+				}
+				if v := strings.Split(tag, `,`)[0]; v != "" {
+					name = v
+				}
+			}
+			props[name] = typeSchema(field.Type, seen)
+		}
+		return map[string]interface{}{`type`: `object`, `properties`: props}
+	default:
+		return map[string]interface{}{}
+	}
+}
@@ -0,0 +1,42 @@
+package types
+
+import "strings"
+
+// Scopes collects the scopes from the values of the "scope" and "scp"
+// claims. Strings are split on whitespace, and arrays are taken as is,
+// regardless of which claim they were found in, because identity
+// providers disagree on the format. Duplicates are removed, and the
+// scopes are returned in the order in which they first appear
+func Scopes(values ...interface{}) []string {
+	var scopes []string
+	seen := make(map[string]struct{})
+	add := func(s string) {
+		if _, ok := seen[s]; ok {
+			return
+		}
+		seen[s] = struct{}{}
+		scopes = append(scopes, s)
+	}
+
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			for _, s := range strings.Fields(v) {
+				add(s)
+			}
+		case []string:
+			for _, s := range v {
+				if s != "" {
+					add(s)
+				}
+			}
+		case []interface{}:
+			for _, e := range v {
+				if s, ok := e.(string); ok && s != "" {
+					add(s)
+				}
+			}
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"bytes"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/pkg/errors"
+)
+
+// Members of a token introspection response (RFC 7662) that are
+// not registered JWT claims
+const (
+	ActiveKey    = "active"
+	ClientIDKey  = "client_id"
+	ScopeKey     = "scope"
+	TokenTypeKey = "token_type"
+	UsernameKey  = "username"
+)
+
+// ErrTokenInactive is the cause of the `jwt.ValidationError` returned
+// by `jwt.ParseIntrospection()` when the authorization server reports
+// that the token is not active
+var ErrTokenInactive = errors.New(`token is not active`)
+
+// ParseIntrospection maps a token introspection response, as described
+// in RFC 7662, to a jwt.Token, so that opaque tokens that are validated
+// by the authorization server can be handled in the same way as JWTs
+// that are verified locally.
+//
+// The response must contain the "active" member. If the token is not
+// active, a `jwt.ValidationError` whose cause is `jwt.ErrTokenInactive`
+// is returned. Otherwise all other members, including "exp", "iat", "nbf"
+// and any extensions, become claims of the token. The "active" member
+// itself is not included.
+//
+// The response is not signed, so it must only be obtained from the
+// authorization server over an authenticated channel. Options related
+// to verification and decryption are ignored, but `jwt.WithToken()`,
+// `jwt.WithTypedClaim()`, `jwt.WithNumberFormat()`, the size limits,
+// as well as `jwt.WithValidate()` and the validation options are honored.
+func ParseIntrospection(src []byte, options ...ParseOption) (Token, error) {
+	ctx, err := newParseCtx(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.maxTokenSize > 0 && int64(len(src)) > ctx.maxTokenSize {
+		return nil, newParseError(errors.Errorf(`introspection response size exceeds maximum of %d bytes`, ctx.maxTokenSize))
+	}
+
+	var resp struct {
+		Active *bool `json:"active"`
+	}
+	if err := json.Unmarshal(src, &resp); err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to parse introspection response`))
+	}
+	if resp.Active == nil {
+		return nil, newParseError(errors.Errorf(`introspection response does not contain %q`, ActiveKey))
+	}
+	if !*resp.Active {
+		return nil, newValidationError(ActiveKey, ErrTokenInactive, `token is not active`)
+	}
+
+	tok, err := decodeToken(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if err := tok.Remove(ActiveKey); err != nil {
+		return nil, errors.Wrapf(err, `failed to remove %q`, ActiveKey)
+	}
+
+	if ctx.validate {
+		if err := Validate(tok, ctx.validateOpts...); err != nil {
+			return nil, err
+		}
+	}
+	return tok, nil
+}
+
+// MarshalIntrospection creates a token introspection response, as
+// described in RFC 7662, for an active token whose claims are those
+// of `t`. If `t` is nil, the response for an inactive token is created,
+// which does not contain any other members.
+//
+// The token is not validated: authorization servers must make sure that
+// the token is active (e.g. not expired or revoked, and presented by
+// a client that is allowed to see it) before calling this function.
+func MarshalIntrospection(t Token) ([]byte, error) {
+	if t == nil {
+		return []byte(`{"active":false}`), nil
+	}
+
+	// "active" is not a claim, and must not be overridden by one
+	if _, ok := t.Get(ActiveKey); ok {
+		cloned, err := t.Clone()
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to clone token`)
+		}
+		if err := cloned.Remove(ActiveKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to remove %q`, ActiveKey)
+		}
+		t = cloned
+	}
+
+	claims, err := json.Marshal(t)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal token`)
+	}
+	claims = bytes.TrimSpace(claims)
+	if len(claims) < 2 || claims[0] != '{' || claims[len(claims)-1] != '}' {
+		return nil, errors.New(`token did not marshal into a JSON object`)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"active":true`)
+	if body := bytes.TrimSpace(claims[1 : len(claims)-1]); len(body) > 0 {
+		buf.WriteByte(',')
+		buf.Write(body)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,167 @@
+// Package jar implements helpers to build and validate request objects
+// used in JWT-secured authorization requests (JAR), as described in
+// https://datatracker.ietf.org/doc/html/rfc9101
+//
+// Request objects are plain JWTs whose claims are the OAuth 2.0
+// authorization request parameters. Use `jwt.New()` to create the token,
+// set the parameters as claims, and pass it to `jar.Sign()`
+package jar
+
+import (
+	"context"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/profile"
+	"github.com/pkg/errors"
+)
+
+// MediaType is the value used in the "typ" header of request objects
+const MediaType = `oauth-authz-req+jwt`
+
+const (
+	ClientIDKey     = "client_id"
+	NonceKey        = "nonce"
+	RedirectURIKey  = "redirect_uri"
+	RequestKey      = "request"
+	RequestURIKey   = "request_uri"
+	ResponseTypeKey = "response_type"
+	ScopeKey        = "scope"
+	StateKey        = "state"
+)
+
+// Sign serializes the token as a signed request object. The "typ" header
+// is set to `oauth-authz-req+jwt`, and if `jar.WithEncrypt()` is specified,
+// the signed request object is then encrypted.
+//
+// The token must contain the "client_id" and "response_type" claims.
+// If the token contains the "iss" claim, it must be the same as the
+// "client_id" claim.
+func Sign(t jwt.Token, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	if alg == jwa.NoSignature {
+		return nil, errors.New(`request objects must be signed: "none" algorithm is not allowed`)
+	}
+
+	if err := checkParameters(t, nil); err != nil {
+		return nil, err
+	}
+
+	var hdrs jws.Headers
+	var encrypt *encryptParams
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identHeaders{}:
+			hdrs = option.Value().(jws.Headers)
+		case identEncrypt{}:
+			encrypt = option.Value().(*encryptParams)
+		}
+	}
+
+	// the headers given by the user must not be modified
+	h := jws.NewHeaders()
+	if hdrs != nil {
+		if err := hdrs.Copy(context.Background(), h); err != nil {
+			return nil, errors.Wrap(err, `failed to copy headers`)
+		}
+	}
+	if err := h.Set(jws.TypeKey, MediaType); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s header`, jws.TypeKey)
+	}
+
+	s := jwt.NewSerializer().Sign(alg, key, jwt.WithJwsHeaders(h))
+	if encrypt != nil {
+		s = s.Encrypt(encrypt.keyalg, encrypt.key, encrypt.contentalg, encrypt.compressalg)
+	}
+	return s.Serialize(t)
+}
+
+// Parse decrypts (if necessary) and verifies the request object, and
+// validates its contents.
+//
+// On top of the validation performed by `jwt.Validate()`, the following
+// are checked:
+//
+//   * the "typ" header is `oauth-authz-req+jwt`
+//   * the signature algorithm is not "none", and is one of the values
+//     specified via `jar.WithAllowedAlgorithms()`
+//   * the "client_id" and "response_type" claims, as well as those
+//     specified via `jar.WithRequiredParameters()`, exist
+//   * the "iss" claim, if present, is the same as "client_id"
+//   * the "request" and "request_uri" claims do not exist
+//
+// One of `jar.WithVerify()` or `jar.WithKeySet()` must be specified.
+func Parse(data []byte, options ...ParseOption) (jwt.Token, error) {
+	cfg := profile.Config{Type: MediaType}
+	parseOptions := []jwt.ParseOption{jwt.WithValidate(true)}
+	var clientID string
+	var required []string
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identVerify{}:
+			p := option.Value().(*verifyParams)
+			parseOptions = append(parseOptions, jwt.WithVerify(p.alg, p.key))
+		case identKeySet{}:
+			parseOptions = append(parseOptions, jwt.WithKeySet(option.Value().(jwk.Set)))
+		case identDecrypt{}:
+			p := option.Value().(*decryptParams)
+			cfg.DecryptAlgorithm = p.alg
+			cfg.DecryptKey = p.key
+		case identAllowedAlgorithms{}:
+			cfg.AllowedAlgorithms = option.Value().([]jwa.SignatureAlgorithm)
+		case identAudience{}:
+			parseOptions = append(parseOptions, jwt.WithAudience(option.Value().(string)))
+		case identClientID{}:
+			clientID = option.Value().(string)
+		case identRequiredParameters{}:
+			required = append(required, option.Value().([]string)...)
+		case identParseOptions{}:
+			parseOptions = append(parseOptions, option.Value().([]jwt.ParseOption)...)
+		}
+	}
+	cfg.ParseOptions = parseOptions
+
+	tok, _, err := profile.Parse(data, &cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse request object`)
+	}
+
+	if err := checkParameters(tok, required); err != nil {
+		return nil, err
+	}
+
+	if _, ok := tok.Get(RequestKey); ok {
+		return nil, errors.Errorf(`request object must not contain %q`, RequestKey)
+	}
+	if _, ok := tok.Get(RequestURIKey); ok {
+		return nil, errors.Errorf(`request object must not contain %q`, RequestURIKey)
+	}
+
+	if clientID != "" {
+		if v, _ := tok.Get(ClientIDKey); v != clientID {
+			return nil, errors.Errorf(`%s not satisfied`, ClientIDKey)
+		}
+	}
+	return tok, nil
+}
+
+func checkParameters(t jwt.Token, extra []string) error {
+	for _, name := range append([]string{ClientIDKey, ResponseTypeKey}, extra...) {
+		if _, ok := t.Get(name); !ok {
+			return errors.Errorf(`required parameter %q was not found`, name)
+		}
+	}
+
+	clientID, _ := t.Get(ClientIDKey)
+	if _, ok := clientID.(string); !ok {
+		return errors.Errorf(`invalid value for %q: expected string, got %T`, ClientIDKey, clientID)
+	}
+
+	if iss := t.Issuer(); iss != "" && iss != clientID {
+		return errors.Errorf(`%q claim must be the same as %q`, jwt.IssuerKey, ClientIDKey)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package jar_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/jar"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	clientID = `s6BhdRkqt3`
+	asIssuer = `https://server.example.com`
+)
+
+func newRequestObject() jwt.Token {
+	t := jwt.New()
+	t.Set(jwt.IssuerKey, clientID)
+	t.Set(jwt.AudienceKey, asIssuer)
+	t.Set(jwt.ExpirationKey, time.Now().Add(5*time.Minute))
+	t.Set(jar.ClientIDKey, clientID)
+	t.Set(jar.ResponseTypeKey, `code`)
+	t.Set(jar.RedirectURIKey, `https://client.example.org/cb`)
+	t.Set(jar.ScopeKey, `openid`)
+	t.Set(jar.StateKey, `af0ifjsldkj`)
+	return t
+}
+
+func TestJAR(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	encKey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	t.Run("Roundtrip", func(t *testing.T) {
+		t.Parallel()
+		signed, err := jar.Sign(newRequestObject(), jwa.PS256, key)
+		if !assert.NoError(t, err, `jar.Sign should succeed`) {
+			return
+		}
+
+		tok, err := jar.Parse(signed,
+			jar.WithVerify(jwa.PS256, &key.PublicKey),
+			jar.WithAllowedAlgorithms(jwa.PS256, jwa.ES256),
+			jar.WithAudience(asIssuer),
+			jar.WithClientID(clientID),
+			jar.WithRequiredParameters(jar.RedirectURIKey, jwt.ExpirationKey),
+		)
+		if !assert.NoError(t, err, `jar.Parse should succeed`) {
+			return
+		}
+		v, _ := tok.Get(jar.StateKey)
+		if !assert.Equal(t, `af0ifjsldkj`, v, `state should match`) {
+			return
+		}
+	})
+	t.Run("Headers", func(t *testing.T) {
+		t.Parallel()
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.KeyIDKey, `client-key`)
+		_ = hdrs.Set(jws.TypeKey, `JWT`)
+		signed, err := jar.Sign(newRequestObject(), jwa.PS256, key, jar.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jar.Sign should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `JWT`, hdrs.Type(), `headers passed by the user should not be modified`) {
+			return
+		}
+
+		msg, err := jws.Parse(signed)
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		protected := msg.Signatures()[0].ProtectedHeaders()
+		if !assert.Equal(t, jar.MediaType, protected.Type(), `"typ" should match`) {
+			return
+		}
+		if !assert.Equal(t, `client-key`, protected.KeyID(), `"kid" should match`) {
+			return
+		}
+	})
+	t.Run("Roundtrip (encrypted)", func(t *testing.T) {
+		t.Parallel()
+		signed, err := jar.Sign(newRequestObject(), jwa.PS256, key, jar.WithEncrypt(jwa.RSA_OAEP, &encKey.PublicKey, jwa.A256GCM, jwa.NoCompress))
+		if !assert.NoError(t, err, `jar.Sign should succeed`) {
+			return
+		}
+
+		_, err = jar.Parse(signed, jar.WithVerify(jwa.PS256, &key.PublicKey))
+		if !assert.Error(t, err, `jar.Parse should fail without decryption key`) {
+			return
+		}
+
+		_, err = jar.Parse(signed, jar.WithVerify(jwa.PS256, &key.PublicKey), jar.WithDecrypt(jwa.RSA_OAEP, encKey))
+		if !assert.NoError(t, err, `jar.Parse should succeed`) {
+			return
+		}
+	})
+	t.Run("Sign errors", func(t *testing.T) {
+		t.Parallel()
+		_, err := jar.Sign(newRequestObject(), jwa.NoSignature, nil)
+		if !assert.Error(t, err, `jar.Sign should fail for "none"`) {
+			return
+		}
+
+		tok := newRequestObject()
+		tok.Remove(jar.ResponseTypeKey)
+		_, err = jar.Sign(tok, jwa.PS256, key)
+		if !assert.Error(t, err, `jar.Sign should fail without response_type`) {
+			return
+		}
+
+		tok = newRequestObject()
+		tok.Set(jwt.IssuerKey, `someone-else`)
+		_, err = jar.Sign(tok, jwa.PS256, key)
+		if !assert.Error(t, err, `jar.Sign should fail when iss != client_id`) {
+			return
+		}
+	})
+	t.Run("Parse errors", func(t *testing.T) {
+		t.Parallel()
+		signed, err := jar.Sign(newRequestObject(), jwa.RS256, key)
+		if !assert.NoError(t, err, `jar.Sign should succeed`) {
+			return
+		}
+
+		testcases := []struct {
+			Name    string
+			Options []jar.ParseOption
+		}{
+			{Name: "algorithm not allowed", Options: []jar.ParseOption{jar.WithAllowedAlgorithms(jwa.PS256)}},
+			{Name: "wrong audience", Options: []jar.ParseOption{jar.WithAudience(`https://other.example.com`)}},
+			{Name: "wrong client_id", Options: []jar.ParseOption{jar.WithClientID(`other`)}},
+			{Name: "missing parameter", Options: []jar.ParseOption{jar.WithRequiredParameters(jar.NonceKey)}},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := jar.Parse(signed, append(tc.Options, jar.WithVerify(jwa.RS256, &key.PublicKey))...)
+				assert.Error(t, err, `jar.Parse should fail`)
+			})
+		}
+
+		// A plain JWT (typ: JWT) is not a request object
+		plain, err := jwt.Sign(newRequestObject(), jwa.RS256, key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+		_, err = jar.Parse(plain, jar.WithVerify(jwa.RS256, &key.PublicKey))
+		if !assert.Error(t, err, `jar.Parse should fail for wrong typ`) {
+			return
+		}
+	})
+}
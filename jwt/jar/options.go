@@ -0,0 +1,134 @@
+package jar
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// SignOption describes an Option that can be passed to `jar.Sign()`
+type SignOption interface {
+	Option
+	signOption()
+}
+
+type signOption struct {
+	Option
+}
+
+func (*signOption) signOption() {}
+
+// ParseOption describes an Option that can be passed to `jar.Parse()`
+type ParseOption interface {
+	Option
+	parseOption()
+}
+
+type parseOption struct {
+	Option
+}
+
+func (*parseOption) parseOption() {}
+
+type identAllowedAlgorithms struct{}
+type identAudience struct{}
+type identClientID struct{}
+type identDecrypt struct{}
+type identEncrypt struct{}
+type identHeaders struct{}
+type identKeySet struct{}
+type identParseOptions struct{}
+type identRequiredParameters struct{}
+type identVerify struct{}
+
+type encryptParams struct {
+	keyalg      jwa.KeyEncryptionAlgorithm
+	key         interface{}
+	contentalg  jwa.ContentEncryptionAlgorithm
+	compressalg jwa.CompressionAlgorithm
+}
+
+type verifyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+type decryptParams struct {
+	alg jwa.KeyEncryptionAlgorithm
+	key interface{}
+}
+
+// WithHeaders specifies extra header values to be included in the
+// protected header of the JWS message. The "typ" header is always
+// overwritten with `oauth-authz-req+jwt`
+func WithHeaders(hdrs jws.Headers) SignOption {
+	return &signOption{option.New(identHeaders{}, hdrs)}
+}
+
+// WithEncrypt specifies that the signed request object should
+// be encrypted using the given parameters
+func WithEncrypt(keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm) SignOption {
+	return &signOption{option.New(identEncrypt{}, &encryptParams{
+		keyalg:      keyalg,
+		key:         key,
+		contentalg:  contentalg,
+		compressalg: compressalg,
+	})}
+}
+
+// WithVerify specifies the algorithm and the key used to verify
+// the request object
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identVerify{}, &verifyParams{alg: alg, key: key})}
+}
+
+// WithKeySet specifies the key set from which the key to verify the
+// request object is chosen. See `jwt.WithKeySet()` for details
+func WithKeySet(set jwk.Set) ParseOption {
+	return &parseOption{option.New(identKeySet{}, set)}
+}
+
+// WithDecrypt specifies the algorithm and the key used to decrypt
+// the request object. If the request object is encrypted and this
+// option is not specified, `jar.Parse()` returns an error
+func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identDecrypt{}, &decryptParams{alg: alg, key: key})}
+}
+
+// WithAllowedAlgorithms specifies the signature algorithms that are
+// accepted. By default any algorithm except for "none" is accepted.
+// For example, FAPI compliant authorization servers would specify
+// `jar.WithAllowedAlgorithms(jwa.PS256, jwa.ES256)`
+func WithAllowedAlgorithms(algs ...jwa.SignatureAlgorithm) ParseOption {
+	return &parseOption{option.New(identAllowedAlgorithms{}, algs)}
+}
+
+// WithAudience specifies the expected value in the "aud" claim, which
+// should be the issuer identifier of the authorization server
+func WithAudience(s string) ParseOption {
+	return &parseOption{option.New(identAudience{}, s)}
+}
+
+// WithClientID specifies the expected value of the "client_id" claim
+func WithClientID(s string) ParseOption {
+	return &parseOption{option.New(identClientID{}, s)}
+}
+
+// WithRequiredParameters specifies claims that must be present in the
+// request object, in addition to "client_id" and "response_type".
+// For example, FAPI profiles require "redirect_uri", "scope", "nonce",
+// "exp" and "nbf"
+func WithRequiredParameters(names ...string) ParseOption {
+	return &parseOption{option.New(identRequiredParameters{}, names)}
+}
+
+// WithParseOptions specifies extra options that are passed to
+// `jwt.Parse()`, for example `jwt.WithClock()`, `jwt.WithAcceptableSkew()`
+// or `jwt.WithMaxDelta()`
+func WithParseOptions(options ...jwt.ParseOption) ParseOption {
+	return &parseOption{option.New(identParseOptions{}, options)}
+}
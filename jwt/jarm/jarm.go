@@ -0,0 +1,193 @@
+// Package jarm implements helpers to build and validate authorization
+// responses in the JWT Secured Authorization Response Mode (JARM), as
+// described in https://openid.net/specs/oauth-v2-jarm.html
+//
+// Authorization servers use `jarm.New()` and `jarm.Sign()` to create
+// the response JWT, and clients use `jarm.Parse()` to verify it and
+// obtain the response parameters.
+package jarm
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/profile"
+	"github.com/pkg/errors"
+)
+
+// DefaultLifetime is the default lifetime of response JWTs. The
+// specification recommends that response JWTs be short-lived.
+const DefaultLifetime = 10 * time.Minute
+
+const (
+	CodeKey             = "code"
+	ErrorKey            = "error"
+	ErrorDescriptionKey = "error_description"
+	ErrorURIKey         = "error_uri"
+	StateKey            = "state"
+
+	// ResponseKey is the name of the parameter used to
+	// transport the response JWT to the client
+	ResponseKey = "response"
+)
+
+// New creates a new response JWT issued by the authorization server
+// `issuer` for the client `clientID`, containing the given authorization
+// response parameters (e.g. "code" and "state", or "error" and "state").
+//
+// The "exp" claim is set according to `jarm.WithLifetime()`.
+func New(issuer, clientID string, params url.Values, options ...NewOption) (jwt.Token, error) {
+	if issuer == "" {
+		return nil, errors.New(`issuer must not be empty`)
+	}
+	if clientID == "" {
+		return nil, errors.New(`client ID must not be empty`)
+	}
+
+	lifetime := DefaultLifetime
+	var clock jwt.Clock = jwt.ClockFunc(time.Now)
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identLifetime{}:
+			lifetime = option.Value().(time.Duration)
+		case identClock{}:
+			clock = option.Value().(jwt.Clock)
+		}
+	}
+
+	if lifetime <= 0 {
+		return nil, errors.New(`lifetime must be positive`)
+	}
+
+	t := jwt.New()
+	for name, values := range params {
+		if len(values) == 0 {
+			continue
+		}
+		switch name {
+		case jwt.IssuerKey, jwt.AudienceKey, jwt.ExpirationKey:
+			return nil, errors.Errorf(`response parameter %q conflicts with a reserved claim`, name)
+		}
+		if err := t.Set(name, values[0]); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, name)
+		}
+	}
+
+	if err := t.Set(jwt.IssuerKey, issuer); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %q`, jwt.IssuerKey)
+	}
+	if err := t.Set(jwt.AudienceKey, clientID); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %q`, jwt.AudienceKey)
+	}
+	if err := t.Set(jwt.ExpirationKey, clock.Now().Add(lifetime)); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %q`, jwt.ExpirationKey)
+	}
+	return t, nil
+}
+
+// Sign serializes the response JWT, and optionally encrypts it
+// if `jarm.WithEncrypt()` is specified.
+//
+// The token must contain the "iss", "aud", and "exp" claims.
+func Sign(t jwt.Token, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	if alg == jwa.NoSignature {
+		return nil, errors.New(`response JWTs must be signed: "none" algorithm is not allowed`)
+	}
+
+	for _, name := range []string{jwt.IssuerKey, jwt.AudienceKey, jwt.ExpirationKey} {
+		if _, ok := t.Get(name); !ok {
+			return nil, errors.Errorf(`required claim %q was not found`, name)
+		}
+	}
+
+	var encrypt *encryptParams
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identEncrypt{}:
+			encrypt = option.Value().(*encryptParams)
+		}
+	}
+
+	s := jwt.NewSerializer().Sign(alg, key)
+	if encrypt != nil {
+		s = s.Encrypt(encrypt.keyalg, encrypt.key, encrypt.contentalg, encrypt.compressalg)
+	}
+	return s.Serialize(t)
+}
+
+// Parse decrypts (if necessary) and verifies the response JWT, and
+// validates its contents: the "iss" claim must match the value given by
+// `jarm.WithIssuer()`, the "aud" claim must contain the value given by
+// `jarm.WithClientID()`, and the "exp" claim must exist and must not
+// have passed.
+//
+// One of `jarm.WithVerify()` or `jarm.WithKeySet()` must be specified.
+func Parse(data []byte, options ...ParseOption) (jwt.Token, error) {
+	var cfg profile.Config
+	parseOptions := []jwt.ParseOption{
+		jwt.WithValidate(true),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+	}
+	var issuer, clientID, state string
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identVerify{}:
+			p := option.Value().(*verifyParams)
+			parseOptions = append(parseOptions, jwt.WithVerify(p.alg, p.key))
+		case identKeySet{}:
+			parseOptions = append(parseOptions, jwt.WithKeySet(option.Value().(jwk.Set)))
+		case identDecrypt{}:
+			p := option.Value().(*decryptParams)
+			cfg.DecryptAlgorithm = p.alg
+			cfg.DecryptKey = p.key
+		case identAllowedAlgorithms{}:
+			cfg.AllowedAlgorithms = option.Value().([]jwa.SignatureAlgorithm)
+		case identIssuer{}:
+			issuer = option.Value().(string)
+		case identClientID{}:
+			clientID = option.Value().(string)
+		case identState{}:
+			state = option.Value().(string)
+		case identParseOptions{}:
+			parseOptions = append(parseOptions, option.Value().([]jwt.ParseOption)...)
+		}
+	}
+
+	if issuer == "" {
+		return nil, errors.New(`jarm.WithIssuer() must be specified`)
+	}
+	if clientID == "" {
+		return nil, errors.New(`jarm.WithClientID() must be specified`)
+	}
+
+	parseOptions = append(parseOptions, jwt.WithIssuer(issuer), jwt.WithAudience(clientID))
+	if state != "" {
+		parseOptions = append(parseOptions, jwt.WithClaimValue(StateKey, state))
+	}
+	cfg.ParseOptions = parseOptions
+
+	tok, _, err := profile.Parse(data, &cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse response JWT`)
+	}
+	return tok, nil
+}
+
+// Parameters extracts the authorization response parameters (i.e.
+// claims other than "iss", "aud", and "exp") from the response JWT.
+// Values that are not strings are ignored.
+func Parameters(t jwt.Token) url.Values {
+	params := url.Values{}
+	for name, value := range t.PrivateClaims() {
+		if s, ok := value.(string); ok {
+			params.Set(name, s)
+		}
+	}
+	return params
+}
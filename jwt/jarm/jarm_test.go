@@ -0,0 +1,202 @@
+package jarm_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/jarm"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	clientID = `s6BhdRkqt3`
+	asIssuer = `https://accounts.example.com`
+)
+
+func TestJARM(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	encKey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	params := url.Values{}
+	params.Set(jarm.CodeKey, `PyyFaux2o7Q0YfXBU32jhw.5FXSQpvr8akv9CeRDSd0QA`)
+	params.Set(jarm.StateKey, `S8NJ7uqk5fY4EjNvP_G_FtyJu6pUsvH9jsYni9dMAJw`)
+
+	t.Run("New", func(t *testing.T) {
+		t.Parallel()
+		now := time.Unix(time.Now().Unix(), 0).UTC()
+		tok, err := jarm.New(asIssuer, clientID, params, jarm.WithLifetime(time.Minute), jarm.WithClock(jwt.ClockFunc(func() time.Time { return now })))
+		if !assert.NoError(t, err, `jarm.New should succeed`) {
+			return
+		}
+		if !assert.Equal(t, asIssuer, tok.Issuer(), `iss should match`) {
+			return
+		}
+		if !assert.Equal(t, []string{clientID}, tok.Audience(), `aud should match`) {
+			return
+		}
+		if !assert.Equal(t, now.Add(time.Minute), tok.Expiration(), `exp should match`) {
+			return
+		}
+		if !assert.Equal(t, params, jarm.Parameters(tok), `parameters should match`) {
+			return
+		}
+
+		bad := url.Values{}
+		bad.Set(jwt.IssuerKey, `https://attacker.example.com`)
+		_, err = jarm.New(asIssuer, clientID, bad)
+		if !assert.Error(t, err, `jarm.New should fail`) {
+			return
+		}
+	})
+	t.Run("Roundtrip", func(t *testing.T) {
+		t.Parallel()
+		tok, err := jarm.New(asIssuer, clientID, params)
+		if !assert.NoError(t, err, `jarm.New should succeed`) {
+			return
+		}
+
+		signed, err := jarm.Sign(tok, jwa.RS256, key)
+		if !assert.NoError(t, err, `jarm.Sign should succeed`) {
+			return
+		}
+
+		pubkey, err := jwk.New(&key.PublicKey)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return
+		}
+		pubkey.Set(jwk.KeyIDKey, `as-key`)
+		pubkey.Set(jwk.AlgorithmKey, jwa.RS256)
+		set := jwk.NewSet()
+		set.Add(pubkey)
+
+		_, err = jarm.Parse(signed, jarm.WithKeySet(set), jarm.WithIssuer(asIssuer), jarm.WithClientID(clientID))
+		if !assert.Error(t, err, `jarm.Parse should fail without a "kid" in the header`) {
+			return
+		}
+
+		parsed, err := jarm.Parse(signed,
+			jarm.WithVerify(jwa.RS256, &key.PublicKey),
+			jarm.WithIssuer(asIssuer),
+			jarm.WithClientID(clientID),
+			jarm.WithState(params.Get(jarm.StateKey)),
+		)
+		if !assert.NoError(t, err, `jarm.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, params, jarm.Parameters(parsed), `parameters should match`) {
+			return
+		}
+	})
+	t.Run("Roundtrip (encrypted)", func(t *testing.T) {
+		t.Parallel()
+		tok, err := jarm.New(asIssuer, clientID, params)
+		if !assert.NoError(t, err, `jarm.New should succeed`) {
+			return
+		}
+
+		signed, err := jarm.Sign(tok, jwa.RS256, key, jarm.WithEncrypt(jwa.RSA_OAEP, &encKey.PublicKey, jwa.A256GCM, jwa.NoCompress))
+		if !assert.NoError(t, err, `jarm.Sign should succeed`) {
+			return
+		}
+
+		_, err = jarm.Parse(signed, jarm.WithVerify(jwa.RS256, &key.PublicKey), jarm.WithIssuer(asIssuer), jarm.WithClientID(clientID))
+		if !assert.Error(t, err, `jarm.Parse should fail without decryption key`) {
+			return
+		}
+
+		_, err = jarm.Parse(signed, jarm.WithVerify(jwa.RS256, &key.PublicKey), jarm.WithDecrypt(jwa.RSA_OAEP, encKey), jarm.WithIssuer(asIssuer), jarm.WithClientID(clientID))
+		if !assert.NoError(t, err, `jarm.Parse should succeed`) {
+			return
+		}
+	})
+	t.Run("Validation errors", func(t *testing.T) {
+		t.Parallel()
+		tok, err := jarm.New(asIssuer, clientID, params)
+		if !assert.NoError(t, err, `jarm.New should succeed`) {
+			return
+		}
+		signed, err := jarm.Sign(tok, jwa.RS256, key)
+		if !assert.NoError(t, err, `jarm.Sign should succeed`) {
+			return
+		}
+
+		testcases := []struct {
+			Name    string
+			Options []jarm.ParseOption
+		}{
+			{
+				Name:    "missing issuer",
+				Options: []jarm.ParseOption{jarm.WithClientID(clientID)},
+			},
+			{
+				Name:    "missing client ID",
+				Options: []jarm.ParseOption{jarm.WithIssuer(asIssuer)},
+			},
+			{
+				Name:    "wrong issuer",
+				Options: []jarm.ParseOption{jarm.WithIssuer(`https://attacker.example.com`), jarm.WithClientID(clientID)},
+			},
+			{
+				Name:    "wrong audience",
+				Options: []jarm.ParseOption{jarm.WithIssuer(asIssuer), jarm.WithClientID(`other-client`)},
+			},
+			{
+				Name:    "wrong state",
+				Options: []jarm.ParseOption{jarm.WithIssuer(asIssuer), jarm.WithClientID(clientID), jarm.WithState(`xxx`)},
+			},
+			{
+				Name:    "disallowed algorithm",
+				Options: []jarm.ParseOption{jarm.WithIssuer(asIssuer), jarm.WithClientID(clientID), jarm.WithAllowedAlgorithms(jwa.ES256)},
+			},
+			{
+				Name: "expired",
+				Options: []jarm.ParseOption{
+					jarm.WithIssuer(asIssuer),
+					jarm.WithClientID(clientID),
+					jarm.WithParseOptions(jwt.WithClock(jwt.ClockFunc(func() time.Time { return time.Now().Add(time.Hour) }))),
+				},
+			},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				options := append([]jarm.ParseOption{jarm.WithVerify(jwa.RS256, &key.PublicKey)}, tc.Options...)
+				_, err := jarm.Parse(signed, options...)
+				if !assert.Error(t, err, `jarm.Parse should fail`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Sign errors", func(t *testing.T) {
+		t.Parallel()
+		tok := jwt.New()
+		tok.Set(jarm.CodeKey, `code`)
+		_, err := jarm.Sign(tok, jwa.RS256, key)
+		if !assert.Error(t, err, `jarm.Sign should fail without iss/aud/exp`) {
+			return
+		}
+
+		tok, err = jarm.New(asIssuer, clientID, params)
+		if !assert.NoError(t, err, `jarm.New should succeed`) {
+			return
+		}
+		_, err = jarm.Sign(tok, jwa.NoSignature, nil)
+		if !assert.Error(t, err, `jarm.Sign should fail with "none"`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package jarm
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// NewOption describes an Option that can be passed to `jarm.New()`
+type NewOption interface {
+	Option
+	newOption()
+}
+
+type newOption struct {
+	Option
+}
+
+func (*newOption) newOption() {}
+
+// SignOption describes an Option that can be passed to `jarm.Sign()`
+type SignOption interface {
+	Option
+	signOption()
+}
+
+type signOption struct {
+	Option
+}
+
+func (*signOption) signOption() {}
+
+// ParseOption describes an Option that can be passed to `jarm.Parse()`
+type ParseOption interface {
+	Option
+	parseOption()
+}
+
+type parseOption struct {
+	Option
+}
+
+func (*parseOption) parseOption() {}
+
+type identAllowedAlgorithms struct{}
+type identClientID struct{}
+type identClock struct{}
+type identDecrypt struct{}
+type identEncrypt struct{}
+type identIssuer struct{}
+type identKeySet struct{}
+type identLifetime struct{}
+type identParseOptions struct{}
+type identState struct{}
+type identVerify struct{}
+
+type encryptParams struct {
+	keyalg      jwa.KeyEncryptionAlgorithm
+	key         interface{}
+	contentalg  jwa.ContentEncryptionAlgorithm
+	compressalg jwa.CompressionAlgorithm
+}
+
+type verifyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+type decryptParams struct {
+	alg jwa.KeyEncryptionAlgorithm
+	key interface{}
+}
+
+// WithLifetime specifies the lifetime of the response JWT, which is used
+// to compute the value of the "exp" claim. The default is `DefaultLifetime`
+func WithLifetime(d time.Duration) NewOption {
+	return &newOption{option.New(identLifetime{}, d)}
+}
+
+// WithClock specifies the `jwt.Clock` used to compute the value of
+// the "exp" claim
+func WithClock(c jwt.Clock) NewOption {
+	return &newOption{option.New(identClock{}, c)}
+}
+
+// WithEncrypt specifies that the signed response JWT should
+// be encrypted using the given parameters
+func WithEncrypt(keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm) SignOption {
+	return &signOption{option.New(identEncrypt{}, &encryptParams{
+		keyalg:      keyalg,
+		key:         key,
+		contentalg:  contentalg,
+		compressalg: compressalg,
+	})}
+}
+
+// WithVerify specifies the algorithm and the key used to verify
+// the response JWT
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identVerify{}, &verifyParams{alg: alg, key: key})}
+}
+
+// WithKeySet specifies the key set of the authorization server, from
+// which the key to verify the response JWT is chosen.
+// See `jwt.WithKeySet()` for details
+func WithKeySet(set jwk.Set) ParseOption {
+	return &parseOption{option.New(identKeySet{}, set)}
+}
+
+// WithDecrypt specifies the algorithm and the key used to decrypt
+// the response JWT. If the response JWT is encrypted and this
+// option is not specified, `jarm.Parse()` returns an error
+func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identDecrypt{}, &decryptParams{alg: alg, key: key})}
+}
+
+// WithAllowedAlgorithms specifies the signature algorithms that are
+// accepted. By default any algorithm except for "none" is accepted.
+func WithAllowedAlgorithms(algs ...jwa.SignatureAlgorithm) ParseOption {
+	return &parseOption{option.New(identAllowedAlgorithms{}, algs)}
+}
+
+// WithIssuer specifies the issuer identifier of the authorization
+// server, which must match the "iss" claim. This option is required
+func WithIssuer(s string) ParseOption {
+	return &parseOption{option.New(identIssuer{}, s)}
+}
+
+// WithClientID specifies the client identifier, which must be
+// contained in the "aud" claim. This option is required
+func WithClientID(s string) ParseOption {
+	return &parseOption{option.New(identClientID{}, s)}
+}
+
+// WithState specifies the expected value of the "state" claim
+func WithState(s string) ParseOption {
+	return &parseOption{option.New(identState{}, s)}
+}
+
+// WithParseOptions specifies extra options that are passed to
+// `jwt.Parse()`, for example `jwt.WithClock()` or `jwt.WithAcceptableSkew()`
+func WithParseOptions(options ...jwt.ParseOption) ParseOption {
+	return &parseOption{option.New(identParseOptions{}, options)}
+}
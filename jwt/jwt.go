@@ -8,45 +8,70 @@ import (
 	"context"
 	"io"
 	"io/ioutil"
+	"math/big"
+	"reflect"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
 	"github.com/lestrrat-go/jwx/jwe"
 
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt/internal/types"
 	"github.com/pkg/errors"
 )
 
 const _jwt = `jwt`
 
 // Settings controls global settings that are specific to JWTs.
+//
+// For backwards compatibility, audience flattening is turned off unless
+// `jwt.WithFlattenAudience(true)` is specified: calling `Settings()`
+// without that option resets it. The NumericDate settings are only
+// changed when they are explicitly specified.
 func Settings(options ...GlobalOption) {
-	var flattenAudienceBool bool
-
+	var flattenAudience uint32
 	//nolint:forcetypeassert
 	for _, option := range options {
 		switch option.Ident() {
 		case identFlattenAudience{}:
-			flattenAudienceBool = option.Value().(bool)
+			flattenAudience = 0
+			if option.Value().(bool) {
+				flattenAudience = 1
+			}
+		case identNumericDateParsePrecision{}:
+			atomic.StoreUint32(&types.ParsePrecision, clampPrecision(option.Value().(int)))
+		case identNumericDateFormatPrecision{}:
+			atomic.StoreUint32(&types.FormatPrecision, clampPrecision(option.Value().(int)))
+		case identNumericDateParsePedantic{}:
+			var v uint32
+			if option.Value().(bool) {
+				v = 1
+			}
+			atomic.StoreUint32(&types.Pedantic, v)
 		}
 	}
+	atomic.StoreUint32(&json.FlattenAudience, flattenAudience)
+}
 
-	v := atomic.LoadUint32(&json.FlattenAudience)
-	if (v == 1) != flattenAudienceBool {
-		var newVal uint32
-		if flattenAudienceBool {
-			newVal = 1
-		}
-		atomic.CompareAndSwapUint32(&json.FlattenAudience, v, newVal)
+func clampPrecision(v int) uint32 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 9:
+		return 9
 	}
+	return uint32(v)
 }
 
-var registry = json.NewRegistry()
+var registry = types.Registry
 
 // ParseString calls Parse against a string
 func ParseString(s string, options ...ParseOption) (Token, error) {
@@ -61,9 +86,12 @@ func ParseString(s string, options ...ParseOption) (Token, error) {
 // attempt to verify/decrypt up to 2 levels (i.e. JWS only, JWE only, JWS then
 // JWE, or JWE then JWS)
 //
-// If the token is signed and you want to verify the payload matches the signature,
-// you must pass the jwt.WithVerify(alg, key) or jwt.WithKeySet(jwk.Set) option.
-// If you do not specify these parameters, no verification will be performed.
+// The token MUST be signed, and you MUST pass either the jwt.WithVerify(alg, key)
+// or the jwt.WithKeySet(jwk.Set) option so that the signature can be verified.
+// If you do not specify these parameters, or if the token does not contain
+// a signature that can be verified, an error is returned. If you really
+// need to decode the claims without verifying the signature, use
+// `jwt.ParseInsecure()`.
 //
 // If you also want to assert the validity of the JWT itself (i.e. expiration
 // and such), use the `Validate()` function on the returned token, or pass the
@@ -77,8 +105,43 @@ func Parse(s []byte, options ...ParseOption) (Token, error) {
 	return parseBytes(s, options...)
 }
 
+// ParseInsecure parses the JWT token payload and creates a new `jwt.Token`
+// object, WITHOUT verifying the signature of the token. This is the only
+// function that allows you to obtain the claims without verification,
+// and it should only be used when you know what you are doing: for example,
+// to peek at the "iss" claim in order to decide which key to use, before
+// calling `jwt.Parse()` on the same payload.
+//
+// Options that control verification (jwt.WithVerify, jwt.WithKeySet) are
+// ignored. Decryption parameters and other options are respected.
+func ParseInsecure(s []byte, options ...ParseOption) (Token, error) {
+	return parseBytes(s, append(options, newParseOption(identInsecure{}, true))...)
+}
+
+// ParseContext is the same as Parse, but accepts a context.Context,
+// which is passed on to `jws.VerifyContext()` and `jwe.DecryptContext()`.
+// An error is returned if the context is canceled before the token
+// is verified/decrypted.
+func ParseContext(ctx context.Context, s []byte, options ...ParseOption) (Token, error) {
+	return parseBytes(s, append(options, newParseOption(identContext{}, ctx))...)
+}
+
 // ParseReader calls Parse against an io.Reader
 func ParseReader(src io.Reader, options ...ParseOption) (Token, error) {
+	maxTokenSize := limits.MaxInputSize()
+	for _, o := range options {
+		if o.Ident() == (identMaxTokenSize{}) {
+			if n, ok := o.Value().(int64); ok && n > 0 {
+				maxTokenSize = n
+			}
+		}
+	}
+	if maxTokenSize > 0 {
+		// Read one extra byte so that parseBytes can detect
+		// that the input was too large
+		src = io.LimitReader(src, maxTokenSize+1)
+	}
+
 	// We're going to need the raw bytes regardless. Read it.
 	data, err := ioutil.ReadAll(src)
 	if err != nil {
@@ -88,19 +151,68 @@ func ParseReader(src io.Reader, options ...ParseOption) (Token, error) {
 }
 
 type parseCtx struct {
+	context       context.Context
 	decryptParams DecryptParameters
 	verifyParams  VerifyParameters
+	verifyCache   jws.VerifyOption
+	remoteKeys    jws.VerifyOption
 	keySet        jwk.Set
+	setKey        jwk.Key // the key in keySet that was selected to verify
 	token         Token
 	validateOpts  []ValidateOption
 	localReg      *json.Registry
+	maxTokenSize  int64
+	maxClaimDepth int
+	numberFormat  *jwx.NumberFormat
+	insecure      bool
+	encryptedOnly bool
 	pedantic      bool
 	useDefault    bool
 	validate      bool
 }
 
 func parseBytes(data []byte, options ...ParseOption) (Token, error) {
+	ctx, err := newParseCtx(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.context.Err(); err != nil {
+		return nil, errors.Wrap(err, `failed to parse token`)
+	}
+
+	if ctx.maxTokenSize > 0 && int64(len(data)) > ctx.maxTokenSize {
+		return nil, newParseError(errors.Errorf(`token size exceeds maximum of %d bytes`, ctx.maxTokenSize))
+	}
+
+	data = bytes.TrimSpace(data)
+
+	if ctx.insecure {
+		ctx.verifyParams = nil
+		return parse(ctx, data)
+	}
+
+	// TODO: This must be moved elsewhere
+	// If with matching kid is true, then look for the corresponding key in the
+	// given key set, by matching the "kid" key
+	if ks := ctx.keySet; ks != nil {
+		alg, key, jwkKey, err := lookupMatchingKey(data, ks, ctx.useDefault)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to find matching key for verification`)
+		}
+		ctx.verifyParams = &verifyParams{alg: alg, key: key}
+		ctx.setKey = jwkKey
+	}
+
+	if ctx.verifyParams == nil && !ctx.encryptedOnly {
+		return nil, errors.New(`no verification key specified: use jwt.WithVerify() or jwt.WithKeySet(), or jwt.ParseInsecure() to skip verification`)
+	}
+	return parse(ctx, data)
+}
+
+func newParseCtx(options []ParseOption) (*parseCtx, error) {
 	var ctx parseCtx
+	ctx.context = context.Background()
 	for _, o := range options {
 		if v, ok := o.(ValidateOption); ok {
 			ctx.validateOpts = append(ctx.validateOpts, v)
@@ -109,10 +221,16 @@ func parseBytes(data []byte, options ...ParseOption) (Token, error) {
 
 		//nolint:forcetypeassert
 		switch o.Ident() {
+		case identContext{}:
+			ctx.context = o.Value().(context.Context)
 		case identVerify{}:
 			ctx.verifyParams = o.Value().(VerifyParameters)
 		case identDecrypt{}:
 			ctx.decryptParams = o.Value().(DecryptParameters)
+		case identVerificationCache{}:
+			ctx.verifyCache = o.Value().(jws.VerifyOption)
+		case identRemoteKeyPolicy{}:
+			ctx.remoteKeys = o.Value().(jws.VerifyOption)
 		case identKeySet{}:
 			ks, ok := o.Value().(jwk.Set)
 			if !ok {
@@ -127,6 +245,17 @@ func parseBytes(data []byte, options ...ParseOption) (Token, error) {
 			ctx.token = token
 		case identPedantic{}:
 			ctx.pedantic = o.Value().(bool)
+		case identInsecure{}:
+			ctx.insecure = o.Value().(bool)
+		case identEncryptedOnly{}:
+			ctx.encryptedOnly = o.Value().(IntegrityModel) == IntegrityAEADOnly
+		case identMaxTokenSize{}:
+			ctx.maxTokenSize = o.Value().(int64)
+		case identMaxClaimDepth{}:
+			ctx.maxClaimDepth = o.Value().(int)
+		case identNumberFormat{}:
+			v := o.Value().(jwx.NumberFormat)
+			ctx.numberFormat = &v
 		case identDefault{}:
 			ctx.useDefault = o.Value().(bool)
 		case identValidate{}:
@@ -140,19 +269,14 @@ func parseBytes(data []byte, options ...ParseOption) (Token, error) {
 		}
 	}
 
-	data = bytes.TrimSpace(data)
-
-	// TODO: This must be moved elsewhere
-	// If with matching kid is true, then look for the corresponding key in the
-	// given key set, by matching the "kid" key
-	if ks := ctx.keySet; ks != nil {
-		alg, key, err := lookupMatchingKey(data, ks, ctx.useDefault)
-		if err != nil {
-			return nil, errors.Wrap(err, `failed to find matching key for verification`)
-		}
-		ctx.verifyParams = &verifyParams{alg: alg, key: key}
+	// Fall back to the limits set via jwx.Settings()
+	if ctx.maxTokenSize == 0 {
+		ctx.maxTokenSize = limits.MaxInputSize()
 	}
-	return parse(&ctx, data)
+	if ctx.maxClaimDepth == 0 {
+		ctx.maxClaimDepth = limits.MaxDepth()
+	}
+	return &ctx, nil
 }
 
 // verify parameter exists to make sure that we don't accidentally skip
@@ -164,13 +288,27 @@ func parse(ctx *parseCtx, data []byte) (Token, error) {
 	// If cty = `JWT`, we expect this to be a nested structure
 	var expectNested bool
 
+	// Unless we are in insecure mode, at least one layer must be
+	// a JWS message whose signature has been verified, or the claims
+	// must be the plaintext of a JWE message (see WithEncryptedOnly)
+	var verified bool
+	var decrypted bool
+
 OUTER:
 	for i := 0; i < maxDecodeLevels; i++ {
-		switch kind := jwx.GuessFormat(payload); kind {
+		kind := jwx.GuessFormat(payload)
+		if kind == jwx.JWS || kind == jwx.JWE {
+			// Bound the envelope before it is verified or decrypted
+			if err := checkEnvelopeDepth(payload, ctx.maxClaimDepth); err != nil {
+				return nil, newParseError(errors.Wrapf(err, `invalid message (layer: #%d)`, i+1))
+			}
+		}
+
+		switch kind {
 		case jwx.JWT:
 			if ctx.pedantic {
 				if expectNested {
-					return nil, errors.Errorf(`expected nested encrypted/signed payload, got raw JWT`)
+					return nil, newParseError(errors.Errorf(`expected nested encrypted/signed payload, got raw JWT`))
 				}
 			}
 			break OUTER
@@ -178,12 +316,10 @@ OUTER:
 			// "Unknown" may include invalid JWTs, for example, those who lack "aud"
 			// claim. We could be pedantic and reject these
 			if ctx.pedantic {
-				return nil, errors.Errorf(`invalid JWT`)
+				return nil, newParseError(errors.Errorf(`invalid JWT`))
 			}
 			break OUTER
 		case jwx.JWS:
-			// For backwards compatibility, we must allow parsing the JWT
-			// without verifying its contents
 			if vp := ctx.verifyParams; vp != nil {
 				// If verify is true, the data MUST be a valid jws message
 				var m *jws.Message
@@ -192,10 +328,17 @@ OUTER:
 					m = jws.NewMessage()
 					verifyOpts = []jws.VerifyOption{jws.WithMessage(m)}
 				}
-				v, err := jws.Verify(payload, vp.Algorithm(), vp.Key(), verifyOpts...)
+				if ctx.verifyCache != nil {
+					verifyOpts = append(verifyOpts, ctx.verifyCache)
+				}
+				if ctx.remoteKeys != nil {
+					verifyOpts = append(verifyOpts, ctx.remoteKeys)
+				}
+				v, err := jws.VerifyContext(ctx.context, payload, vp.Algorithm(), vp.Key(), verifyOpts...)
 				if err != nil {
 					return nil, errors.Wrap(err, `failed to verify jws signature`)
 				}
+				verified = true
 
 				if !ctx.pedantic {
 					payload = v
@@ -218,11 +361,14 @@ OUTER:
 				}
 
 				// Hmmm, it was a JWS and we got... nothing?
-				return nil, errors.Errorf(`expected "typ" or "cty" fields, neither could be found`)
+				return nil, newParseError(errors.Errorf(`expected "typ" or "cty" fields, neither could be found`))
 			}
 
-			// No verification.
-			m, err := jws.Parse(data)
+			// No verification key: this is the explicit insecure path of
+			// jwt.ParseInsecure(). (With jwt.WithEncryptedOnly() and no
+			// verification key, nested tokens also end up here, and are
+			// rejected below because no layer was verified.)
+			m, err := jws.Parse(payload)
 			if err != nil {
 				return nil, errors.Wrap(err, `invalid jws message`)
 			}
@@ -235,16 +381,35 @@ OUTER:
 
 			var m *jwe.Message
 			var decryptOpts []jwe.DecryptOption
-			if ctx.pedantic {
+			if ctx.pedantic || ctx.encryptedOnly {
 				m = jwe.NewMessage()
 				decryptOpts = []jwe.DecryptOption{jwe.WithMessage(m)}
 			}
 
-			v, err := jwe.Decrypt(data, dp.Algorithm(), dp.Key(), decryptOpts...)
+			v, err := jwe.DecryptContext(ctx.context, data, dp.Algorithm(), dp.Key(), decryptOpts...)
 			if err != nil {
 				return nil, errors.Wrap(err, `failed to decrypt payload`)
 			}
 
+			if ctx.encryptedOnly {
+				payload = v
+				// Nested tokens are handled as usual, whether or not
+				// "cty" is set. Otherwise the plaintext is the claims,
+				// which "cty" must agree with
+				// https://datatracker.ietf.org/doc/html/rfc7519#section-5.2
+				if kind := jwx.GuessFormat(v); kind == jwx.JWS || kind == jwx.JWE {
+					continue OUTER
+				}
+				if strings.ToLower(m.ProtectedHeaders().ContentType()) == _jwt {
+					return nil, newParseError(errors.New(`expected nested encrypted/signed payload as indicated by "cty"`))
+				}
+				if err := checkEncryptedOnly(dp.Algorithm()); err != nil {
+					return nil, newVerificationError(err)
+				}
+				decrypted = true
+				break OUTER
+			}
+
 			if !ctx.pedantic {
 				payload = v
 				continue
@@ -261,50 +426,134 @@ OUTER:
 				continue OUTER
 			}
 		default:
-			return nil, errors.Errorf(`unsupported format (layer: #%d)`, i+1)
+			return nil, newParseError(errors.Errorf(`unsupported format (layer: #%d)`, i+1))
 		}
 		expectNested = false
 	}
 
+	if !ctx.insecure && !verified && !decrypted {
+		return nil, newVerificationError(errors.New(`token is not signed: use jwt.ParseInsecure() to parse tokens without verification`))
+	}
+
+	tok, err := decodeToken(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.validate {
+		if err := Validate(tok, ctx.validateOpts...); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only tokens that have been accepted count towards the key usage,
+	// so that a key is not kept alive by tokens that fail validation
+	if verified && ctx.setKey != nil {
+		if r, ok := ctx.keySet.(jwk.UsageRecorder); ok {
+			r.RecordUsage(ctx.setKey, jwk.KeyOpVerify)
+		}
+	}
+	return tok, nil
+}
+
+// checkEnvelopeDepth returns an error if the JSON serialization of a
+// JWS or JWE message in buf, or any of its protected headers, is nested
+// deeper than max. A max of 0 disables the check.
+func checkEnvelopeDepth(buf []byte, max int) error {
+	if max <= 0 {
+		return nil
+	}
+
+	var headers []string
+	if buf[0] == '{' {
+		if err := json.CheckDepth(buf, max); err != nil {
+			return err
+		}
+
+		var envelope struct {
+			Protected  string `json:"protected"`
+			Signatures []struct {
+				Protected string `json:"protected"`
+			} `json:"signatures"`
+		}
+		if err := json.Unmarshal(buf, &envelope); err != nil {
+			return errors.Wrap(err, `failed to parse JSON serialization`)
+		}
+		headers = append(headers, envelope.Protected)
+		for _, sig := range envelope.Signatures {
+			headers = append(headers, sig.Protected)
+		}
+	} else {
+		i := bytes.IndexByte(buf, '.')
+		if i < 0 {
+			return errors.New(`invalid compact serialization`)
+		}
+		headers = append(headers, string(buf[:i]))
+	}
+
+	for _, h := range headers {
+		if h == "" {
+			continue
+		}
+		decoded, err := base64.DecodeString(h)
+		if err != nil {
+			return errors.Wrap(err, `failed to decode protected header`)
+		}
+		if err := json.CheckDepth(decoded, max); err != nil {
+			return errors.Wrap(err, `invalid protected header`)
+		}
+	}
+	return nil
+}
+
+// decodeToken decodes the claims in payload into the token specified
+// in the parse context, honoring the decoding options and limits
+func decodeToken(ctx *parseCtx, payload []byte) (Token, error) {
 	if ctx.token == nil {
 		ctx.token = New()
 	}
 
-	if ctx.localReg != nil {
+	if ctx.localReg != nil || ctx.numberFormat != nil {
 		dcToken, ok := ctx.token.(TokenWithDecodeCtx)
 		if !ok {
-			return nil, errors.Errorf(`typed claim was requested, but the token (%T) does not support DecodeCtx`, ctx.token)
+			return nil, errors.Errorf(`typed claim or number format was requested, but the token (%T) does not support DecodeCtx`, ctx.token)
+		}
+
+		var dc json.DecodeCtx
+		if ctx.numberFormat != nil {
+			dc = json.NewUseNumberDecodeCtx(ctx.localReg, *ctx.numberFormat == jwx.NumberAsJSONNumber)
+		} else {
+			dc = json.NewDecodeCtx(ctx.localReg)
 		}
-		dc := json.NewDecodeCtx(ctx.localReg)
 		dcToken.SetDecodeCtx(dc)
 		defer func() { dcToken.SetDecodeCtx(nil) }()
 	}
 
-	if err := json.Unmarshal(payload, ctx.token); err != nil {
-		return nil, errors.Wrap(err, `failed to parse token`)
+	if ctx.maxClaimDepth > 0 {
+		if err := json.CheckDepth(payload, ctx.maxClaimDepth); err != nil {
+			return nil, newParseError(errors.Wrap(err, `failed to parse token`))
+		}
 	}
 
-	if ctx.validate {
-		if err := Validate(ctx.token, ctx.validateOpts...); err != nil {
-			return nil, err
-		}
+	if err := json.Unmarshal(payload, ctx.token); err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to parse token`))
 	}
 	return ctx.token, nil
 }
 
-func lookupMatchingKey(data []byte, keyset jwk.Set, useDefault bool) (jwa.SignatureAlgorithm, interface{}, error) {
+func lookupMatchingKey(data []byte, keyset jwk.Set, useDefault bool) (jwa.SignatureAlgorithm, interface{}, jwk.Key, error) {
 	msg, err := jws.Parse(data)
 	if err != nil {
-		return "", nil, errors.Wrap(err, `failed to parse token data`)
+		return "", nil, nil, errors.Wrap(err, `failed to parse token data`)
 	}
 
 	headers := msg.Signatures()[0].ProtectedHeaders()
 	kid := headers.KeyID()
 	if kid == "" {
 		if !useDefault {
-			return "", nil, errors.New(`failed to find matching key: no key ID specified in token`)
+			return "", nil, nil, newKeyResolutionError(errors.New(`failed to find matching key: no key ID specified in token`))
 		} else if useDefault && keyset.Len() > 1 {
-			return "", nil, errors.New(`failed to find matching key: no key ID specified in token but multiple in key set`)
+			return "", nil, nil, newKeyResolutionError(errors.New(`failed to find matching key: no key ID specified in token but multiple in key set`))
 		}
 	}
 
@@ -313,26 +562,26 @@ func lookupMatchingKey(data []byte, keyset jwk.Set, useDefault bool) (jwa.Signat
 	if kid == "" {
 		key, ok = keyset.Get(0)
 		if !ok {
-			return "", nil, errors.New(`empty keyset`)
+			return "", nil, nil, newKeyResolutionError(errors.New(`empty keyset`))
 		}
 	} else {
 		key, ok = keyset.LookupKeyID(kid)
 		if !ok {
-			return "", nil, errors.Errorf(`failed to find matching key for key ID %#v in key set`, kid)
+			return "", nil, nil, newKeyResolutionError(errors.Errorf(`failed to find matching key for key ID %#v in key set`, kid))
 		}
 	}
 
 	var rawKey interface{}
 	if err := key.Raw(&rawKey); err != nil {
-		return "", nil, errors.Wrapf(err, `failed to construct raw key from keyset (key ID=%#v)`, kid)
+		return "", nil, nil, newKeyResolutionError(errors.Wrapf(err, `failed to construct raw key from keyset (key ID=%#v)`, kid))
 	}
 
 	var alg jwa.SignatureAlgorithm
 	if err := alg.Accept(key.Algorithm()); err != nil {
-		return "", nil, errors.Wrapf(err, `invalid signature algorithm %s`, key.Algorithm())
+		return "", nil, nil, newKeyResolutionError(errors.Wrapf(err, `invalid signature algorithm %s`, key.Algorithm()))
 	}
 
-	return alg, rawKey, nil
+	return alg, rawKey, key, nil
 }
 
 // Sign is a convenience function to create a signed JWT token serialized in
@@ -351,23 +600,33 @@ func lookupMatchingKey(data []byte, keyset jwk.Set, useDefault bool) (jwa.Signat
 // The protected header will also automatically have the `typ` field set
 // to the literal value `JWT`, unless you provide a custom value for it
 // by jwt.WithHeaders option.
+//
+// Options such as `jwt.WithTTL()` and `jwt.WithAutoJTI()` can be used to
+// populate the "iat", "exp", "nbf", and "jti" claims of the signed token.
+// See `jwt.Mint()` for details.
 func Sign(t Token, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
 	return NewSerializer().Sign(alg, key, options...).Serialize(t)
 }
 
+// SignContext is the same as Sign, but accepts a context.Context,
+// which is passed on to `jws.SignContext()`.
+func SignContext(ctx context.Context, t Token, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	return NewSerializer().Sign(alg, key, options...).SerializeContext(ctx, t)
+}
+
 // Equal compares two JWT tokens. Do not use `reflect.Equal` or the like
 // to compare tokens as they will also compare extra detail such as
 // sync.Mutex objects used to control concurrent access.
 //
-// The comparison for values is currently done using a simple equality ("=="),
-// except for time.Time, which uses time.Equal after dropping the monotonic
-// clock and truncating the values to 1 second accuracy.
+// The comparison is done semantically, using the same rules as `jwt.Diff()`:
+// time.Time values are equal if they are serialized to the same NumericDate
+// at the precision set by `jwt.WithNumericDateFormatPrecision()`, numeric values are
+// compared regardless of their Go types (integers and json.Number values
+// exactly, floating point values as float64), and other values such as
+// the "aud" claim are compared deeply.
 //
 // if both t1 and t2 are nil, returns true
 func Equal(t1, t2 Token) bool {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	if t1 == nil && t2 == nil {
 		return true
 	}
@@ -377,36 +636,165 @@ func Equal(t1, t2 Token) bool {
 		return false
 	}
 
-	m1, err := t1.AsMap(ctx)
+	diffs, err := diff(t1, t2)
 	if err != nil {
 		return false
 	}
+	return len(diffs) == 0
+}
 
-	for iter := t2.Iterate(ctx); iter.Next(ctx); {
-		pair := iter.Pair()
+// ClaimDiff describes a claim whose value differs between two tokens.
+type ClaimDiff struct {
+	// Name is the name of the claim
+	Name string
+	// Left is the value of the claim in the first token, or nil if
+	// the claim does not exist in the first token
+	Left interface{}
+	// Right is the value of the claim in the second token, or nil if
+	// the claim does not exist in the second token
+	Right interface{}
+}
 
-		v1 := m1[pair.Key.(string)]
-		v2 := pair.Value
-		switch tmp := v1.(type) {
-		case time.Time:
-			tmp2, ok := v2.(time.Time)
-			if !ok {
-				return false
-			}
-			tmp = tmp.Round(0).Truncate(time.Second)
-			tmp2 = tmp2.Round(0).Truncate(time.Second)
-			if !tmp.Equal(tmp2) {
-				return false
-			}
-		default:
-			if v1 != v2 {
-				return false
-			}
+// Diff compares the claims in two JWT tokens, and returns the list of
+// claims whose values differ between them, sorted by the claim names.
+// Claims that exist in only one of the tokens are also reported.
+// A nil token is treated as a token without any claims.
+//
+// See `jwt.Equal()` for details on how the values are compared.
+// If the claims of either token cannot be retrieved, a single ClaimDiff
+// with an empty Name and the two tokens as its values is returned, so
+// that the tokens are never mistaken for being equal.
+func Diff(t1, t2 Token) []ClaimDiff {
+	diffs, err := diff(t1, t2)
+	if err != nil {
+		return []ClaimDiff{{Left: t1, Right: t2}}
+	}
+	return diffs
+}
+
+func diff(t1, t2 Token) ([]ClaimDiff, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m1, err := claimsMap(ctx, t1)
+	if err != nil {
+		return nil, err
+	}
+	m2, err := claimsMap(ctx, t2)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(m1)+len(m2))
+	for name := range m1 {
+		names = append(names, name)
+	}
+	for name := range m2 {
+		if _, ok := m1[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []ClaimDiff
+	for _, name := range names {
+		v1, ok1 := m1[name]
+		v2, ok2 := m2[name]
+		if ok1 && ok2 && claimValueEqual(v1, v2) {
+			continue
 		}
-		delete(m1, pair.Key.(string))
+		diffs = append(diffs, ClaimDiff{Name: name, Left: v1, Right: v2})
 	}
+	return diffs, nil
+}
 
-	return len(m1) == 0
+func claimsMap(ctx context.Context, t Token) (map[string]interface{}, error) {
+	if t == nil {
+		return map[string]interface{}{}, nil
+	}
+	m, err := t.AsMap(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to convert token to map`)
+	}
+	return m, nil
+}
+
+func claimValueEqual(v1, v2 interface{}) bool {
+	switch tmp := v1.(type) {
+	case time.Time:
+		tmp2, ok := v2.(time.Time)
+		if !ok {
+			return false
+		}
+		return types.FormatNumericDate(tmp) == types.FormatNumericDate(tmp2)
+	}
+
+	if n1, ok := toNumber(v1); ok {
+		n2, ok := toNumber(v2)
+		return ok && numberEqual(n1, n2)
+	}
+
+	return reflect.DeepEqual(v1, v2)
+}
+
+// toNumber converts a numeric claim value into either a float64, for
+// floating point values, or an exact *big.Rat, for integers and
+// json.Number values
+func toNumber(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int8:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int16:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case uint:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(v))), true
+	case uint8:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case uint16:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case uint32:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v)), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		r, ok := new(big.Rat).SetString(v.String())
+		if !ok {
+			return nil, false
+		}
+		return r, true
+	}
+	return nil, false
+}
+
+// numberEqual compares two values returned by toNumber. Integers and
+// json.Number values are compared exactly, so that large integers such
+// as 64-bit IDs are not confused with each other. A floating point
+// value is compared with the other value converted to float64.
+func numberEqual(n1, n2 interface{}) bool {
+	r1, ok1 := n1.(*big.Rat)
+	r2, ok2 := n2.(*big.Rat)
+	if ok1 && ok2 {
+		return r1.Cmp(r2) == 0
+	}
+	return ratToFloat64(n1) == ratToFloat64(n2)
+}
+
+func ratToFloat64(n interface{}) float64 {
+	if r, ok := n.(*big.Rat); ok {
+		f, _ := r.Float64()
+		return f
+	}
+	return n.(float64) //nolint:forcetypeassert
 }
 
 func (t *stdToken) Clone() (Token, error) {
@@ -424,7 +812,9 @@ func (t *stdToken) Clone() (Token, error) {
 
 // RegisterCustomField allows users to specify that a private field
 // be decoded as an instance of the specified type. This option has
-// a global effect.
+// a global effect, and applies to all tokens parsed afterwards,
+// including openid.Token. If the type (or a pointer to it) implements
+// json.Unmarshaler, it is used to decode the value.
 //
 // For example, suppose you have a custom field `x-birthday`, which
 // you want to represent as a string formatted in RFC3339 in JSON,
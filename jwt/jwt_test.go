@@ -12,9 +12,11 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/lestrrat-go/jwx"
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 	"github.com/lestrrat-go/jwx/jwe"
@@ -24,6 +26,7 @@ import (
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jws"
 	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/openid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -57,19 +60,40 @@ func TestJWTParse(t *testing.T) {
 
 	t.Logf("%s", signed)
 
-	t.Run("Parse (no signature verification)", func(t *testing.T) {
+	t.Run("ParseInsecure (no signature verification)", func(t *testing.T) {
 		t.Parallel()
-		t2, err := jwt.Parse(signed)
-		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+		t2, err := jwt.ParseInsecure(signed)
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
 			return
 		}
 		if !assert.True(t, jwt.Equal(t1, t2), `t1 == t2`) {
 			return
 		}
 	})
+	t.Run("Parse (no signature verification)", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.Parse(signed)
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
 	t.Run("ParseString (no signature verification)", func(t *testing.T) {
 		t.Parallel()
-		t2, err := jwt.ParseString(string(signed))
+		_, err := jwt.ParseString(string(signed))
+		if !assert.Error(t, err, `jwt.ParseString should fail`) {
+			return
+		}
+	})
+	t.Run("ParseReader (no signature verification)", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.ParseReader(bytes.NewReader(signed))
+		if !assert.Error(t, err, `jwt.ParseReader should fail`) {
+			return
+		}
+	})
+	t.Run("ParseString (correct signature key)", func(t *testing.T) {
+		t.Parallel()
+		t2, err := jwt.ParseString(string(signed), jwt.WithVerify(alg, &key.PublicKey))
 		if !assert.NoError(t, err, `jwt.ParseString should succeed`) {
 			return
 		}
@@ -77,16 +101,23 @@ func TestJWTParse(t *testing.T) {
 			return
 		}
 	})
-	t.Run("ParseReader (no signature verification)", func(t *testing.T) {
+	t.Run("ParseReader (correct signature key)", func(t *testing.T) {
 		t.Parallel()
-		t2, err := jwt.ParseReader(bytes.NewReader(signed))
-		if !assert.NoError(t, err, `jwt.ParseBytes should succeed`) {
+		t2, err := jwt.ParseReader(bytes.NewReader(signed), jwt.WithVerify(alg, &key.PublicKey))
+		if !assert.NoError(t, err, `jwt.ParseReader should succeed`) {
 			return
 		}
 		if !assert.True(t, jwt.Equal(t1, t2), `t1 == t2`) {
 			return
 		}
 	})
+	t.Run("Parse (unsigned payload with verification key)", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.Parse([]byte(`{"iss":"foo"}`), jwt.WithVerify(alg, &key.PublicKey))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
 	t.Run("Parse (correct signature key)", func(t *testing.T) {
 		t.Parallel()
 		t2, err := jwt.Parse(signed, jwt.WithVerify(alg, &key.PublicKey))
@@ -448,7 +479,12 @@ func TestSignErrors(t *testing.T) {
 		return
 	}
 
-	if !assert.Contains(t, err.Error(), `unsupported signature algorithm "BOGUS"`) {
+	if jwx.FIPSMode() {
+		// algorithms are checked for approval before they are looked up
+		if !assert.True(t, errors.Is(err, jwx.ErrNotFIPSApproved), `error should match jwx.ErrNotFIPSApproved (got %v)`, err) {
+			return
+		}
+	} else if !assert.Contains(t, err.Error(), `unsupported signature algorithm "BOGUS"`) {
 		return
 	}
 
@@ -535,6 +571,56 @@ func TestSignTyp(t *testing.T) {
 	})
 }
 
+func TestSignFast(t *testing.T) {
+	t.Parallel()
+
+	key := []byte(`Avracadabra-Avracadabra-Avracadabra`)
+	tok := jwt.New()
+	_ = tok.Set(jwt.SubjectKey, `alice`)
+	_ = tok.Set(jwt.ExpirationKey, time.Unix(1300819380, 0))
+
+	expected, err := jwt.Sign(tok, jwa.HS256, key)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	signed, err := jwt.SignFast(make([]byte, 0, 256), tok, jwa.HS256, key)
+	if !assert.NoError(t, err, `jwt.SignFast should succeed`) {
+		return
+	}
+	if !assert.Equal(t, string(expected), string(signed), `result should match jwt.Sign()`) {
+		return
+	}
+
+	parsed, err := jwt.Parse(signed, jwt.WithVerify(jwa.HS256, key))
+	if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+		return
+	}
+	assert.True(t, jwt.Equal(tok, parsed), `tokens should match`)
+
+	_, err = jwt.SignFast(nil, tok, jwa.ES256, key)
+	assert.Error(t, err, `jwt.SignFast should fail for non-HMAC algorithms`)
+}
+
+func TestSignWithSigningPolicy(t *testing.T) {
+	t.Parallel()
+
+	key := []byte(`Avracadabra-Avracadabra-Avracadabra`)
+	policy := jwt.WithSigningPolicy(jws.SigningPolicy{
+		ProtectedHeaders: []string{jws.KeyIDKey, jws.TypeKey},
+	})
+
+	_, err := jwt.Sign(jwt.New(), jwa.HS256, key, policy)
+	if !assert.Error(t, err, `jwt.Sign should fail without "kid"`) {
+		return
+	}
+
+	hdrs := jws.NewHeaders()
+	_ = hdrs.Set(jws.KeyIDKey, `my-key`)
+	_, err = jwt.Sign(jwt.New(), jwa.HS256, key, policy, jwt.WithJwsHeaders(hdrs))
+	assert.NoError(t, err, `jwt.Sign should succeed`)
+}
+
 func TestReadFile(t *testing.T) {
 	t.Parallel()
 
@@ -544,16 +630,28 @@ func TestReadFile(t *testing.T) {
 	}
 	defer f.Close()
 
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
 	token := jwt.New()
 	token.Set(jwt.IssuerKey, `lestrrat`)
-	if !assert.NoError(t, json.NewEncoder(f).Encode(token), `json.NewEncoder.Encode should succeed`) {
+	signed, err := jwt.Sign(token, jwa.RS256, key)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+	if _, err := f.Write(signed); !assert.NoError(t, err, `f.Write should succeed`) {
 		return
 	}
 
-	if _, err := jwt.ReadFile(f.Name(), jwt.WithValidate(true), jwt.WithIssuer("lestrrat")); !assert.NoError(t, err, `jwt.ReadFile should succeed`) {
+	if _, err := jwt.ReadFile(f.Name(), jwt.WithVerify(jwa.RS256, &key.PublicKey), jwt.WithValidate(true), jwt.WithIssuer("lestrrat")); !assert.NoError(t, err, `jwt.ReadFile should succeed`) {
+		return
+	}
+	if _, err := jwt.ReadFile(f.Name(), jwt.WithVerify(jwa.RS256, &key.PublicKey), jwt.WithValidate(true), jwt.WithIssuer("lestrrrrrat")); !assert.Error(t, err, `jwt.ReadFile should fail`) {
 		return
 	}
-	if _, err := jwt.ReadFile(f.Name(), jwt.WithValidate(true), jwt.WithIssuer("lestrrrrrat")); !assert.Error(t, err, `jwt.ReadFile should fail`) {
+	if _, err := jwt.ReadFile(f.Name()); !assert.Error(t, err, `jwt.ReadFile should fail without verification`) {
 		return
 	}
 }
@@ -572,9 +670,9 @@ func TestCustomField(t *testing.T) {
 	b.WriteString(`"}`)
 	src := b.String()
 
-	t.Run("jwt.Parse", func(t *testing.T) {
-		token, err := jwt.Parse([]byte(src))
-		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+	t.Run("jwt.ParseInsecure", func(t *testing.T) {
+		token, err := jwt.ParseInsecure([]byte(src))
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
 			return
 		}
 
@@ -604,6 +702,55 @@ func TestCustomField(t *testing.T) {
 	})
 }
 
+type scopeList []string
+
+func (l *scopeList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*l = strings.Fields(s)
+	return nil
+}
+
+func TestCustomFieldUnmarshaler(t *testing.T) {
+	// XXX has global effect!!!
+	jwt.RegisterCustomField(`scope`, scopeList{})
+	defer jwt.RegisterCustomField(`scope`, nil)
+
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	token := jwt.New()
+	token.Set(`scope`, `openid profile email`)
+	signed, err := jwt.Sign(token, jwa.RS256, key)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	for _, tok := range []jwt.Token{nil, openid.New()} {
+		parseOptions := []jwt.ParseOption{jwt.WithVerify(jwa.RS256, &key.PublicKey)}
+		if tok != nil {
+			parseOptions = append(parseOptions, jwt.WithToken(tok))
+		}
+		parsed, err := jwt.Parse(signed, parseOptions...)
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+
+		v, ok := parsed.Get(`scope`)
+		if !assert.True(t, ok, `parsed.Get("scope") should succeed`) {
+			return
+		}
+
+		if !assert.Equal(t, scopeList{`openid`, `profile`, `email`}, v, `values should match`) {
+			return
+		}
+	}
+}
+
 func TestParseRequest(t *testing.T) {
 	const u = "https://github.com/lestrrat-gow/jwx/jwt"
 
@@ -795,6 +942,21 @@ func TestGHIssue368(t *testing.T) {
 			})
 		})
 	}
+
+	t.Run("Settings without WithFlattenAudience resets it", func(t *testing.T) {
+		jwt.Settings(jwt.WithFlattenAudience(true))
+		jwt.Settings(jwt.WithNumericDateParsePrecision(0))
+
+		tok := jwt.New()
+		_ = tok.Set(jwt.AudienceKey, "hello")
+		buf, err := json.Marshal(tok)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"aud":["hello"]}`, string(buf), `audience should not be flattened`) {
+			return
+		}
+	})
 }
 
 func TestGH375(t *testing.T) {
@@ -895,7 +1057,7 @@ func TestJWTParseWithTypedClaim(t *testing.T) {
 	for _, tc := range testcases {
 		tc := tc
 		t.Run(tc.Name, func(t *testing.T) {
-			got, err := jwt.Parse(signed, tc.Options...)
+			got, err := jwt.Parse(signed, append(tc.Options, jwt.WithVerify(jwa.RS256, &key.PublicKey))...)
 			if !assert.NoError(t, err, `jwt.Parse should succeed`) {
 				return
 			}
@@ -1059,4 +1221,1525 @@ func TestNested(t *testing.T) {
 		return
 	}
 	_ = parsed
+
+	insecure, err := jwt.ParseInsecure(serialized, jwt.WithDecrypt(jwa.RSA_OAEP, key))
+	if !assert.NoError(t, err, `jwt.ParseInsecure with decryption should succeed`) {
+		return
+	}
+	if !assert.Equal(t, token.Issuer(), insecure.Issuer(), `iss should match`) {
+		return
+	}
+}
+
+func TestEncryptedOnly(t *testing.T) {
+	sharedKey := []byte(`0123456789abcdef0123456789abcdef`)
+	rsaKey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	token := jwt.New()
+	token.Set(jwt.IssuerKey, `https://github.com/lestrrat-go/jwx`)
+
+	t.Run("Encrypt", func(t *testing.T) {
+		_, err := jwt.Encrypt(token, jwa.DIRECT, sharedKey, jwa.A256GCM)
+		if !assert.Error(t, err, `jwt.Encrypt without jwt.WithEncryptedOnly should fail`) {
+			return
+		}
+
+		_, err = jwt.Encrypt(token, jwa.RSA_OAEP, &rsaKey.PublicKey, jwa.A256GCM, jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.Error(t, err, `jwt.Encrypt with a public key algorithm should fail`) {
+			return
+		}
+
+		hdrs := jwe.NewHeaders()
+		hdrs.Set(jwe.ContentTypeKey, `JWT`)
+		_, err = jwt.Encrypt(token, jwa.DIRECT, sharedKey, jwa.A256GCM, jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly), jwt.WithJweHeaders(hdrs))
+		if !assert.Error(t, err, `jwt.Encrypt with "cty": "JWT" should fail`) {
+			return
+		}
+
+		encrypted, err := jwt.Encrypt(token, jwa.DIRECT, sharedKey, jwa.A256GCM, jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.NoError(t, err, `jwt.Encrypt should succeed`) {
+			return
+		}
+
+		m := jwe.NewMessage()
+		_, err = jwe.Decrypt(encrypted, jwa.DIRECT, sharedKey, jwe.WithMessage(m))
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `JWT`, m.ProtectedHeaders().Type(), `typ should be JWT`) {
+			return
+		}
+		if !assert.Empty(t, m.ProtectedHeaders().ContentType(), `cty should not be set`) {
+			return
+		}
+	})
+	t.Run("Parse", func(t *testing.T) {
+		encrypted, err := jwt.Encrypt(token, jwa.A128KW, sharedKey[:16], jwa.A128CBC_HS256, jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.NoError(t, err, `jwt.Encrypt should succeed`) {
+			return
+		}
+
+		_, err = jwt.Parse(encrypted, jwt.WithDecrypt(jwa.A128KW, sharedKey[:16]))
+		if !assert.Error(t, err, `jwt.Parse without jwt.WithEncryptedOnly should fail`) {
+			return
+		}
+
+		_, err = jwt.Parse(encrypted, jwt.WithDecrypt(jwa.A128KW, sharedKey[:16]), jwt.WithVerify(jwa.HS256, sharedKey))
+		if !assert.Error(t, err, `jwt.Parse of a token that is not signed should fail`) {
+			return
+		}
+
+		parsed, err := jwt.Parse(encrypted, jwt.WithDecrypt(jwa.A128KW, sharedKey[:16]), jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.NoError(t, err, `jwt.Parse with jwt.WithEncryptedOnly should succeed`) {
+			return
+		}
+		if !assert.Equal(t, token.Issuer(), parsed.Issuer(), `iss should match`) {
+			return
+		}
+
+		_, err = jwt.Parse(encrypted, jwt.WithDecrypt(jwa.A128KW, []byte(`fedcba9876543210`)), jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.Error(t, err, `jwt.Parse with the wrong key should fail`) {
+			return
+		}
+	})
+	t.Run("Public key algorithm", func(t *testing.T) {
+		encrypted, err := jwt.NewSerializer().
+			Encrypt(jwa.RSA_OAEP, &rsaKey.PublicKey, jwa.A256GCM, jwa.NoCompress).
+			Serialize(token)
+		if !assert.NoError(t, err, `jwt.Serializer should succeed`) {
+			return
+		}
+
+		_, err = jwt.Parse(encrypted, jwt.WithDecrypt(jwa.RSA_OAEP, rsaKey), jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
+	t.Run("Mismatched cty", func(t *testing.T) {
+		buf, err := json.Marshal(token)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		hdrs := jwe.NewHeaders()
+		hdrs.Set(jwe.ContentTypeKey, `JWT`)
+		encrypted, err := jwe.Encrypt(buf, jwa.DIRECT, sharedKey, jwa.A256GCM, jwa.NoCompress, jwe.WithProtectedHeaders(hdrs))
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		_, err = jwt.Parse(encrypted, jwt.WithDecrypt(jwa.DIRECT, sharedKey), jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+	})
+	t.Run("Nested", func(t *testing.T) {
+		serialized, err := jwt.NewSerializer().
+			Sign(jwa.RS256, rsaKey).
+			Encrypt(jwa.DIRECT, sharedKey, jwa.A256GCM, jwa.NoCompress, jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly)).
+			Serialize(token)
+		if !assert.NoError(t, err, `jwt.Serializer should succeed`) {
+			return
+		}
+
+		_, err = jwt.Parse(serialized, jwt.WithDecrypt(jwa.DIRECT, sharedKey), jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.Error(t, err, `jwt.Parse of a nested token without a verification key should fail`) {
+			return
+		}
+
+		_, err = jwt.Parse(serialized, jwt.WithDecrypt(jwa.DIRECT, sharedKey), jwt.WithVerify(jwa.RS256, &rsaKey.PublicKey), jwt.WithEncryptedOnly(jwt.IntegrityAEADOnly))
+		if !assert.NoError(t, err, `jwt.Parse of a nested token should succeed`) {
+			return
+		}
+	})
+}
+
+func TestParseLimits(t *testing.T) {
+	tok := jwt.New()
+	tok.Set(jwt.IssuerKey, `github.com/lestrrat-go/jwx`)
+	tok.Set(`nested`, map[string]interface{}{
+		`a`: map[string]interface{}{
+			`b`: []interface{}{`c`},
+		},
+	})
+
+	serialized, err := json.Marshal(tok)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+
+	t.Run("WithMaxTokenSize", func(t *testing.T) {
+		_, err := jwt.ParseInsecure(serialized, jwt.WithMaxTokenSize(int64(len(serialized))))
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
+			return
+		}
+
+		_, err = jwt.ParseInsecure(serialized, jwt.WithMaxTokenSize(int64(len(serialized)-1)))
+		if !assert.Error(t, err, `jwt.ParseInsecure should fail`) {
+			return
+		}
+
+		_, err = jwt.ParseReader(bytes.NewReader(serialized), jwt.WithVerify(jwa.RS256, nil), jwt.WithMaxTokenSize(int64(len(serialized)-1)))
+		if !assert.Error(t, err, `jwt.ParseReader should fail`) {
+			return
+		}
+	})
+	t.Run("WithMaxClaimDepth", func(t *testing.T) {
+		// {"nested":{"a":{"b":["c"]}}} is 4 levels deep
+		_, err := jwt.ParseInsecure(serialized, jwt.WithMaxClaimDepth(4))
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
+			return
+		}
+
+		_, err = jwt.ParseInsecure(serialized, jwt.WithMaxClaimDepth(3))
+		if !assert.Error(t, err, `jwt.ParseInsecure should fail`) {
+			return
+		}
+
+		// brackets within strings should not count
+		_, err = jwt.ParseInsecure([]byte(`{"iss":"[[[{{{\"[[["}`), jwt.WithMaxClaimDepth(1))
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
+			return
+		}
+	})
+	t.Run("WithMaxClaimDepth and nested headers", func(t *testing.T) {
+		key := []byte(`0123456789abcdef0123456789abcdef`)
+		claims := jwt.New()
+		claims.Set(jwt.IssuerKey, `github.com/lestrrat-go/jwx`)
+
+		// {"alg":"HS256","typ":"JWT","x":{"a":{"b":{"c":{}}}}} is 5 levels deep
+		hdrs := jws.NewHeaders()
+		hdrs.Set(`x`, map[string]interface{}{
+			`a`: map[string]interface{}{
+				`b`: map[string]interface{}{
+					`c`: map[string]interface{}{},
+				},
+			},
+		})
+		signed, err := jwt.Sign(claims, jwa.HS256, key, jwt.WithJwsHeaders(hdrs))
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+
+		_, err = jwt.Parse(signed, jwt.WithVerify(jwa.HS256, key), jwt.WithMaxClaimDepth(5))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+
+		// The header must be rejected before the signature is checked
+		_, err = jwt.Parse(signed, jwt.WithVerify(jwa.HS256, []byte(`wrong key`)), jwt.WithMaxClaimDepth(4))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `nesting depth`, `error should be about the nesting depth`) {
+			return
+		}
+
+		m, err := jws.Parse(signed)
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		jsonSerialized, err := json.Marshal(m)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		_, err = jwt.Parse(jsonSerialized, jwt.WithVerify(jwa.HS256, []byte(`wrong key`)), jwt.WithMaxClaimDepth(4))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `nesting depth`, `error should be about the nesting depth`) {
+			return
+		}
+	})
+}
+
+func TestWithNumberFormat(t *testing.T) {
+	t.Parallel()
+
+	// 2^63-1 can not be represented exactly as float64
+	const serialized = `{"iss":"github.com/lestrrat-go/jwx","nested":{"id":9007199254740993},"snowflake":9223372036854775807}`
+
+	t.Run("Default", func(t *testing.T) {
+		t.Parallel()
+		tok, err := jwt.ParseInsecure([]byte(serialized))
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
+			return
+		}
+		v, _ := tok.Get("snowflake")
+		if !assert.IsType(t, float64(0), v, `value should be a float64`) {
+			return
+		}
+	})
+	t.Run("NumberAsFloat64", func(t *testing.T) {
+		t.Parallel()
+		tok, err := jwt.ParseInsecure([]byte(serialized), jwt.WithNumberFormat(jwx.NumberAsFloat64))
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
+			return
+		}
+		v, _ := tok.Get("snowflake")
+		if !assert.IsType(t, float64(0), v, `value should be a float64`) {
+			return
+		}
+	})
+	t.Run("NumberAsJSONNumber", func(t *testing.T) {
+		t.Parallel()
+		tok, err := jwt.ParseInsecure([]byte(serialized), jwt.WithNumberFormat(jwx.NumberAsJSONNumber))
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
+			return
+		}
+
+		v, _ := tok.Get("snowflake")
+		if !assert.Equal(t, json.Number("9223372036854775807"), v, `value should be a json.Number`) {
+			return
+		}
+
+		v, _ = tok.Get("nested")
+		if !assert.Equal(t, map[string]interface{}{"id": json.Number("9007199254740993")}, v, `nested value should be a json.Number`) {
+			return
+		}
+
+		// serializing the token again should preserve the numbers
+		buf, err := json.Marshal(tok)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		if !assert.Equal(t, serialized, string(buf), `serialized token should match`) {
+			return
+		}
+	})
+	t.Run("Signed token with typed claim", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwxtest.GenerateRsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+			return
+		}
+
+		tok, err := jwt.ParseInsecure([]byte(serialized), jwt.WithNumberFormat(jwx.NumberAsJSONNumber))
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
+			return
+		}
+
+		signed, err := jwt.Sign(tok, jwa.RS256, key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+
+		parsed, err := jwt.Parse(signed,
+			jwt.WithVerify(jwa.RS256, &key.PublicKey),
+			jwt.WithNumberFormat(jwx.NumberAsJSONNumber),
+			jwt.WithTypedClaim("nested", json.RawMessage{}),
+		)
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+
+		v, _ := parsed.Get("snowflake")
+		if !assert.Equal(t, json.Number("9223372036854775807"), v, `value should be a json.Number`) {
+			return
+		}
+		v, _ = parsed.Get("nested")
+		if !assert.Equal(t, json.RawMessage(`{"id":9007199254740993}`), v, `typed claim should be decoded`) {
+			return
+		}
+	})
+}
+
+func TestErrorKinds(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, `my-key`)
+	_ = key.Set(jwk.AlgorithmKey, jwa.RS256)
+
+	pubkey, err := jwk.PublicKeyOf(key)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+
+	otherkey, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+
+	tok := jwt.New()
+	_ = tok.Set(jwt.IssuerKey, `github.com/lestrrat-go/jwx`)
+	_ = tok.Set(jwt.ExpirationKey, time.Now().Add(-time.Hour))
+	signed, err := jwt.Sign(tok, jwa.RS256, key)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	set := jwk.NewSet()
+	set.Add(pubkey)
+
+	otherset := jwk.NewSet()
+	_ = otherkey.Set(jwk.KeyIDKey, `other-key`)
+	otherset.Add(otherkey)
+
+	testcases := []struct {
+		Name   string
+		Parse  func() error
+		Kind   error
+		Target interface{}
+	}{
+		{
+			Name: "Parse",
+			Parse: func() error {
+				_, err := jwt.Parse([]byte(`foo.bar.baz`), jwt.WithVerify(jwa.RS256, pubkey))
+				return err
+			},
+			Kind:   jwx.ErrParse,
+			Target: new(*jws.ParseError),
+		},
+		{
+			Name: "Verification",
+			Parse: func() error {
+				_, err := jwt.Parse(signed, jwt.WithVerify(jwa.RS256, otherkey))
+				return err
+			},
+			Kind:   jwx.ErrVerification,
+			Target: new(*jws.VerificationError),
+		},
+		{
+			Name: "KeyResolution",
+			Parse: func() error {
+				_, err := jwt.Parse(signed, jwt.WithKeySet(otherset))
+				return err
+			},
+			Kind:   jwx.ErrKeyResolution,
+			Target: new(*jwt.KeyResolutionError),
+		},
+		{
+			Name: "Validation",
+			Parse: func() error {
+				_, err := jwt.Parse(signed, jwt.WithKeySet(set), jwt.WithValidate(true))
+				return err
+			},
+			Kind:   jwt.ErrTokenExpired,
+			Target: new(*jwt.ValidationError),
+		},
+	}
+
+	kinds := []error{jwx.ErrParse, jwx.ErrVerification, jwx.ErrKeyResolution, jwx.ErrValidation}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.Parse()
+			if !assert.Error(t, err, `jwt.Parse should fail`) {
+				return
+			}
+			if !assert.True(t, errors.Is(err, tc.Kind), `errors.Is(%s) should be true`, tc.Kind) {
+				return
+			}
+			if !assert.True(t, errors.As(err, tc.Target), `errors.As(%T) should be true`, tc.Target) {
+				return
+			}
+
+			var matched int
+			for _, kind := range kinds {
+				if errors.Is(err, kind) {
+					matched++
+				}
+			}
+			if !assert.Equal(t, 1, matched, `error should match exactly one kind`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Validator", func(t *testing.T) {
+		t.Parallel()
+		cause := errors.New(`custom validation failure`)
+		err := jwt.Validate(jwt.New(), jwt.WithValidator(jwt.ValidatorFunc(func(jwt.Token) error {
+			return cause
+		})))
+		if !assert.True(t, errors.Is(err, jwx.ErrValidation), `errors.Is(jwx.ErrValidation) should be true`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, cause), `errors.Is(cause) should be true`) {
+			return
+		}
+	})
+}
+
+func TestContext(t *testing.T) {
+	t.Parallel()
+
+	key := jwxtest.GenerateSymmetricKey()
+	signed, err := jwt.Sign(jwt.New(), jwa.HS256, key)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	t.Run("Active", func(t *testing.T) {
+		t.Parallel()
+		v, err := jwt.SignContext(context.Background(), jwt.New(), jwa.HS256, key)
+		if !assert.NoError(t, err, `jwt.SignContext should succeed`) {
+			return
+		}
+		if _, err := jwt.ParseContext(context.Background(), v, jwt.WithVerify(jwa.HS256, key)); !assert.NoError(t, err, `jwt.ParseContext should succeed`) {
+			return
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := jwt.SignContext(ctx, jwt.New(), jwa.HS256, key)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jwt.SignContext should fail with context.Canceled`) {
+			return
+		}
+		_, err = jwt.ParseContext(ctx, signed, jwt.WithVerify(jwa.HS256, key))
+		if !assert.True(t, errors.Is(err, context.Canceled), `jwt.ParseContext should fail with context.Canceled`) {
+			return
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, `https://github.com/lestrrat-go/jwx`, nil)
+		if !assert.NoError(t, err, `http.NewRequestWithContext should succeed`) {
+			return
+		}
+		req.Header.Set(`Authorization`, `Bearer `+string(signed))
+		if _, err := jwt.ParseRequest(req, jwt.WithVerify(jwa.HS256, key)); !assert.Error(t, err, `jwt.ParseRequest should fail`) {
+			return
+		}
+	})
+}
+
+func TestDiff(t *testing.T) {
+	now := time.Now()
+
+	t1 := jwt.New()
+	t1.Set(jwt.AudienceKey, []string{`foo`, `bar`})
+	t1.Set(jwt.IssuedAtKey, now)
+	t1.Set(jwt.IssuerKey, `github.com/lestrrat-go/jwx`)
+	t1.Set(`count`, 1)
+	t1.Set(`removed`, `value`)
+
+	t2 := jwt.New()
+	t2.Set(jwt.AudienceKey, []string{`foo`, `bar`})
+	t2.Set(jwt.IssuedAtKey, now.Round(0))
+	t2.Set(jwt.IssuerKey, `github.com/lestrrat-go/jwx`)
+	t2.Set(`count`, float64(1))
+	t2.Set(`removed`, `value`)
+
+	t.Run("Equal tokens", func(t *testing.T) {
+		if !assert.True(t, jwt.Equal(t1, t2), `jwt.Equal should be true`) {
+			return
+		}
+		if !assert.Len(t, jwt.Diff(t1, t2), 0, `jwt.Diff should be empty`) {
+			return
+		}
+	})
+	t.Run("Different tokens", func(t *testing.T) {
+		t3, err := t2.Clone()
+		if !assert.NoError(t, err, `t2.Clone should succeed`) {
+			return
+		}
+		t3.Set(jwt.AudienceKey, []string{`foo`})
+		t3.Set(`added`, true)
+		t3.Remove(`removed`)
+
+		if !assert.False(t, jwt.Equal(t1, t3), `jwt.Equal should be false`) {
+			return
+		}
+
+		expected := []jwt.ClaimDiff{
+			{Name: `added`, Right: true},
+			{Name: jwt.AudienceKey, Left: []string{`foo`, `bar`}, Right: []string{`foo`}},
+			{Name: `removed`, Left: `value`},
+		}
+		if !assert.Equal(t, expected, jwt.Diff(t1, t3), `jwt.Diff should match`) {
+			return
+		}
+	})
+	t.Run("Large integers", func(t *testing.T) {
+		// 2^53 + 1 and 2^53 are the same number when converted to float64
+		t3 := jwt.New()
+		t3.Set(`id`, int64(9007199254740993))
+		t4 := jwt.New()
+		t4.Set(`id`, int64(9007199254740992))
+		if !assert.False(t, jwt.Equal(t3, t4), `jwt.Equal should be false`) {
+			return
+		}
+
+		t4.Set(`id`, json.Number(`9007199254740993`))
+		if !assert.True(t, jwt.Equal(t3, t4), `jwt.Equal should be true`) {
+			return
+		}
+
+		t4.Set(`id`, json.Number(`9007199254740992`))
+		if !assert.False(t, jwt.Equal(t3, t4), `jwt.Equal should be false`) {
+			return
+		}
+
+		t3.Set(`id`, int64(9007199254740992))
+		t4.Set(`id`, float64(9007199254740992))
+		if !assert.True(t, jwt.Equal(t3, t4), `jwt.Equal should be true`) {
+			return
+		}
+		t3.Set(`id`, json.Number(`1.5`))
+		t4.Set(`id`, float64(1.5))
+		if !assert.True(t, jwt.Equal(t3, t4), `jwt.Equal should be true`) {
+			return
+		}
+	})
+	t.Run("nil token", func(t *testing.T) {
+		diffs := jwt.Diff(nil, t1)
+		if !assert.Len(t, diffs, 5, `jwt.Diff should report all claims`) {
+			return
+		}
+		if !assert.Len(t, jwt.Diff(nil, nil), 0, `jwt.Diff should be empty`) {
+			return
+		}
+	})
+	t.Run("Sub-second time claims", func(t *testing.T) {
+		// DO NOT MAKE THIS TEST PARALLEL. jwt.Settings() modifies global state
+		base := time.Unix(1600000000, 0)
+		t3 := jwt.New()
+		t3.Set(jwt.IssuedAtKey, base.Add(100*time.Millisecond))
+		t4 := jwt.New()
+		t4.Set(jwt.IssuedAtKey, base.Add(200*time.Millisecond))
+
+		if !assert.True(t, jwt.Equal(t3, t4), `jwt.Equal should be true at the default precision`) {
+			return
+		}
+
+		jwt.Settings(jwt.WithNumericDateFormatPrecision(3))
+		defer jwt.Settings(jwt.WithNumericDateFormatPrecision(0))
+		if !assert.False(t, jwt.Equal(t3, t4), `jwt.Equal should be false at millisecond precision`) {
+			return
+		}
+		if !assert.Len(t, jwt.Diff(t3, t4), 1, `jwt.Diff should report "iat"`) {
+			return
+		}
+		t4.Set(jwt.IssuedAtKey, base.Add(100*time.Millisecond+time.Microsecond))
+		if !assert.True(t, jwt.Equal(t3, t4), `jwt.Equal should ignore digits beyond the precision`) {
+			return
+		}
+	})
+}
+
+func TestClientAssertion(t *testing.T) {
+	t.Parallel()
+
+	const clientID = `s6BhdRkqt3`
+	const tokenURL = `https://server.example.com/token`
+
+	key, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	t.Run("private_key_jwt", func(t *testing.T) {
+		t.Parallel()
+		now := time.Unix(time.Now().Unix(), 0).UTC()
+		signed, err := jwt.NewClientAssertion(clientID, tokenURL, key, jwt.WithAssertionClock(jwt.ClockFunc(func() time.Time { return now })), jwt.WithAssertionLifetime(time.Minute))
+		if !assert.NoError(t, err, `jwt.NewClientAssertion should succeed`) {
+			return
+		}
+
+		msg, err := jws.Parse(signed)
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, jwa.ES256, msg.Signatures()[0].ProtectedHeaders().Algorithm(), `alg should be chosen from the key`) {
+			return
+		}
+
+		tok, err := jwt.ParseClientAssertion(signed, tokenURL, jwt.WithVerify(jwa.ES256, &key.PublicKey))
+		if !assert.NoError(t, err, `jwt.ParseClientAssertion should succeed`) {
+			return
+		}
+		if !assert.Equal(t, clientID, tok.Issuer(), `iss should match`) {
+			return
+		}
+		if !assert.Equal(t, clientID, tok.Subject(), `sub should match`) {
+			return
+		}
+		if !assert.NotEmpty(t, tok.JwtID(), `jti should be populated`) {
+			return
+		}
+		if !assert.Equal(t, now.Add(time.Minute), tok.Expiration(), `exp should match`) {
+			return
+		}
+
+		_, err = jwt.ParseClientAssertion(signed, `https://other.example.com/token`, jwt.WithVerify(jwa.ES256, &key.PublicKey))
+		if !assert.Error(t, err, `jwt.ParseClientAssertion should fail for wrong audience`) {
+			return
+		}
+	})
+	t.Run("client_secret_jwt", func(t *testing.T) {
+		t.Parallel()
+		secret := []byte(`a-very-long-client-secret-shared-with-the-server`)
+		signed, err := jwt.NewClientAssertion(clientID, tokenURL, secret, jwt.WithAssertionAlgorithm(jwa.HS512))
+		if !assert.NoError(t, err, `jwt.NewClientAssertion should succeed`) {
+			return
+		}
+
+		_, err = jwt.ParseClientAssertion(signed, tokenURL, jwt.WithVerify(jwa.HS512, secret))
+		if !assert.NoError(t, err, `jwt.ParseClientAssertion should succeed`) {
+			return
+		}
+	})
+	t.Run("alg none", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.NewClientAssertion(clientID, tokenURL, nil, jwt.WithAssertionAlgorithm(jwa.NoSignature))
+		if !assert.Error(t, err, `jwt.NewClientAssertion should fail`) {
+			return
+		}
+	})
+	t.Run("iss != sub", func(t *testing.T) {
+		t.Parallel()
+		tok := jwt.New()
+		tok.Set(jwt.IssuerKey, clientID)
+		tok.Set(jwt.SubjectKey, `someone-else`)
+		tok.Set(jwt.AudienceKey, tokenURL)
+		tok.Set(jwt.JwtIDKey, `id`)
+		tok.Set(jwt.ExpirationKey, time.Now().Add(time.Minute))
+		signed, err := jwt.Sign(tok, jwa.ES256, key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+
+		_, err = jwt.ParseClientAssertion(signed, tokenURL, jwt.WithVerify(jwa.ES256, &key.PublicKey))
+		if !assert.Error(t, err, `jwt.ParseClientAssertion should fail`) {
+			return
+		}
+	})
+	t.Run("replay check", func(t *testing.T) {
+		t.Parallel()
+		signed, err := jwt.NewClientAssertion(clientID, tokenURL, key, jwt.WithAssertionJwtID(`unique-id`))
+		if !assert.NoError(t, err, `jwt.NewClientAssertion should succeed`) {
+			return
+		}
+
+		seen := make(map[string]time.Time)
+		check := jwt.WithAssertionReplayCheck(func(jti string, exp time.Time) error {
+			if _, ok := seen[jti]; ok {
+				return errors.Errorf(`jti %q has already been used`, jti)
+			}
+			seen[jti] = exp
+			return nil
+		})
+
+		_, err = jwt.ParseClientAssertion(signed, tokenURL, jwt.WithVerify(jwa.ES256, &key.PublicKey), check)
+		if !assert.NoError(t, err, `jwt.ParseClientAssertion should succeed`) {
+			return
+		}
+		if !assert.Contains(t, seen, `unique-id`, `jti should be recorded`) {
+			return
+		}
+
+		_, err = jwt.ParseClientAssertion(signed, tokenURL, jwt.WithVerify(jwa.ES256, &key.PublicKey), check)
+		if !assert.Error(t, err, `jwt.ParseClientAssertion should fail when replayed`) {
+			return
+		}
+	})
+}
+
+func TestVerificationCache(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, `my-key`)
+	_ = key.Set(jwk.AlgorithmKey, jwa.HS256)
+
+	set := jwk.NewSet()
+	set.Add(key)
+
+	signed, err := jwt.Sign(jwt.New(), jwa.HS256, key)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	cache := jwt.WithVerificationCache(10, time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, err := jwt.Parse(signed, jwt.WithKeySet(set), cache); !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+	}
+
+	// Rotate the key, keeping the same key ID
+	rotated, err := jwxtest.GenerateSymmetricJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+		return
+	}
+	_ = rotated.Set(jwk.KeyIDKey, `my-key`)
+	_ = rotated.Set(jwk.AlgorithmKey, jwa.HS256)
+	set.Replace(0, rotated)
+
+	_, err = jwt.Parse(signed, jwt.WithKeySet(set), cache)
+	if !assert.True(t, errors.Is(err, jwx.ErrVerification), `jwt.Parse should fail after the key has been rotated`) {
+		return
+	}
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	key := jwxtest.GenerateSymmetricKey()
+	sign := func(t *testing.T, tok jwt.Token) []byte {
+		t.Helper()
+		signed, err := jwt.Sign(tok, jwa.HS256, key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	var count int64
+	counter := jwt.WithValidator(jwt.ValidatorFunc(func(jwt.Token) error {
+		atomic.AddInt64(&count, 1)
+		return nil
+	}))
+	newCache := func(options ...jwt.CacheOption) *jwt.Cache {
+		return jwt.NewCache(append([]jwt.CacheOption{
+			jwt.WithCacheParseOptions(jwt.WithVerify(jwa.HS256, key), jwt.WithValidate(true), counter),
+		}, options...)...)
+	}
+
+	t.Run("Hit", func(t *testing.T) {
+		cache := newCache()
+		tok := jwt.New()
+		_ = tok.Set(jwt.SubjectKey, `lestrrat`)
+		signed := sign(t, tok)
+
+		atomic.StoreInt64(&count, 0)
+		for i := 0; i < 3; i++ {
+			parsed, err := cache.Parse(signed)
+			if !assert.NoError(t, err, `cache.Parse should succeed`) {
+				return
+			}
+			if !assert.Equal(t, `lestrrat`, parsed.Subject(), `subject should match`) {
+				return
+			}
+			// modifying the result should not affect the cached token
+			_ = parsed.Set(jwt.SubjectKey, `modified`)
+		}
+		if !assert.Equal(t, int64(1), atomic.LoadInt64(&count), `token should only be parsed once`) {
+			return
+		}
+	})
+	t.Run("Failures are not cached", func(t *testing.T) {
+		cache := newCache()
+		signed, err := jwt.Sign(jwt.New(), jwa.HS256, jwxtest.GenerateSymmetricKey())
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := cache.Parse(signed); !assert.True(t, errors.Is(err, jwx.ErrVerification), `cache.Parse should fail`) {
+				return
+			}
+		}
+		if !assert.Equal(t, 0, cache.Len(), `cache should be empty`) {
+			return
+		}
+	})
+	t.Run("Expiration", func(t *testing.T) {
+		cache := newCache()
+		tok := jwt.New()
+		_ = tok.Set(jwt.ExpirationKey, time.Now().Add(time.Second))
+		signed := sign(t, tok)
+
+		if _, err := cache.Parse(signed); !assert.NoError(t, err, `cache.Parse should succeed`) {
+			return
+		}
+		time.Sleep(1100 * time.Millisecond)
+		_, err := cache.Parse(signed)
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenExpired), `cache.Parse should fail once the token expires`) {
+			return
+		}
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		var mu sync.Mutex
+		var parsed int
+		release := make(chan struct{})
+		cache := jwt.NewCache(jwt.WithCacheParseOptions(
+			jwt.WithVerify(jwa.HS256, key),
+			jwt.WithValidate(true),
+			jwt.WithValidator(jwt.ValidatorFunc(func(jwt.Token) error {
+				mu.Lock()
+				parsed++
+				mu.Unlock()
+				<-release
+				return nil
+			})),
+		))
+		signed := sign(t, jwt.New())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := cache.Parse(signed); err != nil {
+					t.Errorf(`cache.Parse should succeed: %s`, err)
+				}
+			}()
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if !assert.Equal(t, 1, parsed, `token should only be parsed once`) {
+			return
+		}
+	})
+	t.Run("Clock", func(t *testing.T) {
+		// the token has expired in real time, but not according to the clock
+		now := time.Now().Add(-time.Hour)
+		cache := jwt.NewCache(jwt.WithCacheParseOptions(
+			jwt.WithVerify(jwa.HS256, key),
+			jwt.WithValidate(true),
+			jwt.WithClock(jwt.ClockFunc(func() time.Time { return now })),
+		))
+		tok := jwt.New()
+		_ = tok.Set(jwt.ExpirationKey, now.Add(time.Minute))
+		signed := sign(t, tok)
+
+		if _, err := cache.Parse(signed); !assert.NoError(t, err, `cache.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1, cache.Len(), `token should be cached`) {
+			return
+		}
+	})
+	t.Run("Panic", func(t *testing.T) {
+		var panicked int64
+		cache := jwt.NewCache(jwt.WithCacheParseOptions(
+			jwt.WithVerify(jwa.HS256, key),
+			jwt.WithValidate(true),
+			jwt.WithValidator(jwt.ValidatorFunc(func(jwt.Token) error {
+				if atomic.CompareAndSwapInt64(&panicked, 0, 1) {
+					panic(`validator panicked`)
+				}
+				return nil
+			})),
+		))
+		signed := sign(t, jwt.New())
+
+		func() {
+			defer func() { _ = recover() }()
+			_, _ = cache.Parse(signed)
+		}()
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := cache.Parse(signed)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if !assert.NoError(t, err, `cache.Parse should succeed`) {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal(`cache.Parse should not block after a panic`)
+		}
+	})
+	t.Run("Replay protection disables caching", func(t *testing.T) {
+		cache := jwt.NewCache(jwt.WithCacheParseOptions(
+			jwt.WithVerify(jwa.HS256, key),
+			jwt.WithValidate(true),
+			jwt.WithReplayProtection(jwt.NewMemoryJTIStore(time.Minute)),
+		))
+		tok := jwt.New()
+		_ = tok.Set(jwt.JwtIDKey, `unique`)
+		signed := sign(t, tok)
+
+		if _, err := cache.Parse(signed); !assert.NoError(t, err, `cache.Parse should succeed`) {
+			return
+		}
+		_, err := cache.Parse(signed)
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenReplayed), `cache.Parse should reject the replayed token`) {
+			return
+		}
+	})
+}
+
+func TestKeyProviderForSigning(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.AlgorithmKey, jwa.RS256)
+
+	pubkey, err := jwk.PublicKeyOf(key)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	_ = pubkey.Set(jwk.KeyIDKey, `rotated`)
+	set := jwk.NewSet()
+	set.Add(pubkey)
+
+	provider := func(context.Context) (jwa.SignatureAlgorithm, interface{}, string, error) {
+		return jwa.RS256, key, `rotated`, nil
+	}
+	signed, err := jwt.Sign(jwt.New(), "", nil, jwt.WithKeyProviderForSigning(provider))
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+	if _, err := jwt.Parse(signed, jwt.WithKeySet(set)); !assert.NoError(t, err, `jwt.Parse should succeed`) {
+		return
+	}
+}
+
+func TestMultiIssuerVerifier(t *testing.T) {
+	t.Parallel()
+
+	type issuer struct {
+		name string
+		alg  jwa.SignatureAlgorithm
+		key  jwk.Key
+		set  jwk.Set
+	}
+	newIssuer := func(t *testing.T, name string, alg jwa.SignatureAlgorithm, kid string) *issuer {
+		t.Helper()
+		var key jwk.Key
+		var err error
+		switch alg {
+		case jwa.RS256:
+			key, err = jwxtest.GenerateRsaJwk()
+		default:
+			key, err = jwxtest.GenerateEcdsaJwk()
+		}
+		if !assert.NoError(t, err, `key generation should succeed`) {
+			t.FailNow()
+		}
+		_ = key.Set(jwk.KeyIDKey, kid)
+		_ = key.Set(jwk.AlgorithmKey, alg)
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			t.FailNow()
+		}
+		set := jwk.NewSet()
+		set.Add(pubkey)
+		return &issuer{name: name, alg: alg, key: key, set: set}
+	}
+
+	a := newIssuer(t, `https://a.example.com`, jwa.RS256, `a`)
+	b := newIssuer(t, `https://b.example.com`, jwa.ES256, `b`)
+
+	// issuer A also publishes a key for an algorithm that is not allowed
+	rs512 := newIssuer(t, a.name, jwa.RS256, `a-rs512`)
+	_ = rs512.key.Set(jwk.AlgorithmKey, jwa.RS512)
+	rs512pub, err := jwk.PublicKeyOf(rs512.key)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	a.set.Add(rs512pub)
+
+	sign := func(t *testing.T, signer *issuer, claims map[string]interface{}) []byte {
+		t.Helper()
+		tok := jwt.New()
+		for k, v := range claims {
+			_ = tok.Set(k, v)
+		}
+		signed, err := jwt.Sign(tok, signer.alg, signer.key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	// the header claims an allowed algorithm, but the signature is
+	// made (and verified) using the "alg" of the key
+	mismatched := func() []byte {
+		hdr := []byte(`{"alg":"RS256","kid":"a-rs512"}`)
+		payload, err := json.Marshal(map[string]interface{}{jwt.IssuerKey: a.name, jwt.AudienceKey: `api-1`})
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			t.FailNow()
+		}
+		input := base64.RawURLEncoding.EncodeToString(hdr) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		signer, err := jws.NewSigner(jwa.RS512)
+		if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+			t.FailNow()
+		}
+		signature, err := signer.Sign([]byte(input), rs512.key)
+		if !assert.NoError(t, err, `signer.Sign should succeed`) {
+			t.FailNow()
+		}
+		return []byte(input + "." + base64.RawURLEncoding.EncodeToString(signature))
+	}()
+
+	multi := func() []byte {
+		payload, err := json.Marshal(map[string]interface{}{jwt.IssuerKey: a.name, jwt.AudienceKey: `api-1`})
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			t.FailNow()
+		}
+		var options []jws.Option
+		for _, kid := range []string{`a`, `a`} {
+			signer, err := jws.NewSigner(jwa.RS256)
+			if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+				t.FailNow()
+			}
+			protected := jws.NewHeaders()
+			_ = protected.Set(jws.KeyIDKey, kid)
+			options = append(options, jws.WithSigner(signer, a.key, nil, protected))
+		}
+		signed, err := jws.SignMulti(payload, options...)
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}()
+
+	v := jwt.NewMultiIssuerVerifier(
+		jwt.WithIssuerConfig(a.name,
+			jwt.WithIssuerKeySet(a.set),
+			jwt.WithIssuerAlgorithms(jwa.RS256),
+			jwt.WithIssuerAudience(`api-1`, `api-2`),
+		),
+		jwt.WithIssuerConfig(b.name,
+			jwt.WithIssuerKeySet(b.set),
+			jwt.WithIssuerAcceptableSkew(time.Hour),
+		),
+	)
+
+	testcases := []struct {
+		Name  string
+		Token []byte
+		Error error
+	}{
+		{
+			Name:  "Issuer A",
+			Token: sign(t, a, map[string]interface{}{jwt.IssuerKey: a.name, jwt.AudienceKey: `api-2`}),
+		},
+		{
+			Name:  "Issuer B, within skew",
+			Token: sign(t, b, map[string]interface{}{jwt.IssuerKey: b.name, jwt.ExpirationKey: time.Now().Add(-time.Minute)}),
+		},
+		{
+			Name:  "Issuer A, wrong audience",
+			Token: sign(t, a, map[string]interface{}{jwt.IssuerKey: a.name, jwt.AudienceKey: `api-3`}),
+			Error: jwt.ErrInvalidAudience,
+		},
+		{
+			Name:  "Unknown issuer",
+			Token: sign(t, a, map[string]interface{}{jwt.IssuerKey: `https://c.example.com`}),
+			Error: jwt.ErrInvalidIssuer,
+		},
+		{
+			Name:  "Signed with the key of another issuer",
+			Token: sign(t, b, map[string]interface{}{jwt.IssuerKey: a.name, jwt.AudienceKey: `api-1`}),
+			Error: jwx.ErrVerification,
+		},
+		{
+			Name:  "Key not in the issuer's key set",
+			Token: sign(t, a, map[string]interface{}{jwt.IssuerKey: b.name}),
+			Error: jwx.ErrKeyResolution,
+		},
+		{
+			Name:  "Header and key algorithms differ",
+			Token: mismatched,
+			Error: jwx.ErrKeyResolution,
+		},
+		{
+			Name:  "Multiple signatures",
+			Token: multi,
+			Error: jwx.ErrVerification,
+		},
+		{
+			Name:  "No issuer",
+			Token: sign(t, a, map[string]interface{}{jwt.AudienceKey: `api-1`}),
+			Error: jwt.ErrInvalidIssuer,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			tok, err := v.Parse(tc.Token)
+			if tc.Error == nil {
+				if !assert.NoError(t, err, `v.Parse should succeed`) {
+					return
+				}
+				if !assert.NotNil(t, tok, `token should be returned`) {
+					return
+				}
+				return
+			}
+			if !assert.True(t, errors.Is(err, tc.Error), `v.Parse should fail with %s, got %v`, tc.Error, err) {
+				return
+			}
+		})
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	t.Parallel()
+
+	const response = `{
+  "active": true,
+  "client_id": "l238j323ds-23ij4",
+  "username": "jdoe",
+  "scope": "read write dolphin",
+  "sub": "Z5O3upPC88QrAjx00dis",
+  "aud": "https://protected.example.net/resource",
+  "iss": "https://server.example.com/",
+  "exp": 1419356238,
+  "iat": 1419350238,
+  "extension_field": "twenty-seven"
+}`
+
+	t.Run("Parse active token", func(t *testing.T) {
+		t.Parallel()
+		tok, err := jwt.ParseIntrospection([]byte(response))
+		if !assert.NoError(t, err, `jwt.ParseIntrospection should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `Z5O3upPC88QrAjx00dis`, tok.Subject(), `"sub" should match`) {
+			return
+		}
+		if !assert.Equal(t, int64(1419356238), tok.Expiration().Unix(), `"exp" should match`) {
+			return
+		}
+		if v, _ := tok.Get(jwt.ScopeKey); !assert.Equal(t, `read write dolphin`, v, `"scope" should match`) {
+			return
+		}
+		if v, _ := tok.Get(`extension_field`); !assert.Equal(t, `twenty-seven`, v, `extensions should be preserved`) {
+			return
+		}
+		if _, ok := tok.Get(jwt.ActiveKey); !assert.False(t, ok, `"active" should not be a claim`) {
+			return
+		}
+
+		// The token is expired, which is only checked when validation is requested
+		_, err = jwt.ParseIntrospection([]byte(response), jwt.WithValidate(true))
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenExpired), `jwt.ParseIntrospection should fail with jwt.ErrTokenExpired`) {
+			return
+		}
+		clock := jwt.ClockFunc(func() time.Time { return time.Unix(1419353238, 0) })
+		_, err = jwt.ParseIntrospection([]byte(response), jwt.WithValidate(true), jwt.WithClock(clock), jwt.WithAudience(`https://protected.example.net/resource`))
+		if !assert.NoError(t, err, `jwt.ParseIntrospection should succeed`) {
+			return
+		}
+	})
+	t.Run("Parse into openid.Token", func(t *testing.T) {
+		t.Parallel()
+		tok, err := jwt.ParseIntrospection([]byte(response), jwt.WithToken(openid.New()))
+		if !assert.NoError(t, err, `jwt.ParseIntrospection should succeed`) {
+			return
+		}
+		if _, ok := tok.(openid.Token); !assert.True(t, ok, `token should be an openid.Token`) {
+			return
+		}
+	})
+	t.Run("Parse inactive token", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.ParseIntrospection([]byte(`{"active":false}`))
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenInactive), `jwt.ParseIntrospection should fail with jwt.ErrTokenInactive`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, jwx.ErrValidation), `error should match jwx.ErrValidation`) {
+			return
+		}
+	})
+	t.Run("Parse invalid responses", func(t *testing.T) {
+		t.Parallel()
+		for _, src := range []string{`{"sub":"foo"}`, `{"active":"true"}`, `not json`} {
+			_, err := jwt.ParseIntrospection([]byte(src))
+			if !assert.True(t, errors.Is(err, jwx.ErrParse), `jwt.ParseIntrospection should fail with jwx.ErrParse for %s`, src) {
+				return
+			}
+		}
+	})
+	t.Run("Marshal", func(t *testing.T) {
+		t.Parallel()
+		buf, err := jwt.MarshalIntrospection(nil)
+		if !assert.NoError(t, err, `jwt.MarshalIntrospection should succeed`) {
+			return
+		}
+		if !assert.JSONEq(t, `{"active":false}`, string(buf), `inactive response should only contain "active"`) {
+			return
+		}
+
+		buf, err = jwt.MarshalIntrospection(jwt.New())
+		if !assert.NoError(t, err, `jwt.MarshalIntrospection should succeed`) {
+			return
+		}
+		if !assert.JSONEq(t, `{"active":true}`, string(buf), `empty token should only contain "active"`) {
+			return
+		}
+
+		tok, err := jwt.ParseIntrospection([]byte(response))
+		if !assert.NoError(t, err, `jwt.ParseIntrospection should succeed`) {
+			return
+		}
+		// a claim named "active" must not override the flag
+		tok.Set(jwt.ActiveKey, false)
+		buf, err = jwt.MarshalIntrospection(tok)
+		if !assert.NoError(t, err, `jwt.MarshalIntrospection should succeed`) {
+			return
+		}
+		// "aud" is marshaled as an array unless jwt.WithFlattenAudience is in effect
+		expected := strings.Replace(response, `"aud": "https://protected.example.net/resource"`, `"aud": ["https://protected.example.net/resource"]`, 1)
+		if !assert.JSONEq(t, expected, string(buf), `response should round trip`) {
+			return
+		}
+		if v, _ := tok.Get(jwt.ActiveKey); !assert.Equal(t, false, v, `original token should not be modified`) {
+			return
+		}
+	})
+}
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, `key-1`)
+
+	exp := time.Unix(time.Now().Add(time.Hour).Unix(), 0).UTC()
+	tok := jwt.New()
+	tok.Set(jwt.IssuerKey, `https://issuer.example.com`)
+	tok.Set(jwt.AudienceKey, `service`)
+	tok.Set(jwt.ExpirationKey, exp)
+	tok.Set(`email`, `alice@example.com`)
+
+	t.Run("JWS", func(t *testing.T) {
+		t.Parallel()
+		signed, err := jwt.Sign(tok, jwa.RS256, key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+
+		hi, ci, err := jwt.Inspect(signed)
+		if !assert.NoError(t, err, `jwt.Inspect should succeed`) {
+			return
+		}
+		expectedHeader := jwt.HeaderInfo{Algorithm: `RS256`, KeyID: `key-1`, Type: `JWT`}
+		if !assert.Equal(t, expectedHeader, hi, `header should match`) {
+			return
+		}
+		expectedClaims := jwt.ClaimInfo{Issuer: `https://issuer.example.com`, Audience: []string{`service`}, Expiration: exp}
+		if !assert.Equal(t, expectedClaims, ci, `claims should match`) {
+			return
+		}
+	})
+	t.Run("JWE", func(t *testing.T) {
+		t.Parallel()
+		buf, err := json.Marshal(tok)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+		encrypted, err := jwe.Encrypt(buf, jwa.RSA_OAEP, pubkey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		hi, ci, err := jwt.Inspect(encrypted)
+		if !assert.NoError(t, err, `jwt.Inspect should succeed`) {
+			return
+		}
+		if !assert.True(t, hi.Encrypted, `token should be reported as encrypted`) {
+			return
+		}
+		if !assert.Equal(t, `RSA-OAEP`, hi.Algorithm, `"alg" should match`) {
+			return
+		}
+		if !assert.Equal(t, jwt.ClaimInfo{}, ci, `claims should not be available`) {
+			return
+		}
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		t.Parallel()
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+		payload := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://issuer.example.com","exp":"tomorrow"}`))
+
+		hi, ci, err := jwt.Inspect([]byte(header + `.` + payload + `.`))
+		if !assert.Error(t, err, `jwt.Inspect should fail`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `error should match jwx.ErrParse`) {
+			return
+		}
+		if !assert.Equal(t, `none`, hi.Algorithm, `"alg" should be available`) {
+			return
+		}
+		if !assert.Equal(t, `https://issuer.example.com`, ci.Issuer, `valid claims should be available`) {
+			return
+		}
+
+		_, _, err = jwt.Inspect([]byte(`not a token`))
+		if !assert.Error(t, err, `jwt.Inspect should fail`) {
+			return
+		}
+	})
+}
+
+func TestMint(t *testing.T) {
+	t.Parallel()
+
+	key := jwxtest.GenerateSymmetricKey()
+
+	t.Run("jwt.Sign", func(t *testing.T) {
+		t.Parallel()
+		tok := jwt.New()
+		_ = tok.Set(jwt.SubjectKey, `alice`)
+
+		before := time.Now().Truncate(time.Second)
+		signed, err := jwt.Sign(tok, jwa.HS256, key, jwt.WithTTL(time.Hour), jwt.WithAutoJTI(), jwt.WithNotBeforeSkew(30*time.Second))
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+		if !assert.True(t, tok.IssuedAt().IsZero(), `original token should not be modified`) {
+			return
+		}
+
+		parsed, err := jwt.Parse(signed, jwt.WithVerify(jwa.HS256, key), jwt.WithValidate(true))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+		iat := parsed.IssuedAt()
+		if !assert.False(t, iat.Before(before), `"iat" should be set to the current time`) {
+			return
+		}
+		if !assert.Equal(t, iat.Add(time.Hour), parsed.Expiration(), `"exp" should be "iat" + TTL`) {
+			return
+		}
+		if !assert.Equal(t, iat.Add(-30*time.Second), parsed.NotBefore(), `"nbf" should be "iat" - skew`) {
+			return
+		}
+		if !assert.NotEmpty(t, parsed.JwtID(), `"jti" should be set`) {
+			return
+		}
+
+		signed2, err := jwt.Sign(tok, jwa.HS256, key, jwt.WithAutoJTI())
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+		parsed2, err := jwt.Parse(signed2, jwt.WithVerify(jwa.HS256, key))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+		if !assert.NotEqual(t, parsed.JwtID(), parsed2.JwtID(), `"jti" should be unique`) {
+			return
+		}
+	})
+	t.Run("Existing claims are kept", func(t *testing.T) {
+		t.Parallel()
+		iat := time.Unix(1600000000, 0).UTC()
+		exp := time.Unix(1600000060, 0).UTC()
+		tok := jwt.New()
+		_ = tok.Set(jwt.IssuedAtKey, iat)
+		_ = tok.Set(jwt.ExpirationKey, exp)
+		_ = tok.Set(jwt.JwtIDKey, `fixed`)
+
+		minted, err := jwt.Mint(tok, jwt.WithTTL(time.Hour), jwt.WithAutoJTI(), jwt.WithNotBeforeSkew(time.Minute))
+		if !assert.NoError(t, err, `jwt.Mint should succeed`) {
+			return
+		}
+		if !assert.Equal(t, iat, minted.IssuedAt(), `"iat" should not be modified`) {
+			return
+		}
+		if !assert.Equal(t, exp, minted.Expiration(), `"exp" should not be modified`) {
+			return
+		}
+		if !assert.Equal(t, `fixed`, minted.JwtID(), `"jti" should not be modified`) {
+			return
+		}
+		if !assert.Equal(t, iat.Add(-time.Minute), minted.NotBefore(), `"nbf" should be computed from "iat"`) {
+			return
+		}
+	})
+	t.Run("Invalid TTL", func(t *testing.T) {
+		t.Parallel()
+		if _, err := jwt.Sign(jwt.New(), jwa.HS256, key, jwt.WithTTL(0)); !assert.Error(t, err, `jwt.Sign should fail`) {
+			return
+		}
+	})
+}
+
+func TestSchemaFor(t *testing.T) {
+	type account struct {
+		ID    int      `json:"id"`
+		Roles []string `json:"roles,omitempty"`
+	}
+	jwt.RegisterCustomField(`x-schema-account`, account{})
+	defer jwt.RegisterCustomField(`x-schema-account`, nil)
+
+	t.Run("jwt.Token", func(t *testing.T) {
+		schema, err := jwt.SchemaFor(jwt.New())
+		if !assert.NoError(t, err, `jwt.SchemaFor should succeed`) {
+			return
+		}
+		if !assert.Equal(t, jwt.JSONSchemaDialect, schema[`$schema`], `"$schema" should match`) {
+			return
+		}
+
+		props, ok := schema[`properties`].(map[string]interface{})
+		if !assert.True(t, ok, `"properties" should be a map`) {
+			return
+		}
+		for _, name := range []string{jwt.AudienceKey, jwt.ExpirationKey, jwt.IssuedAtKey, jwt.IssuerKey, jwt.JwtIDKey, jwt.NotBeforeKey, jwt.SubjectKey} {
+			if !assert.Contains(t, props, name, `%q should be described`, name) {
+				return
+			}
+		}
+		if !assert.Equal(t, map[string]interface{}{`type`: `number`}, props[jwt.ExpirationKey], `"exp" should be a number`) {
+			return
+		}
+
+		expected := map[string]interface{}{
+			`type`: `object`,
+			`properties`: map[string]interface{}{
+				`id`: map[string]interface{}{`type`: `integer`},
+				`roles`: map[string]interface{}{
+					`type`:  `array`,
+					`items`: map[string]interface{}{`type`: `string`},
+				},
+			},
+		}
+		if !assert.Equal(t, expected, props[`x-schema-account`], `custom field should be described`) {
+			return
+		}
+
+		if _, err := json.Marshal(schema); !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+	})
+	t.Run("openid.Token", func(t *testing.T) {
+		schema, err := jwt.SchemaFor(openid.New())
+		if !assert.NoError(t, err, `jwt.SchemaFor should succeed`) {
+			return
+		}
+		props := schema[`properties`].(map[string]interface{})
+		if !assert.Equal(t, map[string]interface{}{`type`: `string`, `format`: `email`}, props[openid.EmailKey], `"email" should be described`) {
+			return
+		}
+		if !assert.Contains(t, props, openid.IssuerKey, `"iss" should be described`) {
+			return
+		}
+		if !assert.Contains(t, props, `x-schema-account`, `custom field registered via jwt should be described`) {
+			return
+		}
+	})
+	t.Run("Unknown token type", func(t *testing.T) {
+		type wrappedToken struct {
+			jwt.Token
+		}
+		if _, err := jwt.SchemaFor(&wrappedToken{Token: jwt.New()}); !assert.Error(t, err, `jwt.SchemaFor should fail`) {
+			return
+		}
+	})
 }
@@ -0,0 +1,125 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/pkg/errors"
+)
+
+// minter holds the parameters specified via MintOptions
+type minter struct {
+	ttl     *time.Duration
+	autoJTI bool
+	nbfSkew *time.Duration
+}
+
+// newMinter returns nil if none of the options are MintOptions
+func newMinter(options []Option) *minter {
+	var m minter
+	var found bool
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identTTL{}:
+			v := option.Value().(time.Duration)
+			m.ttl = &v
+			found = true
+		case identAutoJTI{}:
+			m.autoJTI = option.Value().(bool)
+			found = true
+		case identNotBeforeSkew{}:
+			v := option.Value().(time.Duration)
+			m.nbfSkew = &v
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return &m
+}
+
+func (m *minter) mint(t Token) (Token, error) {
+	if m.ttl != nil && *m.ttl <= 0 {
+		return nil, errors.Errorf(`invalid TTL %s: must be positive`, *m.ttl)
+	}
+	if m.nbfSkew != nil && *m.nbfSkew < 0 {
+		return nil, errors.Errorf(`invalid not-before skew %s: must not be negative`, *m.nbfSkew)
+	}
+
+	t, err := t.Clone()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to clone token`)
+	}
+
+	iat := t.IssuedAt()
+	if iat.IsZero() {
+		iat = time.Now()
+		if err := t.Set(IssuedAtKey, iat); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, IssuedAtKey)
+		}
+	}
+
+	if m.autoJTI && t.JwtID() == "" {
+		jti, err := newJwtID()
+		if err != nil {
+			return nil, err
+		}
+		if err := t.Set(JwtIDKey, jti); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, JwtIDKey)
+		}
+	}
+
+	if m.ttl != nil && t.Expiration().IsZero() {
+		if err := t.Set(ExpirationKey, iat.Add(*m.ttl)); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, ExpirationKey)
+		}
+	}
+
+	if m.nbfSkew != nil && t.NotBefore().IsZero() {
+		if err := t.Set(NotBeforeKey, iat.Add(-*m.nbfSkew)); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, NotBeforeKey)
+		}
+	}
+
+	return t, nil
+}
+
+// newJwtID generates a random value for the "jti" claim
+func newJwtID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, `failed to generate jti`)
+	}
+	return base64.EncodeToString(buf), nil
+}
+
+// Mint returns a copy of the token, with the registered claims that are
+// commonly required when issuing tokens populated as follows:
+//
+//   * "iat" is set to the current time
+//   * "jti" is set to a random value, if `jwt.WithAutoJTI()` is specified
+//   * "exp" is set to "iat" + TTL, if `jwt.WithTTL()` is specified
+//   * "nbf" is set to "iat" - skew, if `jwt.WithNotBeforeSkew()` is specified
+//
+// Claims that are already set in the token are never overwritten. In
+// particular, "exp" and "nbf" are computed from the existing "iat" claim,
+// if present.
+//
+// The same options can be passed to `jwt.Sign()` and (jwt.Serializer).Sign,
+// in which case the token is minted before it is serialized. The token
+// passed to these functions is not modified.
+func Mint(t Token, options ...MintOption) (Token, error) {
+	list := make([]Option, len(options))
+	for i, option := range options {
+		list[i] = option
+	}
+
+	m := newMinter(list)
+	if m == nil {
+		m = &minter{}
+	}
+	return m.mint(t)
+}
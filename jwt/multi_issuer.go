@@ -0,0 +1,210 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
+)
+
+// MultiIssuerVerifier parses tokens that may have been issued by one
+// of several issuers, each with their own keys and requirements. This is
+// typically needed by multi-tenant services that accept tokens from
+// more than one identity provider.
+//
+//   v := jwt.NewMultiIssuerVerifier(
+//     jwt.WithIssuerConfig(`https://a.example.com`,
+//       jwt.WithIssuerAutoRefresh(ar, `https://a.example.com/jwks.json`),
+//       jwt.WithIssuerAlgorithms(jwa.RS256),
+//       jwt.WithIssuerAudience(`my-api`),
+//     ),
+//     jwt.WithIssuerConfig(`https://b.example.com`,
+//       jwt.WithIssuerKeySet(set),
+//       jwt.WithIssuerAcceptableSkew(time.Minute),
+//     ),
+//   )
+//   tok, err := v.Parse(src)
+//
+// The "iss" claim of the token is read without verification, and is only
+// used to select the configuration. The token is then verified against the
+// keys of that issuer, and validated (including the "iss" claim itself)
+// before it is returned.
+type MultiIssuerVerifier struct {
+	issuers map[string]*issuerConfig
+	options []ParseOption
+}
+
+type issuerConfig struct {
+	keySet      jwk.Set
+	autoRefresh *issuerAutoRefresh
+	algorithms  []jwa.SignatureAlgorithm
+	audiences   []string
+	skew        time.Duration
+}
+
+type issuerAutoRefresh struct {
+	ar  *jwk.AutoRefresh
+	url string
+}
+
+type issuerConfigSpec struct {
+	issuer  string
+	options []IssuerOption
+}
+
+// NewMultiIssuerVerifier creates a new MultiIssuerVerifier. Each issuer
+// must be registered using `jwt.WithIssuerConfig()`. Tokens from issuers
+// that have not been registered are rejected.
+func NewMultiIssuerVerifier(options ...MultiIssuerOption) *MultiIssuerVerifier {
+	v := &MultiIssuerVerifier{
+		issuers: make(map[string]*issuerConfig),
+	}
+
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identIssuerConfig{}:
+			spec := option.Value().(*issuerConfigSpec)
+			v.issuers[spec.issuer] = newIssuerConfig(spec.options)
+		case identMultiIssuerParseOptions{}:
+			v.options = append(v.options, option.Value().([]ParseOption)...)
+		}
+	}
+	return v
+}
+
+func newIssuerConfig(options []IssuerOption) *issuerConfig {
+	var c issuerConfig
+
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identIssuerKeySet{}:
+			c.keySet = option.Value().(jwk.Set)
+			c.autoRefresh = nil
+		case identIssuerAutoRefresh{}:
+			c.autoRefresh = option.Value().(*issuerAutoRefresh)
+			c.keySet = nil
+		case identIssuerAlgorithms{}:
+			c.algorithms = append(c.algorithms, option.Value().([]jwa.SignatureAlgorithm)...)
+		case identIssuerAudience{}:
+			c.audiences = append(c.audiences, option.Value().([]string)...)
+		case identIssuerAcceptableSkew{}:
+			c.skew = option.Value().(time.Duration)
+		}
+	}
+	return &c
+}
+
+// Parse selects the configuration for the issuer of the token, and
+// parses, verifies, and validates the token accordingly.
+func (v *MultiIssuerVerifier) Parse(src []byte) (Token, error) {
+	return v.ParseContext(context.Background(), src)
+}
+
+// ParseString is the same as Parse, but accepts a string
+func (v *MultiIssuerVerifier) ParseString(src string) (Token, error) {
+	return v.ParseContext(context.Background(), []byte(src))
+}
+
+// ParseContext is the same as Parse, but accepts a context.Context,
+// which is used to fetch the key set when `jwt.WithIssuerAutoRefresh()`
+// is used, and passed on to `jwt.ParseContext()`.
+func (v *MultiIssuerVerifier) ParseContext(ctx context.Context, src []byte) (Token, error) {
+	// The common options are applied here as well, so that limits
+	// such as `jwt.WithMaxTokenSize()` are honored before any of the
+	// contents are trusted
+	unverified, err := ParseInsecure(src, v.options...)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse token`)
+	}
+
+	iss := unverified.Issuer()
+	c, ok := v.issuers[iss]
+	if !ok {
+		return nil, newValidationError(IssuerKey, ErrInvalidIssuer, `issuer %q is not registered`, iss)
+	}
+
+	msg, err := jws.Parse(src)
+	if err != nil {
+		return nil, newParseError(errors.Wrap(err, `failed to parse token as a JWS message`))
+	}
+	if n := len(msg.Signatures()); n != 1 {
+		return nil, newVerificationError(errors.Errorf(`expected exactly one signature, found %d`, n))
+	}
+
+	if len(c.algorithms) > 0 {
+		alg := msg.Signatures()[0].ProtectedHeaders().Algorithm()
+		if !isAllowedAlgorithm(alg, c.algorithms) {
+			return nil, newVerificationError(errors.Errorf(`algorithm %q is not allowed for issuer %q`, alg, iss))
+		}
+	}
+
+	set := c.keySet
+	if ar := c.autoRefresh; ar != nil {
+		set, err = ar.ar.Fetch(ctx, ar.url)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to fetch key set for issuer %q`, iss)
+		}
+	}
+	if set == nil {
+		return nil, errors.Errorf(`no key set configured for issuer %q`, iss)
+	}
+
+	// The signature is verified using the "alg" of the key, not the
+	// one in the header, so the keys themselves must be restricted
+	if len(c.algorithms) > 0 {
+		set = allowedKeys(set, c.algorithms)
+	}
+
+	options := make([]ParseOption, 0, len(v.options)+5)
+	options = append(options, v.options...)
+	options = append(options,
+		WithKeySet(set),
+		WithValidate(true),
+		WithIssuer(iss),
+		WithAcceptableSkew(c.skew),
+	)
+	if audiences := c.audiences; len(audiences) > 0 {
+		options = append(options, WithValidator(ValidatorFunc(func(t Token) error {
+			for _, aud := range t.Audience() {
+				for _, candidate := range audiences {
+					if aud == candidate {
+						return nil
+					}
+				}
+			}
+			return newValidationError(AudienceKey, ErrInvalidAudience, `aud not satisfied`)
+		})))
+	}
+
+	return ParseContext(ctx, src, options...)
+}
+
+func isAllowedAlgorithm(alg jwa.SignatureAlgorithm, algorithms []jwa.SignatureAlgorithm) bool {
+	for _, candidate := range algorithms {
+		if alg == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedKeys returns a new set containing only the keys whose "alg"
+// is one of the given algorithms
+func allowedKeys(set jwk.Set, algorithms []jwa.SignatureAlgorithm) jwk.Set {
+	allowed := jwk.NewSet()
+	for i := 0; i < set.Len(); i++ {
+		key, ok := set.Get(i)
+		if !ok {
+			continue
+		}
+		if isAllowedAlgorithm(jwa.SignatureAlgorithm(key.Algorithm()), algorithms) {
+			allowed.Add(key)
+		}
+	}
+	return allowed
+}
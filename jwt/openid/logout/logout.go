@@ -0,0 +1,221 @@
+// Package logout implements helpers to build and validate Logout Tokens
+// used in OpenID Connect Back-Channel Logout, as described in
+// https://openid.net/specs/openid-connect-backchannel-1_0.html
+//
+// OpenID Providers use `logout.Sign()` to create Logout Tokens, and
+// Relying Parties use `logout.Parse()` to verify and validate the
+// tokens they receive at their back-channel logout endpoint.
+package logout
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/profile"
+	"github.com/pkg/errors"
+)
+
+// MediaType is the value used in the "typ" header of Logout Tokens
+const MediaType = `logout+jwt`
+
+// Event is the member name in the "events" claim that identifies
+// a Logout Token
+const Event = `http://schemas.openid.net/event/backchannel-logout`
+
+const (
+	EventsKey    = "events"
+	NonceKey     = "nonce"
+	SessionIDKey = "sid"
+)
+
+// DefaultLifetime is the lifetime of Logout Tokens created by
+// `logout.Sign()`, unless specified otherwise
+const DefaultLifetime = 2 * time.Minute
+
+// Sign serializes the token as a signed Logout Token. The "typ" header
+// is set to `logout+jwt`.
+//
+// The token must contain the "iss" and "aud" claims, and at least one of
+// the "sub" and "sid" claims. The "iat", "exp", "jti" and "events" claims
+// are filled in if they do not exist. The token passed in is not modified.
+func Sign(t jwt.Token, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	if alg == jwa.NoSignature {
+		return nil, errors.New(`logout tokens must be signed: "none" algorithm is not allowed`)
+	}
+
+	var hdrs jws.Headers
+	var clock jwt.Clock = jwt.ClockFunc(time.Now)
+	lifetime := DefaultLifetime
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identHeaders{}:
+			hdrs = option.Value().(jws.Headers)
+		case identClock{}:
+			clock = option.Value().(jwt.Clock)
+		case identLifetime{}:
+			lifetime = option.Value().(time.Duration)
+		}
+	}
+
+	if lifetime <= 0 {
+		return nil, errors.New(`logout token lifetime must be positive`)
+	}
+
+	t, err := t.Clone()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to clone token`)
+	}
+
+	now := clock.Now()
+	if _, ok := t.Get(jwt.IssuedAtKey); !ok {
+		if err := t.Set(jwt.IssuedAtKey, now); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, jwt.IssuedAtKey)
+		}
+	}
+	if _, ok := t.Get(jwt.ExpirationKey); !ok {
+		if err := t.Set(jwt.ExpirationKey, now.Add(lifetime)); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, jwt.ExpirationKey)
+		}
+	}
+	if _, ok := t.Get(jwt.JwtIDKey); !ok {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, errors.Wrap(err, `failed to generate jti`)
+		}
+		if err := t.Set(jwt.JwtIDKey, base64.EncodeToString(buf)); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, jwt.JwtIDKey)
+		}
+	}
+	if _, ok := t.Get(EventsKey); !ok {
+		if err := t.Set(EventsKey, map[string]interface{}{Event: map[string]interface{}{}}); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, EventsKey)
+		}
+	}
+
+	if err := checkClaims(t); err != nil {
+		return nil, err
+	}
+
+	if hdrs == nil {
+		hdrs = jws.NewHeaders()
+	}
+	if err := hdrs.Set(jws.TypeKey, MediaType); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s header`, jws.TypeKey)
+	}
+	return jwt.Sign(t, alg, key, jwt.WithHeaders(hdrs))
+}
+
+// Parse decrypts (if necessary) and verifies the Logout Token, and
+// validates its contents.
+//
+// On top of the validation performed by `jwt.Validate()`, the following
+// are checked:
+//
+//   * the "typ" header is `logout+jwt`. Use `logout.WithTypeOptional()`
+//     to also accept tokens without a "typ" header
+//   * the signature algorithm is not "none", and is one of the values
+//     specified via `logout.WithAllowedAlgorithms()`
+//   * the "iss", "aud", "iat", "exp" and "jti" claims exist
+//   * the "events" claim is an object that contains a member named
+//     `http://schemas.openid.net/event/backchannel-logout` whose value
+//     is an object
+//   * at least one of the "sub" and "sid" claims exists
+//   * the "nonce" claim does not exist
+//
+// One of `logout.WithVerify()` or `logout.WithKeySet()` must be specified.
+// Relying Parties should also specify `logout.WithIssuer()` and
+// `logout.WithAudience()`, and are responsible for rejecting tokens whose
+// "jti" has been seen before (see `jwt.WithReplayProtection()`).
+func Parse(data []byte, options ...ParseOption) (jwt.Token, error) {
+	cfg := profile.Config{Type: MediaType}
+	parseOptions := []jwt.ParseOption{jwt.WithValidate(true)}
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identVerify{}:
+			p := option.Value().(*verifyParams)
+			parseOptions = append(parseOptions, jwt.WithVerify(p.alg, p.key))
+		case identKeySet{}:
+			parseOptions = append(parseOptions, jwt.WithKeySet(option.Value().(jwk.Set)))
+		case identDecrypt{}:
+			p := option.Value().(*decryptParams)
+			cfg.DecryptAlgorithm = p.alg
+			cfg.DecryptKey = p.key
+		case identAllowedAlgorithms{}:
+			cfg.AllowedAlgorithms = option.Value().([]jwa.SignatureAlgorithm)
+		case identIssuer{}:
+			parseOptions = append(parseOptions, jwt.WithIssuer(option.Value().(string)))
+		case identAudience{}:
+			parseOptions = append(parseOptions, jwt.WithAudience(option.Value().(string)))
+		case identTypeOptional{}:
+			if option.Value().(bool) {
+				cfg.Type = ""
+			} else {
+				cfg.Type = MediaType
+			}
+		case identParseOptions{}:
+			parseOptions = append(parseOptions, option.Value().([]jwt.ParseOption)...)
+		}
+	}
+	cfg.ParseOptions = parseOptions
+
+	tok, hdrs, err := profile.Parse(data, &cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse logout token`)
+	}
+
+	// When the "typ" header is optional, it must still be correct if present
+	if typ := hdrs.Type(); cfg.Type == "" && typ != "" && !profile.TypeMatches(typ, MediaType) {
+		return nil, errors.Errorf(`invalid "typ" header: expected %q, got %q`, MediaType, typ)
+	}
+
+	if err := checkClaims(tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+func checkClaims(t jwt.Token) error {
+	for _, name := range []string{jwt.IssuerKey, jwt.AudienceKey, jwt.IssuedAtKey, jwt.ExpirationKey, jwt.JwtIDKey} {
+		if _, ok := t.Get(name); !ok {
+			return errors.Errorf(`required claim %q was not found`, name)
+		}
+	}
+
+	v, ok := t.Get(EventsKey)
+	if !ok {
+		return errors.Errorf(`required claim %q was not found`, EventsKey)
+	}
+	events, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.Errorf(`invalid value for %q: expected object, got %T`, EventsKey, v)
+	}
+	event, ok := events[Event]
+	if !ok {
+		return errors.Errorf(`%q claim must contain %q`, EventsKey, Event)
+	}
+	if _, ok := event.(map[string]interface{}); !ok {
+		return errors.Errorf(`invalid value for %q in %q: expected object, got %T`, Event, EventsKey, event)
+	}
+
+	sid, hasSID := t.Get(SessionIDKey)
+	if hasSID {
+		if _, ok := sid.(string); !ok {
+			return errors.Errorf(`invalid value for %q: expected string, got %T`, SessionIDKey, sid)
+		}
+	}
+	if t.Subject() == "" && !hasSID {
+		return errors.Errorf(`one of %q or %q must be present`, jwt.SubjectKey, SessionIDKey)
+	}
+
+	if _, ok := t.Get(NonceKey); ok {
+		return errors.Errorf(`logout token must not contain %q`, NonceKey)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package logout_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/openid/logout"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	opIssuer = `https://server.example.com`
+	clientID = `s6BhdRkqt3`
+)
+
+func newLogoutToken() jwt.Token {
+	t := jwt.New()
+	t.Set(jwt.IssuerKey, opIssuer)
+	t.Set(jwt.AudienceKey, clientID)
+	t.Set(jwt.SubjectKey, `248289761001`)
+	t.Set(logout.SessionIDKey, `08a5019c-17e1-4977-8f42-65a12843ea02`)
+	return t
+}
+
+func TestLogout(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	t.Run("Sign and Parse", func(t *testing.T) {
+		t.Parallel()
+		tok := newLogoutToken()
+		signed, err := logout.Sign(tok, jwa.RS256, key)
+		if !assert.NoError(t, err, `logout.Sign should succeed`) {
+			return
+		}
+		if _, ok := tok.Get(logout.EventsKey); !assert.False(t, ok, `original token should not be modified`) {
+			return
+		}
+
+		msg, err := jws.Parse(signed)
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, logout.MediaType, msg.Signatures()[0].ProtectedHeaders().Type(), `"typ" should be logout+jwt`) {
+			return
+		}
+
+		parsed, err := logout.Parse(signed,
+			logout.WithVerify(jwa.RS256, &key.PublicKey),
+			logout.WithIssuer(opIssuer),
+			logout.WithAudience(clientID),
+			logout.WithAllowedAlgorithms(jwa.RS256),
+		)
+		if !assert.NoError(t, err, `logout.Parse should succeed`) {
+			return
+		}
+		if !assert.NotEmpty(t, parsed.JwtID(), `"jti" should be filled in`) {
+			return
+		}
+		if !assert.Equal(t, logout.DefaultLifetime, parsed.Expiration().Sub(parsed.IssuedAt()), `"exp" should be filled in`) {
+			return
+		}
+
+		_, err = logout.Parse(signed, logout.WithVerify(jwa.RS256, &key.PublicKey), logout.WithAudience(`other-client`))
+		if !assert.Error(t, err, `logout.Parse should fail for wrong audience`) {
+			return
+		}
+		_, err = logout.Parse(signed, logout.WithVerify(jwa.RS256, &key.PublicKey), logout.WithAllowedAlgorithms(jwa.ES256))
+		if !assert.Error(t, err, `logout.Parse should fail for disallowed algorithm`) {
+			return
+		}
+	})
+	t.Run("Sign rejects invalid tokens", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name  string
+			Setup func(jwt.Token)
+		}{
+			{Name: "no sub or sid", Setup: func(t jwt.Token) {
+				t.Remove(jwt.SubjectKey)
+				t.Remove(logout.SessionIDKey)
+			}},
+			{Name: "nonce", Setup: func(t jwt.Token) { t.Set(logout.NonceKey, `n-0S6_WzA2Mj`) }},
+			{Name: "no aud", Setup: func(t jwt.Token) { t.Remove(jwt.AudienceKey) }},
+			{Name: "wrong event", Setup: func(t jwt.Token) {
+				t.Set(logout.EventsKey, map[string]interface{}{`https://example.com/event`: map[string]interface{}{}})
+			}},
+			{Name: "event is not an object", Setup: func(t jwt.Token) {
+				t.Set(logout.EventsKey, map[string]interface{}{logout.Event: true})
+			}},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				tok := newLogoutToken()
+				tc.Setup(tok)
+				_, err := logout.Sign(tok, jwa.RS256, key)
+				if !assert.Error(t, err, `logout.Sign should fail`) {
+					return
+				}
+			})
+		}
+		_, err := logout.Sign(newLogoutToken(), jwa.NoSignature, nil)
+		if !assert.Error(t, err, `logout.Sign should fail for "none"`) {
+			return
+		}
+	})
+	t.Run("Parse rejects invalid tokens", func(t *testing.T) {
+		t.Parallel()
+		// Build tokens with jwt.Sign, bypassing the checks in logout.Sign
+		sign := func(t *testing.T, tok jwt.Token, typ string) []byte {
+			t.Helper()
+			hdrs := jws.NewHeaders()
+			if typ != "" {
+				hdrs.Set(jws.TypeKey, typ)
+			}
+			now := time.Now()
+			tok.Set(jwt.IssuedAtKey, now)
+			tok.Set(jwt.ExpirationKey, now.Add(time.Minute))
+			tok.Set(jwt.JwtIDKey, `bWJq`)
+			if _, ok := tok.Get(logout.EventsKey); !ok {
+				tok.Set(logout.EventsKey, map[string]interface{}{logout.Event: map[string]interface{}{}})
+			}
+			signed, err := jwt.Sign(tok, jwa.RS256, key, jwt.WithHeaders(hdrs))
+			if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+				t.FailNow()
+			}
+			return signed
+		}
+
+		withNonce := newLogoutToken()
+		withNonce.Set(logout.NonceKey, `n-0S6_WzA2Mj`)
+		noEvents := newLogoutToken()
+		noEvents.Set(logout.EventsKey, `logout`)
+		noSubject := newLogoutToken()
+		noSubject.Remove(jwt.SubjectKey)
+		noSubject.Remove(logout.SessionIDKey)
+
+		testcases := []struct {
+			Name    string
+			Token   []byte
+			Options []logout.ParseOption
+			Error   bool
+		}{
+			{Name: "valid", Token: sign(t, newLogoutToken(), logout.MediaType)},
+			{Name: "typ with prefix", Token: sign(t, newLogoutToken(), `application/logout+jwt`)},
+			{Name: "nonce", Token: sign(t, withNonce, logout.MediaType), Error: true},
+			{Name: "invalid events", Token: sign(t, noEvents, logout.MediaType), Error: true},
+			{Name: "no sub or sid", Token: sign(t, noSubject, logout.MediaType), Error: true},
+			{Name: "typ is JWT", Token: sign(t, newLogoutToken(), `JWT`), Error: true},
+			{Name: "typ is JWT, typ optional", Token: sign(t, newLogoutToken(), `JWT`), Options: []logout.ParseOption{logout.WithTypeOptional(true)}, Error: true},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				options := append([]logout.ParseOption{logout.WithVerify(jwa.RS256, &key.PublicKey)}, tc.Options...)
+				_, err := logout.Parse(tc.Token, options...)
+				if tc.Error {
+					if !assert.Error(t, err, `logout.Parse should fail`) {
+						return
+					}
+					return
+				}
+				if !assert.NoError(t, err, `logout.Parse should succeed`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Replay protection", func(t *testing.T) {
+		t.Parallel()
+		signed, err := logout.Sign(newLogoutToken(), jwa.RS256, key)
+		if !assert.NoError(t, err, `logout.Sign should succeed`) {
+			return
+		}
+		options := []logout.ParseOption{
+			logout.WithVerify(jwa.RS256, &key.PublicKey),
+			logout.WithParseOptions(jwt.WithReplayProtection(jwt.NewMemoryJTIStore(time.Minute))),
+		}
+		if _, err := logout.Parse(signed, options...); !assert.NoError(t, err, `logout.Parse should succeed`) {
+			return
+		}
+		if _, err := logout.Parse(signed, options...); !assert.Error(t, err, `logout.Parse should fail for replayed token`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package logout
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// SignOption describes an Option that can be passed to `logout.Sign()`
+type SignOption interface {
+	Option
+	signOption()
+}
+
+type signOption struct {
+	Option
+}
+
+func (*signOption) signOption() {}
+
+// ParseOption describes an Option that can be passed to `logout.Parse()`
+type ParseOption interface {
+	Option
+	parseOption()
+}
+
+type parseOption struct {
+	Option
+}
+
+func (*parseOption) parseOption() {}
+
+type identAllowedAlgorithms struct{}
+type identAudience struct{}
+type identClock struct{}
+type identDecrypt struct{}
+type identHeaders struct{}
+type identIssuer struct{}
+type identKeySet struct{}
+type identLifetime struct{}
+type identParseOptions struct{}
+type identTypeOptional struct{}
+type identVerify struct{}
+
+type verifyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+type decryptParams struct {
+	alg jwa.KeyEncryptionAlgorithm
+	key interface{}
+}
+
+// WithHeaders specifies extra header values to be included in the
+// protected header of the JWS message. The "typ" header is always
+// overwritten with `logout+jwt`
+func WithHeaders(hdrs jws.Headers) SignOption {
+	return &signOption{option.New(identHeaders{}, hdrs)}
+}
+
+// WithClock specifies the clock used to fill in the "iat" and "exp"
+// claims, if they do not exist
+func WithClock(c jwt.Clock) SignOption {
+	return &signOption{option.New(identClock{}, c)}
+}
+
+// WithLifetime specifies the duration between the "iat" and "exp"
+// claims, when the "exp" claim is filled in. The default is
+// `logout.DefaultLifetime`
+func WithLifetime(d time.Duration) SignOption {
+	return &signOption{option.New(identLifetime{}, d)}
+}
+
+// WithVerify specifies the algorithm and the key used to verify
+// the Logout Token
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identVerify{}, &verifyParams{alg: alg, key: key})}
+}
+
+// WithKeySet specifies the key set from which the key to verify the
+// Logout Token is chosen. See `jwt.WithKeySet()` for details
+func WithKeySet(set jwk.Set) ParseOption {
+	return &parseOption{option.New(identKeySet{}, set)}
+}
+
+// WithDecrypt specifies the algorithm and the key used to decrypt
+// the Logout Token. If the Logout Token is encrypted and this
+// option is not specified, `logout.Parse()` returns an error
+func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identDecrypt{}, &decryptParams{alg: alg, key: key})}
+}
+
+// WithAllowedAlgorithms specifies the signature algorithms that are
+// accepted. By default any algorithm except for "none" is accepted.
+// This should be the algorithm that was registered with the OpenID
+// Provider for ID Tokens
+func WithAllowedAlgorithms(algs ...jwa.SignatureAlgorithm) ParseOption {
+	return &parseOption{option.New(identAllowedAlgorithms{}, algs)}
+}
+
+// WithIssuer specifies the expected value in the "iss" claim, which
+// should be the issuer identifier of the OpenID Provider
+func WithIssuer(s string) ParseOption {
+	return &parseOption{option.New(identIssuer{}, s)}
+}
+
+// WithAudience specifies the expected value in the "aud" claim, which
+// should be the client ID of the Relying Party
+func WithAudience(s string) ParseOption {
+	return &parseOption{option.New(identAudience{}, s)}
+}
+
+// WithTypeOptional specifies whether Logout Tokens without a "typ"
+// header are accepted. Explicit typing is only recommended by the
+// specification, so some OpenID Providers may omit it. A "typ" header
+// with any other value than `logout+jwt` is always rejected
+func WithTypeOptional(b bool) ParseOption {
+	return &parseOption{option.New(identTypeOptional{}, b)}
+}
+
+// WithParseOptions specifies extra options that are passed to
+// `jwt.Parse()`, for example `jwt.WithClock()`, `jwt.WithAcceptableSkew()`
+// or `jwt.WithReplayProtection()`
+func WithParseOptions(options ...jwt.ParseOption) ParseOption {
+	return &parseOption{option.New(identParseOptions{}, options)}
+}
@@ -4,7 +4,7 @@
 // In order to use OpenID claims, you specify the token to use in the
 // jwt.Parse method
 //
-//    jwt.Parse(data, jwt.WithToken(openid.New())
+//    jwt.Parse(data, jwt.WithVerify(alg, key), jwt.WithToken(openid.New()))
 package openid
 
 import (
@@ -12,10 +12,13 @@ import (
 
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/types"
 	"github.com/pkg/errors"
 )
 
-var registry = json.NewRegistry()
+// Fields that are not registered via openid.RegisterCustomField are
+// looked up in the registry used by jwt.RegisterCustomField
+var registry = json.NewRegistryWithFallback(types.Registry)
 
 func (t *stdToken) Clone() (jwt.Token, error) {
 	var dst jwt.Token = New()
@@ -34,6 +37,9 @@ func (t *stdToken) Clone() (jwt.Token, error) {
 // be decoded as an instance of the specified type. This option has
 // a global effect.
 //
+// Fields registered using `jwt.RegisterCustomField()` are also respected
+// by openid.Token, unless they are overridden using this function.
+//
 // For example, suppose you have a custom field `x-birthday`, which
 // you want to represent as a string formatted in RFC3339 in JSON,
 // but want it back as `time.Time`.
@@ -51,3 +57,9 @@ func (t *stdToken) Clone() (jwt.Token, error) {
 func RegisterCustomField(name string, object interface{}) {
 	registry.Register(name, object)
 }
+
+func (t *stdToken) Scopes() []string {
+	scope, _ := t.Get(jwt.ScopeKey)
+	scp, _ := t.Get(jwt.ScpKey)
+	return types.Scopes(scope, scp)
+}
@@ -0,0 +1,52 @@
+package openid
+
+import (
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/types"
+)
+
+func init() {
+	claims := map[string]map[string]interface{}{
+		AddressKey: {
+			`type`: `object`,
+			`properties`: map[string]interface{}{
+				AddressCountryKey:       map[string]interface{}{`type`: `string`},
+				AddressFormattedKey:     map[string]interface{}{`type`: `string`},
+				AddressLocalityKey:      map[string]interface{}{`type`: `string`},
+				AddressPostalCodeKey:    map[string]interface{}{`type`: `string`},
+				AddressRegionKey:        map[string]interface{}{`type`: `string`},
+				AddressStreetAddressKey: map[string]interface{}{`type`: `string`},
+			},
+		},
+		BirthdateKey:           {`type`: `string`},
+		EmailKey:               {`type`: `string`, `format`: `email`},
+		EmailVerifiedKey:       {`type`: `boolean`},
+		FamilyNameKey:          {`type`: `string`},
+		GenderKey:              {`type`: `string`},
+		GivenNameKey:           {`type`: `string`},
+		LocaleKey:              {`type`: `string`},
+		MiddleNameKey:          {`type`: `string`},
+		NameKey:                {`type`: `string`},
+		NicknameKey:            {`type`: `string`},
+		PhoneNumberKey:         {`type`: `string`},
+		PhoneNumberVerifiedKey: {`type`: `boolean`},
+		PictureKey:             {`type`: `string`, `format`: `uri`},
+		PreferredUsernameKey:   {`type`: `string`},
+		ProfileKey:             {`type`: `string`, `format`: `uri`},
+		UpdatedAtKey:           types.NumericDateSchema(),
+		WebsiteKey:             {`type`: `string`, `format`: `uri`},
+		ZoneinfoKey:            {`type`: `string`},
+	}
+
+	// The claims defined by RFC7519 are shared with jwt.Token
+	if s, ok := types.LookupSchema(jwt.New()); ok {
+		for name, schema := range s.Claims {
+			claims[name] = schema
+		}
+	}
+
+	types.RegisterSchema(&stdToken{}, &types.Schema{
+		Claims:   claims,
+		Registry: registry,
+	})
+}
@@ -79,6 +79,7 @@ type Token interface {
 	Get(string) (interface{}, bool)
 	Set(string, interface{}) error
 	Remove(string) error
+	Scopes() []string
 	Clone() (jwt.Token, error)
 	Iterate(context.Context) Iterator
 	Walk(context.Context, Visitor) error
@@ -892,6 +893,9 @@ func (t *stdToken) makePairs() []*ClaimPair {
 	for k, v := range t.privateClaims {
 		pairs = append(pairs, &ClaimPair{Key: k, Value: v})
 	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.(string) < pairs[j].Key.(string)
+	})
 	return pairs
 }
 
@@ -924,7 +928,7 @@ func (t *stdToken) UnmarshalJSON(buf []byte) error {
 	t.phoneNumberVerified = nil
 	t.address = nil
 	t.updatedAt = nil
-	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec := json.NewDecoderCtx(t.dc, bytes.NewReader(buf))
 LOOP:
 	for {
 		tok, err := dec.Token()
@@ -1120,7 +1124,7 @@ func (t stdToken) MarshalJSON() ([]byte, error) {
 			}
 			continue
 		case ExpirationKey, IssuedAtKey, NotBeforeKey, UpdatedAtKey:
-			enc.Encode(data[f].(time.Time).Unix())
+			buf.WriteString(types.FormatNumericDate(data[f].(time.Time)))
 			continue
 		}
 		v := data[f]
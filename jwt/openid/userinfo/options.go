@@ -0,0 +1,116 @@
+package userinfo
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// ParseOption describes an Option that can be passed to `userinfo.Parse()`
+// and `userinfo.ParseResponse()`
+type ParseOption interface {
+	Option
+	parseOption()
+}
+
+type parseOption struct {
+	Option
+}
+
+func (*parseOption) parseOption() {}
+
+type identAllowedAlgorithms struct{}
+type identAudience struct{}
+type identDecrypt struct{}
+type identIssuer struct{}
+type identKeySet struct{}
+type identParseOptions struct{}
+type identRequireEncryption struct{}
+type identRequireSignature struct{}
+type identSubject struct{}
+type identVerify struct{}
+
+type verifyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+type decryptParams struct {
+	alg jwa.KeyEncryptionAlgorithm
+	key interface{}
+}
+
+// WithVerify specifies the algorithm and the key used to verify
+// signed UserInfo Responses
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identVerify{}, &verifyParams{alg: alg, key: key})}
+}
+
+// WithKeySet specifies the key set from which the key to verify signed
+// UserInfo Responses is chosen. This is usually the key set published
+// by the OpenID Provider at its "jwks_uri". See `jwt.WithKeySet()` for
+// details
+func WithKeySet(set jwk.Set) ParseOption {
+	return &parseOption{option.New(identKeySet{}, set)}
+}
+
+// WithDecrypt specifies the algorithm and the key used to decrypt
+// encrypted UserInfo Responses. If the response is encrypted and this
+// option is not specified, an error is returned
+func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identDecrypt{}, &decryptParams{alg: alg, key: key})}
+}
+
+// WithAllowedAlgorithms specifies the signature algorithms that are
+// accepted. By default any algorithm except for "none" is accepted.
+// This should be the "userinfo_signed_response_alg" value that was
+// registered with the OpenID Provider
+func WithAllowedAlgorithms(algs ...jwa.SignatureAlgorithm) ParseOption {
+	return &parseOption{option.New(identAllowedAlgorithms{}, algs)}
+}
+
+// WithIssuer specifies the expected value in the "iss" claim, which
+// should be the issuer identifier of the OpenID Provider. The claim is
+// only checked if it is present
+func WithIssuer(s string) ParseOption {
+	return &parseOption{option.New(identIssuer{}, s)}
+}
+
+// WithAudience specifies the expected value in the "aud" claim, which
+// should be the client ID of the Relying Party. The claim is only
+// checked if it is present
+func WithAudience(s string) ParseOption {
+	return &parseOption{option.New(identAudience{}, s)}
+}
+
+// WithSubject specifies the expected value in the "sub" claim, which
+// must be the "sub" claim of the ID Token. Relying Parties must always
+// check this, as required by OpenID Connect Core 1.0 section 5.3.4
+func WithSubject(s string) ParseOption {
+	return &parseOption{option.New(identSubject{}, s)}
+}
+
+// WithRequireSignature specifies whether unsigned responses (that is,
+// plain JSON responses and responses that are only encrypted) are
+// rejected. This should be set to true if the Relying Party registered
+// a "userinfo_signed_response_alg"
+func WithRequireSignature(b bool) ParseOption {
+	return &parseOption{option.New(identRequireSignature{}, b)}
+}
+
+// WithRequireEncryption specifies whether unencrypted responses are
+// rejected. This should be set to true if the Relying Party registered
+// a "userinfo_encrypted_response_alg"
+func WithRequireEncryption(b bool) ParseOption {
+	return &parseOption{option.New(identRequireEncryption{}, b)}
+}
+
+// WithParseOptions specifies extra options that are passed to
+// `jwt.Parse()` when the response is signed, for example `jwt.WithClock()`
+// or `jwt.WithAcceptableSkew()`
+func WithParseOptions(options ...jwt.ParseOption) ParseOption {
+	return &parseOption{option.New(identParseOptions{}, options)}
+}
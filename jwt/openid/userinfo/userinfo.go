@@ -0,0 +1,236 @@
+// Package userinfo implements helpers to handle responses from the
+// UserInfo Endpoint of an OpenID Provider, as described in
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
+//
+// UserInfo Responses are either plain JSON objects, or JWTs that are
+// signed, encrypted, or signed and then encrypted (section 5.3.2).
+// Relying Parties use `userinfo.Parse()` or `userinfo.ParseResponse()`
+// to verify and decrypt the response as needed, and to map the claims
+// into an openid.Token.
+package userinfo
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/limits"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/profile"
+	"github.com/lestrrat-go/jwx/jwt/openid"
+	"github.com/pkg/errors"
+)
+
+// Media types of UserInfo Responses
+const (
+	MediaTypeJSON = `application/json`
+	MediaTypeJWT  = `application/jwt`
+)
+
+type parseCtx struct {
+	profile            profile.Config
+	decryptAlg         jwa.KeyEncryptionAlgorithm
+	decryptKey         interface{}
+	issuer             string
+	audience           string
+	subject            string
+	requireSignature   bool
+	requireEncryption  bool
+	hasVerificationKey bool
+}
+
+// ParseResponse reads the body of a response from the UserInfo Endpoint
+// and passes it to `userinfo.Parse()`, along with the value of its
+// "Content-Type" header. An error is returned if the status code of
+// the response is not 200. The body is not closed.
+func ParseResponse(res *http.Response, options ...ParseOption) (openid.Token, error) {
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(`userinfo endpoint returned status %d`, res.StatusCode)
+	}
+
+	var src io.Reader = res.Body
+	if max := limits.MaxInputSize(); max > 0 {
+		src = io.LimitReader(src, max+1)
+	}
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to read userinfo response`)
+	}
+	return Parse(res.Header.Get(`Content-Type`), data, options...)
+}
+
+// Parse parses a UserInfo Response whose media type is `contentType`,
+// and returns the claims as an openid.Token.
+//
+// Responses of type `application/json` are decoded as is. Responses of
+// type `application/jwt` are decrypted (if they are encrypted) using the
+// key specified via `userinfo.WithDecrypt()`, and verified (if they are
+// signed) using `userinfo.WithVerify()` or `userinfo.WithKeySet()`.
+// As allowed by the specification, the response may be encrypted without
+// being signed, in which case the decrypted payload is decoded as a JSON
+// object. Use `userinfo.WithRequireSignature()` and
+// `userinfo.WithRequireEncryption()` to reject responses that are not
+// secured in the way that was registered with the OpenID Provider.
+//
+// On top of the above, the following are checked:
+//
+//   * the "sub" claim exists, and matches the value specified via
+//     `userinfo.WithSubject()`
+//   * the "iss" and "aud" claims, if they exist, match the values
+//     specified via `userinfo.WithIssuer()` and `userinfo.WithAudience()`
+//   * for signed responses, the signature algorithm is not "none" and
+//     is one of the values specified via `userinfo.WithAllowedAlgorithms()`,
+//     and the time related claims are valid (see `jwt.Validate()`)
+func Parse(contentType string, data []byte, options ...ParseOption) (openid.Token, error) {
+	ctx := parseCtx{
+		profile: profile.Config{
+			ParseOptions: []jwt.ParseOption{jwt.WithValidate(true)},
+		},
+	}
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identVerify{}:
+			p := option.Value().(*verifyParams)
+			ctx.profile.ParseOptions = append(ctx.profile.ParseOptions, jwt.WithVerify(p.alg, p.key))
+			ctx.hasVerificationKey = true
+		case identKeySet{}:
+			ctx.profile.ParseOptions = append(ctx.profile.ParseOptions, jwt.WithKeySet(option.Value().(jwk.Set)))
+			ctx.hasVerificationKey = true
+		case identDecrypt{}:
+			p := option.Value().(*decryptParams)
+			ctx.decryptAlg = p.alg
+			ctx.decryptKey = p.key
+		case identAllowedAlgorithms{}:
+			ctx.profile.AllowedAlgorithms = option.Value().([]jwa.SignatureAlgorithm)
+		case identIssuer{}:
+			ctx.issuer = option.Value().(string)
+		case identAudience{}:
+			ctx.audience = option.Value().(string)
+		case identSubject{}:
+			ctx.subject = option.Value().(string)
+		case identRequireSignature{}:
+			ctx.requireSignature = option.Value().(bool)
+		case identRequireEncryption{}:
+			ctx.requireEncryption = option.Value().(bool)
+		case identParseOptions{}:
+			ctx.profile.ParseOptions = append(ctx.profile.ParseOptions, option.Value().([]jwt.ParseOption)...)
+		}
+	}
+
+	if err := limits.CheckInputSize(len(data)); err != nil {
+		return nil, errors.Wrap(err, `failed to parse userinfo response`)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to parse content type %q`, contentType)
+	}
+
+	var tok openid.Token
+	switch mediaType {
+	case MediaTypeJSON:
+		if ctx.requireSignature || ctx.requireEncryption {
+			return nil, errors.New(`userinfo response must be a JWT, but a plain JSON response was received`)
+		}
+		tok, err = decodeClaims(data)
+	case MediaTypeJWT:
+		tok, err = parseJWT(&ctx, data)
+	default:
+		return nil, errors.Errorf(`unsupported content type for userinfo response: %q`, mediaType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkClaims(&ctx, tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+func parseJWT(ctx *parseCtx, data []byte) (openid.Token, error) {
+	payload := data
+	encrypted := jwx.GuessFormat(payload) == jwx.JWE
+	if encrypted {
+		if ctx.decryptKey == nil {
+			return nil, errors.New(`userinfo response is encrypted, but no decryption key was specified`)
+		}
+		decrypted, err := jwe.Decrypt(payload, ctx.decryptAlg, ctx.decryptKey)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to decrypt userinfo response`)
+		}
+		payload = decrypted
+	} else if ctx.requireEncryption {
+		return nil, errors.New(`userinfo response must be encrypted`)
+	}
+
+	if jwx.GuessFormat(payload) != jwx.JWS {
+		// Encrypted but not signed: the plaintext is the set of claims
+		if !encrypted {
+			return nil, errors.New(`userinfo response is neither signed nor encrypted`)
+		}
+		if ctx.requireSignature {
+			return nil, errors.New(`userinfo response must be signed`)
+		}
+		return decodeClaims(payload)
+	}
+
+	if !ctx.hasVerificationKey {
+		return nil, errors.New(`userinfo response is signed, but no verification key was specified`)
+	}
+
+	cfg := ctx.profile
+	cfg.ParseOptions = append(append([]jwt.ParseOption(nil), cfg.ParseOptions...), jwt.WithToken(openid.New()))
+	parsed, _, err := profile.Parse(payload, &cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse signed userinfo response`)
+	}
+	tok, ok := parsed.(openid.Token)
+	if !ok {
+		return nil, errors.Errorf(`expected openid.Token, got %T`, parsed)
+	}
+	return tok, nil
+}
+
+func decodeClaims(data []byte) (openid.Token, error) {
+	tok := openid.New()
+	if err := json.Unmarshal(data, tok); err != nil {
+		return nil, errors.Wrap(err, `failed to decode userinfo claims`)
+	}
+	return tok, nil
+}
+
+func checkClaims(ctx *parseCtx, t openid.Token) error {
+	sub := t.Subject()
+	if sub == "" {
+		return errors.Errorf(`required claim %q was not found`, openid.SubjectKey)
+	}
+	if ctx.subject != "" && sub != ctx.subject {
+		return errors.Errorf(`%q claim does not match the ID Token: expected %q, got %q`, openid.SubjectKey, ctx.subject, sub)
+	}
+
+	if iss := t.Issuer(); ctx.issuer != "" && iss != "" && iss != ctx.issuer {
+		return errors.Errorf(`invalid %q claim: expected %q, got %q`, openid.IssuerKey, ctx.issuer, iss)
+	}
+
+	if aud := t.Audience(); ctx.audience != "" && len(aud) > 0 {
+		var found bool
+		for _, v := range aud {
+			if v == ctx.audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf(`invalid %q claim: %q was not found`, openid.AudienceKey, ctx.audience)
+		}
+	}
+	return nil
+}
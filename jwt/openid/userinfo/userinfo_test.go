@@ -0,0 +1,202 @@
+package userinfo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/openid"
+	"github.com/lestrrat-go/jwx/jwt/openid/userinfo"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	opIssuer = `https://server.example.com`
+	clientID = `s6BhdRkqt3`
+	subject  = `248289761001`
+)
+
+func newUserInfo() openid.Token {
+	t := openid.New()
+	t.Set(openid.SubjectKey, subject)
+	t.Set(openid.NameKey, `Jane Doe`)
+	t.Set(openid.EmailKey, `janedoe@example.com`)
+	t.Set(openid.EmailVerifiedKey, true)
+	return t
+}
+
+func TestUserInfo(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	signingKey.Set(jwk.KeyIDKey, `op-key`)
+	signingKey.Set(jwk.AlgorithmKey, jwa.RS256)
+	pubkey, err := jwk.PublicKeyOf(signingKey)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	set := jwk.NewSet()
+	set.Add(pubkey)
+
+	encryptionKey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	plain, err := json.Marshal(newUserInfo())
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+
+	signedInfo := newUserInfo()
+	signedInfo.Set(openid.IssuerKey, opIssuer)
+	signedInfo.Set(openid.AudienceKey, clientID)
+	signed, err := jwt.Sign(signedInfo, jwa.RS256, signingKey)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	encrypted, err := jwe.Encrypt(plain, jwa.RSA_OAEP, &encryptionKey.PublicKey, jwa.A256GCM, jwa.NoCompress)
+	if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+		return
+	}
+
+	hdrs := jwe.NewHeaders()
+	hdrs.Set(jwe.ContentTypeKey, `JWT`)
+	nested, err := jwe.Encrypt(signed, jwa.RSA_OAEP, &encryptionKey.PublicKey, jwa.A256GCM, jwa.NoCompress, jwe.WithProtectedHeaders(hdrs))
+	if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+		return
+	}
+
+	checkToken := func(t *testing.T, tok openid.Token) bool {
+		t.Helper()
+		return assert.Equal(t, subject, tok.Subject(), `"sub" should match`) &&
+			assert.Equal(t, `janedoe@example.com`, tok.Email(), `"email" should match`) &&
+			assert.True(t, tok.EmailVerified(), `"email_verified" should match`)
+	}
+
+	t.Run("Plain JSON", func(t *testing.T) {
+		t.Parallel()
+		tok, err := userinfo.Parse(`application/json; charset=utf-8`, plain, userinfo.WithSubject(subject))
+		if !assert.NoError(t, err, `userinfo.Parse should succeed`) {
+			return
+		}
+		if !checkToken(t, tok) {
+			return
+		}
+
+		_, err = userinfo.Parse(userinfo.MediaTypeJSON, plain, userinfo.WithRequireSignature(true))
+		if !assert.Error(t, err, `userinfo.Parse should fail when a signature is required`) {
+			return
+		}
+	})
+	t.Run("Signed", func(t *testing.T) {
+		t.Parallel()
+		tok, err := userinfo.Parse(userinfo.MediaTypeJWT, signed,
+			userinfo.WithKeySet(set),
+			userinfo.WithIssuer(opIssuer),
+			userinfo.WithAudience(clientID),
+			userinfo.WithSubject(subject),
+			userinfo.WithAllowedAlgorithms(jwa.RS256),
+			userinfo.WithRequireSignature(true),
+		)
+		if !assert.NoError(t, err, `userinfo.Parse should succeed`) {
+			return
+		}
+		if !checkToken(t, tok) {
+			return
+		}
+
+		_, err = userinfo.Parse(userinfo.MediaTypeJWT, signed)
+		if !assert.Error(t, err, `userinfo.Parse should fail without a verification key`) {
+			return
+		}
+		_, err = userinfo.Parse(userinfo.MediaTypeJWT, signed, userinfo.WithKeySet(set), userinfo.WithAudience(`other-client`))
+		if !assert.Error(t, err, `userinfo.Parse should fail for wrong audience`) {
+			return
+		}
+		_, err = userinfo.Parse(userinfo.MediaTypeJWT, signed, userinfo.WithKeySet(set), userinfo.WithAllowedAlgorithms(jwa.ES256))
+		if !assert.Error(t, err, `userinfo.Parse should fail for disallowed algorithm`) {
+			return
+		}
+		_, err = userinfo.Parse(userinfo.MediaTypeJWT, signed, userinfo.WithKeySet(set), userinfo.WithRequireEncryption(true))
+		if !assert.Error(t, err, `userinfo.Parse should fail when encryption is required`) {
+			return
+		}
+	})
+	t.Run("Encrypted", func(t *testing.T) {
+		t.Parallel()
+		tok, err := userinfo.Parse(userinfo.MediaTypeJWT, encrypted, userinfo.WithDecrypt(jwa.RSA_OAEP, encryptionKey))
+		if !assert.NoError(t, err, `userinfo.Parse should succeed`) {
+			return
+		}
+		if !checkToken(t, tok) {
+			return
+		}
+
+		_, err = userinfo.Parse(userinfo.MediaTypeJWT, encrypted)
+		if !assert.Error(t, err, `userinfo.Parse should fail without a decryption key`) {
+			return
+		}
+		_, err = userinfo.Parse(userinfo.MediaTypeJWT, encrypted, userinfo.WithDecrypt(jwa.RSA_OAEP, encryptionKey), userinfo.WithRequireSignature(true))
+		if !assert.Error(t, err, `userinfo.Parse should fail when a signature is required`) {
+			return
+		}
+	})
+	t.Run("Signed and encrypted", func(t *testing.T) {
+		t.Parallel()
+		tok, err := userinfo.Parse(userinfo.MediaTypeJWT, nested,
+			userinfo.WithDecrypt(jwa.RSA_OAEP, encryptionKey),
+			userinfo.WithKeySet(set),
+			userinfo.WithRequireSignature(true),
+			userinfo.WithRequireEncryption(true),
+		)
+		if !assert.NoError(t, err, `userinfo.Parse should succeed`) {
+			return
+		}
+		if !checkToken(t, tok) {
+			return
+		}
+	})
+	t.Run("Subject mismatch", func(t *testing.T) {
+		t.Parallel()
+		_, err := userinfo.Parse(userinfo.MediaTypeJSON, plain, userinfo.WithSubject(`someone-else`))
+		if !assert.Error(t, err, `userinfo.Parse should fail`) {
+			return
+		}
+		_, err = userinfo.Parse(userinfo.MediaTypeJSON, []byte(`{"name":"Jane Doe"}`))
+		if !assert.Error(t, err, `userinfo.Parse should fail without "sub"`) {
+			return
+		}
+	})
+	t.Run("ParseResponse", func(t *testing.T) {
+		t.Parallel()
+		rec := httptest.NewRecorder()
+		rec.Header().Set(`Content-Type`, userinfo.MediaTypeJWT)
+		rec.Write(signed)
+
+		tok, err := userinfo.ParseResponse(rec.Result(), userinfo.WithKeySet(set), userinfo.WithSubject(subject))
+		if !assert.NoError(t, err, `userinfo.ParseResponse should succeed`) {
+			return
+		}
+		if !checkToken(t, tok) {
+			return
+		}
+
+		rec = httptest.NewRecorder()
+		rec.WriteHeader(http.StatusUnauthorized)
+		_, err = userinfo.ParseResponse(rec.Result())
+		if !assert.Error(t, err, `userinfo.ParseResponse should fail for non-200 responses`) {
+			return
+		}
+	})
+}
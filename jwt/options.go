@@ -3,6 +3,7 @@ package jwt
 import (
 	"time"
 
+	"github.com/lestrrat-go/jwx"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
@@ -36,6 +37,36 @@ type httpParseOption struct {
 
 func (*httpParseOption) httpParseOption() {}
 
+// ClientAssertionOption describes an Option that can be passed to
+// `NewClientAssertion()`.
+type ClientAssertionOption interface {
+	Option
+	clientAssertionOption()
+}
+
+type clientAssertionOption struct {
+	Option
+}
+
+func newClientAssertionOption(n interface{}, v interface{}) ClientAssertionOption {
+	return &clientAssertionOption{option.New(n, v)}
+}
+
+func (*clientAssertionOption) clientAssertionOption() {}
+
+// ClientAssertionParseOption describes an Option that can be passed to
+// `ParseClientAssertion()`.
+type ClientAssertionParseOption interface {
+	ParseOption
+	clientAssertionParseOption()
+}
+
+type clientAssertionParseOption struct {
+	ParseOption
+}
+
+func (*clientAssertionParseOption) clientAssertionParseOption() {}
+
 // ParseOption describes an Option that can be passed to `Parse()`.
 // ParseOption also implements ReadFileOption, therefore it may be
 // safely pass them to `jwt.ReadFile()`
@@ -72,6 +103,26 @@ func newSignOption(n interface{}, v interface{}) SignOption {
 
 func (*signOption) signOption() {}
 
+// MintOption describes an Option that populates the registered claims
+// of a token when it is issued. MintOption also implements SignOption,
+// therefore it may be passed to `jwt.Sign()` and (jwt.Serializer).Sign,
+// as well as `jwt.Mint()`
+type MintOption interface {
+	SignOption
+	mintOption()
+}
+
+type mintOption struct {
+	Option
+}
+
+func newMintOption(n interface{}, v interface{}) MintOption {
+	return &mintOption{option.New(n, v)}
+}
+
+func (*mintOption) mintOption() {}
+func (*mintOption) signOption() {}
+
 // EncryptOption describes an Option that can be passed to Encrypt() or
 // (jwt.Serializer).Encrypt
 type EncryptOption interface {
@@ -89,6 +140,73 @@ func newEncryptOption(n interface{}, v interface{}) EncryptOption {
 
 func (*encryptOption) encryptOption() {}
 
+// EncryptParseOption describes an Option that can be passed to both
+// `jwt.Encrypt()` and `jwt.Parse()`
+type EncryptParseOption interface {
+	EncryptOption
+	ParseOption
+}
+
+type encryptParseOption struct {
+	Option
+}
+
+func newEncryptParseOption(n interface{}, v interface{}) EncryptParseOption {
+	return &encryptParseOption{option.New(n, v)}
+}
+
+func (*encryptParseOption) encryptOption()  {}
+func (*encryptParseOption) parseOption()    {}
+func (*encryptParseOption) readFileOption() {}
+
+// CacheOption describes an Option that can be passed to `jwt.NewCache()`
+type CacheOption interface {
+	Option
+	cacheOption()
+}
+
+type cacheOption struct {
+	Option
+}
+
+func newCacheOption(n interface{}, v interface{}) CacheOption {
+	return &cacheOption{option.New(n, v)}
+}
+
+func (*cacheOption) cacheOption() {}
+
+// MultiIssuerOption describes an Option that can be passed to `jwt.NewMultiIssuerVerifier()`
+type MultiIssuerOption interface {
+	Option
+	multiIssuerOption()
+}
+
+type multiIssuerOption struct {
+	Option
+}
+
+func newMultiIssuerOption(n interface{}, v interface{}) MultiIssuerOption {
+	return &multiIssuerOption{option.New(n, v)}
+}
+
+func (*multiIssuerOption) multiIssuerOption() {}
+
+// IssuerOption describes an Option that can be passed to `jwt.WithIssuerConfig()`
+type IssuerOption interface {
+	Option
+	issuerOption()
+}
+
+type issuerOption struct {
+	Option
+}
+
+func newIssuerOption(n interface{}, v interface{}) IssuerOption {
+	return &issuerOption{option.New(n, v)}
+}
+
+func (*issuerOption) issuerOption() {}
+
 // ValidateOption describes an Option that can be passed to Validate().
 // ValidateOption also implements ParseOption, therefore it may be
 // safely passed to `Parse()` (and thus `jwt.ReadFile()`)
@@ -108,24 +226,62 @@ func newValidateOption(n interface{}, v interface{}) ValidateOption {
 func (*validateOption) validateOption() {}
 
 type identAcceptableSkew struct{}
+type identAssertionAlgorithm struct{}
+type identAssertionClock struct{}
+type identAssertionJwtID struct{}
+type identAssertionLifetime struct{}
+type identAssertionReplayCheck struct{}
 type identAudience struct{}
+type identAutoJTI struct{}
+type identCacheParseOptions struct{}
+type identCacheSize struct{}
+type identCacheTTL struct{}
 type identClaim struct{}
+type identClaimComparison struct{}
 type identClock struct{}
+type identContext struct{}
 type identDecrypt struct{}
 type identDefault struct{}
+type identEncryptedOnly struct{}
+type identExpirationLeeway struct{}
 type identFlattenAudience struct{}
+type identInsecure struct{}
+type identIssuedAtTolerance struct{}
 type identIssuer struct{}
+type identIssuerAcceptableSkew struct{}
+type identIssuerAlgorithms struct{}
+type identIssuerAudience struct{}
+type identIssuerAutoRefresh struct{}
+type identIssuerConfig struct{}
+type identIssuerKeySet struct{}
 type identJweHeaders struct{}
 type identJwsHeaders struct{}
+type identKeyProviderForSigning struct{}
+type identSigningPolicy struct{}
 type identJwtid struct{}
 type identKeySet struct{}
+type identMaxClaimDepth struct{}
+type identMaxTokenSize struct{}
+type identMultiIssuerParseOptions struct{}
+type identNumericDateFormatPrecision struct{}
+type identNumericDateParsePedantic struct{}
+type identNumericDateParsePrecision struct{}
+type identNotBeforeLeeway struct{}
+type identNotBeforeSkew struct{}
+type identNumberFormat struct{}
 type identPedantic struct{}
+type identReplayProtection struct{}
 type identRequiredClaim struct{}
+type identRequiredScopes struct{}
 type identSubject struct{}
 type identTimeDelta struct{}
 type identToken struct{}
+type identTTL struct{}
 type identTypedClaim struct{}
 type identValidate struct{}
+type identValidator struct{}
+type identVerificationCache struct{}
+type identRemoteKeyPolicy struct{}
 type identVerify struct{}
 
 type identHeaderKey struct{}
@@ -168,10 +324,35 @@ func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) ParseOption {
 // set. This is because we need to know the exact algorithm that
 // you (the user) wants to use to verify the token. We do NOT
 // trust the token's headers, because they can easily be tampered with.
+//
+// If the set implements `jwk.UsageRecorder`, such as `jwk.TrackedSet`,
+// the key is recorded as used once the token has been accepted: the
+// signature must verify, and if `jwt.WithValidate(true)` is specified,
+// the claims must pass validation as well.
 func WithKeySet(set jwk.Set) ParseOption {
 	return newParseOption(identKeySet{}, set)
 }
 
+// WithRemoteKeyPolicy specifies how the "jku" and "x5u" headers of
+// the token are handled when it is verified using `jwt.WithVerify()`
+// or `jwt.WithKeySet()`.
+// See `jws.WithRemoteKeyPolicy()` for details.
+func WithRemoteKeyPolicy(p jws.RemoteKeyPolicy) ParseOption {
+	return newParseOption(identRemoteKeyPolicy{}, jws.WithRemoteKeyPolicy(p))
+}
+
+// WithVerificationCache creates a cache of up to `size` tokens whose
+// signatures have been successfully verified. When the identical token
+// is parsed again with the same verification key within `ttl`, the
+// signature is not verified again. Claims are still validated every time.
+//
+// The cache lives within the returned option, so the option must be
+// created once and passed to every call to `jwt.Parse()` that should
+// share the cache. See `jws.WithVerificationCache()` for details.
+func WithVerificationCache(size int, ttl time.Duration) ParseOption {
+	return newParseOption(identVerificationCache{}, jws.WithVerificationCache(size, ttl))
+}
+
 // UseDefaultKey is used in conjunction with the option WithKeySet
 // to instruct the Parse method to default to the single key in a key
 // set when no Key ID is included in the JWT. If the key set contains
@@ -195,6 +376,22 @@ func WithHeaders(hdrs jws.Headers) SignOption {
 	return WithJwsHeaders(hdrs)
 }
 
+// WithKeyProviderForSigning is passed to `jwt.Sign()` function or
+// "jwt.Serializer".Sign() method, to obtain the algorithm, key, and key ID
+// every time a token is signed, instead of using the algorithm and key
+// that were given. See `jws.WithKeyProviderForSigning()` for details.
+func WithKeyProviderForSigning(p jws.SigningKeyProvider) SignOption {
+	return newSignOption(identKeyProviderForSigning{}, p)
+}
+
+// WithSigningPolicy is passed to `jwt.Sign()` function or
+// "jwt.Serializer".Sign() method, to enforce constraints on the
+// JOSE headers of the signed token. See `jws.WithSigningPolicy()`
+// for details.
+func WithSigningPolicy(p jws.SigningPolicy) SignOption {
+	return newSignOption(identSigningPolicy{}, p)
+}
+
 // WithJwsHeaders is passed to `jwt.Sign()` function or
 // "jwt.Serializer".Sign() method, to allow specifying arbitrary
 // header values to be included in the header section of the JWE message
@@ -228,6 +425,34 @@ func WithAcceptableSkew(dur time.Duration) ValidateOption {
 	return newValidateOption(identAcceptableSkew{}, dur)
 }
 
+// WithExpirationLeeway specifies the duration by which the current time
+// may exceed the value of the "exp" claim before the token is considered
+// expired. When specified, this value takes precedence over the value
+// given by `WithAcceptableSkew()` for the "exp" claim only.
+func WithExpirationLeeway(dur time.Duration) ValidateOption {
+	return newValidateOption(identExpirationLeeway{}, dur)
+}
+
+// WithNotBeforeLeeway specifies the duration by which the current time
+// may precede the value of the "nbf" claim before the token is considered
+// not yet valid. When specified, this value takes precedence over the value
+// given by `WithAcceptableSkew()` for the "nbf" claim only.
+func WithNotBeforeLeeway(dur time.Duration) ValidateOption {
+	return newValidateOption(identNotBeforeLeeway{}, dur)
+}
+
+// WithIssuedAtTolerance specifies the duration by which the value of
+// the "iat" claim may be ahead of the current time. When specified, this
+// value takes precedence over the value given by `WithAcceptableSkew()`
+// for the "iat" claim only.
+//
+// This is useful when, for example, you need to reprocess tokens offline
+// with a clock that has drifted, but you do not want to relax the checks
+// against "exp" and "nbf".
+func WithIssuedAtTolerance(dur time.Duration) ValidateOption {
+	return newValidateOption(identIssuedAtTolerance{}, dur)
+}
+
 // WithIssuer specifies that expected issuer value. If not specified,
 // the value of issuer is not verified at all.
 func WithIssuer(s string) ValidateOption {
@@ -254,6 +479,29 @@ func WithAudience(s string) ValidateOption {
 	return newValidateOption(identAudience{}, s)
 }
 
+type claimComparison struct {
+	name string
+	cmp  StringComparison
+}
+
+// WithClaimComparison specifies how the value of the claim `name` is
+// compared against the value given by `jwt.WithIssuer()`,
+// `jwt.WithSubject()`, or `jwt.WithAudience()`. `name` must be one of
+// jwt.IssuerKey, jwt.SubjectKey, or jwt.AudienceKey.
+//
+// By default the values are compared byte by byte. As identity
+// providers are not always consistent about the values they use (e.g.
+// "https://login.example.com/" vs "https://login.example.com"), you may
+// use this option instead of normalizing the values by hand:
+//
+//   jwt.Validate(token,
+//     jwt.WithIssuer(`https://login.example.com`),
+//     jwt.WithClaimComparison(jwt.IssuerKey, jwt.URLComparison),
+//   )
+func WithClaimComparison(name string, cmp StringComparison) ValidateOption {
+	return newValidateOption(identClaimComparison{}, claimComparison{name: name, cmp: cmp})
+}
+
 type claimValue struct {
 	name  string
 	value interface{}
@@ -264,6 +512,30 @@ func WithClaimValue(name string, v interface{}) ValidateOption {
 	return newValidateOption(identClaim{}, claimValue{name, v})
 }
 
+// WithValidator specifies a custom Validator that is run after
+// the standard claims have been validated. This option may be
+// specified multiple times, in which case the validators are
+// run in the order they were given.
+func WithValidator(v Validator) ValidateOption {
+	return newValidateOption(identValidator{}, v)
+}
+
+// WithReplayProtection specifies a JTIStore that is used to reject tokens
+// whose "jti" claim has already been seen for the same "iss". When this
+// option is specified, the "jti" claim is required, and it is recorded in
+// the store until the token expires (including any leeway for the "exp"
+// claim), as measured by the clock specified by `jwt.WithClock()`.
+//
+// The check is performed after all other validations have succeeded, so
+// that tokens that are otherwise invalid are never recorded.
+//
+// Note that validation records the token as a side effect: validating the
+// same token twice with the same store fails the second time. Only pass
+// this option to the single call that accepts the token.
+func WithReplayProtection(store JTIStore) ValidateOption {
+	return newValidateOption(identReplayProtection{}, store)
+}
+
 // WithHeaderKey is used to specify header keys to search for tokens.
 //
 // While the type system allows this option to be passed to jwt.Parse() directly,
@@ -293,6 +565,44 @@ func WithFlattenAudience(v bool) GlobalOption {
 	return &globalOption{option.New(identFlattenAudience{}, v)}
 }
 
+// WithNumericDateParsePrecision sets the precision up to which the
+// library uses to parse fractional dates found in the numeric date
+// fields ("exp", "iat", "nbf", etc). The value specifies the number of
+// fractional digits that are retained: 0 means seconds, 3 means
+// milliseconds, 6 means microseconds, and 9 means nanoseconds.
+// Any digits beyond this precision are truncated, unless
+// `jwt.WithNumericDateParsePedantic(true)` is specified.
+//
+// Values outside of the range 0-9 are clamped. The default value is 0,
+// which means that fractional dates are truncated to seconds.
+// This setting has a global effect.
+func WithNumericDateParsePrecision(v int) GlobalOption {
+	return &globalOption{option.New(identNumericDateParsePrecision{}, v)}
+}
+
+// WithNumericDateParsePedantic specifies whether numeric date values
+// that carry more fractional digits than allowed by
+// `jwt.WithNumericDateParsePrecision()` should be rejected, instead of
+// being silently truncated.
+//
+// The default value is `false`. This setting has a global effect.
+func WithNumericDateParsePedantic(v bool) GlobalOption {
+	return &globalOption{option.New(identNumericDateParsePedantic{}, v)}
+}
+
+// WithNumericDateFormatPrecision sets the precision up to which the
+// library uses to format numeric date fields ("exp", "iat", "nbf", etc)
+// when the token is serialized. The value specifies the number of
+// fractional digits that are emitted, in the same manner as
+// `jwt.WithNumericDateParsePrecision()`.
+//
+// Values outside of the range 0-9 are clamped. The default value is 0,
+// which means that numeric dates are serialized as integers.
+// This setting has a global effect.
+func WithNumericDateFormatPrecision(v int) GlobalOption {
+	return &globalOption{option.New(identNumericDateFormatPrecision{}, v)}
+}
+
 type typedClaimPair struct {
 	Name  string
 	Value interface{}
@@ -332,6 +642,15 @@ func WithRequiredClaim(name string) ValidateOption {
 	return newValidateOption(identRequiredClaim{}, name)
 }
 
+// WithRequiredScopes specifies that the token must have been granted
+// all of the given scopes. Scopes are read using `(jwt.Token).Scopes()`,
+// so both the space-delimited "scope" claim and the array-valued "scp"
+// claim are honored. This option may be specified multiple times,
+// in which case all scopes are required.
+func WithRequiredScopes(scopes ...string) ValidateOption {
+	return newValidateOption(identRequiredScopes{}, scopes)
+}
+
 type delta struct {
 	c1   string
 	c2   string
@@ -402,6 +721,20 @@ func (dp *decryptParams) Key() interface{} {
 	return dp.key
 }
 
+// WithEncryptedOnly allows tokens that are encrypted but not signed,
+// and must be passed to `jwt.Encrypt()` to create them. The only accepted
+// value is `jwt.IntegrityAEADOnly`, which acknowledges that such tokens are
+// only protected by the authenticated encryption of the JWE message.
+//
+// When parsing, a token that is only encrypted is accepted if it was
+// encrypted using a key encryption algorithm where the recipient key is
+// not public (see `jwt.Encrypt()`), and its "cty" header is not "JWT".
+// `jwt.WithDecrypt()` must be specified, and `jwt.WithVerify()` or
+// `jwt.WithKeySet()` is then only required to verify nested tokens.
+func WithEncryptedOnly(m IntegrityModel) EncryptParseOption {
+	return newEncryptParseOption(identEncryptedOnly{}, m)
+}
+
 // WithDecrypt allows users to specify parameters for decryption using
 // `jwe.Decrypt`. You must specify this if your JWT is encrypted.
 func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
@@ -411,8 +744,179 @@ func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
 	})
 }
 
+// WithMaxTokenSize specifies the maximum size in bytes of the token
+// that `jwt.Parse()` and friends will accept. Tokens larger than this
+// are rejected before any decoding or signature verification takes place.
+//
+// When passed to `jwt.ParseReader()`, no more than `n` bytes (plus one,
+// to detect oversized input) are read from the source.
+//
+// The default value is 0, which means that the limit set via
+// `jwx.WithMaxInputSize()` is used, if any.
+func WithMaxTokenSize(n int64) ParseOption {
+	return newParseOption(identMaxTokenSize{}, n)
+}
+
+// WithMaxClaimDepth specifies the maximum depth of nested JSON objects
+// and/or arrays allowed in the JWT claims. For signed and/or encrypted
+// tokens, the same limit is applied to the protected headers and to the
+// JSON serialization of each JWS/JWE layer before it is verified or
+// decrypted, and then to the verified/decrypted payload.
+// The top-level JSON object counts as one level.
+//
+// The default value is 0, which means that the limit set via
+// `jwx.WithMaxNestingDepth()` is used, if any.
+func WithMaxClaimDepth(n int) ParseOption {
+	return newParseOption(identMaxClaimDepth{}, n)
+}
+
+// WithNumberFormat specifies how numbers in claims whose types are not
+// known in advance (i.e. private claims) are decoded. By default they are
+// decoded as float64, which silently loses precision for integers larger
+// than 2^53 (e.g. snowflake IDs). Use `jwt.WithNumberFormat(jwx.NumberAsJSONNumber)`
+// to decode them as json.Number instead.
+//
+// Unlike `jwx.DecoderSettings`, this option only affects the current call,
+// and takes precedence over the global setting.
+func WithNumberFormat(f jwx.NumberFormat) ParseOption {
+	return newParseOption(identNumberFormat{}, f)
+}
+
 // WithPedantic enables pedantic mode for parsing JWTs. Currently this only
 // applies to checking for the correct `typ` and/or `cty` when necessary.
 func WithPedantic(v bool) ParseOption {
 	return newParseOption(identPedantic{}, v)
 }
+
+// WithAssertionAlgorithm specifies the signature algorithm used by
+// `NewClientAssertion()`. If not specified, the algorithm is chosen
+// based on the type of the key.
+func WithAssertionAlgorithm(alg jwa.SignatureAlgorithm) ClientAssertionOption {
+	return newClientAssertionOption(identAssertionAlgorithm{}, alg)
+}
+
+// WithAssertionLifetime specifies the lifetime of the client assertion
+// created by `NewClientAssertion()`, which is used to compute the
+// value of the "exp" claim. The default is `DefaultClientAssertionLifetime`
+func WithAssertionLifetime(d time.Duration) ClientAssertionOption {
+	return newClientAssertionOption(identAssertionLifetime{}, d)
+}
+
+// WithAssertionJwtID specifies the value of the "jti" claim in the
+// client assertion created by `NewClientAssertion()`. By default a
+// random value is generated.
+func WithAssertionJwtID(s string) ClientAssertionOption {
+	return newClientAssertionOption(identAssertionJwtID{}, s)
+}
+
+// WithAssertionClock specifies the `jwt.Clock` used by `NewClientAssertion()`
+// to compute the values of the "iat" and "exp" claims
+func WithAssertionClock(c Clock) ClientAssertionOption {
+	return newClientAssertionOption(identAssertionClock{}, c)
+}
+
+// AssertionReplayCheckFunc is called by `ParseClientAssertion()` with the
+// "jti" and "exp" claims of a client assertion that has been verified and
+// validated. It should return an error if the "jti" has already been used,
+// and otherwise remember it at least until `exp`.
+type AssertionReplayCheckFunc func(jti string, exp time.Time) error
+
+// WithAssertionReplayCheck specifies a function that is used by
+// `ParseClientAssertion()` to reject client assertions that are replayed.
+//
+// While the type system allows this option to be passed to jwt.Parse() directly,
+// doing so will have no effect. Only use it for `ParseClientAssertion()`
+func WithAssertionReplayCheck(fn AssertionReplayCheckFunc) ClientAssertionParseOption {
+	return &clientAssertionParseOption{newParseOption(identAssertionReplayCheck{}, fn)}
+}
+
+// WithCacheSize specifies the maximum number of tokens that a
+// `jwt.Cache` holds. Once the cache is full, the least recently used
+// tokens are evicted. The default value is 1000.
+func WithCacheSize(n int) CacheOption {
+	return newCacheOption(identCacheSize{}, n)
+}
+
+// WithCacheTTL specifies the maximum duration for which a `jwt.Cache`
+// remembers a token. Tokens are never remembered beyond their "exp"
+// claim. The default value is 1 minute.
+func WithCacheTTL(d time.Duration) CacheOption {
+	return newCacheOption(identCacheTTL{}, d)
+}
+
+// WithCacheParseOptions specifies the options that a `jwt.Cache` passes
+// to `jwt.Parse()` when parsing tokens that are not in the cache.
+func WithCacheParseOptions(options ...ParseOption) CacheOption {
+	return newCacheOption(identCacheParseOptions{}, options)
+}
+
+// WithIssuerConfig registers the issuer `iss` with a `jwt.MultiIssuerVerifier`.
+// The options specify the keys that tokens from this issuer are verified
+// against, and the requirements that they must satisfy. Either
+// `jwt.WithIssuerKeySet()` or `jwt.WithIssuerAutoRefresh()` must be specified.
+func WithIssuerConfig(iss string, options ...IssuerOption) MultiIssuerOption {
+	return newMultiIssuerOption(identIssuerConfig{}, &issuerConfigSpec{issuer: iss, options: options})
+}
+
+// WithMultiIssuerParseOptions specifies options that are passed to
+// `jwt.Parse()` for tokens from all issuers, such as `jwt.WithMaxTokenSize()`
+// or `jwt.WithRequiredClaim()`.
+func WithMultiIssuerParseOptions(options ...ParseOption) MultiIssuerOption {
+	return newMultiIssuerOption(identMultiIssuerParseOptions{}, options)
+}
+
+// WithIssuerKeySet specifies the key set that tokens from the issuer
+// are verified against. See `jwt.WithKeySet()` for details.
+func WithIssuerKeySet(set jwk.Set) IssuerOption {
+	return newIssuerOption(identIssuerKeySet{}, set)
+}
+
+// WithIssuerAutoRefresh specifies that tokens from the issuer are verified
+// against the key set at `url`, which is fetched using `ar`. The url must
+// have been registered using `(jwk.AutoRefresh).Configure()`.
+func WithIssuerAutoRefresh(ar *jwk.AutoRefresh, url string) IssuerOption {
+	return newIssuerOption(identIssuerAutoRefresh{}, &issuerAutoRefresh{ar: ar, url: url})
+}
+
+// WithIssuerAlgorithms restricts the signature algorithms that are
+// accepted for tokens from the issuer. Both the "alg" header of the token
+// and the "alg" of the key used to verify it must be one of them, so keys
+// with any other "alg" are ignored. If not specified, any algorithm that
+// the keys in the key set allow is accepted.
+func WithIssuerAlgorithms(algs ...jwa.SignatureAlgorithm) IssuerOption {
+	return newIssuerOption(identIssuerAlgorithms{}, algs)
+}
+
+// WithIssuerAudience specifies the audiences that tokens from the issuer
+// must be intended for. The token is accepted if its "aud" claim contains
+// any of them.
+func WithIssuerAudience(aud ...string) IssuerOption {
+	return newIssuerOption(identIssuerAudience{}, aud)
+}
+
+// WithIssuerAcceptableSkew specifies the clock skew that is tolerated
+// when validating time based claims of tokens from the issuer.
+// See `jwt.WithAcceptableSkew()` for details.
+func WithIssuerAcceptableSkew(dur time.Duration) IssuerOption {
+	return newIssuerOption(identIssuerAcceptableSkew{}, dur)
+}
+
+// WithTTL specifies that the "exp" claim of the token being issued
+// is set to `d` after its "iat" claim. See `jwt.Mint()` for details.
+func WithTTL(d time.Duration) MintOption {
+	return newMintOption(identTTL{}, d)
+}
+
+// WithAutoJTI specifies that the "jti" claim of the token being issued
+// is set to a random, unique value. See `jwt.Mint()` for details.
+func WithAutoJTI() MintOption {
+	return newMintOption(identAutoJTI{}, true)
+}
+
+// WithNotBeforeSkew specifies that the "nbf" claim of the token being
+// issued is set to `d` before its "iat" claim, so that the token is
+// accepted by verifiers whose clock is slightly behind the issuer's.
+// See `jwt.Mint()` for details.
+func WithNotBeforeSkew(d time.Duration) MintOption {
+	return newMintOption(identNotBeforeSkew{}, d)
+}
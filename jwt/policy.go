@@ -0,0 +1,439 @@
+package jwt
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/pkg/errors"
+)
+
+// Constraint describes a condition that a claim value must satisfy.
+// Constraints are created using functions such as `jwt.Equals()`,
+// `jwt.OneOf()`, and `jwt.Contains()`, or loaded as part of a `jwt.Policy`.
+type Constraint interface {
+	Check(v interface{}) error
+}
+
+// Policy describes a set of constraints that tokens must satisfy in order
+// to be accepted. A Policy implements `jwt.Validator`, and thus may be
+// passed to `jwt.Parse()` and `jwt.Validate()` using `jwt.WithValidator()`.
+//
+//   policy := &jwt.Policy{
+//     Issuer: jwt.OneOf("https://a.example.com", "https://b.example.com"),
+//     Claims: map[string]jwt.Constraint{
+//       "scope": jwt.Contains("read"),
+//     },
+//   }
+//
+// Policies can also be loaded from JSON (or YAML) documents, which
+// allows them to be configured without code changes:
+//
+//   {
+//     "iss": {"one_of": ["https://a.example.com", "https://b.example.com"]},
+//     "claims": {
+//       "scope": {"contains": ["read"]}
+//     },
+//     "required": ["sub"]
+//   }
+//
+// A constraint is specified as an object with exactly one of the following
+// keys: "equals", "one_of", "contains", "matches", "all_of", and "any_of".
+// The last two take a list of constraints.
+type Policy struct {
+	Issuer   Constraint
+	Subject  Constraint
+	Audience Constraint
+	Claims   map[string]Constraint
+	Required []string
+}
+
+// ParsePolicy parses a JSON document describing a Policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, errors.Wrap(err, `failed to parse policy`)
+	}
+	return &p, nil
+}
+
+// Validate checks that the token satisfies all the constraints in the policy
+func (p *Policy) Validate(t Token) error {
+	for _, name := range p.Required {
+		if _, ok := t.Get(name); !ok {
+			return errors.Errorf(`required claim %s was not found`, name)
+		}
+	}
+
+	constraints := make(map[string]Constraint, len(p.Claims)+3)
+	for name, c := range p.Claims {
+		constraints[name] = c
+	}
+	for _, pair := range []struct {
+		Name       string
+		Constraint Constraint
+	}{
+		{IssuerKey, p.Issuer},
+		{SubjectKey, p.Subject},
+		{AudienceKey, p.Audience},
+	} {
+		if pair.Constraint != nil {
+			constraints[pair.Name] = pair.Constraint
+		}
+	}
+
+	// Check the claims in a stable order, so that errors are reproducible
+	names := make([]string, 0, len(constraints))
+	for name := range constraints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v, ok := t.Get(name)
+		if !ok {
+			return errors.Errorf(`%s not satisfied: claim was not found`, name)
+		}
+		if err := constraints[name].Check(v); err != nil {
+			return errors.Wrapf(err, `%s not satisfied`, name)
+		}
+	}
+	return nil
+}
+
+type rawPolicy struct {
+	Issuer   json.RawMessage            `json:"iss,omitempty"`
+	Subject  json.RawMessage            `json:"sub,omitempty"`
+	Audience json.RawMessage            `json:"aud,omitempty"`
+	Claims   map[string]json.RawMessage `json:"claims,omitempty"`
+	Required []string                   `json:"required,omitempty"`
+}
+
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var raw rawPolicy
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errors.Wrap(err, `failed to unmarshal policy`)
+	}
+
+	var p2 Policy
+	for _, pair := range []struct {
+		Name string
+		Src  json.RawMessage
+		Dst  *Constraint
+	}{
+		{IssuerKey, raw.Issuer, &p2.Issuer},
+		{SubjectKey, raw.Subject, &p2.Subject},
+		{AudienceKey, raw.Audience, &p2.Audience},
+	} {
+		if len(pair.Src) == 0 {
+			continue
+		}
+		c, err := ParseConstraint(pair.Src)
+		if err != nil {
+			return errors.Wrapf(err, `invalid constraint for %s`, pair.Name)
+		}
+		*(pair.Dst) = c
+	}
+
+	if len(raw.Claims) > 0 {
+		p2.Claims = make(map[string]Constraint, len(raw.Claims))
+		for name, src := range raw.Claims {
+			c, err := ParseConstraint(src)
+			if err != nil {
+				return errors.Wrapf(err, `invalid constraint for %s`, name)
+			}
+			p2.Claims[name] = c
+		}
+	}
+	p2.Required = raw.Required
+
+	*p = p2
+	return nil
+}
+
+// UnmarshalYAML allows a Policy to be loaded from YAML documents, using
+// packages such as gopkg.in/yaml.v2 and gopkg.in/yaml.v3. The document
+// must have the same structure as its JSON counterpart.
+func (p *Policy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return errors.Wrap(err, `failed to unmarshal policy`)
+	}
+
+	normalized, err := normalizeYAML(v)
+	if err != nil {
+		return errors.Wrap(err, `failed to unmarshal policy`)
+	}
+
+	buf, err := json.Marshal(normalized)
+	if err != nil {
+		return errors.Wrap(err, `failed to unmarshal policy`)
+	}
+	return p.UnmarshalJSON(buf)
+}
+
+// normalizeYAML converts map[interface{}]interface{} values, as produced
+// by some YAML decoders, into map[string]interface{} values so that they
+// can be encoded as JSON
+func normalizeYAML(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			s, ok := key.(string)
+			if !ok {
+				return nil, errors.Errorf(`invalid key type %T`, key)
+			}
+			normalized, err := normalizeYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			m[s] = normalized
+		}
+		return m, nil
+	case map[string]interface{}:
+		for key, value := range v {
+			normalized, err := normalizeYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = normalized
+		}
+		return v, nil
+	case []interface{}:
+		for i, value := range v {
+			normalized, err := normalizeYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = normalized
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// ParseConstraint parses the JSON representation of a constraint,
+// for example `{"one_of": ["a", "b"]}`
+func ParseConstraint(data []byte) (Constraint, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrap(err, `failed to unmarshal constraint`)
+	}
+	if len(m) != 1 {
+		return nil, errors.Errorf(`constraint must contain exactly one key, got %d`, len(m))
+	}
+
+	var kind string
+	var src json.RawMessage
+	for k, v := range m {
+		kind, src = k, v
+	}
+
+	switch kind {
+	case `equals`:
+		var v interface{}
+		if err := json.Unmarshal(src, &v); err != nil {
+			return nil, errors.Wrapf(err, `failed to unmarshal %q constraint`, kind)
+		}
+		return Equals(v), nil
+	case `one_of`, `contains`:
+		var list []interface{}
+		if err := json.Unmarshal(src, &list); err != nil {
+			return nil, errors.Wrapf(err, `failed to unmarshal %q constraint`, kind)
+		}
+		if kind == `one_of` {
+			return OneOf(list...), nil
+		}
+		return Contains(list...), nil
+	case `matches`:
+		var pattern string
+		if err := json.Unmarshal(src, &pattern); err != nil {
+			return nil, errors.Wrapf(err, `failed to unmarshal %q constraint`, kind)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, `invalid pattern for %q constraint`, kind)
+		}
+		return Matches(re), nil
+	case `all_of`, `any_of`:
+		var list []json.RawMessage
+		if err := json.Unmarshal(src, &list); err != nil {
+			return nil, errors.Wrapf(err, `failed to unmarshal %q constraint`, kind)
+		}
+		constraints := make([]Constraint, len(list))
+		for i, elem := range list {
+			c, err := ParseConstraint(elem)
+			if err != nil {
+				return nil, errors.Wrapf(err, `invalid constraint in %q`, kind)
+			}
+			constraints[i] = c
+		}
+		if kind == `all_of` {
+			return AllOf(constraints...), nil
+		}
+		return AnyOf(constraints...), nil
+	default:
+		return nil, errors.Errorf(`unknown constraint %q`, kind)
+	}
+}
+
+// claimValues returns the individual values of a claim: the elements if
+// the claim is a list, or the value itself otherwise
+func claimValues(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list
+	default:
+		return []interface{}{v}
+	}
+}
+
+type equalsConstraint struct {
+	value interface{}
+}
+
+// Equals creates a Constraint that is satisfied when the claim value is
+// equal to `v`. Numeric values are compared regardless of their Go types.
+func Equals(v interface{}) Constraint {
+	return &equalsConstraint{value: v}
+}
+
+func (c *equalsConstraint) Check(v interface{}) error {
+	if !claimValueEqual(v, c.value) {
+		return errors.Errorf(`expected %v, got %v`, c.value, v)
+	}
+	return nil
+}
+
+type oneOfConstraint struct {
+	values []interface{}
+}
+
+// OneOf creates a Constraint that is satisfied when the claim value is
+// equal to one of `values`. If the claim value is a list (such as "aud"),
+// the constraint is satisfied when at least one of its elements is equal
+// to one of `values`.
+func OneOf(values ...interface{}) Constraint {
+	return &oneOfConstraint{values: values}
+}
+
+func (c *oneOfConstraint) Check(v interface{}) error {
+	for _, elem := range claimValues(v) {
+		for _, expected := range c.values {
+			if claimValueEqual(elem, expected) {
+				return nil
+			}
+		}
+	}
+	return errors.Errorf(`%v is not one of %v`, v, c.values)
+}
+
+type containsConstraint struct {
+	values []interface{}
+}
+
+// Contains creates a Constraint that is satisfied when the claim value
+// contains all of `values`. The claim value may be a list, or a string
+// of space-delimited values such as the "scope" claim.
+func Contains(values ...interface{}) Constraint {
+	return &containsConstraint{values: values}
+}
+
+func (c *containsConstraint) Check(v interface{}) error {
+	var list []interface{}
+	if s, ok := v.(string); ok {
+		for _, field := range strings.Fields(s) {
+			list = append(list, field)
+		}
+	} else {
+		list = claimValues(v)
+	}
+
+	for _, expected := range c.values {
+		var found bool
+		for _, elem := range list {
+			if claimValueEqual(elem, expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf(`%v does not contain %v`, v, expected)
+		}
+	}
+	return nil
+}
+
+type matchesConstraint struct {
+	re *regexp.Regexp
+}
+
+// Matches creates a Constraint that is satisfied when the claim value is
+// a string that matches the regular expression `re`. If the claim value
+// is a list, all of its elements must match.
+func Matches(re *regexp.Regexp) Constraint {
+	return &matchesConstraint{re: re}
+}
+
+func (c *matchesConstraint) Check(v interface{}) error {
+	for _, elem := range claimValues(v) {
+		s, ok := elem.(string)
+		if !ok {
+			return errors.Errorf(`expected string, got %T`, elem)
+		}
+		if !c.re.MatchString(s) {
+			return errors.Errorf(`%q does not match %s`, s, c.re)
+		}
+	}
+	return nil
+}
+
+type allOfConstraint struct {
+	constraints []Constraint
+}
+
+// AllOf creates a Constraint that is satisfied when all of
+// `constraints` are satisfied
+func AllOf(constraints ...Constraint) Constraint {
+	return &allOfConstraint{constraints: constraints}
+}
+
+func (c *allOfConstraint) Check(v interface{}) error {
+	for _, constraint := range c.constraints {
+		if err := constraint.Check(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type anyOfConstraint struct {
+	constraints []Constraint
+}
+
+// AnyOf creates a Constraint that is satisfied when at least one of
+// `constraints` is satisfied
+func AnyOf(constraints ...Constraint) Constraint {
+	return &anyOfConstraint{constraints: constraints}
+}
+
+func (c *anyOfConstraint) Check(v interface{}) error {
+	var errs []string
+	for _, constraint := range c.constraints {
+		err := constraint.Check(v)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return errors.Errorf(`none of the constraints were satisfied: [%s]`, strings.Join(errs, `, `))
+}
@@ -0,0 +1,170 @@
+package jwt_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	tok := jwt.New()
+	tok.Set(jwt.IssuerKey, `https://b.example.com`)
+	tok.Set(jwt.SubjectKey, `user-1234`)
+	tok.Set(jwt.AudienceKey, []string{`api`, `admin`})
+	tok.Set(`scope`, `read write`)
+	tok.Set(`level`, 3)
+	tok.Set(`roles`, []interface{}{`editor`, `viewer`})
+
+	t.Run("Constraints", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name       string
+			Constraint jwt.Constraint
+			Value      interface{}
+			Error      bool
+		}{
+			{Name: "Equals", Constraint: jwt.Equals(3), Value: float64(3)},
+			{Name: "Equals (mismatch)", Constraint: jwt.Equals(`foo`), Value: `bar`, Error: true},
+			{Name: "OneOf", Constraint: jwt.OneOf(`a`, `b`), Value: `b`},
+			{Name: "OneOf (list)", Constraint: jwt.OneOf(`admin`), Value: []string{`api`, `admin`}},
+			{Name: "OneOf (mismatch)", Constraint: jwt.OneOf(`a`, `b`), Value: `c`, Error: true},
+			{Name: "Contains (space-delimited)", Constraint: jwt.Contains(`read`), Value: `read write`},
+			{Name: "Contains (list)", Constraint: jwt.Contains(`editor`, `viewer`), Value: []interface{}{`viewer`, `editor`}},
+			{Name: "Contains (mismatch)", Constraint: jwt.Contains(`delete`), Value: `read write`, Error: true},
+			{Name: "Matches", Constraint: jwt.Matches(regexp.MustCompile(`^user-\d+$`)), Value: `user-1234`},
+			{Name: "Matches (non-string)", Constraint: jwt.Matches(regexp.MustCompile(`.*`)), Value: 1, Error: true},
+			{Name: "AllOf", Constraint: jwt.AllOf(jwt.Contains(`read`), jwt.Contains(`write`)), Value: `read write`},
+			{Name: "AllOf (mismatch)", Constraint: jwt.AllOf(jwt.Contains(`read`), jwt.Contains(`delete`)), Value: `read write`, Error: true},
+			{Name: "AnyOf", Constraint: jwt.AnyOf(jwt.Equals(`a`), jwt.Equals(`b`)), Value: `b`},
+			{Name: "AnyOf (mismatch)", Constraint: jwt.AnyOf(jwt.Equals(`a`), jwt.Equals(`b`)), Value: `c`, Error: true},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				err := tc.Constraint.Check(tc.Value)
+				if tc.Error {
+					assert.Error(t, err, `Check should fail`)
+				} else {
+					assert.NoError(t, err, `Check should succeed`)
+				}
+			})
+		}
+	})
+	t.Run("Validate", func(t *testing.T) {
+		t.Parallel()
+		policy := &jwt.Policy{
+			Issuer:   jwt.OneOf(`https://a.example.com`, `https://b.example.com`),
+			Audience: jwt.OneOf(`api`),
+			Claims: map[string]jwt.Constraint{
+				`scope`: jwt.Contains(`read`),
+			},
+			Required: []string{jwt.SubjectKey},
+		}
+		if !assert.NoError(t, jwt.Validate(tok, jwt.WithValidator(policy)), `jwt.Validate should succeed`) {
+			return
+		}
+
+		policy.Claims[`scope`] = jwt.Contains(`delete`)
+		if !assert.Error(t, jwt.Validate(tok, jwt.WithValidator(policy)), `jwt.Validate should fail`) {
+			return
+		}
+
+		policy.Claims = map[string]jwt.Constraint{`missing`: jwt.Equals(`x`)}
+		if !assert.Error(t, jwt.Validate(tok, jwt.WithValidator(policy)), `jwt.Validate should fail for missing claim`) {
+			return
+		}
+
+		policy.Claims = nil
+		policy.Required = []string{jwt.JwtIDKey}
+		if !assert.Error(t, jwt.Validate(tok, jwt.WithValidator(policy)), `jwt.Validate should fail for missing required claim`) {
+			return
+		}
+	})
+	t.Run("ParsePolicy", func(t *testing.T) {
+		t.Parallel()
+		src := `{
+  "iss": {"one_of": ["https://a.example.com", "https://b.example.com"]},
+  "sub": {"matches": "^user-[0-9]+$"},
+  "claims": {
+    "scope": {"all_of": [{"contains": ["read"]}, {"contains": ["write"]}]},
+    "level": {"any_of": [{"equals": 3}, {"equals": 4}]}
+  },
+  "required": ["aud"]
+}`
+		policy, err := jwt.ParsePolicy([]byte(src))
+		if !assert.NoError(t, err, `jwt.ParsePolicy should succeed`) {
+			return
+		}
+		if !assert.NoError(t, policy.Validate(tok), `policy.Validate should succeed`) {
+			return
+		}
+
+		tok2, err := tok.Clone()
+		if !assert.NoError(t, err, `tok.Clone should succeed`) {
+			return
+		}
+		tok2.Set(`level`, 5)
+		if !assert.Error(t, policy.Validate(tok2), `policy.Validate should fail`) {
+			return
+		}
+
+		for _, invalid := range []string{
+			`{"iss": {"unknown": "x"}}`,
+			`{"iss": {"equals": "x", "one_of": ["y"]}}`,
+			`{"sub": {"matches": "("}}`,
+			`{"claims": {"scope": {"any_of": [{"nope": 1}]}}}`,
+		} {
+			_, err := jwt.ParsePolicy([]byte(invalid))
+			if !assert.Error(t, err, `jwt.ParsePolicy should fail for %s`, invalid) {
+				return
+			}
+		}
+	})
+	t.Run("YAML", func(t *testing.T) {
+		t.Parallel()
+		// the value as decoded by gopkg.in/yaml.v2 from
+		//
+		//   iss:
+		//     one_of:
+		//       - https://b.example.com
+		//   aud:
+		//     one_of: [admin]
+		//   claims:
+		//     roles:
+		//       contains: [editor]
+		decoded := map[interface{}]interface{}{
+			"iss": map[interface{}]interface{}{
+				"one_of": []interface{}{"https://b.example.com"},
+			},
+			"aud": map[interface{}]interface{}{
+				"one_of": []interface{}{"admin"},
+			},
+			"claims": map[interface{}]interface{}{
+				"roles": map[interface{}]interface{}{
+					"contains": []interface{}{"editor"},
+				},
+			},
+		}
+		unmarshal := func(v interface{}) error {
+			*(v.(*interface{})) = decoded
+			return nil
+		}
+
+		var policy jwt.Policy
+		if !assert.NoError(t, policy.UnmarshalYAML(unmarshal), `policy.UnmarshalYAML should succeed`) {
+			return
+		}
+		if !assert.NoError(t, policy.Validate(tok), `policy.Validate should succeed`) {
+			return
+		}
+
+		decoded["claims"] = map[interface{}]interface{}{1: "roles"}
+		if !assert.Error(t, policy.UnmarshalYAML(unmarshal), `policy.UnmarshalYAML should fail for non-string keys`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// JTIStore records the "jti" claims of tokens that have been accepted,
+// so that tokens can be rejected when they are replayed.
+// Use `jwt.WithReplayProtection()` to enable replay protection.
+//
+// Implementations must be safe for concurrent use. To share state
+// between multiple processes, implement this interface on top of
+// a shared store such as Redis or memcached.
+type JTIStore interface {
+	// Add records that the token identified by `jti` and issued by
+	// `iss` has been used, and that it must be remembered at least until
+	// `expires`. The same "jti" from different issuers identifies
+	// different tokens. If `expires` is the zero value, the token does
+	// not expire, and it is up to the store to decide how long to
+	// remember it. `now` is the current time according to the clock
+	// used for validation (see `jwt.WithClock()`).
+	//
+	// Add must return false if the token has already been recorded and
+	// has not expired yet. Checking and recording must be atomic.
+	Add(iss, jti string, expires, now time.Time) (bool, error)
+}
+
+type jtiKey struct {
+	iss string
+	jti string
+}
+
+// MemoryJTIStore is an in-memory implementation of JTIStore.
+// Expired entries are purged lazily as new entries are added.
+type MemoryJTIStore struct {
+	mu         sync.Mutex
+	entries    map[jtiKey]time.Time
+	ttl        time.Duration
+	lastPurged time.Time
+}
+
+// NewMemoryJTIStore creates a new in-memory JTIStore. `ttl` is the
+// duration for which tokens without an expiration time are remembered.
+func NewMemoryJTIStore(ttl time.Duration) *MemoryJTIStore {
+	return &MemoryJTIStore{
+		entries: make(map[jtiKey]time.Time),
+		ttl:     ttl,
+	}
+}
+
+func (s *MemoryJTIStore) Add(iss, jti string, expires, now time.Time) (bool, error) {
+	if expires.IsZero() {
+		expires = now.Add(s.ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Purging requires a full scan, so do it at most once per second
+	if d := now.Sub(s.lastPurged); d >= time.Second || d < 0 {
+		for k, v := range s.entries {
+			if !now.Before(v) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastPurged = now
+	}
+
+	key := jtiKey{iss: iss, jti: jti}
+	if v, ok := s.entries[key]; ok && now.Before(v) {
+		return false, nil
+	}
+	s.entries[key] = expires
+	return true, nil
+}
+
+// Len returns the number of entries currently in the store, including
+// those that have expired but have not been purged yet
+func (s *MemoryJTIStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
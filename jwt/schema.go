@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"github.com/lestrrat-go/jwx/jwt/internal/types"
+	"github.com/pkg/errors"
+)
+
+// JSONSchemaDialect is the JSON Schema dialect of the schemas generated
+// by `jwt.SchemaFor()`
+const JSONSchemaDialect = `https://json-schema.org/draft/2020-12/schema`
+
+func init() {
+	types.RegisterSchema(&stdToken{}, &types.Schema{
+		Claims:   stdClaimSchemas(),
+		Registry: registry,
+	})
+}
+
+func stdClaimSchemas() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		AudienceKey:   types.StringListSchema(),
+		ExpirationKey: types.NumericDateSchema(),
+		IssuedAtKey:   types.NumericDateSchema(),
+		IssuerKey:     {`type`: `string`},
+		JwtIDKey:      {`type`: `string`},
+		NotBeforeKey:  types.NumericDateSchema(),
+		SubjectKey:    {`type`: `string`},
+	}
+}
+
+// SchemaFor generates a JSON Schema describing the claims of tokens of
+// the same type as `t` (e.g. `jwt.New()` or `openid.New()`), so that API
+// documentation and request validators can be generated from the same
+// definitions that are used for parsing.
+//
+// The schema includes the registered claims of the token type, as well
+// as the custom claims registered via `jwt.RegisterCustomField()` (or
+// the equivalent function of the package that defines the token type).
+// The types of custom claims are derived from the Go types that they
+// were registered with. Claims are not marked as required, and other
+// claims are allowed.
+//
+// The result can be serialized using `json.Marshal()`.
+func SchemaFor(t Token) (map[string]interface{}, error) {
+	s, ok := types.LookupSchema(t)
+	if !ok {
+		return nil, errors.Errorf(`no schema is available for token type %T`, t)
+	}
+
+	props := make(map[string]interface{})
+	for name, typ := range s.Registry.Fields() {
+		props[name] = types.TypeSchema(typ)
+	}
+	// registered claims cannot be overridden by custom claims
+	for name, schema := range s.Claims {
+		props[name] = schema
+	}
+
+	return map[string]interface{}{
+		`$schema`:              JSONSchemaDialect,
+		`type`:                 `object`,
+		`properties`:           props,
+		`additionalProperties`: true,
+	}, nil
+}
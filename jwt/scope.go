@@ -0,0 +1,20 @@
+package jwt
+
+import "github.com/lestrrat-go/jwx/jwt/internal/types"
+
+// ScpKey is the name of the claim that some identity providers use to
+// hold the granted scopes as an array, instead of the space-delimited
+// "scope" claim
+const ScpKey = "scp"
+
+func (t *stdToken) Scopes() []string {
+	return scopesOf(t)
+}
+
+// scopesOf returns the scopes from the "scope" and "scp" claims of
+// any token, so that it also works for user-supplied Token types
+func scopesOf(t Token) []string {
+	scope, _ := t.Get(ScopeKey)
+	scp, _ := t.Get(ScpKey)
+	return types.Scopes(scope, scp)
+}
@@ -0,0 +1,179 @@
+package sdjwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+
+	// Register the hash functions used for digests
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/pkg/errors"
+)
+
+// Names of the supported digest algorithms, as registered in the
+// IANA "Named Information Hash Algorithm" registry
+const (
+	SHA256 = `sha-256`
+	SHA384 = `sha-384`
+	SHA512 = `sha-512`
+)
+
+func hashFunc(alg string) (crypto.Hash, error) {
+	switch alg {
+	case SHA256:
+		return crypto.SHA256, nil
+	case SHA384:
+		return crypto.SHA384, nil
+	case SHA512:
+		return crypto.SHA512, nil
+	default:
+		return 0, errors.Errorf(`unsupported digest algorithm %q`, alg)
+	}
+}
+
+func digest(alg string, data string) (string, error) {
+	hf, err := hashFunc(alg)
+	if err != nil {
+		return "", err
+	}
+	h := hf.New()
+	h.Write([]byte(data))
+	return base64.EncodeToString(h.Sum(nil)), nil
+}
+
+// Disclosure represents a single selectively disclosable value. A disclosure
+// for an object property is a JSON array of the form [salt, name, value],
+// while a disclosure for an array element is of the form [salt, value].
+type Disclosure struct {
+	salt    string
+	name    string
+	value   interface{}
+	element bool
+	encoded string
+}
+
+func newSalt() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, `failed to generate salt`)
+	}
+	return base64.EncodeToString(buf), nil
+}
+
+// NewDisclosure creates a new disclosure for the object property `name`
+// with a random salt
+func NewDisclosure(name string, value interface{}) (*Disclosure, error) {
+	switch name {
+	case sdKey, ellipsisKey:
+		return nil, errors.Errorf(`%q cannot be used as a claim name`, name)
+	}
+
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	return encodeDisclosure(&Disclosure{salt: salt, name: name, value: value})
+}
+
+// NewArrayElementDisclosure creates a new disclosure for an array element
+// with a random salt
+func NewArrayElementDisclosure(value interface{}) (*Disclosure, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	return encodeDisclosure(&Disclosure{salt: salt, value: value, element: true})
+}
+
+func encodeDisclosure(d *Disclosure) (*Disclosure, error) {
+	list := []interface{}{d.salt}
+	if !d.element {
+		list = append(list, d.name)
+	}
+	list = append(list, d.value)
+
+	buf, err := json.Marshal(list)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to encode disclosure`)
+	}
+	d.encoded = base64.EncodeToString(buf)
+	return d, nil
+}
+
+// ParseDisclosure parses the base64url encoded form of a disclosure
+func ParseDisclosure(s string) (*Disclosure, error) {
+	buf, err := base64.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode disclosure`)
+	}
+
+	var list []interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&list); err != nil {
+		return nil, errors.Wrap(err, `failed to parse disclosure`)
+	}
+
+	d := Disclosure{encoded: s}
+	switch len(list) {
+	case 2:
+		d.element = true
+		d.value = list[1]
+	case 3:
+		name, ok := list[1].(string)
+		if !ok {
+			return nil, errors.Errorf(`invalid disclosure: claim name must be a string (%T)`, list[1])
+		}
+		switch name {
+		case sdKey, ellipsisKey:
+			return nil, errors.Errorf(`invalid disclosure: %q cannot be used as a claim name`, name)
+		}
+		d.name = name
+		d.value = list[2]
+	default:
+		return nil, errors.Errorf(`invalid disclosure: expected 2 or 3 elements, got %d`, len(list))
+	}
+
+	salt, ok := list[0].(string)
+	if !ok {
+		return nil, errors.Errorf(`invalid disclosure: salt must be a string (%T)`, list[0])
+	}
+	d.salt = salt
+	return &d, nil
+}
+
+// Salt returns the salt of the disclosure
+func (d *Disclosure) Salt() string {
+	return d.salt
+}
+
+// Name returns the claim name of the disclosure. It is empty for
+// array element disclosures
+func (d *Disclosure) Name() string {
+	return d.name
+}
+
+// Value returns the disclosed value
+func (d *Disclosure) Value() interface{} {
+	return d.value
+}
+
+// IsArrayElement returns true if the disclosure is for an array element
+func (d *Disclosure) IsArrayElement() bool {
+	return d.element
+}
+
+// String returns the base64url encoded form of the disclosure
+func (d *Disclosure) String() string {
+	return d.encoded
+}
+
+// Digest computes the digest of the disclosure using the digest
+// algorithm `alg` (e.g. "sha-256")
+func (d *Disclosure) Digest(alg string) (string, error) {
+	return digest(alg, d.encoded)
+}
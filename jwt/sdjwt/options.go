@@ -0,0 +1,142 @@
+package sdjwt
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// SignOption describes an Option that can be passed to `sdjwt.Sign()`
+type SignOption interface {
+	Option
+	signOption()
+}
+
+type signOption struct {
+	Option
+}
+
+func (*signOption) signOption() {}
+
+// VerifyOption describes an Option that can be passed to `sdjwt.Verify()`
+type VerifyOption interface {
+	Option
+	verifyOption()
+}
+
+type verifyOption struct {
+	Option
+}
+
+func (*verifyOption) verifyOption() {}
+
+type identAllowedAlgorithms struct{}
+type identArrayElements struct{}
+type identDecoys struct{}
+type identDigestAlgorithm struct{}
+type identHeaders struct{}
+type identHolderKey struct{}
+type identKeyBinding struct{}
+type identKeyBindingMaxAge struct{}
+type identKeySet struct{}
+type identParseOptions struct{}
+type identSelective struct{}
+type identVerify struct{}
+
+type verifyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+type keyBindingParams struct {
+	audience string
+	nonce    string
+}
+
+// WithSelectiveDisclosure specifies the names of the top-level claims
+// that should be made selectively disclosable. The claims are removed from
+// the issuer-signed JWT, and their digests are embedded in the "_sd" claim.
+func WithSelectiveDisclosure(names ...string) SignOption {
+	return &signOption{option.New(identSelective{}, names)}
+}
+
+// WithSelectiveArrayElements specifies the names of the top-level claims
+// whose values are arrays, and whose elements should each be made
+// selectively disclosable.
+func WithSelectiveArrayElements(names ...string) SignOption {
+	return &signOption{option.New(identArrayElements{}, names)}
+}
+
+// WithDecoyDigests specifies the number of decoy digests that are added
+// to the top-level "_sd" claim, in order to hide the number of
+// selectively disclosable claims
+func WithDecoyDigests(n int) SignOption {
+	return &signOption{option.New(identDecoys{}, n)}
+}
+
+// WithDigestAlgorithm specifies the algorithm used to compute the digests
+// of disclosures. The default is "sha-256"
+func WithDigestAlgorithm(alg string) SignOption {
+	return &signOption{option.New(identDigestAlgorithm{}, alg)}
+}
+
+// WithHolderKey specifies the public key of the holder, which is embedded
+// in the "cnf" claim so that the holder can create key binding JWTs
+func WithHolderKey(key jwk.Key) SignOption {
+	return &signOption{option.New(identHolderKey{}, key)}
+}
+
+// WithHeaders specifies extra headers for the issuer-signed JWT
+func WithHeaders(hdrs jws.Headers) SignOption {
+	return &signOption{option.New(identHeaders{}, hdrs)}
+}
+
+// WithVerify specifies the algorithm and the key used to verify
+// the issuer-signed JWT
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) VerifyOption {
+	return &verifyOption{option.New(identVerify{}, &verifyParams{alg: alg, key: key})}
+}
+
+// WithKeySet specifies the key set of the issuer, from which the key to
+// verify the issuer-signed JWT is chosen. See `jwt.WithKeySet()` for details
+func WithKeySet(set jwk.Set) VerifyOption {
+	return &verifyOption{option.New(identKeySet{}, set)}
+}
+
+// WithAllowedAlgorithms specifies the signature algorithms that are
+// accepted for both the issuer-signed JWT and the key binding JWT.
+// By default any algorithm except for "none" is accepted.
+func WithAllowedAlgorithms(algs ...jwa.SignatureAlgorithm) VerifyOption {
+	return &verifyOption{option.New(identAllowedAlgorithms{}, algs)}
+}
+
+// WithKeyBinding specifies that the presentation must contain a key binding
+// JWT signed by the holder key found in the "cnf" claim, and that its "aud"
+// and "nonce" claims must match the given values.
+func WithKeyBinding(audience, nonce string) VerifyOption {
+	return &verifyOption{option.New(identKeyBinding{}, &keyBindingParams{audience: audience, nonce: nonce})}
+}
+
+// WithKeyBindingMaxAge specifies how old the key binding JWT may be, as
+// indicated by its "iat" claim. The current time is taken from the
+// `jwt.WithClock()` option, and `jwt.WithAcceptableSkew()` is honored if
+// they are passed via `sdjwt.WithParseOptions()`. The default is
+// `sdjwt.DefaultKeyBindingMaxAge`. A value of 0 or less disables the check,
+// which allows a captured key binding JWT to be replayed for as long as the
+// verifier accepts the same nonce.
+func WithKeyBindingMaxAge(d time.Duration) VerifyOption {
+	return &verifyOption{option.New(identKeyBindingMaxAge{}, d)}
+}
+
+// WithParseOptions specifies extra options that are used when the
+// resulting token is parsed and validated, for example `jwt.WithClock()`
+// or `jwt.WithIssuer()`
+func WithParseOptions(options ...jwt.ParseOption) VerifyOption {
+	return &verifyOption{option.New(identParseOptions{}, options)}
+}
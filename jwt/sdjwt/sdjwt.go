@@ -0,0 +1,642 @@
+// Package sdjwt implements Selective Disclosure for JWTs (SD-JWT), as
+// described in https://datatracker.ietf.org/doc/draft-ietf-oauth-selective-disclosure-jwt/
+//
+// An issuer uses `sdjwt.Sign()` to create an SD-JWT in which some of the
+// claims are replaced by digests of their disclosures. The holder may then
+// choose which disclosures to present using `(*SDJWT).Present()` or
+// `(*SDJWT).Select()`, and optionally prove possession of its key using
+// `(*SDJWT).Bind()`. Finally the verifier uses `sdjwt.Verify()` to verify
+// the presentation and to obtain a token containing the disclosed claims.
+package sdjwt
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/profile"
+	"github.com/pkg/errors"
+)
+
+const (
+	sdKey       = `_sd`
+	sdAlgKey    = `_sd_alg`
+	ellipsisKey = `...`
+	separator   = `~`
+
+	// ConfirmationKey is the name of the claim that holds the
+	// public key of the holder
+	ConfirmationKey = `cnf`
+
+	// KeyBindingType is the value of the "typ" header of key binding JWTs
+	KeyBindingType = `kb+jwt`
+
+	NonceKey  = `nonce`
+	SDHashKey = `sd_hash`
+
+	// DefaultKeyBindingMaxAge is how old a key binding JWT may be, unless
+	// specified otherwise using `sdjwt.WithKeyBindingMaxAge()`
+	DefaultKeyBindingMaxAge = 5 * time.Minute
+)
+
+// SDJWT represents an SD-JWT, which consists of an issuer-signed JWT,
+// zero or more disclosures, and an optional key binding JWT.
+type SDJWT struct {
+	jwt         []byte
+	disclosures []*Disclosure
+	keyBinding  []byte
+}
+
+// Sign creates an SD-JWT from the claims in `t`, signed using the given
+// algorithm and key. Claims specified via `sdjwt.WithSelectiveDisclosure()`
+// and `sdjwt.WithSelectiveArrayElements()` are replaced by digests, and the
+// corresponding disclosures are attached to the returned SD-JWT.
+func Sign(t jwt.Token, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) (*SDJWT, error) {
+	if alg == jwa.NoSignature {
+		return nil, errors.New(`SD-JWTs must be signed: "none" algorithm is not allowed`)
+	}
+
+	var selective, arrayElements []string
+	var decoys int
+	var holderKey jwk.Key
+	var hdrs jws.Headers
+	digestAlg := SHA256
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identSelective{}:
+			selective = append(selective, option.Value().([]string)...)
+		case identArrayElements{}:
+			arrayElements = append(arrayElements, option.Value().([]string)...)
+		case identDecoys{}:
+			decoys = option.Value().(int)
+		case identDigestAlgorithm{}:
+			digestAlg = option.Value().(string)
+		case identHolderKey{}:
+			holderKey = option.Value().(jwk.Key)
+		case identHeaders{}:
+			hdrs = option.Value().(jws.Headers)
+		}
+	}
+
+	if _, err := hashFunc(digestAlg); err != nil {
+		return nil, err
+	}
+
+	// Round-trip through JSON, so that the claims are represented
+	// exactly the way they would be in a regular JWT
+	buf, err := json.Marshal(t)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal token`)
+	}
+	claims, err := decodeObject(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode token`)
+	}
+
+	for _, name := range []string{sdKey, sdAlgKey} {
+		if _, ok := claims[name]; ok {
+			return nil, errors.Errorf(`token must not contain the reserved claim %q`, name)
+		}
+	}
+
+	var sd SDJWT
+	var digests []string
+	for _, name := range selective {
+		v, ok := claims[name]
+		if !ok {
+			return nil, errors.Errorf(`claim %q was not found`, name)
+		}
+		d, err := NewDisclosure(name, v)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create disclosure for %q`, name)
+		}
+		dgst, err := d.Digest(digestAlg)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to compute digest for %q`, name)
+		}
+		delete(claims, name)
+		digests = append(digests, dgst)
+		sd.disclosures = append(sd.disclosures, d)
+	}
+
+	for _, name := range arrayElements {
+		v, ok := claims[name]
+		if !ok {
+			return nil, errors.Errorf(`claim %q was not found`, name)
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, errors.Errorf(`claim %q is not an array (%T)`, name, v)
+		}
+
+		replaced := make([]interface{}, len(list))
+		for i, elem := range list {
+			d, err := NewArrayElementDisclosure(elem)
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to create disclosure for element %d of %q`, i, name)
+			}
+			dgst, err := d.Digest(digestAlg)
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to compute digest for element %d of %q`, i, name)
+			}
+			replaced[i] = map[string]interface{}{ellipsisKey: dgst}
+			sd.disclosures = append(sd.disclosures, d)
+		}
+		claims[name] = replaced
+	}
+
+	for i := 0; i < decoys; i++ {
+		salt, err := newSalt()
+		if err != nil {
+			return nil, err
+		}
+		dgst, err := digest(digestAlg, salt)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to compute decoy digest`)
+		}
+		digests = append(digests, dgst)
+	}
+
+	if len(digests) > 0 {
+		// Sort the digests, so that their order does not reveal
+		// the original order of the claims
+		sort.Strings(digests)
+		claims[sdKey] = digests
+	}
+	claims[sdAlgKey] = digestAlg
+
+	if holderKey != nil {
+		pubkey, err := jwk.PublicKeyOf(holderKey)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to get public key of holder`)
+		}
+		claims[ConfirmationKey] = map[string]interface{}{`jwk`: pubkey}
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal claims`)
+	}
+
+	var signOptions []jws.SignOption
+	if hdrs != nil {
+		signOptions = append(signOptions, jws.WithHeaders(hdrs))
+	}
+	signed, err := jws.Sign(payload, alg, key, signOptions...)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign SD-JWT`)
+	}
+	sd.jwt = signed
+	return &sd, nil
+}
+
+// Parse parses the combined serialization of an SD-JWT. The signatures
+// and digests are NOT verified: use `sdjwt.Verify()` for that.
+func Parse(data []byte) (*SDJWT, error) {
+	parts := strings.Split(string(bytes.TrimSpace(data)), separator)
+	if len(parts) < 2 {
+		return nil, errors.New(`invalid SD-JWT: no separator found`)
+	}
+
+	var sd SDJWT
+	sd.jwt = []byte(parts[0])
+	if len(sd.jwt) == 0 {
+		return nil, errors.New(`invalid SD-JWT: empty issuer-signed JWT`)
+	}
+	if kb := parts[len(parts)-1]; kb != "" {
+		sd.keyBinding = []byte(kb)
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		d, err := ParseDisclosure(part)
+		if err != nil {
+			return nil, errors.Wrap(err, `invalid SD-JWT`)
+		}
+		sd.disclosures = append(sd.disclosures, d)
+	}
+	return &sd, nil
+}
+
+// IssuerJWT returns the issuer-signed JWT
+func (sd *SDJWT) IssuerJWT() []byte {
+	return sd.jwt
+}
+
+// Disclosures returns the disclosures
+func (sd *SDJWT) Disclosures() []*Disclosure {
+	return sd.disclosures
+}
+
+// KeyBinding returns the key binding JWT, or nil if there is none
+func (sd *SDJWT) KeyBinding() []byte {
+	return sd.keyBinding
+}
+
+// Serialize returns the combined serialization of the SD-JWT, which is
+// the issuer-signed JWT followed by the disclosures and the key binding
+// JWT (if any), separated by "~"
+func (sd *SDJWT) Serialize() []byte {
+	var buf bytes.Buffer
+	buf.Write(sd.presentation())
+	buf.Write(sd.keyBinding)
+	return buf.Bytes()
+}
+
+func (sd *SDJWT) presentation() []byte {
+	var buf bytes.Buffer
+	buf.Write(sd.jwt)
+	buf.WriteString(separator)
+	for _, d := range sd.disclosures {
+		buf.WriteString(d.String())
+		buf.WriteString(separator)
+	}
+	return buf.Bytes()
+}
+
+// Select creates a new SD-JWT that only contains the disclosures for
+// which `fn` returns true. The key binding JWT is not carried over.
+func (sd *SDJWT) Select(fn func(*Disclosure) bool) *SDJWT {
+	selected := SDJWT{jwt: sd.jwt}
+	for _, d := range sd.disclosures {
+		if fn(d) {
+			selected.disclosures = append(selected.disclosures, d)
+		}
+	}
+	return &selected
+}
+
+// Present creates a new SD-JWT that only contains the disclosures for
+// the claims with the given names. Array element disclosures are not
+// included: use `(*SDJWT).Select()` for finer control.
+func (sd *SDJWT) Present(names ...string) *SDJWT {
+	m := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		m[name] = struct{}{}
+	}
+	return sd.Select(func(d *Disclosure) bool {
+		if d.IsArrayElement() {
+			return false
+		}
+		_, ok := m[d.Name()]
+		return ok
+	})
+}
+
+// Bind creates a new SD-JWT with a key binding JWT attached, which proves
+// that the holder possesses the key specified in the "cnf" claim.
+// `audience` and `nonce` are provided by the verifier.
+func (sd *SDJWT) Bind(alg jwa.SignatureAlgorithm, key interface{}, audience, nonce string) (*SDJWT, error) {
+	if alg == jwa.NoSignature {
+		return nil, errors.New(`key binding JWTs must be signed: "none" algorithm is not allowed`)
+	}
+
+	m, err := jws.Parse(sd.jwt)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse issuer-signed JWT`)
+	}
+	claims, err := decodeObject(m.Payload())
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode issuer-signed JWT`)
+	}
+	digestAlg, err := digestAlgorithm(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := SDJWT{jwt: sd.jwt, disclosures: sd.disclosures}
+	sdHash, err := digest(digestAlg, string(bound.presentation()))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to compute sd_hash`)
+	}
+
+	t := jwt.New()
+	for _, pair := range []struct {
+		Name  string
+		Value interface{}
+	}{
+		{jwt.IssuedAtKey, time.Now()},
+		{jwt.AudienceKey, audience},
+		{NonceKey, nonce},
+		{SDHashKey, sdHash},
+	} {
+		if err := t.Set(pair.Name, pair.Value); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, pair.Name)
+		}
+	}
+
+	hdrs := jws.NewHeaders()
+	if err := hdrs.Set(jws.TypeKey, KeyBindingType); err != nil {
+		return nil, errors.Wrap(err, `failed to set "typ"`)
+	}
+	signed, err := jwt.Sign(t, alg, key, jwt.WithHeaders(hdrs))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to sign key binding JWT`)
+	}
+	bound.keyBinding = signed
+	return &bound, nil
+}
+
+// Verify verifies an SD-JWT presentation, and returns a token containing
+// the claims of the issuer-signed JWT along with the disclosed claims.
+// The resulting token is always validated.
+//
+// One of `sdjwt.WithVerify()` or `sdjwt.WithKeySet()` must be specified.
+// Every disclosure must be referenced by a digest in the issuer-signed JWT.
+// If `sdjwt.WithKeyBinding()` is specified, the key binding JWT is required
+// and verified, and must not be older than `sdjwt.WithKeyBindingMaxAge()`;
+// otherwise it is ignored.
+func Verify(data []byte, options ...VerifyOption) (jwt.Token, error) {
+	var cfg profile.Config
+	var kb *keyBindingParams
+	var parseOptions []jwt.ParseOption
+	kbMaxAge := DefaultKeyBindingMaxAge
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identVerify{}:
+			p := option.Value().(*verifyParams)
+			cfg.ParseOptions = append(cfg.ParseOptions, jwt.WithVerify(p.alg, p.key))
+		case identKeySet{}:
+			cfg.ParseOptions = append(cfg.ParseOptions, jwt.WithKeySet(option.Value().(jwk.Set)))
+		case identAllowedAlgorithms{}:
+			cfg.AllowedAlgorithms = option.Value().([]jwa.SignatureAlgorithm)
+		case identKeyBinding{}:
+			kb = option.Value().(*keyBindingParams)
+		case identKeyBindingMaxAge{}:
+			kbMaxAge = option.Value().(time.Duration)
+		case identParseOptions{}:
+			parseOptions = append(parseOptions, option.Value().([]jwt.ParseOption)...)
+		}
+	}
+
+	sd, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := profile.Parse(sd.jwt, &cfg); err != nil {
+		return nil, errors.Wrap(err, `failed to verify issuer-signed JWT`)
+	}
+
+	m, err := jws.Parse(sd.jwt)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse issuer-signed JWT`)
+	}
+	claims, err := decodeObject(m.Payload())
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode issuer-signed JWT`)
+	}
+
+	digestAlg, err := digestAlgorithm(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if kb != nil {
+		kbOptions := timeValidateOptions(parseOptions)
+		if kbMaxAge > 0 {
+			kbOptions = append(kbOptions, jwt.WithMaxDelta(kbMaxAge, "", jwt.IssuedAtKey))
+		}
+		if err := verifyKeyBinding(sd, claims, digestAlg, kb, cfg.AllowedAlgorithms, kbOptions); err != nil {
+			return nil, errors.Wrap(err, `failed to verify key binding JWT`)
+		}
+	}
+
+	p := processor{
+		alg:         digestAlg,
+		disclosures: make(map[string]*Disclosure),
+		used:        make(map[string]struct{}),
+	}
+	for _, d := range sd.disclosures {
+		dgst, err := d.Digest(digestAlg)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to compute digest`)
+		}
+		if _, ok := p.disclosures[dgst]; ok {
+			return nil, errors.New(`duplicate disclosure found`)
+		}
+		p.disclosures[dgst] = d
+	}
+
+	if err := p.object(claims); err != nil {
+		return nil, err
+	}
+	if len(p.used) != len(p.disclosures) {
+		return nil, errors.New(`disclosure is not referenced by the issuer-signed JWT`)
+	}
+	delete(claims, sdAlgKey)
+
+	buf, err := json.Marshal(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal disclosed claims`)
+	}
+
+	parseOptions = append(parseOptions, jwt.WithValidate(true))
+	t, err := jwt.ParseInsecure(buf, parseOptions...)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse disclosed claims`)
+	}
+	return t, nil
+}
+
+// timeValidateOptions returns the options in `options` that control how
+// time claims are validated, so that the key binding JWT is validated
+// against the same clock as the issuer-signed JWT
+func timeValidateOptions(options []jwt.ParseOption) []jwt.ParseOption {
+	clock := jwt.WithClock(nil).Ident()
+	skew := jwt.WithAcceptableSkew(0).Ident()
+
+	var list []jwt.ParseOption
+	for _, option := range options {
+		if ident := option.Ident(); ident == clock || ident == skew {
+			list = append(list, option)
+		}
+	}
+	return list
+}
+
+func verifyKeyBinding(sd *SDJWT, claims map[string]interface{}, digestAlg string, kb *keyBindingParams, algs []jwa.SignatureAlgorithm, options []jwt.ParseOption) error {
+	if len(sd.keyBinding) == 0 {
+		return errors.New(`key binding JWT is missing`)
+	}
+
+	cnf, ok := claims[ConfirmationKey].(map[string]interface{})
+	if !ok {
+		return errors.Errorf(`%q claim is missing`, ConfirmationKey)
+	}
+	rawkey, ok := cnf[`jwk`]
+	if !ok {
+		return errors.Errorf(`%q claim does not contain a "jwk"`, ConfirmationKey)
+	}
+	buf, err := json.Marshal(rawkey)
+	if err != nil {
+		return errors.Wrap(err, `failed to marshal holder key`)
+	}
+	key, err := jwk.ParseKey(buf)
+	if err != nil {
+		return errors.Wrap(err, `failed to parse holder key`)
+	}
+
+	m, err := jws.Parse(sd.keyBinding)
+	if err != nil {
+		return errors.Wrap(err, `failed to parse key binding JWT`)
+	}
+	if len(m.Signatures()) != 1 {
+		return errors.New(`key binding JWT must have exactly one signature`)
+	}
+	alg := m.Signatures()[0].ProtectedHeaders().Algorithm()
+
+	t, _, err := profile.Parse(sd.keyBinding, &profile.Config{
+		Type:              KeyBindingType,
+		AllowedAlgorithms: algs,
+		ParseOptions: append([]jwt.ParseOption{
+			jwt.WithVerify(alg, key),
+			jwt.WithValidate(true),
+			jwt.WithRequiredClaim(jwt.IssuedAtKey),
+			jwt.WithAudience(kb.audience),
+			jwt.WithClaimValue(NonceKey, kb.nonce),
+		}, options...),
+	})
+	if err != nil {
+		return err
+	}
+
+	expected, err := digest(digestAlg, string(sd.presentation()))
+	if err != nil {
+		return errors.Wrap(err, `failed to compute sd_hash`)
+	}
+	if v, _ := t.Get(SDHashKey); v != expected {
+		return errors.Errorf(`%q does not match`, SDHashKey)
+	}
+	return nil
+}
+
+func decodeObject(buf []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func digestAlgorithm(claims map[string]interface{}) (string, error) {
+	v, ok := claims[sdAlgKey]
+	if !ok {
+		return SHA256, nil
+	}
+	alg, ok := v.(string)
+	if !ok {
+		return "", errors.Errorf(`invalid %q claim (%T)`, sdAlgKey, v)
+	}
+	if _, err := hashFunc(alg); err != nil {
+		return "", err
+	}
+	return alg, nil
+}
+
+// processor replaces digests with the values of the matching disclosures
+type processor struct {
+	alg         string
+	disclosures map[string]*Disclosure
+	used        map[string]struct{}
+}
+
+func (p *processor) lookup(dgst interface{}) (*Disclosure, bool, error) {
+	s, ok := dgst.(string)
+	if !ok {
+		return nil, false, errors.Errorf(`invalid digest (%T)`, dgst)
+	}
+	d, ok := p.disclosures[s]
+	if !ok {
+		// decoy digest, or the holder chose not to disclose it
+		return nil, false, nil
+	}
+	if _, ok := p.used[s]; ok {
+		return nil, false, errors.New(`digest is referenced more than once`)
+	}
+	p.used[s] = struct{}{}
+	return d, true, nil
+}
+
+func (p *processor) object(m map[string]interface{}) error {
+	if v, ok := m[sdKey]; ok {
+		digests, ok := v.([]interface{})
+		if !ok {
+			return errors.Errorf(`invalid %q claim (%T)`, sdKey, v)
+		}
+		delete(m, sdKey)
+
+		for _, dgst := range digests {
+			d, ok, err := p.lookup(dgst)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if d.IsArrayElement() {
+				return errors.New(`array element disclosure is referenced from an object`)
+			}
+			if _, ok := m[d.Name()]; ok {
+				return errors.Errorf(`disclosed claim %q already exists`, d.Name())
+			}
+			m[d.Name()] = d.Value()
+		}
+	}
+
+	for name, v := range m {
+		processed, err := p.value(v)
+		if err != nil {
+			return errors.Wrapf(err, `failed to process %q`, name)
+		}
+		m[name] = processed
+	}
+	return nil
+}
+
+func (p *processor) value(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if err := p.object(v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			if obj, ok := elem.(map[string]interface{}); ok && len(obj) == 1 {
+				if dgst, ok := obj[ellipsisKey]; ok {
+					d, ok, err := p.lookup(dgst)
+					if err != nil {
+						return nil, err
+					}
+					if !ok {
+						continue
+					}
+					if !d.IsArrayElement() {
+						return nil, errors.New(`object property disclosure is referenced from an array`)
+					}
+					elem = d.Value()
+				}
+			}
+
+			processed, err := p.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, processed)
+		}
+		return list, nil
+	default:
+		return v, nil
+	}
+}
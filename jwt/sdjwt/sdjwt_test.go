@@ -0,0 +1,241 @@
+package sdjwt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/sdjwt"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	verifierID = `https://verifier.example.org`
+	nonce      = `1234567890`
+)
+
+func TestDisclosure(t *testing.T) {
+	t.Parallel()
+	t.Run("Digest", func(t *testing.T) {
+		t.Parallel()
+		// Example taken from the specification
+		d, err := sdjwt.ParseDisclosure(`WyI2cU1RdlJMNWhhaiIsICJmYW1pbHlfbmFtZSIsICJNw7ZiaXVzIl0`)
+		if !assert.NoError(t, err, `sdjwt.ParseDisclosure should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `6qMQvRL5haj`, d.Salt(), `salt should match`) {
+			return
+		}
+		if !assert.Equal(t, `family_name`, d.Name(), `name should match`) {
+			return
+		}
+		if !assert.Equal(t, `Möbius`, d.Value(), `value should match`) {
+			return
+		}
+		dgst, err := d.Digest(sdjwt.SHA256)
+		if !assert.NoError(t, err, `d.Digest should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `uutlBuYeMDyjLLTpf6Jxi7yNkEF35jdyWMn9U7b_RYY`, dgst, `digest should match`) {
+			return
+		}
+	})
+	t.Run("Roundtrip", func(t *testing.T) {
+		t.Parallel()
+		d, err := sdjwt.NewArrayElementDisclosure(`DE`)
+		if !assert.NoError(t, err, `sdjwt.NewArrayElementDisclosure should succeed`) {
+			return
+		}
+		parsed, err := sdjwt.ParseDisclosure(d.String())
+		if !assert.NoError(t, err, `sdjwt.ParseDisclosure should succeed`) {
+			return
+		}
+		if !assert.True(t, parsed.IsArrayElement(), `disclosure should be an array element`) {
+			return
+		}
+		if !assert.Equal(t, d.Salt(), parsed.Salt(), `salt should match`) {
+			return
+		}
+		if !assert.Equal(t, `DE`, parsed.Value(), `value should match`) {
+			return
+		}
+	})
+	t.Run("Reserved names", func(t *testing.T) {
+		t.Parallel()
+		for _, name := range []string{`_sd`, `...`} {
+			_, err := sdjwt.NewDisclosure(name, `foo`)
+			if !assert.Error(t, err, `sdjwt.NewDisclosure should fail for %q`, name) {
+				return
+			}
+		}
+	})
+}
+
+func TestSDJWT(t *testing.T) {
+	t.Parallel()
+
+	issuerKey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	rawHolderKey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	holderKey, err := jwk.New(rawHolderKey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+
+	tok := jwt.New()
+	tok.Set(jwt.IssuerKey, `https://issuer.example.com`)
+	tok.Set(jwt.SubjectKey, `user_42`)
+	tok.Set(jwt.ExpirationKey, time.Now().Add(time.Hour))
+	tok.Set(`given_name`, `John`)
+	tok.Set(`email`, `johndoe@example.com`)
+	tok.Set(`address`, map[string]interface{}{`country`: `US`, `locality`: `Anytown`})
+	tok.Set(`nationalities`, []interface{}{`US`, `DE`})
+
+	issued, err := sdjwt.Sign(tok, jwa.ES256, issuerKey,
+		sdjwt.WithSelectiveDisclosure(`given_name`, `email`, `address`),
+		sdjwt.WithSelectiveArrayElements(`nationalities`),
+		sdjwt.WithDecoyDigests(3),
+		sdjwt.WithHolderKey(holderKey),
+	)
+	if !assert.NoError(t, err, `sdjwt.Sign should succeed`) {
+		return
+	}
+	if !assert.Len(t, issued.Disclosures(), 5, `there should be 5 disclosures`) {
+		return
+	}
+
+	verifyIssuer := sdjwt.WithVerify(jwa.ES256, &issuerKey.PublicKey)
+
+	t.Run("Parse and Serialize", func(t *testing.T) {
+		t.Parallel()
+		serialized := issued.Serialize()
+		parsed, err := sdjwt.Parse(serialized)
+		if !assert.NoError(t, err, `sdjwt.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, serialized, parsed.Serialize(), `serialized forms should match`) {
+			return
+		}
+		if !assert.Nil(t, parsed.KeyBinding(), `there should be no key binding JWT`) {
+			return
+		}
+	})
+	t.Run("Verify all disclosures", func(t *testing.T) {
+		t.Parallel()
+		verified, err := sdjwt.Verify(issued.Serialize(), verifyIssuer)
+		if !assert.NoError(t, err, `sdjwt.Verify should succeed`) {
+			return
+		}
+		for name, expected := range map[string]interface{}{
+			`given_name`:    `John`,
+			`email`:         `johndoe@example.com`,
+			`address`:       map[string]interface{}{`country`: `US`, `locality`: `Anytown`},
+			`nationalities`: []interface{}{`US`, `DE`},
+		} {
+			v, ok := verified.Get(name)
+			if !assert.True(t, ok, `claim %q should exist`, name) {
+				return
+			}
+			if !assert.Equal(t, expected, v, `claim %q should match`, name) {
+				return
+			}
+		}
+		for _, name := range []string{`_sd`, `_sd_alg`} {
+			if _, ok := verified.Get(name); !assert.False(t, ok, `claim %q should not exist`, name) {
+				return
+			}
+		}
+	})
+	t.Run("Selective presentation with key binding", func(t *testing.T) {
+		t.Parallel()
+		presented := issued.Select(func(d *sdjwt.Disclosure) bool {
+			return d.Name() == `email` || (d.IsArrayElement() && d.Value() == `DE`)
+		})
+		bound, err := presented.Bind(jwa.ES256, rawHolderKey, verifierID, nonce)
+		if !assert.NoError(t, err, `presented.Bind should succeed`) {
+			return
+		}
+
+		verified, err := sdjwt.Verify(bound.Serialize(), verifyIssuer, sdjwt.WithKeyBinding(verifierID, nonce))
+		if !assert.NoError(t, err, `sdjwt.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `user_42`, verified.Subject(), `sub should match`) {
+			return
+		}
+		v, _ := verified.Get(`email`)
+		if !assert.Equal(t, `johndoe@example.com`, v, `email should be disclosed`) {
+			return
+		}
+		v, _ = verified.Get(`nationalities`)
+		if !assert.Equal(t, []interface{}{`DE`}, v, `only the selected nationality should be disclosed`) {
+			return
+		}
+		for _, name := range []string{`given_name`, `address`} {
+			if _, ok := verified.Get(name); !assert.False(t, ok, `claim %q should not be disclosed`, name) {
+				return
+			}
+		}
+
+		_, err = sdjwt.Verify(bound.Serialize(), verifyIssuer, sdjwt.WithKeyBinding(verifierID, `wrong-nonce`))
+		if !assert.Error(t, err, `sdjwt.Verify should fail with wrong nonce`) {
+			return
+		}
+		_, err = sdjwt.Verify(presented.Serialize(), verifyIssuer, sdjwt.WithKeyBinding(verifierID, nonce))
+		if !assert.Error(t, err, `sdjwt.Verify should fail without key binding JWT`) {
+			return
+		}
+
+		// A key binding JWT that was created too long ago must be rejected,
+		// even if the nonce and the audience match
+		stale := sdjwt.WithParseOptions(jwt.WithClock(jwt.ClockFunc(func() time.Time {
+			return time.Now().Add(10 * time.Minute)
+		})))
+		_, err = sdjwt.Verify(bound.Serialize(), verifyIssuer, sdjwt.WithKeyBinding(verifierID, nonce), stale)
+		if !assert.Error(t, err, `sdjwt.Verify should fail with stale key binding JWT`) {
+			return
+		}
+		_, err = sdjwt.Verify(bound.Serialize(), verifyIssuer, sdjwt.WithKeyBinding(verifierID, nonce), stale, sdjwt.WithKeyBindingMaxAge(time.Hour))
+		if !assert.NoError(t, err, `sdjwt.Verify should succeed with longer max age`) {
+			return
+		}
+
+		// Adding a disclosure after the key binding JWT was created
+		// must invalidate the sd_hash
+		tampered, err := sdjwt.Parse(append(issued.Present(`given_name`).Serialize(), bound.KeyBinding()...))
+		if !assert.NoError(t, err, `sdjwt.Parse should succeed`) {
+			return
+		}
+		_, err = sdjwt.Verify(tampered.Serialize(), verifyIssuer, sdjwt.WithKeyBinding(verifierID, nonce))
+		if !assert.Error(t, err, `sdjwt.Verify should fail with mismatching sd_hash`) {
+			return
+		}
+	})
+	t.Run("Unreferenced disclosure", func(t *testing.T) {
+		t.Parallel()
+		other, err := sdjwt.NewDisclosure(`given_name`, `Mallory`)
+		if !assert.NoError(t, err, `sdjwt.NewDisclosure should succeed`) {
+			return
+		}
+		serialized := append(issued.Present(`email`).Serialize(), []byte(other.String()+`~`)...)
+		_, err = sdjwt.Verify(serialized, verifyIssuer)
+		if !assert.Error(t, err, `sdjwt.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Wrong issuer key", func(t *testing.T) {
+		t.Parallel()
+		_, err := sdjwt.Verify(issued.Serialize(), sdjwt.WithVerify(jwa.ES256, &rawHolderKey.PublicKey))
+		if !assert.Error(t, err, `sdjwt.Verify should fail`) {
+			return
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package jwt
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/jwa"
@@ -11,13 +13,19 @@ import (
 )
 
 type SerializeCtx interface {
+	Context() context.Context
 	Step() int
 	Nested() bool
 }
 
 type serializeCtx struct {
-	step   int
-	nested bool
+	context context.Context
+	step    int
+	nested  bool
+}
+
+func (ctx *serializeCtx) Context() context.Context {
+	return ctx.context
 }
 
 func (ctx *serializeCtx) Step() int {
@@ -132,11 +140,16 @@ func (s *jwsSerializer) Serialize(ctx SerializeCtx, v interface{}) (interface{},
 	}
 
 	var hdrs jws.Headers
+	var signOptions []jws.SignOption
 	//nolint:forcetypeassert
 	for _, option := range s.options {
 		switch option.Ident() {
 		case identJwsHeaders{}:
 			hdrs = option.Value().(jws.Headers)
+		case identKeyProviderForSigning{}:
+			signOptions = append(signOptions, jws.WithKeyProviderForSigning(option.Value().(jws.SigningKeyProvider)))
+		case identSigningPolicy{}:
+			signOptions = append(signOptions, jws.WithSigningPolicy(option.Value().(jws.SigningPolicy)))
 		}
 	}
 
@@ -147,7 +160,8 @@ func (s *jwsSerializer) Serialize(ctx SerializeCtx, v interface{}) (interface{},
 	if err := setTypeOrCty(ctx, hdrs); err != nil {
 		return nil, err // this is already wrapped
 	}
-	return jws.Sign(payload, s.alg, s.key, jws.WithHeaders(hdrs))
+	signOptions = append(signOptions, jws.WithHeaders(hdrs))
+	return jws.SignContext(ctx.Context(), payload, s.alg, s.key, signOptions...)
 }
 
 func (s *Serializer) Sign(alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) *Serializer {
@@ -173,11 +187,14 @@ func (s *jweSerializer) Serialize(ctx SerializeCtx, v interface{}) (interface{},
 	}
 
 	var hdrs jwe.Headers
+	var encryptedOnly bool
 	//nolint:forcetypeassert
 	for _, option := range s.options {
 		switch option.Ident() {
 		case identJweHeaders{}:
 			hdrs = option.Value().(jwe.Headers)
+		case identEncryptedOnly{}:
+			encryptedOnly = option.Value().(IntegrityModel) == IntegrityAEADOnly
 		}
 	}
 
@@ -188,7 +205,18 @@ func (s *jweSerializer) Serialize(ctx SerializeCtx, v interface{}) (interface{},
 	if err := setTypeOrCty(ctx, hdrs); err != nil {
 		return nil, err // this is already wrapped
 	}
-	return jwe.Encrypt(payload, s.keyalg, s.key, s.contentalg, s.compressalg, jwe.WithProtectedHeaders(hdrs))
+
+	// The claims are encrypted as is, so the token must be acceptable
+	// to jwt.Parse() with jwt.WithEncryptedOnly()
+	if encryptedOnly && ctx.Step() == 1 {
+		if err := checkEncryptedOnly(s.keyalg); err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(hdrs.ContentType(), `JWT`) {
+			return nil, errors.New(`"cty" must not be "JWT" for tokens that are not signed`)
+		}
+	}
+	return jwe.EncryptContext(ctx.Context(), payload, s.keyalg, s.key, s.contentalg, s.compressalg, jwe.WithProtectedHeaders(hdrs))
 }
 
 func (s *Serializer) Encrypt(keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...EncryptOption) *Serializer {
@@ -201,19 +229,45 @@ func (s *Serializer) Encrypt(keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 	})
 }
 
+// Serialize serializes the token, applying each of the registered steps
 func (s *Serializer) Serialize(t Token) ([]byte, error) {
+	return s.SerializeContext(context.Background(), t)
+}
+
+// SerializeContext is the same as Serialize, but accepts a context.Context,
+// which is made available to each step via `SerializeCtx.Context()`
+func (s *Serializer) SerializeContext(ctx context.Context, t Token) ([]byte, error) {
 	steps := make([]SerializeStep, len(s.steps)+1)
 	steps[0] = jsonSerializer{}
 	for i, step := range s.steps {
 		steps[i+1] = step
 	}
 
-	var ctx serializeCtx
-	ctx.nested = len(s.steps) > 1
+	// MintOptions passed to Sign() are applied to a copy of the token,
+	// before it is marshaled into JSON
+	var mintOptions []Option
+	for _, step := range s.steps {
+		if js, ok := step.(*jwsSerializer); ok {
+			for _, option := range js.options {
+				mintOptions = append(mintOptions, option)
+			}
+		}
+	}
+	if m := newMinter(mintOptions); m != nil {
+		minted, err := m.mint(t)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to mint token`)
+		}
+		t = minted
+	}
+
+	var sctx serializeCtx
+	sctx.context = ctx
+	sctx.nested = len(s.steps) > 1
 	var payload interface{} = t
 	for i, step := range steps {
-		ctx.step = i
-		v, err := step.Serialize(&ctx, payload)
+		sctx.step = i
+		v, err := step.Serialize(&sctx, payload)
 		if err != nil {
 			return nil, errors.Wrapf(err, `failed to serialize token at step #%d`, i+1)
 		}
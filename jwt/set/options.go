@@ -0,0 +1,121 @@
+package set
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// SignOption describes an Option that can be passed to `set.Sign()`
+type SignOption interface {
+	Option
+	signOption()
+}
+
+type signOption struct {
+	Option
+}
+
+func (*signOption) signOption() {}
+
+// ParseOption describes an Option that can be passed to `set.Parse()`
+type ParseOption interface {
+	Option
+	parseOption()
+}
+
+type parseOption struct {
+	Option
+}
+
+func (*parseOption) parseOption() {}
+
+type identAllowedAlgorithms struct{}
+type identAudience struct{}
+type identClock struct{}
+type identDecrypt struct{}
+type identHeaders struct{}
+type identIssuer struct{}
+type identKeySet struct{}
+type identParseOptions struct{}
+type identTypeOptional struct{}
+type identVerify struct{}
+
+type verifyParams struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+type decryptParams struct {
+	alg jwa.KeyEncryptionAlgorithm
+	key interface{}
+}
+
+// WithHeaders specifies extra header values to be included in the
+// protected header of the JWS message. The "typ" header is always
+// overwritten with `secevent+jwt`
+func WithHeaders(hdrs jws.Headers) SignOption {
+	return &signOption{option.New(identHeaders{}, hdrs)}
+}
+
+// WithClock specifies the clock used to fill in the "iat" claim,
+// if it does not exist
+func WithClock(c jwt.Clock) SignOption {
+	return &signOption{option.New(identClock{}, c)}
+}
+
+// WithVerify specifies the algorithm and the key used to verify
+// the Security Event Token
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identVerify{}, &verifyParams{alg: alg, key: key})}
+}
+
+// WithKeySet specifies the key set from which the key to verify the
+// Security Event Token is chosen. See `jwt.WithKeySet()` for details
+func WithKeySet(set jwk.Set) ParseOption {
+	return &parseOption{option.New(identKeySet{}, set)}
+}
+
+// WithDecrypt specifies the algorithm and the key used to decrypt
+// the Security Event Token. If the token is encrypted and this
+// option is not specified, `set.Parse()` returns an error
+func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
+	return &parseOption{option.New(identDecrypt{}, &decryptParams{alg: alg, key: key})}
+}
+
+// WithAllowedAlgorithms specifies the signature algorithms that are
+// accepted. By default any algorithm except for "none" is accepted
+func WithAllowedAlgorithms(algs ...jwa.SignatureAlgorithm) ParseOption {
+	return &parseOption{option.New(identAllowedAlgorithms{}, algs)}
+}
+
+// WithIssuer specifies the expected value in the "iss" claim, which
+// should be the issuer identifier of the transmitter
+func WithIssuer(s string) ParseOption {
+	return &parseOption{option.New(identIssuer{}, s)}
+}
+
+// WithAudience specifies the expected value in the "aud" claim, which
+// should identify the receiver
+func WithAudience(s string) ParseOption {
+	return &parseOption{option.New(identAudience{}, s)}
+}
+
+// WithTypeOptional specifies whether Security Event Tokens without a
+// "typ" header are accepted. RFC 8417 only recommends explicit typing,
+// so some transmitters may omit it. A "typ" header with any other value
+// than `secevent+jwt` is always rejected
+func WithTypeOptional(b bool) ParseOption {
+	return &parseOption{option.New(identTypeOptional{}, b)}
+}
+
+// WithParseOptions specifies extra options that are passed to
+// `jwt.Parse()`, for example `jwt.WithClock()`, `jwt.WithAcceptableSkew()`
+// or `jwt.WithReplayProtection()`
+func WithParseOptions(options ...jwt.ParseOption) ParseOption {
+	return &parseOption{option.New(identParseOptions{}, options)}
+}
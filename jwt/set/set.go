@@ -0,0 +1,442 @@
+// Package set implements helpers to build and validate Security Event
+// Tokens (SETs), as described in https://tools.ietf.org/html/rfc8417
+//
+// Transmitters use `set.NewBuilder()` and `set.Sign()` to create SETs,
+// and receivers use `set.Parse()` to verify and validate the SETs they
+// receive. Events defined by the OpenID RISC and CAEP profiles are
+// additionally checked against the requirements of those profiles.
+package set
+
+import (
+	"crypto/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/profile"
+	"github.com/lestrrat-go/jwx/jwt/internal/types"
+	"github.com/pkg/errors"
+)
+
+// MediaType is the value used in the "typ" header of SETs
+const MediaType = `secevent+jwt`
+
+const (
+	EventsKey        = "events"
+	SubjectIDKey     = "sub_id"
+	TimeOfEventKey   = "toe"
+	TransactionIDKey = "txn"
+)
+
+// Prefixes of the event type identifiers defined by the OpenID RISC
+// and CAEP profiles
+const (
+	RISCEventPrefix = `https://schemas.openid.net/secevent/risc/event-type/`
+	CAEPEventPrefix = `https://schemas.openid.net/secevent/caep/event-type/`
+)
+
+// Builder is a convenience wrapper to construct SETs. Errors are
+// reported when `Build()` is called.
+type Builder struct {
+	claims map[string]interface{}
+	events map[string]interface{}
+}
+
+// NewBuilder creates a new Builder
+func NewBuilder() *Builder {
+	return &Builder{
+		claims: make(map[string]interface{}),
+		events: make(map[string]interface{}),
+	}
+}
+
+// Claim sets the value of an arbitrary claim
+func (b *Builder) Claim(name string, v interface{}) *Builder {
+	b.claims[name] = v
+	return b
+}
+
+// Issuer sets the "iss" claim
+func (b *Builder) Issuer(s string) *Builder {
+	return b.Claim(jwt.IssuerKey, s)
+}
+
+// Audience sets the "aud" claim
+func (b *Builder) Audience(aud ...string) *Builder {
+	return b.Claim(jwt.AudienceKey, aud)
+}
+
+// Subject sets the "sub" claim. SETs for RISC and CAEP events must use
+// `SubjectID()`, or a "subject" member in the event payload instead
+func (b *Builder) Subject(s string) *Builder {
+	return b.Claim(jwt.SubjectKey, s)
+}
+
+// SubjectID sets the "sub_id" claim, which identifies the subject of
+// the events using a subject identifier (RFC 9493), such as
+// `{"format": "email", "email": "user@example.com"}`
+func (b *Builder) SubjectID(v map[string]interface{}) *Builder {
+	return b.Claim(SubjectIDKey, v)
+}
+
+// JwtID sets the "jti" claim. If it is not set, `set.Sign()` generates
+// a random value
+func (b *Builder) JwtID(s string) *Builder {
+	return b.Claim(jwt.JwtIDKey, s)
+}
+
+// IssuedAt sets the "iat" claim. If it is not set, `set.Sign()` uses
+// the current time
+func (b *Builder) IssuedAt(t time.Time) *Builder {
+	return b.Claim(jwt.IssuedAtKey, t)
+}
+
+// TimeOfEvent sets the "toe" claim, which is the time at which the
+// events took place
+func (b *Builder) TimeOfEvent(t time.Time) *Builder {
+	return b.Claim(TimeOfEventKey, t.Unix())
+}
+
+// TransactionID sets the "txn" claim, which is used to correlate SETs
+// that were issued as the result of a single transaction
+func (b *Builder) TransactionID(s string) *Builder {
+	return b.Claim(TransactionIDKey, s)
+}
+
+// Event adds an event to the "events" claim. `typ` is the URI that
+// identifies the event type, and `payload` contains the event specific
+// members. A nil payload is encoded as an empty object.
+func (b *Builder) Event(typ string, payload map[string]interface{}) *Builder {
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+	b.events[typ] = payload
+	return b
+}
+
+// Build creates a new jwt.Token with the claims that were specified.
+// The claims are checked using `set.Validate()`, except for "iat" and
+// "jti", which `set.Sign()` fills in if they are missing.
+func (b *Builder) Build() (jwt.Token, error) {
+	t := jwt.New()
+
+	names := make([]string, 0, len(b.claims))
+	for name := range b.claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := t.Set(name, b.claims[name]); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, name)
+		}
+	}
+
+	if len(b.events) > 0 {
+		events := make(map[string]interface{}, len(b.events))
+		for typ, payload := range b.events {
+			events[typ] = payload
+		}
+		if err := t.Set(EventsKey, events); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, EventsKey)
+		}
+	}
+
+	if err := checkClaims(t, false); err != nil {
+		return nil, errors.Wrap(err, `failed to build security event token`)
+	}
+	return t, nil
+}
+
+// Sign serializes the token as a signed SET. The "typ" header is set
+// to `secevent+jwt`.
+//
+// The token must contain the "iss" and "events" claims. The "iat" and
+// "jti" claims are filled in if they do not exist. The token passed in
+// is not modified.
+func Sign(t jwt.Token, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
+	if alg == jwa.NoSignature {
+		return nil, errors.New(`security event tokens must be signed: "none" algorithm is not allowed`)
+	}
+
+	var hdrs jws.Headers
+	var clock jwt.Clock = jwt.ClockFunc(time.Now)
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identHeaders{}:
+			hdrs = option.Value().(jws.Headers)
+		case identClock{}:
+			clock = option.Value().(jwt.Clock)
+		}
+	}
+
+	t, err := t.Clone()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to clone token`)
+	}
+
+	if _, ok := t.Get(jwt.IssuedAtKey); !ok {
+		if err := t.Set(jwt.IssuedAtKey, clock.Now()); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, jwt.IssuedAtKey)
+		}
+	}
+	if _, ok := t.Get(jwt.JwtIDKey); !ok {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, errors.Wrap(err, `failed to generate jti`)
+		}
+		if err := t.Set(jwt.JwtIDKey, base64.EncodeToString(buf)); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, jwt.JwtIDKey)
+		}
+	}
+
+	if err := Validate(t); err != nil {
+		return nil, err
+	}
+
+	if hdrs == nil {
+		hdrs = jws.NewHeaders()
+	}
+	if err := hdrs.Set(jws.TypeKey, MediaType); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s header`, jws.TypeKey)
+	}
+	return jwt.Sign(t, alg, key, jwt.WithHeaders(hdrs))
+}
+
+// Parse decrypts (if necessary) and verifies the SET, and validates its
+// contents.
+//
+// On top of the validation performed by `jwt.Validate()`, the following
+// are checked:
+//
+//   * the "typ" header is `secevent+jwt`. Use `set.WithTypeOptional()`
+//     to also accept tokens without a "typ" header
+//   * the signature algorithm is not "none", and is one of the values
+//     specified via `set.WithAllowedAlgorithms()`
+//   * the claims, as described in `set.Validate()`
+//
+// One of `set.WithVerify()` or `set.WithKeySet()` must be specified.
+// Receivers should also specify `set.WithIssuer()` and `set.WithAudience()`,
+// and are responsible for rejecting SETs whose "jti" has been seen before
+// (see `jwt.WithReplayProtection()`).
+func Parse(data []byte, options ...ParseOption) (jwt.Token, error) {
+	cfg := profile.Config{Type: MediaType}
+	parseOptions := []jwt.ParseOption{jwt.WithValidate(true)}
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identVerify{}:
+			p := option.Value().(*verifyParams)
+			parseOptions = append(parseOptions, jwt.WithVerify(p.alg, p.key))
+		case identKeySet{}:
+			parseOptions = append(parseOptions, jwt.WithKeySet(option.Value().(jwk.Set)))
+		case identDecrypt{}:
+			p := option.Value().(*decryptParams)
+			cfg.DecryptAlgorithm = p.alg
+			cfg.DecryptKey = p.key
+		case identAllowedAlgorithms{}:
+			cfg.AllowedAlgorithms = option.Value().([]jwa.SignatureAlgorithm)
+		case identIssuer{}:
+			parseOptions = append(parseOptions, jwt.WithIssuer(option.Value().(string)))
+		case identAudience{}:
+			parseOptions = append(parseOptions, jwt.WithAudience(option.Value().(string)))
+		case identTypeOptional{}:
+			if option.Value().(bool) {
+				cfg.Type = ""
+			} else {
+				cfg.Type = MediaType
+			}
+		case identParseOptions{}:
+			parseOptions = append(parseOptions, option.Value().([]jwt.ParseOption)...)
+		}
+	}
+	cfg.ParseOptions = parseOptions
+
+	tok, hdrs, err := profile.Parse(data, &cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse security event token`)
+	}
+
+	// When the "typ" header is optional, it must still be correct if present
+	if typ := hdrs.Type(); cfg.Type == "" && typ != "" && !profile.TypeMatches(typ, MediaType) {
+		return nil, errors.Errorf(`invalid "typ" header: expected %q, got %q`, MediaType, typ)
+	}
+
+	if err := Validate(tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+// Validate checks the claims of a SET:
+//
+//   * the "iss", "iat", "jti" and "events" claims exist
+//   * the "events" claim is a non-empty object, whose members are objects
+//   * the "toe" claim, if present, is a NumericDate
+//   * the "txn" claim, if present, is a string
+//
+// If the SET contains RISC or CAEP events, the following are checked
+// as well:
+//
+//   * the "events" claim contains exactly one event
+//   * the "sub" claim does not exist
+//   * the subject of the event is specified either in the "sub_id"
+//     claim, or in the "subject" member of the event, as an object
+//     with a "format" (or the legacy "subject_type") member
+//   * for CAEP events, the "event_timestamp" member, if present,
+//     is a number
+func Validate(t jwt.Token) error {
+	return checkClaims(t, true)
+}
+
+func checkClaims(t jwt.Token, complete bool) error {
+	required := []string{jwt.IssuerKey}
+	if complete {
+		required = append(required, jwt.IssuedAtKey, jwt.JwtIDKey)
+	}
+	for _, name := range required {
+		if _, ok := t.Get(name); !ok {
+			return errors.Errorf(`required claim %q was not found`, name)
+		}
+	}
+
+	events, err := Events(t)
+	if err != nil {
+		return err
+	}
+
+	if v, ok := t.Get(TimeOfEventKey); ok {
+		if _, err := numericDate(v); err != nil {
+			return errors.Wrapf(err, `invalid value for %q`, TimeOfEventKey)
+		}
+	}
+	if v, ok := t.Get(TransactionIDKey); ok {
+		if _, ok := v.(string); !ok {
+			return errors.Errorf(`invalid value for %q: expected string, got %T`, TransactionIDKey, v)
+		}
+	}
+
+	for typ := range events {
+		if strings.HasPrefix(typ, RISCEventPrefix) || strings.HasPrefix(typ, CAEPEventPrefix) {
+			return checkProfile(t, events)
+		}
+	}
+	return nil
+}
+
+func checkProfile(t jwt.Token, events map[string]map[string]interface{}) error {
+	if len(events) != 1 {
+		return errors.Errorf(`%q claim must contain exactly one RISC or CAEP event, got %d events`, EventsKey, len(events))
+	}
+	if _, ok := t.Get(jwt.SubjectKey); ok {
+		return errors.Errorf(`%q claim must not be used with RISC or CAEP events`, jwt.SubjectKey)
+	}
+
+	for typ, payload := range events {
+		subject, ok := payload["subject"]
+		if !ok {
+			subject, ok = t.Get(SubjectIDKey)
+		}
+		if !ok {
+			return errors.Errorf(`subject of event %q must be specified in %q or in the "subject" member of the event`, typ, SubjectIDKey)
+		}
+		if err := checkSubjectIdentifier(subject); err != nil {
+			return errors.Wrapf(err, `invalid subject for event %q`, typ)
+		}
+
+		if strings.HasPrefix(typ, CAEPEventPrefix) {
+			if v, ok := payload["event_timestamp"]; ok {
+				if _, err := numericDate(v); err != nil {
+					return errors.Wrapf(err, `invalid value for "event_timestamp" in event %q`, typ)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkSubjectIdentifier(v interface{}) error {
+	subject, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.Errorf(`expected object, got %T`, v)
+	}
+
+	for _, name := range []string{"format", "subject_type"} {
+		if format, ok := subject[name]; ok {
+			if s, ok := format.(string); !ok || s == "" {
+				return errors.Errorf(`%q must be a non-empty string`, name)
+			}
+			return nil
+		}
+	}
+	return errors.New(`subject identifier must contain "format"`)
+}
+
+// Events returns the events in the "events" claim of the SET, keyed
+// by their event type identifiers. An error is returned if the claim
+// does not exist, is empty, or if the claim or any of the events is
+// not an object.
+func Events(t jwt.Token) (map[string]map[string]interface{}, error) {
+	v, ok := t.Get(EventsKey)
+	if !ok {
+		return nil, errors.Errorf(`required claim %q was not found`, EventsKey)
+	}
+	src, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf(`invalid value for %q: expected object, got %T`, EventsKey, v)
+	}
+	if len(src) == 0 {
+		return nil, errors.Errorf(`%q claim must contain at least one event`, EventsKey)
+	}
+
+	events := make(map[string]map[string]interface{}, len(src))
+	for typ, payload := range src {
+		m, ok := payload.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf(`invalid value for %q in %q: expected object, got %T`, typ, EventsKey, payload)
+		}
+		events[typ] = m
+	}
+	return events, nil
+}
+
+// TimeOfEvent returns the value of the "toe" claim. The second return
+// value is false if the claim does not exist or is not a NumericDate.
+func TimeOfEvent(t jwt.Token) (time.Time, bool) {
+	v, ok := t.Get(TimeOfEventKey)
+	if !ok {
+		return time.Time{}, false
+	}
+	toe, err := numericDate(v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return toe, true
+}
+
+// TransactionID returns the value of the "txn" claim, or an empty
+// string if it does not exist
+func TransactionID(t jwt.Token) string {
+	v, _ := t.Get(TransactionIDKey)
+	s, _ := v.(string)
+	return s
+}
+
+// numericDate converts a value decoded from JSON to time.Time. Unlike
+// the standard time based claims, strings are not accepted
+func numericDate(v interface{}) (time.Time, error) {
+	if _, ok := v.(string); ok {
+		return time.Time{}, errors.Errorf(`expected number, got %T`, v)
+	}
+
+	var date types.NumericDate
+	if err := date.Accept(v); err != nil {
+		return time.Time{}, err
+	}
+	return date.Get(), nil
+}
@@ -0,0 +1,210 @@
+package set_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/set"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	transmitter = `https://idp.example.com/`
+	receiver    = `https://sp.example.com/`
+
+	accountDisabled = set.RISCEventPrefix + `account-disabled`
+	sessionRevoked  = set.CAEPEventPrefix + `session-revoked`
+)
+
+func TestSET(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	t.Run("Sign and Parse", func(t *testing.T) {
+		t.Parallel()
+		toe := time.Unix(1615305159, 0)
+		tok, err := set.NewBuilder().
+			Issuer(transmitter).
+			Audience(receiver).
+			TimeOfEvent(toe).
+			TransactionID(`8675309`).
+			SubjectID(map[string]interface{}{"format": "email", "email": "user@example.com"}).
+			Event(sessionRevoked, map[string]interface{}{"event_timestamp": toe.Unix()}).
+			Build()
+		if !assert.NoError(t, err, `Build should succeed`) {
+			return
+		}
+
+		signed, err := set.Sign(tok, jwa.ES256, key)
+		if !assert.NoError(t, err, `set.Sign should succeed`) {
+			return
+		}
+		if _, ok := tok.Get(jwt.JwtIDKey); !assert.False(t, ok, `original token should not be modified`) {
+			return
+		}
+
+		msg, err := jws.Parse(signed)
+		if !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, set.MediaType, msg.Signatures()[0].ProtectedHeaders().Type(), `"typ" should be secevent+jwt`) {
+			return
+		}
+
+		parsed, err := set.Parse(signed,
+			set.WithVerify(jwa.ES256, &key.PublicKey),
+			set.WithIssuer(transmitter),
+			set.WithAudience(receiver),
+			set.WithAllowedAlgorithms(jwa.ES256),
+		)
+		if !assert.NoError(t, err, `set.Parse should succeed`) {
+			return
+		}
+		if !assert.NotEmpty(t, parsed.JwtID(), `"jti" should be filled in`) {
+			return
+		}
+
+		events, err := set.Events(parsed)
+		if !assert.NoError(t, err, `set.Events should succeed`) {
+			return
+		}
+		if !assert.Contains(t, events, sessionRevoked, `events should contain the session revoked event`) {
+			return
+		}
+
+		parsedTOE, ok := set.TimeOfEvent(parsed)
+		if !assert.True(t, ok, `set.TimeOfEvent should succeed`) {
+			return
+		}
+		if !assert.True(t, toe.Equal(parsedTOE), `"toe" should match`) {
+			return
+		}
+		if !assert.Equal(t, `8675309`, set.TransactionID(parsed), `"txn" should match`) {
+			return
+		}
+
+		_, err = set.Parse(signed, set.WithVerify(jwa.ES256, &key.PublicKey), set.WithAudience(`https://other.example.com/`))
+		if !assert.Error(t, err, `set.Parse should fail for wrong audience`) {
+			return
+		}
+	})
+	t.Run("typ", func(t *testing.T) {
+		t.Parallel()
+		tok := jwt.New()
+		tok.Set(jwt.IssuerKey, transmitter)
+		tok.Set(jwt.IssuedAtKey, time.Now())
+		tok.Set(jwt.JwtIDKey, `4d3559ec67504aaba65d40b0363faad8`)
+		tok.Set(set.EventsKey, map[string]interface{}{`urn:example:event`: map[string]interface{}{}})
+
+		// jwt.Sign always sets "typ", so sign the payload directly
+		payload, err := json.Marshal(tok)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+		signed, err := jws.Sign(payload, jwa.ES256, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = set.Parse(signed, set.WithVerify(jwa.ES256, &key.PublicKey))
+		if !assert.Error(t, err, `set.Parse should fail without "typ"`) {
+			return
+		}
+		_, err = set.Parse(signed, set.WithVerify(jwa.ES256, &key.PublicKey), set.WithTypeOptional(true))
+		if !assert.NoError(t, err, `set.Parse with WithTypeOptional should succeed`) {
+			return
+		}
+
+		signed, err = jwt.Sign(tok, jwa.ES256, key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+		_, err = set.Parse(signed, set.WithVerify(jwa.ES256, &key.PublicKey), set.WithTypeOptional(true))
+		if !assert.Error(t, err, `set.Parse should fail for wrong "typ"`) {
+			return
+		}
+	})
+	t.Run("Validate", func(t *testing.T) {
+		t.Parallel()
+		subject := map[string]interface{}{"format": "iss_sub", "iss": transmitter, "sub": "145234573"}
+		testcases := []struct {
+			Name    string
+			Builder *set.Builder
+			Error   bool
+		}{
+			{
+				Name:    "no events",
+				Builder: set.NewBuilder().Issuer(transmitter),
+				Error:   true,
+			},
+			{
+				Name:    "no issuer",
+				Builder: set.NewBuilder().Event(`urn:example:event`, nil),
+				Error:   true,
+			},
+			{
+				Name:    "custom event",
+				Builder: set.NewBuilder().Issuer(transmitter).Subject(`user`).Event(`urn:example:event`, nil),
+			},
+			{
+				Name:    "invalid txn",
+				Builder: set.NewBuilder().Issuer(transmitter).Claim(set.TransactionIDKey, 1).Event(`urn:example:event`, nil),
+				Error:   true,
+			},
+			{
+				Name:    "invalid toe",
+				Builder: set.NewBuilder().Issuer(transmitter).Claim(set.TimeOfEventKey, `yesterday`).Event(`urn:example:event`, nil),
+				Error:   true,
+			},
+			{
+				Name:    "RISC event with subject",
+				Builder: set.NewBuilder().Issuer(transmitter).Event(accountDisabled, map[string]interface{}{"subject": subject}),
+			},
+			{
+				Name:    "RISC event without subject",
+				Builder: set.NewBuilder().Issuer(transmitter).Event(accountDisabled, nil),
+				Error:   true,
+			},
+			{
+				Name:    "RISC event with sub",
+				Builder: set.NewBuilder().Issuer(transmitter).Subject(`user`).SubjectID(subject).Event(accountDisabled, nil),
+				Error:   true,
+			},
+			{
+				Name:    "RISC event with invalid subject",
+				Builder: set.NewBuilder().Issuer(transmitter).Event(accountDisabled, map[string]interface{}{"subject": map[string]interface{}{"email": "user@example.com"}}),
+				Error:   true,
+			},
+			{
+				Name:    "multiple RISC and CAEP events",
+				Builder: set.NewBuilder().Issuer(transmitter).SubjectID(subject).Event(accountDisabled, nil).Event(sessionRevoked, nil),
+				Error:   true,
+			},
+			{
+				Name:    "CAEP event with invalid event_timestamp",
+				Builder: set.NewBuilder().Issuer(transmitter).SubjectID(subject).Event(sessionRevoked, map[string]interface{}{"event_timestamp": "now"}),
+				Error:   true,
+			},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				_, err := tc.Builder.Build()
+				if tc.Error {
+					assert.Error(t, err, `Build should fail`)
+				} else {
+					assert.NoError(t, err, `Build should succeed`)
+				}
+			})
+		}
+	})
+}
@@ -40,6 +40,17 @@ const (
 // Embedding jwt.Token into another struct is not recommended, becase
 // jwt.Token needs to handle private claims, and this really does not
 // work well when it is embedded in other structure
+//
+// `Iterate()` and `Walk()` visit the claims in lexicographical order of
+// their names, so that the results are reproducible across runs.
+//
+// `Remove()` deletes the claim (standard or private) from the token,
+// which means that it will no longer be included when the token is
+// serialized. Removing a claim that does not exist is not an error.
+//
+// `Scopes()` returns the scopes granted to the token, taken from both the
+// space-delimited "scope" claim (RFC 8693) and the "scp" claim, which some
+// identity providers use to hold the scopes as an array.
 type Token interface {
 	Audience() []string
 	Expiration() time.Time
@@ -52,6 +63,7 @@ type Token interface {
 	Get(string) (interface{}, bool)
 	Set(string, interface{}) error
 	Remove(string) error
+	Scopes() []string
 	Clone() (Token, error)
 	Iterate(context.Context) Iterator
 	Walk(context.Context, Visitor) error
@@ -336,6 +348,9 @@ func (t *stdToken) makePairs() []*ClaimPair {
 	for k, v := range t.privateClaims {
 		pairs = append(pairs, &ClaimPair{Key: k, Value: v})
 	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.(string) < pairs[j].Key.(string)
+	})
 	return pairs
 }
 
@@ -349,7 +364,7 @@ func (t *stdToken) UnmarshalJSON(buf []byte) error {
 	t.jwtID = nil
 	t.notBefore = nil
 	t.subject = nil
-	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec := json.NewDecoderCtx(t.dc, bytes.NewReader(buf))
 LOOP:
 	for {
 		tok, err := dec.Token()
@@ -459,7 +474,7 @@ func (t stdToken) MarshalJSON() ([]byte, error) {
 			}
 			continue
 		case ExpirationKey, IssuedAtKey, NotBeforeKey:
-			enc.Encode(data[f].(time.Time).Unix())
+			buf.WriteString(types.FormatNumericDate(data[f].(time.Time)))
 			continue
 		}
 		v := data[f]
@@ -3,6 +3,7 @@ package jwt_test
 import (
 	"context"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -237,8 +238,8 @@ func TestToken(t *testing.T) {
 			return
 		}
 
-		newtok, err := jwt.Parse(buf)
-		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+		newtok, err := jwt.ParseInsecure(buf)
+		if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
 			return
 		}
 
@@ -285,4 +286,68 @@ func TestToken(t *testing.T) {
 			}
 		}
 	})
+	t.Run("Remove and serialize", func(t *testing.T) {
+		newtok, err := tok.Clone()
+		if !assert.NoError(t, err, `tok.Clone should succeed`) {
+			return
+		}
+
+		newtok.Set(`secret`, `do not log me`)
+		for _, name := range []string{jwt.SubjectKey, jwt.IssuedAtKey, `secret`, `does-not-exist`} {
+			if !assert.NoError(t, newtok.Remove(name), `newtok.Remove should succeed`) {
+				return
+			}
+		}
+
+		buf, err := json.Marshal(newtok)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+
+		var m map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal(buf, &m), `json.Unmarshal should succeed`) {
+			return
+		}
+		for _, name := range []string{jwt.SubjectKey, jwt.IssuedAtKey, `secret`} {
+			if !assert.NotContains(t, m, name, `removed claim should not be serialized`) {
+				return
+			}
+		}
+		if !assert.Contains(t, m, jwt.IssuerKey, `other claims should be serialized`) {
+			return
+		}
+	})
+	t.Run("Iterate (deterministic order)", func(t *testing.T) {
+		ctx := context.TODO()
+
+		newtok, err := tok.Clone()
+		if !assert.NoError(t, err, `tok.Clone should succeed`) {
+			return
+		}
+		for _, k := range []string{`zzz`, `aaa`, `mmm`, `x-private`} {
+			if !assert.NoError(t, newtok.Set(k, k), `newtok.Set should succeed`) {
+				return
+			}
+		}
+
+		var expected []string
+		for i := 0; i < 10; i++ {
+			var keys []string
+			for iter := newtok.Iterate(ctx); iter.Next(ctx); {
+				keys = append(keys, iter.Pair().Key.(string))
+			}
+
+			if !assert.True(t, sort.StringsAreSorted(keys), `keys should be sorted`) {
+				return
+			}
+
+			if expected == nil {
+				expected = keys
+				continue
+			}
+			if !assert.Equal(t, expected, keys, `keys should be visited in the same order`) {
+				return
+			}
+		}
+	})
 }
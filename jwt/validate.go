@@ -1,7 +1,6 @@
 package jwt
 
 import (
-	"fmt"
 	"strconv"
 	"time"
 
@@ -17,6 +16,20 @@ func (f ClockFunc) Now() time.Time {
 	return f()
 }
 
+// Validator describes an object that can validate a token, in
+// addition to the standard validation performed by `jwt.Validate()`.
+// Use `jwt.WithValidator()` to register one.
+type Validator interface {
+	Validate(Token) error
+}
+
+// ValidatorFunc is a function that implements the Validator interface
+type ValidatorFunc func(Token) error
+
+func (f ValidatorFunc) Validate(t Token) error {
+	return f(t)
+}
+
 func isSupportedTimeClaim(c string) error {
 	switch c {
 	case ExpirationKey, IssuedAtKey, NotBeforeKey:
@@ -39,6 +52,15 @@ func timeClaim(t Token, clock Clock, c string) time.Time {
 	return time.Time{} // should *NEVER* reach here, but...
 }
 
+// leeway returns the claim specific leeway if specified, otherwise
+// the global skew value
+func leeway(specific *time.Duration, skew time.Duration) time.Duration {
+	if specific != nil {
+		return *specific
+	}
+	return skew
+}
+
 // Validate makes sure that the essential claims stand.
 //
 // See the various `WithXXX` functions for optional parameters
@@ -50,7 +72,12 @@ func Validate(t Token, options ...ValidateOption) error {
 	var jwtid string
 	var clock Clock = ClockFunc(time.Now)
 	var skew time.Duration
+	var expSkew, nbfSkew, iatSkew *time.Duration
 	var deltas []delta
+	var validators []Validator
+	var jtiStore JTIStore
+	var requiredScopes []string
+	var issuerCmp, subjectCmp, audienceCmp StringComparison
 	requiredMap := make(map[string]struct{})
 	claimValues := make(map[string]interface{})
 	for _, o := range options {
@@ -60,6 +87,15 @@ func Validate(t Token, options ...ValidateOption) error {
 			clock = o.Value().(Clock)
 		case identAcceptableSkew{}:
 			skew = o.Value().(time.Duration)
+		case identExpirationLeeway{}:
+			v := o.Value().(time.Duration)
+			expSkew = &v
+		case identNotBeforeLeeway{}:
+			v := o.Value().(time.Duration)
+			nbfSkew = &v
+		case identIssuedAtTolerance{}:
+			v := o.Value().(time.Duration)
+			iatSkew = &v
 		case identIssuer{}:
 			issuer = o.Value().(string)
 		case identSubject{}:
@@ -70,6 +106,8 @@ func Validate(t Token, options ...ValidateOption) error {
 			jwtid = o.Value().(string)
 		case identRequiredClaim{}:
 			requiredMap[o.Value().(string)] = struct{}{}
+		case identRequiredScopes{}:
+			requiredScopes = append(requiredScopes, o.Value().([]string)...)
 		case identTimeDelta{}:
 			d := o.Value().(delta)
 			deltas = append(deltas, d)
@@ -86,15 +124,34 @@ func Validate(t Token, options ...ValidateOption) error {
 				}
 				requiredMap[d.c2] = struct{}{}
 			}
+		case identClaimComparison{}:
+			v := o.Value().(claimComparison)
+			if err := v.cmp.validate(); err != nil {
+				return err
+			}
+			switch v.name {
+			case IssuerKey:
+				issuerCmp = v.cmp
+			case SubjectKey:
+				subjectCmp = v.cmp
+			case AudienceKey:
+				audienceCmp = v.cmp
+			default:
+				return errors.Errorf(`claim comparison cannot be specified for %q`, v.name)
+			}
 		case identClaim{}:
 			claim := o.Value().(claimValue)
 			claimValues[claim.name] = claim.value
+		case identValidator{}:
+			validators = append(validators, o.Value().(Validator))
+		case identReplayProtection{}:
+			jtiStore = o.Value().(JTIStore)
 		}
 	}
 
 	for c := range requiredMap {
 		if _, ok := t.Get(c); !ok {
-			return errors.Errorf(`required claim %s was not found`, c)
+			return newValidationError(c, ErrMissingRequiredClaim, `required claim %s was not found`, c)
 		}
 	}
 
@@ -106,33 +163,33 @@ func Validate(t Token, options ...ValidateOption) error {
 		if delta.less { // t1 - t2 <= delta.dur
 			// t1 - t2 < delta.dur + skew
 			if t1.Sub(t2) > delta.dur+skew {
-				return errors.Errorf(`delta between %s and %s exceeds %s (skew %s)`, delta.c1, delta.c2, delta.dur, skew)
+				return newValidationError(delta.c1, ErrInvalidTimeDelta, `delta between %s and %s exceeds %s (skew %s)`, delta.c1, delta.c2, delta.dur, skew)
 			}
 		} else {
 			if t1.Sub(t2) < delta.dur-skew {
-				return errors.Errorf(`delta between %s and %s is less than %s (skew %s)`, delta.c1, delta.c2, delta.dur, skew)
+				return newValidationError(delta.c1, ErrInvalidTimeDelta, `delta between %s and %s is less than %s (skew %s)`, delta.c1, delta.c2, delta.dur, skew)
 			}
 		}
 	}
 
 	// check for iss
 	if len(issuer) > 0 {
-		if v := t.Issuer(); v != issuer {
-			return errors.New(`iss not satisfied`)
+		if v := t.Issuer(); !issuerCmp.equal(issuer, v) {
+			return newValidationError(IssuerKey, ErrInvalidIssuer, `iss not satisfied`)
 		}
 	}
 
 	// check for jti
 	if len(jwtid) > 0 {
 		if v := t.JwtID(); v != jwtid {
-			return errors.New(`jti not satisfied`)
+			return newValidationError(JwtIDKey, ErrInvalidJwtID, `jti not satisfied`)
 		}
 	}
 
 	// check for sub
 	if len(subject) > 0 {
-		if v := t.Subject(); v != subject {
-			return errors.New(`sub not satisfied`)
+		if v := t.Subject(); !subjectCmp.equal(subject, v) {
+			return newValidationError(SubjectKey, ErrInvalidSubject, `sub not satisfied`)
 		}
 	}
 
@@ -140,13 +197,13 @@ func Validate(t Token, options ...ValidateOption) error {
 	if len(audience) > 0 {
 		var found bool
 		for _, v := range t.Audience() {
-			if v == audience {
+			if audienceCmp.equal(audience, v) {
 				found = true
 				break
 			}
 		}
 		if !found {
-			return errors.New(`aud not satisfied`)
+			return newValidationError(AudienceKey, ErrInvalidAudience, `aud not satisfied`)
 		}
 	}
 
@@ -154,8 +211,8 @@ func Validate(t Token, options ...ValidateOption) error {
 	if tv := t.Expiration(); !tv.IsZero() && tv.Unix() != 0 {
 		now := clock.Now().Truncate(time.Second)
 		ttv := tv.Truncate(time.Second)
-		if !now.Before(ttv.Add(skew)) {
-			return errors.New(`exp not satisfied`)
+		if !now.Before(ttv.Add(leeway(expSkew, skew))) {
+			return newValidationError(ExpirationKey, ErrTokenExpired, `exp not satisfied`)
 		}
 	}
 
@@ -163,8 +220,8 @@ func Validate(t Token, options ...ValidateOption) error {
 	if tv := t.IssuedAt(); !tv.IsZero() && tv.Unix() != 0 {
 		now := clock.Now().Truncate(time.Second)
 		ttv := tv.Truncate(time.Second)
-		if now.Before(ttv.Add(-1 * skew)) {
-			return errors.New(`iat not satisfied`)
+		if now.Before(ttv.Add(-1 * leeway(iatSkew, skew))) {
+			return newValidationError(IssuedAtKey, ErrInvalidIssuedAt, `iat not satisfied`)
 		}
 	}
 
@@ -173,14 +230,55 @@ func Validate(t Token, options ...ValidateOption) error {
 		now := clock.Now().Truncate(time.Second)
 		ttv := tv.Truncate(time.Second)
 		// now cannot be before t, so we check for now > t - skew
-		if !now.Equal(ttv) && !now.After(ttv.Add(-1*skew)) {
-			return errors.New(`nbf not satisfied`)
+		if !now.Equal(ttv) && !now.After(ttv.Add(-1*leeway(nbfSkew, skew))) {
+			return newValidationError(NotBeforeKey, ErrTokenNotYetValid, `nbf not satisfied`)
+		}
+	}
+
+	if len(requiredScopes) > 0 {
+		granted := make(map[string]struct{})
+		for _, scope := range t.Scopes() {
+			granted[scope] = struct{}{}
+		}
+		for _, scope := range requiredScopes {
+			if _, ok := granted[scope]; !ok {
+				return newValidationError(ScopeKey, ErrInsufficientScope, `scope %q not granted`, scope)
+			}
 		}
 	}
 
 	for name, expectedValue := range claimValues {
 		if v, ok := t.Get(name); !ok || v != expectedValue {
-			return fmt.Errorf(`%v not satisfied`, name)
+			return newValidationError(name, ErrInvalidClaim, `%v not satisfied`, name)
+		}
+	}
+
+	for _, v := range validators {
+		if err := v.Validate(t); err != nil {
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				return err
+			}
+			return newValidationError("", err, `%s`, err)
+		}
+	}
+
+	if jtiStore != nil {
+		jti := t.JwtID()
+		if jti == "" {
+			return newValidationError(JwtIDKey, ErrMissingRequiredClaim, `required claim jti was not found`)
+		}
+
+		var expires time.Time
+		if tv := t.Expiration(); !tv.IsZero() && tv.Unix() != 0 {
+			expires = tv.Add(leeway(expSkew, skew))
+		}
+		ok, err := jtiStore.Add(t.Issuer(), jti, expires, clock.Now())
+		if err != nil {
+			return errors.Wrap(err, `failed to record jti`)
+		}
+		if !ok {
+			return newValidationError(JwtIDKey, ErrTokenReplayed, `jti not satisfied: token has already been used`)
 		}
 	}
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -206,16 +207,16 @@ func TestGHIssue10(t *testing.T) {
 			return
 		}
 
-		_, err = jwt.Parse(buf, jwt.WithValidate(true))
+		_, err = jwt.ParseInsecure(buf, jwt.WithValidate(true))
 		// This should fail, because exp is set in the past
-		if !assert.Error(t, err, "jwt.Parse should fail") {
+		if !assert.Error(t, err, "jwt.ParseInsecure should fail") {
 			return
 		}
 
-		_, err = jwt.Parse(buf, jwt.WithValidate(true), jwt.WithAcceptableSkew(time.Hour))
+		_, err = jwt.ParseInsecure(buf, jwt.WithValidate(true), jwt.WithAcceptableSkew(time.Hour))
 		// This should succeed, because we have given big skew
 		// that is well enough to get us accepted
-		if !assert.NoError(t, err, "jwt.Parse should succeed (1)") {
+		if !assert.NoError(t, err, "jwt.ParseInsecure should succeed (1)") {
 			return
 		}
 
@@ -224,8 +225,8 @@ func TestGHIssue10(t *testing.T) {
 		clock := jwt.ClockFunc(func() time.Time {
 			return tm.Add(-59 * time.Minute)
 		})
-		_, err = jwt.Parse(buf, jwt.WithValidate(true), jwt.WithClock(clock))
-		if !assert.NoError(t, err, "jwt.Parse should succeed (2)") {
+		_, err = jwt.ParseInsecure(buf, jwt.WithValidate(true), jwt.WithClock(clock))
+		if !assert.NoError(t, err, "jwt.ParseInsecure should succeed (2)") {
 			return
 		}
 	})
@@ -255,4 +256,290 @@ func TestGHIssue10(t *testing.T) {
 			return
 		}
 	})
+	t.Run("per-claim leeway", func(t *testing.T) {
+		t.Parallel()
+		now := time.Now()
+		clock := jwt.ClockFunc(func() time.Time { return now })
+
+		t.Run(jwt.ExpirationKey, func(t *testing.T) {
+			t1 := jwt.New()
+			t1.Set(jwt.ExpirationKey, now.Add(-time.Minute))
+
+			if !assert.Error(t, jwt.Validate(t1, jwt.WithClock(clock), jwt.WithNotBeforeLeeway(time.Hour), jwt.WithIssuedAtTolerance(time.Hour)), `jwt.Validate should fail`) {
+				return
+			}
+			if !assert.NoError(t, jwt.Validate(t1, jwt.WithClock(clock), jwt.WithExpirationLeeway(2*time.Minute)), `jwt.Validate should succeed`) {
+				return
+			}
+			// claim specific leeway takes precedence over the global skew
+			if !assert.Error(t, jwt.Validate(t1, jwt.WithClock(clock), jwt.WithAcceptableSkew(time.Hour), jwt.WithExpirationLeeway(0)), `jwt.Validate should fail`) {
+				return
+			}
+		})
+		t.Run(jwt.NotBeforeKey, func(t *testing.T) {
+			t1 := jwt.New()
+			t1.Set(jwt.NotBeforeKey, now.Add(time.Minute))
+
+			if !assert.Error(t, jwt.Validate(t1, jwt.WithClock(clock), jwt.WithExpirationLeeway(time.Hour), jwt.WithIssuedAtTolerance(time.Hour)), `jwt.Validate should fail`) {
+				return
+			}
+			if !assert.NoError(t, jwt.Validate(t1, jwt.WithClock(clock), jwt.WithNotBeforeLeeway(5*time.Minute)), `jwt.Validate should succeed`) {
+				return
+			}
+		})
+		t.Run(jwt.IssuedAtKey, func(t *testing.T) {
+			t1 := jwt.New()
+			t1.Set(jwt.IssuedAtKey, now.Add(12*time.Hour))
+			t1.Set(jwt.NotBeforeKey, now.Add(-time.Minute))
+
+			if !assert.Error(t, jwt.Validate(t1, jwt.WithClock(clock), jwt.WithExpirationLeeway(time.Hour), jwt.WithNotBeforeLeeway(time.Hour)), `jwt.Validate should fail`) {
+				return
+			}
+			if !assert.NoError(t, jwt.Validate(t1, jwt.WithClock(clock), jwt.WithIssuedAtTolerance(24*time.Hour)), `jwt.Validate should succeed`) {
+				return
+			}
+		})
+	})
+}
+
+func TestReplayProtection(t *testing.T) {
+	t.Parallel()
+	t.Run("Validate", func(t *testing.T) {
+		t.Parallel()
+		store := jwt.NewMemoryJTIStore(time.Hour)
+
+		t1 := jwt.New()
+		t1.Set(jwt.JwtIDKey, `one-time`)
+		t1.Set(jwt.ExpirationKey, time.Now().Add(time.Minute))
+
+		if !assert.NoError(t, jwt.Validate(t1, jwt.WithReplayProtection(store)), `first use should succeed`) {
+			return
+		}
+		if !assert.Error(t, jwt.Validate(t1, jwt.WithReplayProtection(store)), `second use should fail`) {
+			return
+		}
+
+		t2 := jwt.New()
+		t2.Set(jwt.JwtIDKey, `another`)
+		if !assert.NoError(t, jwt.Validate(t2, jwt.WithReplayProtection(store)), `token without exp should succeed`) {
+			return
+		}
+
+		t3 := jwt.New()
+		if !assert.Error(t, jwt.Validate(t3, jwt.WithReplayProtection(store)), `token without jti should fail`) {
+			return
+		}
+
+		// Invalid tokens must not be recorded
+		t4 := jwt.New()
+		t4.Set(jwt.JwtIDKey, `invalid`)
+		t4.Set(jwt.IssuerKey, `https://attacker.example.com`)
+		if !assert.Error(t, jwt.Validate(t4, jwt.WithIssuer(`https://issuer.example.com`), jwt.WithReplayProtection(store)), `jwt.Validate should fail`) {
+			return
+		}
+		if !assert.Equal(t, 2, store.Len(), `invalid token should not be recorded`) {
+			return
+		}
+	})
+	t.Run("Clock and issuer", func(t *testing.T) {
+		t.Parallel()
+		store := jwt.NewMemoryJTIStore(time.Hour)
+
+		// the token is valid according to the validation clock, and it
+		// must be remembered until it expires according to that clock
+		now := time.Unix(1600000000, 0)
+		clock := jwt.ClockFunc(func() time.Time { return now })
+		tok := jwt.New()
+		tok.Set(jwt.IssuerKey, `https://a.example.com`)
+		tok.Set(jwt.JwtIDKey, `one-time`)
+		tok.Set(jwt.ExpirationKey, now.Add(time.Minute))
+		if !assert.NoError(t, jwt.Validate(tok, jwt.WithClock(clock), jwt.WithReplayProtection(store)), `first use should succeed`) {
+			return
+		}
+		if !assert.Error(t, jwt.Validate(tok, jwt.WithClock(clock), jwt.WithReplayProtection(store)), `second use should fail`) {
+			return
+		}
+
+		other := jwt.New()
+		other.Set(jwt.IssuerKey, `https://b.example.com`)
+		other.Set(jwt.JwtIDKey, `one-time`)
+		other.Set(jwt.ExpirationKey, now.Add(time.Minute))
+		if !assert.NoError(t, jwt.Validate(other, jwt.WithClock(clock), jwt.WithReplayProtection(store)), `same jti from another issuer should succeed`) {
+			return
+		}
+	})
+	t.Run("MemoryJTIStore", func(t *testing.T) {
+		t.Parallel()
+		store := jwt.NewMemoryJTIStore(time.Hour)
+
+		now := time.Now()
+		ok, err := store.Add(``, `expired`, now.Add(-time.Second), now)
+		if !assert.NoError(t, err, `store.Add should succeed`) || !assert.True(t, ok, `store.Add should return true`) {
+			return
+		}
+		ok, err = store.Add(``, `expired`, now.Add(time.Minute), now)
+		if !assert.NoError(t, err, `store.Add should succeed`) || !assert.True(t, ok, `expired entry should be replaced`) {
+			return
+		}
+		ok, err = store.Add(``, `expired`, now.Add(time.Minute), now)
+		if !assert.NoError(t, err, `store.Add should succeed`) || !assert.False(t, ok, `store.Add should return false`) {
+			return
+		}
+		ok, err = store.Add(``, `expired`, now.Add(2*time.Minute), now.Add(time.Minute))
+		if !assert.NoError(t, err, `store.Add should succeed`) || !assert.True(t, ok, `entry should expire according to now`) {
+			return
+		}
+	})
+}
+
+func TestScopes(t *testing.T) {
+	t.Parallel()
+	t.Run("Scopes", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name     string
+			Claims   map[string]interface{}
+			Expected []string
+		}{
+			{
+				Name:     "no scopes",
+				Claims:   map[string]interface{}{},
+				Expected: nil,
+			},
+			{
+				Name:     "space-delimited scope",
+				Claims:   map[string]interface{}{jwt.ScopeKey: `read  write`},
+				Expected: []string{`read`, `write`},
+			},
+			{
+				Name:     "array-valued scp",
+				Claims:   map[string]interface{}{jwt.ScpKey: []string{`read`, `write`}},
+				Expected: []string{`read`, `write`},
+			},
+			{
+				Name:     "string-valued scp",
+				Claims:   map[string]interface{}{jwt.ScpKey: `read write`},
+				Expected: []string{`read`, `write`},
+			},
+			{
+				Name:     "both claims",
+				Claims:   map[string]interface{}{jwt.ScopeKey: `read write`, jwt.ScpKey: []interface{}{`write`, `admin`}},
+				Expected: []string{`read`, `write`, `admin`},
+			},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				buf, err := json.Marshal(tc.Claims)
+				if !assert.NoError(t, err, `json.Marshal should succeed`) {
+					return
+				}
+				tok, err := jwt.ParseInsecure(buf)
+				if !assert.NoError(t, err, `jwt.ParseInsecure should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Expected, tok.Scopes(), `scopes should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("WithRequiredScopes", func(t *testing.T) {
+		t.Parallel()
+		tok := jwt.New()
+		tok.Set(jwt.ScpKey, []string{`read`, `write`})
+
+		if !assert.NoError(t, jwt.Validate(tok, jwt.WithRequiredScopes(`read`, `write`)), `jwt.Validate should succeed`) {
+			return
+		}
+
+		err := jwt.Validate(tok, jwt.WithRequiredScopes(`read`), jwt.WithRequiredScopes(`admin`))
+		if !assert.Error(t, err, `jwt.Validate should fail`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, jwt.ErrInsufficientScope), `error should be jwt.ErrInsufficientScope`) {
+			return
+		}
+	})
+}
+
+func TestClaimComparison(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Name     string
+		Claim    string
+		Expected string
+		Actual   string
+		Cmp      jwt.StringComparison
+		Error    bool
+	}{
+		{Name: "exact match", Claim: jwt.IssuerKey, Expected: `https://login.example.com`, Actual: `https://login.example.com`, Cmp: jwt.ExactComparison},
+		{Name: "exact mismatch (case)", Claim: jwt.IssuerKey, Expected: `https://login.example.com`, Actual: `https://Login.example.com`, Cmp: jwt.ExactComparison, Error: true},
+		{Name: "case-insensitive subject", Claim: jwt.SubjectKey, Expected: `Alice@Example.com`, Actual: `alice@example.COM`, Cmp: jwt.CaseInsensitiveComparison},
+		{Name: "case-insensitive mismatch", Claim: jwt.SubjectKey, Expected: `alice`, Actual: `alicia`, Cmp: jwt.CaseInsensitiveComparison, Error: true},
+		{Name: "URL trailing slash", Claim: jwt.IssuerKey, Expected: `https://login.example.com`, Actual: `https://login.example.com/`, Cmp: jwt.URLComparison},
+		{Name: "URL scheme and host casing", Claim: jwt.IssuerKey, Expected: `https://login.example.com/tenant`, Actual: `HTTPS://Login.Example.COM/tenant/`, Cmp: jwt.URLComparison},
+		{Name: "URL default port", Claim: jwt.AudienceKey, Expected: `https://api.example.com`, Actual: `https://api.example.com:443/`, Cmp: jwt.URLComparison},
+		{Name: "URL non-default port", Claim: jwt.AudienceKey, Expected: `https://api.example.com`, Actual: `https://api.example.com:8443`, Cmp: jwt.URLComparison, Error: true},
+		{Name: "URL path casing", Claim: jwt.IssuerKey, Expected: `https://login.example.com/tenant`, Actual: `https://login.example.com/Tenant`, Cmp: jwt.URLComparison, Error: true},
+		{Name: "URL comparison on non-URL", Claim: jwt.AudienceKey, Expected: `my-api`, Actual: `my-api/`, Cmp: jwt.URLComparison, Error: true},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			token := jwt.New()
+			if !assert.NoError(t, token.Set(tc.Claim, tc.Actual), `token.Set should succeed`) {
+				return
+			}
+
+			options := []jwt.ValidateOption{jwt.WithClaimComparison(tc.Claim, tc.Cmp)}
+			var expectedErr error
+			switch tc.Claim {
+			case jwt.IssuerKey:
+				options = append(options, jwt.WithIssuer(tc.Expected))
+				expectedErr = jwt.ErrInvalidIssuer
+			case jwt.SubjectKey:
+				options = append(options, jwt.WithSubject(tc.Expected))
+				expectedErr = jwt.ErrInvalidSubject
+			case jwt.AudienceKey:
+				options = append(options, jwt.WithAudience(tc.Expected))
+				expectedErr = jwt.ErrInvalidAudience
+			}
+
+			err := jwt.Validate(token, options...)
+			if tc.Error {
+				assert.True(t, errors.Is(err, expectedErr), `jwt.Validate should fail`)
+				return
+			}
+			assert.NoError(t, err, `jwt.Validate should succeed`)
+		})
+	}
+
+	t.Run("Comparison applies only to the given claim", func(t *testing.T) {
+		t.Parallel()
+		token := jwt.New()
+		token.Set(jwt.IssuerKey, `https://login.example.com/`)
+		token.Set(jwt.SubjectKey, `Alice`)
+
+		err := jwt.Validate(token,
+			jwt.WithIssuer(`https://login.example.com`),
+			jwt.WithSubject(`alice`),
+			jwt.WithClaimComparison(jwt.IssuerKey, jwt.URLComparison),
+		)
+		assert.True(t, errors.Is(err, jwt.ErrInvalidSubject), `jwt.Validate should fail`)
+	})
+	t.Run("Unsupported claim", func(t *testing.T) {
+		t.Parallel()
+		err := jwt.Validate(jwt.New(), jwt.WithClaimComparison(jwt.JwtIDKey, jwt.CaseInsensitiveComparison))
+		assert.Error(t, err, `jwt.Validate should fail`)
+	})
+	t.Run("Invalid comparison", func(t *testing.T) {
+		t.Parallel()
+		err := jwt.Validate(jwt.New(), jwt.WithClaimComparison(jwt.IssuerKey, jwt.StringComparison(42)))
+		assert.Error(t, err, `jwt.Validate should fail`)
+	})
 }
@@ -27,11 +27,32 @@
 package jwx
 
 import (
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/fips"
+	"github.com/lestrrat-go/jwx/internal/hook"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/keysep"
+	"github.com/lestrrat-go/jwx/internal/limits"
+	"github.com/lestrrat-go/jwx/internal/pool"
+)
+
+// NumberFormat specifies how JSON numbers are decoded when the type of
+// the destination is not known in advance, such as private claims.
+type NumberFormat int
+
+const (
+	// NumberAsFloat64 decodes numbers as float64, just like "encoding/json" does
+	NumberAsFloat64 NumberFormat = iota
+	// NumberAsJSONNumber decodes numbers as json.Number, which preserves
+	// the exact representation of the number
+	NumberAsJSONNumber
 )
 
 // DecoderSettings gives you a access to configure the "encoding/json".Decoder
 // used to decode JSON objects within the jwx framework.
+//
+// When built with the `jwx_goccy` tag, the settings are applied to the
+// "github.com/goccy/go-json".Decoder instead.
 func DecoderSettings(options ...JSONOption) {
 	// XXX We're using this format instead of just passing a single boolean
 	// in case a new option is to be added some time later
@@ -46,3 +67,51 @@ func DecoderSettings(options ...JSONOption) {
 
 	json.DecoderSettings(useNumber)
 }
+
+// Settings controls global settings of the jwx packages, such as
+// the hook set via `jwx.WithHook()`, the limits imposed on untrusted
+// input set via `jwx.WithMaxInputSize()` and friends, the key
+// separation policy set via `jwx.WithKeySeparation()`, the size of pooled
+// buffers set via `jwx.WithMaxPooledBufferSize()`, lenient base64
+// decoding set via `jwx.WithLenientBase64()`, and FIPS mode set via
+// `jwx.WithFIPSMode()`.
+//
+// Only the settings specified in the options are changed.
+func Settings(options ...GlobalOption) {
+	for _, option := range options {
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identFIPSMode{}:
+			fips.SetEnabled(option.Value().(bool))
+		case identHook{}:
+			h, _ := option.Value().(Hook)
+			hook.Set(h)
+		case identKeySeparation{}:
+			keysep.SetPolicy(option.Value().(KeySeparationPolicy))
+		case identKeySeparationTracker{}:
+			t, _ := option.Value().(*KeySeparationTracker)
+			keysep.SetTracker(t)
+		case identKeySeparationWarning{}:
+			keysep.SetWarningHandler(option.Value().(func(error)))
+		case identLenientBase64{}:
+			base64.SetLenient(option.Value().(bool))
+		case identMaxInputSize{}:
+			limits.SetMaxInputSize(option.Value().(int64))
+		case identMaxNestingDepth{}:
+			limits.SetMaxDepth(option.Value().(int))
+		case identMaxPooledBufferSize{}:
+			pool.SetMaxBufferSize(option.Value().(int))
+		case identMaxSignatures{}:
+			limits.SetMaxSignatures(option.Value().(int))
+		case identMaxRecipients{}:
+			limits.SetMaxRecipients(option.Value().(int))
+		case identMaxHeaderCount{}:
+			limits.SetMaxHeaderCount(option.Value().(int))
+		}
+	}
+}
+
+// FIPSMode returns true if FIPS mode is enabled. See `jwx.WithFIPSMode()`
+func FIPSMode() bool {
+	return fips.Enabled()
+}
@@ -1,10 +1,13 @@
 package jwx_test
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -16,6 +19,9 @@ import (
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -85,6 +91,571 @@ func TestDecoderSetting(t *testing.T) {
 	}
 }
 
+func TestHook(t *testing.T) {
+	// DO NOT MAKE THIS TEST PARALLEL. This test uses features with global side effects
+	var events []jwx.Event
+	jwx.Settings(jwx.WithHook(jwx.HookFunc(func(ev jwx.Event) {
+		events = append(events, ev)
+	})))
+	t.Cleanup(func() {
+		jwx.Settings(jwx.WithHook(nil))
+	})
+
+	key, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, `my-key`)
+
+	pubkey, err := jwk.PublicKeyOf(key)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pubkey)
+	}))
+	defer srv.Close()
+
+	testcases := []struct {
+		Name      string
+		Run       func() error
+		Kind      jwx.EventKind
+		Algorithm string
+		KeyID     string
+		URL       string
+		Error     bool
+	}{
+		{
+			Name: "jwk.Fetch",
+			Run: func() error {
+				_, err := jwk.Fetch(context.Background(), srv.URL)
+				return err
+			},
+			Kind: jwx.FetchEvent,
+			URL:  srv.URL,
+		},
+		{
+			Name: "jws.Sign",
+			Run: func() error {
+				_, err := jws.Sign([]byte(`Lorem ipsum`), jwa.RS256, key)
+				return err
+			},
+			Kind:      jwx.SignEvent,
+			Algorithm: `RS256`,
+			KeyID:     `my-key`,
+		},
+		{
+			Name: "jws.Verify (failure)",
+			Run: func() error {
+				signed, err := jws.Sign([]byte(`Lorem ipsum`), jwa.RS256, key)
+				if err != nil {
+					return err
+				}
+				events = events[:0]
+				_, err = jws.Verify(signed, jwa.RS384, pubkey)
+				return err
+			},
+			Kind:      jwx.VerifyEvent,
+			Algorithm: `RS384`,
+			KeyID:     `my-key`,
+			Error:     true,
+		},
+		{
+			Name: "jwe.Encrypt",
+			Run: func() error {
+				_, err := jwe.Encrypt([]byte(`Lorem ipsum`), jwa.RSA_OAEP, pubkey, jwa.A128GCM, jwa.NoCompress)
+				return err
+			},
+			Kind:      jwx.EncryptEvent,
+			Algorithm: `RSA-OAEP`,
+			KeyID:     `my-key`,
+		},
+		{
+			Name: "jwe.Decrypt",
+			Run: func() error {
+				encrypted, err := jwe.Encrypt([]byte(`Lorem ipsum`), jwa.RSA_OAEP, pubkey, jwa.A128GCM, jwa.NoCompress)
+				if err != nil {
+					return err
+				}
+				events = events[:0]
+				_, err = jwe.Decrypt(encrypted, jwa.RSA_OAEP, key)
+				return err
+			},
+			Kind:      jwx.DecryptEvent,
+			Algorithm: `RSA-OAEP`,
+			KeyID:     `my-key`,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			events = events[:0]
+			err := tc.Run()
+			if tc.Error {
+				if !assert.Error(t, err, `operation should fail`) {
+					return
+				}
+			} else {
+				if !assert.NoError(t, err, `operation should succeed`) {
+					return
+				}
+			}
+
+			if !assert.Len(t, events, 1, `there should be exactly one event`) {
+				return
+			}
+			ev := events[0]
+			if !assert.Equal(t, tc.Kind, ev.Kind, `event kind should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.Algorithm, ev.Algorithm, `algorithm should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.KeyID, ev.KeyID, `key ID should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.URL, ev.URL, `URL should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.Error, ev.Err != nil, `error should be reported`) {
+				return
+			}
+			if !assert.False(t, ev.Start.IsZero(), `start time should be set`) {
+				return
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	// DO NOT MAKE THIS TEST PARALLEL. This test uses features with global side effects
+	key := jwxtest.GenerateSymmetricKey()
+
+	t.Run("WithMaxInputSize", func(t *testing.T) {
+		signed, err := jws.Sign([]byte(`Lorem ipsum`), jwa.HS256, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		jwx.Settings(jwx.WithMaxInputSize(int64(len(signed) - 1)))
+		defer jwx.Settings(jwx.WithMaxInputSize(0))
+
+		_, err = jws.Parse(signed)
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jws.Parse should fail`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.HS256, key)
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jws.Verify should fail`) {
+			return
+		}
+		_, err = jws.ParseReader(bytes.NewReader(signed))
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jws.ParseReader should fail`) {
+			return
+		}
+		_, err = jwt.ParseInsecure(signed)
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jwt.ParseInsecure should fail`) {
+			return
+		}
+
+		jwx.Settings(jwx.WithMaxInputSize(int64(len(signed))))
+		if _, err := jws.Parse(signed); !assert.NoError(t, err, `jws.Parse should succeed`) {
+			return
+		}
+	})
+	t.Run("WithMaxNestingDepth", func(t *testing.T) {
+		jwx.Settings(jwx.WithMaxNestingDepth(2))
+		defer jwx.Settings(jwx.WithMaxNestingDepth(0))
+
+		_, err := jwk.ParseKey([]byte(`{"kty":"oct","k":"AAAA","x-nested":{"foo":{"bar":1}}}`))
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jwk.ParseKey should fail`) {
+			return
+		}
+		if _, err := jwk.ParseKey([]byte(`{"kty":"oct","k":"AAAA","x-nested":{"foo":1}}`)); !assert.NoError(t, err, `jwk.ParseKey should succeed`) {
+			return
+		}
+
+		_, err = jwt.ParseInsecure([]byte(`{"x-nested":{"foo":{"bar":1}}}`))
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jwt.ParseInsecure should fail`) {
+			return
+		}
+	})
+	t.Run("WithMaxSignatures", func(t *testing.T) {
+		var options []jws.Option
+		for i := 0; i < 3; i++ {
+			signer, err := jws.NewSigner(jwa.HS256)
+			if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+				return
+			}
+			options = append(options, jws.WithSigner(signer, key, nil, nil))
+		}
+		signed, err := jws.SignMulti([]byte(`Lorem ipsum`), options...)
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		jwx.Settings(jwx.WithMaxSignatures(2))
+		defer jwx.Settings(jwx.WithMaxSignatures(0))
+
+		_, err = jws.Parse(signed)
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jws.Parse should fail`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.HS256, key)
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("WithMaxRecipients", func(t *testing.T) {
+		var options []jwe.EncryptOption
+		for i := 0; i < 3; i++ {
+			options = append(options, jwe.WithRecipient(jwa.A128KW, jwxtest.GenerateSymmetricKey()[:16]))
+		}
+		encrypted, err := jwe.EncryptMulti([]byte(`Lorem ipsum`), jwa.A128GCM, jwa.NoCompress, options...)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		jwx.Settings(jwx.WithMaxRecipients(2))
+		defer jwx.Settings(jwx.WithMaxRecipients(0))
+
+		_, err = jwe.Parse(encrypted)
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jwe.Parse should fail`) {
+			return
+		}
+	})
+	t.Run("WithMaxHeaderCount", func(t *testing.T) {
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(`x-foo`, `foo`)
+		_ = hdrs.Set(`x-bar`, `bar`)
+		signed, err := jws.Sign([]byte(`Lorem ipsum`), jwa.HS256, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		// "alg", "x-foo", and "x-bar"
+		jwx.Settings(jwx.WithMaxHeaderCount(2))
+		defer jwx.Settings(jwx.WithMaxHeaderCount(0))
+
+		_, err = jws.Parse(signed)
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jws.Parse should fail`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.HS256, key)
+		if !assert.True(t, errors.Is(err, jwx.ErrParse), `jws.Verify should fail`) {
+			return
+		}
+
+		jwx.Settings(jwx.WithMaxHeaderCount(3))
+		if _, err := jws.Verify(signed, jwa.HS256, key); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+	})
+}
+
+func TestMaxPooledBufferSize(t *testing.T) {
+	key := jwxtest.GenerateSymmetricKey()
+	payload := bytes.Repeat([]byte{'a'}, 256*1024)
+
+	for _, size := range []int{0, 1024, 1024 * 1024} {
+		size := size
+		t.Run(fmt.Sprintf("%d", size), func(t *testing.T) {
+			jwx.Settings(jwx.WithMaxPooledBufferSize(size))
+			defer jwx.Settings(jwx.WithMaxPooledBufferSize(64 * 1024))
+
+			for i := 0; i < 3; i++ {
+				signed, err := jws.Sign(payload, jwa.HS256, key)
+				if !assert.NoError(t, err, `jws.Sign should succeed`) {
+					return
+				}
+
+				verified, err := jws.Verify(signed, jwa.HS256, key)
+				if !assert.NoError(t, err, `jws.Verify should succeed`) {
+					return
+				}
+				if !assert.Equal(t, payload, verified, `payload should match`) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestKeySeparation(t *testing.T) {
+	// DO NOT MAKE THIS TEST PARALLEL. This test uses features with global side effects
+	defer jwx.Settings(
+		jwx.WithKeySeparation(jwx.KeySeparationIgnore),
+		jwx.WithKeySeparationTracker(nil),
+		jwx.WithKeySeparationWarning(nil),
+	)
+
+	t.Run("Enforce", func(t *testing.T) {
+		jwx.Settings(
+			jwx.WithKeySeparation(jwx.KeySeparationEnforce),
+			jwx.WithKeySeparationTracker(jwx.NewKeySeparationTracker()),
+		)
+
+		key, err := jwxtest.GenerateRsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+			return
+		}
+
+		signed, err := jws.Sign([]byte(`Lorem ipsum`), jwa.RS256, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(signed, jwa.RS256, &key.PublicKey); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+
+		_, err = jwe.Encrypt([]byte(`Lorem ipsum`), jwa.RSA_OAEP, &key.PublicKey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.True(t, errors.Is(err, jwx.ErrKeySeparation), `jwe.Encrypt should fail`) {
+			return
+		}
+
+		encKey, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+			return
+		}
+		_ = encKey.Set(jwk.KeyUsageKey, jwk.ForEncryption)
+		_, err = jws.Sign([]byte(`Lorem ipsum`), jwa.HS256, encKey)
+		if !assert.True(t, errors.Is(err, jwx.ErrKeySeparation), `jws.Sign should fail`) {
+			return
+		}
+	})
+	t.Run("Warn", func(t *testing.T) {
+		var warnings []error
+		jwx.Settings(
+			jwx.WithKeySeparation(jwx.KeySeparationWarn),
+			jwx.WithKeySeparationTracker(jwx.NewKeySeparationTracker()),
+			jwx.WithKeySeparationWarning(func(err error) { warnings = append(warnings, err) }),
+		)
+
+		key := jwxtest.GenerateSymmetricKey()
+		if _, err := jws.Sign([]byte(`Lorem ipsum`), jwa.HS256, key); !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if _, err := jwe.Encrypt([]byte(`Lorem ipsum`), jwa.A128KW, key[:16], jwa.A128GCM, jwa.NoCompress); !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		if !assert.Len(t, warnings, 0, `using different keys should not warn`) {
+			return
+		}
+
+		if _, err := jwe.Encrypt([]byte(`Lorem ipsum`), jwa.DIRECT, key, jwa.A256CBC_HS512, jwa.NoCompress); !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		if !assert.Len(t, warnings, 1, `reusing the key should warn`) {
+			return
+		}
+		if !assert.True(t, errors.Is(warnings[0], jwx.ErrKeySeparation), `warning should match jwx.ErrKeySeparation`) {
+			return
+		}
+	})
+	t.Run("Tracker", func(t *testing.T) {
+		tracker := jwx.NewKeySeparationTracker()
+		jwx.Settings(
+			jwx.WithKeySeparation(jwx.KeySeparationEnforce),
+			jwx.WithKeySeparationTracker(tracker),
+		)
+
+		key := jwxtest.GenerateSymmetricKey()
+		if _, err := jws.Sign([]byte(`Lorem ipsum`), jwa.HS256, key); !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1, tracker.Len(), `tracker should record the key`) {
+			return
+		}
+
+		// the record is discarded by Reset
+		tracker.Reset()
+		if !assert.Equal(t, 0, tracker.Len(), `tracker should be empty`) {
+			return
+		}
+		if _, err := jwe.Encrypt([]byte(`Lorem ipsum`), jwa.DIRECT, key, jwa.A256CBC_HS512, jwa.NoCompress); !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		// without a tracker, nothing is recorded
+		jwx.Settings(jwx.WithKeySeparationTracker(nil))
+		if _, err := jws.Sign([]byte(`Lorem ipsum`), jwa.HS256, key); !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1, tracker.Len(), `tracker should not be used after it has been replaced`) {
+			return
+		}
+	})
+	t.Run("Ignore", func(t *testing.T) {
+		jwx.Settings(jwx.WithKeySeparation(jwx.KeySeparationIgnore))
+
+		key := jwxtest.GenerateSymmetricKey()
+		if _, err := jws.Sign([]byte(`Lorem ipsum`), jwa.HS256, key); !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if _, err := jwe.Encrypt([]byte(`Lorem ipsum`), jwa.DIRECT, key, jwa.A256CBC_HS512, jwa.NoCompress); !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+	})
+	t.Run("jwk.CheckKeySeparation", func(t *testing.T) {
+		raw := jwxtest.GenerateSymmetricKey()
+		set := jwk.NewSet()
+		var keys []jwk.Key
+		for _, use := range []jwk.KeyUsageType{jwk.ForSignature, jwk.ForEncryption} {
+			key, err := jwk.New(raw)
+			if !assert.NoError(t, err, `jwk.New should succeed`) {
+				return
+			}
+			_ = key.Set(jwk.KeyUsageKey, use)
+			set.Add(key)
+			keys = append(keys, key)
+		}
+		if !assert.True(t, errors.Is(jwk.CheckKeySeparation(set), jwx.ErrKeySeparation), `jwk.CheckKeySeparation should fail`) {
+			return
+		}
+
+		set.Remove(keys[1])
+		other, err := jwxtest.GenerateSymmetricJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateSymmetricJwk should succeed`) {
+			return
+		}
+		_ = other.Set(jwk.KeyUsageKey, jwk.ForEncryption)
+		set.Add(other)
+		if !assert.NoError(t, jwk.CheckKeySeparation(set), `jwk.CheckKeySeparation should succeed`) {
+			return
+		}
+	})
+}
+
+func TestFIPSMode(t *testing.T) {
+	// DO NOT MAKE THIS TEST PARALLEL. This test uses features with global side effects
+	defer jwx.Settings(jwx.WithFIPSMode(jwx.FIPSMode()))
+
+	payload := []byte(`Lorem ipsum`)
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	x25519key, err := jwxtest.GenerateX25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateX25519Key should succeed`) {
+		return
+	}
+	shortKey := []byte(`short`)
+
+	// messages created before FIPS mode was enabled
+	jwx.Settings(jwx.WithFIPSMode(false))
+	hsShort, err := jws.Sign(payload, jwa.HS256, shortKey)
+	if !assert.NoError(t, err, `jws.Sign should succeed`) {
+		return
+	}
+	rsa15, err := jwe.Encrypt(payload, jwa.RSA1_5, &rsakey.PublicKey, jwa.A128GCM, jwa.NoCompress)
+	if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+		return
+	}
+
+	jwx.Settings(jwx.WithFIPSMode(true))
+	if !assert.True(t, jwx.FIPSMode(), `jwx.FIPSMode should be true`) {
+		return
+	}
+
+	t.Run("Approved", func(t *testing.T) {
+		signed, err := jws.Sign(payload, jwa.RS256, rsakey)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(signed, jwa.RS256, &rsakey.PublicKey); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+
+		hmackey := jwxtest.GenerateSymmetricKey()
+		if _, err := jws.SignCompactHS(nil, jwa.HS256, hmackey, payload); !assert.NoError(t, err, `jws.SignCompactHS should succeed`) {
+			return
+		}
+
+		encrypted, err := jwe.Encrypt(payload, jwa.RSA_OAEP, &rsakey.PublicKey, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		decrypted, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.Equal(t, payload, decrypted, `payloads should match`) {
+			return
+		}
+	})
+	t.Run("Not approved", func(t *testing.T) {
+		testcases := []struct {
+			Name string
+			Func func() error
+		}{
+			{
+				Name: `jws.Sign with "none"`,
+				Func: func() error {
+					_, err := jws.Sign(payload, jwa.NoSignature, nil)
+					return err
+				},
+			},
+			{
+				Name: `jws.Sign with a short HMAC key`,
+				Func: func() error {
+					_, err := jws.Sign(payload, jwa.HS256, shortKey)
+					return err
+				},
+			},
+			{
+				Name: `jws.SignCompactHS with a short HMAC key`,
+				Func: func() error {
+					_, err := jws.SignCompactHS(nil, jwa.HS256, shortKey, payload)
+					return err
+				},
+			},
+			{
+				Name: `jws.Verify with a short HMAC key`,
+				Func: func() error {
+					_, err := jws.Verify(hsShort, jwa.HS256, shortKey)
+					return err
+				},
+			},
+			{
+				Name: `jwe.Encrypt with RSA1_5`,
+				Func: func() error {
+					_, err := jwe.Encrypt(payload, jwa.RSA1_5, &rsakey.PublicKey, jwa.A128GCM, jwa.NoCompress)
+					return err
+				},
+			},
+			{
+				Name: `jwe.Decrypt with RSA1_5`,
+				Func: func() error {
+					_, err := jwe.Decrypt(rsa15, jwa.RSA1_5, rsakey)
+					return err
+				},
+			},
+			{
+				Name: `jwe.Encrypt with X25519`,
+				Func: func() error {
+					_, err := jwe.Encrypt(payload, jwa.ECDH_ES, x25519key.Public(), jwa.A128GCM, jwa.NoCompress)
+					return err
+				},
+			},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				err := tc.Func()
+				if !assert.True(t, errors.Is(err, jwx.ErrNotFIPSApproved), `error should match jwx.ErrNotFIPSApproved (got %v)`, err) {
+					return
+				}
+				var ferr *jwx.FIPSError
+				if !assert.True(t, errors.As(err, &ferr), `error should be a *jwx.FIPSError`) {
+					return
+				}
+			})
+		}
+	})
+}
+
 // Test compatibility against `jose` tool
 func TestJoseCompatibility(t *testing.T) {
 	t.Parallel()
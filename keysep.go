@@ -0,0 +1,29 @@
+package jwx
+
+import "github.com/lestrrat-go/jwx/internal/keysep"
+
+// KeySeparationPolicy specifies what happens when a key is used both
+// for signatures and for encryption. See `jwx.WithKeySeparation()`
+type KeySeparationPolicy = keysep.Policy
+
+const (
+	// KeySeparationIgnore disables key separation checks
+	KeySeparationIgnore KeySeparationPolicy = keysep.Ignore
+	// KeySeparationWarn reports violations, but lets the operation proceed
+	KeySeparationWarn KeySeparationPolicy = keysep.Warn
+	// KeySeparationEnforce makes the operation fail with an error
+	// matching `jwx.ErrKeySeparation`
+	KeySeparationEnforce KeySeparationPolicy = keysep.Enforce
+)
+
+// KeySeparationTracker records the keys used for signatures and for
+// encryption, so that a key used for both can be detected. It is
+// created by the user, who controls how long it lives: the record
+// grows with the number of distinct keys used until `Reset()` is
+// called. See `jwx.WithKeySeparationTracker()`
+type KeySeparationTracker = keysep.Tracker
+
+// NewKeySeparationTracker creates a new, empty KeySeparationTracker
+func NewKeySeparationTracker() *KeySeparationTracker {
+	return keysep.NewTracker()
+}
@@ -0,0 +1,213 @@
+// Package openidfed implements trust chain resolution for OpenID
+// Federation (https://openid.net/specs/openid-federation-1_0.html).
+//
+// A `openidfed.Resolver` fetches the Entity Configuration of an entity,
+// follows its "authority_hints" upwards, fetches the Subordinate Statements
+// issued about each entity by its superior, and verifies the resulting
+// trust chain against the configured Trust Anchors:
+//
+//   r := openidfed.NewResolver(
+//     openidfed.WithTrustAnchor(`https://ta.example.com`, anchorKeys),
+//   )
+//   entity, err := r.Resolve(ctx, `https://op.example.com`)
+//
+// The resolved entity carries the federation keys of the entity as
+// attested by its superior, and its metadata. Metadata policies are
+// not applied.
+package openidfed
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+)
+
+const (
+	// WellKnownPath is the path, relative to the entity identifier,
+	// where the Entity Configuration of an entity is published
+	WellKnownPath = `/.well-known/openid-federation`
+
+	// EntityStatementType is the value of the "typ" header of
+	// Entity Statements
+	EntityStatementType = `entity-statement+jwt`
+
+	// SignedJWKSType is the value of the "typ" header of signed
+	// JWK sets published at "signed_jwks_uri"
+	SignedJWKSType = `jwk-set+jwt`
+)
+
+// Claims specific to Entity Statements
+const (
+	JWKSKey           = `jwks`
+	AuthorityHintsKey = `authority_hints`
+	MetadataKey       = `metadata`
+)
+
+// Entity types, as used in the "metadata" claim
+const (
+	FederationEntity   = `federation_entity`
+	OpenIDProvider     = `openid_provider`
+	OpenIDRelyingParty = `openid_relying_party`
+)
+
+// EntityStatement is a verified Entity Statement. If the issuer and
+// the subject are the same entity, it is an Entity Configuration,
+// otherwise it is a Subordinate Statement.
+type EntityStatement struct {
+	Issuer         string
+	Subject        string
+	IssuedAt       time.Time
+	Expiration     time.Time
+	Keys           jwk.Set
+	AuthorityHints []string
+	Metadata       map[string]interface{}
+
+	// Raw is the serialized form of the statement
+	Raw []byte
+}
+
+// IsConfiguration returns true if the statement is an Entity Configuration
+func (s *EntityStatement) IsConfiguration() bool {
+	return s.Issuer == s.Subject
+}
+
+// EntityMetadata returns the metadata for the given entity type
+// (e.g. `openidfed.OpenIDProvider`)
+func (s *EntityStatement) EntityMetadata(typ string) (map[string]interface{}, bool) {
+	v, ok := s.Metadata[typ].(map[string]interface{})
+	return v, ok
+}
+
+// ParseEntityStatement verifies the Entity Statement in `src` against
+// `keys`, validates its claims, and returns its contents. If `keys` is
+// nil, the statement must be an Entity Configuration, and it is verified
+// against the keys in its own "jwks" claim. Note that a self-signed
+// Entity Configuration is not trusted until it is verified as part of
+// a trust chain.
+//
+// The options are passed to `jwt.Validate()`.
+func ParseEntityStatement(src []byte, keys jwk.Set, options ...jwt.ValidateOption) (*EntityStatement, error) {
+	unverified, err := jwt.ParseInsecure(src)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse entity statement`)
+	}
+
+	stmt, err := newEntityStatement(unverified)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Raw = src
+
+	if keys == nil {
+		if !stmt.IsConfiguration() {
+			return nil, errors.New(`keys must be specified to verify subordinate statements`)
+		}
+		keys = stmt.Keys
+	}
+
+	if _, err := verifySigned(src, keys, EntityStatementType); err != nil {
+		return nil, errors.Wrap(err, `failed to verify entity statement`)
+	}
+
+	validateOptions := append([]jwt.ValidateOption{
+		jwt.WithRequiredClaim(jwt.IssuedAtKey),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+	}, options...)
+	if err := jwt.Validate(unverified, validateOptions...); err != nil {
+		return nil, errors.Wrap(err, `failed to validate entity statement`)
+	}
+	return stmt, nil
+}
+
+func newEntityStatement(tok jwt.Token) (*EntityStatement, error) {
+	stmt := &EntityStatement{
+		Issuer:     tok.Issuer(),
+		Subject:    tok.Subject(),
+		IssuedAt:   tok.IssuedAt(),
+		Expiration: tok.Expiration(),
+	}
+	if stmt.Issuer == "" || stmt.Subject == "" {
+		return nil, errors.New(`entity statement must contain "iss" and "sub"`)
+	}
+
+	v, ok := tok.Get(JWKSKey)
+	if !ok {
+		return nil, errors.Errorf(`entity statement must contain %q`, JWKSKey)
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to marshal %q`, JWKSKey)
+	}
+	keys, err := jwk.Parse(buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to parse %q`, JWKSKey)
+	}
+	stmt.Keys = keys
+
+	if v, ok := tok.Get(AuthorityHintsKey); ok {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, errors.Errorf(`invalid %q: expected a list`, AuthorityHintsKey)
+		}
+		for _, hint := range list {
+			s, ok := hint.(string)
+			if !ok {
+				return nil, errors.Errorf(`invalid %q: expected a list of strings`, AuthorityHintsKey)
+			}
+			stmt.AuthorityHints = append(stmt.AuthorityHints, s)
+		}
+	}
+
+	if v, ok := tok.Get(MetadataKey); ok {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf(`invalid %q: expected an object`, MetadataKey)
+		}
+		stmt.Metadata = m
+	}
+	return stmt, nil
+}
+
+// verifySigned verifies the signature of a signed document such as an
+// Entity Statement, and returns its payload. The key is selected using
+// the "kid" header, which is mandatory for federation documents. Only
+// asymmetric algorithms are accepted.
+func verifySigned(src []byte, keys jwk.Set, typ string) ([]byte, error) {
+	msg, err := jws.Parse(src)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse JWS message`)
+	}
+	if len(msg.Signatures()) != 1 {
+		return nil, errors.New(`message must have exactly one signature`)
+	}
+
+	hdrs := msg.Signatures()[0].ProtectedHeaders()
+	if v := hdrs.Type(); v != typ {
+		return nil, errors.Errorf(`invalid "typ" header %q: expected %q`, v, typ)
+	}
+
+	alg := hdrs.Algorithm()
+	switch alg {
+	case jwa.NoSignature, jwa.HS256, jwa.HS384, jwa.HS512, "":
+		return nil, errors.Errorf(`algorithm %q is not allowed`, alg)
+	}
+
+	kid := hdrs.KeyID()
+	if kid == "" {
+		return nil, errors.New(`message does not specify a "kid"`)
+	}
+	key, ok := keys.LookupKeyID(kid)
+	if !ok {
+		return nil, errors.Errorf(`key %q was not found`, kid)
+	}
+	if v := key.Algorithm(); v != "" && v != alg.String() {
+		return nil, errors.Errorf(`key %q cannot be used with algorithm %q`, kid, alg)
+	}
+
+	return jws.Verify(src, alg, key)
+}
@@ -0,0 +1,465 @@
+package openidfed_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/openidfed"
+	"github.com/stretchr/testify/assert"
+)
+
+type testEntity struct {
+	id       string
+	key      jwk.Key
+	hints    []string
+	metadata map[string]interface{}
+}
+
+func newTestEntity(t *testing.T, id string) *testEntity {
+	t.Helper()
+	raw, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `GenerateEcdsaKey should succeed`) {
+		t.FailNow()
+	}
+	key, err := jwk.New(raw)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		t.FailNow()
+	}
+	_ = key.Set(jwk.KeyIDKey, id+`#key`)
+	_ = key.Set(jwk.AlgorithmKey, jwa.ES256)
+	return &testEntity{id: id, key: key}
+}
+
+func (e *testEntity) publicKeys(t *testing.T) jwk.Set {
+	t.Helper()
+	set := jwk.NewSet()
+	set.Add(e.key)
+	pub, err := jwk.PublicSetOf(set)
+	if !assert.NoError(t, err, `jwk.PublicSetOf should succeed`) {
+		t.FailNow()
+	}
+	return pub
+}
+
+func signDocument(t *testing.T, signer jwk.Key, typ string, claims map[string]interface{}) []byte {
+	t.Helper()
+	tok := jwt.New()
+	for k, v := range claims {
+		if !assert.NoError(t, tok.Set(k, v), `tok.Set should succeed`) {
+			t.FailNow()
+		}
+	}
+	hdrs := jws.NewHeaders()
+	_ = hdrs.Set(jws.TypeKey, typ)
+	signed, err := jwt.Sign(tok, jwa.ES256, signer, jwt.WithHeaders(hdrs))
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		t.FailNow()
+	}
+	return signed
+}
+
+// testFederation serves the Entity Configurations and fetch endpoints
+// of a set of entities, all hosted under the same httptest.Server
+type testFederation struct {
+	t        *testing.T
+	srv      *httptest.Server
+	entities map[string]*testEntity
+	// subordinate keys override the keys that a superior attests to
+	subordinateKeys map[string]jwk.Set
+	exp             time.Time
+	fetches         int64
+}
+
+func newTestFederation(t *testing.T) *testFederation {
+	f := &testFederation{
+		t:               t,
+		entities:        make(map[string]*testEntity),
+		subordinateKeys: make(map[string]jwk.Set),
+		exp:             time.Now().Add(time.Hour),
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+func (f *testFederation) URL(name string) string {
+	return f.srv.URL + `/` + name
+}
+
+func (f *testFederation) Add(name string, hints ...string) *testEntity {
+	e := newTestEntity(f.t, f.URL(name))
+	for _, hint := range hints {
+		e.hints = append(e.hints, f.URL(hint))
+	}
+	e.metadata = map[string]interface{}{
+		openidfed.FederationEntity: map[string]interface{}{
+			`federation_fetch_endpoint`: e.id + `/fetch`,
+		},
+	}
+	f.entities[e.id] = e
+	return e
+}
+
+func (f *testFederation) claims(iss, sub string, keys jwk.Set) map[string]interface{} {
+	return map[string]interface{}{
+		jwt.IssuerKey:     iss,
+		jwt.SubjectKey:    sub,
+		jwt.IssuedAtKey:   time.Now().Add(-time.Minute),
+		jwt.ExpirationKey: f.exp,
+		openidfed.JWKSKey: keys,
+	}
+}
+
+func (f *testFederation) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&f.fetches, 1)
+	switch {
+	case strings.HasSuffix(r.URL.Path, openidfed.WellKnownPath):
+		e, ok := f.entities[f.srv.URL+strings.TrimSuffix(r.URL.Path, openidfed.WellKnownPath)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		claims := f.claims(e.id, e.id, e.publicKeys(f.t))
+		if len(e.hints) > 0 {
+			claims[openidfed.AuthorityHintsKey] = e.hints
+		}
+		claims[openidfed.MetadataKey] = e.metadata
+		w.Header().Set(`Content-Type`, `application/entity-statement+jwt`)
+		_, _ = w.Write(signDocument(f.t, e.key, openidfed.EntityStatementType, claims))
+	case strings.HasSuffix(r.URL.Path, `/fetch`):
+		superior, ok := f.entities[f.srv.URL+strings.TrimSuffix(r.URL.Path, `/fetch`)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		sub, ok := f.entities[r.URL.Query().Get(`sub`)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		keys, ok := f.subordinateKeys[sub.id]
+		if !ok {
+			keys = sub.publicKeys(f.t)
+		}
+		w.Header().Set(`Content-Type`, `application/entity-statement+jwt`)
+		_, _ = w.Write(signDocument(f.t, superior.key, openidfed.EntityStatementType, f.claims(superior.id, sub.id, keys)))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Resolve via intermediate", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		f := newTestFederation(t)
+		defer f.srv.Close()
+
+		ta := f.Add(`ta`)
+		f.Add(`ia`, `ta`)
+		leaf := f.Add(`leaf`, `ia`)
+		leaf.metadata[openidfed.OpenIDProvider] = map[string]interface{}{
+			`issuer`: leaf.id,
+		}
+
+		r := openidfed.NewResolver(openidfed.WithTrustAnchor(ta.id, ta.publicKeys(t)))
+		e, err := r.Resolve(ctx, leaf.id)
+		if !assert.NoError(t, err, `r.Resolve should succeed`) {
+			return
+		}
+		if !assert.Equal(t, leaf.id, e.EntityID, `entity IDs should match`) {
+			return
+		}
+		if !assert.Equal(t, ta.id, e.TrustAnchor, `trust anchors should match`) {
+			return
+		}
+		if !assert.Len(t, e.Chain, 3, `chain should contain 3 statements`) {
+			return
+		}
+		if !assert.Equal(t, f.URL(`ia`), e.Chain[1].Issuer, `second statement should be issued by the intermediate`) {
+			return
+		}
+		if _, ok := e.Keys.LookupKeyID(leaf.key.KeyID()); !assert.True(t, ok, `keys should contain the leaf's key`) {
+			return
+		}
+		md, ok := e.EntityMetadata(openidfed.OpenIDProvider)
+		if !assert.True(t, ok, `metadata should contain openid_provider`) {
+			return
+		}
+		if !assert.Equal(t, leaf.id, md[`issuer`], `issuer should match`) {
+			return
+		}
+
+		// Second call should be served from the cache
+		fetches := atomic.LoadInt64(&f.fetches)
+		if _, err := r.Resolve(ctx, leaf.id); !assert.NoError(t, err, `r.Resolve should succeed`) {
+			return
+		}
+		if !assert.Equal(t, fetches, atomic.LoadInt64(&f.fetches), `no documents should be fetched`) {
+			return
+		}
+	})
+	t.Run("Resolve trust anchor", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		f := newTestFederation(t)
+		defer f.srv.Close()
+
+		ta := f.Add(`ta`)
+		r := openidfed.NewResolver(openidfed.WithTrustAnchor(ta.id, ta.publicKeys(t)))
+		e, err := r.Resolve(ctx, ta.id)
+		if !assert.NoError(t, err, `r.Resolve should succeed`) {
+			return
+		}
+		if !assert.Len(t, e.Chain, 1, `chain should contain 1 statement`) {
+			return
+		}
+	})
+	t.Run("Unknown trust anchor", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		f := newTestFederation(t)
+		defer f.srv.Close()
+
+		f.Add(`ta`)
+		other := f.Add(`other`)
+		leaf := f.Add(`leaf`, `ta`)
+
+		r := openidfed.NewResolver(openidfed.WithTrustAnchor(other.id, other.publicKeys(t)))
+		_, err := r.Resolve(ctx, leaf.id)
+		if !assert.Error(t, err, `r.Resolve should fail`) {
+			return
+		}
+	})
+	t.Run("Trust anchor key mismatch", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		f := newTestFederation(t)
+		defer f.srv.Close()
+
+		ta := f.Add(`ta`)
+		leaf := f.Add(`leaf`, `ta`)
+
+		// configured keys do not match the keys the anchor signs with
+		impostor := newTestEntity(t, ta.id)
+		r := openidfed.NewResolver(openidfed.WithTrustAnchor(ta.id, impostor.publicKeys(t)))
+		_, err := r.Resolve(ctx, leaf.id)
+		if !assert.Error(t, err, `r.Resolve should fail`) {
+			return
+		}
+	})
+	t.Run("Entity configuration not signed by attested key", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		f := newTestFederation(t)
+		defer f.srv.Close()
+
+		ta := f.Add(`ta`)
+		leaf := f.Add(`leaf`, `ta`)
+
+		other := newTestEntity(t, leaf.id)
+		f.subordinateKeys[leaf.id] = other.publicKeys(t)
+
+		r := openidfed.NewResolver(openidfed.WithTrustAnchor(ta.id, ta.publicKeys(t)))
+		_, err := r.Resolve(ctx, leaf.id)
+		if !assert.Error(t, err, `r.Resolve should fail`) {
+			return
+		}
+	})
+	t.Run("Maximum path length", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		f := newTestFederation(t)
+		defer f.srv.Close()
+
+		ta := f.Add(`ta`)
+		f.Add(`ia1`, `ta`)
+		f.Add(`ia2`, `ia1`)
+		leaf := f.Add(`leaf`, `ia2`)
+
+		r := openidfed.NewResolver(
+			openidfed.WithTrustAnchor(ta.id, ta.publicKeys(t)),
+			openidfed.WithMaxPathLength(2),
+		)
+		_, err := r.Resolve(ctx, leaf.id)
+		if !assert.Error(t, err, `r.Resolve should fail`) {
+			return
+		}
+
+		r = openidfed.NewResolver(
+			openidfed.WithTrustAnchor(ta.id, ta.publicKeys(t)),
+			openidfed.WithMaxPathLength(3),
+		)
+		_, err = r.Resolve(ctx, leaf.id)
+		if !assert.NoError(t, err, `r.Resolve should succeed`) {
+			return
+		}
+	})
+	t.Run("Expired statements", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		f := newTestFederation(t)
+		defer f.srv.Close()
+
+		ta := f.Add(`ta`)
+		leaf := f.Add(`leaf`, `ta`)
+
+		clock := jwt.ClockFunc(func() time.Time { return f.exp.Add(time.Hour) })
+		r := openidfed.NewResolver(
+			openidfed.WithTrustAnchor(ta.id, ta.publicKeys(t)),
+			openidfed.WithClock(clock),
+		)
+		_, err := r.Resolve(ctx, leaf.id)
+		if !assert.Error(t, err, `r.Resolve should fail`) {
+			return
+		}
+	})
+}
+
+func TestParseEntityStatement(t *testing.T) {
+	t.Parallel()
+
+	e := newTestEntity(t, `https://example.com`)
+	claims := map[string]interface{}{
+		jwt.IssuerKey:     e.id,
+		jwt.SubjectKey:    e.id,
+		jwt.IssuedAtKey:   time.Now(),
+		jwt.ExpirationKey: time.Now().Add(time.Hour),
+		openidfed.JWKSKey: e.publicKeys(t),
+	}
+
+	t.Run("Entity configuration", func(t *testing.T) {
+		t.Parallel()
+		signed := signDocument(t, e.key, openidfed.EntityStatementType, claims)
+		stmt, err := openidfed.ParseEntityStatement(signed, nil)
+		if !assert.NoError(t, err, `openidfed.ParseEntityStatement should succeed`) {
+			return
+		}
+		if !assert.True(t, stmt.IsConfiguration(), `statement should be an entity configuration`) {
+			return
+		}
+	})
+	t.Run("Wrong typ", func(t *testing.T) {
+		t.Parallel()
+		signed := signDocument(t, e.key, `JWT`, claims)
+		_, err := openidfed.ParseEntityStatement(signed, nil)
+		if !assert.Error(t, err, `openidfed.ParseEntityStatement should fail`) {
+			return
+		}
+	})
+	t.Run("Subordinate statement without keys", func(t *testing.T) {
+		t.Parallel()
+		sub := make(map[string]interface{})
+		for k, v := range claims {
+			sub[k] = v
+		}
+		sub[jwt.SubjectKey] = `https://sub.example.com`
+		signed := signDocument(t, e.key, openidfed.EntityStatementType, sub)
+		_, err := openidfed.ParseEntityStatement(signed, nil)
+		if !assert.Error(t, err, `openidfed.ParseEntityStatement should fail`) {
+			return
+		}
+		_, err = openidfed.ParseEntityStatement(signed, e.publicKeys(t))
+		if !assert.NoError(t, err, `openidfed.ParseEntityStatement should succeed`) {
+			return
+		}
+	})
+}
+
+func TestConfigureAutoRefresh(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := newTestEntity(t, `https://op.example.com`)
+	opKeys := jwk.NewSet()
+	opKey, err := jwxtest.GenerateRsaPublicJwk()
+	if !assert.NoError(t, err, `GenerateRsaPublicJwk should succeed`) {
+		return
+	}
+	_ = opKey.Set(jwk.KeyIDKey, `op-key`)
+	opKeys.Add(opKey)
+
+	buf, err := json.Marshal(opKeys)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+	var keysClaim map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal(buf, &keysClaim), `json.Unmarshal should succeed`) {
+		return
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.TypeKey, openidfed.SignedJWKSType)
+		tok := jwt.New()
+		_ = tok.Set(jwt.IssuerKey, e.id)
+		_ = tok.Set(`keys`, keysClaim[`keys`])
+		signed, err := jwt.Sign(tok, jwa.ES256, e.key, jwt.WithHeaders(hdrs))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(signed)
+	}))
+	defer srv.Close()
+
+	resolved := &openidfed.ResolvedEntity{
+		EntityID: e.id,
+		Keys:     e.publicKeys(t),
+		Metadata: map[string]interface{}{
+			openidfed.OpenIDProvider: map[string]interface{}{
+				`signed_jwks_uri`: srv.URL,
+			},
+		},
+	}
+
+	ar := jwk.NewAutoRefresh(ctx)
+	u, err := resolved.ConfigureAutoRefresh(ar, openidfed.OpenIDProvider)
+	if !assert.NoError(t, err, `ConfigureAutoRefresh should succeed`) {
+		return
+	}
+	if !assert.Equal(t, srv.URL, u, `URL should be signed_jwks_uri`) {
+		return
+	}
+
+	set, err := ar.Fetch(ctx, u)
+	if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+	if _, ok := set.LookupKeyID(`op-key`); !assert.True(t, ok, `set should contain the OP key`) {
+		return
+	}
+
+	_, err = resolved.ConfigureAutoRefresh(ar, openidfed.OpenIDRelyingParty)
+	if !assert.Error(t, err, `ConfigureAutoRefresh should fail for missing metadata`) {
+		return
+	}
+}
@@ -0,0 +1,60 @@
+package openidfed
+
+import (
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+type identClock struct{}
+type identHTTPClient struct{}
+type identMaxPathLength struct{}
+type identTrustAnchor struct{}
+
+// ResolverOption describes an Option that can be passed to `openidfed.NewResolver()`
+type ResolverOption interface {
+	Option
+	resolverOption()
+}
+
+type resolverOption struct {
+	Option
+}
+
+func (*resolverOption) resolverOption() {}
+
+func newResolverOption(n interface{}, v interface{}) ResolverOption {
+	return &resolverOption{option.New(n, v)}
+}
+
+type trustAnchor struct {
+	entityID string
+	keys     jwk.Set
+}
+
+// WithTrustAnchor specifies a Trust Anchor, identified by its entity
+// identifier, and its federation keys. The keys must be obtained
+// out-of-band. This option can be specified multiple times.
+func WithTrustAnchor(entityID string, keys jwk.Set) ResolverOption {
+	return newResolverOption(identTrustAnchor{}, &trustAnchor{entityID: entityID, keys: keys})
+}
+
+// WithHTTPClient specifies the HTTP client used to fetch Entity Statements.
+// By default http.DefaultClient is used.
+func WithHTTPClient(cl jwk.HTTPClient) ResolverOption {
+	return newResolverOption(identHTTPClient{}, cl)
+}
+
+// WithMaxPathLength specifies the maximum number of Subordinate
+// Statements in a trust chain. The default is 5.
+func WithMaxPathLength(n int) ResolverOption {
+	return newResolverOption(identMaxPathLength{}, n)
+}
+
+// WithClock specifies the clock used to validate the time based claims
+// of Entity Statements, and to expire resolved entities.
+func WithClock(c jwt.Clock) ResolverOption {
+	return newResolverOption(identClock{}, c)
+}
@@ -0,0 +1,331 @@
+package openidfed
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultMaxPathLength = 5
+
+	// maxStatementSize is the maximum size of a fetched document
+	maxStatementSize = 1 << 20
+)
+
+// Resolver resolves trust chains from entities to the configured
+// Trust Anchors. Resolved entities are cached until the earliest
+// expiration time of the statements in their trust chain.
+// Use `openidfed.NewResolver()` to create one.
+type Resolver struct {
+	anchors       map[string]jwk.Set
+	httpcl        jwk.HTTPClient
+	maxPathLength int
+	clock         jwt.Clock
+
+	mu    sync.Mutex
+	cache map[string]*ResolvedEntity
+}
+
+// ResolvedEntity is an entity whose trust chain has been verified
+type ResolvedEntity struct {
+	// EntityID is the entity identifier of the entity
+	EntityID string
+
+	// TrustAnchor is the entity identifier of the Trust Anchor
+	// that the trust chain leads to
+	TrustAnchor string
+
+	// Keys are the federation keys of the entity, as attested by its
+	// immediate superior (or configured, if the entity is a Trust Anchor)
+	Keys jwk.Set
+
+	// Metadata is the metadata of the entity, as published in its
+	// Entity Configuration
+	Metadata map[string]interface{}
+
+	// Chain is the trust chain, starting with the Entity Configuration
+	// of the entity, followed by the Subordinate Statements up to
+	// the Trust Anchor
+	Chain []*EntityStatement
+
+	// Expiration is the earliest expiration time of the statements
+	// in the trust chain
+	Expiration time.Time
+}
+
+// EntityMetadata returns the metadata for the given entity type
+// (e.g. `openidfed.OpenIDProvider`)
+func (e *ResolvedEntity) EntityMetadata(typ string) (map[string]interface{}, bool) {
+	v, ok := e.Metadata[typ].(map[string]interface{})
+	return v, ok
+}
+
+// NewResolver creates a new Resolver. At least one Trust Anchor must be
+// specified using `openidfed.WithTrustAnchor()`.
+func NewResolver(options ...ResolverOption) *Resolver {
+	r := &Resolver{
+		anchors:       make(map[string]jwk.Set),
+		httpcl:        http.DefaultClient,
+		maxPathLength: defaultMaxPathLength,
+		clock:         jwt.ClockFunc(time.Now),
+		cache:         make(map[string]*ResolvedEntity),
+	}
+
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identTrustAnchor{}:
+			ta := option.Value().(*trustAnchor)
+			r.anchors[ta.entityID] = ta.keys
+		case identHTTPClient{}:
+			r.httpcl = option.Value().(jwk.HTTPClient)
+		case identMaxPathLength{}:
+			r.maxPathLength = option.Value().(int)
+		case identClock{}:
+			r.clock = option.Value().(jwt.Clock)
+		}
+	}
+	return r
+}
+
+// Resolve fetches the Entity Configuration of the entity, and resolves
+// a trust chain to one of the Trust Anchors. If the entity has more than
+// one superior, they are tried in the order of its "authority_hints".
+func (r *Resolver) Resolve(ctx context.Context, entityID string) (*ResolvedEntity, error) {
+	now := r.clock.Now()
+	r.mu.Lock()
+	if e, ok := r.cache[entityID]; ok {
+		if now.Before(e.Expiration) {
+			r.mu.Unlock()
+			return e, nil
+		}
+		delete(r.cache, entityID)
+	}
+	r.mu.Unlock()
+
+	e, err := r.resolve(ctx, entityID)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to resolve trust chain for %q`, entityID)
+	}
+
+	r.mu.Lock()
+	r.cache[entityID] = e
+	r.mu.Unlock()
+	return e, nil
+}
+
+// Purge removes all resolved entities from the cache
+func (r *Resolver) Purge() {
+	r.mu.Lock()
+	r.cache = make(map[string]*ResolvedEntity)
+	r.mu.Unlock()
+}
+
+func (r *Resolver) resolve(ctx context.Context, entityID string) (*ResolvedEntity, error) {
+	ec, err := r.FetchEntityConfiguration(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The entity may be a Trust Anchor itself
+	if keys, ok := r.anchors[entityID]; ok {
+		if _, err := verifySigned(ec.Raw, keys, EntityStatementType); err != nil {
+			return nil, errors.Wrap(err, `failed to verify entity configuration of trust anchor`)
+		}
+		return newResolvedEntity(ec, keys, entityID, nil), nil
+	}
+
+	chain, anchor, err := r.buildChain(ctx, ec, 0)
+	if err != nil {
+		return nil, err
+	}
+	return newResolvedEntity(ec, chain[0].Keys, anchor, chain), nil
+}
+
+func newResolvedEntity(ec *EntityStatement, keys jwk.Set, anchor string, chain []*EntityStatement) *ResolvedEntity {
+	e := &ResolvedEntity{
+		EntityID:    ec.Subject,
+		TrustAnchor: anchor,
+		Keys:        keys,
+		Metadata:    ec.Metadata,
+		Chain:       append([]*EntityStatement{ec}, chain...),
+		Expiration:  ec.Expiration,
+	}
+	for _, stmt := range chain {
+		if stmt.Expiration.Before(e.Expiration) {
+			e.Expiration = stmt.Expiration
+		}
+	}
+	return e
+}
+
+// buildChain returns the Subordinate Statements from the superior of
+// `ec` up to a Trust Anchor, and the entity identifier of the Trust Anchor.
+// `ec` is verified against the keys in the statement issued by its superior.
+func (r *Resolver) buildChain(ctx context.Context, ec *EntityStatement, depth int) ([]*EntityStatement, string, error) {
+	if len(ec.AuthorityHints) == 0 {
+		return nil, "", errors.Errorf(`entity %q has no authority hints`, ec.Subject)
+	}
+
+	var lastErr error
+	for _, hint := range ec.AuthorityHints {
+		chain, anchor, err := r.buildChainVia(ctx, ec, hint, depth)
+		if err == nil {
+			return chain, anchor, nil
+		}
+		lastErr = errors.Wrapf(err, `failed to build trust chain via %q`, hint)
+	}
+	return nil, "", lastErr
+}
+
+func (r *Resolver) buildChainVia(ctx context.Context, ec *EntityStatement, superiorID string, depth int) ([]*EntityStatement, string, error) {
+	superior, err := r.FetchEntityConfiguration(ctx, superiorID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ss, err := r.FetchSubordinateStatement(ctx, superior, ec.Subject)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The entity's configuration must be signed by one of the keys that
+	// its superior attests to
+	if _, err := verifySigned(ec.Raw, ss.Keys, EntityStatementType); err != nil {
+		return nil, "", errors.Wrapf(err, `entity configuration of %q is not signed by a key in its subordinate statement`, ec.Subject)
+	}
+
+	if keys, ok := r.anchors[superiorID]; ok {
+		if _, err := verifySigned(ss.Raw, keys, EntityStatementType); err != nil {
+			return nil, "", errors.Wrap(err, `subordinate statement is not signed by the trust anchor`)
+		}
+		return []*EntityStatement{ss}, superiorID, nil
+	}
+
+	if depth+1 >= r.maxPathLength {
+		return nil, "", errors.Errorf(`maximum path length (%d) exceeded`, r.maxPathLength)
+	}
+
+	rest, anchor, err := r.buildChain(ctx, superior, depth+1)
+	if err != nil {
+		return nil, "", err
+	}
+	return append([]*EntityStatement{ss}, rest...), anchor, nil
+}
+
+// FetchEntityConfiguration fetches the Entity Configuration of the
+// entity, and verifies it against the keys it contains. The result
+// must not be trusted until it is verified as part of a trust chain.
+func (r *Resolver) FetchEntityConfiguration(ctx context.Context, entityID string) (*EntityStatement, error) {
+	buf, err := r.fetch(ctx, strings.TrimSuffix(entityID, `/`)+WellKnownPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch entity configuration of %q`, entityID)
+	}
+
+	ec, err := ParseEntityStatement(buf, nil, jwt.WithClock(r.clock))
+	if err != nil {
+		return nil, errors.Wrapf(err, `invalid entity configuration of %q`, entityID)
+	}
+	if ec.Issuer != entityID || ec.Subject != entityID {
+		return nil, errors.Errorf(`entity configuration of %q was issued by %q for %q`, entityID, ec.Issuer, ec.Subject)
+	}
+	return ec, nil
+}
+
+// FetchSubordinateStatement fetches the statement about the entity `sub`
+// from the fetch endpoint of its superior, and verifies it against the
+// keys in the superior's Entity Configuration.
+func (r *Resolver) FetchSubordinateStatement(ctx context.Context, superior *EntityStatement, sub string) (*EntityStatement, error) {
+	md, _ := superior.EntityMetadata(FederationEntity)
+	endpoint, _ := md[`federation_fetch_endpoint`].(string)
+	if endpoint == "" {
+		return nil, errors.Errorf(`entity %q does not publish a fetch endpoint`, superior.Subject)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, `invalid fetch endpoint of %q`, superior.Subject)
+	}
+	q := u.Query()
+	q.Set(`sub`, sub)
+	u.RawQuery = q.Encode()
+
+	buf, err := r.fetch(ctx, u.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch subordinate statement for %q from %q`, sub, superior.Subject)
+	}
+
+	ss, err := ParseEntityStatement(buf, superior.Keys, jwt.WithClock(r.clock))
+	if err != nil {
+		return nil, errors.Wrapf(err, `invalid subordinate statement for %q from %q`, sub, superior.Subject)
+	}
+	if ss.Issuer != superior.Subject || ss.Subject != sub {
+		return nil, errors.Errorf(`subordinate statement for %q from %q was issued by %q for %q`, sub, superior.Subject, ss.Issuer, ss.Subject)
+	}
+	return ss, nil
+}
+
+func (r *Resolver) fetch(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create request`)
+	}
+
+	res, err := r.httpcl.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to send request`)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(`unexpected status %d`, res.StatusCode)
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(res.Body, maxStatementSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to read response`)
+	}
+	if len(buf) > maxStatementSize {
+		return nil, errors.Errorf(`response exceeds %d bytes`, maxStatementSize)
+	}
+	return buf, nil
+}
+
+// ConfigureAutoRefresh registers the key set that the entity uses for
+// the given entity type (e.g. `openidfed.OpenIDProvider`) with `ar`, and
+// returns its URL, which can then be passed to `(*jwk.AutoRefresh).Fetch()`.
+//
+// If the metadata contains "signed_jwks_uri", the signed key set is
+// verified against the federation keys of the entity on every refresh.
+// Otherwise "jwks_uri" is used.
+func (e *ResolvedEntity) ConfigureAutoRefresh(ar *jwk.AutoRefresh, typ string, options ...jwk.AutoRefreshOption) (string, error) {
+	md, ok := e.EntityMetadata(typ)
+	if !ok {
+		return "", errors.Errorf(`entity %q has no metadata for %q`, e.EntityID, typ)
+	}
+
+	if u, ok := md[`signed_jwks_uri`].(string); ok && u != "" {
+		keys := e.Keys
+		verifier := jwk.SignatureVerifierFunc(func(buf []byte) ([]byte, error) {
+			return verifySigned(buf, keys, SignedJWKSType)
+		})
+		ar.Configure(u, append(options, jwk.WithSignatureVerifier(verifier))...)
+		return u, nil
+	}
+
+	if u, ok := md[`jwks_uri`].(string); ok && u != "" {
+		ar.Configure(u, options...)
+		return u, nil
+	}
+	return "", errors.Errorf(`entity %q does not publish a key set for %q`, e.EntityID, typ)
+}
@@ -28,3 +28,166 @@ func newJSONOption(n interface{}, v interface{}) JSONOption {
 func WithUseNumber(b bool) JSONOption {
 	return newJSONOption(identUseNumber{}, b)
 }
+
+type identFIPSMode struct{}
+type identHook struct{}
+type identKeySeparation struct{}
+type identKeySeparationTracker struct{}
+type identKeySeparationWarning struct{}
+type identLenientBase64 struct{}
+type identMaxHeaderCount struct{}
+type identMaxInputSize struct{}
+type identMaxNestingDepth struct{}
+type identMaxPooledBufferSize struct{}
+type identMaxRecipients struct{}
+type identMaxSignatures struct{}
+
+// GlobalOption describes an Option that can be passed to `jwx.Settings()`
+type GlobalOption interface {
+	Option
+	isGlobalOption()
+}
+
+type globalOption struct {
+	Option
+}
+
+func (o *globalOption) isGlobalOption() {}
+
+func newGlobalOption(n interface{}, v interface{}) GlobalOption {
+	return &globalOption{option.New(n, v)}
+}
+
+// WithHook specifies the Hook that is notified of operations performed
+// by the jwx packages. Pass nil to stop notifications.
+//
+// This has global effect.
+func WithHook(h Hook) GlobalOption {
+	return newGlobalOption(identHook{}, h)
+}
+
+// WithMaxInputSize specifies the maximum size in bytes of the serialized
+// JWS/JWE messages, JWKs, and JWTs that are accepted by the parsing,
+// verification, and decryption functions in the jwx packages.
+//
+// The default value is 0, which means that no limit is imposed.
+// This has global effect.
+func WithMaxInputSize(n int64) GlobalOption {
+	return newGlobalOption(identMaxInputSize{}, n)
+}
+
+// WithMaxNestingDepth specifies the maximum depth of nested JSON objects
+// and/or arrays in JWS/JWE messages in JSON serialization, JOSE headers,
+// JWKs, and JWT claims. The top-level JSON object counts as one level.
+//
+// The default value is 0, which means that no limit is imposed.
+// This has global effect.
+func WithMaxNestingDepth(n int) GlobalOption {
+	return newGlobalOption(identMaxNestingDepth{}, n)
+}
+
+// WithMaxSignatures specifies the maximum number of signatures allowed
+// in a JWS message.
+//
+// The default value is 0, which means that no limit is imposed.
+// This has global effect.
+func WithMaxSignatures(n int) GlobalOption {
+	return newGlobalOption(identMaxSignatures{}, n)
+}
+
+// WithMaxRecipients specifies the maximum number of recipients allowed
+// in a JWE message.
+//
+// The default value is 0, which means that no limit is imposed.
+// This has global effect.
+func WithMaxRecipients(n int) GlobalOption {
+	return newGlobalOption(identMaxRecipients{}, n)
+}
+
+// WithMaxHeaderCount specifies the maximum number of fields allowed in
+// each of the protected and unprotected JOSE headers of JWS/JWE messages.
+//
+// The default value is 0, which means that no limit is imposed.
+// This has global effect.
+func WithMaxHeaderCount(n int) GlobalOption {
+	return newGlobalOption(identMaxHeaderCount{}, n)
+}
+
+// WithMaxPooledBufferSize specifies the capacity in bytes above which
+// the internal buffers used to serialize and verify messages are discarded
+// instead of being kept for reuse. Buffers are pooled by size, so that
+// signing an occasional large payload does not keep a large buffer alive
+// for the small payloads that follow. Raise the value if you routinely
+// handle large payloads, or set it to 0 to disable pooling.
+//
+// The default value is 64KiB.
+// This has global effect.
+func WithMaxPooledBufferSize(n int) GlobalOption {
+	return newGlobalOption(identMaxPooledBufferSize{}, n)
+}
+
+// WithKeySeparation specifies what happens when a key is used both for
+// signatures (`jws.Sign()`, `jws.Verify()`) and for encryption
+// (`jwe.Encrypt()`, `jwe.Decrypt()`). A `jwk.Key` whose "use" field
+// does not match the operation is considered a violation. To also
+// detect keys that are used for both operations, specify a tracker
+// using `jwx.WithKeySeparationTracker()`.
+//
+// The default is `jwx.KeySeparationIgnore`, which disables detection.
+//
+// This has global effect.
+func WithKeySeparation(p KeySeparationPolicy) GlobalOption {
+	return newGlobalOption(identKeySeparation{}, p)
+}
+
+// WithKeySeparationTracker specifies the tracker that records the
+// keys used for signatures and for encryption, while the policy set
+// via `jwx.WithKeySeparation()` is not `jwx.KeySeparationIgnore`.
+// Keys are identified by the thumbprint of their public key, so a
+// private key and its public key are considered the same key.
+//
+// The tracker is owned by the caller, who is responsible for
+// discarding it (or calling its `Reset()` method) when the keys
+// recorded so far are no longer of interest. Passing nil disables
+// tracking, which is the default.
+//
+// This has global effect.
+func WithKeySeparationTracker(t *KeySeparationTracker) GlobalOption {
+	return newGlobalOption(identKeySeparationTracker{}, t)
+}
+
+// WithKeySeparationWarning specifies the function that receives
+// key separation violations when the policy is `jwx.KeySeparationWarn`.
+// By default, they are discarded.
+//
+// This has global effect.
+func WithKeySeparationWarning(fn func(error)) GlobalOption {
+	return newGlobalOption(identKeySeparationWarning{}, fn)
+}
+
+// WithLenientBase64 specifies whether the standard base64 alphabet and
+// padding are accepted when decoding headers, payloads, signatures, and
+// JWK parameters. The RFCs require unpadded base64url (and padded standard
+// base64 for "x5c"), which is the only form accepted by default. Enable
+// this only to interoperate with producers that do not follow them.
+//
+// This has global effect.
+func WithLenientBase64(b bool) GlobalOption {
+	return newGlobalOption(identLenientBase64{}, b)
+}
+
+// WithFIPSMode enables or disables FIPS mode. In FIPS mode, the jws
+// and jwe packages refuse to sign, verify, encrypt, or decrypt using
+// algorithms that are not approved by FIPS 140-3, and return an error
+// matching `jwx.ErrNotFIPSApproved`. This rejects "none", secp256k1,
+// X25519, RSA1_5, HMAC keys shorter than 112 bits, as well as any
+// algorithm registered by the user.
+//
+// FIPS mode is enabled by default when the library is built with the
+// `jwx_fips` build tag, or when the Go cryptographic module runs in
+// FIPS 140-3 mode (e.g. GODEBUG=fips140=on).
+//
+// This has global effect.
+func WithFIPSMode(b bool) GlobalOption {
+	return newGlobalOption(identFIPSMode{}, b)
+}
@@ -0,0 +1,327 @@
+// Package awskms provides a crypto.Signer backed by keys stored in
+// AWS Key Management Service (KMS), which can be used directly with
+// jws.Sign and jwt.Sign.
+//
+// This package does not depend on the AWS SDK. Instead, it works with
+// any value that satisfies the `Client` interface, which is a thin
+// subset of the KMS API. An adapter for aws-sdk-go-v2 is a few lines:
+//
+//	type kmsClient struct {
+//	  client *kms.Client
+//	}
+//
+//	func (c kmsClient) GetPublicKey(ctx context.Context, in *awskms.GetPublicKeyInput) (*awskms.GetPublicKeyOutput, error) {
+//	  out, err := c.client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(in.KeyID)})
+//	  if err != nil {
+//	    return nil, err
+//	  }
+//	  return &awskms.GetPublicKeyOutput{KeyID: aws.ToString(out.KeyId), PublicKey: out.PublicKey}, nil
+//	}
+//
+//	func (c kmsClient) Sign(ctx context.Context, in *awskms.SignInput) (*awskms.SignOutput, error) {
+//	  out, err := c.client.Sign(ctx, &kms.SignInput{
+//	    KeyId:            aws.String(in.KeyID),
+//	    Message:          in.Message,
+//	    MessageType:      types.MessageType(in.MessageType),
+//	    SigningAlgorithm: types.SigningAlgorithmSpec(in.SigningAlgorithm),
+//	  })
+//	  if err != nil {
+//	    return nil, err
+//	  }
+//	  return &awskms.SignOutput{Signature: out.Signature}, nil
+//	}
+//
+// Then create a signer and use it as the key:
+//
+//	signer, err := awskms.New(kmsClient{client: kms.NewFromConfig(cfg)}, keyID)
+//	pubkey, err := signer.PublicJWK()  // publish this in your JWKS
+//	hdrs := jws.NewHeaders()
+//	hdrs.Set(jws.KeyIDKey, signer.KeyID())
+//	signed, err := jwt.Sign(token, signer.Algorithm(), signer, jwt.WithHeaders(hdrs))
+package awskms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"io"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/x/internal/signerutil"
+	"github.com/pkg/errors"
+)
+
+// SigningAlgorithm represents the KMS signing algorithm specification
+// (e.g. "RSASSA_PKCS1_V1_5_SHA_256")
+type SigningAlgorithm string
+
+const (
+	RSASSAPKCS1v15SHA256 SigningAlgorithm = "RSASSA_PKCS1_V1_5_SHA_256"
+	RSASSAPKCS1v15SHA384 SigningAlgorithm = "RSASSA_PKCS1_V1_5_SHA_384"
+	RSASSAPKCS1v15SHA512 SigningAlgorithm = "RSASSA_PKCS1_V1_5_SHA_512"
+	RSASSAPSSSHA256      SigningAlgorithm = "RSASSA_PSS_SHA_256"
+	RSASSAPSSSHA384      SigningAlgorithm = "RSASSA_PSS_SHA_384"
+	RSASSAPSSSHA512      SigningAlgorithm = "RSASSA_PSS_SHA_512"
+	ECDSASHA256          SigningAlgorithm = "ECDSA_SHA_256"
+	ECDSASHA384          SigningAlgorithm = "ECDSA_SHA_384"
+	ECDSASHA512          SigningAlgorithm = "ECDSA_SHA_512"
+)
+
+// MessageTypeDigest is the value of `SignInput.MessageType` used by this
+// package. The message sent to KMS is always a pre-computed digest.
+const MessageTypeDigest = "DIGEST"
+
+var signingAlgorithms = map[jwa.SignatureAlgorithm]SigningAlgorithm{
+	jwa.RS256: RSASSAPKCS1v15SHA256,
+	jwa.RS384: RSASSAPKCS1v15SHA384,
+	jwa.RS512: RSASSAPKCS1v15SHA512,
+	jwa.PS256: RSASSAPSSSHA256,
+	jwa.PS384: RSASSAPSSSHA384,
+	jwa.PS512: RSASSAPSSSHA512,
+	jwa.ES256: ECDSASHA256,
+	jwa.ES384: ECDSASHA384,
+	jwa.ES512: ECDSASHA512,
+}
+
+var hashes = map[jwa.SignatureAlgorithm]crypto.Hash{
+	jwa.RS256: crypto.SHA256,
+	jwa.RS384: crypto.SHA384,
+	jwa.RS512: crypto.SHA512,
+	jwa.PS256: crypto.SHA256,
+	jwa.PS384: crypto.SHA384,
+	jwa.PS512: crypto.SHA512,
+	jwa.ES256: crypto.SHA256,
+	jwa.ES384: crypto.SHA384,
+	jwa.ES512: crypto.SHA512,
+}
+
+// GetPublicKeyInput is the input to Client.GetPublicKey
+type GetPublicKeyInput struct {
+	KeyID string
+}
+
+// GetPublicKeyOutput is the output of Client.GetPublicKey
+type GetPublicKeyOutput struct {
+	// KeyID is the ARN of the key
+	KeyID string
+	// PublicKey is the DER encoded (SubjectPublicKeyInfo) public key
+	PublicKey []byte
+}
+
+// SignInput is the input to Client.Sign
+type SignInput struct {
+	KeyID            string
+	Message          []byte
+	MessageType      string
+	SigningAlgorithm SigningAlgorithm
+}
+
+// SignOutput is the output of Client.Sign
+type SignOutput struct {
+	// Signature is the signature as returned by KMS. For ECDSA
+	// keys, this is an ASN.1 DER encoded signature.
+	Signature []byte
+}
+
+// Client is the subset of the AWS KMS API required by this package.
+type Client interface {
+	GetPublicKey(context.Context, *GetPublicKeyInput) (*GetPublicKeyOutput, error)
+	Sign(context.Context, *SignInput) (*SignOutput, error)
+}
+
+// Signer is a crypto.Signer that delegates signing operations to
+// AWS KMS. The private key never leaves KMS.
+type Signer struct {
+	alg    jwa.SignatureAlgorithm
+	arn    string
+	client Client
+	ctx    context.Context
+	keyID  string
+	kid    string
+	pubkey crypto.PublicKey
+}
+
+// New creates a new Signer for the KMS key identified by keyID, which
+// may be a key ID, key ARN, alias name, or alias ARN.
+//
+// The public key is fetched from KMS upon creation.
+func New(client Client, keyID string, options ...Option) (*Signer, error) {
+	if client == nil {
+		return nil, errors.New(`awskms.New: client must not be nil`)
+	}
+
+	ctx := context.Background()
+	var alg jwa.SignatureAlgorithm
+	var thumbprintKeyID bool
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identAlgorithm{}:
+			alg = option.Value().(jwa.SignatureAlgorithm)
+		case identContext{}:
+			ctx = option.Value().(context.Context)
+		case identThumbprintKeyID{}:
+			thumbprintKeyID = option.Value().(bool)
+		}
+	}
+
+	out, err := client.GetPublicKey(ctx, &GetPublicKeyInput{KeyID: keyID})
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch public key for %s`, keyID)
+	}
+
+	pubkey, err := x509.ParsePKIXPublicKey(out.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse public key`)
+	}
+
+	if alg == "" {
+		alg, err = defaultAlgorithm(pubkey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := checkAlgorithm(alg, pubkey); err != nil {
+		return nil, err
+	}
+
+	arn := out.KeyID
+	if arn == "" {
+		arn = keyID
+	}
+
+	s := &Signer{
+		alg:    alg,
+		arn:    arn,
+		client: client,
+		ctx:    ctx,
+		keyID:  keyID,
+		kid:    arn,
+		pubkey: pubkey,
+	}
+
+	if thumbprintKeyID {
+		kid, err := signerutil.ThumbprintKeyID(pubkey)
+		if err != nil {
+			return nil, err
+		}
+		s.kid = kid
+	}
+
+	return s, nil
+}
+
+func defaultAlgorithm(pubkey crypto.PublicKey) (jwa.SignatureAlgorithm, error) {
+	switch pubkey := pubkey.(type) {
+	case *rsa.PublicKey:
+		return jwa.RS256, nil
+	case *ecdsa.PublicKey:
+		switch pubkey.Curve {
+		case elliptic.P256():
+			return jwa.ES256, nil
+		case elliptic.P384():
+			return jwa.ES384, nil
+		case elliptic.P521():
+			return jwa.ES512, nil
+		}
+		return "", errors.Errorf(`unsupported curve %s`, pubkey.Curve.Params().Name)
+	default:
+		return "", errors.Errorf(`unsupported public key type %T`, pubkey)
+	}
+}
+
+func checkAlgorithm(alg jwa.SignatureAlgorithm, pubkey crypto.PublicKey) error {
+	if _, ok := signingAlgorithms[alg]; !ok {
+		return errors.Errorf(`unsupported signature algorithm %s`, alg)
+	}
+
+	switch pubkey.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		expected, err := defaultAlgorithm(pubkey)
+		if err != nil {
+			return err
+		}
+		if alg == expected {
+			return nil
+		}
+	}
+	return errors.Errorf(`signature algorithm %s cannot be used with key of type %T`, alg, pubkey)
+}
+
+// Algorithm returns the JWS algorithm used by this signer
+func (s *Signer) Algorithm() jwa.SignatureAlgorithm {
+	return s.alg
+}
+
+// KeyARN returns the ARN of the KMS key
+func (s *Signer) KeyARN() string {
+	return s.arn
+}
+
+// KeyID returns the value to be used in the "kid" field. By default
+// this is the key ARN, or the RFC7638 thumbprint of the public key
+// if WithThumbprintKeyID(true) was specified.
+func (s *Signer) KeyID() string {
+	return s.kid
+}
+
+// Public returns the public key associated with the KMS key
+func (s *Signer) Public() crypto.PublicKey {
+	return s.pubkey
+}
+
+// PublicJWK returns the public key as a jwk.Key, with the "kid", "alg"
+// and "use" fields populated.
+func (s *Signer) PublicJWK() (jwk.Key, error) {
+	return signerutil.PublicJWK(s.pubkey, s.kid, s.alg)
+}
+
+// Sign signs the given digest using KMS, using the context specified
+// by WithContext(). See SignContext for details.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(s.ctx, rand, digest, opts)
+}
+
+// SignContext signs the given digest using KMS. The hash function and
+// padding scheme specified in opts must match the algorithm of
+// this signer. The rand parameter is ignored.
+//
+// SignContext is called by jws.SignContext() and jwt.SignContext(),
+// so that their context is used for the call to KMS.
+func (s *Signer) SignContext(ctx context.Context, _ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts == nil || opts.HashFunc() != hashes[s.alg] {
+		return nil, errors.Errorf(`hash function does not match algorithm %s`, s.alg)
+	}
+
+	_, isPSS := opts.(*rsa.PSSOptions)
+	switch s.alg {
+	case jwa.PS256, jwa.PS384, jwa.PS512:
+		if !isPSS {
+			return nil, errors.Errorf(`algorithm %s requires *rsa.PSSOptions`, s.alg)
+		}
+	default:
+		if isPSS {
+			return nil, errors.Errorf(`algorithm %s cannot be used with *rsa.PSSOptions`, s.alg)
+		}
+	}
+
+	out, err := s.client.Sign(ctx, &SignInput{
+		KeyID:            s.keyID,
+		Message:          digest,
+		MessageType:      MessageTypeDigest,
+		SigningAlgorithm: signingAlgorithms[s.alg],
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to sign using KMS key %s`, s.keyID)
+	}
+	return out.Signature, nil
+}
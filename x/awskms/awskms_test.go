@@ -0,0 +1,224 @@
+package awskms_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/x/awskms"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testKeyARN = `arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab`
+
+// fakeKMS emulates the KMS API using a local private key
+type fakeKMS struct {
+	key crypto.Signer
+	// beforeSign, if set, is called at the start of every call to Sign
+	beforeSign func()
+}
+
+func (c *fakeKMS) GetPublicKey(_ context.Context, in *awskms.GetPublicKeyInput) (*awskms.GetPublicKeyOutput, error) {
+	der, err := x509.MarshalPKIXPublicKey(c.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &awskms.GetPublicKeyOutput{KeyID: testKeyARN, PublicKey: der}, nil
+}
+
+func (c *fakeKMS) Sign(ctx context.Context, in *awskms.SignInput) (*awskms.SignOutput, error) {
+	if c.beforeSign != nil {
+		c.beforeSign()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if in.MessageType != awskms.MessageTypeDigest {
+		return nil, errors.Errorf(`unexpected message type %s`, in.MessageType)
+	}
+
+	var opts crypto.SignerOpts
+	switch in.SigningAlgorithm {
+	case awskms.RSASSAPKCS1v15SHA256, awskms.ECDSASHA256:
+		opts = crypto.SHA256
+	case awskms.RSASSAPKCS1v15SHA384, awskms.ECDSASHA384:
+		opts = crypto.SHA384
+	case awskms.RSASSAPKCS1v15SHA512, awskms.ECDSASHA512:
+		opts = crypto.SHA512
+	case awskms.RSASSAPSSSHA256:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	case awskms.RSASSAPSSSHA384:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}
+	case awskms.RSASSAPSSSHA512:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}
+	default:
+		return nil, errors.Errorf(`unsupported signing algorithm %s`, in.SigningAlgorithm)
+	}
+	if len(in.Message) != opts.HashFunc().Size() {
+		return nil, errors.New(`invalid digest length`)
+	}
+
+	signature, err := c.key.Sign(rand.Reader, in.Message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &awskms.SignOutput{Signature: signature}, nil
+}
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	p256key, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	p521key, err := jwxtest.GenerateEcdsaKey(jwa.P521)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name      string
+		Key       crypto.Signer
+		Options   []awskms.Option
+		Algorithm jwa.SignatureAlgorithm
+	}{
+		{Name: "RSA (default)", Key: rsakey, Algorithm: jwa.RS256},
+		{Name: "RSA (PS384)", Key: rsakey, Options: []awskms.Option{awskms.WithAlgorithm(jwa.PS384)}, Algorithm: jwa.PS384},
+		{Name: "RSA (RS512)", Key: rsakey, Options: []awskms.Option{awskms.WithAlgorithm(jwa.RS512)}, Algorithm: jwa.RS512},
+		{Name: "ECDSA P-256", Key: p256key, Algorithm: jwa.ES256},
+		{Name: "ECDSA P-521", Key: p521key, Algorithm: jwa.ES512},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			signer, err := awskms.New(&fakeKMS{key: tc.Key}, `alias/test`, tc.Options...)
+			if !assert.NoError(t, err, `awskms.New should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Algorithm, signer.Algorithm(), `algorithm should match`) {
+				return
+			}
+			if !assert.Equal(t, testKeyARN, signer.KeyID(), `kid should be the key ARN`) {
+				return
+			}
+
+			pubkey, err := signer.PublicJWK()
+			if !assert.NoError(t, err, `signer.PublicJWK should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Algorithm.String(), pubkey.Algorithm(), `"alg" should match`) {
+				return
+			}
+
+			payload := []byte(`Lorem ipsum`)
+			signed, err := jws.Sign(payload, signer.Algorithm(), signer)
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+			verified, err := jws.Verify(signed, signer.Algorithm(), pubkey)
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payloads should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("jwt.Sign with kid", func(t *testing.T) {
+		t.Parallel()
+		signer, err := awskms.New(&fakeKMS{key: p256key}, `alias/test`, awskms.WithThumbprintKeyID(true))
+		if !assert.NoError(t, err, `awskms.New should succeed`) {
+			return
+		}
+		if !assert.False(t, strings.HasPrefix(signer.KeyID(), `arn:`), `kid should be a thumbprint`) {
+			return
+		}
+
+		token := jwt.New()
+		_ = token.Set(jwt.IssuerKey, `github.com/lestrrat-go/jwx`)
+		_ = token.Set(jwt.IssuedAtKey, time.Now())
+
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.KeyIDKey, signer.KeyID())
+		signed, err := jwt.Sign(token, signer.Algorithm(), signer, jwt.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+
+		pubkey, err := signer.PublicJWK()
+		if !assert.NoError(t, err, `signer.PublicJWK should succeed`) {
+			return
+		}
+		set := jwk.NewSet()
+		set.Add(pubkey)
+
+		parsed, err := jwt.Parse(signed, jwt.WithKeySet(set))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `github.com/lestrrat-go/jwx`, parsed.Issuer(), `issuer should match`) {
+			return
+		}
+	})
+	t.Run("jws.SignContext", func(t *testing.T) {
+		t.Parallel()
+		// the context is canceled while the request is in flight, which
+		// the client only notices if it receives the per-call context
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		signer, err := awskms.New(&fakeKMS{key: p256key, beforeSign: cancel}, `alias/test`)
+		if !assert.NoError(t, err, `awskms.New should succeed`) {
+			return
+		}
+		_, err = jws.SignContext(ctx, []byte(`Lorem ipsum`), signer.Algorithm(), signer)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jws.SignContext should fail with context.Canceled (got %v)`, err) {
+			return
+		}
+	})
+	t.Run("mismatched algorithm", func(t *testing.T) {
+		t.Parallel()
+		_, err := awskms.New(&fakeKMS{key: p256key}, `alias/test`, awskms.WithAlgorithm(jwa.ES384))
+		if !assert.Error(t, err, `awskms.New should fail`) {
+			return
+		}
+		_, err = awskms.New(&fakeKMS{key: rsakey}, `alias/test`, awskms.WithAlgorithm(jwa.ES256))
+		if !assert.Error(t, err, `awskms.New should fail`) {
+			return
+		}
+	})
+	t.Run("mismatched signer options", func(t *testing.T) {
+		t.Parallel()
+		signer, err := awskms.New(&fakeKMS{key: rsakey}, `alias/test`)
+		if !assert.NoError(t, err, `awskms.New should succeed`) {
+			return
+		}
+		digest := make([]byte, 32)
+		_, err = signer.Sign(rand.Reader, digest, &rsa.PSSOptions{Hash: crypto.SHA256})
+		if !assert.Error(t, err, `signer.Sign should fail with PSS options`) {
+			return
+		}
+		_, err = signer.Sign(rand.Reader, digest, crypto.SHA384)
+		if !assert.Error(t, err, `signer.Sign should fail with wrong hash`) {
+			return
+		}
+	})
+}
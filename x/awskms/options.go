@@ -0,0 +1,38 @@
+package awskms
+
+import (
+	"context"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+type identAlgorithm struct{}
+type identContext struct{}
+type identThumbprintKeyID struct{}
+
+// WithAlgorithm specifies the JWS algorithm to use when signing. If not
+// specified, the algorithm is inferred from the public key:
+// RS256 for RSA keys, and ES256/ES384/ES512 for ECDSA keys depending on
+// the curve.
+func WithAlgorithm(alg jwa.SignatureAlgorithm) Option {
+	return option.New(identAlgorithm{}, alg)
+}
+
+// WithContext specifies the context.Context object to use when calling
+// the KMS API. As `crypto.Signer` does not accept a context, this
+// context is used for all subsequent calls to `Sign()`. Calls to
+// `SignContext()`, such as those made by `jws.SignContext()`, use the
+// context passed to them instead.
+// By default `context.Background()` is used.
+func WithContext(ctx context.Context) Option {
+	return option.New(identContext{}, ctx)
+}
+
+// WithThumbprintKeyID specifies that the key ID should be computed as the
+// RFC7638 thumbprint (SHA-256) of the public key, instead of using the key ARN.
+func WithThumbprintKeyID(v bool) Option {
+	return option.New(identThumbprintKeyID{}, v)
+}
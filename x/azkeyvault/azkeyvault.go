@@ -0,0 +1,503 @@
+// Package azkeyvault provides opaque keys backed by Azure Key Vault and
+// Azure Key Vault Managed HSM. The keys implement crypto.Signer, so they
+// can be used directly with jws.Sign and jwt.Sign, and RSA keys also
+// implement crypto.Decrypter, so they can be used with jwe.Decrypt.
+//
+// Key Vault uses the JWA algorithm names for its sign, verify, wrap
+// and unwrap operations, so the algorithms are mapped directly.
+//
+// This package does not depend on the Azure SDK. Instead, it works with
+// any value that satisfies the `Client` interface, which is a thin
+// subset of the Key Vault keys API. When writing an adapter for the
+// Azure SDK, use `ParseKeyID` to obtain the key name and version from
+// the key identifier passed to each method, and pass the JSON encoded
+// form of the key returned by GetKey in `GetKeyOutput.JWK`.
+//
+// The key can then be used as follows:
+//
+//	key, err := azkeyvault.New(client, "https://myvault.vault.azure.net/keys/signing/0123456789abcdef")
+//	pubkey, err := key.PublicJWK()  // publish this in your JWKS
+//	hdrs := jws.NewHeaders()
+//	hdrs.Set(jws.KeyIDKey, key.KeyID())
+//	signed, err := jwt.Sign(token, jwa.PS256, key, jwt.WithHeaders(hdrs))
+//
+//	encrypted, err := jwe.Encrypt(payload, jwa.RSA_OAEP_256, key.Public(), jwa.A256GCM, jwa.NoCompress)
+//	decrypted, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP_256, key)
+package azkeyvault
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/x/internal/signerutil"
+	"github.com/pkg/errors"
+)
+
+// GetKeyInput is the input to Client.GetKey
+type GetKeyInput struct {
+	// KeyID is the key identifier. The version may be omitted,
+	// in which case the latest version is requested.
+	KeyID string
+}
+
+// GetKeyOutput is the output of Client.GetKey
+type GetKeyOutput struct {
+	// JWK is the JSON encoded key, as returned by Key Vault. The "kid"
+	// field must contain the versioned key identifier.
+	JWK []byte
+}
+
+// SignInput is the input to Client.Sign
+type SignInput struct {
+	KeyID     string
+	Algorithm jwa.SignatureAlgorithm
+	Digest    []byte
+}
+
+// SignOutput is the output of Client.Sign
+type SignOutput struct {
+	// Signature is the signature as returned by Key Vault. For ECDSA
+	// keys, this is the R || S concatenation used by JWS.
+	Signature []byte
+}
+
+// VerifyInput is the input to Client.Verify
+type VerifyInput struct {
+	KeyID     string
+	Algorithm jwa.SignatureAlgorithm
+	Digest    []byte
+	Signature []byte
+}
+
+// VerifyOutput is the output of Client.Verify
+type VerifyOutput struct {
+	Valid bool
+}
+
+// WrapKeyInput is the input to Client.WrapKey
+type WrapKeyInput struct {
+	KeyID     string
+	Algorithm jwa.KeyEncryptionAlgorithm
+	Key       []byte
+}
+
+// WrapKeyOutput is the output of Client.WrapKey
+type WrapKeyOutput struct {
+	EncryptedKey []byte
+}
+
+// UnwrapKeyInput is the input to Client.UnwrapKey
+type UnwrapKeyInput struct {
+	KeyID        string
+	Algorithm    jwa.KeyEncryptionAlgorithm
+	EncryptedKey []byte
+}
+
+// UnwrapKeyOutput is the output of Client.UnwrapKey
+type UnwrapKeyOutput struct {
+	Key []byte
+}
+
+// Client is the subset of the Key Vault keys API required by this package.
+type Client interface {
+	GetKey(context.Context, *GetKeyInput) (*GetKeyOutput, error)
+	Sign(context.Context, *SignInput) (*SignOutput, error)
+	Verify(context.Context, *VerifyInput) (*VerifyOutput, error)
+	WrapKey(context.Context, *WrapKeyInput) (*WrapKeyOutput, error)
+	UnwrapKey(context.Context, *UnwrapKeyInput) (*UnwrapKeyOutput, error)
+}
+
+// ParseKeyID parses a Key Vault or Managed HSM key identifier such as
+// `https://myvault.vault.azure.net/keys/mykey/0123456789abcdef`, and
+// returns the vault URL, the key name, and the key version. The
+// version is empty if the identifier does not specify one.
+func ParseKeyID(keyID string) (string, string, string, error) {
+	u, err := url.Parse(keyID)
+	if err != nil {
+		return "", "", "", errors.Wrapf(err, `failed to parse key identifier %q`, keyID)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return "", "", "", errors.Errorf(`invalid key identifier %q: expected https URL`, keyID)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "keys" || parts[1] == "" {
+		return "", "", "", errors.Errorf(`invalid key identifier %q: expected /keys/{name}[/{version}]`, keyID)
+	}
+
+	var version string
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+	return u.Scheme + "://" + u.Host, parts[1], version, nil
+}
+
+// Key is an opaque key stored in Key Vault. The private key never
+// leaves Key Vault.
+type Key struct {
+	client Client
+	ctx    context.Context
+	id     string
+	kid    string
+	pubkey crypto.PublicKey
+}
+
+// New creates a new Key for the Key Vault or Managed HSM key identified
+// by keyID. If the identifier does not contain a version, the latest
+// version at the time of creation is used.
+//
+// The public key is fetched from Key Vault upon creation.
+func New(client Client, keyID string, options ...Option) (*Key, error) {
+	if client == nil {
+		return nil, errors.New(`azkeyvault.New: client must not be nil`)
+	}
+	if _, _, _, err := ParseKeyID(keyID); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var thumbprintKeyID bool
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identContext{}:
+			ctx = option.Value().(context.Context)
+		case identThumbprintKeyID{}:
+			thumbprintKeyID = option.Value().(bool)
+		}
+	}
+
+	out, err := client.GetKey(ctx, &GetKeyInput{KeyID: keyID})
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch key %s`, keyID)
+	}
+
+	id, pubkey, err := parsePublicKey(out.JWK)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = keyID
+	}
+
+	k := &Key{
+		client: client,
+		ctx:    ctx,
+		id:     id,
+		kid:    id,
+		pubkey: pubkey,
+	}
+
+	if thumbprintKeyID {
+		kid, err := signerutil.ThumbprintKeyID(pubkey)
+		if err != nil {
+			return nil, err
+		}
+		k.kid = kid
+	}
+
+	return k, nil
+}
+
+// parsePublicKey parses the JSON encoded key returned by Key Vault.
+// Key Vault uses "RSA-HSM" and "EC-HSM" key types for HSM protected
+// keys, which are normalized to their JWK counterparts.
+func parsePublicKey(data []byte) (string, crypto.PublicKey, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", nil, errors.Wrap(err, `failed to parse key`)
+	}
+
+	kty, _ := m[jwk.KeyTypeKey].(string)
+	kty = strings.TrimSuffix(kty, "-HSM")
+	switch kty {
+	case jwa.RSA.String(), jwa.EC.String():
+	default:
+		return "", nil, errors.Errorf(`unsupported key type %q`, kty)
+	}
+	m[jwk.KeyTypeKey] = kty
+	// key_ops in Key Vault contain values such as "import" that are
+	// not valid in JWK
+	delete(m, jwk.KeyOpsKey)
+
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return "", nil, errors.Wrap(err, `failed to marshal key`)
+	}
+	key, err := jwk.ParseKey(buf)
+	if err != nil {
+		return "", nil, errors.Wrap(err, `failed to parse key`)
+	}
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return "", nil, errors.Wrap(err, `failed to retrieve raw key`)
+	}
+	pubkey, err := jwk.PublicRawKeyOf(raw)
+	if err != nil {
+		return "", nil, errors.Wrap(err, `failed to retrieve public key`)
+	}
+	return key.KeyID(), pubkey, nil
+}
+
+// ID returns the versioned Key Vault key identifier
+func (k *Key) ID() string {
+	return k.id
+}
+
+// KeyID returns the value to be used in the "kid" field. By default
+// this is the versioned Key Vault key identifier, or the RFC7638
+// thumbprint of the public key if WithThumbprintKeyID(true) was specified.
+func (k *Key) KeyID() string {
+	return k.kid
+}
+
+// Public returns the public key
+func (k *Key) Public() crypto.PublicKey {
+	return k.pubkey
+}
+
+// PublicJWK returns the public key as a jwk.Key, with the "kid", "alg"
+// and "use" fields populated. The "alg" field is set to the given
+// signature algorithm.
+func (k *Key) PublicJWK(alg jwa.SignatureAlgorithm) (jwk.Key, error) {
+	if err := k.checkSignatureAlgorithm(alg); err != nil {
+		return nil, err
+	}
+	return signerutil.PublicJWK(k.pubkey, k.kid, alg)
+}
+
+func (k *Key) checkSignatureAlgorithm(alg jwa.SignatureAlgorithm) error {
+	switch pubkey := k.pubkey.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if expected, ok := ecdsaAlgorithm(pubkey.Curve); ok && alg == expected {
+			return nil
+		}
+	}
+	return errors.Errorf(`signature algorithm %s cannot be used with key of type %T`, alg, k.pubkey)
+}
+
+var ecdsaHashes = map[jwa.SignatureAlgorithm]crypto.Hash{
+	jwa.ES256: crypto.SHA256,
+	jwa.ES384: crypto.SHA384,
+	jwa.ES512: crypto.SHA512,
+}
+
+func ecdsaAlgorithm(crv elliptic.Curve) (jwa.SignatureAlgorithm, bool) {
+	switch crv {
+	case elliptic.P256():
+		return jwa.ES256, true
+	case elliptic.P384():
+		return jwa.ES384, true
+	case elliptic.P521():
+		return jwa.ES512, true
+	}
+	return "", false
+}
+
+// signatureAlgorithm determines the signature algorithm from
+// the crypto.SignerOpts passed to Sign
+func (k *Key) signatureAlgorithm(opts crypto.SignerOpts) (jwa.SignatureAlgorithm, error) {
+	if opts == nil {
+		return "", errors.New(`crypto.SignerOpts must be specified`)
+	}
+
+	switch pubkey := k.pubkey.(type) {
+	case *rsa.PublicKey:
+		_, isPSS := opts.(*rsa.PSSOptions)
+		switch opts.HashFunc() {
+		case crypto.SHA256:
+			if isPSS {
+				return jwa.PS256, nil
+			}
+			return jwa.RS256, nil
+		case crypto.SHA384:
+			if isPSS {
+				return jwa.PS384, nil
+			}
+			return jwa.RS384, nil
+		case crypto.SHA512:
+			if isPSS {
+				return jwa.PS512, nil
+			}
+			return jwa.RS512, nil
+		}
+	case *ecdsa.PublicKey:
+		alg, ok := ecdsaAlgorithm(pubkey.Curve)
+		if ok && opts.HashFunc() == ecdsaHashes[alg] {
+			return alg, nil
+		}
+	}
+	return "", errors.Errorf(`unsupported hash function %s for key of type %T`, opts.HashFunc(), k.pubkey)
+}
+
+// Sign signs the given digest using Key Vault, using the context
+// specified by WithContext(). See SignContext for details.
+func (k *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(k.ctx, rand, digest, opts)
+}
+
+// SignContext signs the given digest using Key Vault. The signature
+// algorithm is determined by the key type and opts: for RSA keys, the
+// hash function and the use of *rsa.PSSOptions select between RSxxx and
+// PSxxx. ECDSA signatures are returned in ASN.1 DER format, as required
+// by crypto.Signer. The rand parameter is ignored.
+//
+// SignContext is called by jws.SignContext() and jwt.SignContext(),
+// so that their context is used for the call to Key Vault.
+func (k *Key) SignContext(ctx context.Context, _ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	alg, err := k.signatureAlgorithm(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := k.client.Sign(ctx, &SignInput{
+		KeyID:     k.id,
+		Algorithm: alg,
+		Digest:    digest,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to sign using Key Vault key %s`, k.id)
+	}
+
+	if _, ok := k.pubkey.(*ecdsa.PublicKey); ok {
+		return ecdsaRawToDER(out.Signature)
+	}
+	return out.Signature, nil
+}
+
+func ecdsaRawToDER(raw []byte) ([]byte, error) {
+	if len(raw) == 0 || len(raw)%2 != 0 {
+		return nil, errors.New(`invalid ECDSA signature size`)
+	}
+	n := len(raw) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{
+		R: new(big.Int).SetBytes(raw[:n]),
+		S: new(big.Int).SetBytes(raw[n:]),
+	})
+}
+
+// Verify verifies the signature of the given digest using Key Vault.
+// Note that signatures can also be verified locally using the
+// public key, which is usually preferable.
+func (k *Key) Verify(alg jwa.SignatureAlgorithm, digest, signature []byte) error {
+	if err := k.checkSignatureAlgorithm(alg); err != nil {
+		return err
+	}
+
+	out, err := k.client.Verify(k.ctx, &VerifyInput{
+		KeyID:     k.id,
+		Algorithm: alg,
+		Digest:    digest,
+		Signature: signature,
+	})
+	if err != nil {
+		return errors.Wrapf(err, `failed to verify using Key Vault key %s`, k.id)
+	}
+	if !out.Valid {
+		return errors.New(`invalid signature`)
+	}
+	return nil
+}
+
+func (k *Key) checkKeyEncryptionAlgorithm(alg jwa.KeyEncryptionAlgorithm) error {
+	if _, ok := k.pubkey.(*rsa.PublicKey); !ok {
+		return errors.Errorf(`key of type %T cannot be used to wrap keys`, k.pubkey)
+	}
+	switch alg {
+	case jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256:
+		return nil
+	}
+	return errors.Errorf(`unsupported key encryption algorithm %s`, alg)
+}
+
+// WrapKey encrypts the given content encryption key using Key Vault
+func (k *Key) WrapKey(alg jwa.KeyEncryptionAlgorithm, cek []byte) ([]byte, error) {
+	if err := k.checkKeyEncryptionAlgorithm(alg); err != nil {
+		return nil, err
+	}
+
+	out, err := k.client.WrapKey(k.ctx, &WrapKeyInput{
+		KeyID:     k.id,
+		Algorithm: alg,
+		Key:       cek,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to wrap key using Key Vault key %s`, k.id)
+	}
+	return out.EncryptedKey, nil
+}
+
+// UnwrapKey decrypts the given encrypted content encryption key using Key Vault
+func (k *Key) UnwrapKey(alg jwa.KeyEncryptionAlgorithm, enckey []byte) ([]byte, error) {
+	return k.unwrapKey(k.ctx, alg, enckey)
+}
+
+func (k *Key) unwrapKey(ctx context.Context, alg jwa.KeyEncryptionAlgorithm, enckey []byte) ([]byte, error) {
+	if err := k.checkKeyEncryptionAlgorithm(alg); err != nil {
+		return nil, err
+	}
+
+	out, err := k.client.UnwrapKey(ctx, &UnwrapKeyInput{
+		KeyID:        k.id,
+		Algorithm:    alg,
+		EncryptedKey: enckey,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to unwrap key using Key Vault key %s`, k.id)
+	}
+	return out.Key, nil
+}
+
+// Decrypt implements crypto.Decrypter by unwrapping msg using Key Vault,
+// using the context specified by WithContext(). See DecryptContext for
+// details.
+func (k *Key) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	return k.DecryptContext(k.ctx, rand, msg, opts)
+}
+
+// DecryptContext unwraps msg using Key Vault. opts must be
+// *rsa.OAEPOptions with SHA-1 (RSA-OAEP) or SHA-256 (RSA-OAEP-256), or
+// *rsa.PKCS1v15DecryptOptions (RSA1_5). The rand parameter is ignored.
+//
+// DecryptContext is called by jwe.DecryptContext(), so that its context
+// is used for the call to Key Vault.
+func (k *Key) DecryptContext(ctx context.Context, _ io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	var alg jwa.KeyEncryptionAlgorithm
+	switch opts := opts.(type) {
+	case *rsa.OAEPOptions:
+		if len(opts.Label) > 0 {
+			return nil, errors.New(`OAEP labels are not supported`)
+		}
+		switch opts.Hash {
+		case crypto.SHA1:
+			alg = jwa.RSA_OAEP
+		case crypto.SHA256:
+			alg = jwa.RSA_OAEP_256
+		default:
+			return nil, errors.Errorf(`unsupported OAEP hash function %s`, opts.Hash)
+		}
+	case *rsa.PKCS1v15DecryptOptions, nil:
+		alg = jwa.RSA1_5
+	default:
+		return nil, errors.Errorf(`unsupported decrypter options %T`, opts)
+	}
+	return k.unwrapKey(ctx, alg, msg)
+}
@@ -0,0 +1,361 @@
+package azkeyvault_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/asn1"
+	"hash"
+	"math/big"
+	"testing"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/x/azkeyvault"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testVaultKeyID = `https://jwx.vault.azure.net/keys/signing/0123456789abcdef`
+	testHSMKeyID   = `https://jwx.managedhsm.azure.net/keys/encryption/fedcba9876543210`
+)
+
+// fakeKeyVault emulates the Key Vault keys API using a local private key
+type fakeKeyVault struct {
+	id  string
+	kty string
+	key crypto.Signer
+	// beforeCall, if set, is called at the start of every call to Sign
+	// and UnwrapKey
+	beforeCall func()
+}
+
+func (c *fakeKeyVault) begin(ctx context.Context) error {
+	if c.beforeCall != nil {
+		c.beforeCall()
+	}
+	return ctx.Err()
+}
+
+func (c *fakeKeyVault) GetKey(_ context.Context, _ *azkeyvault.GetKeyInput) (*azkeyvault.GetKeyOutput, error) {
+	key, err := jwk.New(c.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	buf, _ := json.Marshal(key)
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, err
+	}
+	m["kid"] = c.id
+	m["kty"] = c.kty
+	m["key_ops"] = []string{"sign", "verify", "wrapKey", "unwrapKey", "import"}
+	buf, _ = json.Marshal(m)
+	return &azkeyvault.GetKeyOutput{JWK: buf}, nil
+}
+
+func signerOpts(alg jwa.SignatureAlgorithm) crypto.SignerOpts {
+	switch alg {
+	case jwa.RS256, jwa.ES256:
+		return crypto.SHA256
+	case jwa.RS384, jwa.ES384:
+		return crypto.SHA384
+	case jwa.RS512, jwa.ES512:
+		return crypto.SHA512
+	case jwa.PS256:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	case jwa.PS384:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}
+	default:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}
+	}
+}
+
+func (c *fakeKeyVault) Sign(ctx context.Context, in *azkeyvault.SignInput) (*azkeyvault.SignOutput, error) {
+	if err := c.begin(ctx); err != nil {
+		return nil, err
+	}
+	if in.KeyID != c.id {
+		return nil, errors.Errorf(`unknown key %s`, in.KeyID)
+	}
+	signature, err := c.key.Sign(rand.Reader, in.Digest, signerOpts(in.Algorithm))
+	if err != nil {
+		return nil, err
+	}
+
+	// Key Vault returns ECDSA signatures as R || S
+	if eckey, ok := c.key.(*ecdsa.PrivateKey); ok {
+		var sig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			return nil, err
+		}
+		size := (eckey.Curve.Params().BitSize + 7) / 8
+		raw := make([]byte, 2*size)
+		sig.R.FillBytes(raw[:size])
+		sig.S.FillBytes(raw[size:])
+		signature = raw
+	}
+	return &azkeyvault.SignOutput{Signature: signature}, nil
+}
+
+func (c *fakeKeyVault) Verify(_ context.Context, in *azkeyvault.VerifyInput) (*azkeyvault.VerifyOutput, error) {
+	pubkey, ok := c.key.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New(`only RSA keys are supported by this fake`)
+	}
+	opts := signerOpts(in.Algorithm)
+	var err error
+	if pssopts, ok := opts.(*rsa.PSSOptions); ok {
+		err = rsa.VerifyPSS(pubkey, opts.HashFunc(), in.Digest, in.Signature, pssopts)
+	} else {
+		err = rsa.VerifyPKCS1v15(pubkey, opts.HashFunc(), in.Digest, in.Signature)
+	}
+	return &azkeyvault.VerifyOutput{Valid: err == nil}, nil
+}
+
+func oaepHash(alg jwa.KeyEncryptionAlgorithm) hash.Hash {
+	if alg == jwa.RSA_OAEP_256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+func (c *fakeKeyVault) WrapKey(_ context.Context, in *azkeyvault.WrapKeyInput) (*azkeyvault.WrapKeyOutput, error) {
+	pubkey := c.key.Public().(*rsa.PublicKey)
+	var enckey []byte
+	var err error
+	if in.Algorithm == jwa.RSA1_5 {
+		enckey, err = rsa.EncryptPKCS1v15(rand.Reader, pubkey, in.Key)
+	} else {
+		enckey, err = rsa.EncryptOAEP(oaepHash(in.Algorithm), rand.Reader, pubkey, in.Key, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &azkeyvault.WrapKeyOutput{EncryptedKey: enckey}, nil
+}
+
+func (c *fakeKeyVault) UnwrapKey(ctx context.Context, in *azkeyvault.UnwrapKeyInput) (*azkeyvault.UnwrapKeyOutput, error) {
+	if err := c.begin(ctx); err != nil {
+		return nil, err
+	}
+	privkey := c.key.(*rsa.PrivateKey)
+	var cek []byte
+	var err error
+	if in.Algorithm == jwa.RSA1_5 {
+		cek, err = rsa.DecryptPKCS1v15(rand.Reader, privkey, in.EncryptedKey)
+	} else {
+		cek, err = rsa.DecryptOAEP(oaepHash(in.Algorithm), rand.Reader, privkey, in.EncryptedKey, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &azkeyvault.UnwrapKeyOutput{Key: cek}, nil
+}
+
+func TestParseKeyID(t *testing.T) {
+	t.Parallel()
+
+	vault, name, version, err := azkeyvault.ParseKeyID(testVaultKeyID)
+	if !assert.NoError(t, err, `azkeyvault.ParseKeyID should succeed`) {
+		return
+	}
+	if !assert.Equal(t, []string{`https://jwx.vault.azure.net`, `signing`, `0123456789abcdef`}, []string{vault, name, version}, `parsed values should match`) {
+		return
+	}
+
+	_, name, version, err = azkeyvault.ParseKeyID(`https://jwx.managedhsm.azure.net/keys/encryption`)
+	if !assert.NoError(t, err, `azkeyvault.ParseKeyID should succeed`) {
+		return
+	}
+	if !assert.Equal(t, []string{`encryption`, ``}, []string{name, version}, `parsed values should match`) {
+		return
+	}
+
+	for _, bad := range []string{`http://jwx.vault.azure.net/keys/signing`, `https://jwx.vault.azure.net/secrets/signing`, `https://jwx.vault.azure.net/keys/`} {
+		if _, _, _, err := azkeyvault.ParseKeyID(bad); !assert.Error(t, err, `azkeyvault.ParseKeyID(%q) should fail`, bad) {
+			return
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P384)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	t.Run("Sign", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Client    *fakeKeyVault
+			Algorithm jwa.SignatureAlgorithm
+		}{
+			{Client: &fakeKeyVault{id: testVaultKeyID, kty: "RSA", key: rsakey}, Algorithm: jwa.RS256},
+			{Client: &fakeKeyVault{id: testVaultKeyID, kty: "RSA", key: rsakey}, Algorithm: jwa.PS512},
+			{Client: &fakeKeyVault{id: testHSMKeyID, kty: "RSA-HSM", key: rsakey}, Algorithm: jwa.PS256},
+			{Client: &fakeKeyVault{id: testHSMKeyID, kty: "EC-HSM", key: eckey}, Algorithm: jwa.ES384},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Client.kty+"/"+tc.Algorithm.String(), func(t *testing.T) {
+				t.Parallel()
+				key, err := azkeyvault.New(tc.Client, tc.Client.id)
+				if !assert.NoError(t, err, `azkeyvault.New should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Client.id, key.KeyID(), `kid should be the key identifier`) {
+					return
+				}
+
+				pubkey, err := key.PublicJWK(tc.Algorithm)
+				if !assert.NoError(t, err, `key.PublicJWK should succeed`) {
+					return
+				}
+
+				payload := []byte(`Lorem ipsum`)
+				signed, err := jws.Sign(payload, tc.Algorithm, key)
+				if !assert.NoError(t, err, `jws.Sign should succeed`) {
+					return
+				}
+				verified, err := jws.Verify(signed, tc.Algorithm, pubkey)
+				if !assert.NoError(t, err, `jws.Verify should succeed`) {
+					return
+				}
+				if !assert.Equal(t, payload, verified, `payloads should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("SignContext", func(t *testing.T) {
+		t.Parallel()
+		// the context is canceled while the request is in flight, which
+		// the client only notices if it receives the per-call context
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		key, err := azkeyvault.New(&fakeKeyVault{id: testVaultKeyID, kty: "RSA", key: rsakey, beforeCall: cancel}, testVaultKeyID)
+		if !assert.NoError(t, err, `azkeyvault.New should succeed`) {
+			return
+		}
+		_, err = jws.SignContext(ctx, []byte(`Lorem ipsum`), jwa.RS256, key)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jws.SignContext should fail with context.Canceled (got %v)`, err) {
+			return
+		}
+	})
+	t.Run("Verify", func(t *testing.T) {
+		t.Parallel()
+		key, err := azkeyvault.New(&fakeKeyVault{id: testVaultKeyID, kty: "RSA", key: rsakey}, testVaultKeyID)
+		if !assert.NoError(t, err, `azkeyvault.New should succeed`) {
+			return
+		}
+
+		digest := sha256.Sum256([]byte(`Lorem ipsum`))
+		signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if !assert.NoError(t, err, `key.Sign should succeed`) {
+			return
+		}
+		if !assert.NoError(t, key.Verify(jwa.RS256, digest[:], signature), `key.Verify should succeed`) {
+			return
+		}
+		signature[0] ^= 0xff
+		if !assert.Error(t, key.Verify(jwa.RS256, digest[:], signature), `key.Verify should fail`) {
+			return
+		}
+		if !assert.Error(t, key.Verify(jwa.ES256, digest[:], signature), `key.Verify should fail for mismatched algorithm`) {
+			return
+		}
+	})
+	t.Run("Decrypt", func(t *testing.T) {
+		t.Parallel()
+		key, err := azkeyvault.New(&fakeKeyVault{id: testHSMKeyID, kty: "RSA-HSM", key: rsakey}, testHSMKeyID, azkeyvault.WithThumbprintKeyID(true))
+		if !assert.NoError(t, err, `azkeyvault.New should succeed`) {
+			return
+		}
+		if !assert.NotEqual(t, testHSMKeyID, key.KeyID(), `kid should be a thumbprint`) {
+			return
+		}
+
+		payload := []byte(`Lorem ipsum`)
+		for _, alg := range []jwa.KeyEncryptionAlgorithm{jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256} {
+			if alg == jwa.RSA1_5 && jwx.FIPSMode() {
+				// RSA1_5 is not approved in FIPS mode
+				continue
+			}
+			encrypted, err := jwe.Encrypt(payload, alg, key.Public(), jwa.A256GCM, jwa.NoCompress)
+			if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+				return
+			}
+			decrypted, err := jwe.Decrypt(encrypted, alg, key)
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, decrypted, `payloads should match`) {
+				return
+			}
+		}
+	})
+	t.Run("DecryptContext", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		key, err := azkeyvault.New(&fakeKeyVault{id: testHSMKeyID, kty: "RSA-HSM", key: rsakey, beforeCall: cancel}, testHSMKeyID)
+		if !assert.NoError(t, err, `azkeyvault.New should succeed`) {
+			return
+		}
+		encrypted, err := jwe.Encrypt([]byte(`Lorem ipsum`), jwa.RSA_OAEP_256, key.Public(), jwa.A256GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		// jwe.DecryptContext reports the errors of all recipients as text
+		_, err = jwe.DecryptContext(ctx, encrypted, jwa.RSA_OAEP_256, key)
+		if !assert.Error(t, err, `jwe.DecryptContext should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), context.Canceled.Error(), `error should be context.Canceled`) {
+			return
+		}
+	})
+	t.Run("WrapKey/UnwrapKey", func(t *testing.T) {
+		t.Parallel()
+		key, err := azkeyvault.New(&fakeKeyVault{id: testHSMKeyID, kty: "RSA-HSM", key: rsakey}, testHSMKeyID)
+		if !assert.NoError(t, err, `azkeyvault.New should succeed`) {
+			return
+		}
+
+		cek := make([]byte, 32)
+		_, _ = rand.Read(cek)
+		wrapped, err := key.WrapKey(jwa.RSA_OAEP_256, cek)
+		if !assert.NoError(t, err, `key.WrapKey should succeed`) {
+			return
+		}
+		unwrapped, err := key.UnwrapKey(jwa.RSA_OAEP_256, wrapped)
+		if !assert.NoError(t, err, `key.UnwrapKey should succeed`) {
+			return
+		}
+		if !assert.Equal(t, cek, unwrapped, `keys should match`) {
+			return
+		}
+		if _, err := key.WrapKey(jwa.A128KW, cek); !assert.Error(t, err, `key.WrapKey should fail for unsupported algorithms`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package azkeyvault
+
+import (
+	"context"
+
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+type identContext struct{}
+type identThumbprintKeyID struct{}
+
+// WithContext specifies the context.Context object to use when calling
+// the Key Vault API. As `crypto.Signer` and `crypto.Decrypter` do not
+// accept a context, this context is used for all subsequent calls to
+// `Sign()` and `Decrypt()`. Calls to `SignContext()` and
+// `DecryptContext()`, such as those made by `jws.SignContext()` and
+// `jwe.DecryptContext()`, use the context passed to them instead.
+// By default `context.Background()` is used.
+func WithContext(ctx context.Context) Option {
+	return option.New(identContext{}, ctx)
+}
+
+// WithThumbprintKeyID specifies that the key ID should be computed as the
+// RFC7638 thumbprint (SHA-256) of the public key, instead of using the
+// Key Vault key identifier.
+func WithThumbprintKeyID(v bool) Option {
+	return option.New(identThumbprintKeyID{}, v)
+}
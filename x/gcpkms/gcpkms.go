@@ -0,0 +1,305 @@
+// Package gcpkms provides a crypto.Signer backed by asymmetric signing
+// keys stored in Google Cloud Key Management Service, which can be used
+// directly with jws.Sign and jwt.Sign.
+//
+// The JWS algorithm is automatically derived from the algorithm of the
+// key version (e.g. EC_SIGN_P256_SHA256 maps to ES256, and
+// RSA_SIGN_PSS_2048_SHA256 maps to PS256).
+//
+// This package does not depend on the Google Cloud client libraries.
+// Instead, it works with any value that satisfies the `Client`
+// interface, which is a thin subset of the Cloud KMS API. An adapter
+// for cloud.google.com/go/kms/apiv1 is a few lines:
+//
+//	type kmsClient struct {
+//	  client *kms.KeyManagementClient
+//	}
+//
+//	func (c kmsClient) GetPublicKey(ctx context.Context, in *gcpkms.GetPublicKeyInput) (*gcpkms.GetPublicKeyOutput, error) {
+//	  out, err := c.client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: in.Name})
+//	  if err != nil {
+//	    return nil, err
+//	  }
+//	  return &gcpkms.GetPublicKeyOutput{Name: out.Name, PEM: out.Pem, Algorithm: out.Algorithm.String()}, nil
+//	}
+//
+//	func (c kmsClient) AsymmetricSign(ctx context.Context, in *gcpkms.AsymmetricSignInput) (*gcpkms.AsymmetricSignOutput, error) {
+//	  req := &kmspb.AsymmetricSignRequest{Name: in.Name, Data: in.Data}
+//	  switch in.DigestAlgorithm {
+//	  case crypto.SHA256:
+//	    req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha256{Sha256: in.Digest}}
+//	  case crypto.SHA384:
+//	    req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha384{Sha384: in.Digest}}
+//	  case crypto.SHA512:
+//	    req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha512{Sha512: in.Digest}}
+//	  }
+//	  out, err := c.client.AsymmetricSign(ctx, req)
+//	  if err != nil {
+//	    return nil, err
+//	  }
+//	  return &gcpkms.AsymmetricSignOutput{Signature: out.Signature}, nil
+//	}
+//
+// Then create a signer and use it as the key:
+//
+//	signer, err := gcpkms.New(kmsClient{client: client}, "projects/.../cryptoKeyVersions/1")
+//	pubkey, err := signer.PublicJWK()  // publish this in your JWKS
+//	hdrs := jws.NewHeaders()
+//	hdrs.Set(jws.KeyIDKey, signer.KeyID())
+//	signed, err := jwt.Sign(token, signer.Algorithm(), signer, jwt.WithHeaders(hdrs))
+package gcpkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/x/internal/signerutil"
+	"github.com/pkg/errors"
+)
+
+type algorithmSpec struct {
+	alg  jwa.SignatureAlgorithm
+	hash crypto.Hash
+}
+
+// algorithms maps Cloud KMS CryptoKeyVersionAlgorithm names to
+// their JWS counterparts
+var algorithms = map[string]algorithmSpec{
+	"RSA_SIGN_PSS_2048_SHA256":   {alg: jwa.PS256, hash: crypto.SHA256},
+	"RSA_SIGN_PSS_3072_SHA256":   {alg: jwa.PS256, hash: crypto.SHA256},
+	"RSA_SIGN_PSS_4096_SHA256":   {alg: jwa.PS256, hash: crypto.SHA256},
+	"RSA_SIGN_PSS_4096_SHA512":   {alg: jwa.PS512, hash: crypto.SHA512},
+	"RSA_SIGN_PKCS1_2048_SHA256": {alg: jwa.RS256, hash: crypto.SHA256},
+	"RSA_SIGN_PKCS1_3072_SHA256": {alg: jwa.RS256, hash: crypto.SHA256},
+	"RSA_SIGN_PKCS1_4096_SHA256": {alg: jwa.RS256, hash: crypto.SHA256},
+	"RSA_SIGN_PKCS1_4096_SHA512": {alg: jwa.RS512, hash: crypto.SHA512},
+	"EC_SIGN_P256_SHA256":        {alg: jwa.ES256, hash: crypto.SHA256},
+	"EC_SIGN_P384_SHA384":        {alg: jwa.ES384, hash: crypto.SHA384},
+	"EC_SIGN_ED25519":            {alg: jwa.EdDSA},
+}
+
+// Algorithm returns the JWS algorithm that corresponds to the given
+// Cloud KMS key version algorithm (e.g. "EC_SIGN_P256_SHA256")
+func Algorithm(v string) (jwa.SignatureAlgorithm, error) {
+	spec, ok := algorithms[v]
+	if !ok {
+		return "", errors.Errorf(`unsupported key version algorithm %q`, v)
+	}
+	return spec.alg, nil
+}
+
+// GetPublicKeyInput is the input to Client.GetPublicKey
+type GetPublicKeyInput struct {
+	// Name is the resource name of the key version
+	Name string
+}
+
+// GetPublicKeyOutput is the output of Client.GetPublicKey
+type GetPublicKeyOutput struct {
+	// Name is the resource name of the key version
+	Name string
+	// PEM is the PEM encoded public key
+	PEM string
+	// Algorithm is the name of the key version algorithm
+	// (e.g. "EC_SIGN_P256_SHA256")
+	Algorithm string
+}
+
+// AsymmetricSignInput is the input to Client.AsymmetricSign. Either
+// Digest (along with DigestAlgorithm) or Data is populated, as
+// Ed25519 keys sign the data directly.
+type AsymmetricSignInput struct {
+	Name            string
+	Digest          []byte
+	DigestAlgorithm crypto.Hash
+	Data            []byte
+}
+
+// AsymmetricSignOutput is the output of Client.AsymmetricSign
+type AsymmetricSignOutput struct {
+	// Signature is the signature as returned by Cloud KMS. For ECDSA
+	// keys, this is an ASN.1 DER encoded signature.
+	Signature []byte
+}
+
+// Client is the subset of the Cloud KMS API required by this package.
+type Client interface {
+	GetPublicKey(context.Context, *GetPublicKeyInput) (*GetPublicKeyOutput, error)
+	AsymmetricSign(context.Context, *AsymmetricSignInput) (*AsymmetricSignOutput, error)
+}
+
+// Signer is a crypto.Signer that delegates signing operations to
+// Cloud KMS. The private key never leaves Cloud KMS.
+type Signer struct {
+	client Client
+	ctx    context.Context
+	kid    string
+	name   string
+	pubkey crypto.PublicKey
+	spec   algorithmSpec
+}
+
+// New creates a new Signer for the key version identified by its
+// resource name (projects/*/locations/*/keyRings/*/cryptoKeys/*/cryptoKeyVersions/*).
+//
+// The public key and the key version algorithm are fetched from
+// Cloud KMS upon creation.
+func New(client Client, name string, options ...Option) (*Signer, error) {
+	if client == nil {
+		return nil, errors.New(`gcpkms.New: client must not be nil`)
+	}
+
+	ctx := context.Background()
+	var thumbprintKeyID bool
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identContext{}:
+			ctx = option.Value().(context.Context)
+		case identThumbprintKeyID{}:
+			thumbprintKeyID = option.Value().(bool)
+		}
+	}
+
+	out, err := client.GetPublicKey(ctx, &GetPublicKeyInput{Name: name})
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch public key for %s`, name)
+	}
+
+	spec, ok := algorithms[out.Algorithm]
+	if !ok {
+		return nil, errors.Errorf(`unsupported key version algorithm %q`, out.Algorithm)
+	}
+
+	block, _ := pem.Decode([]byte(out.PEM))
+	if block == nil {
+		return nil, errors.New(`failed to decode PEM encoded public key`)
+	}
+	pubkey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse public key`)
+	}
+	if err := checkAlgorithm(spec.alg, pubkey); err != nil {
+		return nil, err
+	}
+
+	if out.Name != "" {
+		name = out.Name
+	}
+
+	s := &Signer{
+		client: client,
+		ctx:    ctx,
+		kid:    name,
+		name:   name,
+		pubkey: pubkey,
+		spec:   spec,
+	}
+
+	if thumbprintKeyID {
+		kid, err := signerutil.ThumbprintKeyID(pubkey)
+		if err != nil {
+			return nil, err
+		}
+		s.kid = kid
+	}
+
+	return s, nil
+}
+
+func checkAlgorithm(alg jwa.SignatureAlgorithm, pubkey crypto.PublicKey) error {
+	var ok bool
+	switch alg {
+	case jwa.RS256, jwa.RS512, jwa.PS256, jwa.PS512:
+		_, ok = pubkey.(*rsa.PublicKey)
+	case jwa.ES256, jwa.ES384:
+		_, ok = pubkey.(*ecdsa.PublicKey)
+	case jwa.EdDSA:
+		_, ok = pubkey.(ed25519.PublicKey)
+	}
+	if !ok {
+		return errors.Errorf(`signature algorithm %s cannot be used with key of type %T`, alg, pubkey)
+	}
+	return nil
+}
+
+// Algorithm returns the JWS algorithm used by this signer
+func (s *Signer) Algorithm() jwa.SignatureAlgorithm {
+	return s.spec.alg
+}
+
+// Name returns the resource name of the key version
+func (s *Signer) Name() string {
+	return s.name
+}
+
+// KeyID returns the value to be used in the "kid" field. By default
+// this is the key version resource name, or the RFC7638 thumbprint of
+// the public key if WithThumbprintKeyID(true) was specified.
+func (s *Signer) KeyID() string {
+	return s.kid
+}
+
+// Public returns the public key associated with the key version
+func (s *Signer) Public() crypto.PublicKey {
+	return s.pubkey
+}
+
+// PublicJWK returns the public key as a jwk.Key, with the "kid", "alg"
+// and "use" fields populated.
+func (s *Signer) PublicJWK() (jwk.Key, error) {
+	return signerutil.PublicJWK(s.pubkey, s.kid, s.spec.alg)
+}
+
+// Sign signs the given digest using Cloud KMS, using the context
+// specified by WithContext(). See SignContext for details.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(s.ctx, rand, digest, opts)
+}
+
+// SignContext signs the given digest using Cloud KMS. The hash function
+// and padding scheme specified in opts must match the algorithm of the
+// key version. For Ed25519 keys, digest is the message itself, and opts
+// must specify crypto.Hash(0). The rand parameter is ignored.
+//
+// SignContext is called by jws.SignContext() and jwt.SignContext(),
+// so that their context is used for the call to Cloud KMS.
+func (s *Signer) SignContext(ctx context.Context, _ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts == nil || opts.HashFunc() != s.spec.hash {
+		return nil, errors.Errorf(`hash function does not match algorithm %s`, s.spec.alg)
+	}
+
+	_, isPSS := opts.(*rsa.PSSOptions)
+	switch s.spec.alg {
+	case jwa.PS256, jwa.PS512:
+		if !isPSS {
+			return nil, errors.Errorf(`algorithm %s requires *rsa.PSSOptions`, s.spec.alg)
+		}
+	default:
+		if isPSS {
+			return nil, errors.Errorf(`algorithm %s cannot be used with *rsa.PSSOptions`, s.spec.alg)
+		}
+	}
+
+	in := &AsymmetricSignInput{Name: s.name}
+	if s.spec.hash == 0 {
+		in.Data = digest
+	} else {
+		in.Digest = digest
+		in.DigestAlgorithm = s.spec.hash
+	}
+
+	out, err := s.client.AsymmetricSign(ctx, in)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to sign using Cloud KMS key %s`, s.name)
+	}
+	return out.Signature, nil
+}
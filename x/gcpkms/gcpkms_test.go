@@ -0,0 +1,228 @@
+package gcpkms_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/x/gcpkms"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testKeyName = `projects/jwx/locations/global/keyRings/test/cryptoKeys/signing/cryptoKeyVersions/1`
+
+// fakeKMS emulates the Cloud KMS API using a local private key
+type fakeKMS struct {
+	algorithm string
+	key       crypto.Signer
+	// beforeSign, if set, is called at the start of every call to AsymmetricSign
+	beforeSign func()
+}
+
+func (c *fakeKMS) GetPublicKey(_ context.Context, in *gcpkms.GetPublicKeyInput) (*gcpkms.GetPublicKeyOutput, error) {
+	der, err := x509.MarshalPKIXPublicKey(c.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &gcpkms.GetPublicKeyOutput{
+		Name:      in.Name,
+		PEM:       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		Algorithm: c.algorithm,
+	}, nil
+}
+
+func (c *fakeKMS) AsymmetricSign(ctx context.Context, in *gcpkms.AsymmetricSignInput) (*gcpkms.AsymmetricSignOutput, error) {
+	if c.beforeSign != nil {
+		c.beforeSign()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var signature []byte
+	var err error
+	switch c.algorithm {
+	case "EC_SIGN_ED25519":
+		if len(in.Digest) > 0 {
+			return nil, errors.New(`ed25519 keys must sign data`)
+		}
+		signature, err = c.key.Sign(rand.Reader, in.Data, crypto.Hash(0))
+	case "RSA_SIGN_PSS_2048_SHA256":
+		signature, err = c.key.Sign(rand.Reader, in.Digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: in.DigestAlgorithm})
+	default:
+		if in.DigestAlgorithm.Size() != len(in.Digest) {
+			return nil, errors.New(`invalid digest length`)
+		}
+		signature, err = c.key.Sign(rand.Reader, in.Digest, in.DigestAlgorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gcpkms.AsymmetricSignOutput{Signature: signature}, nil
+}
+
+func TestAlgorithm(t *testing.T) {
+	t.Parallel()
+	for name, expected := range map[string]jwa.SignatureAlgorithm{
+		"EC_SIGN_P256_SHA256":        jwa.ES256,
+		"EC_SIGN_P384_SHA384":        jwa.ES384,
+		"RSA_SIGN_PSS_2048_SHA256":   jwa.PS256,
+		"RSA_SIGN_PKCS1_4096_SHA512": jwa.RS512,
+		"EC_SIGN_ED25519":            jwa.EdDSA,
+	} {
+		alg, err := gcpkms.Algorithm(name)
+		if !assert.NoError(t, err, `gcpkms.Algorithm should succeed for %s`, name) {
+			return
+		}
+		if !assert.Equal(t, expected, alg, `algorithm should match for %s`, name) {
+			return
+		}
+	}
+
+	_, err := gcpkms.Algorithm("GOOGLE_SYMMETRIC_ENCRYPTION")
+	if !assert.Error(t, err, `gcpkms.Algorithm should fail for non-signing keys`) {
+		return
+	}
+}
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	p256key, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	p384key, err := jwxtest.GenerateEcdsaKey(jwa.P384)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	edkey, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Algorithm string
+		Key       crypto.Signer
+		Expected  jwa.SignatureAlgorithm
+	}{
+		{Algorithm: "RSA_SIGN_PSS_2048_SHA256", Key: rsakey, Expected: jwa.PS256},
+		{Algorithm: "RSA_SIGN_PKCS1_2048_SHA256", Key: rsakey, Expected: jwa.RS256},
+		{Algorithm: "EC_SIGN_P256_SHA256", Key: p256key, Expected: jwa.ES256},
+		{Algorithm: "EC_SIGN_P384_SHA384", Key: p384key, Expected: jwa.ES384},
+		{Algorithm: "EC_SIGN_ED25519", Key: edkey, Expected: jwa.EdDSA},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Algorithm, func(t *testing.T) {
+			t.Parallel()
+			signer, err := gcpkms.New(&fakeKMS{algorithm: tc.Algorithm, key: tc.Key}, testKeyName)
+			if !assert.NoError(t, err, `gcpkms.New should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, signer.Algorithm(), `algorithm should match`) {
+				return
+			}
+			if !assert.Equal(t, testKeyName, signer.KeyID(), `kid should be the resource name`) {
+				return
+			}
+
+			pubkey, err := signer.PublicJWK()
+			if !assert.NoError(t, err, `signer.PublicJWK should succeed`) {
+				return
+			}
+
+			payload := []byte(`Lorem ipsum`)
+			signed, err := jws.Sign(payload, signer.Algorithm(), signer)
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+			verified, err := jws.Verify(signed, signer.Algorithm(), pubkey)
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payloads should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("jwt.Sign with kid", func(t *testing.T) {
+		t.Parallel()
+		signer, err := gcpkms.New(&fakeKMS{algorithm: "EC_SIGN_P256_SHA256", key: p256key}, testKeyName, gcpkms.WithThumbprintKeyID(true))
+		if !assert.NoError(t, err, `gcpkms.New should succeed`) {
+			return
+		}
+		if !assert.NotEqual(t, testKeyName, signer.KeyID(), `kid should be a thumbprint`) {
+			return
+		}
+
+		token := jwt.New()
+		_ = token.Set(jwt.SubjectKey, `service-account`)
+
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.KeyIDKey, signer.KeyID())
+		signed, err := jwt.Sign(token, signer.Algorithm(), signer, jwt.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+
+		pubkey, err := signer.PublicJWK()
+		if !assert.NoError(t, err, `signer.PublicJWK should succeed`) {
+			return
+		}
+		set := jwk.NewSet()
+		set.Add(pubkey)
+
+		parsed, err := jwt.Parse(signed, jwt.WithKeySet(set))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `service-account`, parsed.Subject(), `subject should match`) {
+			return
+		}
+	})
+	t.Run("jws.SignContext", func(t *testing.T) {
+		t.Parallel()
+		// the context is canceled while the request is in flight, which
+		// the client only notices if it receives the per-call context
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		signer, err := gcpkms.New(&fakeKMS{algorithm: "EC_SIGN_P256_SHA256", key: p256key, beforeSign: cancel}, testKeyName)
+		if !assert.NoError(t, err, `gcpkms.New should succeed`) {
+			return
+		}
+		_, err = jws.SignContext(ctx, []byte(`Lorem ipsum`), signer.Algorithm(), signer)
+		if !assert.True(t, errors.Is(err, context.Canceled), `jws.SignContext should fail with context.Canceled (got %v)`, err) {
+			return
+		}
+	})
+	t.Run("mismatched key type", func(t *testing.T) {
+		t.Parallel()
+		_, err := gcpkms.New(&fakeKMS{algorithm: "EC_SIGN_P256_SHA256", key: rsakey}, testKeyName)
+		if !assert.Error(t, err, `gcpkms.New should fail`) {
+			return
+		}
+	})
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		t.Parallel()
+		_, err := gcpkms.New(&fakeKMS{algorithm: "RSA_DECRYPT_OAEP_2048_SHA256", key: rsakey}, testKeyName)
+		if !assert.Error(t, err, `gcpkms.New should fail`) {
+			return
+		}
+	})
+}
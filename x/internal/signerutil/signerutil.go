@@ -0,0 +1,46 @@
+// Package signerutil contains utilities shared by the crypto.Signer
+// implementations backed by remote key management services.
+package signerutil
+
+import (
+	"crypto"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// ThumbprintKeyID computes the RFC7638 thumbprint (SHA-256) of the
+// given public key, encoded in base64url, for use as a key ID
+func ThumbprintKeyID(pubkey crypto.PublicKey) (string, error) {
+	key, err := jwk.New(pubkey)
+	if err != nil {
+		return "", errors.Wrap(err, `failed to create jwk.Key from public key`)
+	}
+	tp, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", errors.Wrap(err, `failed to compute thumbprint`)
+	}
+	return base64.EncodeToString(tp), nil
+}
+
+// PublicJWK creates a jwk.Key from the public key, with the "kid", "alg"
+// and "use" fields populated
+func PublicJWK(pubkey crypto.PublicKey, kid string, alg jwa.SignatureAlgorithm) (jwk.Key, error) {
+	key, err := jwk.New(pubkey)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create jwk.Key from public key`)
+	}
+
+	for k, v := range map[string]interface{}{
+		jwk.KeyIDKey:     kid,
+		jwk.AlgorithmKey: alg.String(),
+		jwk.KeyUsageKey:  jwk.ForSignature,
+	} {
+		if err := key.Set(k, v); err != nil {
+			return nil, errors.Wrapf(err, `failed to set %q`, k)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,281 @@
+package pkcs11
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws/rsaprim"
+	"github.com/lestrrat-go/jwx/x/internal/signerutil"
+	"github.com/pkg/errors"
+)
+
+var (
+	oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// ulongValue decodes a CK_ULONG attribute value. The value is
+// encoded in the native byte order and size of the platform, but as
+// the values we are interested in (key types) are small, looking for
+// the non-zero byte works for either byte order.
+func ulongValue(v []byte) uint {
+	var n uint
+	for _, b := range v {
+		if b != 0 {
+			n = uint(b)
+		}
+	}
+	return n
+}
+
+func readPublicKey(module Module, sh SessionHandle, obj ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := module.GetAttributeValue(sh, obj, []*Attribute{{Type: AttributeKeyType}})
+	if err != nil || len(attrs) != 1 {
+		return nil, errors.Wrap(err, `failed to read key type`)
+	}
+	kt, _ := attrs[0].Value.([]byte)
+
+	switch ulongValue(kt) {
+	case KeyTypeRSA:
+		attrs, err := module.GetAttributeValue(sh, obj, []*Attribute{{Type: AttributeModulus}, {Type: AttributePublicExponent}})
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to read RSA public key`)
+		}
+		values := attributeValues(attrs)
+		n, e := values[AttributeModulus], values[AttributePublicExponent]
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New(`invalid RSA public key`)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case KeyTypeEC:
+		attrs, err := module.GetAttributeValue(sh, obj, []*Attribute{{Type: AttributeECParams}, {Type: AttributeECPoint}})
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to read EC public key`)
+		}
+		values := attributeValues(attrs)
+		return parseECPublicKey(values[AttributeECParams], values[AttributeECPoint])
+	default:
+		return nil, errors.Errorf(`unsupported key type %d`, ulongValue(kt))
+	}
+}
+
+func attributeValues(attrs []*Attribute) map[AttributeType][]byte {
+	values := make(map[AttributeType][]byte)
+	for _, attr := range attrs {
+		if v, ok := attr.Value.([]byte); ok {
+			values[attr.Type] = v
+		}
+	}
+	return values
+}
+
+func parseECPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, errors.Wrap(err, `failed to parse CKA_EC_PARAMS`)
+	}
+
+	var crv elliptic.Curve
+	switch {
+	case oid.Equal(oidP256):
+		crv = elliptic.P256()
+	case oid.Equal(oidP384):
+		crv = elliptic.P384()
+	case oid.Equal(oidP521):
+		crv = elliptic.P521()
+	default:
+		return nil, errors.Errorf(`unsupported curve %s`, oid)
+	}
+
+	// CKA_EC_POINT is a DER encoded OCTET STRING, but some tokens
+	// return the raw point instead
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) > 0 {
+		raw = point
+	}
+
+	x, y := elliptic.Unmarshal(crv, raw) //nolint:staticcheck
+	if x == nil {
+		return nil, errors.New(`invalid CKA_EC_POINT`)
+	}
+	return &ecdsa.PublicKey{Curve: crv, X: x, Y: y}, nil
+}
+
+// Key is a private key stored in a PKCS#11 token. It implements
+// crypto.Signer for RSA (PKCS#1 v1.5 and PSS) and ECDSA keys, and
+// crypto.Decrypter for RSA keys (PKCS#1 v1.5 and OAEP). The private
+// key never leaves the token.
+type Key struct {
+	handle ObjectHandle
+	pubkey crypto.PublicKey
+	token  *Token
+}
+
+// Public returns the public key
+func (k *Key) Public() crypto.PublicKey {
+	return k.pubkey
+}
+
+// PublicJWK returns the public key as a jwk.Key, with the "alg" and
+// "use" fields populated. The "kid" field is set to the RFC7638
+// thumbprint of the public key.
+func (k *Key) PublicJWK(alg jwa.SignatureAlgorithm) (jwk.Key, error) {
+	kid, err := signerutil.ThumbprintKeyID(k.pubkey)
+	if err != nil {
+		return nil, err
+	}
+	return signerutil.PublicJWK(k.pubkey, kid, alg)
+}
+
+func (k *Key) signMechanism(digest []byte, opts crypto.SignerOpts) (*Mechanism, []byte, error) {
+	if opts == nil {
+		return nil, nil, errors.New(`crypto.SignerOpts must be specified`)
+	}
+	hash := opts.HashFunc()
+	if hash == 0 || len(digest) != hash.Size() {
+		return nil, nil, errors.Errorf(`digest length does not match hash function %s`, hash)
+	}
+
+	switch k.pubkey.(type) {
+	case *rsa.PublicKey:
+		if pssopts, ok := opts.(*rsa.PSSOptions); ok {
+			hm, ok := hashMechanisms[hash]
+			if !ok {
+				return nil, nil, errors.Errorf(`unsupported hash function %s`, hash)
+			}
+			saltLength := pssopts.SaltLength
+			if saltLength == rsa.PSSSaltLengthEqualsHash || saltLength == rsa.PSSSaltLengthAuto {
+				saltLength = hash.Size()
+			}
+			return &Mechanism{
+				Type: MechanismRSAPKCSPSS,
+				Parameter: &PSSParams{
+					HashAlg:    hm.mech,
+					MGF:        hm.mgf,
+					SaltLength: uint(saltLength),
+				},
+			}, digest, nil
+		}
+
+		data, err := rsaprim.DigestInfo(hash, digest)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &Mechanism{Type: MechanismRSAPKCS}, data, nil
+	case *ecdsa.PublicKey:
+		return &Mechanism{Type: MechanismECDSA}, digest, nil
+	default:
+		return nil, nil, errors.Errorf(`unsupported key type %T`, k.pubkey)
+	}
+}
+
+// Sign signs the given digest using the token. See SignContext for details.
+func (k *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), rand, digest, opts)
+}
+
+// SignContext signs the given digest using the token. ECDSA signatures
+// are returned in ASN.1 DER format, as required by crypto.Signer.
+// The rand parameter is ignored.
+//
+// As PKCS#11 operations cannot be interrupted, ctx is only honored while
+// waiting for a session to become available. SignContext is called by
+// jws.SignContext() and jwt.SignContext().
+func (k *Key) SignContext(ctx context.Context, _ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	mech, data, err := k.signMechanism(digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature []byte
+	err = k.token.withSession(ctx, func(sh SessionHandle) error {
+		v, err := k.token.module.Sign(sh, mech, k.handle, data)
+		if err != nil {
+			return errors.Wrap(err, `failed to sign using PKCS#11 token`)
+		}
+		signature = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if mech.Type == MechanismECDSA {
+		// CKM_ECDSA returns R || S
+		if len(signature) == 0 || len(signature)%2 != 0 {
+			return nil, errors.New(`invalid ECDSA signature size`)
+		}
+		n := len(signature) / 2
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{
+			R: new(big.Int).SetBytes(signature[:n]),
+			S: new(big.Int).SetBytes(signature[n:]),
+		})
+	}
+	return signature, nil
+}
+
+// Decrypt decrypts msg using the token. See DecryptContext for details.
+func (k *Key) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	return k.DecryptContext(context.Background(), rand, msg, opts)
+}
+
+// DecryptContext decrypts msg using the token. opts must be
+// *rsa.OAEPOptions (without a label) or *rsa.PKCS1v15DecryptOptions.
+// The rand parameter is ignored.
+//
+// As PKCS#11 operations cannot be interrupted, ctx is only honored while
+// waiting for a session to become available. DecryptContext is called by
+// jwe.DecryptContext().
+func (k *Key) DecryptContext(ctx context.Context, _ io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	if _, ok := k.pubkey.(*rsa.PublicKey); !ok {
+		return nil, errors.Errorf(`key of type %T cannot be used to decrypt`, k.pubkey)
+	}
+
+	var mech *Mechanism
+	switch opts := opts.(type) {
+	case *rsa.OAEPOptions:
+		if len(opts.Label) > 0 {
+			return nil, errors.New(`OAEP labels are not supported`)
+		}
+		hm, ok := hashMechanisms[opts.Hash]
+		if !ok {
+			return nil, errors.Errorf(`unsupported OAEP hash function %s`, opts.Hash)
+		}
+		mech = &Mechanism{
+			Type:      MechanismRSAPKCSOAEP,
+			Parameter: &OAEPParams{HashAlg: hm.mech, MGF: hm.mgf},
+		}
+	case *rsa.PKCS1v15DecryptOptions, nil:
+		mech = &Mechanism{Type: MechanismRSAPKCS}
+	default:
+		return nil, errors.Errorf(`unsupported decrypter options %T`, opts)
+	}
+
+	var plaintext []byte
+	err := k.token.withSession(ctx, func(sh SessionHandle) error {
+		v, err := k.token.module.Decrypt(sh, mech, k.handle, msg)
+		if err != nil {
+			return errors.Wrap(err, `failed to decrypt using PKCS#11 token`)
+		}
+		plaintext = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
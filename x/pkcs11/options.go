@@ -0,0 +1,29 @@
+package pkcs11
+
+import (
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+type identMaxSessions struct{}
+type identPIN struct{}
+type identPINFunc struct{}
+
+// WithPIN specifies the user PIN used to log in to the token
+func WithPIN(pin string) Option {
+	return option.New(identPIN{}, pin)
+}
+
+// WithPINFunc specifies a function that is called to obtain the user
+// PIN when logging in to the token, for example to read it from a
+// secret store or to prompt the user
+func WithPINFunc(fn func() (string, error)) Option {
+	return option.New(identPINFunc{}, fn)
+}
+
+// WithMaxSessions specifies the maximum number of sessions that are
+// opened concurrently. The default is 4.
+func WithMaxSessions(n int) Option {
+	return option.New(identMaxSessions{}, n)
+}
@@ -0,0 +1,144 @@
+// Package pkcs11 exposes keys stored in PKCS#11 tokens (such as HSMs)
+// as opaque crypto.Signer and crypto.Decrypter values, which can be
+// used directly with jws.Sign, jwt.Sign, and jwe.Decrypt.
+//
+// This package does not link against any PKCS#11 library. Instead, it
+// works with any value that satisfies the `Module` interface, whose
+// methods mirror those of the `Ctx` type in github.com/miekg/pkcs11.
+// An adapter is mostly a matter of converting attribute and mechanism
+// values:
+//
+//	type module struct {
+//	  ctx *pkcs11.Ctx
+//	}
+//
+//	func (m module) Sign(sh jwxpkcs11.SessionHandle, mech *jwxpkcs11.Mechanism, key jwxpkcs11.ObjectHandle, data []byte) ([]byte, error) {
+//	  if err := m.ctx.SignInit(pkcs11.SessionHandle(sh), convertMechanism(mech), pkcs11.ObjectHandle(key)); err != nil {
+//	    return nil, err
+//	  }
+//	  return m.ctx.Sign(pkcs11.SessionHandle(sh), data)
+//	}
+//	...
+//
+// Sessions are pooled, and the user is logged in to the token once
+// when it is opened.
+//
+//	token, err := jwxpkcs11.Open(module{ctx: ctx}, slot, jwxpkcs11.WithPIN(pin))
+//	defer token.Close()
+//	key, err := token.FindKey("jwt-signing", nil)
+//	signed, err := jwt.Sign(t, jwa.PS256, key)
+package pkcs11
+
+import (
+	"crypto"
+)
+
+// SessionHandle is a PKCS#11 session handle (CK_SESSION_HANDLE)
+type SessionHandle uint
+
+// ObjectHandle is a PKCS#11 object handle (CK_OBJECT_HANDLE)
+type ObjectHandle uint
+
+// AttributeType is a PKCS#11 attribute type (CK_ATTRIBUTE_TYPE)
+type AttributeType uint
+
+// PKCS#11 attribute types used by this package
+const (
+	AttributeClass          AttributeType = 0x0000 // CKA_CLASS
+	AttributeLabel          AttributeType = 0x0003 // CKA_LABEL
+	AttributeKeyType        AttributeType = 0x0100 // CKA_KEY_TYPE
+	AttributeID             AttributeType = 0x0102 // CKA_ID
+	AttributeModulus        AttributeType = 0x0120 // CKA_MODULUS
+	AttributePublicExponent AttributeType = 0x0122 // CKA_PUBLIC_EXPONENT
+	AttributeECParams       AttributeType = 0x0180 // CKA_EC_PARAMS
+	AttributeECPoint        AttributeType = 0x0181 // CKA_EC_POINT
+)
+
+// PKCS#11 object classes (CK_OBJECT_CLASS)
+const (
+	ClassPublicKey  uint = 0x0002 // CKO_PUBLIC_KEY
+	ClassPrivateKey uint = 0x0003 // CKO_PRIVATE_KEY
+)
+
+// PKCS#11 key types (CK_KEY_TYPE)
+const (
+	KeyTypeRSA uint = 0x0000 // CKK_RSA
+	KeyTypeEC  uint = 0x0003 // CKK_EC
+)
+
+// MechanismType is a PKCS#11 mechanism type (CK_MECHANISM_TYPE)
+type MechanismType uint
+
+// PKCS#11 mechanisms used by this package
+const (
+	MechanismRSAPKCS     MechanismType = 0x0001 // CKM_RSA_PKCS
+	MechanismRSAPKCSOAEP MechanismType = 0x0009 // CKM_RSA_PKCS_OAEP
+	MechanismRSAPKCSPSS  MechanismType = 0x000d // CKM_RSA_PKCS_PSS
+	MechanismECDSA       MechanismType = 0x1041 // CKM_ECDSA
+	MechanismSHA1        MechanismType = 0x0220 // CKM_SHA_1
+	MechanismSHA256      MechanismType = 0x0250 // CKM_SHA256
+	MechanismSHA384      MechanismType = 0x0260 // CKM_SHA384
+	MechanismSHA512      MechanismType = 0x0270 // CKM_SHA512
+)
+
+// PKCS#11 mask generation functions (CK_RSA_PKCS_MGF_TYPE)
+const (
+	MGF1SHA1   uint = 0x0001 // CKG_MGF1_SHA1
+	MGF1SHA256 uint = 0x0002 // CKG_MGF1_SHA256
+	MGF1SHA384 uint = 0x0003 // CKG_MGF1_SHA384
+	MGF1SHA512 uint = 0x0004 // CKG_MGF1_SHA512
+)
+
+// Attribute is a PKCS#11 attribute. When used in search templates,
+// Value is a uint, a string, or a []byte. When returned from
+// Module.GetAttributeValue, Value is a []byte.
+type Attribute struct {
+	Type  AttributeType
+	Value interface{}
+}
+
+// PSSParams are the parameters for the CKM_RSA_PKCS_PSS mechanism
+type PSSParams struct {
+	HashAlg    MechanismType
+	MGF        uint
+	SaltLength uint
+}
+
+// OAEPParams are the parameters for the CKM_RSA_PKCS_OAEP mechanism.
+// The source of the encoding parameter is always CKZ_DATA_SPECIFIED
+// with an empty label.
+type OAEPParams struct {
+	HashAlg MechanismType
+	MGF     uint
+}
+
+// Mechanism is a PKCS#11 mechanism. Parameter is nil, *PSSParams,
+// or *OAEPParams.
+type Mechanism struct {
+	Type      MechanismType
+	Parameter interface{}
+}
+
+// Module is the subset of the PKCS#11 API required by this package.
+// Sign and Decrypt correspond to C_SignInit+C_Sign and
+// C_DecryptInit+C_Decrypt, respectively.
+type Module interface {
+	OpenSession(slot uint) (SessionHandle, error)
+	CloseSession(SessionHandle) error
+	Login(sh SessionHandle, pin string) error
+	Logout(SessionHandle) error
+	FindObjects(sh SessionHandle, template []*Attribute) ([]ObjectHandle, error)
+	GetAttributeValue(sh SessionHandle, obj ObjectHandle, template []*Attribute) ([]*Attribute, error)
+	Sign(sh SessionHandle, mech *Mechanism, key ObjectHandle, data []byte) ([]byte, error)
+	Decrypt(sh SessionHandle, mech *Mechanism, key ObjectHandle, data []byte) ([]byte, error)
+}
+
+var hashMechanisms = map[crypto.Hash]struct {
+	mech MechanismType
+	mgf  uint
+}{
+	crypto.SHA1:   {mech: MechanismSHA1, mgf: MGF1SHA1},
+	crypto.SHA256: {mech: MechanismSHA256, mgf: MGF1SHA256},
+	crypto.SHA384: {mech: MechanismSHA384, mgf: MGF1SHA384},
+	crypto.SHA512: {mech: MechanismSHA512, mgf: MGF1SHA512},
+}
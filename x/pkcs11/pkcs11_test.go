@@ -0,0 +1,532 @@
+package pkcs11_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"math/big"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/x/pkcs11"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPIN = `1234`
+
+type fakeObject struct {
+	class uint
+	label string
+	id    []byte
+	key   crypto.Signer
+}
+
+// fakeModule emulates a PKCS#11 token using local private keys
+type fakeModule struct {
+	mu       sync.Mutex
+	objects  map[pkcs11.ObjectHandle]*fakeObject
+	sessions map[pkcs11.SessionHandle]struct{}
+	next     pkcs11.SessionHandle
+	opened   int
+	inUse    int
+	maxInUse int
+	loggedIn bool
+	pin      string
+	delay    time.Duration
+	// onEnter, if set, is called when an operation starts using a session
+	onEnter func()
+}
+
+func newFakeModule() *fakeModule {
+	return &fakeModule{
+		objects:  make(map[pkcs11.ObjectHandle]*fakeObject),
+		sessions: make(map[pkcs11.SessionHandle]struct{}),
+		pin:      testPIN,
+	}
+}
+
+func (m *fakeModule) addKey(label string, id []byte, key crypto.Signer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := pkcs11.ObjectHandle(len(m.objects) + 1)
+	m.objects[n] = &fakeObject{class: pkcs11.ClassPrivateKey, label: label, id: id, key: key}
+	m.objects[n+1] = &fakeObject{class: pkcs11.ClassPublicKey, label: label, id: id, key: key}
+}
+
+func (m *fakeModule) OpenSession(uint) (pkcs11.SessionHandle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next++
+	m.opened++
+	m.sessions[m.next] = struct{}{}
+	return m.next, nil
+}
+
+func (m *fakeModule) CloseSession(sh pkcs11.SessionHandle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[sh]; !ok {
+		return errors.New(`CKR_SESSION_HANDLE_INVALID`)
+	}
+	delete(m.sessions, sh)
+	return nil
+}
+
+func (m *fakeModule) Login(_ pkcs11.SessionHandle, pin string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if pin != m.pin {
+		return errors.New(`CKR_PIN_INCORRECT`)
+	}
+	m.loggedIn = true
+	return nil
+}
+
+func (m *fakeModule) Logout(pkcs11.SessionHandle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.loggedIn {
+		return errors.New(`CKR_USER_NOT_LOGGED_IN`)
+	}
+	m.loggedIn = false
+	return nil
+}
+
+func (m *fakeModule) FindObjects(_ pkcs11.SessionHandle, template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var found []pkcs11.ObjectHandle
+	for h, obj := range m.objects {
+		if obj.class == pkcs11.ClassPrivateKey && !m.loggedIn {
+			continue
+		}
+		match := true
+		for _, attr := range template {
+			switch attr.Type {
+			case pkcs11.AttributeClass:
+				match = match && attr.Value.(uint) == obj.class
+			case pkcs11.AttributeLabel:
+				match = match && attr.Value.(string) == obj.label
+			case pkcs11.AttributeID:
+				match = match && bytes.Equal(attr.Value.([]byte), obj.id)
+			}
+		}
+		if match {
+			found = append(found, h)
+		}
+	}
+	return found, nil
+}
+
+func (m *fakeModule) GetAttributeValue(_ pkcs11.SessionHandle, h pkcs11.ObjectHandle, template []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
+	m.mu.Lock()
+	obj, ok := m.objects[h]
+	m.mu.Unlock()
+	if !ok {
+		return nil, errors.New(`CKR_OBJECT_HANDLE_INVALID`)
+	}
+
+	var attrs []*pkcs11.Attribute
+	for _, attr := range template {
+		var v []byte
+		switch pubkey := obj.key.Public().(type) {
+		case *rsa.PublicKey:
+			switch attr.Type {
+			case pkcs11.AttributeKeyType:
+				// little endian CK_ULONG
+				v = make([]byte, 8)
+			case pkcs11.AttributeModulus:
+				v = pubkey.N.Bytes()
+			case pkcs11.AttributePublicExponent:
+				v = big.NewInt(int64(pubkey.E)).Bytes()
+			}
+		case *ecdsa.PublicKey:
+			switch attr.Type {
+			case pkcs11.AttributeKeyType:
+				v = []byte{0x03, 0, 0, 0, 0, 0, 0, 0}
+			case pkcs11.AttributeECParams:
+				v, _ = asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7})
+			case pkcs11.AttributeECPoint:
+				v, _ = asn1.Marshal(elliptic.Marshal(pubkey.Curve, pubkey.X, pubkey.Y)) //nolint:staticcheck
+			}
+		}
+		attrs = append(attrs, &pkcs11.Attribute{Type: attr.Type, Value: v})
+	}
+	return attrs, nil
+}
+
+func (m *fakeModule) enter() {
+	m.mu.Lock()
+	m.inUse++
+	if m.inUse > m.maxInUse {
+		m.maxInUse = m.inUse
+	}
+	onEnter := m.onEnter
+	m.mu.Unlock()
+	if onEnter != nil {
+		onEnter()
+	}
+	time.Sleep(m.delay)
+}
+
+func (m *fakeModule) leave() {
+	m.mu.Lock()
+	m.inUse--
+	m.mu.Unlock()
+}
+
+var mechanismHashes = map[pkcs11.MechanismType]crypto.Hash{
+	pkcs11.MechanismSHA1:   crypto.SHA1,
+	pkcs11.MechanismSHA256: crypto.SHA256,
+	pkcs11.MechanismSHA384: crypto.SHA384,
+	pkcs11.MechanismSHA512: crypto.SHA512,
+}
+
+func (m *fakeModule) Sign(_ pkcs11.SessionHandle, mech *pkcs11.Mechanism, h pkcs11.ObjectHandle, data []byte) ([]byte, error) {
+	m.enter()
+	defer m.leave()
+
+	obj, ok := m.objects[h]
+	if !ok || obj.class != pkcs11.ClassPrivateKey {
+		return nil, errors.New(`CKR_KEY_HANDLE_INVALID`)
+	}
+
+	switch mech.Type {
+	case pkcs11.MechanismRSAPKCS:
+		// data is a DigestInfo structure
+		return rsa.SignPKCS1v15(rand.Reader, obj.key.(*rsa.PrivateKey), 0, data)
+	case pkcs11.MechanismRSAPKCSPSS:
+		params := mech.Parameter.(*pkcs11.PSSParams)
+		return rsa.SignPSS(rand.Reader, obj.key.(*rsa.PrivateKey), mechanismHashes[params.HashAlg], data, &rsa.PSSOptions{SaltLength: int(params.SaltLength)})
+	case pkcs11.MechanismECDSA:
+		r, s, err := ecdsa.Sign(rand.Reader, obj.key.(*ecdsa.PrivateKey), data)
+		if err != nil {
+			return nil, err
+		}
+		size := (obj.key.(*ecdsa.PrivateKey).Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	default:
+		return nil, errors.New(`CKR_MECHANISM_INVALID`)
+	}
+}
+
+func (m *fakeModule) Decrypt(_ pkcs11.SessionHandle, mech *pkcs11.Mechanism, h pkcs11.ObjectHandle, data []byte) ([]byte, error) {
+	m.enter()
+	defer m.leave()
+
+	obj, ok := m.objects[h]
+	if !ok || obj.class != pkcs11.ClassPrivateKey {
+		return nil, errors.New(`CKR_KEY_HANDLE_INVALID`)
+	}
+	key, ok := obj.key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New(`CKR_KEY_TYPE_INCONSISTENT`)
+	}
+
+	switch mech.Type {
+	case pkcs11.MechanismRSAPKCS:
+		return rsa.DecryptPKCS1v15(rand.Reader, key, data)
+	case pkcs11.MechanismRSAPKCSOAEP:
+		params := mech.Parameter.(*pkcs11.OAEPParams)
+		return rsa.DecryptOAEP(mechanismHashes[params.HashAlg].New(), rand.Reader, key, data, nil)
+	default:
+		return nil, errors.New(`CKR_MECHANISM_INVALID`)
+	}
+}
+
+func setupModule(t *testing.T) (*fakeModule, *rsa.PrivateKey, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		t.FailNow()
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		t.FailNow()
+	}
+
+	m := newFakeModule()
+	m.addKey(`rsa`, []byte{0x01}, rsakey)
+	m.addKey(`ec`, []byte{0x02}, eckey)
+	return m, rsakey, eckey
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	m, rsakey, _ := setupModule(t)
+	token, err := pkcs11.Open(m, 0, pkcs11.WithPIN(testPIN))
+	if !assert.NoError(t, err, `pkcs11.Open should succeed`) {
+		return
+	}
+	defer token.Close()
+
+	payload := []byte("Lorem ipsum")
+	t.Run("Sign", func(t *testing.T) {
+		testcases := []struct {
+			Label string
+			Algs  []jwa.SignatureAlgorithm
+		}{
+			{Label: `rsa`, Algs: []jwa.SignatureAlgorithm{jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512}},
+			{Label: `ec`, Algs: []jwa.SignatureAlgorithm{jwa.ES256}},
+		}
+		for _, tc := range testcases {
+			key, err := token.FindKey(tc.Label, nil)
+			if !assert.NoError(t, err, `token.FindKey should succeed`) {
+				return
+			}
+			for _, alg := range tc.Algs {
+				alg := alg
+				t.Run(alg.String(), func(t *testing.T) {
+					signed, err := jws.Sign(payload, alg, key)
+					if !assert.NoError(t, err, `jws.Sign should succeed`) {
+						return
+					}
+
+					pubkey, err := key.PublicJWK(alg)
+					if !assert.NoError(t, err, `key.PublicJWK should succeed`) {
+						return
+					}
+					verified, err := jws.Verify(signed, alg, pubkey)
+					if !assert.NoError(t, err, `jws.Verify should succeed`) {
+						return
+					}
+					if !assert.Equal(t, payload, verified, `payload should match`) {
+						return
+					}
+				})
+			}
+		}
+	})
+	t.Run("FindKey by ID", func(t *testing.T) {
+		key, err := token.FindKey("", []byte{0x01})
+		if !assert.NoError(t, err, `token.FindKey should succeed`) {
+			return
+		}
+		if !assert.Equal(t, &rsakey.PublicKey, key.Public(), `public keys should match`) {
+			return
+		}
+	})
+	t.Run("Decrypt", func(t *testing.T) {
+		key, err := token.FindKey(`rsa`, nil)
+		if !assert.NoError(t, err, `token.FindKey should succeed`) {
+			return
+		}
+		for _, alg := range []jwa.KeyEncryptionAlgorithm{jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256} {
+			alg := alg
+			t.Run(alg.String(), func(t *testing.T) {
+				if alg == jwa.RSA1_5 && jwx.FIPSMode() {
+					t.Skip(`RSA1_5 is not approved in FIPS mode`)
+				}
+				encrypted, err := jwe.Encrypt(payload, alg, &rsakey.PublicKey, jwa.A128GCM, jwa.NoCompress)
+				if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+					return
+				}
+				decrypted, err := jwe.Decrypt(encrypted, alg, key)
+				if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+					return
+				}
+				if !assert.Equal(t, payload, decrypted, `payload should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Decrypt with EC key", func(t *testing.T) {
+		key, err := token.FindKey(`ec`, nil)
+		if !assert.NoError(t, err, `token.FindKey should succeed`) {
+			return
+		}
+		_, err = key.Decrypt(rand.Reader, []byte("dummy"), nil)
+		if !assert.Error(t, err, `key.Decrypt should fail`) {
+			return
+		}
+	})
+	t.Run("FindKey errors", func(t *testing.T) {
+		_, err := token.FindKey("", nil)
+		if !assert.Error(t, err, `token.FindKey without label or id should fail`) {
+			return
+		}
+		_, err = token.FindKey(`nonexistent`, nil)
+		if !assert.Error(t, err, `token.FindKey with unknown label should fail`) {
+			return
+		}
+	})
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	t.Run("WithPINFunc", func(t *testing.T) {
+		t.Parallel()
+		m, _, _ := setupModule(t)
+		token, err := pkcs11.Open(m, 0, pkcs11.WithPINFunc(func() (string, error) {
+			return testPIN, nil
+		}))
+		if !assert.NoError(t, err, `pkcs11.Open should succeed`) {
+			return
+		}
+		defer token.Close()
+
+		_, err = token.FindKey(`rsa`, nil)
+		if !assert.NoError(t, err, `token.FindKey should succeed`) {
+			return
+		}
+	})
+	t.Run("WithPINFunc error", func(t *testing.T) {
+		t.Parallel()
+		m, _, _ := setupModule(t)
+		_, err := pkcs11.Open(m, 0, pkcs11.WithPINFunc(func() (string, error) {
+			return "", errors.New(`cancelled`)
+		}))
+		if !assert.Error(t, err, `pkcs11.Open should fail`) {
+			return
+		}
+	})
+	t.Run("Wrong PIN", func(t *testing.T) {
+		t.Parallel()
+		m, _, _ := setupModule(t)
+		_, err := pkcs11.Open(m, 0, pkcs11.WithPIN(`0000`))
+		if !assert.Error(t, err, `pkcs11.Open should fail`) {
+			return
+		}
+		if !assert.Empty(t, m.sessions, `session should be closed`) {
+			return
+		}
+	})
+	t.Run("No PIN", func(t *testing.T) {
+		t.Parallel()
+		m, _, _ := setupModule(t)
+		token, err := pkcs11.Open(m, 0)
+		if !assert.NoError(t, err, `pkcs11.Open should succeed`) {
+			return
+		}
+		defer token.Close()
+
+		// private keys are not visible without logging in
+		_, err = token.FindKey(`rsa`, nil)
+		if !assert.Error(t, err, `token.FindKey should fail`) {
+			return
+		}
+	})
+}
+
+func TestSessionPool(t *testing.T) {
+	t.Parallel()
+
+	for _, max := range []int{1, 3} {
+		max := max
+		t.Run(strconv.Itoa(max), func(t *testing.T) {
+			t.Parallel()
+
+			m, _, _ := setupModule(t)
+			m.delay = 10 * time.Millisecond
+			token, err := pkcs11.Open(m, 0, pkcs11.WithPIN(testPIN), pkcs11.WithMaxSessions(max))
+			if !assert.NoError(t, err, `pkcs11.Open should succeed`) {
+				return
+			}
+
+			key, err := token.FindKey(`ec`, nil)
+			if !assert.NoError(t, err, `token.FindKey should succeed`) {
+				return
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := jws.Sign([]byte("Lorem ipsum"), jwa.ES256, key)
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if !assert.NoError(t, err, `jws.Sign should succeed`) {
+					return
+				}
+			}
+
+			if !assert.LessOrEqual(t, m.maxInUse, max, `concurrent operations should not exceed the pool size`) {
+				return
+			}
+			if !assert.LessOrEqual(t, m.opened, max, `opened sessions should not exceed the pool size`) {
+				return
+			}
+
+			if !assert.NoError(t, token.Close(), `token.Close should succeed`) {
+				return
+			}
+			if !assert.Empty(t, m.sessions, `all sessions should be closed`) {
+				return
+			}
+			if !assert.False(t, m.loggedIn, `token should be logged out`) {
+				return
+			}
+
+			_, err = jws.Sign([]byte("Lorem ipsum"), jwa.ES256, key)
+			if !assert.Error(t, err, `jws.Sign after token.Close should fail`) {
+				return
+			}
+		})
+	}
+	t.Run("SignContext", func(t *testing.T) {
+		t.Parallel()
+
+		m, _, _ := setupModule(t)
+		m.delay = 200 * time.Millisecond
+		token, err := pkcs11.Open(m, 0, pkcs11.WithPIN(testPIN), pkcs11.WithMaxSessions(1))
+		if !assert.NoError(t, err, `pkcs11.Open should succeed`) {
+			return
+		}
+		defer token.Close()
+
+		key, err := token.FindKey(`ec`, nil)
+		if !assert.NoError(t, err, `token.FindKey should succeed`) {
+			return
+		}
+
+		// occupy the only session, so that the next operation has to wait
+		var once sync.Once
+		busy := make(chan struct{})
+		m.mu.Lock()
+		m.onEnter = func() { once.Do(func() { close(busy) }) }
+		m.mu.Unlock()
+		done := make(chan error, 1)
+		go func() {
+			_, err := jws.Sign([]byte("Lorem ipsum"), jwa.ES256, key)
+			done <- err
+		}()
+		<-busy
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = jws.SignContext(ctx, []byte("Lorem ipsum"), jwa.ES256, key)
+		if !assert.True(t, errors.Is(err, context.DeadlineExceeded), `jws.SignContext should fail with context.DeadlineExceeded (got %v)`, err) {
+			return
+		}
+		if !assert.NoError(t, <-done, `jws.Sign should succeed`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package pkcs11
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxSessions is the default maximum number of sessions
+// opened concurrently for a token
+const DefaultMaxSessions = 4
+
+// Token represents a logged in PKCS#11 token, and manages a pool
+// of sessions
+type Token struct {
+	module Module
+	slot   uint
+
+	mu       sync.Mutex
+	closed   bool
+	idle     chan SessionHandle
+	max      int
+	open     int
+	released *sync.Cond
+}
+
+// Open opens a session on the token in the given slot, and logs in as
+// the user if a PIN was specified via WithPIN or WithPINFunc.
+//
+// Subsequent operations use a pool of sessions, whose maximum size is
+// controlled by WithMaxSessions. Call Close to close all sessions.
+func Open(module Module, slot uint, options ...Option) (*Token, error) {
+	if module == nil {
+		return nil, errors.New(`pkcs11.Open: module must not be nil`)
+	}
+
+	max := DefaultMaxSessions
+	var pin string
+	var pinFunc func() (string, error)
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identMaxSessions{}:
+			max = option.Value().(int)
+		case identPIN{}:
+			pin = option.Value().(string)
+		case identPINFunc{}:
+			pinFunc = option.Value().(func() (string, error))
+		}
+	}
+	if max < 1 {
+		return nil, errors.Errorf(`invalid maximum number of sessions %d`, max)
+	}
+
+	if pinFunc != nil {
+		v, err := pinFunc()
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to obtain PIN`)
+		}
+		pin = v
+	}
+
+	sh, err := module.OpenSession(slot)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to open session on slot %d`, slot)
+	}
+
+	// Logging in applies to all sessions of the application, so
+	// we only need to do this once
+	if pin != "" {
+		if err := module.Login(sh, pin); err != nil {
+			_ = module.CloseSession(sh)
+			return nil, errors.Wrap(err, `failed to log in to token`)
+		}
+	}
+
+	t := &Token{
+		module: module,
+		slot:   slot,
+		idle:   make(chan SessionHandle, max),
+		max:    max,
+		open:   1,
+	}
+	t.released = sync.NewCond(&t.mu)
+	t.idle <- sh
+	return t, nil
+}
+
+// acquire returns an idle session, opening a new one if the pool has
+// not reached its maximum size. Otherwise it blocks until a session
+// is released
+func (t *Token) acquire(ctx context.Context) (SessionHandle, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// stop is closed when we return, which ends the goroutine that wakes
+	// us up when ctx is done while waiting for a session
+	var stop chan struct{}
+	defer func() {
+		if stop != nil {
+			close(stop)
+		}
+	}()
+
+	for {
+		if t.closed {
+			return 0, errors.New(`token has been closed`)
+		}
+
+		select {
+		case sh := <-t.idle:
+			return sh, nil
+		default:
+		}
+
+		if t.open < t.max {
+			sh, err := t.module.OpenSession(t.slot)
+			if err != nil {
+				return 0, errors.Wrapf(err, `failed to open session on slot %d`, t.slot)
+			}
+			t.open++
+			return sh, nil
+		}
+
+		// only give up when there is no session for us, so that a
+		// wakeup meant for another waiter is never lost
+		if err := ctx.Err(); err != nil {
+			return 0, errors.Wrap(err, `failed to acquire session`)
+		}
+		if stop == nil && ctx.Done() != nil {
+			stop = make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					t.mu.Lock()
+					t.released.Broadcast()
+					t.mu.Unlock()
+				case <-stop:
+				}
+			}()
+		}
+		t.released.Wait()
+	}
+}
+
+func (t *Token) release(sh SessionHandle) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		_ = t.module.CloseSession(sh)
+		t.open--
+		return
+	}
+	t.idle <- sh
+	t.released.Signal()
+}
+
+func (t *Token) withSession(ctx context.Context, fn func(SessionHandle) error) error {
+	sh, err := t.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer t.release(sh)
+
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, `failed to acquire session`)
+	}
+	return fn(sh)
+}
+
+// Close logs out of the token, and closes all idle sessions. Sessions
+// that are in use are closed once the operation using them completes.
+func (t *Token) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	t.released.Broadcast()
+
+	var firstErr error
+	var loggedOut bool
+	for len(t.idle) > 0 {
+		sh := <-t.idle
+		if !loggedOut {
+			// errors are ignored, as the user may not have been logged in
+			_ = t.module.Logout(sh)
+			loggedOut = true
+		}
+		if err := t.module.CloseSession(sh); err != nil && firstErr == nil {
+			firstErr = errors.Wrap(err, `failed to close session`)
+		}
+		t.open--
+	}
+	return firstErr
+}
+
+// FindKey finds the private key with the given label and/or ID (CKA_ID),
+// along with its public key. Either label or id may be empty, but not
+// both. It is an error if more than one private key matches.
+func (t *Token) FindKey(label string, id []byte) (*Key, error) {
+	if label == "" && len(id) == 0 {
+		return nil, errors.New(`either label or id must be specified`)
+	}
+
+	template := func(class uint) []*Attribute {
+		attrs := []*Attribute{{Type: AttributeClass, Value: class}}
+		if label != "" {
+			attrs = append(attrs, &Attribute{Type: AttributeLabel, Value: label})
+		}
+		if len(id) > 0 {
+			attrs = append(attrs, &Attribute{Type: AttributeID, Value: id})
+		}
+		return attrs
+	}
+
+	var key *Key
+	err := t.withSession(context.Background(), func(sh SessionHandle) error {
+		privs, err := t.module.FindObjects(sh, template(ClassPrivateKey))
+		if err != nil {
+			return errors.Wrap(err, `failed to find private key`)
+		}
+		switch len(privs) {
+		case 0:
+			return errors.Errorf(`private key (label=%q, id=%x) not found`, label, id)
+		case 1:
+		default:
+			return errors.Errorf(`multiple private keys (label=%q, id=%x) found`, label, id)
+		}
+
+		pubs, err := t.module.FindObjects(sh, template(ClassPublicKey))
+		if err != nil {
+			return errors.Wrap(err, `failed to find public key`)
+		}
+		if len(pubs) != 1 {
+			return errors.Errorf(`expected exactly one public key (label=%q, id=%x), found %d`, label, id, len(pubs))
+		}
+
+		pubkey, err := readPublicKey(t.module, sh, pubs[0])
+		if err != nil {
+			return err
+		}
+
+		key = &Key{
+			handle: privs[0],
+			pubkey: pubkey,
+			token:  t,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,26 @@
+package tpm
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+type identAlgorithm struct{}
+type identHierarchy struct{}
+
+// WithAlgorithm specifies the JWS algorithm to use when signing with a
+// persistent key passed to New. If not specified, the algorithm is
+// inferred from the public key: RS256 for RSA keys, and ES256/ES384/ES512
+// for ECDSA keys depending on the curve.
+func WithAlgorithm(alg jwa.SignatureAlgorithm) Option {
+	return option.New(identAlgorithm{}, alg)
+}
+
+// WithHierarchy specifies the hierarchy under which DeriveKey creates
+// the primary key. The default is HandleEndorsement, which ties the
+// key to the endorsement key certified by the TPM manufacturer.
+func WithHierarchy(h Handle) Option {
+	return option.New(identHierarchy{}, h)
+}
@@ -0,0 +1,339 @@
+package tpm
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/x/internal/signerutil"
+	"github.com/pkg/errors"
+)
+
+var hashes = map[jwa.SignatureAlgorithm]crypto.Hash{
+	jwa.RS256: crypto.SHA256,
+	jwa.RS384: crypto.SHA384,
+	jwa.RS512: crypto.SHA512,
+	jwa.PS256: crypto.SHA256,
+	jwa.PS384: crypto.SHA384,
+	jwa.PS512: crypto.SHA512,
+	jwa.ES256: crypto.SHA256,
+	jwa.ES384: crypto.SHA384,
+	jwa.ES512: crypto.SHA512,
+}
+
+var hashAlgorithms = map[crypto.Hash]Algorithm{
+	crypto.SHA256: AlgSHA256,
+	crypto.SHA384: AlgSHA384,
+	crypto.SHA512: AlgSHA512,
+}
+
+// deviceKeyAttributes are the attributes of keys created by DeriveKey.
+// The key can only be used for signing arbitrary digests, and can
+// be certified as having been generated inside (and never leaving)
+// the TPM.
+const deviceKeyAttributes = FlagFixedTPM | FlagFixedParent | FlagSensitiveDataOrigin | FlagUserWithAuth | FlagNoDA | FlagSign
+
+// Signer is a crypto.Signer that delegates signing operations to a
+// TPM 2.0 device. The private key never leaves the TPM.
+type Signer struct {
+	alg       jwa.SignatureAlgorithm
+	handle    Handle
+	kid       string
+	pubkey    crypto.PublicKey
+	tpm       TPM
+	transient bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// New creates a new Signer for the persistent key with the given
+// handle (e.g. 0x81000001). The public key is read from the TPM
+// upon creation.
+func New(tpm TPM, handle Handle, options ...Option) (*Signer, error) {
+	if tpm == nil {
+		return nil, errors.New(`tpm.New: tpm must not be nil`)
+	}
+
+	var alg jwa.SignatureAlgorithm
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identAlgorithm{}:
+			alg = option.Value().(jwa.SignatureAlgorithm)
+		}
+	}
+
+	pubkey, err := tpm.ReadPublic(handle)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to read public key of handle 0x%x`, uint32(handle))
+	}
+
+	if alg == "" {
+		alg, err = defaultAlgorithm(pubkey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newSigner(tpm, handle, pubkey, alg, false)
+}
+
+// DeriveKey creates a primary signing key for the given algorithm
+// under the endorsement hierarchy (or the hierarchy specified by
+// WithHierarchy). The label selects the key: the same TPM, hierarchy,
+// algorithm and label always derive the same key, which makes it
+// suitable as a stable device identity without provisioning or
+// storing any key material.
+//
+// The key is loaded as a transient object, and must be released
+// by calling Close.
+func DeriveKey(tpm TPM, alg jwa.SignatureAlgorithm, label string, options ...Option) (*Signer, error) {
+	if tpm == nil {
+		return nil, errors.New(`tpm.DeriveKey: tpm must not be nil`)
+	}
+
+	hierarchy := HandleEndorsement
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identHierarchy{}:
+			hierarchy = option.Value().(Handle)
+		}
+	}
+
+	template, err := DeviceKeyTemplate(alg, label)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, pubkey, err := tpm.CreatePrimary(hierarchy, template)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create primary key`)
+	}
+
+	s, err := newSigner(tpm, handle, pubkey, alg, true)
+	if err != nil {
+		_ = tpm.FlushContext(handle)
+		return nil, err
+	}
+	return s, nil
+}
+
+// DeviceKeyTemplate returns the template used by DeriveKey to create
+// a key for the given algorithm and label. It is exported so that
+// verifiers can reproduce the expected public area when validating
+// a TPM2_Certify attestation of the key.
+func DeviceKeyTemplate(alg jwa.SignatureAlgorithm, label string) (*Template, error) {
+	unique := sha256.Sum256([]byte(label))
+	template := &Template{
+		NameAlg:    AlgSHA256,
+		Attributes: deviceKeyAttributes,
+		Unique:     unique[:],
+	}
+
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		template.Type = AlgRSA
+		template.KeyBits = 2048
+	case jwa.ES256:
+		template.Type = AlgECC
+		template.Curve = CurveNISTP256
+	case jwa.ES384:
+		template.Type = AlgECC
+		template.Curve = CurveNISTP384
+	case jwa.ES512:
+		template.Type = AlgECC
+		template.Curve = CurveNISTP521
+	default:
+		return nil, errors.Errorf(`unsupported signature algorithm %s`, alg)
+	}
+	return template, nil
+}
+
+func newSigner(tpm TPM, handle Handle, pubkey crypto.PublicKey, alg jwa.SignatureAlgorithm, transient bool) (*Signer, error) {
+	if err := checkAlgorithm(alg, pubkey); err != nil {
+		return nil, err
+	}
+
+	kid, err := signerutil.ThumbprintKeyID(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{
+		alg:       alg,
+		handle:    handle,
+		kid:       kid,
+		pubkey:    pubkey,
+		tpm:       tpm,
+		transient: transient,
+	}, nil
+}
+
+func defaultAlgorithm(pubkey crypto.PublicKey) (jwa.SignatureAlgorithm, error) {
+	switch pubkey := pubkey.(type) {
+	case *rsa.PublicKey:
+		return jwa.RS256, nil
+	case *ecdsa.PublicKey:
+		switch pubkey.Curve {
+		case elliptic.P256():
+			return jwa.ES256, nil
+		case elliptic.P384():
+			return jwa.ES384, nil
+		case elliptic.P521():
+			return jwa.ES512, nil
+		}
+		return "", errors.Errorf(`unsupported curve %s`, pubkey.Curve.Params().Name)
+	default:
+		return "", errors.Errorf(`unsupported public key type %T`, pubkey)
+	}
+}
+
+func checkAlgorithm(alg jwa.SignatureAlgorithm, pubkey crypto.PublicKey) error {
+	if _, ok := hashes[alg]; !ok {
+		return errors.Errorf(`unsupported signature algorithm %s`, alg)
+	}
+
+	switch pubkey.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		expected, err := defaultAlgorithm(pubkey)
+		if err != nil {
+			return err
+		}
+		if alg == expected {
+			return nil
+		}
+	}
+	return errors.Errorf(`signature algorithm %s cannot be used with key of type %T`, alg, pubkey)
+}
+
+// Algorithm returns the JWS algorithm used by this signer
+func (s *Signer) Algorithm() jwa.SignatureAlgorithm {
+	return s.alg
+}
+
+// Handle returns the TPM handle of the key
+func (s *Signer) Handle() Handle {
+	return s.handle
+}
+
+// KeyID returns the RFC7638 thumbprint of the public key, to be used
+// in the "kid" field. For keys created by DeriveKey, this is a stable
+// identifier of the device.
+func (s *Signer) KeyID() string {
+	return s.kid
+}
+
+// Public returns the public key associated with the TPM key
+func (s *Signer) Public() crypto.PublicKey {
+	return s.pubkey
+}
+
+// PublicJWK returns the public key as a jwk.Key, with the "kid", "alg"
+// and "use" fields populated.
+func (s *Signer) PublicJWK() (jwk.Key, error) {
+	return signerutil.PublicJWK(s.pubkey, s.kid, s.alg)
+}
+
+// Sign signs the given digest using the TPM. See SignContext for details.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(context.Background(), rand, digest, opts)
+}
+
+// SignContext signs the given digest using the TPM. The hash function
+// and padding scheme specified in opts must match the algorithm of
+// this signer. ECDSA signatures are returned in ASN.1 DER format, as
+// required by crypto.Signer. The rand parameter is ignored.
+//
+// As TPM commands cannot be interrupted, ctx is only checked before the
+// command is sent. SignContext is called by jws.SignContext() and
+// jwt.SignContext().
+//
+// Note that the salt length of RSA-PSS signatures is chosen by the
+// TPM. TPMs conforming to revision 1.38 or later of the specification
+// use a salt as long as the digest, as required by RFC7518.
+func (s *Signer) SignContext(ctx context.Context, _ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts == nil || opts.HashFunc() != hashes[s.alg] {
+		return nil, errors.Errorf(`hash function does not match algorithm %s`, s.alg)
+	}
+
+	scheme := &SigScheme{Hash: hashAlgorithms[opts.HashFunc()]}
+	_, isPSS := opts.(*rsa.PSSOptions)
+	switch s.alg {
+	case jwa.PS256, jwa.PS384, jwa.PS512:
+		if !isPSS {
+			return nil, errors.Errorf(`algorithm %s requires *rsa.PSSOptions`, s.alg)
+		}
+		scheme.Alg = AlgRSAPSS
+	case jwa.RS256, jwa.RS384, jwa.RS512:
+		scheme.Alg = AlgRSASSA
+	default:
+		scheme.Alg = AlgECDSA
+	}
+	if isPSS && scheme.Alg != AlgRSAPSS {
+		return nil, errors.Errorf(`algorithm %s cannot be used with *rsa.PSSOptions`, s.alg)
+	}
+
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return nil, errors.New(`signer has been closed`)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, `failed to sign using TPM`)
+	}
+
+	sig, err := s.tpm.Sign(s.handle, digest, scheme)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to sign using TPM key 0x%x`, uint32(s.handle))
+	}
+
+	if scheme.Alg == AlgECDSA {
+		if sig.R == nil || sig.S == nil {
+			return nil, errors.New(`TPM returned an invalid ECDSA signature`)
+		}
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{R: sig.R, S: sig.S})
+	}
+	if len(sig.RSA) == 0 {
+		return nil, errors.New(`TPM returned an invalid RSA signature`)
+	}
+	return sig.RSA, nil
+}
+
+// Close flushes the key from the TPM if it was created by DeriveKey.
+// Persistent keys passed to New are left untouched.
+func (s *Signer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if !s.transient {
+		return nil
+	}
+	if err := s.tpm.FlushContext(s.handle); err != nil {
+		return errors.Wrapf(err, `failed to flush TPM key 0x%x`, uint32(s.handle))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package tpm provides a crypto.Signer backed by keys resident in a
+// TPM 2.0 device, which can be used directly with jws.Sign and jwt.Sign.
+// This allows edge and IoT devices to issue device-bound JWTs without
+// ever exporting the private key.
+//
+// This package does not depend on go-tpm. Instead, it works with any
+// value that satisfies the `TPM` interface, which is a thin subset of
+// the TPM 2.0 command set. An adapter for go-tpm's legacy tpm2 package
+// looks like this (error handling and the RSA/ECC variants abbreviated):
+//
+//	type device struct {
+//	  rw io.ReadWriter
+//	}
+//
+//	func (d device) Sign(key tpm.Handle, digest []byte, scheme *tpm.SigScheme) (*tpm.Signature, error) {
+//	  sig, err := tpm2.Sign(d.rw, tpmutil.Handle(key), "", digest, nil, &tpm2.SigScheme{
+//	    Alg:  tpm2.Algorithm(scheme.Alg),
+//	    Hash: tpm2.Algorithm(scheme.Hash),
+//	  })
+//	  if err != nil {
+//	    return nil, err
+//	  }
+//	  if sig.RSA != nil {
+//	    return &tpm.Signature{RSA: sig.RSA.Signature}, nil
+//	  }
+//	  return &tpm.Signature{R: sig.ECC.R, S: sig.ECC.S}, nil
+//	}
+//
+// Keys can either be persistent keys provisioned beforehand (see New),
+// or primary keys derived on demand from the TPM's seed (see DeriveKey).
+// Derived keys are reproducible: the same TPM, hierarchy, algorithm
+// and label always yield the same key, and the key is never stored
+// outside of the TPM. Their template marks them as fixedTPM and
+// sensitiveDataOrigin, so that they can be certified against the
+// endorsement key using TPM2_Certify.
+//
+//	signer, err := tpm.DeriveKey(device{rw: rw}, jwa.ES256, "device-identity")
+//	defer signer.Close()
+//	hdrs := jws.NewHeaders()
+//	hdrs.Set(jws.KeyIDKey, signer.KeyID())
+//	signed, err := jwt.Sign(token, signer.Algorithm(), signer, jwt.WithHeaders(hdrs))
+package tpm
+
+import (
+	"crypto"
+	"math/big"
+)
+
+// Handle is a TPM handle (TPM_HANDLE)
+type Handle uint32
+
+// Hierarchy handles
+const (
+	HandleOwner       Handle = 0x40000001 // TPM_RH_OWNER
+	HandleEndorsement Handle = 0x4000000B // TPM_RH_ENDORSEMENT
+)
+
+// Algorithm is a TPM algorithm ID (TPM_ALG_ID)
+type Algorithm uint16
+
+const (
+	AlgRSA    Algorithm = 0x0001 // TPM_ALG_RSA
+	AlgSHA256 Algorithm = 0x000B // TPM_ALG_SHA256
+	AlgSHA384 Algorithm = 0x000C // TPM_ALG_SHA384
+	AlgSHA512 Algorithm = 0x000D // TPM_ALG_SHA512
+	AlgRSASSA Algorithm = 0x0014 // TPM_ALG_RSASSA
+	AlgRSAPSS Algorithm = 0x0016 // TPM_ALG_RSAPSS
+	AlgECDSA  Algorithm = 0x0018 // TPM_ALG_ECDSA
+	AlgECC    Algorithm = 0x0023 // TPM_ALG_ECC
+)
+
+// Curve is a TPM elliptic curve ID (TPM_ECC_CURVE)
+type Curve uint16
+
+const (
+	CurveNISTP256 Curve = 0x0003 // TPM_ECC_NIST_P256
+	CurveNISTP384 Curve = 0x0004 // TPM_ECC_NIST_P384
+	CurveNISTP521 Curve = 0x0005 // TPM_ECC_NIST_P521
+)
+
+// KeyAttributes are the object attributes of a key (TPMA_OBJECT)
+type KeyAttributes uint32
+
+const (
+	FlagFixedTPM            KeyAttributes = 0x00000002
+	FlagFixedParent         KeyAttributes = 0x00000010
+	FlagSensitiveDataOrigin KeyAttributes = 0x00000020
+	FlagUserWithAuth        KeyAttributes = 0x00000040
+	FlagNoDA                KeyAttributes = 0x00000400
+	FlagSign                KeyAttributes = 0x00040000
+)
+
+// Template is the public area template (TPMT_PUBLIC) used to create
+// a primary key. Only the fields relevant to signing keys are included.
+type Template struct {
+	// Type is AlgRSA or AlgECC
+	Type       Algorithm
+	NameAlg    Algorithm
+	Attributes KeyAttributes
+	// KeyBits is the RSA modulus size. Only used for RSA keys
+	KeyBits uint16
+	// Curve is the elliptic curve. Only used for ECC keys
+	Curve Curve
+	// Unique is the value of the unique field (unique.rsa for RSA keys,
+	// unique.ecc.x for ECC keys), which selects the key derived from
+	// the hierarchy's seed
+	Unique []byte
+}
+
+// SigScheme is the signature scheme (TPMT_SIG_SCHEME) passed to TPM.Sign
+type SigScheme struct {
+	// Alg is AlgRSASSA, AlgRSAPSS, or AlgECDSA
+	Alg  Algorithm
+	Hash Algorithm
+}
+
+// Signature is the signature (TPMT_SIGNATURE) returned by TPM.Sign.
+// RSA is populated for RSA schemes, and R and S for ECDSA.
+type Signature struct {
+	RSA  []byte
+	R, S *big.Int
+}
+
+// TPM is the subset of the TPM 2.0 command set required by this package.
+// Authorization for the keys and hierarchies (if any) is left to the
+// implementation.
+type TPM interface {
+	// CreatePrimary corresponds to TPM2_CreatePrimary, and returns
+	// the handle of the transient key and its public key
+	CreatePrimary(hierarchy Handle, template *Template) (Handle, crypto.PublicKey, error)
+	// ReadPublic corresponds to TPM2_ReadPublic
+	ReadPublic(key Handle) (crypto.PublicKey, error)
+	// Sign corresponds to TPM2_Sign, with a NULL validation ticket
+	Sign(key Handle, digest []byte, scheme *SigScheme) (*Signature, error)
+	// FlushContext corresponds to TPM2_FlushContext
+	FlushContext(Handle) error
+}
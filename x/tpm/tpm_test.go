@@ -0,0 +1,351 @@
+package tpm_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/x/tpm"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPersistentHandle tpm.Handle = 0x81000001
+
+// fakeTPM emulates a TPM using local private keys. Primary keys are
+// generated once per hierarchy and template, which mimics the
+// deterministic derivation from the hierarchy's seed.
+type fakeTPM struct {
+	mu        sync.Mutex
+	primaries map[string]crypto.Signer
+	loaded    map[tpm.Handle]crypto.Signer
+	next      tpm.Handle
+}
+
+func newFakeTPM() *fakeTPM {
+	return &fakeTPM{
+		primaries: make(map[string]crypto.Signer),
+		loaded:    make(map[tpm.Handle]crypto.Signer),
+		next:      0x80000000,
+	}
+}
+
+func (d *fakeTPM) CreatePrimary(hierarchy tpm.Handle, template *tpm.Template) (tpm.Handle, crypto.PublicKey, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if template.Attributes&tpm.FlagSign == 0 {
+		return 0, nil, errors.New(`TPM_RC_ATTRIBUTES`)
+	}
+
+	seed := fmt.Sprintf("%x/%d/%d/%d/%x", uint32(hierarchy), template.Type, template.KeyBits, template.Curve, template.Unique)
+	key, ok := d.primaries[seed]
+	if !ok {
+		var err error
+		switch template.Type {
+		case tpm.AlgRSA:
+			key, err = rsa.GenerateKey(rand.Reader, int(template.KeyBits))
+		case tpm.AlgECC:
+			var crv elliptic.Curve
+			switch template.Curve {
+			case tpm.CurveNISTP256:
+				crv = elliptic.P256()
+			case tpm.CurveNISTP384:
+				crv = elliptic.P384()
+			case tpm.CurveNISTP521:
+				crv = elliptic.P521()
+			default:
+				return 0, nil, errors.New(`TPM_RC_CURVE`)
+			}
+			key, err = ecdsa.GenerateKey(crv, rand.Reader)
+		default:
+			return 0, nil, errors.New(`TPM_RC_TYPE`)
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		d.primaries[seed] = key
+	}
+
+	d.next++
+	d.loaded[d.next] = key
+	return d.next, key.Public(), nil
+}
+
+func (d *fakeTPM) ReadPublic(h tpm.Handle) (crypto.PublicKey, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key, ok := d.loaded[h]
+	if !ok {
+		return nil, errors.New(`TPM_RC_HANDLE`)
+	}
+	return key.Public(), nil
+}
+
+var schemeHashes = map[tpm.Algorithm]crypto.Hash{
+	tpm.AlgSHA256: crypto.SHA256,
+	tpm.AlgSHA384: crypto.SHA384,
+	tpm.AlgSHA512: crypto.SHA512,
+}
+
+func (d *fakeTPM) Sign(h tpm.Handle, digest []byte, scheme *tpm.SigScheme) (*tpm.Signature, error) {
+	d.mu.Lock()
+	key, ok := d.loaded[h]
+	d.mu.Unlock()
+	if !ok {
+		return nil, errors.New(`TPM_RC_HANDLE`)
+	}
+
+	hash := schemeHashes[scheme.Hash]
+	switch scheme.Alg {
+	case tpm.AlgRSASSA:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), hash, digest)
+		if err != nil {
+			return nil, err
+		}
+		return &tpm.Signature{RSA: sig}, nil
+	case tpm.AlgRSAPSS:
+		sig, err := rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		if err != nil {
+			return nil, err
+		}
+		return &tpm.Signature{RSA: sig}, nil
+	case tpm.AlgECDSA:
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest)
+		if err != nil {
+			return nil, err
+		}
+		return &tpm.Signature{R: r, S: s}, nil
+	default:
+		return nil, errors.New(`TPM_RC_SCHEME`)
+	}
+}
+
+func (d *fakeTPM) FlushContext(h tpm.Handle) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.loaded[h]; !ok {
+		return errors.New(`TPM_RC_HANDLE`)
+	}
+	delete(d.loaded, h)
+	return nil
+}
+
+func TestDeriveKey(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("Lorem ipsum")
+	algs := []jwa.SignatureAlgorithm{jwa.RS256, jwa.PS256, jwa.ES256, jwa.ES384, jwa.ES512}
+	for _, alg := range algs {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			t.Parallel()
+
+			device := newFakeTPM()
+			signer, err := tpm.DeriveKey(device, alg, `device-identity`)
+			if !assert.NoError(t, err, `tpm.DeriveKey should succeed`) {
+				return
+			}
+			defer signer.Close()
+
+			if !assert.Equal(t, alg, signer.Algorithm(), `algorithm should match`) {
+				return
+			}
+
+			signed, err := jws.Sign(payload, alg, signer)
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+
+			pubkey, err := signer.PublicJWK()
+			if !assert.NoError(t, err, `signer.PublicJWK should succeed`) {
+				return
+			}
+			if !assert.Equal(t, signer.KeyID(), pubkey.KeyID(), `kid should match`) {
+				return
+			}
+
+			verified, err := jws.Verify(signed, alg, pubkey)
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payload should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Stable identity", func(t *testing.T) {
+		t.Parallel()
+
+		device := newFakeTPM()
+		kids := make(map[string]struct{})
+		for _, label := range []string{`device-identity`, `device-identity`, `other`} {
+			signer, err := tpm.DeriveKey(device, jwa.ES256, label)
+			if !assert.NoError(t, err, `tpm.DeriveKey should succeed`) {
+				return
+			}
+			kids[signer.KeyID()] = struct{}{}
+			if !assert.NoError(t, signer.Close(), `signer.Close should succeed`) {
+				return
+			}
+		}
+		if !assert.Len(t, kids, 2, `the same label should derive the same key`) {
+			return
+		}
+		if !assert.Empty(t, device.loaded, `transient keys should be flushed`) {
+			return
+		}
+
+		signer, err := tpm.DeriveKey(device, jwa.ES256, `device-identity`, tpm.WithHierarchy(tpm.HandleOwner))
+		if !assert.NoError(t, err, `tpm.DeriveKey should succeed`) {
+			return
+		}
+		defer signer.Close()
+		if _, ok := kids[signer.KeyID()]; !assert.False(t, ok, `different hierarchies should derive different keys`) {
+			return
+		}
+	})
+	t.Run("Device identity token", func(t *testing.T) {
+		t.Parallel()
+
+		signer, err := tpm.DeriveKey(newFakeTPM(), jwa.ES256, `device-identity`)
+		if !assert.NoError(t, err, `tpm.DeriveKey should succeed`) {
+			return
+		}
+		defer signer.Close()
+
+		token := jwt.New()
+		_ = token.Set(jwt.SubjectKey, signer.KeyID())
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.KeyIDKey, signer.KeyID())
+		signed, err := jwt.Sign(token, signer.Algorithm(), signer, jwt.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+
+		pubkey, err := signer.PublicJWK()
+		if !assert.NoError(t, err, `signer.PublicJWK should succeed`) {
+			return
+		}
+		parsed, err := jwt.Parse(signed, jwt.WithVerify(signer.Algorithm(), pubkey))
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, signer.KeyID(), parsed.Subject(), `subject should match`) {
+			return
+		}
+	})
+	t.Run("Unsupported algorithm", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := tpm.DeriveKey(newFakeTPM(), jwa.HS256, `device-identity`)
+		if !assert.Error(t, err, `tpm.DeriveKey should fail`) {
+			return
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	device := newFakeTPM()
+	device.loaded[testPersistentHandle] = rsakey
+
+	t.Run("Default algorithm", func(t *testing.T) {
+		t.Parallel()
+
+		signer, err := tpm.New(device, testPersistentHandle)
+		if !assert.NoError(t, err, `tpm.New should succeed`) {
+			return
+		}
+		if !assert.Equal(t, jwa.RS256, signer.Algorithm(), `algorithm should be RS256`) {
+			return
+		}
+		if !assert.Equal(t, &rsakey.PublicKey, signer.Public(), `public key should match`) {
+			return
+		}
+	})
+	t.Run("WithAlgorithm", func(t *testing.T) {
+		t.Parallel()
+
+		signer, err := tpm.New(device, testPersistentHandle, tpm.WithAlgorithm(jwa.PS384))
+		if !assert.NoError(t, err, `tpm.New should succeed`) {
+			return
+		}
+
+		signed, err := jws.Sign([]byte("Lorem ipsum"), jwa.PS384, signer)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.PS384, &rsakey.PublicKey)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+
+		// persistent keys must not be flushed
+		if !assert.NoError(t, signer.Close(), `signer.Close should succeed`) {
+			return
+		}
+		if !assert.Contains(t, device.loaded, testPersistentHandle, `persistent key should not be flushed`) {
+			return
+		}
+	})
+	t.Run("Algorithm mismatch", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := tpm.New(device, testPersistentHandle, tpm.WithAlgorithm(jwa.ES256))
+		if !assert.Error(t, err, `tpm.New should fail`) {
+			return
+		}
+
+		signer, err := tpm.New(device, testPersistentHandle)
+		if !assert.NoError(t, err, `tpm.New should succeed`) {
+			return
+		}
+		_, err = jws.Sign([]byte("Lorem ipsum"), jwa.RS384, signer)
+		if !assert.Error(t, err, `jws.Sign with a different algorithm should fail`) {
+			return
+		}
+	})
+	t.Run("SignContext", func(t *testing.T) {
+		t.Parallel()
+
+		signer, err := tpm.New(device, testPersistentHandle)
+		if !assert.NoError(t, err, `tpm.New should succeed`) {
+			return
+		}
+		h := crypto.SHA256.New()
+		h.Write([]byte("Lorem ipsum"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = signer.SignContext(ctx, rand.Reader, h.Sum(nil), crypto.SHA256)
+		if !assert.True(t, errors.Is(err, context.Canceled), `signer.SignContext should fail with context.Canceled (got %v)`, err) {
+			return
+		}
+	})
+	t.Run("Unknown handle", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := tpm.New(device, 0x81000002)
+		if !assert.Error(t, err, `tpm.New should fail`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,213 @@
+// Package vaulttransit signs and verifies data using the transit
+// secrets engine of HashiCorp Vault, so that applications can issue
+// JWTs without ever holding private keys in process memory.
+//
+//	cl := vaulttransit.NewClient("https://vault.example.com:8200", token)
+//	signer, err := cl.Signer(ctx, "jwt-signing")
+//	hdrs := jws.NewHeaders()
+//	hdrs.Set(jws.KeyIDKey, signer.KeyID())
+//	signed, err := jwt.Sign(token, signer.Algorithm(), signer, jwt.WithHeaders(hdrs))
+//
+// Verifiers can fetch all versions of the public key as a jwk.Set,
+// where each key carries a versioned key ID:
+//
+//	set, err := cl.KeySet(ctx, "jwt-signing")
+//	token, err := jwt.Parse(signed, jwt.WithKeySet(set))
+package vaulttransit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/pkg/errors"
+)
+
+// Client is a minimal client for the transit secrets engine
+type Client struct {
+	addr       string
+	httpClient *http.Client
+	mount      string
+	namespace  string
+	token      string
+}
+
+// NewClient creates a new Client. addr is the address of the Vault
+// server (e.g. "https://vault.example.com:8200"), and token is the
+// Vault token used to authenticate requests.
+func NewClient(addr, token string, options ...ClientOption) *Client {
+	httpClient := http.DefaultClient
+	mount := "transit"
+	var namespace string
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identHTTPClient{}:
+			httpClient = option.Value().(*http.Client)
+		case identMount{}:
+			mount = option.Value().(string)
+		case identNamespace{}:
+			namespace = option.Value().(string)
+		}
+	}
+
+	return &Client{
+		addr:       strings.TrimSuffix(addr, "/"),
+		httpClient: httpClient,
+		mount:      strings.Trim(mount, "/"),
+		namespace:  namespace,
+		token:      token,
+	}
+}
+
+type keyVersion struct {
+	PublicKey string `json:"public_key"`
+}
+
+type keyInfo struct {
+	Type          string                     `json:"type"`
+	LatestVersion int                        `json:"latest_version"`
+	Keys          map[string]json.RawMessage `json:"keys"`
+}
+
+type signRequest struct {
+	Input              string `json:"input"`
+	KeyVersion         int    `json:"key_version,omitempty"`
+	Prehashed          bool   `json:"prehashed,omitempty"`
+	SignatureAlgorithm string `json:"signature_algorithm,omitempty"`
+	SaltLength         string `json:"salt_length,omitempty"`
+}
+
+type signResponse struct {
+	Signature  string `json:"signature"`
+	KeyVersion int    `json:"key_version"`
+}
+
+type verifyRequest struct {
+	Input              string `json:"input"`
+	Signature          string `json:"signature"`
+	Prehashed          bool   `json:"prehashed,omitempty"`
+	SignatureAlgorithm string `json:"signature_algorithm,omitempty"`
+	SaltLength         string `json:"salt_length,omitempty"`
+}
+
+type verifyResponse struct {
+	Valid bool `json:"valid"`
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return errors.Wrap(err, `failed to marshal request`)
+		}
+	}
+
+	u := c.addr + "/v1/" + c.mount + "/" + path
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, `failed to create request`)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", c.token)
+	req.Header.Set("X-Vault-Request", "true")
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, `failed to call %s %s`, method, u)
+	}
+	defer res.Body.Close()
+
+	resbody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return errors.Wrap(err, `failed to read response body`)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var verr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(resbody, &verr) == nil && len(verr.Errors) > 0 {
+			return errors.Errorf(`%s %s failed (status %d): %s`, method, u, res.StatusCode, strings.Join(verr.Errors, ", "))
+		}
+		return errors.Errorf(`%s %s failed (status %d)`, method, u, res.StatusCode)
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(resbody, &envelope); err != nil {
+		return errors.Wrap(err, `failed to unmarshal response`)
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return errors.Wrap(err, `failed to unmarshal response data`)
+	}
+	return nil
+}
+
+func (c *Client) readKey(ctx context.Context, name string) (*keyInfo, error) {
+	var info keyInfo
+	if err := c.do(ctx, http.MethodGet, "keys/"+url.PathEscape(name), nil, &info); err != nil {
+		return nil, errors.Wrapf(err, `failed to read transit key %s`, name)
+	}
+	return &info, nil
+}
+
+func (c *Client) sign(ctx context.Context, name, hashAlgorithm string, in *signRequest) (*signResponse, error) {
+	path := "sign/" + url.PathEscape(name)
+	if hashAlgorithm != "" {
+		path += "/" + hashAlgorithm
+	}
+
+	var out signResponse
+	if err := c.do(ctx, http.MethodPost, path, in, &out); err != nil {
+		return nil, errors.Wrapf(err, `failed to sign using transit key %s`, name)
+	}
+	return &out, nil
+}
+
+func (c *Client) verify(ctx context.Context, name, hashAlgorithm string, in *verifyRequest) (bool, error) {
+	path := "verify/" + url.PathEscape(name)
+	if hashAlgorithm != "" {
+		path += "/" + hashAlgorithm
+	}
+
+	var out verifyResponse
+	if err := c.do(ctx, http.MethodPost, path, in, &out); err != nil {
+		return false, errors.Wrapf(err, `failed to verify using transit key %s`, name)
+	}
+	return out.Valid, nil
+}
+
+// KeyID returns the versioned key ID used for the given version of the
+// named transit key, e.g. "jwt-signing:v2"
+func KeyID(name string, version int) string {
+	return fmt.Sprintf("%s:v%d", name, version)
+}
+
+// parseSignature parses the "vault:v1:..." signature format
+func parseSignature(s string) (int, string, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return 0, "", errors.Errorf(`invalid signature format %q`, s)
+	}
+	version, err := strconv.Atoi(parts[1][1:])
+	if err != nil {
+		return 0, "", errors.Wrapf(err, `invalid signature version in %q`, s)
+	}
+	return version, parts[2], nil
+}
@@ -0,0 +1,104 @@
+package vaulttransit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+// ClientOption describes an option that can be passed to NewClient
+type ClientOption interface {
+	Option
+	clientOption()
+}
+
+type clientOption struct {
+	Option
+}
+
+func (*clientOption) clientOption() {}
+
+// SignerOption describes an option that can be passed to Client.Signer
+type SignerOption interface {
+	Option
+	signerOption()
+}
+
+type signerOption struct {
+	Option
+}
+
+func (*signerOption) signerOption() {}
+
+// KeySetOption describes an option that can be passed to Client.KeySet
+type KeySetOption interface {
+	Option
+	keySetOption()
+}
+
+// SignerKeySetOption describes an option that can be passed to both
+// Client.Signer and Client.KeySet
+type SignerKeySetOption interface {
+	Option
+	signerOption()
+	keySetOption()
+}
+
+type signerKeySetOption struct {
+	Option
+}
+
+func (*signerKeySetOption) signerOption() {}
+func (*signerKeySetOption) keySetOption() {}
+
+type identAlgorithm struct{}
+type identContext struct{}
+type identHTTPClient struct{}
+type identKeyVersion struct{}
+type identMount struct{}
+type identNamespace struct{}
+
+// WithHTTPClient specifies the *http.Client to use when calling Vault.
+// By default http.DefaultClient is used.
+func WithHTTPClient(cl *http.Client) ClientOption {
+	return &clientOption{option.New(identHTTPClient{}, cl)}
+}
+
+// WithMount specifies the path where the transit secrets engine is
+// mounted. The default is "transit".
+func WithMount(s string) ClientOption {
+	return &clientOption{option.New(identMount{}, s)}
+}
+
+// WithNamespace specifies the Vault Enterprise namespace to use.
+func WithNamespace(s string) ClientOption {
+	return &clientOption{option.New(identNamespace{}, s)}
+}
+
+// WithAlgorithm specifies the JWS algorithm to use with RSA keys. Valid
+// values are RS256, RS384, RS512, PS256, PS384, and PS512, and the
+// default is RS256. The algorithms for ECDSA and Ed25519 keys are
+// determined by the key type, and cannot be changed.
+func WithAlgorithm(alg jwa.SignatureAlgorithm) SignerKeySetOption {
+	return &signerKeySetOption{option.New(identAlgorithm{}, alg)}
+}
+
+// WithContext specifies the context.Context object to use when calling
+// Vault. As `crypto.Signer` does not accept a context, this context is
+// used for all subsequent calls to `Sign()`. Calls to `SignContext()`,
+// such as those made by `jws.SignContext()`, use the context passed to
+// them instead.
+// By default `context.Background()` is used.
+func WithContext(ctx context.Context) SignerOption {
+	return &signerOption{option.New(identContext{}, ctx)}
+}
+
+// WithKeyVersion specifies the version of the transit key to sign with.
+// By default the latest version at the time the signer is created is used.
+func WithKeyVersion(v int) SignerOption {
+	return &signerOption{option.New(identKeyVersion{}, v)}
+}
@@ -0,0 +1,327 @@
+package vaulttransit
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/x/internal/signerutil"
+	"github.com/pkg/errors"
+)
+
+var hashAlgorithms = map[crypto.Hash]string{
+	crypto.SHA256: "sha2-256",
+	crypto.SHA384: "sha2-384",
+	crypto.SHA512: "sha2-512",
+}
+
+var rsaHashes = map[jwa.SignatureAlgorithm]crypto.Hash{
+	jwa.RS256: crypto.SHA256,
+	jwa.RS384: crypto.SHA384,
+	jwa.RS512: crypto.SHA512,
+	jwa.PS256: crypto.SHA256,
+	jwa.PS384: crypto.SHA384,
+	jwa.PS512: crypto.SHA512,
+}
+
+// algorithm returns the JWS algorithm and the hash function for the
+// given transit key type. rsaAlg is used for RSA keys.
+func algorithm(keyType string, rsaAlg jwa.SignatureAlgorithm) (jwa.SignatureAlgorithm, crypto.Hash, error) {
+	switch keyType {
+	case "ecdsa-p256":
+		return jwa.ES256, crypto.SHA256, nil
+	case "ecdsa-p384":
+		return jwa.ES384, crypto.SHA384, nil
+	case "ecdsa-p521":
+		return jwa.ES512, crypto.SHA512, nil
+	case "ed25519":
+		return jwa.EdDSA, 0, nil
+	case "rsa-2048", "rsa-3072", "rsa-4096":
+		if rsaAlg == "" {
+			rsaAlg = jwa.RS256
+		}
+		h, ok := rsaHashes[rsaAlg]
+		if !ok {
+			return "", 0, errors.Errorf(`signature algorithm %s cannot be used with transit key of type %s`, rsaAlg, keyType)
+		}
+		return rsaAlg, h, nil
+	}
+	return "", 0, errors.Errorf(`unsupported transit key type %q`, keyType)
+}
+
+func parsePublicKey(keyType string, data json.RawMessage) (crypto.PublicKey, error) {
+	var v keyVersion
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, errors.Wrap(err, `failed to unmarshal key version`)
+	}
+	if v.PublicKey == "" {
+		return nil, errors.New(`missing public key`)
+	}
+
+	if keyType == "ed25519" {
+		buf, err := base64.StdEncoding.DecodeString(v.PublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to decode ed25519 public key`)
+		}
+		if len(buf) != ed25519.PublicKeySize {
+			return nil, errors.New(`invalid ed25519 public key size`)
+		}
+		return ed25519.PublicKey(buf), nil
+	}
+
+	block, _ := pem.Decode([]byte(v.PublicKey))
+	if block == nil {
+		return nil, errors.New(`failed to decode PEM encoded public key`)
+	}
+	pubkey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to parse public key`)
+	}
+	return pubkey, nil
+}
+
+// KeySet fetches all versions of the public key of the named transit
+// key, and returns them as a jwk.Set. Each key has its "kid" field set
+// to the versioned key ID (see KeyID), and "alg" and "use" populated.
+func (c *Client) KeySet(ctx context.Context, name string, options ...KeySetOption) (jwk.Set, error) {
+	var rsaAlg jwa.SignatureAlgorithm
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identAlgorithm{}:
+			rsaAlg = option.Value().(jwa.SignatureAlgorithm)
+		}
+	}
+
+	info, err := c.readKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, _, err := algorithm(info.Type, rsaAlg)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(info.Keys))
+	for s := range info.Keys {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, `invalid key version %q`, s)
+		}
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+
+	set := jwk.NewSet()
+	for _, v := range versions {
+		pubkey, err := parsePublicKey(info.Type, info.Keys[strconv.Itoa(v)])
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to parse public key for version %d of %s`, v, name)
+		}
+		key, err := signerutil.PublicJWK(pubkey, KeyID(name, v), alg)
+		if err != nil {
+			return nil, err
+		}
+		set.Add(key)
+	}
+	return set, nil
+}
+
+// Signer is a crypto.Signer that delegates signing operations to the
+// transit secrets engine. The private key never leaves Vault.
+type Signer struct {
+	alg     jwa.SignatureAlgorithm
+	client  *Client
+	ctx     context.Context
+	hash    crypto.Hash
+	name    string
+	pubkey  crypto.PublicKey
+	version int
+}
+
+// Signer creates a crypto.Signer for the named transit key. The key
+// must be an asymmetric key (ecdsa-p256, ecdsa-p384, ecdsa-p521,
+// ed25519, rsa-2048, rsa-3072, or rsa-4096).
+func (c *Client) Signer(ctx context.Context, name string, options ...SignerOption) (*Signer, error) {
+	var rsaAlg jwa.SignatureAlgorithm
+	var version int
+	signctx := context.Background()
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identAlgorithm{}:
+			rsaAlg = option.Value().(jwa.SignatureAlgorithm)
+		case identContext{}:
+			signctx = option.Value().(context.Context)
+		case identKeyVersion{}:
+			version = option.Value().(int)
+		}
+	}
+
+	info, err := c.readKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, hash, err := algorithm(info.Type, rsaAlg)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 0 {
+		version = info.LatestVersion
+	}
+	data, ok := info.Keys[strconv.Itoa(version)]
+	if !ok {
+		return nil, errors.Errorf(`version %d of transit key %s does not exist`, version, name)
+	}
+	pubkey, err := parsePublicKey(info.Type, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to parse public key for version %d of %s`, version, name)
+	}
+
+	return &Signer{
+		alg:     alg,
+		client:  c,
+		ctx:     signctx,
+		hash:    hash,
+		name:    name,
+		pubkey:  pubkey,
+		version: version,
+	}, nil
+}
+
+// Algorithm returns the JWS algorithm used by this signer
+func (s *Signer) Algorithm() jwa.SignatureAlgorithm {
+	return s.alg
+}
+
+// KeyID returns the versioned key ID of the key used by this signer
+func (s *Signer) KeyID() string {
+	return KeyID(s.name, s.version)
+}
+
+// Public returns the public key of the key version used by this signer
+func (s *Signer) Public() crypto.PublicKey {
+	return s.pubkey
+}
+
+// PublicJWK returns the public key as a jwk.Key, with the "kid", "alg"
+// and "use" fields populated.
+func (s *Signer) PublicJWK() (jwk.Key, error) {
+	return signerutil.PublicJWK(s.pubkey, s.KeyID(), s.alg)
+}
+
+func (s *Signer) params(opts crypto.SignerOpts) (string, string, error) {
+	if opts == nil || opts.HashFunc() != s.hash {
+		return "", "", errors.Errorf(`hash function does not match algorithm %s`, s.alg)
+	}
+
+	if _, ok := s.pubkey.(*rsa.PublicKey); !ok {
+		return hashAlgorithms[s.hash], "", nil
+	}
+
+	_, isPSS := opts.(*rsa.PSSOptions)
+	switch s.alg {
+	case jwa.PS256, jwa.PS384, jwa.PS512:
+		if !isPSS {
+			return "", "", errors.Errorf(`algorithm %s requires *rsa.PSSOptions`, s.alg)
+		}
+		return hashAlgorithms[s.hash], "pss", nil
+	default:
+		if isPSS {
+			return "", "", errors.Errorf(`algorithm %s cannot be used with *rsa.PSSOptions`, s.alg)
+		}
+		return hashAlgorithms[s.hash], "pkcs1v15", nil
+	}
+}
+
+// Sign signs the given digest using Vault, using the context specified
+// by WithContext(). See SignContext for details.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(s.ctx, rand, digest, opts)
+}
+
+// SignContext signs the given digest using Vault. For Ed25519 keys,
+// digest is the message itself, and opts must specify crypto.Hash(0).
+// ECDSA signatures are returned in ASN.1 DER format, as required by
+// crypto.Signer. The rand parameter is ignored.
+//
+// SignContext is called by jws.SignContext() and jwt.SignContext(),
+// so that their context is used for the request to Vault.
+func (s *Signer) SignContext(ctx context.Context, _ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hashAlgorithm, signatureAlgorithm, err := s.params(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &signRequest{
+		Input:              base64.StdEncoding.EncodeToString(digest),
+		KeyVersion:         s.version,
+		Prehashed:          s.hash != 0,
+		SignatureAlgorithm: signatureAlgorithm,
+	}
+	if signatureAlgorithm == "pss" {
+		in.SaltLength = "hash"
+	}
+
+	out, err := s.client.sign(ctx, s.name, hashAlgorithm, in)
+	if err != nil {
+		return nil, err
+	}
+
+	version, encoded, err := parseSignature(out.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if version != s.version {
+		return nil, errors.Errorf(`signature was created using version %d of %s (expected %d)`, version, s.name, s.version)
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode signature`)
+	}
+	return signature, nil
+}
+
+// Verify verifies the signature of the given digest using Vault. The
+// signature must be in the format returned by Sign. Note that
+// signatures can also be verified locally using the public key, which
+// is usually preferable.
+func (s *Signer) Verify(digest, signature []byte, opts crypto.SignerOpts) error {
+	hashAlgorithm, signatureAlgorithm, err := s.params(opts)
+	if err != nil {
+		return err
+	}
+
+	in := &verifyRequest{
+		Input:              base64.StdEncoding.EncodeToString(digest),
+		Signature:          strings.Join([]string{"vault", "v" + strconv.Itoa(s.version), base64.StdEncoding.EncodeToString(signature)}, ":"),
+		Prehashed:          s.hash != 0,
+		SignatureAlgorithm: signatureAlgorithm,
+	}
+	if signatureAlgorithm == "pss" {
+		in.SaltLength = "hash"
+	}
+
+	valid, err := s.client.verify(s.ctx, s.name, hashAlgorithm, in)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New(`invalid signature`)
+	}
+	return nil
+}
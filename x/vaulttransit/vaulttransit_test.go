@@ -0,0 +1,340 @@
+package vaulttransit_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/x/vaulttransit"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testToken = `s.jwx-test-token`
+
+type transitKey struct {
+	keyType  string
+	versions []crypto.Signer
+}
+
+// fakeTransit emulates the relevant parts of the transit secrets engine
+type fakeTransit struct {
+	mu   sync.Mutex
+	keys map[string]*transitKey
+}
+
+func (f *fakeTransit) writeError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{msg}})
+}
+
+func (f *fakeTransit) writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != testToken {
+		f.writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	// /v1/transit/{op}/{name}[/{hash}]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/transit/"), "/")
+	if len(parts) < 2 {
+		f.writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	key, ok := f.keys[parts[1]]
+	if !ok {
+		f.writeError(w, http.StatusBadRequest, "key not found")
+		return
+	}
+
+	var hash crypto.Hash
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "sha2-256":
+			hash = crypto.SHA256
+		case "sha2-384":
+			hash = crypto.SHA384
+		case "sha2-512":
+			hash = crypto.SHA512
+		default:
+			f.writeError(w, http.StatusBadRequest, "unsupported hash")
+			return
+		}
+	}
+
+	switch parts[0] {
+	case "keys":
+		keys := make(map[string]interface{})
+		for i, signer := range key.versions {
+			var encoded string
+			if pubkey, ok := signer.Public().(ed25519.PublicKey); ok {
+				encoded = base64.StdEncoding.EncodeToString(pubkey)
+			} else {
+				der, _ := x509.MarshalPKIXPublicKey(signer.Public())
+				encoded = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			}
+			keys[strconv.Itoa(i+1)] = map[string]interface{}{"public_key": encoded}
+		}
+		f.writeData(w, map[string]interface{}{
+			"type":           key.keyType,
+			"latest_version": len(key.versions),
+			"keys":           keys,
+		})
+	case "sign":
+		var req struct {
+			Input              string `json:"input"`
+			KeyVersion         int    `json:"key_version"`
+			Prehashed          bool   `json:"prehashed"`
+			SignatureAlgorithm string `json:"signature_algorithm"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.KeyVersion < 1 || req.KeyVersion > len(key.versions) {
+			f.writeError(w, http.StatusBadRequest, "invalid key version")
+			return
+		}
+		input, _ := base64.StdEncoding.DecodeString(req.Input)
+		if hash != 0 && (!req.Prehashed || len(input) != hash.Size()) {
+			f.writeError(w, http.StatusBadRequest, "expected prehashed input")
+			return
+		}
+
+		var opts crypto.SignerOpts = hash
+		if req.SignatureAlgorithm == "pss" {
+			opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+		}
+		signature, err := key.versions[req.KeyVersion-1].Sign(rand.Reader, input, opts)
+		if err != nil {
+			f.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		f.writeData(w, map[string]interface{}{
+			"signature":   "vault:v" + strconv.Itoa(req.KeyVersion) + ":" + base64.StdEncoding.EncodeToString(signature),
+			"key_version": req.KeyVersion,
+		})
+	case "verify":
+		var req struct {
+			Input     string `json:"input"`
+			Signature string `json:"signature"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		input, _ := base64.StdEncoding.DecodeString(req.Input)
+		sigparts := strings.SplitN(req.Signature, ":", 3)
+		version, _ := strconv.Atoi(strings.TrimPrefix(sigparts[1], "v"))
+		signature, _ := base64.StdEncoding.DecodeString(sigparts[2])
+
+		var valid bool
+		switch pubkey := key.versions[version-1].Public().(type) {
+		case *ecdsa.PublicKey:
+			valid = ecdsa.VerifyASN1(pubkey, input, signature)
+		case ed25519.PublicKey:
+			valid = ed25519.Verify(pubkey, input, signature)
+		case *rsa.PublicKey:
+			valid = rsa.VerifyPKCS1v15(pubkey, hash, input, signature) == nil
+		}
+		f.writeData(w, map[string]interface{}{"valid": valid})
+	default:
+		f.writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey1, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	eckey2, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	edkey, err := jwxtest.GenerateEd25519Key()
+	if !assert.NoError(t, err, `jwxtest.GenerateEd25519Key should succeed`) {
+		return
+	}
+
+	srv := httptest.NewServer(&fakeTransit{
+		keys: map[string]*transitKey{
+			"rsa":     {keyType: "rsa-2048", versions: []crypto.Signer{rsakey}},
+			"ecdsa":   {keyType: "ecdsa-p256", versions: []crypto.Signer{eckey1, eckey2}},
+			"ed25519": {keyType: "ed25519", versions: []crypto.Signer{edkey}},
+		},
+	})
+	defer srv.Close()
+
+	cl := vaulttransit.NewClient(srv.URL, testToken, vaulttransit.WithHTTPClient(srv.Client()))
+	ctx := context.Background()
+
+	testcases := []struct {
+		Name      string
+		Options   []vaulttransit.SignerOption
+		Algorithm jwa.SignatureAlgorithm
+		KeyID     string
+	}{
+		{Name: "rsa", Algorithm: jwa.RS256, KeyID: "rsa:v1"},
+		{Name: "rsa", Options: []vaulttransit.SignerOption{vaulttransit.WithAlgorithm(jwa.PS384)}, Algorithm: jwa.PS384, KeyID: "rsa:v1"},
+		{Name: "ecdsa", Algorithm: jwa.ES256, KeyID: "ecdsa:v2"},
+		{Name: "ecdsa", Options: []vaulttransit.SignerOption{vaulttransit.WithKeyVersion(1)}, Algorithm: jwa.ES256, KeyID: "ecdsa:v1"},
+		{Name: "ed25519", Algorithm: jwa.EdDSA, KeyID: "ed25519:v1"},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.KeyID+"/"+tc.Algorithm.String(), func(t *testing.T) {
+			signer, err := cl.Signer(ctx, tc.Name, tc.Options...)
+			if !assert.NoError(t, err, `cl.Signer should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Algorithm, signer.Algorithm(), `algorithm should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.KeyID, signer.KeyID(), `kid should match`) {
+				return
+			}
+
+			var setOptions []vaulttransit.KeySetOption
+			if tc.Algorithm != jwa.RS256 && tc.Name == "rsa" {
+				setOptions = append(setOptions, vaulttransit.WithAlgorithm(tc.Algorithm))
+			}
+			set, err := cl.KeySet(ctx, tc.Name, setOptions...)
+			if !assert.NoError(t, err, `cl.KeySet should succeed`) {
+				return
+			}
+
+			token := jwt.New()
+			_ = token.Set(jwt.IssuerKey, `github.com/lestrrat-go/jwx`)
+			_ = token.Set(jwt.IssuedAtKey, time.Now())
+
+			hdrs := jws.NewHeaders()
+			_ = hdrs.Set(jws.KeyIDKey, signer.KeyID())
+			signed, err := jwt.Sign(token, signer.Algorithm(), signer, jwt.WithHeaders(hdrs))
+			if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+				return
+			}
+
+			parsed, err := jwt.Parse(signed, jwt.WithKeySet(set))
+			if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+				return
+			}
+			if !assert.Equal(t, `github.com/lestrrat-go/jwx`, parsed.Issuer(), `issuer should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("KeySet", func(t *testing.T) {
+		set, err := cl.KeySet(ctx, "ecdsa")
+		if !assert.NoError(t, err, `cl.KeySet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, set.Len(), `key set should contain all versions`) {
+			return
+		}
+		for _, kid := range []string{"ecdsa:v1", "ecdsa:v2"} {
+			key, ok := set.LookupKeyID(kid)
+			if !assert.True(t, ok, `key set should contain %s`, kid) {
+				return
+			}
+			if !assert.Equal(t, jwa.ES256.String(), key.Algorithm(), `"alg" should be populated`) {
+				return
+			}
+		}
+	})
+	t.Run("Verify", func(t *testing.T) {
+		signer, err := cl.Signer(ctx, "ecdsa")
+		if !assert.NoError(t, err, `cl.Signer should succeed`) {
+			return
+		}
+		h := crypto.SHA256.New()
+		h.Write([]byte(`Lorem ipsum`))
+		digest := h.Sum(nil)
+
+		signature, err := signer.Sign(rand.Reader, digest, crypto.SHA256)
+		if !assert.NoError(t, err, `signer.Sign should succeed`) {
+			return
+		}
+		if !assert.NoError(t, signer.Verify(digest, signature, crypto.SHA256), `signer.Verify should succeed`) {
+			return
+		}
+		digest[0] ^= 0xff
+		if !assert.Error(t, signer.Verify(digest, signature, crypto.SHA256), `signer.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("SignContext", func(t *testing.T) {
+		// the signer was created with a live context, so the request only
+		// fails if the per-call context reaches the HTTP client
+		signer, err := cl.Signer(ctx, "ecdsa")
+		if !assert.NoError(t, err, `cl.Signer should succeed`) {
+			return
+		}
+		h := crypto.SHA256.New()
+		h.Write([]byte(`Lorem ipsum`))
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = signer.SignContext(cctx, rand.Reader, h.Sum(nil), crypto.SHA256)
+		if !assert.True(t, errors.Is(err, context.Canceled), `signer.SignContext should fail with context.Canceled (got %v)`, err) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		if _, err := cl.Signer(ctx, "does-not-exist"); !assert.Error(t, err, `cl.Signer should fail for missing keys`) {
+			return
+		}
+		if _, err := cl.Signer(ctx, "ecdsa", vaulttransit.WithKeyVersion(3)); !assert.Error(t, err, `cl.Signer should fail for missing versions`) {
+			return
+		}
+		if _, err := cl.Signer(ctx, "ecdsa", vaulttransit.WithAlgorithm(jwa.PS256)); err != nil {
+			// WithAlgorithm only applies to RSA keys
+			t.Errorf(`cl.Signer should succeed: %s`, err)
+			return
+		}
+		if _, err := cl.Signer(ctx, "rsa", vaulttransit.WithAlgorithm(jwa.ES256)); !assert.Error(t, err, `cl.Signer should fail for invalid RSA algorithms`) {
+			return
+		}
+
+		bad := vaulttransit.NewClient(srv.URL, `bad-token`, vaulttransit.WithHTTPClient(srv.Client()))
+		_, err := bad.Signer(ctx, "ecdsa")
+		if !assert.Error(t, err, `cl.Signer should fail with a bad token`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `permission denied`, `error should contain the Vault error`) {
+			return
+		}
+	})
+}